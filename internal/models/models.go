@@ -10,6 +10,7 @@ type Instance struct {
 	ImageRelease  string
 	ImageHash     string
 	IPv4          []string
+	IPv6          []string
 	CPUCount      int
 	MemoryTotal   uint64
 	MemoryUsed    uint64
@@ -18,7 +19,26 @@ type Instance struct {
 	Load          []float64
 	SnapshotCount int
 	Mounts        []Mount
+	Interfaces    []NetworkInterface
 	LastUpdated   time.Time
+	// AllInterfaces is true when Interfaces lists every NIC. Releases that
+	// do not report extra_interfaces only describe the default one.
+	AllInterfaces bool
+}
+
+// NetworkInterface describes a network interface attached to an instance
+// together with the addresses assigned to it.
+type NetworkInterface struct {
+	Name      string
+	MAC       string
+	Addresses []string
+}
+
+// VersionInfo captures the versions reported by `multipass version`.
+// Daemon is empty when multipassd could not be reached.
+type VersionInfo struct {
+	Client string
+	Daemon string
 }
 
 // Mount represents a host to instance mount binding.
@@ -26,6 +46,11 @@ type Mount struct {
 	HostPath     string
 	InstancePath string
 	ReadOnly     bool
+	// UIDMappings and GIDMappings hold ordered "host:instance" ID pairs.
+	// The instance side is "default" when Multipass maps to the default
+	// instance user/group.
+	UIDMappings []string
+	GIDMappings []string
 }
 
 // Snapshot represents a Multipass snapshot associated with an instance.
@@ -34,6 +59,9 @@ type Snapshot struct {
 	Name     string
 	Comment  string
 	Parent   string
+	Children []string
+	Created  time.Time // zero when the CLI does not report a creation time
+	Size     uint64    // bytes; zero when the CLI does not report a size
 }
 
 // ImageKind identifies whether an entry originates from regular images or blueprints.
@@ -54,6 +82,9 @@ type Image struct {
 	Version     string
 	Description string
 	Kind        ImageKind
+	// Architecture is the image's CPU architecture, e.g. amd64, or empty
+	// when unknown. `multipass find` does not report it.
+	Architecture string
 }
 
 // Network represents host network information for bridging.
@@ -66,9 +97,13 @@ type Network struct {
 // Alias models a `multipass alias` entry.
 type Alias struct {
 	Name             string
+	Context          string // alias context; empty means the active one
 	Instance         string
 	Command          string
 	WorkingDirectory string // optional explicit directory (wraps command with cd)
+	// MapWorkingDirectory mirrors the host working directory into the
+	// instance before running the command.
+	MapWorkingDirectory bool
 }
 
 // LaunchOptions controls instance creation parameters.
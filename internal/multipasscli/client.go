@@ -6,12 +6,17 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os/exec"
 	"regexp"
+	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+
 	"github.com/todoroff/terraform-provider-multipass/internal/models"
 )
 
@@ -22,47 +27,131 @@ var ansiRegex = regexp.MustCompile(`(\x1b\[[0-9;]*[A-Za-z]|\r\n|\r)`)
 // Client exposes typed helpers for interacting with the Multipass CLI.
 type Client interface {
 	Version(ctx context.Context) (string, error)
+	VersionInfo(ctx context.Context) (models.VersionInfo, error)
 	ListInstances(ctx context.Context, refresh bool) ([]models.Instance, error)
 	GetInstance(ctx context.Context, name string) (*models.Instance, error)
+	GetInstances(ctx context.Context, names []string) (map[string]*models.Instance, error)
 	LaunchInstance(ctx context.Context, opts models.LaunchOptions) error
 	Exec(ctx context.Context, instance string, command []string) error
+	ExecCapture(ctx context.Context, instance string, command []string) ([]byte, error)
+	ExecOutput(ctx context.Context, instance string, opts ExecOptions) (ExecResult, error)
 	StartInstance(ctx context.Context, name string) error
 	StopInstance(ctx context.Context, name string, force bool) error
 	SuspendInstance(ctx context.Context, name string) error
 	RestartInstance(ctx context.Context, name string) error
 	DeleteInstance(ctx context.Context, name string, purge bool) error
 	RecoverInstance(ctx context.Context, name string) error
+	CloneInstance(ctx context.Context, source, name string) error
 	SetPrimary(ctx context.Context, name string) error
-	ListImages(ctx context.Context, refresh bool) ([]models.Image, error)
+	Authenticate(ctx context.Context, passphrase string) error
+	GetSetting(ctx context.Context, key string) (string, error)
+	SetSetting(ctx context.Context, key, value string) error
+	ListSettingKeys(ctx context.Context) ([]string, error)
+	ListImages(ctx context.Context, opts ImageListOptions) ([]models.Image, error)
 	ListNetworks(ctx context.Context, refresh bool) ([]models.Network, error)
 	ListAliases(ctx context.Context, refresh bool) ([]models.Alias, error)
+	ActiveAliasContext(ctx context.Context) (string, error)
 	CreateAlias(ctx context.Context, alias models.Alias) error
 	DeleteAlias(ctx context.Context, name string) error
 	ListSnapshots(ctx context.Context, instance string) ([]models.Snapshot, error)
+	ListSnapshotDetails(ctx context.Context, instance string) ([]models.Snapshot, error)
 	CreateSnapshot(ctx context.Context, instance, name, comment string) (string, error)
 	DeleteSnapshot(ctx context.Context, instance, name string, purge bool) error
+	RestoreSnapshot(ctx context.Context, instance, name string, opts RestoreOptions) error
+	ListMounts(ctx context.Context, instance string) ([]models.Mount, error)
 	Mount(ctx context.Context, instance string, mount models.Mount) error
 	Unmount(ctx context.Context, instance string, mount models.Mount) error
 	Transfer(ctx context.Context, opts TransferOptions) error
 	TransferCapture(ctx context.Context, opts TransferOptions) ([]byte, error)
+	TransferTo(ctx context.Context, opts TransferOptions, w io.Writer) error
 }
 
 // Config controls the multipass CLI client instantiation.
 type Config struct {
 	BinaryPath string
 	Timeout    int // Seconds
+	// CacheTTL is how long listings are cached, in seconds. Nil uses the
+	// default and zero disables caching.
+	CacheTTL *int
+	// MaxParallel limits how many multipass commands run at once. Zero uses
+	// the default.
+	MaxParallel int
+	// DeferBinaryCheck postpones looking up BinaryPath until the first
+	// command, so multipass can be installed after the client is created.
+	DeferBinaryCheck bool
 }
 
 type client struct {
 	binaryPath string
 	timeout    time.Duration
+	cacheTTL   time.Duration
+	// sem holds one token per running command. A nil sem means no limit.
+	sem chan struct{}
 
 	mu sync.Mutex
 
+	// lookupPending is set while a deferred binary lookup has not yet
+	// succeeded. Failed lookups are retried by the next command.
+	lookupPending bool
+	// versionInfo caches the first complete `multipass version` answer.
+	versionInfo *models.VersionInfo
+
 	instanceCache *cacheEntry[[]models.Instance]
-	imageCache    *cacheEntry[[]models.Image]
+	imageCache    map[ImageListOptions]*cacheEntry[[]models.Image]
 	networkCache  *cacheEntry[[]models.Network]
-	aliasCache    *cacheEntry[[]models.Alias]
+	aliasCache    *cacheEntry[aliasListing]
+
+	// instanceGen and aliasGen count invalidations. A listing is only
+	// cached if no invalidation happened while it was being fetched;
+	// otherwise a listing started before a sibling resource's mutation
+	// would be served as current for the rest of the TTL.
+	instanceGen uint64
+	aliasGen    uint64
+
+	// infoCache holds the bulk `multipass info` snapshot that GetInstance
+	// serves from, and infoFetch the bulk call in progress, if any.
+	infoCache *cacheEntry[*infoSnapshot]
+	infoFetch *infoFetch
+	// bulkInfoUnsupported is set once `multipass info` without names was
+	// rejected, after which GetInstance always asks per name.
+	bulkInfoUnsupported bool
+}
+
+// aliasListing is the cached result of `multipass aliases`.
+type aliasListing struct {
+	active  string
+	aliases []models.Alias
+}
+
+// ImageListOptions controls `multipass find` behavior.
+type ImageListOptions struct {
+	// Refresh bypasses the client-side cache.
+	Refresh bool
+	// ForceUpdate maps to --force-update, which makes the daemon refetch
+	// the image manifests. It implies Refresh.
+	ForceUpdate bool
+	// OnlyImages and OnlyBlueprints map to --only-images and
+	// --only-blueprints. Setting both is rejected.
+	OnlyImages     bool
+	OnlyBlueprints bool
+}
+
+// ExecOptions controls `multipass exec` behavior for ExecOutput.
+type ExecOptions struct {
+	Command []string
+	// WorkingDirectory maps to --working-directory.
+	WorkingDirectory string
+	// ReadOnly marks a probe that does not change the instance, such as a
+	// readiness check. A dry-run client runs read-only commands and
+	// simulates all others.
+	ReadOnly bool
+}
+
+// ExecResult is the outcome of a command run through ExecOutput.
+type ExecResult struct {
+	Stdout   []byte
+	Stderr   []byte
+	ExitCode int
 }
 
 // TransferOptions controls multipass transfer behavior.
@@ -78,11 +167,23 @@ type TransferOptions struct {
 	Stdin []byte
 }
 
+// RestoreOptions controls `multipass restore`.
+type RestoreOptions struct {
+	// Destructive maps to --destructive and discards the current state
+	// without asking.
+	Destructive bool
+	// SnapshotCurrentState answers the prompt multipass shows without
+	// --destructive, taking a snapshot of the current state first. It must
+	// be false when Destructive is set.
+	SnapshotCurrentState bool
+}
+
 const (
-	defaultTimeout  = 10 * time.Minute
-	cacheTTL        = 3 * time.Second
-	jsonFormatFlag  = "--format"
-	jsonFormatValue = "json"
+	defaultTimeout     = 10 * time.Minute
+	defaultCacheTTL    = 3 * time.Second
+	defaultMaxParallel = 4
+	jsonFormatFlag     = "--format"
+	jsonFormatValue    = "json"
 )
 
 // NewClient validates the supplied configuration and returns an initialized Client.
@@ -92,10 +193,10 @@ func NewClient(ctx context.Context, cfg Config) (Client, error) {
 		binary = "multipass"
 	}
 
-	if !strings.Contains(binary, "/") && !strings.Contains(binary, "\\") {
+	if !cfg.DeferBinaryCheck {
 		// Look up in PATH to produce early errors.
-		if _, err := exec.LookPath(binary); err != nil {
-			return nil, fmt.Errorf("unable to find multipass binary %q in PATH: %w", binary, err)
+		if err := lookupBinary(binary); err != nil {
+			return nil, err
 		}
 	}
 
@@ -104,18 +205,63 @@ func NewClient(ctx context.Context, cfg Config) (Client, error) {
 		timeout = time.Duration(cfg.Timeout) * time.Second
 	}
 
+	cacheTTL := defaultCacheTTL
+	if cfg.CacheTTL != nil {
+		if *cfg.CacheTTL < 0 {
+			return nil, fmt.Errorf("cache TTL cannot be negative")
+		}
+		cacheTTL = time.Duration(*cfg.CacheTTL) * time.Second
+	}
+
+	maxParallel := defaultMaxParallel
+	if cfg.MaxParallel < 0 {
+		return nil, fmt.Errorf("max parallel commands cannot be negative")
+	}
+	if cfg.MaxParallel > 0 {
+		maxParallel = cfg.MaxParallel
+	}
+
 	return &client{
 		binaryPath: binary,
 		timeout:    timeout,
+		cacheTTL:   cacheTTL,
+		sem:        make(chan struct{}, maxParallel),
+
+		lookupPending: cfg.DeferBinaryCheck,
 	}, nil
 }
 
 func (c *client) Version(ctx context.Context) (string, error) {
+	info, err := c.VersionInfo(ctx)
+	if err != nil {
+		return "", err
+	}
+	return info.Client, nil
+}
+
+// VersionInfo reports both the CLI and daemon versions. The CLI still
+// answers when multipassd is down, in which case Daemon is left empty and
+// the next call asks again; complete answers are cached for the lifetime
+// of the client.
+func (c *client) VersionInfo(ctx context.Context) (models.VersionInfo, error) {
+	c.mu.Lock()
+	cached := c.versionInfo
+	c.mu.Unlock()
+	if cached != nil {
+		return *cached, nil
+	}
+
 	var payload versionResponse
 	if err := c.runJSON(ctx, &payload, "version"); err != nil {
-		return "", err
+		return models.VersionInfo{}, err
 	}
-	return payload.Multipass, nil
+	info := payload.toModel()
+	if info.Daemon != "" {
+		c.mu.Lock()
+		c.versionInfo = &info
+		c.mu.Unlock()
+	}
+	return info, nil
 }
 
 func (c *client) ListInstances(ctx context.Context, refresh bool) ([]models.Instance, error) {
@@ -124,6 +270,7 @@ func (c *client) ListInstances(ctx context.Context, refresh bool) ([]models.Inst
 		defer c.mu.Unlock()
 		return cloneInstances(c.instanceCache.value), nil
 	}
+	gen := c.instanceGen
 	c.mu.Unlock()
 
 	var payload listResponse
@@ -134,13 +281,24 @@ func (c *client) ListInstances(ctx context.Context, refresh bool) ([]models.Inst
 	instances := payload.toModel()
 
 	c.mu.Lock()
-	c.instanceCache = newCacheEntry(instances, cacheTTL)
+	if c.instanceGen == gen {
+		c.instanceCache = newCacheEntry(instances, c.cacheTTL)
+	}
 	c.mu.Unlock()
 
 	return cloneInstances(instances), nil
 }
 
+// GetInstance runs `multipass info` for name. While listings are cached, the
+// first lookup runs it for all instances at once and later lookups of other
+// instances are served from that answer; see instanceFromSnapshot.
 func (c *client) GetInstance(ctx context.Context, name string) (*models.Instance, error) {
+	if c.cacheTTL > 0 {
+		if inst, ok := c.instanceFromSnapshot(ctx, name); ok {
+			return inst, nil
+		}
+	}
+
 	var payload infoResponse
 	if err := c.runJSON(ctx, &payload, "info", name); err != nil {
 		if errorsIsNotFound(err) {
@@ -157,6 +315,60 @@ func (c *client) GetInstance(ctx context.Context, name string) (*models.Instance
 	return inst, nil
 }
 
+// GetInstances runs `multipass info` for all names in one process. Multipass
+// fails the whole call when one name does not exist, in which case the
+// names are read one by one instead. The instances found are returned
+// together with an *InstancesError for the rest.
+func (c *client) GetInstances(ctx context.Context, names []string) (map[string]*models.Instance, error) {
+	found := make(map[string]*models.Instance, len(names))
+	seen := make(map[string]bool, len(names))
+	unique := make([]string, 0, len(names))
+	for _, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			unique = append(unique, name)
+		}
+	}
+	if len(unique) == 0 {
+		return found, nil
+	}
+
+	var payload infoResponse
+	err := c.runJSON(ctx, &payload, append([]string{"info"}, unique...)...)
+	switch {
+	case err != nil && (errors.Is(err, ErrDaemonUnavailable) || ctx.Err() != nil):
+		// Reading the names one by one would fail the same way.
+		return nil, err
+	case err == nil:
+		var rest []string
+		for _, name := range unique {
+			inst, err := payload.toModel(name)
+			if err != nil {
+				rest = append(rest, name)
+				continue
+			}
+			found[name] = inst
+		}
+		unique = rest
+	default:
+		tflog.Debug(ctx, "multipass info failed for several instances, reading them one by one", map[string]any{"instances": unique, "error": err.Error()})
+	}
+
+	failed := map[string]error{}
+	for _, name := range unique {
+		inst, err := c.GetInstance(ctx, name)
+		if err != nil {
+			failed[name] = err
+			continue
+		}
+		found[name] = inst
+	}
+	if len(failed) > 0 {
+		return found, &InstancesError{Errors: failed}
+	}
+	return found, nil
+}
+
 func (c *client) LaunchInstance(ctx context.Context, opts models.LaunchOptions) error {
 	if opts.CloudInitInline != "" && opts.CloudInitFile != "" {
 		return fmt.Errorf("only one of CloudInitInline or CloudInitFile may be set")
@@ -252,7 +464,87 @@ func (c *client) Exec(ctx context.Context, instance string, command []string) er
 	return nil
 }
 
+// ExecCapture runs command inside instance and returns its standard output.
+func (c *client) ExecCapture(ctx context.Context, instance string, command []string) ([]byte, error) {
+	if instance == "" {
+		return nil, fmt.Errorf("instance name is required for exec")
+	}
+	if len(command) == 0 {
+		return nil, fmt.Errorf("exec command cannot be empty")
+	}
+
+	args := []string{"exec", instance, "--"}
+	args = append(args, command...)
+	return c.run(ctx, args...)
+}
+
+// ExecOutput runs a command inside instance and returns its standard output,
+// standard error and exit status. Unlike Exec and ExecCapture, a non-zero
+// exit is reported through ExecResult.ExitCode rather than as an error, and
+// stderr is never classified as ErrNotFound, since the command's own output
+// can contain "not found". Multipass reports its own failures, such as a
+// missing or stopped instance, the same way, so callers should check the
+// instance first.
+func (c *client) ExecOutput(ctx context.Context, instance string, opts ExecOptions) (ExecResult, error) {
+	if instance == "" {
+		return ExecResult{}, fmt.Errorf("instance name is required for exec")
+	}
+	if len(opts.Command) == 0 {
+		return ExecResult{}, fmt.Errorf("exec command cannot be empty")
+	}
+
+	args := []string{"exec", instance}
+	if opts.WorkingDirectory != "" {
+		args = append(args, "--working-directory", opts.WorkingDirectory)
+	}
+	args = append(args, "--")
+	args = append(args, opts.Command...)
+
+	binary, err := c.resolveBinary()
+	if err != nil {
+		return ExecResult{}, err
+	}
+	release, err := c.acquire(ctx, args)
+	if err != nil {
+		return ExecResult{}, err
+	}
+	defer release()
+
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, binary, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	start := time.Now()
+	err = cmd.Run()
+	logCommand(ctx, args, start, err)
+	if ctx.Err() == context.DeadlineExceeded {
+		return ExecResult{}, fmt.Errorf("%w: %s", ErrTimeout, strings.Join(args, " "))
+	}
+	result := ExecResult{Stdout: stdout.Bytes(), Stderr: stderr.Bytes()}
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	default:
+		return ExecResult{}, &CLIError{
+			Command: strings.Join(args, " "),
+			Stderr:  strings.TrimSpace(ansiRegex.ReplaceAllString(stderr.String(), "")),
+			Err:     err,
+		}
+	}
+	return result, nil
+}
+
 func (c *client) StartInstance(ctx context.Context, name string) error {
+	defer c.invalidateInstances()
 	return c.runSimple(ctx, "start", name)
 }
 
@@ -262,6 +554,7 @@ func (c *client) StopInstance(ctx context.Context, name string, force bool) erro
 		args = append(args, "--cancel")
 	}
 	args = append(args, name)
+	defer c.invalidateInstances()
 	if _, err := c.run(ctx, args...); err != nil {
 		return err
 	}
@@ -269,10 +562,12 @@ func (c *client) StopInstance(ctx context.Context, name string, force bool) erro
 }
 
 func (c *client) SuspendInstance(ctx context.Context, name string) error {
+	defer c.invalidateInstances()
 	return c.runSimple(ctx, "suspend", name)
 }
 
 func (c *client) RestartInstance(ctx context.Context, name string) error {
+	defer c.invalidateInstances()
 	return c.runSimple(ctx, "restart", name)
 }
 
@@ -290,34 +585,152 @@ func (c *client) DeleteInstance(ctx context.Context, name string, purge bool) er
 }
 
 func (c *client) RecoverInstance(ctx context.Context, name string) error {
+	defer c.invalidateInstances()
 	return c.runSimple(ctx, "recover", name)
 }
 
+// CloneInstance runs `multipass clone`, which copies a stopped instance under
+// a new name. The clone is left stopped. A missing source returns
+// ErrNotFound.
+func (c *client) CloneInstance(ctx context.Context, source, name string) error {
+	if source == "" || name == "" {
+		return fmt.Errorf("source and clone names are required")
+	}
+	if _, err := c.run(ctx, "clone", source, "--name", name); err != nil {
+		if errorsIsNotFound(err) {
+			return ErrNotFound
+		}
+		return err
+	}
+	c.invalidateInstances()
+	return nil
+}
+
 func (c *client) SetPrimary(ctx context.Context, name string) error {
 	if name == "" {
 		return fmt.Errorf("name is required to set primary")
 	}
-	arg := fmt.Sprintf("client.primary-name=%s", name)
-	return c.runSimple(ctx, "set", arg)
+	return c.SetSetting(ctx, "client.primary-name", name)
+}
+
+// Authenticate runs `multipass authenticate`, passing the passphrase on
+// stdin so it never shows up in the process list or in CLIError.Command. It
+// returns ErrAuthenticationFailed for a wrong passphrase and nil when the
+// daemon has no passphrase set, since there is nothing to authenticate
+// against.
+func (c *client) Authenticate(ctx context.Context, passphrase string) error {
+	if passphrase == "" {
+		return fmt.Errorf("passphrase is required to authenticate")
+	}
+	_, err := c.runWithStdin(ctx, []byte(passphrase+"\n"), "authenticate")
+	if err == nil {
+		return nil
+	}
+	var cliErr *CLIError
+	if errors.As(err, &cliErr) {
+		switch {
+		case isPassphraseNotSetError(cliErr.Stderr):
+			return nil
+		case isWrongPassphraseError(cliErr.Stderr):
+			return ErrAuthenticationFailed
+		}
+		// Never echo the passphrase back, should the CLI ever print it.
+		cliErr.Stdout = strings.ReplaceAll(cliErr.Stdout, passphrase, "(redacted)")
+		cliErr.Stderr = strings.ReplaceAll(cliErr.Stderr, passphrase, "(redacted)")
+	}
+	return err
+}
+
+// GetSetting returns the value of a single `multipass get` key. It returns
+// ErrNotFound when Multipass does not recognise the key.
+func (c *client) GetSetting(ctx context.Context, key string) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("setting key is required")
+	}
+	out, err := c.run(ctx, "get", key)
+	if err != nil {
+		var cliErr *CLIError
+		if errorsIsNotFound(err) || (errors.As(err, &cliErr) && isUnknownSettingError(cliErr.Stderr)) {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+	return strings.TrimSpace(ansiRegex.ReplaceAllString(string(out), "")), nil
+}
+
+// SetSetting runs `multipass set key=value`. It returns ErrNotFound when
+// Multipass does not recognise the key; rejected values are reported as a
+// CLIError.
+func (c *client) SetSetting(ctx context.Context, key, value string) error {
+	if key == "" {
+		return fmt.Errorf("setting key is required")
+	}
+	err := c.runSimple(ctx, "set", key+"="+value)
+	// local.<instance>.* settings change what info reports.
+	c.invalidateInstances()
+	var cliErr *CLIError
+	if errorsIsNotFound(err) || (errors.As(err, &cliErr) && isUnknownSettingError(cliErr.Stderr)) {
+		return ErrNotFound
+	}
+	return err
 }
 
-func (c *client) ListImages(ctx context.Context, refresh bool) ([]models.Image, error) {
+// ListSettingKeys returns the keys `multipass get --keys` reports for this
+// host, sorted.
+func (c *client) ListSettingKeys(ctx context.Context) ([]string, error) {
+	out, err := c.run(ctx, "get", "--keys")
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, line := range strings.Split(ansiRegex.ReplaceAllString(string(out), "\n"), "\n") {
+		if key := strings.TrimSpace(line); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (c *client) ListImages(ctx context.Context, opts ImageListOptions) ([]models.Image, error) {
+	if opts.OnlyImages && opts.OnlyBlueprints {
+		return nil, fmt.Errorf("only one of OnlyImages or OnlyBlueprints may be set")
+	}
+
+	key := opts
+	key.Refresh = false
+	key.ForceUpdate = false
+
 	c.mu.Lock()
-	if !refresh && c.imageCache.valid(time.Now()) {
+	if cached := c.imageCache[key]; !opts.Refresh && !opts.ForceUpdate && cached.valid(time.Now()) {
 		defer c.mu.Unlock()
-		return cloneImages(c.imageCache.value), nil
+		return cloneImages(cached.value), nil
 	}
 	c.mu.Unlock()
 
+	args := []string{"find"}
+	if opts.OnlyImages {
+		args = append(args, "--only-images")
+	}
+	if opts.OnlyBlueprints {
+		args = append(args, "--only-blueprints")
+	}
+	if opts.ForceUpdate {
+		args = append(args, "--force-update")
+	}
+
 	var payload findResponse
-	if err := c.runJSON(ctx, &payload, "find"); err != nil {
+	if err := c.runJSON(ctx, &payload, args...); err != nil {
 		return nil, err
 	}
 
 	images := payload.toModel()
 
 	c.mu.Lock()
-	c.imageCache = newCacheEntry(images, cacheTTL)
+	if c.imageCache == nil {
+		c.imageCache = map[ImageListOptions]*cacheEntry[[]models.Image]{}
+	}
+	c.imageCache[key] = newCacheEntry(images, c.cacheTTL)
 	c.mu.Unlock()
 
 	return cloneImages(images), nil
@@ -339,39 +752,66 @@ func (c *client) ListNetworks(ctx context.Context, refresh bool) ([]models.Netwo
 	networks := payload.toModel()
 
 	c.mu.Lock()
-	c.networkCache = newCacheEntry(networks, cacheTTL)
+	c.networkCache = newCacheEntry(networks, c.cacheTTL)
 	c.mu.Unlock()
 
 	return cloneNetworks(networks), nil
 }
 
 func (c *client) ListAliases(ctx context.Context, refresh bool) ([]models.Alias, error) {
+	listing, err := c.aliasListing(ctx, refresh)
+	if err != nil {
+		return nil, err
+	}
+	return cloneAliases(listing.aliases), nil
+}
+
+// ActiveAliasContext returns the alias context new aliases are created in,
+// as selected with `multipass prefer`.
+func (c *client) ActiveAliasContext(ctx context.Context) (string, error) {
+	listing, err := c.aliasListing(ctx, false)
+	if err != nil {
+		return "", err
+	}
+	return listing.active, nil
+}
+
+func (c *client) aliasListing(ctx context.Context, refresh bool) (aliasListing, error) {
 	c.mu.Lock()
 	if !refresh && c.aliasCache.valid(time.Now()) {
 		defer c.mu.Unlock()
-		return cloneAliases(c.aliasCache.value), nil
+		return c.aliasCache.value, nil
 	}
+	gen := c.aliasGen
 	c.mu.Unlock()
 
 	var payload aliasesResponse
 	if err := c.runJSON(ctx, &payload, "aliases"); err != nil {
-		return nil, err
+		return aliasListing{}, err
 	}
 
-	aliases := payload.toModel()
+	listing := aliasListing{active: payload.ActiveContext, aliases: payload.toModel()}
+	if listing.active == "" {
+		listing.active = DefaultAliasContext
+	}
 
 	c.mu.Lock()
-	c.aliasCache = newCacheEntry(aliases, cacheTTL)
+	if c.aliasGen == gen {
+		c.aliasCache = newCacheEntry(listing, c.cacheTTL)
+	}
 	c.mu.Unlock()
 
-	return cloneAliases(aliases), nil
+	return listing, nil
 }
 
 func (c *client) CreateAlias(ctx context.Context, alias models.Alias) error {
 	if alias.Name == "" || alias.Instance == "" || alias.Command == "" {
 		return fmt.Errorf("alias requires name, instance, and command")
 	}
-	args := []string{"alias", "--no-map-working-directory"}
+	args := []string{"alias"}
+	if !alias.MapWorkingDirectory {
+		args = append(args, "--no-map-working-directory")
+	}
 
 	command := aliasCommand(alias.Command, alias.WorkingDirectory)
 
@@ -385,20 +825,48 @@ func (c *client) CreateAlias(ctx context.Context, alias models.Alias) error {
 	return nil
 }
 
+// DeleteAlias removes an alias. name may be qualified as
+// `<context>.<alias>` to remove an alias outside the active context.
 func (c *client) DeleteAlias(ctx context.Context, name string) error {
 	if name == "" {
 		return fmt.Errorf("alias name is required")
 	}
 	if _, err := c.run(ctx, "unalias", name); err != nil {
+		var cliErr *CLIError
+		if errorsIsNotFound(err) || (errors.As(err, &cliErr) && isAliasNotFoundError(cliErr.Stderr)) {
+			c.invalidateAliases()
+			return ErrNotFound
+		}
 		return err
 	}
 	c.invalidateAliases()
 	return nil
 }
 
+// ListSnapshots returns the snapshots of a single instance ordered by name.
+// It returns ErrNotFound when the instance itself does not exist.
 func (c *client) ListSnapshots(ctx context.Context, instance string) ([]models.Snapshot, error) {
-	var payload snapshotListResponse
-	if err := c.runJSON(ctx, &payload, "list", "--snapshots"); err != nil {
+	snapshots, err := c.ListSnapshotDetails(ctx, instance)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Name < snapshots[j].Name
+	})
+	return snapshots, nil
+}
+
+// ListSnapshotDetails returns the snapshots of a single instance, including
+// creation time and children, ordered oldest first.
+func (c *client) ListSnapshotDetails(ctx context.Context, instance string) ([]models.Snapshot, error) {
+	if instance == "" {
+		return nil, fmt.Errorf("instance name is required for snapshots")
+	}
+	var payload snapshotInfoResponse
+	if err := c.runJSON(ctx, &payload, "info", instance, "--snapshots"); err != nil {
+		if errorsIsNotFound(err) {
+			return nil, ErrNotFound
+		}
 		return nil, err
 	}
 	return payload.toModel(instance), nil
@@ -418,6 +886,8 @@ func (c *client) CreateSnapshot(ctx context.Context, instance, name, comment str
 	args = append(args, instance)
 
 	out, err := c.run(ctx, args...)
+	// The snapshot count is part of the instance info.
+	c.invalidateInstances()
 	if err != nil {
 		return "", err
 	}
@@ -451,12 +921,56 @@ func (c *client) DeleteSnapshot(ctx context.Context, instance, name string, purg
 		args = append(args, "--purge")
 	}
 	args = append(args, target)
+	defer c.invalidateInstances()
 	if _, err := c.run(ctx, args...); err != nil {
 		return err
 	}
 	return nil
 }
 
+// RestoreSnapshot reverts instance to snapshot name. The instance must be
+// stopped. Without --destructive multipass asks whether to snapshot the
+// current state first and waits for an answer, so the answer from opts is
+// always written to stdin; the command never waits on a prompt.
+func (c *client) RestoreSnapshot(ctx context.Context, instance, name string, opts RestoreOptions) error {
+	if instance == "" || name == "" {
+		return fmt.Errorf("instance and snapshot name are required")
+	}
+	if opts.Destructive && opts.SnapshotCurrentState {
+		return fmt.Errorf("a destructive restore cannot snapshot the current state")
+	}
+	target := fmt.Sprintf("%s.%s", instance, name)
+	args := []string{"restore"}
+	answer := []byte("n\n")
+	if opts.SnapshotCurrentState {
+		answer = []byte("y\n")
+	}
+	if opts.Destructive {
+		args = append(args, "--destructive")
+	}
+	args = append(args, target)
+	// The restored state and, with SnapshotCurrentState, the snapshot count
+	// are part of the instance info.
+	defer c.invalidateInstances()
+	if _, err := c.runWithStdin(ctx, answer, args...); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ListMounts returns the mounts currently attached to an instance, sorted by
+// instance path, including any uid/gid mappings reported by Multipass.
+func (c *client) ListMounts(ctx context.Context, instance string) ([]models.Mount, error) {
+	if instance == "" {
+		return nil, fmt.Errorf("instance name is required to list mounts")
+	}
+	inst, err := c.GetInstance(ctx, instance)
+	if err != nil {
+		return nil, err
+	}
+	return inst.Mounts, nil
+}
+
 func (c *client) Mount(ctx context.Context, instance string, mount models.Mount) error {
 	if instance == "" {
 		return fmt.Errorf("instance name is required for mount")
@@ -473,7 +987,12 @@ func (c *client) Mount(ctx context.Context, instance string, mount models.Mount)
 		target = target + ":ro"
 	}
 
-	if _, err := c.run(ctx, "mount", mount.HostPath, target); err != nil {
+	args := []string{"mount"}
+	args = append(args, idMappingArgs("--uid-map", mount.UIDMappings)...)
+	args = append(args, idMappingArgs("--gid-map", mount.GIDMappings)...)
+	args = append(args, mount.HostPath, target)
+
+	if _, err := c.run(ctx, args...); err != nil {
 		return err
 	}
 
@@ -541,6 +1060,33 @@ func (c *client) TransferCapture(ctx context.Context, opts TransferOptions) ([]b
 	return c.run(ctx, args...)
 }
 
+// TransferTo streams a single remote source to w through `multipass
+// transfer <source> -`, so large files never have to fit in memory. Partial
+// output may already have been written to w when an error is returned.
+func (c *client) TransferTo(ctx context.Context, opts TransferOptions, w io.Writer) error {
+	if opts.Stdin != nil {
+		return fmt.Errorf("stdin input is not supported for streaming transfers")
+	}
+	if len(opts.Sources) != 1 {
+		return fmt.Errorf("exactly one source is required for streaming transfers")
+	}
+	opts.Destination = "-"
+
+	return c.runStreaming(ctx, nil, w, buildTransferArgs(opts)...)
+}
+
+// idMappingArgs renders uid/gid mappings as repeated CLI flags. Every mapping
+// is passed on, including ones onto the default instance user: Multipass only
+// implies the caller's own ID for those, so a mapping such as 0:default would
+// otherwise be lost. No mappings means no flags.
+func idMappingArgs(flag string, mappings []string) []string {
+	var args []string
+	for _, m := range mappings {
+		args = append(args, flag, m)
+	}
+	return args
+}
+
 func buildTransferArgs(opts TransferOptions) []string {
 	args := []string{"transfer"}
 	if opts.Recursive {
@@ -580,6 +1126,30 @@ func (c *client) run(ctx context.Context, args ...string) ([]byte, error) {
 }
 
 func (c *client) runWithStdin(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+	var in io.Reader
+	if stdin != nil {
+		in = bytes.NewReader(stdin)
+	}
+	var stdout bytes.Buffer
+	if err := c.runStreaming(ctx, in, &stdout, args...); err != nil {
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+// runStreaming runs the CLI with stdout connected to the given writer. When
+// stdout is a *bytes.Buffer its contents are included in CLIError.
+func (c *client) runStreaming(ctx context.Context, stdin io.Reader, stdout io.Writer, args ...string) error {
+	binary, err := c.resolveBinary()
+	if err != nil {
+		return err
+	}
+	release, err := c.acquire(ctx, args)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	// Respect per-operation deadlines set by the caller (e.g. per-resource
 	// timeouts). Only apply the client-level default when the context does
 	// not already carry a deadline.
@@ -589,36 +1159,45 @@ func (c *client) runWithStdin(ctx context.Context, stdin []byte, args ...string)
 		defer cancel()
 	}
 
-	cmd := exec.CommandContext(ctx, c.binaryPath, args...)
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
+	cmd := exec.CommandContext(ctx, binary, args...)
+	var stderr bytes.Buffer
+	cmd.Stdout = stdout
 	cmd.Stderr = &stderr
 	if stdin != nil {
-		cmd.Stdin = bytes.NewReader(stdin)
+		cmd.Stdin = stdin
 	}
 
-	err := cmd.Run()
+	start := time.Now()
+	err = cmd.Run()
+	logCommand(ctx, args, start, err)
 	if err == nil {
-		return stdout.Bytes(), nil
+		return nil
 	}
 
 	if ctx.Err() == context.DeadlineExceeded {
-		return nil, fmt.Errorf("%w: %s", ErrTimeout, strings.Join(args, " "))
+		return fmt.Errorf("%w: %s", ErrTimeout, strings.Join(args, " "))
 	}
 
-	stdoutStr := strings.TrimSpace(ansiRegex.ReplaceAllString(stdout.String(), ""))
+	var stdoutStr string
+	if buf, ok := stdout.(*bytes.Buffer); ok {
+		stdoutStr = strings.TrimSpace(ansiRegex.ReplaceAllString(buf.String(), ""))
+	}
 	stderrStr := strings.TrimSpace(ansiRegex.ReplaceAllString(stderr.String(), ""))
 
+	if isDaemonUnavailableError(stderrStr) {
+		return fmt.Errorf("%w: %s. %s", ErrDaemonUnavailable, strings.TrimSuffix(stderrStr, "."), DaemonHint(runtime.GOOS))
+	}
+
 	if strings.Contains(stderrStr, "does not exist") || strings.Contains(stderrStr, "not found") {
-		return nil, fmt.Errorf("%w: %s", ErrNotFound, stderrStr)
+		return fmt.Errorf("%w: %s", ErrNotFound, stderrStr)
 	}
 
 	// Detect Multipass CLI's own --timeout errors
 	if isTimeoutError(stderrStr) {
-		return nil, fmt.Errorf("%w: %s", ErrTimeout, stderrStr)
+		return fmt.Errorf("%w: %s", ErrTimeout, stderrStr)
 	}
 
-	return nil, &CLIError{
+	return &CLIError{
 		Command: strings.Join(args, " "),
 		Stdout:  stdoutStr,
 		Stderr:  stderrStr,
@@ -626,16 +1205,74 @@ func (c *client) runWithStdin(ctx context.Context, stdin []byte, args ...string)
 	}
 }
 
+// logCommand logs a finished multipass command. Only the subcommand is
+// logged, since arguments can carry secrets such as exec commands. Fields
+// set on ctx by the caller, like the resource and instance, are inherited.
+func logCommand(ctx context.Context, args []string, start time.Time, err error) {
+	tflog.Debug(ctx, "Ran multipass command", map[string]any{
+		"subcommand":  args[0],
+		"duration_ms": time.Since(start).Milliseconds(),
+		"failed":      err != nil,
+	})
+}
+
+// lookupBinary checks that a bare binary name resolves through PATH.
+// Explicit paths are left to exec.
+func lookupBinary(binary string) error {
+	if strings.Contains(binary, "/") || strings.Contains(binary, "\\") {
+		return nil
+	}
+	if _, err := exec.LookPath(binary); err != nil {
+		return fmt.Errorf("unable to find multipass binary %q in PATH: %w", binary, err)
+	}
+	return nil
+}
+
+// resolveBinary returns the binary to run, performing the lookup deferred
+// by Config.DeferBinaryCheck on first use.
+func (c *client) resolveBinary() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lookupPending {
+		if err := lookupBinary(c.binaryPath); err != nil {
+			return "", err
+		}
+		c.lookupPending = false
+	}
+	return c.binaryPath, nil
+}
+
+// acquire waits for a free command slot. Waiting happens before the
+// per-command timeout starts, so queued commands do not use up their own
+// timeout, but it still honours deadlines set by the caller.
+func (c *client) acquire(ctx context.Context, args []string) (func(), error) {
+	if c.sem == nil {
+		return func() {}, nil
+	}
+	select {
+	case c.sem <- struct{}{}:
+		return func() { <-c.sem }, nil
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("%w: waiting to run %s", ErrTimeout, strings.Join(args, " "))
+		}
+		return nil, ctx.Err()
+	}
+}
+
 func (c *client) invalidateInstances() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.instanceCache = nil
+	c.infoCache = nil
+	c.instanceGen++
 }
 
 func (c *client) invalidateAliases() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.aliasCache = nil
+	c.aliasGen++
 }
 
 func cloneInstances(in []models.Instance) []models.Instance {
@@ -679,6 +1316,22 @@ func aliasCommand(command, dir string) string {
 	return command
 }
 
+// unwrapAliasCommand reverses aliasCommand, returning the original command
+// and working directory. Commands that were not wrapped are returned as is
+// with an empty directory.
+func unwrapAliasCommand(wrapped string) (command, dir string) {
+	const prefix, sep = `bash -c 'cd "`, `" && exec `
+	if !strings.HasPrefix(wrapped, prefix) || !strings.HasSuffix(wrapped, "'") {
+		return wrapped, ""
+	}
+	dir, command, ok := strings.Cut(wrapped[len(prefix):len(wrapped)-1], sep)
+	if !ok {
+		return wrapped, ""
+	}
+	unescape := func(s string) string { return strings.ReplaceAll(s, `'\''`, "'") }
+	return unescape(command), unescape(dir)
+}
+
 func errorsIsNotFound(err error) bool {
 	if err == nil {
 		return false
@@ -1,10 +1,25 @@
 package multipasscli
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-log/tflogtest"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
 )
 
 func TestAliasCommand_noDir(t *testing.T) {
@@ -62,6 +77,24 @@ func TestAliasCommand_dirWithSingleQuotes(t *testing.T) {
 	}
 }
 
+func TestUnwrapAliasCommand_roundTrip(t *testing.T) {
+	t.Parallel()
+	cases := []struct{ command, dir string }{
+		{"ls -la", ""},
+		{"ls", "/workspace"},
+		{"grep foo bar.txt", "/home/ubuntu/project"},
+		{"bash", "/my project"},
+		{"grep 'foo' bar.txt", "/workspace"},
+		{"ls", "/tmp/user's files"},
+	}
+	for _, tc := range cases {
+		command, dir := unwrapAliasCommand(aliasCommand(tc.command, tc.dir))
+		if command != tc.command || dir != tc.dir {
+			t.Fatalf("round trip of (%q, %q) gave (%q, %q)", tc.command, tc.dir, command, dir)
+		}
+	}
+}
+
 func TestBuildTransferArgs_sources(t *testing.T) {
 	t.Parallel()
 	got := buildTransferArgs(TransferOptions{
@@ -135,3 +168,994 @@ func TestTransferCapture_rejectsStdin(t *testing.T) {
 		t.Fatalf("expected error to mention stdin, got: %v", err)
 	}
 }
+
+func TestIDMappingArgs(t *testing.T) {
+	t.Parallel()
+	got := idMappingArgs("--uid-map", []string{"0:default", "1000:1001"})
+	want := []string{"--uid-map", "0:default", "--uid-map", "1000:1001"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got  %v\nwant %v", got, want)
+	}
+	if got := idMappingArgs("--gid-map", nil); got != nil {
+		t.Fatalf("expected no flags without mappings, got %v", got)
+	}
+}
+
+func TestTransferTo_validatesOptions(t *testing.T) {
+	t.Parallel()
+	c := &client{binaryPath: "multipass"}
+	var buf bytes.Buffer
+
+	if err := c.TransferTo(context.Background(), TransferOptions{
+		Sources: []string{"vm:/tmp/file"},
+		Stdin:   []byte("nope"),
+	}, &buf); err == nil || !strings.Contains(err.Error(), "stdin") {
+		t.Fatalf("expected stdin error, got: %v", err)
+	}
+
+	if err := c.TransferTo(context.Background(), TransferOptions{
+		Sources: []string{"vm:/tmp/a", "vm:/tmp/b"},
+	}, &buf); err == nil || !strings.Contains(err.Error(), "exactly one source") {
+		t.Fatalf("expected single-source error, got: %v", err)
+	}
+}
+
+// writeFakeCLI writes script as an executable named multipass into a fresh
+// temporary directory and returns its path.
+func writeFakeCLI(t testing.TB, script string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake CLI is a shell script")
+	}
+	bin := filepath.Join(t.TempDir(), "multipass")
+	if err := os.WriteFile(bin, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return bin
+}
+
+// fakeAliasCLI stands in for multipass: `alias` records the alias name and
+// `aliases` lists the recorded names. When a "hold" file exists, the next
+// listing snapshots the names, signals through "listing" and waits for
+// "release" before printing, so a test can mutate aliases mid-fetch.
+const fakeAliasCLI = `#!/bin/sh
+dir=$(dirname "$0")
+case "$1" in
+alias)
+	for name; do :; done
+	echo "$name" >> "$dir/names"
+	;;
+aliases)
+	names=$(cat "$dir/names" 2>/dev/null)
+	if [ -e "$dir/hold" ]; then
+		rm "$dir/hold"
+		touch "$dir/listing"
+		while [ ! -e "$dir/release" ]; do sleep 0.01; done
+	fi
+	printf '{"active-context":"default","contexts":{"default":{'
+	sep=""
+	for name in $names; do
+		printf '%s"%s":{"command":"true","instance":"vm","working-directory":"default"}' "$sep" "$name"
+		sep=","
+	done
+	printf '}}}\n'
+	;;
+esac
+`
+
+func TestListAliases_ignoresListingStartedBeforeInvalidation(t *testing.T) {
+	t.Parallel()
+
+	bin := writeFakeCLI(t, fakeAliasCLI)
+	dir := filepath.Dir(bin)
+	if err := os.WriteFile(filepath.Join(dir, "hold"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := &client{binaryPath: bin, timeout: time.Minute, cacheTTL: time.Minute}
+	ctx := context.Background()
+
+	// A sibling resource's Read starts listing aliases...
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.ListAliases(ctx, true)
+		done <- err
+	}()
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "listing")); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("listing never started")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// ...while another resource creates its alias, and the listing only
+	// completes afterwards.
+	if err := c.CreateAlias(ctx, models.Alias{Name: "created", Instance: "vm", Command: "true"}); err != nil {
+		t.Fatalf("create alias: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "release"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("list aliases: %v", err)
+	}
+
+	aliases, err := c.ListAliases(ctx, false)
+	if err != nil {
+		t.Fatalf("list aliases: %v", err)
+	}
+	if len(aliases) != 1 || aliases[0].Name != "created" {
+		t.Fatalf("served a listing from before CreateAlias: %+v", aliases)
+	}
+}
+
+func TestDeleteAlias_notFoundVariants(t *testing.T) {
+	t.Parallel()
+
+	// stderr printed by `multipass unalias` for a missing alias across
+	// releases.
+	for _, stderr := range []string{
+		"Alias 'ghost' does not exist",
+		"No such alias: ghost",
+		"Nonexistent alias: ghost.",
+		"Nonexistent aliases: ghost, other.",
+		"Unknown alias 'ghost'",
+	} {
+		bin := writeFakeCLI(t, "#!/bin/sh\necho \""+stderr+"\" >&2\nexit 2\n")
+		c := &client{binaryPath: bin, timeout: time.Minute}
+		if err := c.DeleteAlias(context.Background(), "ghost"); err != ErrNotFound {
+			t.Errorf("%q: expected ErrNotFound, got %v", stderr, err)
+		}
+	}
+
+	bin := writeFakeCLI(t, "#!/bin/sh\necho \"Permission denied\" >&2\nexit 2\n")
+	c := &client{binaryPath: bin, timeout: time.Minute}
+	var cliErr *CLIError
+	if err := c.DeleteAlias(context.Background(), "ghost"); !errors.As(err, &cliErr) {
+		t.Fatalf("expected a CLIError, got %v", err)
+	}
+}
+
+func TestListSnapshots_scopedToInstance(t *testing.T) {
+	t.Parallel()
+
+	// Only `info app --snapshots` succeeds; any other instance is missing.
+	// The payload also carries a same-named snapshot of another instance,
+	// which must not be attributed to app.
+	bin := writeFakeCLI(t, `#!/bin/sh
+if [ "$1 $2 $3" != "info app --snapshots" ]; then
+	echo "info failed: instance \"$2\" does not exist" >&2
+	exit 2
+fi
+cat <<'JSON'
+{"errors":[],"info":{
+	"app":{"snapshots":{
+		"snapshot2":{"children":[],"comment":"","created":"2024-05-02T10:00:00Z","parent":"snapshot1"},
+		"snapshot1":{"children":["snapshot2"],"comment":"base","created":"2024-05-01T10:00:00Z","parent":""}
+	}},
+	"other":{"snapshots":{
+		"snapshot3":{"children":[],"comment":"","created":"2024-05-03T10:00:00Z","parent":""}
+	}}
+}}
+JSON
+`)
+	c := &client{binaryPath: bin, timeout: time.Minute}
+	ctx := context.Background()
+
+	snapshots, err := c.ListSnapshots(ctx, "app")
+	if err != nil {
+		t.Fatalf("list snapshots: %v", err)
+	}
+	var names []string
+	for _, s := range snapshots {
+		if s.Instance != "app" {
+			t.Fatalf("snapshot attributed to %q", s.Instance)
+		}
+		names = append(names, s.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"snapshot1", "snapshot2"}) {
+		t.Fatalf("unexpected snapshots: %v", names)
+	}
+
+	if _, err := c.ListSnapshots(ctx, "ghost"); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound for a missing instance, got %v", err)
+	}
+}
+
+func TestListImages_forceUpdateBypassesCache(t *testing.T) {
+	t.Parallel()
+
+	// Every call appends its arguments to a log next to the script.
+	bin := writeFakeCLI(t, `#!/bin/sh
+echo "$*" >> "$(dirname "$0")/calls.log"
+cat <<'JSON'
+{"errors":[],"images":{"24.04":{"aliases":["noble","lts"],"os":"Ubuntu","release":"24.04 LTS","remote":"","version":"20240423"}},"blueprints":{}}
+JSON
+`)
+	c := &client{binaryPath: bin, timeout: time.Minute, cacheTTL: time.Minute}
+	ctx := context.Background()
+
+	for _, opts := range []ImageListOptions{{}, {}, {ForceUpdate: true}, {}} {
+		images, err := c.ListImages(ctx, opts)
+		if err != nil {
+			t.Fatalf("list images: %v", err)
+		}
+		if len(images) != 1 || images[0].Name != "24.04" {
+			t.Fatalf("unexpected images: %#v", images)
+		}
+	}
+
+	log, err := os.ReadFile(filepath.Join(filepath.Dir(bin), "calls.log"))
+	if err != nil {
+		t.Fatalf("read call log: %v", err)
+	}
+	want := "find --format json\nfind --force-update --format json\n"
+	if string(log) != want {
+		t.Fatalf("unexpected calls:\n%s", log)
+	}
+}
+
+func TestGetSetting(t *testing.T) {
+	t.Parallel()
+
+	bin := writeFakeCLI(t, `#!/bin/sh
+case "$*" in
+"get --keys") printf 'local.driver\nclient.primary-name\n' ;;
+"get local.driver") echo qemu ;;
+*) echo "Unrecognized settings key: '$2'" >&2; exit 1 ;;
+esac
+`)
+	c := &client{binaryPath: bin, timeout: time.Minute}
+	ctx := context.Background()
+
+	value, err := c.GetSetting(ctx, "local.driver")
+	if err != nil || value != "qemu" {
+		t.Fatalf("get local.driver = %q, %v", value, err)
+	}
+	if _, err := c.GetSetting(ctx, "local.bogus"); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound for an unknown key, got %v", err)
+	}
+	keys, err := c.ListSettingKeys(ctx)
+	if err != nil || !reflect.DeepEqual(keys, []string{"client.primary-name", "local.driver"}) {
+		t.Fatalf("list keys = %v, %v", keys, err)
+	}
+}
+
+func TestSetSetting(t *testing.T) {
+	t.Parallel()
+
+	bin := writeFakeCLI(t, `#!/bin/sh
+echo "$*" >> "$(dirname "$0")/calls.log"
+case "$2" in
+local.driver=qemu|client.primary-name=dev) exit 0 ;;
+local.driver=*) echo "Invalid setting '$2': unsupported driver" >&2; exit 1 ;;
+*) echo "Unrecognized settings key: '${2%%=*}'" >&2; exit 1 ;;
+esac
+`)
+	c := &client{binaryPath: bin, timeout: time.Minute}
+	ctx := context.Background()
+
+	if err := c.SetSetting(ctx, "local.driver", "qemu"); err != nil {
+		t.Fatalf("set local.driver: %v", err)
+	}
+	if err := c.SetPrimary(ctx, "dev"); err != nil {
+		t.Fatalf("set primary: %v", err)
+	}
+	if err := c.SetSetting(ctx, "local.bogus", "1"); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound for an unknown key, got %v", err)
+	}
+	var cliErr *CLIError
+	if err := c.SetSetting(ctx, "local.driver", "vmware"); !errors.As(err, &cliErr) {
+		t.Fatalf("expected a CLIError for a rejected value, got %v", err)
+	}
+
+	log, err := os.ReadFile(filepath.Join(filepath.Dir(bin), "calls.log"))
+	if err != nil {
+		t.Fatalf("read call log: %v", err)
+	}
+	want := "set local.driver=qemu\nset client.primary-name=dev\nset local.bogus=1\nset local.driver=vmware\n"
+	if string(log) != want {
+		t.Fatalf("unexpected calls:\n%s", log)
+	}
+}
+
+func TestCloneInstance(t *testing.T) {
+	t.Parallel()
+
+	bin := writeFakeCLI(t, `#!/bin/sh
+echo "$*" >> "$(dirname "$0")/calls.log"
+case "$2" in
+golden) exit 0 ;;
+*) echo "instance \"$2\" does not exist" >&2; exit 2 ;;
+esac
+`)
+	c := &client{binaryPath: bin, timeout: time.Minute}
+	ctx := context.Background()
+
+	if err := c.CloneInstance(ctx, "golden", "worker-1"); err != nil {
+		t.Fatalf("clone: %v", err)
+	}
+	if err := c.CloneInstance(ctx, "ghost", "worker-2"); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound for a missing source, got %v", err)
+	}
+	if err := c.CloneInstance(ctx, "golden", ""); err == nil {
+		t.Fatal("expected an error for an empty clone name")
+	}
+
+	log, err := os.ReadFile(filepath.Join(filepath.Dir(bin), "calls.log"))
+	if err != nil {
+		t.Fatalf("read call log: %v", err)
+	}
+	want := "clone golden --name worker-1\nclone ghost --name worker-2\n"
+	if string(log) != want {
+		t.Fatalf("unexpected calls:\n%s", log)
+	}
+}
+
+func TestExecOutput_reportsExitCode(t *testing.T) {
+	t.Parallel()
+
+	// The fake prints its arguments and fails with a "not found" message,
+	// which must not be mistaken for a missing instance.
+	bin := writeFakeCLI(t, `#!/bin/sh
+echo "$*"
+echo "sh: 1: foo: not found" >&2
+exit 127
+`)
+	c := &client{binaryPath: bin, timeout: time.Minute}
+
+	result, err := c.ExecOutput(context.Background(), "vm", ExecOptions{
+		Command:          []string{"foo", "--bar"},
+		WorkingDirectory: "/srv",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := string(result.Stdout), "exec vm --working-directory /srv -- foo --bar\n"; got != want {
+		t.Fatalf("stdout = %q, want %q", got, want)
+	}
+	if got, want := string(result.Stderr), "sh: 1: foo: not found\n"; got != want {
+		t.Fatalf("stderr = %q, want %q", got, want)
+	}
+	if result.ExitCode != 127 {
+		t.Fatalf("exit code = %d, want 127", result.ExitCode)
+	}
+}
+
+func TestListInstances_cacheTTL(t *testing.T) {
+	t.Parallel()
+
+	// Every call appends a line to a log next to the script.
+	script := `#!/bin/sh
+echo "$*" >> "$(dirname "$0")/calls.log"
+echo '{"list":[{"name":"vm","state":"Running","ipv4":[],"release":"24.04 LTS"}]}'
+`
+	cases := []struct {
+		name     string
+		ttl      int
+		refresh  []bool
+		wantRuns int
+	}{
+		{name: "disabled", ttl: 0, refresh: []bool{false, false, false}, wantRuns: 3},
+		{name: "long", ttl: 3600, refresh: []bool{false, false, false}, wantRuns: 1},
+		{name: "long with refresh", ttl: 3600, refresh: []bool{false, true, false}, wantRuns: 2},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			bin := writeFakeCLI(t, script)
+			ttl := tc.ttl
+			c, err := NewClient(context.Background(), Config{BinaryPath: bin, CacheTTL: &ttl})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, refresh := range tc.refresh {
+				instances, err := c.ListInstances(context.Background(), refresh)
+				if err != nil {
+					t.Fatalf("list instances: %v", err)
+				}
+				if len(instances) != 1 || instances[0].Name != "vm" {
+					t.Fatalf("unexpected instances: %#v", instances)
+				}
+			}
+
+			log, err := os.ReadFile(filepath.Join(filepath.Dir(bin), "calls.log"))
+			if err != nil {
+				t.Fatalf("read call log: %v", err)
+			}
+			if runs := strings.Count(string(log), "\n"); runs != tc.wantRuns {
+				t.Fatalf("multipass ran %d times, want %d:\n%s", runs, tc.wantRuns, log)
+			}
+		})
+	}
+}
+
+func TestMaxParallel_limitsConcurrentCommands(t *testing.T) {
+	t.Parallel()
+
+	// Each invocation marks itself as running, records how many commands
+	// are running at that moment and holds its slot briefly.
+	script := `#!/bin/sh
+dir=$(dirname "$0")
+mkdir -p "$dir/running"
+touch "$dir/running/$$"
+ls "$dir/running" | wc -l >> "$dir/concurrency.log"
+sleep 0.1
+rm "$dir/running/$$"
+`
+	for _, limit := range []int{1, 3} {
+		t.Run(fmt.Sprintf("limit %d", limit), func(t *testing.T) {
+			t.Parallel()
+
+			bin := writeFakeCLI(t, script)
+			c, err := NewClient(context.Background(), Config{BinaryPath: bin, MaxParallel: limit})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Ten callers, like ten instance resources applied in parallel
+			// by Terraform, all complete; only the CLI access is limited.
+			var wg sync.WaitGroup
+			errs := make(chan error, 10)
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					errs <- c.StartInstance(context.Background(), fmt.Sprintf("vm-%d", i))
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				if err != nil {
+					t.Fatalf("start: %v", err)
+				}
+			}
+
+			log, err := os.ReadFile(filepath.Join(filepath.Dir(bin), "concurrency.log"))
+			if err != nil {
+				t.Fatalf("read concurrency log: %v", err)
+			}
+			counts := strings.Fields(string(log))
+			if len(counts) != 10 {
+				t.Fatalf("multipass ran %d times, want 10", len(counts))
+			}
+			for _, count := range counts {
+				if n, err := strconv.Atoi(count); err != nil || n > limit {
+					t.Fatalf("saw %s concurrent commands with limit %d", count, limit)
+				}
+			}
+		})
+	}
+}
+
+func TestMaxParallel_waitHonoursCallerDeadline(t *testing.T) {
+	t.Parallel()
+
+	c := &client{binaryPath: "multipass", timeout: time.Minute, sem: make(chan struct{}, 1)}
+	c.sem <- struct{}{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := c.StartInstance(ctx, "vm"); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout while waiting for a slot, got %v", err)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	t.Parallel()
+
+	// The fake reads the passphrase from stdin and logs its arguments.
+	bin := writeFakeCLI(t, `#!/bin/sh
+echo "$*" >> "$(dirname "$0")/calls.log"
+read -r p
+case "$p" in
+right) exit 0 ;;
+unset) echo "Passphrase is not set. Please set local.passphrase with a trusted client." >&2; exit 1 ;;
+wrong) echo "Passphrase is not correct. Please try again." >&2; exit 1 ;;
+*) echo "unexpected failure for $p" >&2; exit 1 ;;
+esac
+`)
+	c := &client{binaryPath: bin, timeout: time.Minute}
+	ctx := context.Background()
+
+	if err := c.Authenticate(ctx, "right"); err != nil {
+		t.Fatalf("correct passphrase: %v", err)
+	}
+	if err := c.Authenticate(ctx, "unset"); err != nil {
+		t.Fatalf("daemon without passphrase should be tolerated, got %v", err)
+	}
+	if err := c.Authenticate(ctx, "wrong"); err != ErrAuthenticationFailed {
+		t.Fatalf("expected ErrAuthenticationFailed, got %v", err)
+	}
+	err := c.Authenticate(ctx, "hunter2")
+	if err == nil || strings.Contains(err.Error(), "hunter2") {
+		t.Fatalf("expected an error without the passphrase, got %v", err)
+	}
+
+	log, err := os.ReadFile(filepath.Join(filepath.Dir(bin), "calls.log"))
+	if err != nil {
+		t.Fatalf("read call log: %v", err)
+	}
+	if want := strings.Repeat("authenticate\n", 4); string(log) != want {
+		t.Fatalf("passphrase leaked into arguments:\n%s", log)
+	}
+}
+
+func TestRestoreSnapshot(t *testing.T) {
+	t.Parallel()
+
+	// Like multipass, the fake asks before a non-destructive restore and
+	// keeps waiting until an answer arrives, so a restore that does not
+	// answer hangs until the deadline.
+	bin := writeFakeCLI(t, `#!/bin/sh
+dir=$(dirname "$0")
+echo "$*" >> "$dir/calls.log"
+if [ "$1" = restore ] && [ "$2" != --destructive ]; then
+	printf 'Do you want to take a snapshot of %s before discarding its current state? (Yes/no): ' "${2%%.*}"
+	while ! read -r answer; do sleep 1; done
+	echo "$answer" >> "$dir/answers.log"
+fi
+`)
+	c := &client{binaryPath: bin, timeout: time.Minute}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := c.RestoreSnapshot(ctx, "vm", "snap1", RestoreOptions{}); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if err := c.RestoreSnapshot(ctx, "vm", "snap1", RestoreOptions{SnapshotCurrentState: true}); err != nil {
+		t.Fatalf("restore keeping current state: %v", err)
+	}
+	if err := c.RestoreSnapshot(ctx, "vm", "snap1", RestoreOptions{Destructive: true}); err != nil {
+		t.Fatalf("destructive restore: %v", err)
+	}
+	if err := c.RestoreSnapshot(ctx, "vm", "snap1", RestoreOptions{Destructive: true, SnapshotCurrentState: true}); err == nil {
+		t.Fatal("expected conflicting options to be rejected")
+	}
+
+	dir := filepath.Dir(bin)
+	calls, err := os.ReadFile(filepath.Join(dir, "calls.log"))
+	if err != nil {
+		t.Fatalf("read call log: %v", err)
+	}
+	if want := "restore vm.snap1\nrestore vm.snap1\nrestore --destructive vm.snap1\n"; string(calls) != want {
+		t.Fatalf("unexpected calls:\n%s", calls)
+	}
+	answers, err := os.ReadFile(filepath.Join(dir, "answers.log"))
+	if err != nil {
+		t.Fatalf("read answers: %v", err)
+	}
+	if want := "n\ny\n"; string(answers) != want {
+		t.Fatalf("unexpected answers:\n%s", answers)
+	}
+}
+
+func TestDeferBinaryCheck_looksUpOnFirstCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake CLI is a shell script")
+	}
+	dir := t.TempDir()
+	t.Setenv("PATH", dir)
+
+	if _, err := NewClient(context.Background(), Config{}); err == nil {
+		t.Fatal("expected NewClient to fail without multipass in PATH")
+	}
+	c, err := NewClient(context.Background(), Config{DeferBinaryCheck: true})
+	if err != nil {
+		t.Fatalf("deferred client: %v", err)
+	}
+	err = c.StartInstance(context.Background(), "vm")
+	if err == nil || !strings.Contains(err.Error(), "unable to find multipass binary") {
+		t.Fatalf("expected the lookup error on first command, got %v", err)
+	}
+
+	// Installing multipass later makes the next command succeed.
+	if err := os.WriteFile(filepath.Join(dir, "multipass"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.StartInstance(context.Background(), "vm"); err != nil {
+		t.Fatalf("start after install: %v", err)
+	}
+}
+
+func TestVersionInfo_cachesCompleteAnswers(t *testing.T) {
+	t.Parallel()
+
+	// The daemon is only reported once a "daemon" file exists.
+	bin := writeFakeCLI(t, `#!/bin/sh
+dir=$(dirname "$0")
+echo "$*" >> "$dir/calls.log"
+if [ -f "$dir/daemon" ]; then
+  echo '{"multipass":"1.14.0","multipassd":"1.14.0"}'
+else
+  echo '{"multipass":"1.14.0"}'
+fi
+`)
+	c, err := NewClient(context.Background(), Config{BinaryPath: bin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	info, err := c.VersionInfo(ctx)
+	if err != nil || info.Daemon != "" {
+		t.Fatalf("expected no daemon version, got %#v, %v", info, err)
+	}
+	if err := os.WriteFile(filepath.Join(filepath.Dir(bin), "daemon"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		info, err = c.VersionInfo(ctx)
+		if err != nil || info.Daemon != "1.14.0" {
+			t.Fatalf("expected daemon version 1.14.0, got %#v, %v", info, err)
+		}
+	}
+
+	log, err := os.ReadFile(filepath.Join(filepath.Dir(bin), "calls.log"))
+	if err != nil {
+		t.Fatalf("read call log: %v", err)
+	}
+	if runs := strings.Count(string(log), "\n"); runs != 2 {
+		t.Fatalf("multipass ran %d times, want 2:\n%s", runs, log)
+	}
+}
+
+func TestRun_daemonUnavailable(t *testing.T) {
+	t.Parallel()
+
+	bin := writeFakeCLI(t, "#!/bin/sh\necho \"list failed: cannot connect to the multipass socket\" >&2\nexit 1\n")
+	c := &client{binaryPath: bin, timeout: time.Minute}
+	_, err := c.ListInstances(context.Background(), true)
+	if !errors.Is(err, ErrDaemonUnavailable) {
+		t.Fatalf("expected ErrDaemonUnavailable, got %v", err)
+	}
+	if !strings.Contains(err.Error(), DaemonHint(runtime.GOOS)) {
+		t.Fatalf("expected the error to explain how to start the daemon, got %v", err)
+	}
+}
+
+func TestRun_logsInheritCallerFields(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	ctx := tflogtest.RootLogger(context.Background(), &buf)
+	ctx = tflog.SetField(ctx, "instance", "vm")
+
+	bin := writeFakeCLI(t, "#!/bin/sh\n")
+	c := &client{binaryPath: bin, timeout: time.Minute}
+	if err := c.StartInstance(ctx, "vm"); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	entries, err := tflogtest.MultilineJSONDecode(&buf)
+	if err != nil {
+		t.Fatalf("decode logs: %v", err)
+	}
+	if len(entries) != 1 || entries[0]["instance"] != "vm" || entries[0]["subcommand"] != "start" {
+		t.Fatalf("expected one command entry carrying the caller's fields, got %v", entries)
+	}
+}
+
+// fakeInfoCLI answers `multipass info` for vm1 and vm2, whose states live in
+// <name>.state files next to the script (Stopped when missing), and `start`
+// by marking an instance Running. Every call is appended to calls.log. A
+// "bulk-unsupported" file makes bulk info fail like releases that need
+// names, and a "bulk-broken" file like an instance multipass cannot read.
+const fakeInfoCLI = `#!/bin/sh
+dir=$(dirname "$0")
+echo "$*" >> "$dir/calls.log"
+entry() {
+	state=$(cat "$dir/$1.state" 2>/dev/null || echo Stopped)
+	printf '"%s":{"state":"%s","cpu_count":"1","snapshot_count":"0"}' "$1" "$state"
+}
+case "$1" in
+info)
+	if [ "$2" = "--format" ]; then
+		[ -e "$dir/bulk-unsupported" ] && { echo "error: instance name required" >&2; exit 2; }
+		[ -e "$dir/bulk-broken" ] && { echo "info failed: cannot retrieve information for instance vm2" >&2; exit 2; }
+		printf '{"errors":[],"info":{%s,%s}}\n' "$(entry vm1)" "$(entry vm2)"
+	else
+		printf '{"errors":[],"info":{%s}}\n' "$(entry "$2")"
+	fi
+	;;
+start)
+	echo Running > "$dir/$2.state"
+	;;
+esac
+`
+
+func TestGetInstance_bulkInfo(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name       string
+		ttl        int
+		failure    string
+		steps      []string
+		wantStates []string
+		wantCalls  []string
+	}{
+		{
+			name:       "one call serves every instance",
+			ttl:        3600,
+			steps:      []string{"vm1", "vm2"},
+			wantStates: []string{"Stopped", "Stopped"},
+			wantCalls:  []string{"info --format json"},
+		},
+		{
+			name:       "second read of an instance asks again",
+			ttl:        3600,
+			steps:      []string{"vm1", "vm1"},
+			wantStates: []string{"Stopped", "Stopped"},
+			wantCalls:  []string{"info --format json", "info vm1 --format json"},
+		},
+		{
+			name:       "mutation invalidates the snapshot",
+			ttl:        3600,
+			steps:      []string{"vm1", "start vm2", "vm2"},
+			wantStates: []string{"Stopped", "Running"},
+			wantCalls:  []string{"info --format json", "start vm2", "info --format json"},
+		},
+		{
+			name:       "caching disabled",
+			ttl:        0,
+			steps:      []string{"vm1", "vm2"},
+			wantStates: []string{"Stopped", "Stopped"},
+			wantCalls:  []string{"info vm1 --format json", "info vm2 --format json"},
+		},
+		{
+			name:       "bulk info rejected once",
+			ttl:        3600,
+			failure:    "bulk-unsupported",
+			steps:      []string{"vm1", "vm2"},
+			wantStates: []string{"Stopped", "Stopped"},
+			wantCalls:  []string{"info --format json", "info vm1 --format json", "info vm2 --format json"},
+		},
+		{
+			name:       "other bulk failures are retried",
+			ttl:        3600,
+			failure:    "bulk-broken",
+			steps:      []string{"vm1", "vm2"},
+			wantStates: []string{"Stopped", "Stopped"},
+			wantCalls:  []string{"info --format json", "info vm1 --format json", "info --format json", "info vm2 --format json"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			bin := writeFakeCLI(t, fakeInfoCLI)
+			dir := filepath.Dir(bin)
+			if tc.failure != "" {
+				if err := os.WriteFile(filepath.Join(dir, tc.failure), nil, 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			ttl := tc.ttl
+			c, err := NewClient(context.Background(), Config{BinaryPath: bin, CacheTTL: &ttl})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var states []string
+			for _, step := range tc.steps {
+				if name, ok := strings.CutPrefix(step, "start "); ok {
+					if err := c.StartInstance(context.Background(), name); err != nil {
+						t.Fatalf("start: %v", err)
+					}
+					continue
+				}
+				inst, err := c.GetInstance(context.Background(), step)
+				if err != nil {
+					t.Fatalf("get instance %s: %v", step, err)
+				}
+				states = append(states, inst.State)
+			}
+			if !reflect.DeepEqual(states, tc.wantStates) {
+				t.Fatalf("states = %v, want %v", states, tc.wantStates)
+			}
+
+			log, err := os.ReadFile(filepath.Join(dir, "calls.log"))
+			if err != nil {
+				t.Fatalf("read call log: %v", err)
+			}
+			if calls := strings.Split(strings.TrimSpace(string(log)), "\n"); !reflect.DeepEqual(calls, tc.wantCalls) {
+				t.Fatalf("multipass calls = %q, want %q", calls, tc.wantCalls)
+			}
+		})
+	}
+}
+
+// fakeSlowInfoCLI answers `multipass info` for vm1..vm20 after a delay
+// standing in for the daemon: 150 ms per named call and 300 ms for the bulk
+// call, which queries every instance.
+const fakeSlowInfoCLI = `#!/bin/sh
+entry() {
+	printf '"%s":{"state":"Running","cpu_count":"1","snapshot_count":"0"}' "$1"
+}
+if [ "$2" = "--format" ]; then
+	sleep 0.3
+	entries=$(entry vm1)
+	for i in $(seq 2 20); do entries="$entries,$(entry "vm$i")"; done
+	printf '{"errors":[],"info":{%s}}\n' "$entries"
+else
+	sleep 0.15
+	printf '{"errors":[],"info":{%s}}\n' "$(entry "$2")"
+fi
+`
+
+// BenchmarkGetInstance_refresh reads 20 instances concurrently, as a refresh
+// of 20 multipass_instance resources does, with the default
+// max_parallel_commands. Run it with
+// `go test ./internal/multipasscli -run '^$' -bench GetInstance_refresh`.
+func BenchmarkGetInstance_refresh(b *testing.B) {
+	bin := writeFakeCLI(b, fakeSlowInfoCLI)
+	for _, bc := range []struct {
+		name string
+		ttl  int
+	}{
+		{name: "per instance", ttl: 0},
+		{name: "bulk", ttl: 3600},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			ttl := bc.ttl
+			c, err := NewClient(context.Background(), Config{BinaryPath: bin, CacheTTL: &ttl})
+			if err != nil {
+				b.Fatal(err)
+			}
+			for i := 0; i < b.N; i++ {
+				// Start every iteration without a bulk snapshot.
+				c.(*client).invalidateInstances()
+				var wg sync.WaitGroup
+				for n := 1; n <= 20; n++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						if _, err := c.GetInstance(context.Background(), fmt.Sprintf("vm%d", n)); err != nil {
+							b.Error(err)
+						}
+					}()
+				}
+				wg.Wait()
+			}
+		})
+	}
+}
+
+func TestGetInstance_concurrentReadsShareBulkInfo(t *testing.T) {
+	t.Parallel()
+
+	bin := writeFakeCLI(t, fakeInfoCLI)
+	c, err := NewClient(context.Background(), Config{BinaryPath: bin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for _, name := range []string{"vm1", "vm2"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.GetInstance(context.Background(), name)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("get instance: %v", err)
+		}
+	}
+
+	log, err := os.ReadFile(filepath.Join(filepath.Dir(bin), "calls.log"))
+	if err != nil {
+		t.Fatalf("read call log: %v", err)
+	}
+	if got := strings.TrimSpace(string(log)); got != "info --format json" {
+		t.Fatalf("expected one bulk info call, got:\n%s", got)
+	}
+}
+
+func TestGetInstances(t *testing.T) {
+	t.Parallel()
+
+	// Like multipass, the whole call fails when one name does not exist.
+	script := `#!/bin/sh
+echo "$*" >> "$(dirname "$0")/calls.log"
+shift
+printf '{"errors":[],"info":{'
+sep=""
+for name; do
+	case "$name" in
+	--format) break ;;
+	vm1|vm2) printf '%s"%s":{"state":"Running"}' "$sep" "$name"; sep="," ;;
+	*) echo "info failed: instance \"$name\" does not exist" >&2; exit 2 ;;
+	esac
+done
+printf '}}\n'
+`
+	cases := []struct {
+		name      string
+		names     []string
+		wantFound []string
+		wantErrs  []string
+		wantCalls []string
+	}{
+		{
+			name:      "one call",
+			names:     []string{"vm1", "vm2", "vm1"},
+			wantFound: []string{"vm1", "vm2"},
+			wantCalls: []string{"info vm1 vm2 --format json"},
+		},
+		{
+			name:      "missing instance degrades to per-name calls",
+			names:     []string{"vm1", "ghost", "vm2"},
+			wantFound: []string{"vm1", "vm2"},
+			wantErrs:  []string{"ghost"},
+			wantCalls: []string{"info vm1 ghost vm2 --format json", "info vm1 --format json", "info ghost --format json", "info vm2 --format json"},
+		},
+		{
+			name: "no names",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			bin := writeFakeCLI(t, script)
+			ttl := 0
+			c, err := NewClient(context.Background(), Config{BinaryPath: bin, CacheTTL: &ttl})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			found, err := c.GetInstances(context.Background(), tc.names)
+			var gotFound []string
+			for name, inst := range found {
+				if inst.Name != name || inst.State != "Running" {
+					t.Fatalf("unexpected instance for %s: %#v", name, inst)
+				}
+				gotFound = append(gotFound, name)
+			}
+			sort.Strings(gotFound)
+			if !reflect.DeepEqual(gotFound, tc.wantFound) {
+				t.Fatalf("found %v, want %v", gotFound, tc.wantFound)
+			}
+
+			var gotErrs []string
+			if err != nil {
+				var instErr *InstancesError
+				if !errors.As(err, &instErr) {
+					t.Fatalf("expected an InstancesError, got %v", err)
+				}
+				for name, err := range instErr.Errors {
+					if !errors.Is(err, ErrNotFound) {
+						t.Fatalf("expected ErrNotFound for %s, got %v", name, err)
+					}
+					gotErrs = append(gotErrs, name)
+				}
+				if !errors.Is(err, ErrNotFound) {
+					t.Fatalf("expected the InstancesError to match ErrNotFound, got %v", err)
+				}
+			}
+			if !reflect.DeepEqual(gotErrs, tc.wantErrs) {
+				t.Fatalf("errors for %v, want %v", gotErrs, tc.wantErrs)
+			}
+
+			var calls []string
+			if log, err := os.ReadFile(filepath.Join(filepath.Dir(bin), "calls.log")); err == nil {
+				calls = strings.Split(strings.TrimSpace(string(log)), "\n")
+			}
+			if !reflect.DeepEqual(calls, tc.wantCalls) {
+				t.Fatalf("multipass calls = %q, want %q", calls, tc.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,368 @@
+package multipasscli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
+)
+
+// dryRunClient wraps a Client so that commands which change the host or an
+// instance are logged and reported as successful without running. Reads go
+// to the wrapped client, except for instances the dry run launched or
+// cloned itself, which only exist in memory.
+type dryRunClient struct {
+	Client
+
+	mu        sync.Mutex
+	instances map[string]*models.Instance
+}
+
+// NewDryRunClient returns a Client that simulates every mutating command on
+// top of inner. Callers can collect the simulated commands of an operation
+// with TrackSimulated and SimulatedCommands.
+func NewDryRunClient(inner Client) Client {
+	return &dryRunClient{Client: inner, instances: map[string]*models.Instance{}}
+}
+
+type simulatedKey struct{}
+
+// simulatedLog collects the commands a dry-run client skipped.
+type simulatedLog struct {
+	mu       sync.Mutex
+	commands []string
+}
+
+// TrackSimulated returns a context in which a dry-run client records the
+// commands it skips, for SimulatedCommands to report.
+func TrackSimulated(ctx context.Context) context.Context {
+	return context.WithValue(ctx, simulatedKey{}, &simulatedLog{})
+}
+
+// SimulatedCommands lists the commands a dry-run client skipped under ctx,
+// which must come from TrackSimulated. It is empty for other clients.
+func SimulatedCommands(ctx context.Context) []string {
+	log, ok := ctx.Value(simulatedKey{}).(*simulatedLog)
+	if !ok {
+		return nil
+	}
+	log.mu.Lock()
+	defer log.mu.Unlock()
+	return append([]string(nil), log.commands...)
+}
+
+// simulate logs and records a skipped command.
+func (c *dryRunClient) simulate(ctx context.Context, args ...string) {
+	command := strings.Join(args, " ")
+	tflog.Info(ctx, "dry_run: skipping multipass command", map[string]any{"command": "multipass " + command})
+	if log, ok := ctx.Value(simulatedKey{}).(*simulatedLog); ok {
+		log.mu.Lock()
+		log.commands = append(log.commands, command)
+		log.mu.Unlock()
+	}
+}
+
+// simulated reports whether name was launched or cloned by the dry run, or
+// whether the current operation already skipped a command, in which case
+// follow-up probes such as upload verification would only see the host as
+// it was before.
+func (c *dryRunClient) simulated(ctx context.Context, name string) bool {
+	if len(SimulatedCommands(ctx)) > 0 {
+		return true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.instances[name]
+	return ok
+}
+
+// setState changes the state of a simulated instance, if there is one.
+func (c *dryRunClient) setState(name, state string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if inst, ok := c.instances[name]; ok {
+		inst.State = state
+	}
+}
+
+func (c *dryRunClient) ListInstances(ctx context.Context, refresh bool) ([]models.Instance, error) {
+	instances, err := c.Client.ListInstances(ctx, refresh)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.instances) == 0 {
+		return instances, nil
+	}
+	merged := make([]models.Instance, 0, len(instances)+len(c.instances))
+	for _, inst := range instances {
+		if _, ok := c.instances[inst.Name]; !ok {
+			merged = append(merged, inst)
+		}
+	}
+	for _, inst := range c.instances {
+		merged = append(merged, *inst)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Name < merged[j].Name })
+	return merged, nil
+}
+
+func (c *dryRunClient) GetInstance(ctx context.Context, name string) (*models.Instance, error) {
+	c.mu.Lock()
+	inst, ok := c.instances[name]
+	c.mu.Unlock()
+	if ok {
+		cp := *inst
+		return &cp, nil
+	}
+	return c.Client.GetInstance(ctx, name)
+}
+
+func (c *dryRunClient) GetInstances(ctx context.Context, names []string) (map[string]*models.Instance, error) {
+	found := make(map[string]*models.Instance, len(names))
+	var rest []string
+	c.mu.Lock()
+	for _, name := range names {
+		if inst, ok := c.instances[name]; ok {
+			cp := *inst
+			found[name] = &cp
+		} else {
+			rest = append(rest, name)
+		}
+	}
+	c.mu.Unlock()
+	if len(rest) == 0 {
+		return found, nil
+	}
+
+	real, err := c.Client.GetInstances(ctx, rest)
+	for name, inst := range real {
+		found[name] = inst
+	}
+	return found, err
+}
+
+func (c *dryRunClient) LaunchInstance(ctx context.Context, opts models.LaunchOptions) error {
+	args := []string{"launch", "--name", opts.Name}
+	if opts.Image != "" {
+		args = append(args, opts.Image)
+	}
+	c.simulate(ctx, args...)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.instances[opts.Name] = &models.Instance{
+		Name:        opts.Name,
+		State:       "Running",
+		CPUCount:    opts.CPUs,
+		LastUpdated: time.Now(),
+	}
+	return nil
+}
+
+func (c *dryRunClient) CloneInstance(ctx context.Context, source, name string) error {
+	c.simulate(ctx, "clone", source, "--name", name)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.instances[name] = &models.Instance{Name: name, State: "Stopped", LastUpdated: time.Now()}
+	return nil
+}
+
+func (c *dryRunClient) StartInstance(ctx context.Context, name string) error {
+	c.simulate(ctx, "start", name)
+	c.setState(name, "Running")
+	return nil
+}
+
+func (c *dryRunClient) StopInstance(ctx context.Context, name string, force bool) error {
+	if force {
+		c.simulate(ctx, "stop", "--force", name)
+	} else {
+		c.simulate(ctx, "stop", name)
+	}
+	c.setState(name, "Stopped")
+	return nil
+}
+
+func (c *dryRunClient) SuspendInstance(ctx context.Context, name string) error {
+	c.simulate(ctx, "suspend", name)
+	c.setState(name, "Suspended")
+	return nil
+}
+
+func (c *dryRunClient) RestartInstance(ctx context.Context, name string) error {
+	c.simulate(ctx, "restart", name)
+	c.setState(name, "Running")
+	return nil
+}
+
+func (c *dryRunClient) DeleteInstance(ctx context.Context, name string, purge bool) error {
+	if purge {
+		c.simulate(ctx, "delete", "--purge", name)
+		c.mu.Lock()
+		delete(c.instances, name)
+		c.mu.Unlock()
+		return nil
+	}
+	c.simulate(ctx, "delete", name)
+	c.setState(name, "Deleted")
+	return nil
+}
+
+func (c *dryRunClient) RecoverInstance(ctx context.Context, name string) error {
+	c.simulate(ctx, "recover", name)
+	c.setState(name, "Stopped")
+	return nil
+}
+
+func (c *dryRunClient) SetPrimary(ctx context.Context, name string) error {
+	c.simulate(ctx, "set", "client.primary-name="+name)
+	return nil
+}
+
+func (c *dryRunClient) SetSetting(ctx context.Context, key, _ string) error {
+	// The value may be a secret, so only the key is logged.
+	c.simulate(ctx, "set", key)
+	return nil
+}
+
+func (c *dryRunClient) CreateAlias(ctx context.Context, alias models.Alias) error {
+	c.simulate(ctx, "alias", alias.Instance+":"+alias.Command, alias.Name)
+	return nil
+}
+
+func (c *dryRunClient) DeleteAlias(ctx context.Context, name string) error {
+	c.simulate(ctx, "unalias", name)
+	return nil
+}
+
+func (c *dryRunClient) CreateSnapshot(ctx context.Context, instance, name, _ string) (string, error) {
+	if name == "" {
+		name = fmt.Sprintf("dry-run-%d", time.Now().UnixNano())
+	}
+	c.simulate(ctx, "snapshot", "--name", name, instance)
+	return name, nil
+}
+
+func (c *dryRunClient) DeleteSnapshot(ctx context.Context, instance, name string, purge bool) error {
+	if purge {
+		c.simulate(ctx, "delete", "--purge", instance+"."+name)
+	} else {
+		c.simulate(ctx, "delete", instance+"."+name)
+	}
+	return nil
+}
+
+func (c *dryRunClient) RestoreSnapshot(ctx context.Context, instance, name string, opts RestoreOptions) error {
+	if opts.Destructive {
+		c.simulate(ctx, "restore", "--destructive", instance+"."+name)
+	} else {
+		c.simulate(ctx, "restore", instance+"."+name)
+	}
+	return nil
+}
+
+func (c *dryRunClient) Mount(ctx context.Context, instance string, mount models.Mount) error {
+	c.simulate(ctx, "mount", mount.HostPath, instance+":"+mount.InstancePath)
+	return nil
+}
+
+func (c *dryRunClient) Unmount(ctx context.Context, instance string, mount models.Mount) error {
+	target := instance
+	if mount.InstancePath != "" {
+		target += ":" + mount.InstancePath
+	}
+	c.simulate(ctx, "umount", target)
+	return nil
+}
+
+func (c *dryRunClient) Transfer(ctx context.Context, opts TransferOptions) error {
+	sources := opts.Sources
+	if opts.Stdin != nil {
+		sources = []string{"-"}
+	}
+	c.simulate(ctx, append(append([]string{"transfer"}, sources...), opts.Destination)...)
+	return nil
+}
+
+func (c *dryRunClient) Exec(ctx context.Context, instance string, _ []string) error {
+	// Commands can carry secrets, so only the instance is logged.
+	c.simulate(ctx, "exec", instance)
+	return nil
+}
+
+// ExecCapture passes through: the provider only uses it to inspect files and
+// the instance, and for multipass_exec ephemeral resources, which read.
+func (c *dryRunClient) ExecCapture(ctx context.Context, instance string, command []string) ([]byte, error) {
+	if c.simulated(ctx, instance) {
+		return nil, nil
+	}
+	return c.Client.ExecCapture(ctx, instance, command)
+}
+
+// ExecOutput runs read-only probes and simulates every other command, which
+// then succeeds with no output.
+func (c *dryRunClient) ExecOutput(ctx context.Context, instance string, opts ExecOptions) (ExecResult, error) {
+	if !opts.ReadOnly {
+		// Commands can carry secrets, so only the instance is logged.
+		c.simulate(ctx, "exec", instance)
+		return ExecResult{}, nil
+	}
+	if c.simulated(ctx, instance) {
+		return ExecResult{}, nil
+	}
+	return c.Client.ExecOutput(ctx, instance, opts)
+}
+
+func (c *dryRunClient) ListSnapshots(ctx context.Context, instance string) ([]models.Snapshot, error) {
+	if c.simulated(ctx, instance) {
+		return nil, nil
+	}
+	return c.Client.ListSnapshots(ctx, instance)
+}
+
+func (c *dryRunClient) ListSnapshotDetails(ctx context.Context, instance string) ([]models.Snapshot, error) {
+	if c.simulated(ctx, instance) {
+		return nil, nil
+	}
+	return c.Client.ListSnapshotDetails(ctx, instance)
+}
+
+func (c *dryRunClient) ListMounts(ctx context.Context, instance string) ([]models.Mount, error) {
+	if c.simulated(ctx, instance) {
+		return nil, nil
+	}
+	return c.Client.ListMounts(ctx, instance)
+}
+
+func (c *dryRunClient) TransferCapture(ctx context.Context, opts TransferOptions) ([]byte, error) {
+	if c.transfersFromSimulated(ctx, opts) {
+		return nil, nil
+	}
+	return c.Client.TransferCapture(ctx, opts)
+}
+
+func (c *dryRunClient) TransferTo(ctx context.Context, opts TransferOptions, w io.Writer) error {
+	if c.transfersFromSimulated(ctx, opts) {
+		return nil
+	}
+	return c.Client.TransferTo(ctx, opts, w)
+}
+
+// transfersFromSimulated reports whether a download reads from a simulated
+// instance, which has no files to return.
+func (c *dryRunClient) transfersFromSimulated(ctx context.Context, opts TransferOptions) bool {
+	for _, source := range opts.Sources {
+		if instance, _, ok := strings.Cut(source, ":"); ok && c.simulated(ctx, instance) {
+			return true
+		}
+	}
+	return false
+}
@@ -3,6 +3,7 @@ package multipasscli
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -12,6 +13,13 @@ var (
 
 	// ErrTimeout indicates the command timed out.
 	ErrTimeout = errors.New("command timed out")
+
+	// ErrAuthenticationFailed indicates the daemon rejected the passphrase.
+	ErrAuthenticationFailed = errors.New("passphrase rejected by the multipass daemon")
+
+	// ErrDaemonUnavailable indicates the CLI could not reach multipassd,
+	// typically because it is stopped or still starting.
+	ErrDaemonUnavailable = errors.New("multipass daemon is not reachable")
 )
 
 // isTimeoutError checks whether a CLI error's stderr indicates a timeout.
@@ -20,6 +28,127 @@ func isTimeoutError(stderr string) bool {
 	return strings.Contains(lower, "timed out") || strings.Contains(lower, "timeout")
 }
 
+// daemonUnavailableMessages are the ways the CLI reports that it cannot
+// reach multipassd, lower-cased.
+var daemonUnavailableMessages = []string{
+	"cannot connect to the multipass socket",
+	"failed to connect to the multipass socket",
+	"please ensure multipassd is running",
+}
+
+// isDaemonUnavailableError checks whether a CLI stderr says the daemon could
+// not be reached.
+func isDaemonUnavailableError(stderr string) bool {
+	lower := strings.ToLower(stderr)
+	for _, msg := range daemonUnavailableMessages {
+		if strings.Contains(lower, msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// DaemonHint explains how to start multipassd on the given GOOS.
+func DaemonHint(goos string) string {
+	switch goos {
+	case "darwin":
+		return "Make sure multipassd is running: open the Multipass app, or run `sudo launchctl load -w /Library/LaunchDaemons/com.canonical.multipassd.plist`."
+	case "windows":
+		return "Make sure the Multipass service is running: start it from services.msc, or run `Start-Service Multipass` in an elevated PowerShell."
+	default:
+		return "Make sure multipassd is running: run `sudo snap start multipass` and check `snap services multipass`."
+	}
+}
+
+// aliasNotFoundMessages are the ways `multipass unalias` has reported a
+// missing alias across releases, lower-cased.
+var aliasNotFoundMessages = []string{
+	"does not exist",
+	"no such alias",
+	"nonexistent alias",
+	"unknown alias",
+}
+
+// isAliasNotFoundError checks whether a `multipass unalias` stderr says the
+// alias is already gone.
+func isAliasNotFoundError(stderr string) bool {
+	lower := strings.ToLower(stderr)
+	for _, msg := range aliasNotFoundMessages {
+		if strings.Contains(lower, msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// isUnknownSettingError checks whether a `multipass get` or `multipass set`
+// stderr says the settings key is not recognised.
+func isUnknownSettingError(stderr string) bool {
+	lower := strings.ToLower(stderr)
+	return strings.Contains(lower, "unrecognized settings key") || strings.Contains(lower, "unknown settings key")
+}
+
+// bulkInfoRejectedMessages are the ways releases without bulk info reject
+// `multipass info` without instance names, lower-cased.
+var bulkInfoRejectedMessages = []string{
+	"name argument or --all is required",
+	"instance name required",
+	"instance name is required",
+}
+
+// isBulkInfoRejectedError checks whether a `multipass info` stderr says the
+// call needs instance names, as opposed to failing for one of the
+// instances.
+func isBulkInfoRejectedError(stderr string) bool {
+	lower := strings.ToLower(stderr)
+	for _, msg := range bulkInfoRejectedMessages {
+		if strings.Contains(lower, msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// isWrongPassphraseError checks whether a `multipass authenticate` stderr
+// says the passphrase was rejected.
+func isWrongPassphraseError(stderr string) bool {
+	return strings.Contains(strings.ToLower(stderr), "passphrase is not correct")
+}
+
+// isPassphraseNotSetError checks whether a `multipass authenticate` stderr
+// says the daemon has no passphrase configured.
+func isPassphraseNotSetError(stderr string) bool {
+	return strings.Contains(strings.ToLower(stderr), "passphrase is not set")
+}
+
+// InstancesError reports the instances GetInstances could not read, keyed by
+// name. Instances that do not exist map to ErrNotFound.
+type InstancesError struct {
+	Errors map[string]error
+}
+
+func (e *InstancesError) Error() string {
+	names := make([]string, 0, len(e.Errors))
+	for name := range e.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s: %v", name, e.Errors[name]))
+	}
+	return "could not read instances: " + strings.Join(parts, "; ")
+}
+
+// Unwrap lets errors.Is match the per-instance errors, e.g. ErrNotFound.
+func (e *InstancesError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
 // CLIError represents a failure raised by the multipass CLI.
 type CLIError struct {
 	Command string
@@ -0,0 +1,115 @@
+package multipasscli
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
+)
+
+// errInfoSnapshotStale is returned to callers waiting on a bulk info run
+// when an instance changed while it was running.
+var errInfoSnapshotStale = errors.New("instances changed during bulk info")
+
+// infoSnapshot is one `multipass info` answer covering every instance. It
+// lets a refresh of many multipass_instance resources share one CLI call.
+// Each instance in it serves a single GetInstance, so reading the same
+// instance again, e.g. while polling or after a change, asks multipass
+// afresh.
+type infoSnapshot struct {
+	payload infoResponse
+	served  map[string]bool
+}
+
+// infoFetch is a bulk info run in progress. Concurrent GetInstance calls
+// wait for it rather than starting their own.
+type infoFetch struct {
+	done     chan struct{}
+	snapshot *infoSnapshot
+	err      error
+}
+
+// instanceFromSnapshot serves name from the current bulk info snapshot,
+// fetching one when there is none. ok is false when the snapshot cannot
+// answer, because the bulk call failed, name is missing from it or name
+// was already served from it; the caller then runs `multipass info name`.
+func (c *client) instanceFromSnapshot(ctx context.Context, name string) (*models.Instance, bool) {
+	snapshot, err := c.infoSnapshot(ctx)
+	if err != nil {
+		tflog.Debug(ctx, "Bulk multipass info unavailable, reading instance on its own", map[string]any{"instance": name, "error": err.Error()})
+		return nil, false
+	}
+
+	c.mu.Lock()
+	served := snapshot.served[name]
+	snapshot.served[name] = true
+	c.mu.Unlock()
+	if served {
+		return nil, false
+	}
+	if _, ok := snapshot.payload.entry(name); !ok {
+		return nil, false
+	}
+	inst, err := snapshot.payload.toModel(name)
+	if err != nil {
+		return nil, false
+	}
+	return inst, true
+}
+
+// infoSnapshot returns the cached bulk info snapshot, or runs
+// `multipass info` for every instance when it has expired. A snapshot is
+// only cached if no instance changed while it was being fetched, as for
+// instance listings.
+func (c *client) infoSnapshot(ctx context.Context) (*infoSnapshot, error) {
+	c.mu.Lock()
+	if c.bulkInfoUnsupported {
+		c.mu.Unlock()
+		return nil, errors.New("multipass info without instance names is not supported")
+	}
+	if c.infoCache.valid(time.Now()) {
+		defer c.mu.Unlock()
+		return c.infoCache.value, nil
+	}
+	if f := c.infoFetch; f != nil {
+		c.mu.Unlock()
+		select {
+		case <-f.done:
+			return f.snapshot, f.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	f := &infoFetch{done: make(chan struct{})}
+	c.infoFetch = f
+	gen := c.instanceGen
+	c.mu.Unlock()
+
+	var payload infoResponse
+	err := c.runJSON(ctx, &payload, "info")
+
+	c.mu.Lock()
+	c.infoFetch = nil
+	var cliErr *CLIError
+	switch {
+	case errors.As(err, &cliErr) && isBulkInfoRejectedError(cliErr.Stderr):
+		// Releases that require instance names reject the call outright;
+		// do not ask again for the lifetime of the client. Other failures,
+		// such as an instance in a broken state, only fail this fetch.
+		c.bulkInfoUnsupported = true
+		f.err = err
+	case err != nil:
+		f.err = err
+	case c.instanceGen != gen:
+		f.err = errInfoSnapshotStale
+	default:
+		f.snapshot = &infoSnapshot{payload: payload, served: map[string]bool{}}
+		c.infoCache = newCacheEntry(f.snapshot, c.cacheTTL)
+	}
+	c.mu.Unlock()
+	close(f.done)
+	return f.snapshot, f.err
+}
@@ -1,7 +1,9 @@
 package multipasscli
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/netip"
 	"sort"
 	"strconv"
 	"strings"
@@ -11,7 +13,15 @@ import (
 )
 
 type versionResponse struct {
-	Multipass string `json:"multipass"`
+	Multipass  string `json:"multipass"`
+	Multipassd string `json:"multipassd"`
+}
+
+func (r versionResponse) toModel() models.VersionInfo {
+	return models.VersionInfo{
+		Client: strings.TrimSpace(r.Multipass),
+		Daemon: strings.TrimSpace(r.Multipassd),
+	}
 }
 
 type listResponse struct {
@@ -29,33 +39,114 @@ func (r listResponse) toModel() []models.Instance {
 	now := time.Now()
 	out := make([]models.Instance, 0, len(r.List))
 	for _, entry := range r.List {
+		ipv4, ipv6 := splitIPs(orderIPs(sanitizeIPs(entry.IPv4), nil))
 		out = append(out, models.Instance{
 			Name:        entry.Name,
 			State:       entry.State,
 			Release:     entry.Release,
-			IPv4:        sanitizeIPs(entry.IPv4),
+			IPv4:        ipv4,
+			IPv6:        ipv6,
 			LastUpdated: now,
 		})
 	}
 	return out
 }
 
+// infoResponse decodes `multipass info --format json`. Multipass 1.13
+// nests instances under "info"; 1.14 moved them under "instances" and added
+// an "errors" array describing instances that could not be found.
 type infoResponse struct {
-	Info map[string]infoEntry `json:"info"`
+	Errors    []infoError          `json:"errors"`
+	Info      map[string]infoEntry `json:"info"`
+	Instances map[string]infoEntry `json:"instances"`
+}
+
+// infoError is one entry of the info "errors" array. Releases report either
+// plain strings or objects; both are flattened into a single message.
+type infoError string
+
+func (e *infoError) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		*e = infoError(str)
+		return nil
+	}
+	var obj map[string]any
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprint(obj[k]))
+	}
+	*e = infoError(strings.Join(parts, " "))
+	return nil
+}
+
+func (r infoResponse) entry(name string) (infoEntry, bool) {
+	if entry, ok := r.Instances[name]; ok {
+		return entry, true
+	}
+	entry, ok := r.Info[name]
+	return entry, ok
+}
+
+// mentions reports whether any entry of the errors array refers to name.
+// The name has to appear as a whole token, so an error about web10 does
+// not mention web1.
+func (r infoResponse) mentions(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, e := range r.Errors {
+		msg := string(e)
+		for i := strings.Index(msg, name); i != -1; {
+			end := i + len(name)
+			if (i == 0 || !isNameByte(msg[i-1])) && (end == len(msg) || !isNameByte(msg[end])) {
+				return true
+			}
+			next := strings.Index(msg[i+1:], name)
+			if next == -1 {
+				break
+			}
+			i += 1 + next
+		}
+	}
+	return false
+}
+
+// isNameByte reports whether b can be part of an instance name.
+func isNameByte(b byte) bool {
+	return b == '-' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
 }
 
 type infoEntry struct {
-	CPUCount      string                `json:"cpu_count"`
-	Disks         map[string]diskEntry  `json:"disks"`
-	ImageHash     string                `json:"image_hash"`
-	ImageRelease  string                `json:"image_release"`
-	IPv4          []string              `json:"ipv4"`
-	Load          []float64             `json:"load"`
-	Memory        memoryEntry           `json:"memory"`
-	Mounts        map[string]mountEntry `json:"mounts"`
-	Release       string                `json:"release"`
-	SnapshotCount string                `json:"snapshot_count"`
-	State         string                `json:"state"`
+	CPUCount        string                `json:"cpu_count"`
+	Disks           map[string]diskEntry  `json:"disks"`
+	ExtraInterfaces []interfaceEntry      `json:"extra_interfaces"`
+	ImageHash       string                `json:"image_hash"`
+	ImageRelease    string                `json:"image_release"`
+	IPv4            []string              `json:"ipv4"`
+	Load            []float64             `json:"load"`
+	MACAddress      string                `json:"mac_address"`
+	Memory          memoryEntry           `json:"memory"`
+	Mounts          map[string]mountEntry `json:"mounts"`
+	Release         string                `json:"release"`
+	SnapshotCount   string                `json:"snapshot_count"`
+	State           string                `json:"state"`
+}
+
+// interfaceEntry describes an additional NIC reported by newer Multipass
+// releases. Older releases omit the extra_interfaces array entirely.
+type interfaceEntry struct {
+	ID         string   `json:"id"`
+	MACAddress string   `json:"mac_address"`
+	IPv4       []string `json:"ipv4"`
 }
 
 type diskEntry struct {
@@ -69,13 +160,18 @@ type memoryEntry struct {
 }
 
 type mountEntry struct {
-	SourcePath string `json:"source_path"`
-	ReadOnly   bool   `json:"readonly"`
+	SourcePath  string   `json:"source_path"`
+	ReadOnly    bool     `json:"readonly"`
+	UIDMappings []string `json:"uid_mappings"`
+	GIDMappings []string `json:"gid_mappings"`
 }
 
 func (r infoResponse) toModel(name string) (*models.Instance, error) {
-	entry, ok := r.Info[name]
+	entry, ok := r.entry(name)
 	if !ok {
+		if r.mentions(name) {
+			return nil, ErrNotFound
+		}
 		return nil, fmt.Errorf("instance %q missing from info payload", name)
 	}
 
@@ -103,19 +199,23 @@ func (r infoResponse) toModel(name string) (*models.Instance, error) {
 			HostPath:     m.SourcePath,
 			InstancePath: instancePath,
 			ReadOnly:     readOnly,
+			UIDMappings:  NormalizeIDMappings(m.UIDMappings),
+			GIDMappings:  NormalizeIDMappings(m.GIDMappings),
 		})
 	}
 	sort.Slice(mounts, func(i, j int) bool {
 		return mounts[i].InstancePath < mounts[j].InstancePath
 	})
 
+	ipv4, ipv6 := splitIPs(orderIPs(sanitizeIPs(entry.IPv4), extraInterfaceIPs(entry)))
 	return &models.Instance{
 		Name:          name,
 		State:         entry.State,
 		Release:       entry.Release,
 		ImageRelease:  entry.ImageRelease,
 		ImageHash:     entry.ImageHash,
-		IPv4:          sanitizeIPs(entry.IPv4),
+		IPv4:          ipv4,
+		IPv6:          ipv6,
 		CPUCount:      cpuc,
 		DiskTotal:     diskTotal,
 		DiskUsed:      diskUsed,
@@ -124,13 +224,68 @@ func (r infoResponse) toModel(name string) (*models.Instance, error) {
 		Load:          entry.Load,
 		SnapshotCount: snapshots,
 		Mounts:        mounts,
+		Interfaces:    parseInterfaces(entry),
 		LastUpdated:   time.Now(),
+		AllInterfaces: entry.ExtraInterfaces != nil,
 	}, nil
 }
 
+// DefaultInterfaceName labels the NIC Multipass always creates for an
+// instance, which the info payload describes only through its top-level
+// mac_address and ipv4 fields.
+const DefaultInterfaceName = "default"
+
+// parseInterfaces builds the per-interface view of an info entry. Addresses
+// claimed by an extra interface are attributed to it; the remainder belong
+// to the default interface. Payloads without any interface details (older
+// Multipass releases) yield nil.
+func parseInterfaces(entry infoEntry) []models.NetworkInterface {
+	if entry.MACAddress == "" && len(entry.ExtraInterfaces) == 0 {
+		return nil
+	}
+
+	claimed := extraInterfaceIPs(entry)
+	extras := make([]models.NetworkInterface, 0, len(entry.ExtraInterfaces))
+	for _, iface := range entry.ExtraInterfaces {
+		extras = append(extras, models.NetworkInterface{
+			Name:      iface.ID,
+			MAC:       strings.ToLower(iface.MACAddress),
+			Addresses: orderIPs(sanitizeIPs(iface.IPv4), nil),
+		})
+	}
+
+	defaultAddrs := []string{}
+	for _, a := range orderIPs(sanitizeIPs(entry.IPv4), nil) {
+		if !claimed[a] {
+			defaultAddrs = append(defaultAddrs, a)
+		}
+	}
+
+	out := make([]models.NetworkInterface, 0, len(extras)+1)
+	out = append(out, models.NetworkInterface{
+		Name:      DefaultInterfaceName,
+		MAC:       strings.ToLower(entry.MACAddress),
+		Addresses: defaultAddrs,
+	})
+	return append(out, extras...)
+}
+
+// extraInterfaceIPs returns the addresses claimed by the extra interfaces
+// of an info entry.
+func extraInterfaceIPs(entry infoEntry) map[string]bool {
+	claimed := map[string]bool{}
+	for _, iface := range entry.ExtraInterfaces {
+		for _, a := range sanitizeIPs(iface.IPv4) {
+			claimed[a] = true
+		}
+	}
+	return claimed
+}
+
 type findResponse struct {
-	Images     map[string]findEntry `json:"images"`
-	Blueprints map[string]findEntry `json:"blueprints (deprecated)"`
+	Images               map[string]findEntry `json:"images"`
+	Blueprints           map[string]findEntry `json:"blueprints"`
+	DeprecatedBlueprints map[string]findEntry `json:"blueprints (deprecated)"`
 }
 
 type findEntry struct {
@@ -142,37 +297,50 @@ type findEntry struct {
 }
 
 func (r findResponse) toModel() []models.Image {
-	out := make([]models.Image, 0, len(r.Images)+len(r.Blueprints))
-	for name, entry := range r.Images {
-		out = append(out, models.Image{
-			Name:        name,
-			Aliases:     entry.Aliases,
-			OS:          entry.OS,
-			Release:     entry.Release,
-			Remote:      entry.Remote,
-			Version:     entry.Version,
-			Description: entry.Release,
-			Kind:        models.ImageKindImage,
-		})
+	out := make([]models.Image, 0, len(r.Images)+len(r.Blueprints)+len(r.DeprecatedBlueprints))
+	for key, entry := range r.Images {
+		out = append(out, entry.toModel(key, models.ImageKindImage))
 	}
-	for name, entry := range r.Blueprints {
-		out = append(out, models.Image{
-			Name:        name,
-			Aliases:     entry.Aliases,
-			OS:          entry.OS,
-			Release:     entry.Release,
-			Remote:      entry.Remote,
-			Version:     entry.Version,
-			Description: entry.Release,
-			Kind:        models.ImageKindBlueprint,
-		})
+	for key, entry := range r.Blueprints {
+		out = append(out, entry.toModel(key, models.ImageKindBlueprint))
+	}
+	for key, entry := range r.DeprecatedBlueprints {
+		out = append(out, entry.toModel(key, models.ImageKindBlueprint))
 	}
 	sort.Slice(out, func(i, j int) bool {
+		if out[i].Name == out[j].Name {
+			return out[i].Remote < out[j].Remote
+		}
 		return out[i].Name < out[j].Name
 	})
 	return out
 }
 
+// toModel converts a find entry keyed by key. Images from non-default
+// remotes are keyed as "<remote>:<name>" (e.g. "daily:24.10" or
+// "appliance:nextcloud"); when the entry does not carry its own remote, the
+// prefix is split off the key instead.
+func (e findEntry) toModel(key string, kind models.ImageKind) models.Image {
+	name := key
+	remote := e.Remote
+	if prefix, rest, ok := strings.Cut(key, ":"); ok && prefix != "" && rest != "" {
+		name = rest
+		if remote == "" {
+			remote = prefix
+		}
+	}
+	return models.Image{
+		Name:        name,
+		Aliases:     e.Aliases,
+		OS:          e.OS,
+		Release:     e.Release,
+		Remote:      remote,
+		Version:     e.Version,
+		Description: e.Release,
+		Kind:        kind,
+	}
+}
+
 type networksResponse struct {
 	List []networkEntry `json:"list"`
 }
@@ -196,9 +364,21 @@ func (r networksResponse) toModel() []models.Network {
 }
 
 type aliasesResponse struct {
-	Contexts map[string]map[string]aliasEntry `json:"contexts"`
+	ActiveContext string                           `json:"active-context"`
+	Contexts      map[string]map[string]aliasEntry `json:"contexts"`
 }
 
+// DefaultAliasContext is the context Multipass uses until another one is
+// selected with `multipass prefer`.
+const DefaultAliasContext = "default"
+
+// aliasMapWorkingDirectory is the working-directory mode of aliases created
+// without --no-map-working-directory.
+const aliasMapWorkingDirectory = "map"
+
+// aliasEntry is one alias as reported by `multipass aliases`. The CLI's
+// working-directory is its mapping mode ("map" or "default"), not a path;
+// explicit directories are expressed by wrapping the command instead.
 type aliasEntry struct {
 	Instance         string `json:"instance"`
 	Command          string `json:"command"`
@@ -207,17 +387,24 @@ type aliasEntry struct {
 
 func (r aliasesResponse) toModel() []models.Alias {
 	out := []models.Alias{}
-	for _, ctx := range r.Contexts {
-		for name, entry := range ctx {
+	for context, aliases := range r.Contexts {
+		for name, entry := range aliases {
+			command, dir := unwrapAliasCommand(entry.Command)
 			out = append(out, models.Alias{
-				Name:     name,
-				Instance: entry.Instance,
-				Command:  entry.Command,
+				Name:                name,
+				Context:             context,
+				Instance:            entry.Instance,
+				Command:             command,
+				WorkingDirectory:    dir,
+				MapWorkingDirectory: entry.WorkingDirectory == aliasMapWorkingDirectory,
 			})
 		}
 	}
 	sort.Slice(out, func(i, j int) bool {
-		return out[i].Name < out[j].Name
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+		return out[i].Context < out[j].Context
 	})
 	return out
 }
@@ -229,18 +416,63 @@ func parseUintString(value string) (uint64, error) {
 	return strconv.ParseUint(value, 10, 64)
 }
 
+// sanitizeIPs trims the addresses reported by Multipass, drops placeholders
+// and duplicates, and preserves the reported order.
 func sanitizeIPs(values []string) []string {
 	out := make([]string, 0, len(values))
+	seen := make(map[string]bool, len(values))
 	for _, v := range values {
 		v = strings.TrimSpace(v)
-		if v == "" || strings.EqualFold(v, "N/A") {
+		if v == "" || strings.EqualFold(v, "N/A") || seen[v] {
 			continue
 		}
+		seen[v] = true
 		out = append(out, v)
 	}
 	return out
 }
 
+// orderIPs sorts sanitized addresses so that refreshes do not reorder them:
+// addresses of the default interface come first, then those in secondary,
+// each group by address. Multipass reports them in whatever order the
+// guest's interfaces answered, which varies between calls.
+func orderIPs(values []string, secondary map[string]bool) []string {
+	sort.SliceStable(values, func(i, j int) bool {
+		a, b := values[i], values[j]
+		if secondary[a] != secondary[b] {
+			return !secondary[a]
+		}
+		addrA, errA := netip.ParseAddr(a)
+		addrB, errB := netip.ParseAddr(b)
+		if errA != nil || errB != nil {
+			return a < b
+		}
+		return addrA.Unmap().Compare(addrB.Unmap()) < 0
+	})
+	return values
+}
+
+// splitIPs classifies addresses by family. Multipass reports every address
+// under "ipv4", including IPv6 ones on dual-stack networks. IPv4-mapped IPv6
+// addresses are unmapped into the IPv4 list; values that do not parse as an
+// address are dropped.
+func splitIPs(values []string) (ipv4, ipv6 []string) {
+	ipv4 = make([]string, 0, len(values))
+	ipv6 = make([]string, 0, len(values))
+	for _, v := range values {
+		addr, err := netip.ParseAddr(v)
+		if err != nil {
+			continue
+		}
+		if addr.Unmap().Is4() {
+			ipv4 = append(ipv4, addr.Unmap().String())
+			continue
+		}
+		ipv6 = append(ipv6, v)
+	}
+	return ipv4, ipv6
+}
+
 func parseMountTarget(target string, explicitReadOnly bool) (string, bool) {
 	readOnly := explicitReadOnly
 	path := target
@@ -251,36 +483,124 @@ func parseMountTarget(target string, explicitReadOnly bool) (string, bool) {
 	return path, readOnly
 }
 
-type snapshotListResponse struct {
-	Errors []any                               `json:"errors"`
-	Info   map[string]map[string]snapshotEntry `json:"info"`
+// defaultIDMapping is the instance-side value Multipass uses for mappings
+// onto the default instance user or group.
+const defaultIDMapping = "default"
+
+// NormalizeIDMappings canonicalizes uid/gid mappings as "host:instance"
+// pairs, preserving order. Multipass reports the default mapping either as
+// "default" or as the sentinel -1 depending on the release; both become
+// "default". Payloads without mappings yield nil.
+func NormalizeIDMappings(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		host, instance, ok := strings.Cut(strings.TrimSpace(v), ":")
+		if !ok || host == "" {
+			continue
+		}
+		host = strings.TrimSpace(host)
+		instance = strings.TrimSpace(instance)
+		if instance == "" || instance == "-1" || strings.EqualFold(instance, defaultIDMapping) {
+			instance = defaultIDMapping
+		}
+		out = append(out, host+":"+instance)
+	}
+	return out
+}
+
+// snapshotInfoResponse decodes `multipass info <instance> --snapshots
+// --format json`, which (unlike `list --snapshots`) is scoped to one
+// instance and reports creation times and the children of each snapshot.
+type snapshotInfoResponse struct {
+	Errors    []any                           `json:"errors"`
+	Info      map[string]snapshotInfoInstance `json:"info"`
+	Instances map[string]snapshotInfoInstance `json:"instances"`
 }
 
-type snapshotEntry struct {
-	Comment string `json:"comment"`
-	Parent  string `json:"parent"`
+type snapshotInfoInstance struct {
+	Snapshots map[string]snapshotInfoEntry `json:"snapshots"`
 }
 
-func (r snapshotListResponse) toModel(instanceFilter string) []models.Snapshot {
+type snapshotInfoEntry struct {
+	Children []string  `json:"children"`
+	Comment  string    `json:"comment"`
+	Created  string    `json:"created"`
+	Parent   string    `json:"parent"`
+	Size     looseUint `json:"size"`
+}
+
+// looseUint decodes an unsigned integer Multipass may report either as a
+// JSON number or as a numeric string. Missing or unparseable values decode
+// to zero.
+type looseUint uint64
+
+func (u *looseUint) UnmarshalJSON(data []byte) error {
+	value, err := parseUintString(strings.Trim(string(data), `"`))
+	if err != nil {
+		value = 0
+	}
+	*u = looseUint(value)
+	return nil
+}
+
+func (r snapshotInfoResponse) toModel(instanceFilter string) []models.Snapshot {
 	out := []models.Snapshot{}
-	for instance, snaps := range r.Info {
+	// Accept both the 1.13 ("info") and 1.14+ ("instances") layouts.
+	entries := r.Instances
+	if len(entries) == 0 {
+		entries = r.Info
+	}
+	for instance, entry := range entries {
 		if instanceFilter != "" && instance != instanceFilter {
 			continue
 		}
-		for name, entry := range snaps {
+		for name, snap := range entry.Snapshots {
+			children := append([]string{}, snap.Children...)
+			sort.Strings(children)
 			out = append(out, models.Snapshot{
 				Instance: instance,
 				Name:     name,
-				Comment:  entry.Comment,
-				Parent:   entry.Parent,
+				Comment:  snap.Comment,
+				Parent:   snap.Parent,
+				Children: children,
+				Created:  parseSnapshotTime(snap.Created),
+				Size:     uint64(snap.Size),
 			})
 		}
 	}
-	sort.Slice(out, func(i, j int) bool {
-		if out[i].Instance == out[j].Instance {
-			return out[i].Name < out[j].Name
+	sortSnapshotsByCreation(out)
+	return out
+}
+
+// parseSnapshotTime parses the creation timestamp Multipass reports for a
+// snapshot. Unparseable or empty values yield the zero time.
+func parseSnapshotTime(value string) time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC()
 		}
-		return out[i].Instance < out[j].Instance
+	}
+	return time.Time{}
+}
+
+// sortSnapshotsByCreation orders snapshots oldest first, falling back to
+// instance and name so the result is deterministic when timestamps are
+// missing or equal.
+func sortSnapshotsByCreation(snaps []models.Snapshot) {
+	sort.SliceStable(snaps, func(i, j int) bool {
+		if !snaps[i].Created.Equal(snaps[j].Created) {
+			return snaps[i].Created.Before(snaps[j].Created)
+		}
+		if snaps[i].Instance != snaps[j].Instance {
+			return snaps[i].Instance < snaps[j].Instance
+		}
+		return snaps[i].Name < snaps[j].Name
 	})
-	return out
 }
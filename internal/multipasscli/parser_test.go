@@ -2,9 +2,14 @@ package multipasscli
 
 import (
 	"encoding/json"
+	"errors"
+	"math/rand"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
 )
 
 func TestListResponseToModel(t *testing.T) {
@@ -66,3 +71,403 @@ func TestInfoResponseToModel(t *testing.T) {
 		t.Fatalf("unexpected mounts: %#v", model.Mounts)
 	}
 }
+
+func TestInfoResponseToModel_interfaces(t *testing.T) {
+	payload := []byte(`{
+		"info":{
+			"bridged":{
+				"cpu_count":"2",
+				"ipv4":["10.0.0.5","192.168.1.40"],
+				"mac_address":"52:54:00:AA:BB:01",
+				"extra_interfaces":[
+					{"id":"eth1","mac_address":"52:54:00:aa:bb:02","ipv4":["192.168.1.40"]}
+				],
+				"release":"Ubuntu 24.04 LTS",
+				"state":"Running"
+			}
+		}
+	}`)
+
+	var resp infoResponse
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	model, err := resp.toModel("bridged")
+	if err != nil {
+		t.Fatalf("toModel: %v", err)
+	}
+
+	want := []models.NetworkInterface{
+		{Name: "default", MAC: "52:54:00:aa:bb:01", Addresses: []string{"10.0.0.5"}},
+		{Name: "eth1", MAC: "52:54:00:aa:bb:02", Addresses: []string{"192.168.1.40"}},
+	}
+	if diff := cmp.Diff(want, model.Interfaces); diff != "" {
+		t.Fatalf("unexpected interfaces diff: %s", diff)
+	}
+	if !model.AllInterfaces {
+		t.Fatalf("expected a payload with extra_interfaces to list every interface")
+	}
+}
+
+func TestInfoResponseToModel_interfacesAbsent(t *testing.T) {
+	payload := []byte(`{"info":{"old":{"ipv4":["10.0.0.9"],"state":"Running"}}}`)
+
+	var resp infoResponse
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	model, err := resp.toModel("old")
+	if err != nil {
+		t.Fatalf("toModel: %v", err)
+	}
+	if model.Interfaces != nil || model.AllInterfaces {
+		t.Fatalf("expected no interfaces for legacy payload, got %#v", model.Interfaces)
+	}
+}
+
+func TestInfoResponseToModel_mountMappings(t *testing.T) {
+	payload := []byte(`{
+		"info":{
+			"primary":{
+				"mounts":{
+					"/defaults":{"source_path":"/host/a","uid_mappings":["501:default"],"gid_mappings":["20:-1"]},
+					"/explicit":{"source_path":"/host/b","uid_mappings":["1000:1001","1002:1003"],"gid_mappings":["1000:1001"]},
+					"/legacy":{"source_path":"/host/c"}
+				},
+				"state":"Running"
+			}
+		}
+	}`)
+
+	var resp infoResponse
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	model, err := resp.toModel("primary")
+	if err != nil {
+		t.Fatalf("toModel: %v", err)
+	}
+
+	want := []models.Mount{
+		{HostPath: "/host/a", InstancePath: "/defaults", UIDMappings: []string{"501:default"}, GIDMappings: []string{"20:default"}},
+		{HostPath: "/host/b", InstancePath: "/explicit", UIDMappings: []string{"1000:1001", "1002:1003"}, GIDMappings: []string{"1000:1001"}},
+		{HostPath: "/host/c", InstancePath: "/legacy"},
+	}
+	if diff := cmp.Diff(want, model.Mounts); diff != "" {
+		t.Fatalf("unexpected mounts diff: %s", diff)
+	}
+}
+
+func TestSnapshotInfoResponseToModel(t *testing.T) {
+	payload := []byte(`{
+		"errors":[],
+		"info":{
+			"web":{
+				"snapshots":{
+					"second":{"children":[],"comment":"","created":"2024-05-02T10:00:00.123Z","parent":"first","size":"1048576"},
+					"first":{"children":["second"],"comment":"base","created":"2024-05-01T09:30:00.000Z","parent":"","size":2048}
+				}
+			}
+		}
+	}`)
+
+	var resp snapshotInfoResponse
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	snaps := resp.toModel("web")
+	if len(snaps) != 2 {
+		t.Fatalf("expected 2 snapshots, got %d", len(snaps))
+	}
+	if snaps[0].Name != "first" || snaps[1].Name != "second" {
+		t.Fatalf("expected snapshots ordered by creation, got %q then %q", snaps[0].Name, snaps[1].Name)
+	}
+	if diff := cmp.Diff([]string{"second"}, snaps[0].Children); diff != "" {
+		t.Fatalf("unexpected children diff: %s", diff)
+	}
+	if snaps[1].Parent != "first" {
+		t.Fatalf("unexpected parent %q", snaps[1].Parent)
+	}
+	if got := snaps[0].Created.Format(time.RFC3339); got != "2024-05-01T09:30:00Z" {
+		t.Fatalf("unexpected created time %s", got)
+	}
+	// Sizes are reported as numbers or numeric strings depending on the
+	// release.
+	if snaps[0].Size != 2048 || snaps[1].Size != 1048576 {
+		t.Fatalf("unexpected sizes %d, %d", snaps[0].Size, snaps[1].Size)
+	}
+}
+
+func TestVersionResponseToModel(t *testing.T) {
+	cases := map[string]struct {
+		payload string
+		want    models.VersionInfo
+	}{
+		"daemon reachable": {
+			payload: `{"multipass":"1.14.0","multipassd":"1.14.0"}`,
+			want:    models.VersionInfo{Client: "1.14.0", Daemon: "1.14.0"},
+		},
+		"daemon unreachable": {
+			payload: `{"multipass":"1.14.0"}`,
+			want:    models.VersionInfo{Client: "1.14.0"},
+		},
+	}
+
+	for name, tc := range cases {
+		var resp versionResponse
+		if err := json.Unmarshal([]byte(tc.payload), &resp); err != nil {
+			t.Fatalf("%s: unmarshal: %v", name, err)
+		}
+		if diff := cmp.Diff(tc.want, resp.toModel()); diff != "" {
+			t.Fatalf("%s: unexpected version diff: %s", name, diff)
+		}
+	}
+}
+
+func TestInfoResponseToModel_newLayout(t *testing.T) {
+	payload := []byte(`{
+		"errors":[],
+		"instances":{
+			"primary":{"cpu_count":"4","ipv4":["10.0.0.3"],"release":"Ubuntu 24.04 LTS","state":"Running"}
+		}
+	}`)
+
+	var resp infoResponse
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	model, err := resp.toModel("primary")
+	if err != nil {
+		t.Fatalf("toModel: %v", err)
+	}
+	if model.CPUCount != 4 || model.State != "Running" {
+		t.Fatalf("unexpected model: %#v", model)
+	}
+}
+
+func TestInfoResponseToModel_errorsMapToNotFound(t *testing.T) {
+	payloads := map[string]string{
+		"string errors": `{"errors":["instance \"ghost\" does not exist"],"instances":{}}`,
+		"object errors": `{"errors":[{"instance_name":"ghost","message":"does not exist"}],"info":{}}`,
+	}
+
+	for name, payload := range payloads {
+		var resp infoResponse
+		if err := json.Unmarshal([]byte(payload), &resp); err != nil {
+			t.Fatalf("%s: unmarshal: %v", name, err)
+		}
+		if _, err := resp.toModel("ghost"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("%s: expected ErrNotFound, got %v", name, err)
+		}
+	}
+}
+
+func TestInfoResponseMentions_wholeNames(t *testing.T) {
+	var resp infoResponse
+	payload := `{"errors":["instance \"web10\" does not exist",{"instance_name":"db-a","message":"does not exist"}],"instances":{}}`
+	if err := json.Unmarshal([]byte(payload), &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	for name, want := range map[string]bool{
+		"web10": true,
+		"web1":  false,
+		"eb10":  false,
+		"db-a":  true,
+		"db":    false,
+		"a":     false,
+	} {
+		if got := resp.mentions(name); got != want {
+			t.Errorf("mentions(%q) = %t, want %t", name, got, want)
+		}
+	}
+	if _, err := resp.toModel("web1"); errors.Is(err, ErrNotFound) {
+		t.Fatalf("an error about web10 should not make web1 not found, got %v", err)
+	}
+}
+
+func TestFindResponseToModel_remoteQualifiedKeys(t *testing.T) {
+	payload := []byte(`{
+		"images":{
+			"24.04":{"aliases":["noble","lts"],"os":"Ubuntu","release":"24.04 LTS","remote":"","version":"20240423"},
+			"daily:24.10":{"aliases":["oracular"],"os":"Ubuntu","release":"24.10","remote":"","version":"20240801"}
+		},
+		"blueprints (deprecated)":{
+			"appliance:nextcloud":{"aliases":[],"os":"","release":"Nextcloud appliance","remote":"appliance","version":"latest"}
+		}
+	}`)
+
+	var resp findResponse
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	got := resp.toModel()
+	want := []struct{ name, remote string }{
+		{"24.04", ""},
+		{"24.10", "daily"},
+		{"nextcloud", "appliance"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d images, got %#v", len(want), got)
+	}
+	for i, w := range want {
+		if got[i].Name != w.name || got[i].Remote != w.remote {
+			t.Fatalf("image %d: expected %s/%s, got %#v", i, w.remote, w.name, got[i])
+		}
+	}
+	if got[2].Kind != models.ImageKindBlueprint {
+		t.Fatalf("expected deprecated blueprint kind, got %s", got[2].Kind)
+	}
+}
+
+func TestSanitizeAndSplitIPs(t *testing.T) {
+	values := []string{" 10.0.0.3 ", "fd42:1::5", "N/A", "10.0.0.3", "::ffff:192.168.1.4", "", "fd42:1::5"}
+
+	sanitized := sanitizeIPs(values)
+	wantSanitized := []string{"10.0.0.3", "fd42:1::5", "::ffff:192.168.1.4"}
+	if diff := cmp.Diff(wantSanitized, sanitized); diff != "" {
+		t.Fatalf("unexpected sanitized addresses: %s", diff)
+	}
+
+	ipv4, ipv6 := splitIPs(sanitized)
+	if diff := cmp.Diff([]string{"10.0.0.3", "192.168.1.4"}, ipv4); diff != "" {
+		t.Fatalf("unexpected ipv4: %s", diff)
+	}
+	if diff := cmp.Diff([]string{"fd42:1::5"}, ipv6); diff != "" {
+		t.Fatalf("unexpected ipv6: %s", diff)
+	}
+}
+
+func TestInfoResponseToModel_stableIPOrder(t *testing.T) {
+	// The same instance as reported by two calls: the bridged address came
+	// back first the second time.
+	payloads := []string{
+		`{"info":{"vm":{"ipv4":["10.0.0.10","fd42::5","192.168.1.40","10.0.0.9"],"mac_address":"52:54:00:aa:bb:01","extra_interfaces":[{"id":"eth1","ipv4":["192.168.1.40"]}],"state":"Running"}}}`,
+		`{"info":{"vm":{"ipv4":["192.168.1.40","10.0.0.9","fd42::5","10.0.0.10"],"mac_address":"52:54:00:aa:bb:01","extra_interfaces":[{"id":"eth1","ipv4":["192.168.1.40"]}],"state":"Running"}}}`,
+	}
+	for _, payload := range payloads {
+		var resp infoResponse
+		if err := json.Unmarshal([]byte(payload), &resp); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		model, err := resp.toModel("vm")
+		if err != nil {
+			t.Fatalf("toModel: %v", err)
+		}
+		if diff := cmp.Diff([]string{"10.0.0.9", "10.0.0.10", "192.168.1.40"}, model.IPv4); diff != "" {
+			t.Fatalf("unexpected ipv4 for %s: %s", payload, diff)
+		}
+		if diff := cmp.Diff([]string{"10.0.0.9", "10.0.0.10", "fd42::5"}, model.Interfaces[0].Addresses); diff != "" {
+			t.Fatalf("unexpected default interface addresses for %s: %s", payload, diff)
+		}
+	}
+}
+
+func TestOrderIPs(t *testing.T) {
+	want := []string{"10.0.0.2", "10.0.0.10", "fd42::1", "192.168.1.4", "fd42::9"}
+	secondary := map[string]bool{"192.168.1.4": true, "fd42::9": true}
+
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		shuffled := append([]string(nil), want...)
+		rng.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+		if diff := cmp.Diff(want, orderIPs(shuffled, secondary)); diff != "" {
+			t.Fatalf("unexpected order: %s", diff)
+		}
+	}
+
+	if diff := cmp.Diff([]string{"10.0.0.2", "10.0.0.10"}, orderIPs([]string{"10.0.0.10", "10.0.0.2"}, nil)); diff != "" {
+		t.Fatalf("unexpected order without interface details: %s", diff)
+	}
+}
+
+func TestAliasesResponseToModel(t *testing.T) {
+	payload := []byte(`{
+		"active-context": "default",
+		"contexts": {
+			"default": {
+				"logs": {"command":"journalctl -f","instance":"app","working-directory":"map"},
+				"shell": {"command":"bash -c 'cd \"/home/ubuntu\" && exec bash'","instance":"app","working-directory":"default"}
+			},
+			"staging": {
+				"logs": {"command":"tail -f /var/log/syslog","instance":"stage","working-directory":"map"}
+			}
+		}
+	}`)
+
+	var resp aliasesResponse
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	want := []models.Alias{
+		{Name: "logs", Context: "default", Instance: "app", Command: "journalctl -f", MapWorkingDirectory: true},
+		{Name: "logs", Context: "staging", Instance: "stage", Command: "tail -f /var/log/syslog", MapWorkingDirectory: true},
+		{Name: "shell", Context: "default", Instance: "app", Command: "bash", WorkingDirectory: "/home/ubuntu"},
+	}
+	if diff := cmp.Diff(want, resp.toModel()); diff != "" {
+		t.Fatalf("unexpected aliases diff: %s", diff)
+	}
+	if resp.ActiveContext != "default" {
+		t.Fatalf("active context = %q", resp.ActiveContext)
+	}
+}
+
+func TestInfoResponseToModel_multipleInstances(t *testing.T) {
+	payload := []byte(`{
+		"errors":[],
+		"info":{
+			"web":{
+				"cpu_count":"2",
+				"disks":{"sda1":{"total":"5120","used":"1024"}},
+				"image_release":"24.04 LTS",
+				"ipv4":["10.0.0.2"],
+				"memory":{"total":2048,"used":512},
+				"mounts":{},
+				"release":"Ubuntu 24.04 LTS",
+				"snapshot_count":"0",
+				"state":"Running"
+			},
+			"db":{
+				"cpu_count":"",
+				"disks":{},
+				"image_release":"22.04 LTS",
+				"ipv4":[],
+				"memory":{},
+				"mounts":{},
+				"release":"",
+				"snapshot_count":"3",
+				"state":"Stopped"
+			}
+		}
+	}`)
+
+	var resp infoResponse
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	web, err := resp.toModel("web")
+	if err != nil {
+		t.Fatalf("web: %v", err)
+	}
+	if web.State != "Running" || web.CPUCount != 2 || len(web.IPv4) != 1 || web.DiskTotal != 5120 {
+		t.Fatalf("unexpected web model: %#v", web)
+	}
+	db, err := resp.toModel("db")
+	if err != nil {
+		t.Fatalf("db: %v", err)
+	}
+	if db.State != "Stopped" || db.CPUCount != 0 || db.SnapshotCount != 3 || len(db.IPv4) != 0 {
+		t.Fatalf("unexpected db model: %#v", db)
+	}
+	if _, err := resp.toModel("cache"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected an instance missing from the payload to be an error other than ErrNotFound, got %v", err)
+	}
+}
@@ -2,6 +2,9 @@ package provider
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
@@ -9,15 +12,18 @@ import (
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 
 	"github.com/todoroff/terraform-provider-multipass/internal/models"
 	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
 )
 
 var (
-	_ resource.Resource                = (*aliasResource)(nil)
-	_ resource.ResourceWithConfigure   = (*aliasResource)(nil)
-	_ resource.ResourceWithImportState = (*aliasResource)(nil)
+	_ resource.Resource                 = (*aliasResource)(nil)
+	_ resource.ResourceWithConfigure    = (*aliasResource)(nil)
+	_ resource.ResourceWithModifyPlan   = (*aliasResource)(nil)
+	_ resource.ResourceWithImportState  = (*aliasResource)(nil)
+	_ resource.ResourceWithUpgradeState = (*aliasResource)(nil)
 )
 
 // NewAliasResource instantiates the resource.
@@ -26,15 +32,18 @@ func NewAliasResource() resource.Resource {
 }
 
 type aliasResource struct {
-	client multipasscli.Client
+	client        multipasscli.Client
+	strictAliases bool
 }
 
 type aliasResourceModel struct {
 	ID               types.String `tfsdk:"id"`
 	Name             types.String `tfsdk:"name"`
+	Context          types.String `tfsdk:"context"`
 	Instance         types.String `tfsdk:"instance"`
 	Command          types.String `tfsdk:"command"`
 	WorkingDirectory types.String `tfsdk:"working_directory"`
+	MapWorkingDir    types.Bool   `tfsdk:"map_working_directory"`
 }
 
 func (r *aliasResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
@@ -44,9 +53,11 @@ func (r *aliasResource) Metadata(_ context.Context, req resource.MetadataRequest
 func (r *aliasResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
 	resp.Schema = schema.Schema{
 		Description: "Manages Multipass CLI aliases.",
+		Version:     1,
 		Attributes: map[string]schema.Attribute{
 			"id": schema.StringAttribute{
-				Computed: true,
+				Computed:    true,
+				Description: "Alias identifier in the form `<context>/<name>`.",
 				PlanModifiers: []planmodifier.String{
 					stringplanmodifier.UseStateForUnknown(),
 				},
@@ -58,6 +69,16 @@ func (r *aliasResource) Schema(_ context.Context, _ resource.SchemaRequest, resp
 					stringplanmodifier.RequiresReplace(),
 				},
 			},
+			"context": schema.StringAttribute{
+				Optional:            true,
+				Computed:            true,
+				Description:         "Alias context the alias belongs to. Defaults to the active context.",
+				MarkdownDescription: "Alias context the alias belongs to. Defaults to the active context. Multipass only creates aliases in the active context, so a different value requires `multipass prefer <context>` first.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
 			"instance": schema.StringAttribute{
 				Required:    true,
 				Description: "Target Multipass instance.",
@@ -71,6 +92,12 @@ func (r *aliasResource) Schema(_ context.Context, _ resource.SchemaRequest, resp
 				Description:         "Working directory inside the instance. The command is wrapped to cd into this directory before execution.",
 				MarkdownDescription: "Working directory inside the instance. The command is wrapped with `cd <dir> && exec <command>` automatically.",
 			},
+			"map_working_directory": schema.BoolAttribute{
+				Optional:            true,
+				Computed:            true,
+				Description:         "Mirror the host working directory into the instance before running the command. Defaults to true, or false when working_directory is set.",
+				MarkdownDescription: "Mirror the host working directory into the instance before running the command. Defaults to `true`, or `false` when `working_directory` is set; `false` creates the alias with `--no-map-working-directory`.",
+			},
 		},
 	}
 }
@@ -81,6 +108,83 @@ func (r *aliasResource) Configure(_ context.Context, req resource.ConfigureReque
 	}
 	data := req.ProviderData.(providerData)
 	r.client = data.client
+	r.strictAliases = data.strictAliases
+}
+
+func (r *aliasResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	planMapWorkingDirectory(ctx, req, resp)
+	if resp.Diagnostics.HasError() || r.client == nil {
+		return
+	}
+	r.checkInstance(ctx, req, resp)
+}
+
+// planMapWorkingDirectory resolves an omitted map_working_directory and
+// rejects mapping combined with an explicit working_directory, which the
+// command wrapper would override anyway.
+func planMapWorkingDirectory(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	var workingDirectory types.String
+	var mapWorkingDir types.Bool
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("working_directory"), &workingDirectory)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("map_working_directory"), &mapWorkingDir)...)
+	if resp.Diagnostics.HasError() || workingDirectory.IsUnknown() || mapWorkingDir.IsUnknown() {
+		return
+	}
+
+	if mapWorkingDir.IsNull() {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("map_working_directory"), workingDirectory.IsNull())...)
+		return
+	}
+	if mapWorkingDir.ValueBool() && !workingDirectory.IsNull() {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("map_working_directory"),
+			"Conflicting working directory settings",
+			"map_working_directory cannot be true when working_directory is set; the command always changes into working_directory.",
+		)
+	}
+}
+
+// checkInstance checks that the target instance exists when an alias is
+// created or retargeted. `multipass alias` accepts missing instances and
+// leaves a broken alias behind, which usually means the instance resource
+// is created later in the same apply without the alias depending on it.
+func (r *aliasResource) checkInstance(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	var instance types.String
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("instance"), &instance)...)
+	if resp.Diagnostics.HasError() || instance.IsNull() || instance.IsUnknown() {
+		return
+	}
+	if !req.State.Raw.IsNull() {
+		var prior types.String
+		resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root("instance"), &prior)...)
+		if resp.Diagnostics.HasError() || prior.Equal(instance) {
+			return
+		}
+	}
+
+	_, err := r.client.GetInstance(ctx, instance.ValueString())
+	if err == nil {
+		return
+	}
+	if !errors.Is(err, multipasscli.ErrNotFound) {
+		// The daemon may be unavailable at plan time; apply reports it.
+		tflog.Debug(ctx, "Skipping alias instance check", map[string]any{"instance": instance.ValueString(), "error": err.Error()})
+		return
+	}
+
+	summary := "Alias target instance not found"
+	detail := fmt.Sprintf("Instance %q does not exist. If it is created in this configuration, reference the instance resource "+
+		"(e.g. `instance = multipass_instance.app.name`) or add depends_on so the alias is created after it; "+
+		"otherwise the alias will point at a missing instance.", instance.ValueString())
+	if r.strictAliases {
+		resp.Diagnostics.AddAttributeError(path.Root("instance"), summary, detail)
+		return
+	}
+	resp.Diagnostics.AddAttributeWarning(path.Root("instance"), summary, detail)
 }
 
 func (r *aliasResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
@@ -95,12 +199,29 @@ func (r *aliasResource) Create(ctx context.Context, req resource.CreateRequest,
 		return
 	}
 
+	ctx, done := startOperation(ctx, "multipass_alias", "create", plan.Instance.ValueString())
+	defer done(&resp.Diagnostics)
+
+	active, err := r.client.ActiveAliasContext(ctx)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read alias context", err.Error())
+		return
+	}
+	if plan.Context.IsNull() || plan.Context.IsUnknown() {
+		plan.Context = types.StringValue(active)
+	}
+	if err := checkAliasContext(plan.Context.ValueString(), active); err != nil {
+		resp.Diagnostics.AddError("Alias context not active", err.Error())
+		return
+	}
+	resolveMapWorkingDirectory(&plan)
+
 	if err := r.client.CreateAlias(ctx, aliasFromModel(&plan)); err != nil {
 		resp.Diagnostics.AddError("Failed to create alias", err.Error())
 		return
 	}
 
-	plan.ID = plan.Name
+	plan.ID = types.StringValue(aliasResourceID(plan.Context.ValueString(), plan.Name.ValueString()))
 	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
 }
 
@@ -116,20 +237,46 @@ func (r *aliasResource) Read(ctx context.Context, req resource.ReadRequest, resp
 		return
 	}
 
+	ctx, done := startOperation(ctx, "multipass_alias", "read", state.Instance.ValueString())
+	defer done(&resp.Diagnostics)
+
 	aliases, err := r.client.ListAliases(ctx, false)
 	if err != nil {
 		resp.Diagnostics.AddError("Failed to list aliases", err.Error())
 		return
 	}
 
+	// State written before contexts were tracked has no context; such
+	// aliases were created in the active one.
+	aliasContext := state.Context.ValueString()
+	if aliasContext == "" {
+		aliasContext, err = r.client.ActiveAliasContext(ctx)
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to read alias context", err.Error())
+			return
+		}
+	}
+
 	name := state.Name.ValueString()
 	for _, alias := range aliases {
-		if alias.Name == name {
-			state.ID = types.StringValue(name)
+		if alias.Name == name && alias.Context == aliasContext {
+			state.ID = types.StringValue(aliasResourceID(alias.Context, name))
+			state.Context = types.StringValue(alias.Context)
+			state.MapWorkingDir = types.BoolValue(alias.MapWorkingDirectory)
 			state.Instance = types.StringValue(alias.Instance)
-			// command and working_directory are kept from state because
-			// the API returns the wrapped command which doesn't match
-			// the user's original values.
+			// command and working_directory are kept from state so that
+			// equivalent spellings do not produce a diff. They are only
+			// filled in from the listing after an import. The CLI's own
+			// working-directory mode ("map" or "default") is reported as
+			// map_working_directory, so an omitted working_directory stays
+			// null.
+			if state.Command.IsNull() {
+				state.Command = types.StringValue(alias.Command)
+				state.WorkingDirectory = types.StringNull()
+				if alias.WorkingDirectory != "" {
+					state.WorkingDirectory = types.StringValue(alias.WorkingDirectory)
+				}
+			}
 			resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
 			return
 		}
@@ -145,19 +292,50 @@ func (r *aliasResource) Update(ctx context.Context, req resource.UpdateRequest,
 	}
 
 	var plan aliasResourceModel
+	var state aliasResourceModel
 	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	// Multipass aliases cannot be updated in-place; delete then recreate.
-	if err := r.client.DeleteAlias(ctx, plan.Name.ValueString()); err != nil && err != multipasscli.ErrNotFound {
+	ctx, done := startOperation(ctx, "multipass_alias", "update", plan.Instance.ValueString())
+	defer done(&resp.Diagnostics)
+
+	// The recreated alias lands in the active context, so refuse before
+	// deleting anything if that is not the one the alias lives in.
+	active, err := r.client.ActiveAliasContext(ctx)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read alias context", err.Error())
+		return
+	}
+	if err := checkAliasContext(state.Context.ValueString(), active); err != nil {
+		resp.Diagnostics.AddError("Alias context not active", err.Error())
+		return
+	}
+
+	resolveMapWorkingDirectory(&plan)
+
+	// Multipass aliases cannot be updated in-place, and `multipass alias`
+	// refuses existing names; delete then recreate.
+	if err := r.client.DeleteAlias(ctx, state.Name.ValueString()); err != nil && !errors.Is(err, multipasscli.ErrNotFound) {
 		resp.Diagnostics.AddError("Failed to delete alias for update", err.Error())
 		return
 	}
 
 	if err := r.client.CreateAlias(ctx, aliasFromModel(&plan)); err != nil {
-		resp.Diagnostics.AddError("Failed to recreate alias", err.Error())
+		// Put the previous definition back so a failed update does not
+		// lose the alias entirely.
+		if rollbackErr := r.client.CreateAlias(ctx, aliasFromModel(&state)); rollbackErr != nil {
+			resp.Diagnostics.AddError(
+				"Failed to recreate alias",
+				fmt.Sprintf("%s\n\nRestoring the previous definition also failed, so alias %q no longer exists: %s", err, state.Name.ValueString(), rollbackErr),
+			)
+			resp.State.RemoveResource(ctx)
+			return
+		}
+		resp.Diagnostics.AddError("Failed to recreate alias", fmt.Sprintf("%s\n\nThe previous definition was restored.", err))
+		resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
 		return
 	}
 
@@ -175,20 +353,118 @@ func (r *aliasResource) Delete(ctx context.Context, req resource.DeleteRequest,
 		return
 	}
 
-	if err := r.client.DeleteAlias(ctx, state.Name.ValueString()); err != nil && err != multipasscli.ErrNotFound {
+	ctx, done := startOperation(ctx, "multipass_alias", "delete", state.Instance.ValueString())
+	defer done(&resp.Diagnostics)
+
+	name := state.Name.ValueString()
+	if aliasContext := state.Context.ValueString(); aliasContext != "" {
+		active, err := r.client.ActiveAliasContext(ctx)
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to read alias context", err.Error())
+			return
+		}
+		if aliasContext != active {
+			name = aliasContext + "." + name
+		}
+	}
+
+	if err := r.client.DeleteAlias(ctx, name); err != nil && !errors.Is(err, multipasscli.ErrNotFound) {
 		resp.Diagnostics.AddError("Failed to delete alias", err.Error())
 	}
 }
 
+func (r *aliasResource) UpgradeState(ctx context.Context) map[int64]resource.StateUpgrader {
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+	prior := schemaResp.Schema
+	prior.Version = 0
+
+	return map[int64]resource.StateUpgrader{
+		// Version 0 used the bare alias name as id. State written before
+		// contexts were tracked has no context either; Read resolves it
+		// against the active context and corrects the id if needed.
+		0: {
+			PriorSchema: &prior,
+			StateUpgrader: func(ctx context.Context, req resource.UpgradeStateRequest, resp *resource.UpgradeStateResponse) {
+				var state aliasResourceModel
+				resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+				if resp.Diagnostics.HasError() {
+					return
+				}
+				state.ID = types.StringValue(aliasResourceID(state.Context.ValueString(), state.Name.ValueString()))
+				resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+			},
+		},
+	}
+}
+
+// aliasResourceID builds the `<context>/<name>` id of an alias. An empty
+// context stands for Multipass's default one.
+func aliasResourceID(aliasContext, name string) string {
+	if aliasContext == "" {
+		aliasContext = multipasscli.DefaultAliasContext
+	}
+	return aliasContext + "/" + name
+}
+
+// parseAliasImportID accepts `<context>/<name>` as well as the bare alias
+// name used as id before contexts were tracked, which resolves in the
+// active context. The bare form is deprecated.
+func parseAliasImportID(id string) (aliasContext, name string, deprecated bool, err error) {
+	if aliasContext, name, ok := strings.Cut(id, "/"); ok {
+		if aliasContext == "" || name == "" || strings.Contains(name, "/") {
+			return "", "", false, fmt.Errorf("expected `<context>/<name>`, got %q", id)
+		}
+		return aliasContext, name, false, nil
+	}
+	if id == "" {
+		return "", "", false, fmt.Errorf("expected `<context>/<name>`, got an empty id")
+	}
+	return "", id, true, nil
+}
+
 func (r *aliasResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
-	resource.ImportStatePassthroughID(ctx, path.Root("name"), req, resp)
+	aliasContext, name, deprecated, err := parseAliasImportID(req.ID)
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid import ID", err.Error())
+		return
+	}
+	if deprecated {
+		resp.Diagnostics.AddWarning(
+			"Deprecated import ID",
+			fmt.Sprintf("Importing an alias by bare name is deprecated and resolves it in the active context; use `<context>/%s` instead.", name),
+		)
+	}
+	if aliasContext != "" {
+		resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("context"), aliasContext)...)
+	}
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("name"), name)...)
+}
+
+// checkAliasContext reports an error when aliasContext is set and differs
+// from the active context; `multipass alias` has no way to target another.
+func checkAliasContext(aliasContext, active string) error {
+	if aliasContext == "" || aliasContext == active {
+		return nil
+	}
+	return fmt.Errorf("alias context %q is not the active context %q; Multipass only creates aliases in the active context. run `multipass prefer %s` and apply again", aliasContext, active, aliasContext)
 }
 
 func aliasFromModel(m *aliasResourceModel) models.Alias {
 	return models.Alias{
-		Name:             m.Name.ValueString(),
-		Instance:         m.Instance.ValueString(),
-		Command:          m.Command.ValueString(),
-		WorkingDirectory: valueOrEmpty(m.WorkingDirectory),
+		Name:                m.Name.ValueString(),
+		Context:             m.Context.ValueString(),
+		Instance:            m.Instance.ValueString(),
+		Command:             m.Command.ValueString(),
+		WorkingDirectory:    valueOrEmpty(m.WorkingDirectory),
+		MapWorkingDirectory: m.MapWorkingDir.ValueBool(),
+	}
+}
+
+// resolveMapWorkingDirectory fills in map_working_directory when it was
+// still unknown at plan time because working_directory was unknown.
+func resolveMapWorkingDirectory(m *aliasResourceModel) {
+	if m.MapWorkingDir.IsUnknown() || m.MapWorkingDir.IsNull() {
+		m.MapWorkingDir = types.BoolValue(m.WorkingDirectory.IsNull())
 	}
 }
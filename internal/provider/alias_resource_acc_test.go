@@ -35,7 +35,7 @@ func TestAccAliasResource_basic(t *testing.T) {
 			{
 				ResourceName:                         rn,
 				ImportState:                          true,
-				ImportStateId:                        aliasName,
+				ImportStateId:                        "default/" + aliasName,
 				ImportStateVerify:                    true,
 				ImportStateVerifyIdentifierAttribute: "name",
 				ImportStateVerifyIgnore:              []string{"command"},
@@ -0,0 +1,123 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+)
+
+// TestAliasResourceCRUD drives multipass_alias through Terraform against a
+// fakeHost, so it runs without Multipass or TF_ACC.
+func TestAliasResourceCRUD(t *testing.T) {
+	t.Parallel()
+
+	const rn = "multipass_alias.test"
+	for _, tc := range []struct {
+		name  string
+		steps func(host *fakeHost) []resource.TestStep
+	}{
+		{
+			name: "create and import",
+			steps: func(*fakeHost) []resource.TestStep {
+				return []resource.TestStep{
+					{
+						Config: testFakeAliasConfig("ls", ""),
+						Check: resource.ComposeAggregateTestCheckFunc(
+							resource.TestCheckResourceAttr(rn, "id", "default/vm-ls"),
+							resource.TestCheckResourceAttr(rn, "context", "default"),
+							resource.TestCheckResourceAttr(rn, "instance", "vm"),
+							resource.TestCheckResourceAttr(rn, "map_working_directory", "true"),
+						),
+					},
+					{
+						ResourceName:                         rn,
+						ImportState:                          true,
+						ImportStateId:                        "default/vm-ls",
+						ImportStateVerify:                    true,
+						ImportStateVerifyIdentifierAttribute: "name",
+					},
+				}
+			},
+		},
+		{
+			name: "update recreates the alias",
+			steps: func(host *fakeHost) []resource.TestStep {
+				return []resource.TestStep{
+					{Config: testFakeAliasConfig("ls", "")},
+					{
+						Config: testFakeAliasConfig("pwd", "/home/ubuntu"),
+						Check: resource.ComposeAggregateTestCheckFunc(
+							resource.TestCheckResourceAttr(rn, "command", "pwd"),
+							resource.TestCheckResourceAttr(rn, "map_working_directory", "false"),
+							checkFakeHost(func() error {
+								aliases, _ := host.ListAliases(context.Background(), true)
+								if len(aliases) != 1 || aliases[0].Command != "pwd" || aliases[0].WorkingDirectory != "/home/ubuntu" {
+									return fmt.Errorf("unexpected aliases after update: %+v", aliases)
+								}
+								return nil
+							}),
+						),
+					},
+				}
+			},
+		},
+		{
+			name: "recreate after deletion outside terraform",
+			steps: func(host *fakeHost) []resource.TestStep {
+				return []resource.TestStep{
+					{Config: testFakeAliasConfig("ls", "")},
+					{
+						PreConfig: func() { host.removeAlias("vm-ls") },
+						Config:    testFakeAliasConfig("ls", ""),
+						Check: checkFakeHost(func() error {
+							aliases, _ := host.ListAliases(context.Background(), true)
+							if len(aliases) != 1 {
+								return fmt.Errorf("expected the alias to be recreated, got %+v", aliases)
+							}
+							return nil
+						}),
+					},
+				}
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			host := newFakeHost()
+			resource.UnitTest(t, resource.TestCase{
+				ProtoV6ProviderFactories: fakeHostProviderFactories(host),
+				CheckDestroy: func(*terraform.State) error {
+					aliases, _ := host.ListAliases(context.Background(), true)
+					if len(aliases) > 0 {
+						return fmt.Errorf("aliases still exist after destroy: %+v", aliases)
+					}
+					return nil
+				},
+				Steps: tc.steps(host),
+			})
+		})
+	}
+}
+
+func testFakeAliasConfig(command, workingDirectory string) string {
+	wd := ""
+	if workingDirectory != "" {
+		wd = fmt.Sprintf("working_directory = %q", workingDirectory)
+	}
+	return testProviderConfig + fmt.Sprintf(`
+resource "multipass_instance" "test" {
+  name = "vm"
+}
+
+resource "multipass_alias" "test" {
+  name     = "vm-ls"
+  instance = multipass_instance.test.name
+  command  = %q
+  %s
+}
+`, command, wd)
+}
@@ -0,0 +1,572 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+// updateAlias runs aliasResource.Update from state to plan and returns the
+// recorded CLI calls, the resulting state and the diagnostics.
+func updateAlias(t *testing.T, client *fakeClient, state, plan aliasResourceModel) ([]string, tfsdk.State, diag.Diagnostics) {
+	t.Helper()
+	ctx := context.Background()
+
+	r := &aliasResource{client: client}
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	tfState := tfsdk.State{Schema: schemaResp.Schema}
+	if diags := tfState.Set(ctx, &state); diags.HasError() {
+		t.Fatalf("set state: %v", diags)
+	}
+	tfPlan := tfsdk.Plan{Schema: schemaResp.Schema}
+	if diags := tfPlan.Set(ctx, &plan); diags.HasError() {
+		t.Fatalf("set plan: %v", diags)
+	}
+
+	// The framework pre-populates the new state with the plan.
+	resp := resource.UpdateResponse{State: tfsdk.State{Schema: schemaResp.Schema, Raw: tfPlan.Raw}}
+	r.Update(ctx, resource.UpdateRequest{State: tfState, Plan: tfPlan}, &resp)
+	return client.recorded(), resp.State, resp.Diagnostics
+}
+
+func TestAliasUpdate(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	state := aliasResourceModel{
+		ID:               types.StringValue("default/logs"),
+		Name:             types.StringValue("logs"),
+		Context:          types.StringValue("default"),
+		Instance:         types.StringValue("app"),
+		Command:          types.StringValue("journalctl -f"),
+		WorkingDirectory: types.StringNull(),
+		MapWorkingDir:    types.BoolValue(true),
+	}
+
+	t.Run("command change", func(t *testing.T) {
+		plan := state
+		plan.Command = types.StringValue("journalctl -u app -f")
+
+		calls, newState, diags := updateAlias(t, &fakeClient{}, state, plan)
+		if diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		want := []string{"unalias logs", "alias app:journalctl -u app -f logs"}
+		if diff := cmp.Diff(want, calls); diff != "" {
+			t.Fatalf("unexpected calls: %s", diff)
+		}
+		var got aliasResourceModel
+		newState.Get(ctx, &got)
+		if got.Command.ValueString() != "journalctl -u app -f" {
+			t.Fatalf("state command = %s", got.Command)
+		}
+	})
+
+	t.Run("instance change", func(t *testing.T) {
+		plan := state
+		plan.Instance = types.StringValue("db")
+
+		calls, _, diags := updateAlias(t, &fakeClient{}, state, plan)
+		if diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		want := []string{"unalias logs", "alias db:journalctl -f logs"}
+		if diff := cmp.Diff(want, calls); diff != "" {
+			t.Fatalf("unexpected calls: %s", diff)
+		}
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		plan := state
+		plan.Instance = types.StringValue("missing")
+		client := &fakeClient{
+			createAlias: func(alias models.Alias) error {
+				if alias.Instance == "missing" {
+					return errors.New(`instance "missing" does not exist`)
+				}
+				return nil
+			},
+		}
+
+		calls, newState, diags := updateAlias(t, client, state, plan)
+		if !diags.HasError() || !strings.Contains(diags.Errors()[0].Detail(), "previous definition was restored") {
+			t.Fatalf("expected restored error, got %v", diags)
+		}
+		want := []string{"unalias logs", "alias missing:journalctl -f logs", "alias app:journalctl -f logs"}
+		if diff := cmp.Diff(want, calls); diff != "" {
+			t.Fatalf("unexpected calls: %s", diff)
+		}
+		var got aliasResourceModel
+		newState.Get(ctx, &got)
+		if got.Instance.ValueString() != "app" {
+			t.Fatalf("expected prior state to be kept, got instance %s", got.Instance)
+		}
+	})
+
+	t.Run("rollback fails", func(t *testing.T) {
+		plan := state
+		plan.Command = types.StringValue("journalctl -u app -f")
+		client := &fakeClient{
+			createAlias: func(models.Alias) error {
+				return errors.New("permission denied")
+			},
+		}
+
+		_, newState, diags := updateAlias(t, client, state, plan)
+		if !diags.HasError() || !strings.Contains(diags.Errors()[0].Detail(), "no longer exists") {
+			t.Fatalf("expected lost alias error, got %v", diags)
+		}
+		if !newState.Raw.IsNull() {
+			t.Fatalf("expected the resource to be removed from state")
+		}
+	})
+}
+
+// readAlias runs aliasResource.Read against a listing and returns the
+// refreshed state.
+func readAlias(t *testing.T, listing []models.Alias, state aliasResourceModel) aliasResourceModel {
+	t.Helper()
+	return readAliasWith(t, &fakeClient{listAliases: func() ([]models.Alias, error) { return listing, nil }}, state)
+}
+
+func readAliasWith(t *testing.T, client *fakeClient, state aliasResourceModel) aliasResourceModel {
+	t.Helper()
+	ctx := context.Background()
+
+	r := &aliasResource{client: client}
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	tfState := tfsdk.State{Schema: schemaResp.Schema}
+	if diags := tfState.Set(ctx, &state); diags.HasError() {
+		t.Fatalf("set state: %v", diags)
+	}
+	resp := resource.ReadResponse{State: tfState}
+	r.Read(ctx, resource.ReadRequest{State: tfState}, &resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+	}
+
+	var got aliasResourceModel
+	resp.Diagnostics.Append(resp.State.Get(ctx, &got)...)
+	return got
+}
+
+func TestAliasReadWorkingDirectory(t *testing.T) {
+	t.Parallel()
+
+	t.Run("default stays null", func(t *testing.T) {
+		state := aliasResourceModel{
+			ID:               types.StringValue("default/logs"),
+			Name:             types.StringValue("logs"),
+			Context:          types.StringValue("default"),
+			Instance:         types.StringValue("app"),
+			Command:          types.StringValue("journalctl -f"),
+			WorkingDirectory: types.StringNull(),
+			MapWorkingDir:    types.BoolValue(true),
+		}
+		// `multipass aliases` reports working-directory "map" for this
+		// alias; it becomes map_working_directory, not a directory.
+		got := readAlias(t, []models.Alias{{Name: "logs", Context: "default", Instance: "app", Command: "journalctl -f", MapWorkingDirectory: true}}, state)
+		if diff := cmp.Diff(state, got); diff != "" {
+			t.Fatalf("state changed on refresh: %s", diff)
+		}
+	})
+
+	t.Run("explicit directory kept", func(t *testing.T) {
+		state := aliasResourceModel{
+			ID:               types.StringValue("default/shell"),
+			Name:             types.StringValue("shell"),
+			Context:          types.StringValue("default"),
+			Instance:         types.StringValue("app"),
+			Command:          types.StringValue("bash"),
+			WorkingDirectory: types.StringValue("/home/ubuntu"),
+			MapWorkingDir:    types.BoolValue(false),
+		}
+		got := readAlias(t, []models.Alias{{Name: "shell", Context: "default", Instance: "app", Command: "bash", WorkingDirectory: "/home/ubuntu"}}, state)
+		if diff := cmp.Diff(state, got); diff != "" {
+			t.Fatalf("state changed on refresh: %s", diff)
+		}
+	})
+
+	t.Run("import fills command and directory", func(t *testing.T) {
+		state := aliasResourceModel{
+			ID:               types.StringNull(),
+			Name:             types.StringValue("shell"),
+			Context:          types.StringNull(),
+			Instance:         types.StringNull(),
+			Command:          types.StringNull(),
+			WorkingDirectory: types.StringNull(),
+		}
+		got := readAlias(t, []models.Alias{{Name: "shell", Context: "default", Instance: "app", Command: "bash", WorkingDirectory: "/home/ubuntu"}}, state)
+		if got.Command.ValueString() != "bash" || got.WorkingDirectory.ValueString() != "/home/ubuntu" || got.Instance.ValueString() != "app" {
+			t.Fatalf("unexpected imported state: %+v", got)
+		}
+	})
+}
+
+func TestAliasReadContext(t *testing.T) {
+	t.Parallel()
+
+	listing := []models.Alias{
+		{Name: "logs", Context: "default", Instance: "app", Command: "journalctl -f"},
+		{Name: "logs", Context: "staging", Instance: "stage", Command: "tail -f /var/log/syslog"},
+	}
+	state := aliasResourceModel{
+		ID:               types.StringValue("staging/logs"),
+		Name:             types.StringValue("logs"),
+		Context:          types.StringValue("staging"),
+		Instance:         types.StringValue("stage"),
+		Command:          types.StringValue("tail -f /var/log/syslog"),
+		WorkingDirectory: types.StringNull(),
+		MapWorkingDir:    types.BoolValue(false),
+	}
+
+	t.Run("matches by context", func(t *testing.T) {
+		got := readAlias(t, listing, state)
+		if diff := cmp.Diff(state, got); diff != "" {
+			t.Fatalf("state changed on refresh: %s", diff)
+		}
+	})
+
+	t.Run("missing context state uses active", func(t *testing.T) {
+		legacy := state
+		legacy.Context = types.StringNull()
+		legacy.ID = types.StringValue("logs")
+		client := &fakeClient{
+			listAliases:   func() ([]models.Alias, error) { return listing, nil },
+			activeContext: "staging",
+		}
+		got := readAliasWith(t, client, legacy)
+		if diff := cmp.Diff(state, got); diff != "" {
+			t.Fatalf("unexpected state: %s", diff)
+		}
+	})
+}
+
+func TestAliasUpdateInactiveContext(t *testing.T) {
+	t.Parallel()
+
+	state := aliasResourceModel{
+		ID:               types.StringValue("staging/logs"),
+		Name:             types.StringValue("logs"),
+		Context:          types.StringValue("staging"),
+		Instance:         types.StringValue("stage"),
+		Command:          types.StringValue("journalctl -f"),
+		WorkingDirectory: types.StringNull(),
+		MapWorkingDir:    types.BoolValue(true),
+	}
+	plan := state
+	plan.Command = types.StringValue("journalctl -u app -f")
+
+	calls, _, diags := updateAlias(t, &fakeClient{}, state, plan)
+	if !diags.HasError() || !strings.Contains(diags.Errors()[0].Detail(), "multipass prefer staging") {
+		t.Fatalf("expected inactive context error, got %v", diags)
+	}
+	if len(calls) != 0 {
+		t.Fatalf("expected no CLI calls, got %v", calls)
+	}
+}
+
+func TestParseAliasImportID(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		context, name string
+		deprecated    bool
+		wantErr       bool
+	}{
+		"staging/logs": {context: "staging", name: "logs"},
+		"default/logs": {context: "default", name: "logs"},
+		"logs":         {name: "logs", deprecated: true},
+		"":             {wantErr: true},
+		"/logs":        {wantErr: true},
+		"staging/":     {wantErr: true},
+		"a/b/c":        {wantErr: true},
+	}
+	for id, tc := range cases {
+		aliasContext, name, deprecated, err := parseAliasImportID(id)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%q: expected an error", id)
+			}
+			continue
+		}
+		if err != nil || aliasContext != tc.context || name != tc.name || deprecated != tc.deprecated {
+			t.Errorf("%q: got %q, %q, %t, %v", id, aliasContext, name, deprecated, err)
+		}
+	}
+}
+
+func TestAliasImportState(t *testing.T) {
+	t.Parallel()
+
+	for id, want := range map[string][2]string{
+		"logs":         {"", "logs"},
+		"staging/logs": {"staging", "logs"},
+	} {
+		ctx := context.Background()
+		r := &aliasResource{}
+		var schemaResp resource.SchemaResponse
+		r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+		resp := resource.ImportStateResponse{State: tfsdk.State{Schema: schemaResp.Schema}}
+		resp.State.Raw = tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil)
+		r.ImportState(ctx, resource.ImportStateRequest{ID: id}, &resp)
+		if resp.Diagnostics.HasError() {
+			t.Fatalf("%s: unexpected diagnostics: %v", id, resp.Diagnostics)
+		}
+		if deprecated := want[0] == ""; deprecated != (resp.Diagnostics.WarningsCount() == 1) {
+			t.Fatalf("%s: unexpected warnings: %v", id, resp.Diagnostics)
+		}
+		var got aliasResourceModel
+		resp.State.Get(ctx, &got)
+		if got.Context.ValueString() != want[0] || got.Name.ValueString() != want[1] {
+			t.Fatalf("%s: got context %s name %s", id, got.Context, got.Name)
+		}
+	}
+}
+
+func TestUpgradeAliasStateQualifiesID(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	r := &aliasResource{}
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+	upgrader := r.UpgradeState(ctx)[0]
+
+	for aliasContext, want := range map[string]string{
+		"":        "default/logs",
+		"staging": "staging/logs",
+	} {
+		prior := aliasResourceModel{
+			ID:               types.StringValue("logs"),
+			Name:             types.StringValue("logs"),
+			Context:          types.StringNull(),
+			Instance:         types.StringValue("app"),
+			Command:          types.StringValue("journalctl -f"),
+			WorkingDirectory: types.StringNull(),
+			MapWorkingDir:    types.BoolNull(),
+		}
+		if aliasContext != "" {
+			prior.Context = types.StringValue(aliasContext)
+		}
+		state := tfsdk.State{Schema: *upgrader.PriorSchema}
+		if diags := state.Set(ctx, &prior); diags.HasError() {
+			t.Fatalf("set state: %v", diags)
+		}
+
+		resp := resource.UpgradeStateResponse{State: tfsdk.State{Schema: schemaResp.Schema}}
+		upgrader.StateUpgrader(ctx, resource.UpgradeStateRequest{State: &state}, &resp)
+		if resp.Diagnostics.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+		}
+		var upgraded aliasResourceModel
+		resp.State.Get(ctx, &upgraded)
+		if got := upgraded.ID.ValueString(); got != want {
+			t.Fatalf("id = %q, want %q", got, want)
+		}
+		if !upgraded.Context.Equal(prior.Context) {
+			t.Fatalf("context changed to %s", upgraded.Context)
+		}
+	}
+}
+
+// planAlias runs aliasResource.ModifyPlan for creating plan, or for moving
+// from state when it is non-nil, and returns the response.
+func planAlias(t *testing.T, r *aliasResource, state *aliasResourceModel, plan aliasResourceModel) resource.ModifyPlanResponse {
+	t.Helper()
+	ctx := context.Background()
+
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	tfState := tfsdk.State{Schema: schemaResp.Schema, Raw: tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil)}
+	if state != nil {
+		if diags := tfState.Set(ctx, state); diags.HasError() {
+			t.Fatalf("set state: %v", diags)
+		}
+	}
+	tfPlan := tfsdk.Plan{Schema: schemaResp.Schema}
+	if diags := tfPlan.Set(ctx, &plan); diags.HasError() {
+		t.Fatalf("set plan: %v", diags)
+	}
+
+	// Computed attributes are unknown in the plan and null in config.
+	config := plan
+	config.ID = types.StringNull()
+	if config.Context.IsUnknown() {
+		config.Context = types.StringNull()
+	}
+	tfConfig := tfsdk.Config{Schema: schemaResp.Schema}
+	tfConfigState := tfsdk.State{Schema: schemaResp.Schema}
+	if diags := tfConfigState.Set(ctx, &config); diags.HasError() {
+		t.Fatalf("set config: %v", diags)
+	}
+	tfConfig.Raw = tfConfigState.Raw
+
+	resp := resource.ModifyPlanResponse{Plan: tfPlan}
+	r.ModifyPlan(ctx, resource.ModifyPlanRequest{Config: tfConfig, State: tfState, Plan: tfPlan}, &resp)
+	return resp
+}
+
+func TestAliasModifyPlanInstanceCheck(t *testing.T) {
+	t.Parallel()
+
+	plan := aliasResourceModel{
+		ID:               types.StringUnknown(),
+		Name:             types.StringValue("logs"),
+		Context:          types.StringUnknown(),
+		Instance:         types.StringValue("app"),
+		Command:          types.StringValue("journalctl -f"),
+		WorkingDirectory: types.StringNull(),
+		MapWorkingDir:    types.BoolNull(),
+	}
+	missing := func() *fakeClient {
+		return &fakeClient{getInstance: func(string) (*models.Instance, error) { return nil, multipasscli.ErrNotFound }}
+	}
+
+	t.Run("existing instance", func(t *testing.T) {
+		client := &fakeClient{}
+		if diags := planAlias(t, &aliasResource{client: client}, nil, plan).Diagnostics; len(diags) != 0 {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		if diff := cmp.Diff([]string{"info app"}, client.recorded()); diff != "" {
+			t.Fatalf("unexpected calls: %s", diff)
+		}
+	})
+
+	t.Run("missing instance warns", func(t *testing.T) {
+		diags := planAlias(t, &aliasResource{client: missing()}, nil, plan).Diagnostics
+		if diags.HasError() || diags.WarningsCount() != 1 {
+			t.Fatalf("expected one warning, got %v", diags)
+		}
+	})
+
+	t.Run("missing instance fails when strict", func(t *testing.T) {
+		diags := planAlias(t, &aliasResource{client: missing(), strictAliases: true}, nil, plan).Diagnostics
+		if !diags.HasError() {
+			t.Fatalf("expected an error, got %v", diags)
+		}
+	})
+
+	t.Run("unknown instance skipped", func(t *testing.T) {
+		client := missing()
+		unknown := plan
+		unknown.Instance = types.StringUnknown()
+		if diags := planAlias(t, &aliasResource{client: client, strictAliases: true}, nil, unknown).Diagnostics; len(diags) != 0 {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		if calls := client.recorded(); len(calls) != 0 {
+			t.Fatalf("expected no CLI calls, got %v", calls)
+		}
+	})
+
+	t.Run("unchanged instance skipped", func(t *testing.T) {
+		client := missing()
+		state := plan
+		state.ID = types.StringValue("default/logs")
+		state.Context = types.StringValue("default")
+		if diags := planAlias(t, &aliasResource{client: client, strictAliases: true}, &state, state).Diagnostics; len(diags) != 0 {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		if calls := client.recorded(); len(calls) != 0 {
+			t.Fatalf("expected no CLI calls, got %v", calls)
+		}
+	})
+}
+
+func TestAliasModifyPlanMapWorkingDirectory(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	cases := map[string]struct {
+		workingDirectory types.String
+		mapWorkingDir    types.Bool
+		want             types.Bool
+		wantErr          bool
+	}{
+		"defaults to mapping":           {workingDirectory: types.StringNull(), mapWorkingDir: types.BoolNull(), want: types.BoolValue(true)},
+		"directory disables mapping":    {workingDirectory: types.StringValue("/srv"), mapWorkingDir: types.BoolNull(), want: types.BoolValue(false)},
+		"explicit false":                {workingDirectory: types.StringNull(), mapWorkingDir: types.BoolValue(false), want: types.BoolValue(false)},
+		"directory with explicit false": {workingDirectory: types.StringValue("/srv"), mapWorkingDir: types.BoolValue(false), want: types.BoolValue(false)},
+		"directory conflicts":           {workingDirectory: types.StringValue("/srv"), mapWorkingDir: types.BoolValue(true), wantErr: true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			plan := aliasResourceModel{
+				ID:               types.StringUnknown(),
+				Name:             types.StringValue("logs"),
+				Context:          types.StringUnknown(),
+				Instance:         types.StringValue("app"),
+				Command:          types.StringValue("journalctl -f"),
+				WorkingDirectory: tc.workingDirectory,
+				MapWorkingDir:    tc.mapWorkingDir,
+			}
+			resp := planAlias(t, &aliasResource{client: &fakeClient{}}, nil, plan)
+			if tc.wantErr {
+				if !resp.Diagnostics.HasError() {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+			}
+			var got types.Bool
+			resp.Plan.GetAttribute(ctx, path.Root("map_working_directory"), &got)
+			if !got.Equal(tc.want) {
+				t.Fatalf("map_working_directory = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAliasDeleteAlreadyRemoved(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	client := &fakeClient{deleteAlias: func(string) error { return multipasscli.ErrNotFound }}
+	r := &aliasResource{client: client}
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	state := aliasResourceModel{
+		ID:               types.StringValue("default/logs"),
+		Name:             types.StringValue("logs"),
+		Context:          types.StringValue("default"),
+		Instance:         types.StringValue("app"),
+		Command:          types.StringValue("journalctl -f"),
+		WorkingDirectory: types.StringNull(),
+		MapWorkingDir:    types.BoolValue(true),
+	}
+	tfState := tfsdk.State{Schema: schemaResp.Schema}
+	if diags := tfState.Set(ctx, &state); diags.HasError() {
+		t.Fatalf("set state: %v", diags)
+	}
+
+	resp := resource.DeleteResponse{State: tfState}
+	r.Delete(ctx, resource.DeleteRequest{State: tfState}, &resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+	}
+	if diff := cmp.Diff([]string{"unalias logs"}, client.recorded()); diff != "" {
+		t.Fatalf("unexpected calls: %s", diff)
+	}
+}
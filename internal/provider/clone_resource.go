@@ -0,0 +1,402 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/terraform-plugin-framework-timeouts/resource/timeouts"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+var (
+	_ resource.Resource                = (*cloneResource)(nil)
+	_ resource.ResourceWithConfigure   = (*cloneResource)(nil)
+	_ resource.ResourceWithImportState = (*cloneResource)(nil)
+)
+
+// NewCloneResource instantiates the clone resource.
+func NewCloneResource() resource.Resource {
+	return &cloneResource{}
+}
+
+type cloneResource struct {
+	client         multipasscli.Client
+	purgeOnDelete  bool
+	commandTimeout time.Duration
+	// multipassVersion is the detected CLI version, or nil when unknown.
+	multipassVersion *version.Version
+}
+
+type cloneResourceModel struct {
+	ID             types.String   `tfsdk:"id"`
+	Name           types.String   `tfsdk:"name"`
+	SourceInstance types.String   `tfsdk:"source_instance"`
+	StopSource     types.Bool     `tfsdk:"stop_source"`
+	Start          types.Bool     `tfsdk:"start"`
+	PurgeOnDelete  types.Bool     `tfsdk:"purge_on_delete"`
+	Timeouts       timeouts.Value `tfsdk:"timeouts"`
+	IPv4           types.List     `tfsdk:"ipv4"`
+	IPv6           types.List     `tfsdk:"ipv6"`
+	State          types.String   `tfsdk:"state"`
+	Release        types.String   `tfsdk:"release"`
+	ImageRelease   types.String   `tfsdk:"image_release"`
+	LastUpdated    types.String   `tfsdk:"last_updated"`
+}
+
+func (r *cloneResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_clone"
+}
+
+func (r *cloneResource) Schema(ctx context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Creates a Multipass instance by cloning a stopped instance with `multipass clone`, then manages it like any other instance.",
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:    true,
+				Description: "The clone's instance name.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"name": schema.StringAttribute{
+				Required:    true,
+				Description: "Name of the new instance. Must be unique per Multipass host. Changing forces recreation.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"source_instance": schema.StringAttribute{
+				Required:    true,
+				Description: "Instance to clone. It must be stopped unless stop_source is set. Changing forces recreation.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"stop_source": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Stop a running or suspended source instance before cloning and return it to that state afterwards (default: false). Only affects creation.",
+			},
+			"start": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Start the clone once it is created; `multipass clone` leaves it stopped (default: true). Only affects creation.",
+			},
+			"purge_on_delete": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Purge the clone on destroy. When false, it is only soft-deleted and can be brought back with `multipass recover`. Defaults to the provider's purge_on_delete, which defaults to true.",
+			},
+			"ipv4": schema.ListAttribute{
+				Computed:    true,
+				Description: "Assigned IPv4 addresses as reported by `multipass info`.",
+				ElementType: types.StringType,
+			},
+			"ipv6": schema.ListAttribute{
+				Computed:    true,
+				Description: "Assigned IPv6 addresses as reported by `multipass info` on dual-stack networks.",
+				ElementType: types.StringType,
+			},
+			"state": schema.StringAttribute{
+				Computed:    true,
+				Description: "Current power state reported by Multipass.",
+			},
+			"release": schema.StringAttribute{
+				Computed:    true,
+				Description: "Operating system release running in the instance.",
+			},
+			"image_release": schema.StringAttribute{
+				Computed:    true,
+				Description: "Release name pulled from the image metadata.",
+			},
+			"last_updated": schema.StringAttribute{
+				Computed:    true,
+				Description: "Timestamp of the last information refresh in RFC3339 format.",
+			},
+		},
+		Blocks: map[string]schema.Block{
+			"timeouts": timeouts.Block(ctx, timeouts.Opts{
+				Create: true,
+				Read:   true,
+				Delete: true,
+			}),
+		},
+	}
+}
+
+func (r *cloneResource) Configure(_ context.Context, req resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	data := req.ProviderData.(providerData)
+	r.client = data.client
+	r.purgeOnDelete = data.purgeOnDelete
+	r.commandTimeout = data.commandTimeout
+	r.multipassVersion = data.multipassVersion
+}
+
+func (r *cloneResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	if r.client == nil {
+		resp.Diagnostics.AddError("Client not configured", "The provider Multipass client was not configured.")
+		return
+	}
+
+	var plan cloneResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ctx, done := startOperation(ctx, "multipass_clone", "create", plan.Name.ValueString())
+	defer done(&resp.Diagnostics)
+
+	createTimeout, diags := plan.Timeouts.Create(ctx, r.commandTimeout)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, createTimeout)
+	defer cancel()
+
+	if !supportsFeature(r.multipassVersion, featureClone) {
+		resp.Diagnostics.AddError("Clone not supported", unsupportedFeatureDetail(r.multipassVersion, featureClone))
+		return
+	}
+
+	source := plan.SourceInstance.ValueString()
+	name := plan.Name.ValueString()
+
+	if plan.StopSource.ValueBool() {
+		restore, err := stopForOperation(ctx, r.client, source, createTimeout, r.commandTimeout, "cloning")
+		defer func() { resp.Diagnostics.Append(restore()...) }()
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to stop source instance", err.Error())
+			return
+		}
+	} else {
+		resp.Diagnostics.Append(r.checkSourceStopped(ctx, source)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+
+	if err := r.client.CloneInstance(ctx, source, name); err != nil {
+		if errors.Is(err, multipasscli.ErrNotFound) {
+			resp.Diagnostics.AddAttributeError(path.Root("source_instance"), "Source instance not found", fmt.Sprintf("Multipass instance %q does not exist.", source))
+			return
+		}
+		resp.Diagnostics.AddError("Failed to clone instance", err.Error())
+		return
+	}
+
+	if plan.Start.IsNull() || plan.Start.ValueBool() {
+		if err := r.client.StartInstance(ctx, name); err != nil {
+			resp.Diagnostics.AddWarning("Failed to start clone", fmt.Sprintf("Instance %q was cloned but could not be started: %s", name, err))
+		}
+	}
+
+	resp.Diagnostics.Append(r.refreshState(ctx, name, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+// checkSourceStopped reports an error unless source is stopped, so a running
+// source fails with a hint at stop_source rather than the daemon's refusal.
+func (r *cloneResource) checkSourceStopped(ctx context.Context, source string) diag.Diagnostics {
+	var diags diag.Diagnostics
+	instances, err := r.client.ListInstances(ctx, true)
+	if err != nil {
+		diags.AddError("Failed to list instances", err.Error())
+		return diags
+	}
+	for _, inst := range instances {
+		if inst.Name != source {
+			continue
+		}
+		if !strings.EqualFold(inst.State, "Stopped") {
+			diags.AddAttributeError(
+				path.Root("source_instance"),
+				"Source instance not stopped",
+				fmt.Sprintf("Multipass can only clone stopped instances, and %q is %s. Stop it first or set stop_source = true.", source, strings.ToLower(inst.State)),
+			)
+		}
+		return diags
+	}
+	diags.AddAttributeError(path.Root("source_instance"), "Source instance not found", fmt.Sprintf("Multipass instance %q does not exist.", source))
+	return diags
+}
+
+func (r *cloneResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	if r.client == nil {
+		resp.Diagnostics.AddError("Client not configured", "The provider Multipass client was not configured.")
+		return
+	}
+
+	var state cloneResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ctx, done := startOperation(ctx, "multipass_clone", "read", state.Name.ValueString())
+	defer done(&resp.Diagnostics)
+
+	readTimeout, diags := state.Timeouts.Read(ctx, r.commandTimeout)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, readTimeout)
+	defer cancel()
+
+	name := state.Name.ValueString()
+	instance, err := r.client.GetInstance(ctx, name)
+	if errors.Is(err, multipasscli.ErrNotFound) {
+		tflog.Info(ctx, "Multipass clone no longer exists", map[string]any{"name": name})
+		resp.State.RemoveResource(ctx)
+		return
+	}
+	if err != nil {
+		// multipass info requires SSH, which is unavailable on stopped or
+		// booting instances; multipass list only queries the daemon.
+		tflog.Warn(ctx, "multipass info failed in Read, falling back to list", map[string]any{"name": name, "error": err.Error()})
+		instance, err = findListedInstance(ctx, r.client, name)
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to read clone", err.Error())
+			return
+		}
+	}
+
+	state.ID = types.StringValue(name)
+	resp.Diagnostics.Append(applyInstanceToClone(ctx, instance, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+}
+
+// Update only records stop_source, start, purge_on_delete and timeouts; every
+// other argument forces recreation.
+func (r *cloneResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	if r.client == nil {
+		resp.Diagnostics.AddError("Client not configured", "The provider Multipass client was not configured.")
+		return
+	}
+
+	var plan cloneResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ctx, done := startOperation(ctx, "multipass_clone", "update", plan.Name.ValueString())
+	defer done(&resp.Diagnostics)
+
+	resp.Diagnostics.Append(r.refreshState(ctx, plan.Name.ValueString(), &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *cloneResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	if r.client == nil {
+		resp.Diagnostics.AddError("Client not configured", "The provider Multipass client was not configured.")
+		return
+	}
+
+	var state cloneResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ctx, done := startOperation(ctx, "multipass_clone", "delete", state.Name.ValueString())
+	defer done(&resp.Diagnostics)
+
+	deleteTimeout, diags := state.Timeouts.Delete(ctx, r.commandTimeout)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, deleteTimeout)
+	defer cancel()
+
+	purge := r.purgeOnDelete
+	if !state.PurgeOnDelete.IsNull() && !state.PurgeOnDelete.IsUnknown() {
+		purge = state.PurgeOnDelete.ValueBool()
+	}
+	if err := r.client.DeleteInstance(ctx, state.Name.ValueString(), purge); err != nil {
+		if errors.Is(err, multipasscli.ErrNotFound) {
+			return
+		}
+		resp.Diagnostics.AddError("Failed to delete clone", err.Error())
+	}
+}
+
+// ImportState accepts `<source_instance>/<name>`. Multipass does not record
+// where an instance was cloned from, so the source has to be spelled out.
+func (r *cloneResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	source, name, ok := strings.Cut(req.ID, "/")
+	if !ok || source == "" || name == "" {
+		resp.Diagnostics.AddError("Invalid import ID", fmt.Sprintf("Expected `<source_instance>/<name>`, got %q.", req.ID))
+		return
+	}
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("source_instance"), source)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("name"), name)...)
+}
+
+// refreshState reads the clone after create or update, falling back to
+// `multipass list` when `multipass info` is not available yet.
+func (r *cloneResource) refreshState(ctx context.Context, name string, model *cloneResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+	instance, err := r.client.GetInstance(ctx, name)
+	if err != nil {
+		tflog.Warn(ctx, "multipass info failed, falling back to list", map[string]any{"name": name, "error": err.Error()})
+		instance, err = findListedInstance(ctx, r.client, name)
+		if err != nil {
+			diags.AddError("Failed to refresh clone state", err.Error())
+			return diags
+		}
+	}
+
+	model.ID = types.StringValue(name)
+	diags.Append(applyInstanceToClone(ctx, instance, model)...)
+	return diags
+}
+
+func applyInstanceToClone(ctx context.Context, instance *models.Instance, model *cloneResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	model.State = types.StringValue(instance.State)
+	model.Release = types.StringValue(instance.Release)
+	model.ImageRelease = types.StringValue(instance.ImageRelease)
+	model.LastUpdated = types.StringValue(instance.LastUpdated.UTC().Format(time.RFC3339))
+
+	model.IPv4 = types.ListNull(types.StringType)
+	if len(instance.IPv4) > 0 {
+		list, d := types.ListValueFrom(ctx, types.StringType, instance.IPv4)
+		diags.Append(d...)
+		model.IPv4 = list
+	}
+	model.IPv6 = types.ListNull(types.StringType)
+	if len(instance.IPv6) > 0 {
+		list, d := types.ListValueFrom(ctx, types.StringType, instance.IPv6)
+		diags.Append(d...)
+		model.IPv6 = list
+	}
+	return diags
+}
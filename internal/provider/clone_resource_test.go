@@ -0,0 +1,238 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-framework-timeouts/resource/timeouts"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+var nullCloneTimeouts = timeouts.Value{Object: types.ObjectNull(map[string]attr.Type{
+	"create": types.StringType,
+	"read":   types.StringType,
+	"delete": types.StringType,
+})}
+
+// cloneHost fakes a Multipass host holding the given instance states, where
+// clones start out stopped.
+func cloneHost(states map[string]string) *fakeClient {
+	return &fakeClient{
+		listInstances: func(bool) ([]models.Instance, error) {
+			var instances []models.Instance
+			for name, state := range states {
+				instances = append(instances, models.Instance{Name: name, State: state})
+			}
+			return instances, nil
+		},
+		getInstance: func(name string) (*models.Instance, error) {
+			state, ok := states[name]
+			if !ok {
+				return nil, multipasscli.ErrNotFound
+			}
+			return &models.Instance{Name: name, State: state, IPv4: []string{"10.0.0.2"}, Release: "Ubuntu 24.04 LTS"}, nil
+		},
+		cloneInstance: func(source, name string) error {
+			if _, ok := states[source]; !ok {
+				return multipasscli.ErrNotFound
+			}
+			states[name] = "Stopped"
+			return nil
+		},
+		startInstance: func(name string) error { states[name] = "Running"; return nil },
+		stopInstance:  func(name string) error { states[name] = "Stopped"; return nil },
+	}
+}
+
+func createClone(t *testing.T, client *fakeClient, plan cloneResourceModel) (cloneResourceModel, []string, diag.Diagnostics) {
+	t.Helper()
+	ctx := context.Background()
+
+	r := &cloneResource{client: client, commandTimeout: time.Minute}
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	plan.Timeouts = nullCloneTimeouts
+	tfPlan := tfsdk.Plan{Schema: schemaResp.Schema}
+	if diags := tfPlan.Set(ctx, &plan); diags.HasError() {
+		t.Fatalf("set plan: %v", diags)
+	}
+	resp := resource.CreateResponse{State: tfsdk.State{Schema: schemaResp.Schema, Raw: tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil)}}
+	r.Create(ctx, resource.CreateRequest{Plan: tfPlan}, &resp)
+	var got cloneResourceModel
+	if !resp.State.Raw.IsNull() {
+		resp.State.Get(ctx, &got)
+	}
+	return got, client.recorded(), resp.Diagnostics
+}
+
+func TestCloneCreate(t *testing.T) {
+	instanceWaitInterval = time.Millisecond
+
+	plan := cloneResourceModel{
+		ID:             types.StringUnknown(),
+		Name:           types.StringValue("worker-1"),
+		SourceInstance: types.StringValue("golden"),
+		StopSource:     types.BoolNull(),
+		Start:          types.BoolNull(),
+		PurgeOnDelete:  types.BoolNull(),
+		IPv4:           types.ListUnknown(types.StringType),
+		IPv6:           types.ListUnknown(types.StringType),
+		State:          types.StringUnknown(),
+		Release:        types.StringUnknown(),
+		ImageRelease:   types.StringUnknown(),
+		LastUpdated:    types.StringUnknown(),
+	}
+
+	t.Run("stopped source", func(t *testing.T) {
+		got, calls, diags := createClone(t, cloneHost(map[string]string{"golden": "Stopped"}), plan)
+		if diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		want := []string{"list", "clone golden worker-1", "start worker-1", "info worker-1"}
+		if diff := cmp.Diff(want, calls); diff != "" {
+			t.Fatalf("unexpected calls (-want +got): %s", diff)
+		}
+		if got.ID.ValueString() != "worker-1" || got.State.ValueString() != "Running" || got.Release.ValueString() != "Ubuntu 24.04 LTS" {
+			t.Fatalf("unexpected state: id=%s state=%s release=%s", got.ID, got.State, got.Release)
+		}
+	})
+
+	t.Run("start disabled", func(t *testing.T) {
+		stopped := plan
+		stopped.Start = types.BoolValue(false)
+		got, calls, diags := createClone(t, cloneHost(map[string]string{"golden": "Stopped"}), stopped)
+		if diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		want := []string{"list", "clone golden worker-1", "info worker-1"}
+		if diff := cmp.Diff(want, calls); diff != "" {
+			t.Fatalf("unexpected calls (-want +got): %s", diff)
+		}
+		if got.State.ValueString() != "Stopped" {
+			t.Fatalf("expected the clone to stay stopped, got %s", got.State)
+		}
+	})
+
+	t.Run("running source", func(t *testing.T) {
+		_, calls, diags := createClone(t, cloneHost(map[string]string{"golden": "Running"}), plan)
+		if !diags.HasError() || diags[0].Summary() != "Source instance not stopped" {
+			t.Fatalf("expected a source state error, got %v", diags)
+		}
+		if diff := cmp.Diff([]string{"list"}, calls); diff != "" {
+			t.Fatalf("unexpected calls (-want +got): %s", diff)
+		}
+	})
+
+	t.Run("missing source", func(t *testing.T) {
+		_, _, diags := createClone(t, cloneHost(map[string]string{}), plan)
+		if !diags.HasError() || diags[0].Summary() != "Source instance not found" {
+			t.Fatalf("expected a missing source error, got %v", diags)
+		}
+	})
+
+	t.Run("stop source", func(t *testing.T) {
+		stopping := plan
+		stopping.StopSource = types.BoolValue(true)
+		_, calls, diags := createClone(t, cloneHost(map[string]string{"golden": "Running"}), stopping)
+		if diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		want := []string{"list", "stop golden force=false", "list", "clone golden worker-1", "start worker-1", "info worker-1", "start golden"}
+		if diff := cmp.Diff(want, calls); diff != "" {
+			t.Fatalf("unexpected calls (-want +got): %s", diff)
+		}
+	})
+}
+
+func TestCloneDelete(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	for _, tc := range []struct {
+		name     string
+		purge    types.Bool
+		fallback bool
+		want     string
+	}{
+		{name: "provider default", purge: types.BoolNull(), fallback: true, want: "delete worker-1 purge=true"},
+		{name: "resource override", purge: types.BoolValue(false), fallback: true, want: "delete worker-1 purge=false"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// DeleteInstance passes on the wrapped error from runSimple.
+			client := &fakeClient{deleteInstance: func(name string) error {
+				return fmt.Errorf("%w: instance \"%s\" does not exist", multipasscli.ErrNotFound, name)
+			}}
+			r := &cloneResource{client: client, purgeOnDelete: tc.fallback, commandTimeout: time.Minute}
+			var schemaResp resource.SchemaResponse
+			r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+			state := cloneResourceModel{
+				ID:             types.StringValue("worker-1"),
+				Name:           types.StringValue("worker-1"),
+				SourceInstance: types.StringValue("golden"),
+				PurgeOnDelete:  tc.purge,
+				Timeouts:       nullCloneTimeouts,
+				IPv4:           types.ListNull(types.StringType),
+				IPv6:           types.ListNull(types.StringType),
+			}
+			tfState := tfsdk.State{Schema: schemaResp.Schema}
+			if diags := tfState.Set(ctx, &state); diags.HasError() {
+				t.Fatalf("set state: %v", diags)
+			}
+			resp := resource.DeleteResponse{State: tfState}
+			r.Delete(ctx, resource.DeleteRequest{State: tfState}, &resp)
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("an already deleted clone should not fail: %v", resp.Diagnostics)
+			}
+			if diff := cmp.Diff([]string{tc.want}, client.recorded()); diff != "" {
+				t.Fatalf("unexpected calls (-want +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestCloneImportState(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	r := &cloneResource{}
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	importClone := func(id string) (cloneResourceModel, diag.Diagnostics) {
+		resp := resource.ImportStateResponse{State: tfsdk.State{Schema: schemaResp.Schema}}
+		resp.State.Raw = tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil)
+		r.ImportState(ctx, resource.ImportStateRequest{ID: id}, &resp)
+		var got cloneResourceModel
+		if !resp.Diagnostics.HasError() {
+			resp.State.Get(ctx, &got)
+		}
+		return got, resp.Diagnostics
+	}
+
+	got, diags := importClone("golden/worker-1")
+	if diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if got.SourceInstance.ValueString() != "golden" || got.Name.ValueString() != "worker-1" {
+		t.Fatalf("unexpected identity: source=%s name=%s", got.SourceInstance, got.Name)
+	}
+
+	for _, id := range []string{"worker-1", "golden/", "/worker-1"} {
+		if _, diags := importClone(id); !diags.HasError() {
+			t.Errorf("expected %q to be rejected", id)
+		}
+	}
+}
@@ -3,21 +3,49 @@ package provider
 import (
 	"time"
 
+	"github.com/hashicorp/go-version"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 
 	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
 )
 
 type providerConfigModel struct {
-	MultipassPath  types.String `tfsdk:"multipass_path"`
-	CommandTimeout types.Int64  `tfsdk:"command_timeout"`
-	DefaultImage   types.String `tfsdk:"default_image"`
+	MultipassPath    types.String `tfsdk:"multipass_path"`
+	CommandTimeout   types.Int64  `tfsdk:"command_timeout"`
+	CacheTTL         types.Int64  `tfsdk:"cache_ttl"`
+	MaxParallel      types.Int64  `tfsdk:"max_parallel_commands"`
+	TransferStrategy types.String `tfsdk:"transfer_strategy"`
+	Passphrase       types.String `tfsdk:"passphrase"`
+	PurgeOnDelete    types.Bool   `tfsdk:"purge_on_delete"`
+	DeferBinaryCheck types.Bool   `tfsdk:"defer_binary_check"`
+	DefaultImage     types.String `tfsdk:"default_image"`
+	StrictAliases    types.Bool   `tfsdk:"strict_alias_instances"`
+	RequiredVersion  types.String `tfsdk:"required_version"`
+	SkipVersionCheck types.Bool   `tfsdk:"skip_version_check"`
+	WaitForDaemon    types.Int64  `tfsdk:"wait_for_daemon"`
+	DryRun           types.Bool   `tfsdk:"dry_run"`
+	StateRetries     types.Int64  `tfsdk:"transitional_state_retries"`
+	StateInterval    types.Int64  `tfsdk:"transitional_state_interval"`
+	SilenceDriver    types.Bool   `tfsdk:"suppress_driver_warnings"`
 }
 
 type providerConfig struct {
-	BinaryPath     string
-	CommandTimeout int
-	DefaultImage   string
+	BinaryPath       string
+	CommandTimeout   int
+	CacheTTL         *int
+	MaxParallel      int
+	TransferStrategy string
+	DefaultImage     string
+	StrictAliases    bool
+	SkipVersionCheck bool
+	Passphrase       string
+	PurgeOnDelete    bool
+	DeferBinaryCheck bool
+	WaitForDaemon    int
+	DryRun           bool
+	StateRetries     int
+	StateInterval    int
+	SilenceDriver    bool
 }
 
 type providerData struct {
@@ -25,4 +53,23 @@ type providerData struct {
 	defaultImage   string
 	hostOS         string
 	commandTimeout time.Duration
+	strictAliases  bool
+	// purgeOnDelete is the default for multipass_instance.purge_on_delete.
+	purgeOnDelete bool
+	// transferStrategy is transferStrategyDirect or transferStrategyTar.
+	transferStrategy string
+	// multipassVersion is the detected CLI version, or nil when it could
+	// not be detected or parsed.
+	multipassVersion *version.Version
+	// primaryClaims is shared by the resources that set
+	// client.primary-name.
+	primaryClaims *primaryClaims
+	// stateRetries and stateInterval bound how long multipass_instance
+	// reads wait for a transitional state to settle.
+	stateRetries  int
+	stateInterval time.Duration
+	// driver is the virtualization driver read during configuration.
+	driver driverInfo
+	// silenceDriver is suppress_driver_warnings.
+	silenceDriver bool
 }
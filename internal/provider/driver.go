@@ -0,0 +1,95 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+// Drivers, as reported by local.driver, whose limits the provider warns
+// about.
+const (
+	driverHyperV = "hyperv"
+	driverLXD    = "lxd"
+)
+
+// driverInfo describes the virtualization driver multipassd uses, read
+// during provider configuration. name is empty when it is unknown.
+type driverInfo struct {
+	name string
+	// privilegedMounts is local.privileged-mounts. It is only read for
+	// hyperv, where mounts depend on it.
+	privilegedMounts bool
+}
+
+// detectDriver reads local.driver, and local.privileged-mounts on hyperv.
+// The driver only feeds warnings and the settings data source, so a
+// failure leaves it unknown instead of failing configuration.
+func detectDriver(ctx context.Context, client multipasscli.Client) driverInfo {
+	name, err := client.GetSetting(ctx, "local.driver")
+	if err != nil {
+		tflog.Debug(ctx, "Unable to read the Multipass driver", map[string]any{"error": err.Error()})
+		return driverInfo{}
+	}
+	info := driverInfo{name: name}
+	if name == driverHyperV {
+		privileged, err := client.GetSetting(ctx, "local.privileged-mounts")
+		if err != nil {
+			tflog.Debug(ctx, "Unable to read local.privileged-mounts", map[string]any{"error": err.Error()})
+		}
+		info.privilegedMounts = strings.EqualFold(privileged, "true")
+	}
+	tflog.Info(ctx, "Detected Multipass driver", map[string]any{"driver": name})
+	return info
+}
+
+// warnDriverLimits adds plan warnings for instance settings that the
+// driver does not support: mounts on hyperv without privileged mounts, and
+// destroy_behavior = "suspend" on lxd. Only new or changed values are
+// reported. The provider leaves the driver unknown when
+// suppress_driver_warnings is set.
+func (r *instanceResource) warnDriverLimits(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	switch r.driver.name {
+	case driverHyperV:
+		if r.driver.privilegedMounts {
+			return
+		}
+		var planned, prior types.Set
+		resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("mounts"), &planned)...)
+		if !req.State.Raw.IsNull() {
+			resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root("mounts"), &prior)...)
+		}
+		if resp.Diagnostics.HasError() || planned.IsNull() || len(planned.Elements()) == 0 || planned.Equal(prior) {
+			return
+		}
+		resp.Diagnostics.AddAttributeWarning(
+			path.Root("mounts"),
+			"Mounts need privileged mounts on hyperv",
+			"The Multipass driver is hyperv and local.privileged-mounts is not true, so mounting will fail. "+
+				"Run `multipass set local.privileged-mounts=true`, or manage it with a multipass_setting resource. "+
+				"Set suppress_driver_warnings in the provider to silence this warning.",
+		)
+	case driverLXD:
+		var planned, prior types.String
+		resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("destroy_behavior"), &planned)...)
+		if !req.State.Raw.IsNull() {
+			resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root("destroy_behavior"), &prior)...)
+		}
+		if resp.Diagnostics.HasError() || planned.ValueString() != destroyBehaviorSuspend || planned.Equal(prior) {
+			return
+		}
+		resp.Diagnostics.AddAttributeWarning(
+			path.Root("destroy_behavior"),
+			"Suspend not supported by the lxd driver",
+			fmt.Sprintf("The Multipass driver is lxd, which cannot suspend instances, so destroy_behavior = %q will fail on destroy. "+
+				"Use \"abandon\" to keep the instance instead. Set suppress_driver_warnings in the provider to silence this warning.", destroyBehaviorSuspend),
+		)
+	}
+}
@@ -0,0 +1,123 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+func TestDetectDriver(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	settings := func(values map[string]string) *fakeClient {
+		return &fakeClient{getSetting: func(key string) (string, error) {
+			if v, ok := values[key]; ok {
+				return v, nil
+			}
+			return "", multipasscli.ErrNotFound
+		}}
+	}
+
+	if got := detectDriver(ctx, settings(map[string]string{"local.driver": "qemu", "local.privileged-mounts": "true"})); got != (driverInfo{name: "qemu"}) {
+		t.Fatalf("unexpected driver for qemu: %+v", got)
+	}
+	if got := detectDriver(ctx, settings(map[string]string{"local.driver": "hyperv", "local.privileged-mounts": "true"})); got != (driverInfo{name: "hyperv", privilegedMounts: true}) {
+		t.Fatalf("unexpected driver for hyperv: %+v", got)
+	}
+	if got := detectDriver(ctx, settings(nil)); got != (driverInfo{}) {
+		t.Fatalf("expected an unknown driver when local.driver is unreadable, got %+v", got)
+	}
+}
+
+func TestInstanceWarnDriverLimits(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	objType := instanceState(t, &instanceResource{}, nil).Raw.Type().(tftypes.Object)
+	mountsType := objType.AttributeTypes["mounts"].(tftypes.Set)
+	mounts := tftypes.NewValue(mountsType, []tftypes.Value{
+		tftypes.NewValue(mountsType.ElementType, map[string]tftypes.Value{
+			"host_path":     tftypes.NewValue(tftypes.String, "/src"),
+			"instance_path": tftypes.NewValue(tftypes.String, "/mnt/src"),
+			"read_only":     tftypes.NewValue(tftypes.Bool, nil),
+			"uid_mappings":  tftypes.NewValue(tftypes.List{ElementType: tftypes.String}, nil),
+			"gid_mappings":  tftypes.NewValue(tftypes.List{ElementType: tftypes.String}, nil),
+		}),
+	})
+	suspend := tftypes.NewValue(tftypes.String, destroyBehaviorSuspend)
+
+	for _, tc := range []struct {
+		name   string
+		driver driverInfo
+		state  map[string]tftypes.Value // nil plans a create
+		plan   map[string]tftypes.Value
+		want   string
+	}{
+		{
+			name:   "hyperv mounts",
+			driver: driverInfo{name: driverHyperV},
+			plan:   map[string]tftypes.Value{"mounts": mounts},
+			want:   "Mounts need privileged mounts on hyperv",
+		},
+		{
+			name:   "hyperv privileged mounts",
+			driver: driverInfo{name: driverHyperV, privilegedMounts: true},
+			plan:   map[string]tftypes.Value{"mounts": mounts},
+		},
+		{
+			name:   "hyperv unchanged mounts",
+			driver: driverInfo{name: driverHyperV},
+			state:  map[string]tftypes.Value{"mounts": mounts},
+			plan:   map[string]tftypes.Value{"mounts": mounts},
+		},
+		{
+			name:   "lxd suspend",
+			driver: driverInfo{name: driverLXD},
+			plan:   map[string]tftypes.Value{"destroy_behavior": suspend},
+			want:   "Suspend not supported by the lxd driver",
+		},
+		{
+			name:   "lxd mounts",
+			driver: driverInfo{name: driverLXD},
+			plan:   map[string]tftypes.Value{"mounts": mounts},
+		},
+		{
+			name:   "qemu suspend",
+			driver: driverInfo{name: "qemu"},
+			plan:   map[string]tftypes.Value{"destroy_behavior": suspend},
+		},
+		{
+			name: "unknown driver",
+			plan: map[string]tftypes.Value{"mounts": mounts, "destroy_behavior": suspend},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &instanceResource{driver: tc.driver}
+			planState := instanceState(t, r, tc.plan)
+			plan := tfsdk.Plan{Schema: planState.Schema, Raw: planState.Raw}
+			state := tfsdk.State{Schema: planState.Schema, Raw: tftypes.NewValue(objType, nil)}
+			if tc.state != nil {
+				state = instanceState(t, r, tc.state)
+			}
+
+			resp := resource.ModifyPlanResponse{Plan: plan}
+			r.warnDriverLimits(ctx, resource.ModifyPlanRequest{State: state, Plan: plan}, &resp)
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("unexpected errors: %v", resp.Diagnostics)
+			}
+			warnings := resp.Diagnostics.Warnings()
+			switch {
+			case tc.want == "" && len(warnings) != 0:
+				t.Fatalf("expected no warnings, got %v", warnings)
+			case tc.want != "" && (len(warnings) != 1 || warnings[0].Summary() != tc.want):
+				t.Fatalf("expected warning %q, got %v", tc.want, warnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/listvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+var (
+	_ datasource.DataSource              = (*execDataSource)(nil)
+	_ datasource.DataSourceWithConfigure = (*execDataSource)(nil)
+)
+
+// NewExecDataSource returns the exec data source.
+func NewExecDataSource() datasource.DataSource {
+	return &execDataSource{}
+}
+
+type execDataSource struct {
+	client multipasscli.Client
+}
+
+type execDataSourceModel struct {
+	Instance          types.String `tfsdk:"instance"`
+	Command           types.List   `tfsdk:"command"`
+	Script            types.String `tfsdk:"script"`
+	WorkingDirectory  types.String `tfsdk:"working_directory"`
+	User              types.String `tfsdk:"user"`
+	Environment       types.Map    `tfsdk:"environment"`
+	FailOnNonzeroExit types.Bool   `tfsdk:"fail_on_nonzero_exit"`
+	TrimOutput        types.Bool   `tfsdk:"trim_output"`
+	MaxOutputSize     types.Int64  `tfsdk:"max_output_size"`
+	Stdout            types.String `tfsdk:"stdout"`
+	Stderr            types.String `tfsdk:"stderr"`
+	ExitCode          types.Int64  `tfsdk:"exit_code"`
+}
+
+// envNameRegex matches the environment variable names accepted by env(1)
+// without ambiguity.
+var envNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func (d *execDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_exec"
+}
+
+func (d *execDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	oneOf := []path.Expression{
+		path.MatchRelative().AtParent().AtName("command"),
+		path.MatchRelative().AtParent().AtName("script"),
+	}
+
+	resp.Schema = schema.Schema{
+		Description: "Runs a read-only command inside a Multipass instance and captures its output. The command runs on every refresh, so it should not change the instance.",
+		Attributes: map[string]schema.Attribute{
+			"instance": schema.StringAttribute{
+				Required:    true,
+				Description: "Instance to run the command in. It must be running.",
+			},
+			"command": schema.ListAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "Command and arguments, run without a shell. Exactly one of `command` or `script` is required.",
+				Validators: []validator.List{
+					listvalidator.ExactlyOneOf(oneOf...),
+					listvalidator.SizeAtLeast(1),
+				},
+			},
+			"script": schema.StringAttribute{
+				Optional:    true,
+				Description: "Shell script run with `sh -c`. Exactly one of `command` or `script` is required.",
+				Validators: []validator.String{
+					stringvalidator.ExactlyOneOf(oneOf...),
+				},
+			},
+			"working_directory": schema.StringAttribute{
+				Optional:    true,
+				Description: "Directory inside the instance to run the command in.",
+			},
+			"user": schema.StringAttribute{
+				Optional:    true,
+				Description: "Run the command as this user through `sudo -n -u`. Defaults to the instance's default user.",
+			},
+			"environment": schema.MapAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "Environment variables set for the command.",
+			},
+			"fail_on_nonzero_exit": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Fail the read when the command exits non-zero. When false, the exit status is only reported in exit_code (default: true).",
+			},
+			"trim_output": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Trim leading and trailing whitespace from stdout and stderr (default: true).",
+			},
+			"max_output_size": schema.Int64Attribute{
+				Optional:    true,
+				Description: "Largest stdout or stderr size in bytes; larger output fails the read. Defaults to 65536.",
+				Validators: []validator.Int64{
+					int64validator.AtLeast(0),
+				},
+			},
+			"stdout": schema.StringAttribute{
+				Computed:    true,
+				Description: "Standard output of the command.",
+			},
+			"stderr": schema.StringAttribute{
+				Computed:    true,
+				Description: "Standard error of the command.",
+			},
+			"exit_code": schema.Int64Attribute{
+				Computed:    true,
+				Description: "Exit status of the command.",
+			},
+		},
+	}
+}
+
+func (d *execDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, _ *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	data := req.ProviderData.(providerData)
+	d.client = data.client
+}
+
+func (d *execDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	if d.client == nil {
+		resp.Diagnostics.AddError("Client not configured", "Multipass client is nil.")
+		return
+	}
+
+	var config execDataSourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	var command []string
+	if !config.Command.IsNull() {
+		resp.Diagnostics.Append(config.Command.ElementsAs(ctx, &command, false)...)
+	} else {
+		command = []string{"sh", "-c", config.Script.ValueString()}
+	}
+	env := map[string]string{}
+	if !config.Environment.IsNull() {
+		resp.Diagnostics.Append(config.Environment.ElementsAs(ctx, &env, false)...)
+	}
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	for name := range env {
+		if !envNameRegex.MatchString(name) {
+			resp.Diagnostics.AddAttributeError(path.Root("environment"), "Invalid environment variable name", fmt.Sprintf("%q is not a valid environment variable name.", name))
+			return
+		}
+	}
+	command = wrapExecCommand(command, env, valueOrEmpty(config.User))
+
+	instance, diags := runningExecInstance(ctx, d.client, config.Instance.ValueString())
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	result, err := d.client.ExecOutput(ctx, instance.Name, multipasscli.ExecOptions{
+		Command:          command,
+		WorkingDirectory: valueOrEmpty(config.WorkingDirectory),
+		ReadOnly:         true,
+	})
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to run command", err.Error())
+		return
+	}
+
+	limit := valueOrDefaultInt(config.MaxOutputSize, defaultMaxInlineSize)
+	for _, out := range []struct {
+		name string
+		data []byte
+	}{{"stdout", result.Stdout}, {"stderr", result.Stderr}} {
+		if len(out.data) > limit {
+			resp.Diagnostics.AddError("Command output too large", fmt.Sprintf("The command wrote %d bytes to %s inside %q, more than max_output_size (%d).", len(out.data), out.name, instance.Name, limit))
+			return
+		}
+	}
+
+	trim := config.TrimOutput.IsNull() || config.TrimOutput.ValueBool()
+	stdout := execOutputString(result.Stdout, trim)
+	stderr := execOutputString(result.Stderr, trim)
+
+	failOnNonzero := config.FailOnNonzeroExit.IsNull() || config.FailOnNonzeroExit.ValueBool()
+	if result.ExitCode != 0 && failOnNonzero {
+		resp.Diagnostics.AddError("Command failed", fmt.Sprintf("The command inside %q exited with status %d. Set fail_on_nonzero_exit = false to tolerate this.\n\nstderr: %s", instance.Name, result.ExitCode, strings.TrimSpace(stderr)))
+		return
+	}
+
+	config.Stdout = types.StringValue(stdout)
+	config.Stderr = types.StringValue(stderr)
+	config.ExitCode = types.Int64Value(int64(result.ExitCode))
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &config)...)
+}
+
+// runningExecInstance looks up the instance a command runs in. Multipass
+// reports a missing or stopped instance through the same exit status as the
+// command, so callers check it up front.
+func runningExecInstance(ctx context.Context, client multipasscli.Client, name string) (*models.Instance, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	instance, err := client.GetInstance(ctx, name)
+	if errors.Is(err, multipasscli.ErrNotFound) {
+		diags.AddError("Instance not found", fmt.Sprintf("Instance %q does not exist.", name))
+		return nil, diags
+	}
+	if err != nil {
+		diags.AddError("Failed to read instance", err.Error())
+		return nil, diags
+	}
+	if !strings.EqualFold(instance.State, "Running") {
+		diags.AddError("Instance not running", fmt.Sprintf("Instance %q is %s; commands can only run in a running instance.", instance.Name, instance.State))
+		return nil, diags
+	}
+	return instance, diags
+}
+
+// wrapExecCommand prefixes command with env(1) for the environment and
+// sudo(8) for the user. Variables are set after sudo, which would otherwise
+// reset them.
+func wrapExecCommand(command []string, env map[string]string, user string) []string {
+	var prefix []string
+	if user != "" {
+		prefix = append(prefix, "sudo", "-n", "-u", user, "--")
+	}
+	if len(env) > 0 {
+		names := make([]string, 0, len(env))
+		for name := range env {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		prefix = append(prefix, "env")
+		for _, name := range names {
+			prefix = append(prefix, name+"="+env[name])
+		}
+	}
+	return append(prefix, command...)
+}
+
+// execOutputString converts captured output to a Terraform string, which
+// must be valid UTF-8.
+func execOutputString(data []byte, trim bool) string {
+	s := strings.ToValidUTF8(string(data), "�")
+	if trim {
+		s = strings.TrimSpace(s)
+	}
+	return s
+}
@@ -0,0 +1,179 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+// readExec runs execDataSource.Read on instance "vm" with the given
+// attributes set and returns the response.
+func readExec(t *testing.T, client *fakeClient, attrs map[string]tftypes.Value) datasource.ReadResponse {
+	t.Helper()
+	ctx := context.Background()
+
+	d := &execDataSource{client: client}
+	var schemaResp datasource.SchemaResponse
+	d.Schema(ctx, datasource.SchemaRequest{}, &schemaResp)
+
+	configType := schemaResp.Schema.Type().TerraformType(ctx).(tftypes.Object)
+	values := map[string]tftypes.Value{}
+	for name, typ := range configType.AttributeTypes {
+		values[name] = tftypes.NewValue(typ, nil)
+	}
+	values["instance"] = tftypes.NewValue(tftypes.String, "vm")
+	for name, v := range attrs {
+		values[name] = v
+	}
+	config := tfsdk.Config{Schema: schemaResp.Schema, Raw: tftypes.NewValue(configType, values)}
+
+	resp := datasource.ReadResponse{State: tfsdk.State{
+		Schema: schemaResp.Schema,
+		Raw:    tftypes.NewValue(configType, nil),
+	}}
+	d.Read(ctx, datasource.ReadRequest{Config: config}, &resp)
+	return resp
+}
+
+func execResultClient(result multipasscli.ExecResult) *fakeClient {
+	return &fakeClient{
+		execOutput: func(string, multipasscli.ExecOptions) (multipasscli.ExecResult, error) {
+			return result, nil
+		},
+	}
+}
+
+func TestExecDataSourceRead(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	script := tftypes.NewValue(tftypes.String, "uname -r")
+
+	t.Run("script with user and environment", func(t *testing.T) {
+		var opts multipasscli.ExecOptions
+		client := &fakeClient{
+			execOutput: func(_ string, o multipasscli.ExecOptions) (multipasscli.ExecResult, error) {
+				opts = o
+				return multipasscli.ExecResult{Stdout: []byte("6.8.0-45-generic\n")}, nil
+			},
+		}
+		resp := readExec(t, client, map[string]tftypes.Value{
+			"script":            script,
+			"user":              tftypes.NewValue(tftypes.String, "app"),
+			"working_directory": tftypes.NewValue(tftypes.String, "/srv/app"),
+			"environment": tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, map[string]tftypes.Value{
+				"LANG": tftypes.NewValue(tftypes.String, "C"),
+				"A":    tftypes.NewValue(tftypes.String, "1"),
+			}),
+		})
+		if resp.Diagnostics.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+		}
+		want := multipasscli.ExecOptions{
+			Command:          []string{"sudo", "-n", "-u", "app", "--", "env", "A=1", "LANG=C", "sh", "-c", "uname -r"},
+			WorkingDirectory: "/srv/app",
+			ReadOnly:         true,
+		}
+		if diff := cmp.Diff(want, opts); diff != "" {
+			t.Fatalf("unexpected exec options (-want +got):\n%s", diff)
+		}
+		var state execDataSourceModel
+		resp.State.Get(ctx, &state)
+		if state.Stdout.ValueString() != "6.8.0-45-generic" || state.Stderr.ValueString() != "" || state.ExitCode.ValueInt64() != 0 {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+
+	t.Run("command list untrimmed", func(t *testing.T) {
+		client := execResultClient(multipasscli.ExecResult{Stdout: []byte("active\n")})
+		resp := readExec(t, client, map[string]tftypes.Value{
+			"command": tftypes.NewValue(tftypes.List{ElementType: tftypes.String}, []tftypes.Value{
+				tftypes.NewValue(tftypes.String, "systemctl"),
+				tftypes.NewValue(tftypes.String, "is-active"),
+				tftypes.NewValue(tftypes.String, "nginx"),
+			}),
+			"trim_output": tftypes.NewValue(tftypes.Bool, false),
+		})
+		if resp.Diagnostics.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+		}
+		var state execDataSourceModel
+		resp.State.Get(ctx, &state)
+		if state.Stdout.ValueString() != "active\n" {
+			t.Fatalf("stdout = %q", state.Stdout.ValueString())
+		}
+		if diff := cmp.Diff([]string{"info vm", "exec-output vm systemctl is-active nginx"}, client.recorded()); diff != "" {
+			t.Fatalf("unexpected calls (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("nonzero exit fails by default", func(t *testing.T) {
+		client := execResultClient(multipasscli.ExecResult{Stderr: []byte("inactive\n"), ExitCode: 3})
+		resp := readExec(t, client, map[string]tftypes.Value{"script": script})
+		if !resp.Diagnostics.HasError() || !strings.Contains(resp.Diagnostics[0].Detail(), "status 3") {
+			t.Fatalf("expected a command failure, got %v", resp.Diagnostics)
+		}
+	})
+
+	t.Run("nonzero exit tolerated", func(t *testing.T) {
+		client := execResultClient(multipasscli.ExecResult{Stderr: []byte("inactive\n"), ExitCode: 3})
+		resp := readExec(t, client, map[string]tftypes.Value{
+			"script":               script,
+			"fail_on_nonzero_exit": tftypes.NewValue(tftypes.Bool, false),
+		})
+		if resp.Diagnostics.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+		}
+		var state execDataSourceModel
+		resp.State.Get(ctx, &state)
+		if state.ExitCode.ValueInt64() != 3 || state.Stderr.ValueString() != "inactive" {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+
+	t.Run("output too large", func(t *testing.T) {
+		client := execResultClient(multipasscli.ExecResult{Stdout: []byte(strings.Repeat("x", 11))})
+		resp := readExec(t, client, map[string]tftypes.Value{
+			"script":          script,
+			"max_output_size": tftypes.NewValue(tftypes.Number, 10),
+		})
+		if !resp.Diagnostics.HasError() || resp.Diagnostics[0].Summary() != "Command output too large" {
+			t.Fatalf("expected an output size error, got %v", resp.Diagnostics)
+		}
+	})
+
+	t.Run("instance stopped", func(t *testing.T) {
+		client := &fakeClient{
+			getInstance: func(name string) (*models.Instance, error) {
+				return &models.Instance{Name: name, State: "Stopped"}, nil
+			},
+		}
+		resp := readExec(t, client, map[string]tftypes.Value{"script": script})
+		if !resp.Diagnostics.HasError() || resp.Diagnostics[0].Summary() != "Instance not running" {
+			t.Fatalf("expected an instance state error, got %v", resp.Diagnostics)
+		}
+		if diff := cmp.Diff([]string{"info vm"}, client.recorded()); diff != "" {
+			t.Fatalf("unexpected calls (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("invalid environment name", func(t *testing.T) {
+		resp := readExec(t, &fakeClient{}, map[string]tftypes.Value{
+			"script": script,
+			"environment": tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, map[string]tftypes.Value{
+				"BAD-NAME": tftypes.NewValue(tftypes.String, "1"),
+			}),
+		})
+		if !resp.Diagnostics.HasError() {
+			t.Fatal("expected an error for an invalid environment variable name")
+		}
+	})
+}
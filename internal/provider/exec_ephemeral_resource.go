@@ -0,0 +1,213 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework-validators/listvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+var (
+	_ ephemeral.EphemeralResource              = (*execEphemeralResource)(nil)
+	_ ephemeral.EphemeralResourceWithConfigure = (*execEphemeralResource)(nil)
+	_ ephemeral.EphemeralResourceWithClose     = (*execEphemeralResource)(nil)
+)
+
+// execCleanupKey is the private data key Open stores the close_command
+// under for Close.
+const execCleanupKey = "cleanup"
+
+// NewExecEphemeralResource returns the ephemeral exec resource.
+func NewExecEphemeralResource() ephemeral.EphemeralResource {
+	return &execEphemeralResource{}
+}
+
+type execEphemeralResource struct {
+	client multipasscli.Client
+}
+
+type execEphemeralResourceModel struct {
+	Instance     types.String `tfsdk:"instance"`
+	Command      types.List   `tfsdk:"command"`
+	Script       types.String `tfsdk:"script"`
+	CloseCommand types.List   `tfsdk:"close_command"`
+	TrimOutput   types.Bool   `tfsdk:"trim_output"`
+	Stdout       types.String `tfsdk:"stdout"`
+}
+
+// execCleanup is the close_command carried from Open to Close in private
+// data, since Close does not see the configuration.
+type execCleanup struct {
+	Instance string   `json:"instance"`
+	Command  []string `json:"command"`
+}
+
+func (e *execEphemeralResource) Metadata(_ context.Context, req ephemeral.MetadataRequest, resp *ephemeral.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_exec"
+}
+
+func (e *execEphemeralResource) Schema(_ context.Context, _ ephemeral.SchemaRequest, resp *ephemeral.SchemaResponse) {
+	oneOf := []path.Expression{
+		path.MatchRelative().AtParent().AtName("command"),
+		path.MatchRelative().AtParent().AtName("script"),
+	}
+
+	resp.Schema = schema.Schema{
+		Description: "Runs a command inside a Multipass instance and exposes its output without storing it in plan or state, e.g. to read a generated password. Requires Terraform 1.10 or later.",
+		Attributes: map[string]schema.Attribute{
+			"instance": schema.StringAttribute{
+				Required:    true,
+				Description: "Instance to run the command in. It must be running.",
+			},
+			"command": schema.ListAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "Command and arguments, run without a shell. Exactly one of `command` or `script` is required.",
+				Validators: []validator.List{
+					listvalidator.ExactlyOneOf(oneOf...),
+					listvalidator.SizeAtLeast(1),
+				},
+			},
+			"script": schema.StringAttribute{
+				Optional:    true,
+				Description: "Shell script run with `sh -c`. Exactly one of `command` or `script` is required.",
+				Validators: []validator.String{
+					stringvalidator.ExactlyOneOf(oneOf...),
+				},
+			},
+			"close_command": schema.ListAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "Command and arguments run without a shell when Terraform is done with the value, e.g. to remove a one-time credential.",
+				Validators: []validator.List{
+					listvalidator.SizeAtLeast(1),
+				},
+			},
+			"trim_output": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Trim leading and trailing whitespace from stdout (default: true).",
+			},
+			"stdout": schema.StringAttribute{
+				Computed:    true,
+				Sensitive:   true,
+				Description: "Standard output of the command.",
+			},
+		},
+	}
+}
+
+func (e *execEphemeralResource) Configure(_ context.Context, req ephemeral.ConfigureRequest, _ *ephemeral.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	data := req.ProviderData.(providerData)
+	e.client = data.client
+}
+
+func (e *execEphemeralResource) Open(ctx context.Context, req ephemeral.OpenRequest, resp *ephemeral.OpenResponse) {
+	if e.client == nil {
+		resp.Diagnostics.AddError("Client not configured", "Multipass client is nil.")
+		return
+	}
+
+	var config execEphemeralResourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	var command []string
+	if !config.Command.IsNull() {
+		resp.Diagnostics.Append(config.Command.ElementsAs(ctx, &command, false)...)
+	} else {
+		command = []string{"sh", "-c", config.Script.ValueString()}
+	}
+	var closeCommand []string
+	if !config.CloseCommand.IsNull() {
+		resp.Diagnostics.Append(config.CloseCommand.ElementsAs(ctx, &closeCommand, false)...)
+	}
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	instance, diags := runningExecInstance(ctx, e.client, config.Instance.ValueString())
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	out, err := e.client.ExecCapture(ctx, instance.Name, command)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to run command", redactExecError(err))
+		return
+	}
+
+	if len(closeCommand) > 0 && resp.Private != nil {
+		data, err := json.Marshal(execCleanup{Instance: instance.Name, Command: closeCommand})
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to record close_command", err.Error())
+			return
+		}
+		resp.Diagnostics.Append(resp.Private.SetKey(ctx, execCleanupKey, data)...)
+	}
+
+	trim := config.TrimOutput.IsNull() || config.TrimOutput.ValueBool()
+	config.Stdout = types.StringValue(execOutputString(out, trim))
+	resp.Diagnostics.Append(resp.Result.Set(ctx, &config)...)
+}
+
+// Close runs the close_command recorded by Open, if any.
+func (e *execEphemeralResource) Close(ctx context.Context, req ephemeral.CloseRequest, resp *ephemeral.CloseResponse) {
+	if e.client == nil || req.Private == nil {
+		return
+	}
+
+	data, diags := req.Private.GetKey(ctx, execCleanupKey)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() || len(data) == 0 {
+		return
+	}
+	var cleanup execCleanup
+	if err := json.Unmarshal(data, &cleanup); err != nil {
+		resp.Diagnostics.AddError("Failed to read close_command", err.Error())
+		return
+	}
+	resp.Diagnostics.Append(e.runCleanup(ctx, cleanup)...)
+}
+
+// runCleanup runs a close_command. An instance that has gone away in the
+// meantime has nothing left to clean up.
+func (e *execEphemeralResource) runCleanup(ctx context.Context, cleanup execCleanup) diag.Diagnostics {
+	var diags diag.Diagnostics
+	err := e.client.Exec(ctx, cleanup.Instance, cleanup.Command)
+	if errors.Is(err, multipasscli.ErrNotFound) {
+		tflog.Info(ctx, "Skipping close_command; instance no longer exists", map[string]any{"instance": cleanup.Instance})
+		return diags
+	}
+	if err != nil {
+		diags.AddError("Failed to run close_command", fmt.Sprintf("close_command inside %q failed: %s", cleanup.Instance, redactExecError(err)))
+	}
+	return diags
+}
+
+// redactExecError describes a failed exec without the stdout that
+// CLIError carries, which may hold the very secret being read.
+func redactExecError(err error) string {
+	var cliErr *multipasscli.CLIError
+	if errors.As(err, &cliErr) {
+		return fmt.Sprintf("multipass exec failed: %v (stderr: %s)", cliErr.Err, cliErr.Stderr)
+	}
+	return err.Error()
+}
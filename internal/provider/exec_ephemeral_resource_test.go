@@ -0,0 +1,160 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+// openEphemeralExec runs execEphemeralResource.Open for config and returns
+// the result model.
+func openEphemeralExec(t *testing.T, client *fakeClient, config execEphemeralResourceModel) (execEphemeralResourceModel, ephemeral.OpenResponse) {
+	t.Helper()
+	ctx := context.Background()
+
+	e := &execEphemeralResource{client: client}
+	var schemaResp ephemeral.SchemaResponse
+	e.Schema(ctx, ephemeral.SchemaRequest{}, &schemaResp)
+
+	config.CloseCommand = types.ListNull(types.StringType)
+	config.Stdout = types.StringNull()
+	// tfsdk.Config has no Set, so build the value through a State.
+	tfState := tfsdk.State{Schema: schemaResp.Schema}
+	if diags := tfState.Set(ctx, &config); diags.HasError() {
+		t.Fatalf("set config: %v", diags)
+	}
+	tfConfig := tfsdk.Config{Schema: schemaResp.Schema, Raw: tfState.Raw}
+
+	resp := ephemeral.OpenResponse{Result: tfsdk.EphemeralResultData{Schema: schemaResp.Schema}}
+	e.Open(ctx, ephemeral.OpenRequest{Config: tfConfig}, &resp)
+	var got execEphemeralResourceModel
+	if !resp.Diagnostics.HasError() {
+		resp.Diagnostics.Append(resp.Result.Get(ctx, &got)...)
+	}
+	return got, resp
+}
+
+func TestExecEphemeralResourceOpen(t *testing.T) {
+	t.Parallel()
+
+	running := func(name string) (*models.Instance, error) {
+		return &models.Instance{Name: name, State: "Running"}, nil
+	}
+
+	t.Run("script", func(t *testing.T) {
+		client := &fakeClient{
+			getInstance: running,
+			execCapture: func(string, []string) ([]byte, error) { return []byte("s3cret\n"), nil },
+		}
+		got, resp := openEphemeralExec(t, client, execEphemeralResourceModel{
+			Instance:   types.StringValue("vm"),
+			Command:    types.ListNull(types.StringType),
+			Script:     types.StringValue("sudo cat /root/admin-password"),
+			TrimOutput: types.BoolNull(),
+		})
+		if resp.Diagnostics.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+		}
+		if got.Stdout.ValueString() != "s3cret" {
+			t.Fatalf("expected trimmed stdout, got %q", got.Stdout.ValueString())
+		}
+		want := []string{"info vm", "exec-capture vm sh -c sudo cat /root/admin-password"}
+		if diff := cmp.Diff(want, client.recorded()); diff != "" {
+			t.Fatalf("unexpected calls (-want +got): %s", diff)
+		}
+	})
+
+	t.Run("untrimmed", func(t *testing.T) {
+		client := &fakeClient{
+			getInstance: running,
+			execCapture: func(string, []string) ([]byte, error) { return []byte(" key \n"), nil },
+		}
+		command, _ := types.ListValueFrom(context.Background(), types.StringType, []string{"cat", "/etc/key"})
+		got, resp := openEphemeralExec(t, client, execEphemeralResourceModel{
+			Instance:   types.StringValue("vm"),
+			Command:    command,
+			Script:     types.StringNull(),
+			TrimOutput: types.BoolValue(false),
+		})
+		if resp.Diagnostics.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+		}
+		if got.Stdout.ValueString() != " key \n" {
+			t.Fatalf("expected raw stdout, got %q", got.Stdout.ValueString())
+		}
+	})
+
+	t.Run("failure hides stdout", func(t *testing.T) {
+		client := &fakeClient{
+			getInstance: running,
+			execCapture: func(string, []string) ([]byte, error) {
+				return nil, &multipasscli.CLIError{Command: "exec vm -- sh -c x", Stdout: "half-written-s3cret", Stderr: "permission denied", Err: errors.New("exit status 1")}
+			},
+		}
+		_, resp := openEphemeralExec(t, client, execEphemeralResourceModel{
+			Instance:   types.StringValue("vm"),
+			Command:    types.ListNull(types.StringType),
+			Script:     types.StringValue("x"),
+			TrimOutput: types.BoolNull(),
+		})
+		if !resp.Diagnostics.HasError() {
+			t.Fatal("expected an error")
+		}
+		detail := resp.Diagnostics[0].Detail()
+		if strings.Contains(detail, "s3cret") || !strings.Contains(detail, "permission denied") {
+			t.Fatalf("unexpected error detail: %s", detail)
+		}
+	})
+
+	t.Run("stopped instance", func(t *testing.T) {
+		client := &fakeClient{getInstance: func(name string) (*models.Instance, error) {
+			return &models.Instance{Name: name, State: "Stopped"}, nil
+		}}
+		_, resp := openEphemeralExec(t, client, execEphemeralResourceModel{
+			Instance:   types.StringValue("vm"),
+			Command:    types.ListNull(types.StringType),
+			Script:     types.StringValue("true"),
+			TrimOutput: types.BoolNull(),
+		})
+		if !resp.Diagnostics.HasError() {
+			t.Fatal("expected an error for a stopped instance")
+		}
+		if diff := cmp.Diff([]string{"info vm"}, client.recorded()); diff != "" {
+			t.Fatalf("unexpected calls (-want +got): %s", diff)
+		}
+	})
+}
+
+func TestExecEphemeralResourceRunCleanup(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	cleanup := execCleanup{Instance: "vm", Command: []string{"rm", "/root/admin-password"}}
+
+	client := &fakeClient{}
+	if diags := (&execEphemeralResource{client: client}).runCleanup(ctx, cleanup); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if diff := cmp.Diff([]string{"exec vm rm /root/admin-password"}, client.recorded()); diff != "" {
+		t.Fatalf("unexpected calls (-want +got): %s", diff)
+	}
+
+	gone := &fakeClient{exec: func(string, []string) error { return multipasscli.ErrNotFound }}
+	if diags := (&execEphemeralResource{client: gone}).runCleanup(ctx, cleanup); diags.HasError() {
+		t.Fatalf("a deleted instance should not fail close: %v", diags)
+	}
+
+	failing := &fakeClient{exec: func(string, []string) error { return errors.New("exit status 1") }}
+	if diags := (&execEphemeralResource{client: failing}).runCleanup(ctx, cleanup); !diags.HasError() {
+		t.Fatal("expected a failed close_command to be reported")
+	}
+}
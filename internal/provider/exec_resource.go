@@ -0,0 +1,399 @@
+package provider
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework-timeouts/resource/timeouts"
+	"github.com/hashicorp/terraform-plugin-framework-validators/listvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+var (
+	_ resource.Resource               = (*execResource)(nil)
+	_ resource.ResourceWithConfigure  = (*execResource)(nil)
+	_ resource.ResourceWithModifyPlan = (*execResource)(nil)
+)
+
+// NewExecResource instantiates the exec resource.
+func NewExecResource() resource.Resource {
+	return &execResource{}
+}
+
+type execResource struct {
+	client         multipasscli.Client
+	commandTimeout time.Duration
+}
+
+type execResourceModel struct {
+	ID               types.String   `tfsdk:"id"`
+	Instance         types.String   `tfsdk:"instance"`
+	Command          types.List     `tfsdk:"command"`
+	Script           types.String   `tfsdk:"script"`
+	WorkingDirectory types.String   `tfsdk:"working_directory"`
+	Triggers         types.Map      `tfsdk:"triggers"`
+	DestroyCommand   types.List     `tfsdk:"destroy_command"`
+	IgnoreFailure    types.Bool     `tfsdk:"ignore_failure"`
+	Stdout           types.String   `tfsdk:"stdout"`
+	Stderr           types.String   `tfsdk:"stderr"`
+	ExitCode         types.Int64    `tfsdk:"exit_code"`
+	Timeouts         timeouts.Value `tfsdk:"timeouts"`
+}
+
+func (r *execResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_exec"
+}
+
+func (r *execResource) Schema(ctx context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	oneOf := []path.Expression{
+		path.MatchRelative().AtParent().AtName("command"),
+		path.MatchRelative().AtParent().AtName("script"),
+	}
+
+	resp.Schema = schema.Schema{
+		Description:         "Runs a command inside a Multipass instance on create, and again whenever the command or triggers change. An optional destroy_command runs when the resource is destroyed. Refresh only checks that the instance still exists.",
+		MarkdownDescription: "Runs a command inside a Multipass instance on create, and again whenever the command or `triggers` change. An optional `destroy_command` runs when the resource is destroyed. Refresh only checks that the instance still exists; commands are never re-run on refresh. Replaces `null_resource` with a `local-exec` of `multipass exec`.",
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:    true,
+				Description: "Identifier in the form `<instance>/<random suffix>`.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"instance": schema.StringAttribute{
+				Required:    true,
+				Description: "Instance to run the commands in. It must be running. Changing forces recreation.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"command": schema.ListAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "Command and arguments, run without a shell. Exactly one of `command` or `script` is required. Changing re-runs the command in place.",
+				Validators: []validator.List{
+					listvalidator.ExactlyOneOf(oneOf...),
+					listvalidator.SizeAtLeast(1),
+				},
+			},
+			"script": schema.StringAttribute{
+				Optional:    true,
+				Description: "Shell script run with `sh -c`. Exactly one of `command` or `script` is required. Changing re-runs the script in place.",
+				Validators: []validator.String{
+					stringvalidator.ExactlyOneOf(oneOf...),
+				},
+			},
+			"working_directory": schema.StringAttribute{
+				Optional:    true,
+				Description: "Directory inside the instance to run the commands in.",
+			},
+			"triggers": schema.MapAttribute{
+				Optional:            true,
+				ElementType:         types.StringType,
+				Description:         "Map of arbitrary values that, when changed, re-run the command in place.",
+				MarkdownDescription: "Map of arbitrary values that, when changed, re-run the command in place (similar to `null_resource.triggers`, but without running `destroy_command` first).",
+			},
+			"destroy_command": schema.ListAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "Command and arguments run without a shell when the resource is destroyed, e.g. to deregister an agent. Skipped when the instance no longer exists.",
+				Validators: []validator.List{
+					listvalidator.SizeAtLeast(1),
+				},
+			},
+			"ignore_failure": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Record a non-zero exit status in exit_code instead of failing, for both command and destroy_command (default: false).",
+			},
+			"stdout": schema.StringAttribute{
+				Computed:    true,
+				Description: "Standard output of the last run, trimmed of surrounding whitespace and capped at 64 KiB.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"stderr": schema.StringAttribute{
+				Computed:    true,
+				Description: "Standard error of the last run, trimmed of surrounding whitespace and capped at 64 KiB.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"exit_code": schema.Int64Attribute{
+				Computed:    true,
+				Description: "Exit status of the last run.",
+				PlanModifiers: []planmodifier.Int64{
+					int64planmodifier.UseStateForUnknown(),
+				},
+			},
+		},
+		Blocks: map[string]schema.Block{
+			"timeouts": timeouts.Block(ctx, timeouts.Opts{
+				Create: true,
+				Update: true,
+				Delete: true,
+			}),
+		},
+	}
+}
+
+func (r *execResource) Configure(_ context.Context, req resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	data := req.ProviderData.(providerData)
+	r.client = data.client
+	r.commandTimeout = data.commandTimeout
+}
+
+// ModifyPlan marks the outputs unknown when an update re-runs the command.
+func (r *execResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() || req.State.Raw.IsNull() {
+		return
+	}
+
+	var plan execResourceModel
+	var state execResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() || !execNeedsRun(ctx, &plan, &state) {
+		return
+	}
+
+	plan.Stdout = types.StringUnknown()
+	plan.Stderr = types.StringUnknown()
+	plan.ExitCode = types.Int64Unknown()
+	resp.Diagnostics.Append(resp.Plan.Set(ctx, &plan)...)
+}
+
+func (r *execResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var plan execResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ctx, done := startOperation(ctx, "multipass_exec", "create", plan.Instance.ValueString())
+	defer done(&resp.Diagnostics)
+
+	createTimeout, diags := plan.Timeouts.Create(ctx, r.commandTimeout)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, createTimeout)
+	defer cancel()
+
+	resp.Diagnostics.Append(r.run(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		resp.Diagnostics.AddError("Failed to generate resource id", err.Error())
+		return
+	}
+	plan.ID = types.StringValue(plan.Instance.ValueString() + "/" + hex.EncodeToString(suffix))
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+// Read only checks that the instance still exists; commands are not re-run.
+func (r *execResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	if r.client == nil {
+		resp.Diagnostics.AddError("Client not configured", "Multipass client is nil.")
+		return
+	}
+
+	var state execResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ctx, done := startOperation(ctx, "multipass_exec", "read", state.Instance.ValueString())
+	defer done(&resp.Diagnostics)
+
+	_, err := r.client.GetInstance(ctx, state.Instance.ValueString())
+	if errors.Is(err, multipasscli.ErrNotFound) {
+		tflog.Info(ctx, "Multipass instance for exec no longer exists", map[string]any{"instance": state.Instance.ValueString()})
+		resp.State.RemoveResource(ctx)
+		return
+	}
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read instance", err.Error())
+	}
+}
+
+func (r *execResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var plan execResourceModel
+	var state execResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ctx, done := startOperation(ctx, "multipass_exec", "update", plan.Instance.ValueString())
+	defer done(&resp.Diagnostics)
+
+	updateTimeout, diags := plan.Timeouts.Update(ctx, r.commandTimeout)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, updateTimeout)
+	defer cancel()
+
+	if execNeedsRun(ctx, &plan, &state) {
+		resp.Diagnostics.Append(r.run(ctx, &plan)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	} else {
+		plan.Stdout = state.Stdout
+		plan.Stderr = state.Stderr
+		plan.ExitCode = state.ExitCode
+	}
+	plan.ID = state.ID
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *execResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var state execResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() || state.DestroyCommand.IsNull() {
+		return
+	}
+	if r.client == nil {
+		resp.Diagnostics.AddError("Client not configured", "Multipass client is nil.")
+		return
+	}
+
+	ctx, done := startOperation(ctx, "multipass_exec", "delete", state.Instance.ValueString())
+	defer done(&resp.Diagnostics)
+
+	deleteTimeout, diags := state.Timeouts.Delete(ctx, r.commandTimeout)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, deleteTimeout)
+	defer cancel()
+
+	name := state.Instance.ValueString()
+	instance, err := r.client.GetInstance(ctx, name)
+	if errors.Is(err, multipasscli.ErrNotFound) {
+		tflog.Info(ctx, "Skipping destroy_command for a missing instance", map[string]any{"instance": name})
+		return
+	}
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read instance", err.Error())
+		return
+	}
+	if !strings.EqualFold(instance.State, "Running") {
+		resp.Diagnostics.AddError("Instance not running", fmt.Sprintf("Instance %q is %s; start it so destroy_command can run, or remove destroy_command first.", name, instance.State))
+		return
+	}
+
+	var command []string
+	resp.Diagnostics.Append(state.DestroyCommand.ElementsAs(ctx, &command, false)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	_, diags = r.exec(ctx, &state, name, command)
+	resp.Diagnostics.Append(diags...)
+}
+
+// run executes command or script and records its output in model.
+func (r *execResource) run(ctx context.Context, model *execResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+	if r.client == nil {
+		diags.AddError("Client not configured", "Multipass client is nil.")
+		return diags
+	}
+
+	var command []string
+	if !model.Command.IsNull() {
+		diags.Append(model.Command.ElementsAs(ctx, &command, false)...)
+		if diags.HasError() {
+			return diags
+		}
+	} else {
+		command = []string{"sh", "-c", model.Script.ValueString()}
+	}
+
+	instance, d := runningExecInstance(ctx, r.client, model.Instance.ValueString())
+	diags.Append(d...)
+	if diags.HasError() {
+		return diags
+	}
+	result, d := r.exec(ctx, model, instance.Name, command)
+	diags.Append(d...)
+	if diags.HasError() {
+		return diags
+	}
+	model.Stdout = types.StringValue(execResourceOutput(&diags, "stdout", result.Stdout))
+	model.Stderr = types.StringValue(execResourceOutput(&diags, "stderr", result.Stderr))
+	model.ExitCode = types.Int64Value(int64(result.ExitCode))
+	return diags
+}
+
+// exec runs command in instance and fails on a non-zero exit status unless
+// ignore_failure is set.
+func (r *execResource) exec(ctx context.Context, model *execResourceModel, instance string, command []string) (multipasscli.ExecResult, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	tflog.Debug(ctx, "Running command in Multipass instance", map[string]any{"instance": instance, "command": command})
+	result, err := r.client.ExecOutput(ctx, instance, multipasscli.ExecOptions{
+		Command:          command,
+		WorkingDirectory: valueOrEmpty(model.WorkingDirectory),
+	})
+	if err != nil {
+		diags.AddError("Failed to run command", err.Error())
+		return result, diags
+	}
+	if result.ExitCode != 0 && !model.IgnoreFailure.ValueBool() {
+		diags.AddError("Command failed", fmt.Sprintf("The command inside %q exited with status %d. Set ignore_failure = true to tolerate this.\n\nstderr: %s", instance, result.ExitCode, execOutputString(result.Stderr, true)))
+	}
+	return result, diags
+}
+
+// execNeedsRun reports whether an update changes anything that re-runs the
+// command. destroy_command and ignore_failure only affect later runs.
+func execNeedsRun(ctx context.Context, plan, state *execResourceModel) bool {
+	return !plan.Command.Equal(state.Command) ||
+		!plan.Script.Equal(state.Script) ||
+		!plan.WorkingDirectory.Equal(state.WorkingDirectory) ||
+		!mapsEqual(ctx, plan.Triggers, state.Triggers)
+}
+
+// execResourceOutput converts captured output for state, truncating it to
+// defaultMaxInlineSize with a warning since the command has already run.
+func execResourceOutput(diags *diag.Diagnostics, name string, data []byte) string {
+	if len(data) > defaultMaxInlineSize {
+		diags.AddWarning("Command output truncated", fmt.Sprintf("The command wrote %d bytes to %s; only the first %d are kept in state.", len(data), name, defaultMaxInlineSize))
+		data = data[:defaultMaxInlineSize]
+	}
+	return execOutputString(data, true)
+}
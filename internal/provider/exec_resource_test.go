@@ -0,0 +1,326 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-framework-timeouts/resource/timeouts"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+// execModel returns a planned multipass_exec for instance "vm" running
+// script.
+func execModel(script string) execResourceModel {
+	return execResourceModel{
+		ID:               types.StringUnknown(),
+		Instance:         types.StringValue("vm"),
+		Command:          types.ListNull(types.StringType),
+		Script:           types.StringValue(script),
+		WorkingDirectory: types.StringNull(),
+		Triggers:         types.MapNull(types.StringType),
+		DestroyCommand:   types.ListNull(types.StringType),
+		IgnoreFailure:    types.BoolNull(),
+		Stdout:           types.StringUnknown(),
+		Stderr:           types.StringUnknown(),
+		ExitCode:         types.Int64Unknown(),
+		Timeouts: timeouts.Value{Object: types.ObjectNull(map[string]attr.Type{
+			"create": types.StringType,
+			"update": types.StringType,
+			"delete": types.StringType,
+		})},
+	}
+}
+
+// createExec runs execResource.Create for plan and returns the response.
+func createExec(t *testing.T, client *fakeClient, plan execResourceModel) resource.CreateResponse {
+	t.Helper()
+	ctx := context.Background()
+
+	r := &execResource{client: client, commandTimeout: time.Minute}
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	tfPlan := tfsdk.Plan{Schema: schemaResp.Schema}
+	if diags := tfPlan.Set(ctx, &plan); diags.HasError() {
+		t.Fatalf("set plan: %v", diags)
+	}
+	resp := resource.CreateResponse{State: tfsdk.State{Schema: schemaResp.Schema}}
+	r.Create(ctx, resource.CreateRequest{Plan: tfPlan}, &resp)
+	return resp
+}
+
+func TestExecResourceCreate(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("records output", func(t *testing.T) {
+		client := execResultClient(multipasscli.ExecResult{Stdout: []byte("registered\n"), Stderr: []byte("note\n")})
+		resp := createExec(t, client, execModel("register-agent"))
+		if resp.Diagnostics.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+		}
+		if diff := cmp.Diff([]string{"info vm", "exec-output vm sh -c register-agent"}, client.recorded()); diff != "" {
+			t.Fatalf("unexpected calls (-want +got): %s", diff)
+		}
+		var got execResourceModel
+		resp.Diagnostics.Append(resp.State.Get(ctx, &got)...)
+		if got.Stdout.ValueString() != "registered" || got.Stderr.ValueString() != "note" || got.ExitCode.ValueInt64() != 0 {
+			t.Fatalf("unexpected outputs: %q, %q, %d", got.Stdout.ValueString(), got.Stderr.ValueString(), got.ExitCode.ValueInt64())
+		}
+		if !strings.HasPrefix(got.ID.ValueString(), "vm/") {
+			t.Fatalf("unexpected id %q", got.ID.ValueString())
+		}
+	})
+
+	t.Run("non-zero exit fails", func(t *testing.T) {
+		client := execResultClient(multipasscli.ExecResult{ExitCode: 3, Stderr: []byte("boom")})
+		resp := createExec(t, client, execModel("false"))
+		if !resp.Diagnostics.HasError() || !strings.Contains(resp.Diagnostics[0].Detail(), "status 3") {
+			t.Fatalf("expected a command failure, got %v", resp.Diagnostics)
+		}
+	})
+
+	t.Run("ignore_failure records exit code", func(t *testing.T) {
+		plan := execModel("false")
+		plan.IgnoreFailure = types.BoolValue(true)
+		resp := createExec(t, execResultClient(multipasscli.ExecResult{ExitCode: 3}), plan)
+		if resp.Diagnostics.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+		}
+		var got execResourceModel
+		resp.Diagnostics.Append(resp.State.Get(ctx, &got)...)
+		if got.ExitCode.ValueInt64() != 3 {
+			t.Fatalf("exit_code = %d, want 3", got.ExitCode.ValueInt64())
+		}
+	})
+
+	t.Run("stopped instance", func(t *testing.T) {
+		client := &fakeClient{getInstance: func(name string) (*models.Instance, error) {
+			return &models.Instance{Name: name, State: "Stopped"}, nil
+		}}
+		resp := createExec(t, client, execModel("true"))
+		if !resp.Diagnostics.HasError() {
+			t.Fatal("expected an error for a stopped instance")
+		}
+		if diff := cmp.Diff([]string{"info vm"}, client.recorded()); diff != "" {
+			t.Fatalf("unexpected calls (-want +got): %s", diff)
+		}
+	})
+}
+
+func TestExecNeedsRun(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	state := execModel("deploy")
+	state.Triggers = types.MapValueMust(types.StringType, map[string]attr.Value{"version": types.StringValue("1")})
+
+	cases := map[string]struct {
+		change func(*execResourceModel)
+		want   bool
+	}{
+		"unchanged": {change: func(*execResourceModel) {}},
+		"destroy_command": {change: func(m *execResourceModel) {
+			m.DestroyCommand = types.ListValueMust(types.StringType, []attr.Value{types.StringValue("undeploy")})
+		}},
+		"ignore_failure": {change: func(m *execResourceModel) { m.IgnoreFailure = types.BoolValue(true) }},
+		"script":         {change: func(m *execResourceModel) { m.Script = types.StringValue("deploy --force") }, want: true},
+		"working_directory": {change: func(m *execResourceModel) {
+			m.WorkingDirectory = types.StringValue("/srv")
+		}, want: true},
+		"triggers": {change: func(m *execResourceModel) {
+			m.Triggers = types.MapValueMust(types.StringType, map[string]attr.Value{"version": types.StringValue("2")})
+		}, want: true},
+	}
+	for name, tc := range cases {
+		plan := state
+		tc.change(&plan)
+		if got := execNeedsRun(ctx, &plan, &state); got != tc.want {
+			t.Errorf("%s: execNeedsRun = %t, want %t", name, got, tc.want)
+		}
+	}
+}
+
+func TestExecResourceUpdate(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	r := &execResource{commandTimeout: time.Minute}
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	state := execModel("deploy")
+	state.ID = types.StringValue("vm/0123456789abcdef")
+	state.Stdout = types.StringValue("v1")
+	state.Stderr = types.StringValue("")
+	state.ExitCode = types.Int64Value(0)
+
+	update := func(t *testing.T, client *fakeClient, plan execResourceModel) execResourceModel {
+		t.Helper()
+		r.client = client
+		tfPlan := tfsdk.Plan{Schema: schemaResp.Schema}
+		tfState := tfsdk.State{Schema: schemaResp.Schema}
+		if diags := tfPlan.Set(ctx, &plan); diags.HasError() {
+			t.Fatalf("set plan: %v", diags)
+		}
+		if diags := tfState.Set(ctx, &state); diags.HasError() {
+			t.Fatalf("set state: %v", diags)
+		}
+		resp := resource.UpdateResponse{State: tfsdk.State{Schema: schemaResp.Schema}}
+		r.Update(ctx, resource.UpdateRequest{Plan: tfPlan, State: tfState}, &resp)
+		if resp.Diagnostics.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+		}
+		var got execResourceModel
+		resp.Diagnostics.Append(resp.State.Get(ctx, &got)...)
+		return got
+	}
+
+	t.Run("trigger change re-runs", func(t *testing.T) {
+		client := execResultClient(multipasscli.ExecResult{Stdout: []byte("v2")})
+		plan := execModel("deploy")
+		plan.ID = state.ID
+		plan.Triggers = types.MapValueMust(types.StringType, map[string]attr.Value{"version": types.StringValue("2")})
+		got := update(t, client, plan)
+		if diff := cmp.Diff([]string{"info vm", "exec-output vm sh -c deploy"}, client.recorded()); diff != "" {
+			t.Fatalf("unexpected calls (-want +got): %s", diff)
+		}
+		if got.Stdout.ValueString() != "v2" || got.ID.ValueString() != state.ID.ValueString() {
+			t.Fatalf("unexpected state: stdout %q, id %q", got.Stdout.ValueString(), got.ID.ValueString())
+		}
+	})
+
+	t.Run("destroy_command change keeps outputs", func(t *testing.T) {
+		client := &fakeClient{}
+		plan := state
+		plan.DestroyCommand = types.ListValueMust(types.StringType, []attr.Value{types.StringValue("undeploy")})
+		got := update(t, client, plan)
+		if calls := client.recorded(); len(calls) != 0 {
+			t.Fatalf("expected no CLI calls, got %v", calls)
+		}
+		if got.Stdout.ValueString() != "v1" {
+			t.Fatalf("stdout = %q, want the previous output", got.Stdout.ValueString())
+		}
+	})
+}
+
+func TestExecResourceCreateDryRun(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	fake := &fakeClient{}
+	r := &execResource{client: multipasscli.NewDryRunClient(fake), commandTimeout: time.Minute}
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	plan := execModel("register-agent")
+	tfPlan := tfsdk.Plan{Schema: schemaResp.Schema}
+	if diags := tfPlan.Set(ctx, &plan); diags.HasError() {
+		t.Fatalf("set plan: %v", diags)
+	}
+	resp := resource.CreateResponse{State: tfsdk.State{Schema: schemaResp.Schema}}
+	r.Create(ctx, resource.CreateRequest{Plan: tfPlan}, &resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("unexpected error: %v", resp.Diagnostics)
+	}
+	// Only the read-only instance lookup reaches multipass.
+	if diff := cmp.Diff([]string{"info vm"}, fake.recorded()); diff != "" {
+		t.Fatalf("unexpected calls (-want +got): %s", diff)
+	}
+	warnings := resp.Diagnostics.Warnings()
+	if len(warnings) != 1 || warnings[0].Summary() != "Simulated by dry_run" {
+		t.Fatalf("expected a dry_run warning, got %v", resp.Diagnostics)
+	}
+	if want := "multipass exec vm"; !strings.Contains(warnings[0].Detail(), want) {
+		t.Fatalf("expected %q in the warning, got %q", want, warnings[0].Detail())
+	}
+	if strings.Contains(warnings[0].Detail(), "register-agent") {
+		t.Fatalf("the warning must not repeat the command, got %q", warnings[0].Detail())
+	}
+
+	var got execResourceModel
+	resp.Diagnostics.Append(resp.State.Get(ctx, &got)...)
+	if got.Stdout.ValueString() != "" || got.ExitCode.ValueInt64() != 0 {
+		t.Fatalf("expected empty outputs, got %q and %d", got.Stdout.ValueString(), got.ExitCode.ValueInt64())
+	}
+}
+
+func TestExecResourceDelete(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	r := &execResource{commandTimeout: time.Minute}
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	del := func(t *testing.T, client *fakeClient, state execResourceModel) resource.DeleteResponse {
+		t.Helper()
+		r := &execResource{client: client, commandTimeout: time.Minute}
+		tfState := tfsdk.State{Schema: schemaResp.Schema}
+		if diags := tfState.Set(ctx, &state); diags.HasError() {
+			t.Fatalf("set state: %v", diags)
+		}
+		resp := resource.DeleteResponse{State: tfState}
+		r.Delete(ctx, resource.DeleteRequest{State: tfState}, &resp)
+		return resp
+	}
+
+	state := execModel("register")
+	state.ID = types.StringValue("vm/0123456789abcdef")
+	state.Stdout = types.StringValue("")
+	state.Stderr = types.StringValue("")
+	state.ExitCode = types.Int64Value(0)
+
+	t.Run("without destroy_command", func(t *testing.T) {
+		client := &fakeClient{}
+		if resp := del(t, client, state); resp.Diagnostics.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+		}
+		if calls := client.recorded(); len(calls) != 0 {
+			t.Fatalf("expected no CLI calls, got %v", calls)
+		}
+	})
+
+	withDestroy := state
+	withDestroy.DestroyCommand = types.ListValueMust(types.StringType, []attr.Value{types.StringValue("deregister"), types.StringValue("--now")})
+
+	t.Run("runs destroy_command", func(t *testing.T) {
+		client := &fakeClient{}
+		if resp := del(t, client, withDestroy); resp.Diagnostics.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+		}
+		want := []string{"info vm", "exec-output vm deregister --now"}
+		if diff := cmp.Diff(want, client.recorded()); diff != "" {
+			t.Fatalf("unexpected calls (-want +got): %s", diff)
+		}
+	})
+
+	t.Run("failing destroy_command", func(t *testing.T) {
+		resp := del(t, execResultClient(multipasscli.ExecResult{ExitCode: 1}), withDestroy)
+		if !resp.Diagnostics.HasError() {
+			t.Fatal("expected a failing destroy_command to fail the destroy")
+		}
+	})
+
+	t.Run("missing instance", func(t *testing.T) {
+		client := &fakeClient{getInstance: func(string) (*models.Instance, error) {
+			return nil, multipasscli.ErrNotFound
+		}}
+		if resp := del(t, client, withDestroy); resp.Diagnostics.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+		}
+		if diff := cmp.Diff([]string{"info vm"}, client.recorded()); diff != "" {
+			t.Fatalf("unexpected calls (-want +got): %s", diff)
+		}
+	})
+}
@@ -0,0 +1,306 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+// fakeClient is a recording multipasscli.Client for unit tests. Each
+// overridden method appends a line to calls and delegates to the matching
+// function field; methods that are not overridden panic through the nil
+// embedded interface, which flags unexpected CLI usage.
+type fakeClient struct {
+	multipasscli.Client
+
+	mu    sync.Mutex
+	calls []string
+
+	versionInfo     func() (models.VersionInfo, error)
+	listInstances   func(refresh bool) ([]models.Instance, error)
+	getInstance     func(name string) (*models.Instance, error)
+	startInstance   func(name string) error
+	stopInstance    func(name string) error
+	suspend         func(name string) error
+	recoverInstance func(name string) error
+	launchInstance  func(opts models.LaunchOptions) error
+	deleteInstance  func(name string) error
+	cloneInstance   func(source, name string) error
+	exec            func(instance string, command []string) error
+	execCapture     func(instance string, command []string) ([]byte, error)
+	execOutput      func(instance string, opts multipasscli.ExecOptions) (multipasscli.ExecResult, error)
+	transfer        func(opts multipasscli.TransferOptions) error
+	transferTo      func(opts multipasscli.TransferOptions, w io.Writer) error
+	transferCapture func(opts multipasscli.TransferOptions) ([]byte, error)
+	listAliases     func() ([]models.Alias, error)
+	createAlias     func(alias models.Alias) error
+	deleteAlias     func(name string) error
+	activeContext   string
+	listSnapshots   func(instance string) ([]models.Snapshot, error)
+	createSnapshot  func(instance, name, comment string) (string, error)
+	deleteSnapshot  func(instance, name string) error
+	getSetting      func(key string) (string, error)
+	setSetting      func(key, value string) error
+	setPrimary      func(name string) error
+	settingKeys     []string
+}
+
+func (f *fakeClient) record(format string, args ...any) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeClient) recorded() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
+
+func (f *fakeClient) VersionInfo(context.Context) (models.VersionInfo, error) {
+	f.record("version")
+	if f.versionInfo == nil {
+		return models.VersionInfo{Client: "1.15.0", Daemon: "1.15.0"}, nil
+	}
+	return f.versionInfo()
+}
+
+func (f *fakeClient) ListInstances(_ context.Context, refresh bool) ([]models.Instance, error) {
+	f.record("list")
+	if f.listInstances == nil {
+		return nil, nil
+	}
+	return f.listInstances(refresh)
+}
+
+func (f *fakeClient) GetInstance(_ context.Context, name string) (*models.Instance, error) {
+	f.record("info %s", name)
+	if f.getInstance == nil {
+		return &models.Instance{Name: name, State: "Running"}, nil
+	}
+	return f.getInstance(name)
+}
+
+func (f *fakeClient) StartInstance(_ context.Context, name string) error {
+	f.record("start %s", name)
+	if f.startInstance == nil {
+		return nil
+	}
+	return f.startInstance(name)
+}
+
+func (f *fakeClient) StopInstance(_ context.Context, name string, force bool) error {
+	f.record("stop %s force=%t", name, force)
+	if f.stopInstance == nil {
+		return nil
+	}
+	return f.stopInstance(name)
+}
+
+func (f *fakeClient) SuspendInstance(_ context.Context, name string) error {
+	f.record("suspend %s", name)
+	if f.suspend == nil {
+		return nil
+	}
+	return f.suspend(name)
+}
+
+func (f *fakeClient) RecoverInstance(_ context.Context, name string) error {
+	f.record("recover %s", name)
+	if f.recoverInstance == nil {
+		return nil
+	}
+	return f.recoverInstance(name)
+}
+
+func (f *fakeClient) LaunchInstance(_ context.Context, opts models.LaunchOptions) error {
+	f.record("launch %s", opts.Name)
+	if f.launchInstance == nil {
+		return nil
+	}
+	return f.launchInstance(opts)
+}
+
+func (f *fakeClient) DeleteInstance(_ context.Context, name string, purge bool) error {
+	f.record("delete %s purge=%t", name, purge)
+	if f.deleteInstance == nil {
+		return nil
+	}
+	return f.deleteInstance(name)
+}
+
+func (f *fakeClient) CloneInstance(_ context.Context, source, name string) error {
+	f.record("clone %s %s", source, name)
+	if f.cloneInstance == nil {
+		return nil
+	}
+	return f.cloneInstance(source, name)
+}
+
+func (f *fakeClient) Exec(_ context.Context, instance string, command []string) error {
+	f.record("exec %s %s", instance, strings.Join(command, " "))
+	if f.exec == nil {
+		return nil
+	}
+	return f.exec(instance, command)
+}
+
+func (f *fakeClient) ExecCapture(_ context.Context, instance string, command []string) ([]byte, error) {
+	f.record("exec-capture %s %s", instance, strings.Join(command, " "))
+	if f.execCapture == nil {
+		return nil, nil
+	}
+	return f.execCapture(instance, command)
+}
+
+func (f *fakeClient) ExecOutput(_ context.Context, instance string, opts multipasscli.ExecOptions) (multipasscli.ExecResult, error) {
+	f.record("exec-output %s %s", instance, strings.Join(opts.Command, " "))
+	if f.execOutput == nil {
+		return multipasscli.ExecResult{}, nil
+	}
+	return f.execOutput(instance, opts)
+}
+
+func (f *fakeClient) Transfer(_ context.Context, opts multipasscli.TransferOptions) error {
+	source := strings.Join(opts.Sources, " ")
+	if opts.Stdin != nil {
+		source = "-"
+	}
+	f.record("transfer %s %s", source, opts.Destination)
+	if f.transfer == nil {
+		return nil
+	}
+	return f.transfer(opts)
+}
+
+func (f *fakeClient) TransferTo(_ context.Context, opts multipasscli.TransferOptions, w io.Writer) error {
+	f.record("transfer-to %s", strings.Join(opts.Sources, " "))
+	if f.transferTo == nil {
+		return nil
+	}
+	return f.transferTo(opts, w)
+}
+
+func (f *fakeClient) TransferCapture(_ context.Context, opts multipasscli.TransferOptions) ([]byte, error) {
+	f.record("transfer-capture %s", strings.Join(opts.Sources, " "))
+	if f.transferCapture == nil {
+		return nil, nil
+	}
+	return f.transferCapture(opts)
+}
+
+func (f *fakeClient) ListAliases(_ context.Context, refresh bool) ([]models.Alias, error) {
+	f.record("aliases")
+	if f.listAliases == nil {
+		return nil, nil
+	}
+	return f.listAliases()
+}
+
+func (f *fakeClient) ListSnapshots(_ context.Context, instance string) ([]models.Snapshot, error) {
+	f.record("snapshots %s", instance)
+	if f.listSnapshots == nil {
+		return nil, nil
+	}
+	return f.listSnapshots(instance)
+}
+
+func (f *fakeClient) ListSnapshotDetails(_ context.Context, instance string) ([]models.Snapshot, error) {
+	f.record("snapshot-details %s", instance)
+	if f.listSnapshots == nil {
+		return nil, nil
+	}
+	return f.listSnapshots(instance)
+}
+
+func (f *fakeClient) CreateSnapshot(_ context.Context, instance, name, comment string) (string, error) {
+	f.record("snapshot %s %s", instance, name)
+	if f.createSnapshot == nil {
+		return name, nil
+	}
+	return f.createSnapshot(instance, name, comment)
+}
+
+func (f *fakeClient) DeleteSnapshot(_ context.Context, instance, name string, purge bool) error {
+	f.record("delete %s.%s purge=%t", instance, name, purge)
+	if f.deleteSnapshot == nil {
+		return nil
+	}
+	return f.deleteSnapshot(instance, name)
+}
+
+func (f *fakeClient) RestoreSnapshot(_ context.Context, instance, name string, opts multipasscli.RestoreOptions) error {
+	f.record("restore %s.%s destructive=%t snapshot=%t", instance, name, opts.Destructive, opts.SnapshotCurrentState)
+	return nil
+}
+
+func (f *fakeClient) Mount(_ context.Context, instance string, mount models.Mount) error {
+	if len(mount.UIDMappings) > 0 || len(mount.GIDMappings) > 0 {
+		f.record("mount %s %s read_only=%t uid=%v gid=%v", instance, mount.InstancePath, mount.ReadOnly, mount.UIDMappings, mount.GIDMappings)
+		return nil
+	}
+	f.record("mount %s %s read_only=%t", instance, mount.InstancePath, mount.ReadOnly)
+	return nil
+}
+
+func (f *fakeClient) Unmount(_ context.Context, instance string, mount models.Mount) error {
+	f.record("umount %s %s", instance, mount.InstancePath)
+	return nil
+}
+
+func (f *fakeClient) ActiveAliasContext(context.Context) (string, error) {
+	if f.activeContext == "" {
+		return "default", nil
+	}
+	return f.activeContext, nil
+}
+
+func (f *fakeClient) CreateAlias(_ context.Context, alias models.Alias) error {
+	f.record("alias %s:%s %s", alias.Instance, alias.Command, alias.Name)
+	if f.createAlias == nil {
+		return nil
+	}
+	return f.createAlias(alias)
+}
+
+func (f *fakeClient) DeleteAlias(_ context.Context, name string) error {
+	f.record("unalias %s", name)
+	if f.deleteAlias == nil {
+		return nil
+	}
+	return f.deleteAlias(name)
+}
+
+func (f *fakeClient) GetSetting(_ context.Context, key string) (string, error) {
+	f.record("get %s", key)
+	if f.getSetting == nil {
+		return "", multipasscli.ErrNotFound
+	}
+	return f.getSetting(key)
+}
+
+func (f *fakeClient) SetPrimary(_ context.Context, name string) error {
+	f.record("primary %s", name)
+	if f.setPrimary == nil {
+		return nil
+	}
+	return f.setPrimary(name)
+}
+
+func (f *fakeClient) SetSetting(_ context.Context, key, value string) error {
+	f.record("set %s=%s", key, value)
+	if f.setSetting == nil {
+		return nil
+	}
+	return f.setSetting(key, value)
+}
+
+func (f *fakeClient) ListSettingKeys(context.Context) ([]string, error) {
+	f.record("get --keys")
+	return append([]string(nil), f.settingKeys...), nil
+}
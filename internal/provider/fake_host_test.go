@@ -0,0 +1,306 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+// fakeHost is an in-memory Multipass host for tests that drive the whole
+// provider through Terraform. Unlike fakeClient, which scripts single
+// calls, it keeps instances and aliases between calls the way multipassd
+// does. Methods the host does not model panic through the nil embedded
+// Client, which points at what a new test needs to add.
+type fakeHost struct {
+	multipasscli.Client
+
+	mu        sync.Mutex
+	instances map[string]*models.Instance
+	aliases   map[string]models.Alias
+	primary   string
+	launches  map[string]int
+	nextIP    int
+}
+
+func newFakeHost() *fakeHost {
+	return &fakeHost{
+		instances: map[string]*models.Instance{},
+		aliases:   map[string]models.Alias{},
+		launches:  map[string]int{},
+	}
+}
+
+// fakeHostProviderFactories serves a provider backed by host to Terraform.
+func fakeHostProviderFactories(host *fakeHost) map[string]func() (tfprotov6.ProviderServer, error) {
+	return map[string]func() (tfprotov6.ProviderServer, error){
+		"multipass": providerserver.NewProtocol6WithError(NewWithClient("test", host)()),
+	}
+}
+
+// instance returns a copy of the named instance, or nil.
+func (h *fakeHost) instance(name string) *models.Instance {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	inst, ok := h.instances[name]
+	if !ok {
+		return nil
+	}
+	cp := *inst
+	return &cp
+}
+
+// launchCount reports how often the named instance was launched.
+func (h *fakeHost) launchCount(name string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.launches[name]
+}
+
+// setState changes an instance's state behind Terraform's back, or removes
+// it entirely for state "".
+func (h *fakeHost) setState(name, state string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if state == "" {
+		delete(h.instances, name)
+		return
+	}
+	if inst, ok := h.instances[name]; ok {
+		inst.State = state
+	}
+}
+
+// removeMount drops a mount behind Terraform's back, like `multipass umount`.
+func (h *fakeHost) removeMount(name, instancePath string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if inst, ok := h.instances[name]; ok {
+		inst.Mounts = withoutMount(inst.Mounts, instancePath)
+	}
+}
+
+// mountPaths lists the instance paths mounted into the named instance.
+func (h *fakeHost) mountPaths(name string) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var paths []string
+	if inst, ok := h.instances[name]; ok {
+		for _, m := range inst.Mounts {
+			paths = append(paths, m.InstancePath)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// withoutMount returns mounts minus the one at instancePath, in a new slice
+// so copies handed out by instance stay untouched.
+func withoutMount(mounts []models.Mount, instancePath string) []models.Mount {
+	var kept []models.Mount
+	for _, m := range mounts {
+		if m.InstancePath != instancePath {
+			kept = append(kept, m)
+		}
+	}
+	return kept
+}
+
+// removeAlias deletes an alias behind Terraform's back.
+func (h *fakeHost) removeAlias(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.aliases, name)
+}
+
+func (h *fakeHost) VersionInfo(context.Context) (models.VersionInfo, error) {
+	return models.VersionInfo{Client: "1.15.0", Daemon: "1.15.0"}, nil
+}
+
+// GetSetting only knows local.driver, which the provider reads during
+// configuration.
+func (h *fakeHost) GetSetting(_ context.Context, key string) (string, error) {
+	if key == "local.driver" {
+		return "qemu", nil
+	}
+	return "", multipasscli.ErrNotFound
+}
+
+func (h *fakeHost) ListInstances(context.Context, bool) ([]models.Instance, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	instances := make([]models.Instance, 0, len(h.instances))
+	for _, inst := range h.instances {
+		instances = append(instances, *inst)
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].Name < instances[j].Name })
+	return instances, nil
+}
+
+func (h *fakeHost) GetInstance(_ context.Context, name string) (*models.Instance, error) {
+	if inst := h.instance(name); inst != nil {
+		return inst, nil
+	}
+	return nil, multipasscli.ErrNotFound
+}
+
+func (h *fakeHost) LaunchInstance(_ context.Context, opts models.LaunchOptions) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.instances[opts.Name]; ok {
+		return fmt.Errorf("instance %q already exists", opts.Name)
+	}
+	h.nextIP++
+	h.instances[opts.Name] = &models.Instance{
+		Name:         opts.Name,
+		State:        "Running",
+		Release:      "Ubuntu 24.04 LTS",
+		ImageRelease: "24.04 LTS",
+		IPv4:         []string{fmt.Sprintf("10.0.0.%d", h.nextIP)},
+		CPUCount:     opts.CPUs,
+		Mounts:       append([]models.Mount(nil), opts.Mounts...),
+		LastUpdated:  time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	h.launches[opts.Name]++
+	if opts.Primary {
+		h.primary = opts.Name
+	}
+	return nil
+}
+
+func (h *fakeHost) Mount(_ context.Context, name string, mount models.Mount) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	inst, ok := h.instances[name]
+	if !ok {
+		return multipasscli.ErrNotFound
+	}
+	inst.Mounts = append(withoutMount(inst.Mounts, mount.InstancePath), mount)
+	return nil
+}
+
+// Unmount removes the mount at mount.InstancePath, or every mount when it
+// is empty.
+func (h *fakeHost) Unmount(_ context.Context, name string, mount models.Mount) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	inst, ok := h.instances[name]
+	if !ok {
+		return multipasscli.ErrNotFound
+	}
+	if mount.InstancePath == "" {
+		inst.Mounts = nil
+	} else {
+		inst.Mounts = withoutMount(inst.Mounts, mount.InstancePath)
+	}
+	return nil
+}
+
+func (h *fakeHost) StartInstance(_ context.Context, name string) error {
+	return h.transition(name, "Running")
+}
+
+func (h *fakeHost) StopInstance(_ context.Context, name string, _ bool) error {
+	return h.transition(name, "Stopped")
+}
+
+func (h *fakeHost) SuspendInstance(_ context.Context, name string) error {
+	return h.transition(name, "Suspended")
+}
+
+func (h *fakeHost) transition(name, state string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	inst, ok := h.instances[name]
+	if !ok {
+		return multipasscli.ErrNotFound
+	}
+	if inst.State == "Deleted" {
+		return fmt.Errorf("instance %q is deleted", name)
+	}
+	inst.State = state
+	return nil
+}
+
+func (h *fakeHost) DeleteInstance(_ context.Context, name string, purge bool) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	inst, ok := h.instances[name]
+	if !ok {
+		return multipasscli.ErrNotFound
+	}
+	if purge {
+		delete(h.instances, name)
+	} else {
+		inst.State = "Deleted"
+	}
+	return nil
+}
+
+func (h *fakeHost) RecoverInstance(_ context.Context, name string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	inst, ok := h.instances[name]
+	if !ok {
+		return multipasscli.ErrNotFound
+	}
+	if inst.State == "Deleted" {
+		inst.State = "Stopped"
+	}
+	return nil
+}
+
+func (h *fakeHost) SetPrimary(_ context.Context, name string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.primary = name
+	return nil
+}
+
+func (h *fakeHost) ActiveAliasContext(context.Context) (string, error) {
+	return multipasscli.DefaultAliasContext, nil
+}
+
+func (h *fakeHost) ListAliases(context.Context, bool) ([]models.Alias, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	aliases := make([]models.Alias, 0, len(h.aliases))
+	for _, alias := range h.aliases {
+		aliases = append(aliases, alias)
+	}
+	sort.Slice(aliases, func(i, j int) bool { return aliases[i].Name < aliases[j].Name })
+	return aliases, nil
+}
+
+func (h *fakeHost) CreateAlias(_ context.Context, alias models.Alias) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.aliases[alias.Name]; ok {
+		return fmt.Errorf("alias %q already exists", alias.Name)
+	}
+	if _, ok := h.instances[alias.Instance]; !ok {
+		return fmt.Errorf("instance %q does not exist", alias.Instance)
+	}
+	if alias.Context == "" {
+		alias.Context = multipasscli.DefaultAliasContext
+	}
+	h.aliases[alias.Name] = alias
+	return nil
+}
+
+func (h *fakeHost) DeleteAlias(_ context.Context, name string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.aliases[name]; !ok {
+		return multipasscli.ErrNotFound
+	}
+	delete(h.aliases, name)
+	return nil
+}
@@ -0,0 +1,49 @@
+package provider
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/go-version"
+)
+
+// Features that only some multipass releases support.
+const (
+	featureClone     = "clone"
+	featureSnapshots = "snapshots"
+)
+
+// featureMinimumVersions maps each feature to the first multipass release
+// that ships it.
+var featureMinimumVersions = map[string]*version.Version{
+	featureClone:     version.Must(version.NewVersion("1.15.0")),
+	featureSnapshots: version.Must(version.NewVersion("1.13.0")),
+}
+
+// SupportsFeature reports whether the multipass version detected during
+// provider configuration supports feature. See supportsFeature.
+func (d providerData) SupportsFeature(feature string) bool {
+	return supportsFeature(d.multipassVersion, feature)
+}
+
+// supportsFeature reports whether current supports feature. An unknown
+// version, as with defer_binary_check or an unparsable development build,
+// supports every feature so the CLI gets to report what it cannot do.
+// Unknown features are never supported.
+func supportsFeature(current *version.Version, feature string) bool {
+	minimum, ok := featureMinimumVersions[feature]
+	if !ok {
+		return false
+	}
+	if current == nil {
+		return true
+	}
+	return current.Core().GreaterThanOrEqual(minimum)
+}
+
+// unsupportedFeatureDetail explains that current is too old for feature.
+func unsupportedFeatureDetail(current *version.Version, feature string) string {
+	return fmt.Sprintf(
+		"The %s feature requires multipass %s or later, but the provider detected %s. Upgrade multipass on the host.",
+		feature, featureMinimumVersions[feature].String(), current.Original(),
+	)
+}
@@ -0,0 +1,84 @@
+package provider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+func TestSupportsFeature(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		version string
+		feature string
+		want    bool
+	}{
+		{name: "at minimum", version: "1.15.0", feature: featureClone, want: true},
+		{name: "newer", version: "1.16.1", feature: featureClone, want: true},
+		{name: "older", version: "1.14.1", feature: featureClone, want: false},
+		{name: "development build", version: "1.15.0-dev.123+g456", feature: featureClone, want: true},
+		{name: "snapshots", version: "1.13.0", feature: featureSnapshots, want: true},
+		{name: "unknown version", feature: featureClone, want: true},
+		{name: "unknown feature", version: "1.16.0", feature: "teleport", want: false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var data providerData
+			if tc.version != "" {
+				data.multipassVersion = version.Must(version.NewVersion(tc.version))
+			}
+			if got := data.SupportsFeature(tc.feature); got != tc.want {
+				t.Fatalf("SupportsFeature(%q) with %q = %v, want %v", tc.feature, tc.version, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCloneCreateUnsupportedVersion(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	client := cloneHost(map[string]string{"golden": "Running"})
+	r := &cloneResource{client: client, commandTimeout: time.Minute, multipassVersion: version.Must(version.NewVersion("1.14.1"))}
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	plan := cloneResourceModel{
+		ID:             types.StringUnknown(),
+		Name:           types.StringValue("worker-1"),
+		SourceInstance: types.StringValue("golden"),
+		StopSource:     types.BoolNull(),
+		Start:          types.BoolNull(),
+		PurgeOnDelete:  types.BoolNull(),
+		IPv4:           types.ListUnknown(types.StringType),
+		IPv6:           types.ListUnknown(types.StringType),
+		State:          types.StringUnknown(),
+		Release:        types.StringUnknown(),
+		Timeouts:       nullCloneTimeouts,
+	}
+	tfPlan := tfsdk.Plan{Schema: schemaResp.Schema}
+	if diags := tfPlan.Set(ctx, &plan); diags.HasError() {
+		t.Fatalf("set plan: %v", diags)
+	}
+	resp := resource.CreateResponse{State: tfsdk.State{Schema: schemaResp.Schema, Raw: tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil)}}
+	r.Create(ctx, resource.CreateRequest{Plan: tfPlan}, &resp)
+
+	if !resp.Diagnostics.HasError() || resp.Diagnostics.Errors()[0].Summary() != "Clone not supported" {
+		t.Fatalf("expected the clone to be rejected, got %v", resp.Diagnostics)
+	}
+	want := "The clone feature requires multipass 1.15.0 or later, but the provider detected 1.14.1. Upgrade multipass on the host."
+	if got := resp.Diagnostics.Errors()[0].Detail(); got != want {
+		t.Fatalf("unexpected detail:\n got %q\nwant %q", got, want)
+	}
+	if calls := client.recorded(); len(calls) != 0 {
+		t.Fatalf("expected no commands, got %v", calls)
+	}
+}
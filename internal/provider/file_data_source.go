@@ -0,0 +1,224 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+var (
+	_ datasource.DataSource              = (*fileDataSource)(nil)
+	_ datasource.DataSourceWithConfigure = (*fileDataSource)(nil)
+)
+
+// NewFileDataSource returns the file data source.
+func NewFileDataSource() datasource.DataSource {
+	return &fileDataSource{}
+}
+
+type fileDataSource struct {
+	client multipasscli.Client
+}
+
+type fileDataSourceModel struct {
+	Instance               types.String `tfsdk:"instance"`
+	Path                   types.String `tfsdk:"path"`
+	MaxSize                types.Int64  `tfsdk:"max_size"`
+	AllowMissing           types.Bool   `tfsdk:"allow_missing"`
+	Sensitive              types.Bool   `tfsdk:"sensitive"`
+	Exists                 types.Bool   `tfsdk:"exists"`
+	Content                types.String `tfsdk:"content"`
+	ContentBase64          types.String `tfsdk:"content_base64"`
+	SensitiveContent       types.String `tfsdk:"sensitive_content"`
+	SensitiveContentBase64 types.String `tfsdk:"sensitive_content_base64"`
+	SHA256                 types.String `tfsdk:"sha256"`
+}
+
+func (d *fileDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_file"
+}
+
+func (d *fileDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Reads a small file from inside a Multipass instance without writing it to the host.",
+		Attributes: map[string]schema.Attribute{
+			"instance": schema.StringAttribute{
+				Required:    true,
+				Description: "Instance to read the file from.",
+			},
+			"path": schema.StringAttribute{
+				Required:    true,
+				Description: "Absolute path of the file inside the instance; `~/` expands to `/home/ubuntu/`.",
+			},
+			"max_size": schema.Int64Attribute{
+				Optional:    true,
+				Description: "Largest file size in bytes that may be read; larger files fail the read. Defaults to 65536.",
+				Validators: []validator.Int64{
+					int64validator.AtLeast(0),
+				},
+			},
+			"allow_missing": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Return `exists = false` with null content instead of failing when the file does not exist (default: false).",
+			},
+			"sensitive": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Expose the file through sensitive_content and sensitive_content_base64 instead of content and content_base64 (default: false).",
+			},
+			"exists": schema.BoolAttribute{
+				Computed:    true,
+				Description: "Whether the file exists.",
+			},
+			"content": schema.StringAttribute{
+				Computed:    true,
+				Description: "File contents; null when the file is not valid UTF-8 or sensitive is set.",
+			},
+			"content_base64": schema.StringAttribute{
+				Computed:    true,
+				Description: "Base64-encoded file contents; null when sensitive is set.",
+			},
+			"sensitive_content": schema.StringAttribute{
+				Computed:    true,
+				Sensitive:   true,
+				Description: "File contents when sensitive is set; null when the file is not valid UTF-8.",
+			},
+			"sensitive_content_base64": schema.StringAttribute{
+				Computed:    true,
+				Sensitive:   true,
+				Description: "Base64-encoded file contents when sensitive is set.",
+			},
+			"sha256": schema.StringAttribute{
+				Computed:    true,
+				Description: "SHA256 of the file contents.",
+			},
+		},
+	}
+}
+
+func (d *fileDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, _ *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	data := req.ProviderData.(providerData)
+	d.client = data.client
+}
+
+// remoteSizeScript prints the size of a remote regular file, "dir" for a
+// directory or "missing" when the path does not exist.
+const remoteSizeScript = `p="$1"
+if [ -d "$p" ]; then
+  echo dir
+elif [ -e "$p" ]; then
+  wc -c < "$p"
+else
+  echo missing
+fi`
+
+func (d *fileDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	if d.client == nil {
+		resp.Diagnostics.AddError("Client not configured", "Multipass client is nil.")
+		return
+	}
+
+	var config fileDataSourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	instance := config.Instance.ValueString()
+	remotePath, err := resolveRemotePath(config.Path.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("path"), "Invalid path", err.Error())
+		return
+	}
+	limit := valueOrDefaultInt(config.MaxSize, defaultMaxInlineSize)
+
+	config.Exists = types.BoolValue(false)
+	config.Content = types.StringNull()
+	config.ContentBase64 = types.StringNull()
+	config.SensitiveContent = types.StringNull()
+	config.SensitiveContentBase64 = types.StringNull()
+	config.SHA256 = types.StringNull()
+
+	// Check the size before transferring so a large file is never pulled
+	// into memory.
+	out, err := d.client.ExecCapture(ctx, instance, []string{"sh", "-c", remoteSizeScript, "sh", remotePath})
+	if err != nil {
+		// exec does not unwrap ErrNotFound like the typed lookups do.
+		if errors.Is(err, multipasscli.ErrNotFound) {
+			resp.Diagnostics.AddError("Instance not found", fmt.Sprintf("Instance %q does not exist.", instance))
+			return
+		}
+		resp.Diagnostics.AddError("Failed to inspect remote file", err.Error())
+		return
+	}
+	switch size := strings.TrimSpace(string(out)); size {
+	case "missing":
+		if config.AllowMissing.ValueBool() {
+			resp.Diagnostics.Append(resp.State.Set(ctx, &config)...)
+			return
+		}
+		resp.Diagnostics.AddError("Remote file not found", fmt.Sprintf("%s does not exist inside %q. Set allow_missing = true to tolerate this.", remotePath, instance))
+		return
+	case "dir":
+		resp.Diagnostics.AddAttributeError(path.Root("path"), "Remote path is a directory", fmt.Sprintf("%s is a directory inside %q; only regular files can be read.", remotePath, instance))
+		return
+	default:
+		n, err := strconv.Atoi(size)
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to inspect remote file", fmt.Sprintf("unexpected size output %q for %s", size, remotePath))
+			return
+		}
+		if n > limit {
+			resp.Diagnostics.AddError("Remote file too large", fmt.Sprintf("%s inside %q is %d bytes, more than max_size (%d).", remotePath, instance, n, limit))
+			return
+		}
+	}
+
+	data, err := d.client.TransferCapture(ctx, multipasscli.TransferOptions{
+		Sources:     []string{fmt.Sprintf("%s:%s", instance, remotePath)},
+		Destination: "-",
+	})
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read remote file", err.Error())
+		return
+	}
+	if len(data) > limit {
+		// The file grew between the size check and the transfer.
+		resp.Diagnostics.AddError("Remote file too large", fmt.Sprintf("%s inside %q is %d bytes, more than max_size (%d).", remotePath, instance, len(data), limit))
+		return
+	}
+
+	content := types.StringNull()
+	// Terraform strings must be valid UTF-8; binary files are only
+	// available base64-encoded.
+	if utf8.Valid(data) {
+		content = types.StringValue(string(data))
+	}
+	encoded := types.StringValue(base64.StdEncoding.EncodeToString(data))
+	if config.Sensitive.ValueBool() {
+		config.SensitiveContent = content
+		config.SensitiveContentBase64 = encoded
+	} else {
+		config.Content = content
+		config.ContentBase64 = encoded
+	}
+	config.Exists = types.BoolValue(true)
+	config.SHA256 = types.StringValue(hashBytes(data))
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &config)...)
+}
@@ -0,0 +1,144 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+// readFile runs fileDataSource.Read for path on instance "vm" with the given
+// optional attributes set and returns the response.
+func readFile(t *testing.T, client *fakeClient, filePath string, attrs map[string]tftypes.Value) datasource.ReadResponse {
+	t.Helper()
+	ctx := context.Background()
+
+	d := &fileDataSource{client: client}
+	var schemaResp datasource.SchemaResponse
+	d.Schema(ctx, datasource.SchemaRequest{}, &schemaResp)
+
+	configType := schemaResp.Schema.Type().TerraformType(ctx).(tftypes.Object)
+	values := map[string]tftypes.Value{}
+	for name, typ := range configType.AttributeTypes {
+		values[name] = tftypes.NewValue(typ, nil)
+	}
+	values["instance"] = tftypes.NewValue(tftypes.String, "vm")
+	values["path"] = tftypes.NewValue(tftypes.String, filePath)
+	for name, v := range attrs {
+		values[name] = v
+	}
+	config := tfsdk.Config{Schema: schemaResp.Schema, Raw: tftypes.NewValue(configType, values)}
+
+	resp := datasource.ReadResponse{State: tfsdk.State{
+		Schema: schemaResp.Schema,
+		Raw:    tftypes.NewValue(configType, nil),
+	}}
+	d.Read(ctx, datasource.ReadRequest{Config: config}, &resp)
+	return resp
+}
+
+// remoteFileClient serves files from a map keyed by remote path.
+func remoteFileClient(files map[string]string) *fakeClient {
+	return &fakeClient{
+		execCapture: func(_ string, command []string) ([]byte, error) {
+			content, ok := files[command[len(command)-1]]
+			if !ok {
+				return []byte("missing\n"), nil
+			}
+			return []byte(fmt.Sprintf("%d\n", len(content))), nil
+		},
+		transferCapture: func(opts multipasscli.TransferOptions) ([]byte, error) {
+			return []byte(files[strings.TrimPrefix(opts.Sources[0], "vm:")]), nil
+		},
+	}
+}
+
+func TestFileDataSourceRead(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	files := map[string]string{
+		"/var/lib/myapp/token":       "s3cr3t\n",
+		"/home/ubuntu/binary":        "\xff\xfe",
+		"/var/log/cloud-init-output": strings.Repeat("x", 100),
+	}
+
+	t.Run("content", func(t *testing.T) {
+		client := remoteFileClient(files)
+		resp := readFile(t, client, "/var/lib/myapp/token", nil)
+		if resp.Diagnostics.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+		}
+		var state fileDataSourceModel
+		resp.State.Get(ctx, &state)
+		if !state.Exists.ValueBool() || state.Content.ValueString() != "s3cr3t\n" || state.ContentBase64.ValueString() != "czNjcjN0Cg==" ||
+			state.SHA256.ValueString() != hashBytes([]byte("s3cr3t\n")) || !state.SensitiveContent.IsNull() {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+		want := []string{
+			"exec-capture vm sh -c " + remoteSizeScript + " sh /var/lib/myapp/token",
+			"transfer-capture vm:/var/lib/myapp/token",
+		}
+		if diff := cmp.Diff(want, client.recorded()); diff != "" {
+			t.Fatalf("unexpected calls (-want +got): %s", diff)
+		}
+	})
+
+	t.Run("sensitive", func(t *testing.T) {
+		resp := readFile(t, remoteFileClient(files), "/var/lib/myapp/token", map[string]tftypes.Value{"sensitive": tftypes.NewValue(tftypes.Bool, true)})
+		if resp.Diagnostics.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+		}
+		var state fileDataSourceModel
+		resp.State.Get(ctx, &state)
+		if !state.Content.IsNull() || !state.ContentBase64.IsNull() || state.SensitiveContent.ValueString() != "s3cr3t\n" || state.SensitiveContentBase64.ValueString() != "czNjcjN0Cg==" {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+
+	t.Run("binary under home", func(t *testing.T) {
+		resp := readFile(t, remoteFileClient(files), "~/binary", nil)
+		if resp.Diagnostics.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+		}
+		var state fileDataSourceModel
+		resp.State.Get(ctx, &state)
+		if !state.Content.IsNull() || state.ContentBase64.ValueString() != "//4=" {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		client := remoteFileClient(files)
+		resp := readFile(t, client, "/var/log/cloud-init-output", map[string]tftypes.Value{"max_size": tftypes.NewValue(tftypes.Number, 10)})
+		if !resp.Diagnostics.HasError() || !strings.Contains(resp.Diagnostics.Errors()[0].Detail(), "more than max_size") {
+			t.Fatalf("expected a size error, got %v", resp.Diagnostics)
+		}
+		if calls := client.recorded(); len(calls) != 1 {
+			t.Fatalf("expected no transfer for an oversized file, got %v", calls)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		if resp := readFile(t, remoteFileClient(files), "/nope", nil); !resp.Diagnostics.HasError() {
+			t.Fatalf("expected an error for a missing file")
+		}
+
+		resp := readFile(t, remoteFileClient(files), "/nope", map[string]tftypes.Value{"allow_missing": tftypes.NewValue(tftypes.Bool, true)})
+		if resp.Diagnostics.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+		}
+		var state fileDataSourceModel
+		resp.State.Get(ctx, &state)
+		if state.Exists.ValueBool() || !state.Content.IsNull() || !state.SHA256.IsNull() {
+			t.Fatalf("unexpected state: %+v", state)
+		}
+	})
+}
@@ -4,34 +4,49 @@ import (
 	"archive/tar"
 	"bytes"
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/hashicorp/terraform-plugin-framework-timeouts/resource/timeouts"
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/listvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
 	"github.com/hashicorp/terraform-plugin-framework/diag"
 	frameworkpath "github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
-	"github.com/hashicorp/terraform-plugin-framework/resource/schema/mapplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/listplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
 	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
 )
 
 var (
-	_ resource.Resource                = (*fileDownloadResource)(nil)
-	_ resource.ResourceWithConfigure   = (*fileDownloadResource)(nil)
-	_ resource.ResourceWithModifyPlan  = (*fileDownloadResource)(nil)
-	_ resource.ResourceWithImportState = (*fileDownloadResource)(nil)
+	_ resource.Resource                 = (*fileDownloadResource)(nil)
+	_ resource.ResourceWithConfigure    = (*fileDownloadResource)(nil)
+	_ resource.ResourceWithModifyPlan   = (*fileDownloadResource)(nil)
+	_ resource.ResourceWithImportState  = (*fileDownloadResource)(nil)
+	_ resource.ResourceWithUpgradeState = (*fileDownloadResource)(nil)
 )
 
 // NewFileDownloadResource registers the download resource with the provider.
@@ -40,22 +55,42 @@ func NewFileDownloadResource() resource.Resource {
 }
 
 type fileDownloadResource struct {
-	client         multipasscli.Client
-	hostOS         string
-	commandTimeout time.Duration
+	client           multipasscli.Client
+	hostOS           string
+	transferStrategy string
+	commandTimeout   time.Duration
 }
 
 type fileDownloadResourceModel struct {
-	ID            types.String   `tfsdk:"id"`
-	Instance      types.String   `tfsdk:"instance"`
-	Source        types.String   `tfsdk:"source"`
-	Destination   types.String   `tfsdk:"destination"`
-	Recursive     types.Bool     `tfsdk:"recursive"`
-	CreateParents types.Bool     `tfsdk:"create_parents"`
-	Overwrite     types.Bool     `tfsdk:"overwrite"`
-	Triggers      types.Map      `tfsdk:"triggers"`
-	ContentHash   types.String   `tfsdk:"content_hash"`
-	Timeouts      timeouts.Value `tfsdk:"timeouts"`
+	ID              types.String   `tfsdk:"id"`
+	Instance        types.String   `tfsdk:"instance"`
+	Source          types.String   `tfsdk:"source"`
+	Sources         types.List     `tfsdk:"sources"`
+	SourceGlob      types.String   `tfsdk:"source_glob"`
+	AllowEmptyGlob  types.Bool     `tfsdk:"allow_empty_glob"`
+	MatchedSources  types.List     `tfsdk:"matched_sources"`
+	Destination     types.String   `tfsdk:"destination"`
+	Recursive       types.Bool     `tfsdk:"recursive"`
+	CreateParents   types.Bool     `tfsdk:"create_parents"`
+	Overwrite       types.Bool     `tfsdk:"overwrite"`
+	Triggers        types.Map      `tfsdk:"triggers"`
+	WaitForInstance types.Bool     `tfsdk:"wait_for_instance"`
+	StartIfStopped  types.Bool     `tfsdk:"start_if_stopped"`
+	WaitTimeout     types.Int64    `tfsdk:"wait_timeout"`
+	RefreshOnChange types.Bool     `tfsdk:"refresh_on_change"`
+	MaxInlineSize   types.Int64    `tfsdk:"max_inline_size"`
+	FileMode        types.String   `tfsdk:"file_mode"`
+	DirMode         types.String   `tfsdk:"dir_mode"`
+	PreserveMode    types.Bool     `tfsdk:"preserve_mode"`
+	ExpectedSHA256  types.String   `tfsdk:"expected_sha256"`
+	RetainOnDestroy types.Bool     `tfsdk:"retain_on_destroy"`
+	UseSudo         types.Bool     `tfsdk:"use_sudo"`
+	ContentHash     types.String   `tfsdk:"content_hash"`
+	Content         types.String   `tfsdk:"content"`
+	ContentBase64   types.String   `tfsdk:"content_base64"`
+	SourceModified  types.String   `tfsdk:"source_last_modified"`
+	DownloadedAt    types.String   `tfsdk:"downloaded_at"`
+	Timeouts        timeouts.Value `tfsdk:"timeouts"`
 }
 
 func (r *fileDownloadResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
@@ -63,13 +98,23 @@ func (r *fileDownloadResource) Metadata(_ context.Context, req resource.Metadata
 }
 
 func (r *fileDownloadResource) Schema(ctx context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	oneOf := []frameworkpath.Expression{
+		frameworkpath.MatchRelative().AtParent().AtName("source"),
+		frameworkpath.MatchRelative().AtParent().AtName("sources"),
+		frameworkpath.MatchRelative().AtParent().AtName("source_glob"),
+	}
+
 	resp.Schema = schema.Schema{
+		// Version 1: triggers changes update in place instead of
+		// replacing the resource.
+		// Version 2: id escapes source and destination.
+		Version:     2,
 		Description: "Downloads files or directories from Multipass instances to the host using `multipass transfer`.",
 		Attributes: map[string]schema.Attribute{
 			"id": schema.StringAttribute{
 				Computed:            true,
-				Description:         "Identifier in the form `<instance>:<source>-><destination>`.",
-				MarkdownDescription: "Identifier in the form `<instance>:<source>-><destination>`.",
+				Description:         "Identifier in the form `<instance>:<source>:<destination>`, with the paths URL-escaped.",
+				MarkdownDescription: "Identifier in the form `<instance>:<source>:<destination>`, with both paths URL-escaped (including `:`) so that Windows destinations split unambiguously.",
 				PlanModifiers: []planmodifier.String{
 					stringplanmodifier.UseStateForUnknown(),
 				},
@@ -83,12 +128,53 @@ func (r *fileDownloadResource) Schema(ctx context.Context, _ resource.SchemaRequ
 				},
 			},
 			"source": schema.StringAttribute{
-				Required:            true,
+				Optional:            true,
 				Description:         "Path inside the instance to download.",
-				MarkdownDescription: "Path inside the instance to download.",
+				MarkdownDescription: "Path inside the instance to download. Exactly one of `source`, `sources` or `source_glob` is required.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+				Validators: []validator.String{
+					stringvalidator.ExactlyOneOf(oneOf...),
+				},
+			},
+			"sources": schema.ListAttribute{
+				Optional:            true,
+				ElementType:         types.StringType,
+				Description:         "Paths inside the instance downloaded into the destination directory.",
+				MarkdownDescription: "Paths inside the instance downloaded into the `destination` directory, each under its base name.",
+				PlanModifiers: []planmodifier.List{
+					listplanmodifier.RequiresReplace(),
+				},
+				Validators: []validator.List{
+					listvalidator.ExactlyOneOf(oneOf...),
+					listvalidator.SizeAtLeast(1),
+				},
+			},
+			"source_glob": schema.StringAttribute{
+				Optional:            true,
+				Description:         "Shell glob expanded inside the instance; every match is downloaded into the destination directory.",
+				MarkdownDescription: "Shell glob (e.g. `/var/log/myapp/*.log`) expanded inside the instance at apply time. Every match is downloaded into the `destination` directory under its base name.",
 				PlanModifiers: []planmodifier.String{
 					stringplanmodifier.RequiresReplace(),
 				},
+				Validators: []validator.String{
+					stringvalidator.ExactlyOneOf(oneOf...),
+				},
+			},
+			"allow_empty_glob": schema.BoolAttribute{
+				Optional:            true,
+				Description:         "Succeed with an empty destination directory when source_glob matches nothing.",
+				MarkdownDescription: "Succeed with an empty `destination` directory when `source_glob` matches nothing instead of failing. Defaults to `false`.",
+			},
+			"matched_sources": schema.ListAttribute{
+				Computed:            true,
+				ElementType:         types.StringType,
+				Description:         "Remote paths downloaded for sources or source_glob.",
+				MarkdownDescription: "Remote paths downloaded for `sources` or `source_glob`, in download order. Null for single `source` downloads.",
+				PlanModifiers: []planmodifier.List{
+					listplanmodifier.UseStateForUnknown(),
+				},
 			},
 			"destination": schema.StringAttribute{
 				Required:            true,
@@ -122,10 +208,77 @@ func (r *fileDownloadResource) Schema(ctx context.Context, _ resource.SchemaRequ
 			"triggers": schema.MapAttribute{
 				Optional:            true,
 				ElementType:         types.StringType,
-				Description:         "Map of arbitrary values that, when changed, force the download to re-run (similar to `null_resource.triggers`).",
-				MarkdownDescription: "Map of arbitrary values that, when changed, force the download to re-run (similar to `null_resource.triggers`).",
-				PlanModifiers: []planmodifier.Map{
-					mapplanmodifier.RequiresReplace(),
+				Description:         "Map of arbitrary values that, when changed, re-run the download in place.",
+				MarkdownDescription: "Map of arbitrary values that, when changed, re-run the download in place over the existing destination (similar to `null_resource.triggers`, but without destroying the previous copy first).",
+			},
+			"wait_for_instance": schema.BoolAttribute{
+				Optional:            true,
+				Description:         "Wait for the instance to be running before transferring. Defaults to true.",
+				MarkdownDescription: "Wait for the instance to report `Running` before transferring. Defaults to `true`.",
+			},
+			"start_if_stopped": schema.BoolAttribute{
+				Optional:            true,
+				Description:         "Start the instance if it is stopped or suspended when waiting for it, and return it to that state after the download.",
+				MarkdownDescription: "Start the instance if it is stopped or suspended while `wait_for_instance` is enabled, then stop or suspend it again once the download finishes. Defaults to `false`.",
+			},
+			"wait_timeout": schema.Int64Attribute{
+				Optional:            true,
+				Description:         "Maximum seconds to wait for the instance to be running. Defaults to 300.",
+				MarkdownDescription: "Maximum seconds to wait for the instance to be running. Defaults to `300`.",
+				Validators: []validator.Int64{
+					int64validator.AtLeast(1),
+				},
+			},
+			"refresh_on_change": schema.BoolAttribute{
+				Optional:            true,
+				Description:         "Hash the source inside the instance during refresh and re-download it when it changed.",
+				MarkdownDescription: "Hash `source` inside the instance with `sha256sum` during refresh and plan a re-download when it no longer matches the local copy. Skipped with a warning while the instance is not running. Defaults to `false`.",
+			},
+			"file_mode": schema.StringAttribute{
+				Optional:            true,
+				Description:         "Octal permissions for downloaded files (e.g. 0600). Defaults to 0644 for single files.",
+				MarkdownDescription: "Octal permissions for downloaded files (e.g. `0600`). Single files default to `0644`; files inside downloaded directories keep their source mode unless this is set. Ignored on Windows.",
+				Validators: []validator.String{
+					stringvalidator.RegexMatches(fileModeRegex, "must be an octal mode such as 0644 or 0755"),
+				},
+			},
+			"dir_mode": schema.StringAttribute{
+				Optional:            true,
+				Description:         "Octal permissions for downloaded directories (e.g. 0700).",
+				MarkdownDescription: "Octal permissions for directories created by recursive downloads (e.g. `0700`). Directories keep their source mode unless this is set. Ignored on Windows.",
+				Validators: []validator.String{
+					stringvalidator.RegexMatches(fileModeRegex, "must be an octal mode such as 0755 or 0700"),
+				},
+			},
+			"preserve_mode": schema.BoolAttribute{
+				Optional:            true,
+				Description:         "Use the permissions of the source inside the instance, overriding file_mode and dir_mode.",
+				MarkdownDescription: "Use the permissions of the source inside the instance, taking precedence over `file_mode` and `dir_mode`. Single files are inspected with `stat`. Ignored on Windows. Defaults to `false`.",
+			},
+			"expected_sha256": schema.StringAttribute{
+				Optional:            true,
+				Description:         "SHA256 the downloaded payload must match; the apply fails and the destination is removed otherwise.",
+				MarkdownDescription: "SHA256 the downloaded payload must match. On mismatch the apply fails and the written destination is removed. For recursive, `sources` and `source_glob` downloads it is compared with the directory or combined `content_hash` (without the `v2:` prefix).",
+				Validators: []validator.String{
+					stringvalidator.RegexMatches(sha256Regex, "must be a 64-character hex SHA256 digest"),
+				},
+			},
+			"retain_on_destroy": schema.BoolAttribute{
+				Optional:            true,
+				Description:         "Leave the local destination in place when the resource is destroyed.",
+				MarkdownDescription: "Leave the local destination in place when the resource is destroyed instead of removing it. Defaults to `false`.",
+			},
+			"use_sudo": schema.BoolAttribute{
+				Optional:            true,
+				Description:         "Copy the source to a private staging directory under /tmp with sudo and download the copy.",
+				MarkdownDescription: "Copy the source with `sudo` into a private staging directory under `/tmp`, owned by the default user, and download the copy. Use it for paths the default user cannot read, such as `/var/log/journal` or `/etc/ssl/private`. The staging directory is removed afterwards, also when the download fails. Globs, change detection and `preserve_mode` also run through `sudo`. Requires passwordless sudo in the instance. Defaults to `false`.",
+			},
+			"max_inline_size": schema.Int64Attribute{
+				Optional:            true,
+				Description:         "Largest file size in bytes exposed through content and content_base64. Defaults to 65536.",
+				MarkdownDescription: "Largest file size in bytes exposed through `content` and `content_base64`. Defaults to `65536`; `0` disables inline content.",
+				Validators: []validator.Int64{
+					int64validator.AtLeast(0),
 				},
 			},
 			"content_hash": schema.StringAttribute{
@@ -136,6 +289,40 @@ func (r *fileDownloadResource) Schema(ctx context.Context, _ resource.SchemaRequ
 					stringplanmodifier.UseStateForUnknown(),
 				},
 			},
+			"content": schema.StringAttribute{
+				Computed:            true,
+				Sensitive:           true,
+				Description:         "Downloaded file contents when the file is no larger than max_inline_size.",
+				MarkdownDescription: "Downloaded file contents when the file is no larger than `max_inline_size`; null for directories, larger files and content that is not valid UTF-8.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"content_base64": schema.StringAttribute{
+				Computed:            true,
+				Sensitive:           true,
+				Description:         "Base64-encoded downloaded file contents when the file is no larger than max_inline_size.",
+				MarkdownDescription: "Base64-encoded downloaded file contents, for binary payloads. Populated under the same conditions as `content`.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"source_last_modified": schema.StringAttribute{
+				Computed:            true,
+				Description:         "Modification time of the remote source in RFC3339 format, recorded at download time.",
+				MarkdownDescription: "Modification time of the remote source in RFC3339 format, recorded at download time. With `sources` or `source_glob` this is the newest entry. Null when the instance could not be queried.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"downloaded_at": schema.StringAttribute{
+				Computed:            true,
+				Description:         "Time of the last download in RFC3339 format.",
+				MarkdownDescription: "Time of the last download in RFC3339 format.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
 		},
 		Blocks: map[string]schema.Block{
 			"timeouts": timeouts.Block(ctx, timeouts.Opts{
@@ -154,6 +341,7 @@ func (r *fileDownloadResource) Configure(_ context.Context, req resource.Configu
 	data := req.ProviderData.(providerData)
 	r.client = data.client
 	r.hostOS = data.hostOS
+	r.transferStrategy = data.transferStrategy
 	r.commandTimeout = data.commandTimeout
 }
 
@@ -170,9 +358,26 @@ func (r *fileDownloadResource) ModifyPlan(ctx context.Context, req resource.Modi
 		return
 	}
 
-	if !mapsEqual(ctx, plan.Triggers, state.Triggers) {
-		resp.RequiresReplace = append(resp.RequiresReplace, frameworkpath.Root("triggers"))
+	// Read clears content_hash when refresh_on_change detects a remote
+	// change; an unknown hash turns that into a planned re-download. Trigger
+	// changes re-download in place, so the results are unknown as well.
+	redownload := !mapsEqual(ctx, plan.Triggers, state.Triggers) ||
+		(plan.RefreshOnChange.ValueBool() && state.ContentHash.IsNull())
+	if redownload {
+		plan.ContentHash = types.StringUnknown()
+		plan.SourceModified = types.StringUnknown()
+		plan.DownloadedAt = types.StringUnknown()
+	}
+	if redownload || !plan.MaxInlineSize.Equal(state.MaxInlineSize) {
+		plan.Content = types.StringUnknown()
+		plan.ContentBase64 = types.StringUnknown()
 	}
+	if redownload && !plan.Source.IsNull() {
+		plan.MatchedSources = types.ListNull(types.StringType)
+	} else if redownload {
+		plan.MatchedSources = types.ListUnknown(types.StringType)
+	}
+	resp.Diagnostics.Append(resp.Plan.Set(ctx, &plan)...)
 }
 
 func (r *fileDownloadResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
@@ -187,6 +392,9 @@ func (r *fileDownloadResource) Create(ctx context.Context, req resource.CreateRe
 		return
 	}
 
+	ctx, done := startOperation(ctx, "multipass_file_download", "create", plan.Instance.ValueString())
+	defer done(&resp.Diagnostics)
+
 	createTimeout, diags := plan.Timeouts.Create(ctx, r.commandTimeout)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
@@ -195,12 +403,26 @@ func (r *fileDownloadResource) Create(ctx context.Context, req resource.CreateRe
 	ctx, cancel := context.WithTimeout(ctx, createTimeout)
 	defer cancel()
 
+	prior, waitDiags := waitForTransferInstance(ctx, r.client, plan.Instance.ValueString(), plan.WaitForInstance, plan.StartIfStopped, plan.WaitTimeout)
+	resp.Diagnostics.Append(waitDiags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	defer func() {
+		resp.Diagnostics.Append(restoreInstanceState(ctx, r.client, plan.Instance.ValueString(), prior)...)
+	}()
+
 	if downloadDiags := r.downloadAndWrite(ctx, &plan); downloadDiags.HasError() {
 		resp.Diagnostics.Append(downloadDiags...)
 		return
 	}
+	resp.Diagnostics.Append(checkExpectedSHA256(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.recordDownloadTimes(ctx, &plan)
 
-	plan.ID = types.StringValue(fmt.Sprintf("%s:%s->%s", plan.Instance.ValueString(), plan.Source.ValueString(), plan.Destination.ValueString()))
+	plan.ID = types.StringValue(downloadResourceID(plan.Instance.ValueString(), downloadSourceLabel(ctx, &plan), plan.Destination.ValueString()))
 
 	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
 }
@@ -212,12 +434,16 @@ func (r *fileDownloadResource) Read(ctx context.Context, req resource.ReadReques
 		return
 	}
 
+	ctx, done := startOperation(ctx, "multipass_file_download", "read", state.Instance.ValueString())
+	defer done(&resp.Diagnostics)
+
 	if state.Instance.IsNull() || state.Instance.ValueString() == "" {
 		resp.State.RemoveResource(ctx)
 		return
 	}
 
-	if _, err := r.client.GetInstance(ctx, state.Instance.ValueString()); err != nil {
+	instance, err := r.client.GetInstance(ctx, state.Instance.ValueString())
+	if err != nil {
 		if err == multipasscli.ErrNotFound {
 			resp.State.RemoveResource(ctx)
 			return
@@ -232,9 +458,123 @@ func (r *fileDownloadResource) Read(ctx context.Context, req resource.ReadReques
 		return
 	}
 
+	if state.RefreshOnChange.ValueBool() && !state.ContentHash.IsNull() {
+		resp.Diagnostics.Append(r.detectRemoteChange(ctx, instance, &state)...)
+	}
+
 	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
 }
 
+// detectRemoteChange hashes the source inside the instance and clears
+// content_hash when it no longer matches what was downloaded. Files are
+// compared against content_hash; directories against a sha256sum listing of
+// the local copy, since the directory content_hash also covers metadata
+// that cannot be cheaply reproduced remotely.
+func (r *fileDownloadResource) detectRemoteChange(ctx context.Context, instance *models.Instance, state *fileDownloadResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	source := downloadSourceLabel(ctx, state)
+	if !strings.EqualFold(instance.State, "Running") {
+		diags.AddWarning(
+			"Skipping remote change detection",
+			fmt.Sprintf("Instance %q is %s; changes to %s cannot be detected until it is running.", instance.Name, instance.State, source),
+		)
+		return diags
+	}
+	if state.Source.IsNull() {
+		return r.detectMultiSourceChange(ctx, instance.Name, state)
+	}
+
+	remotePath := source
+	if resolved, err := resolveRemotePath(source); err == nil {
+		remotePath = resolved
+	}
+	actual, exists, err := remoteHash(ctx, r.client, instance.Name, remotePath, state.UseSudo.ValueBool())
+	if err != nil {
+		diags.AddWarning("Failed to check remote source", err.Error())
+		return diags
+	}
+	if !exists {
+		diags.AddWarning("Remote source missing", fmt.Sprintf("%s no longer exists inside %q; keeping the downloaded copy.", source, instance.Name))
+		return diags
+	}
+
+	expected := state.ContentHash.ValueString()
+	if state.Recursive.ValueBool() {
+		expected, err = hashDirectoryManifest(filepath.Clean(state.Destination.ValueString()), nil)
+		if err != nil {
+			diags.AddWarning("Failed to check remote source", fmt.Sprintf("Unable to hash local copy: %s", err))
+			return diags
+		}
+	}
+
+	if actual != expected {
+		tflog.Info(ctx, "Remote source changed; scheduling re-download", map[string]any{
+			"instance": instance.Name,
+			"source":   source,
+		})
+		state.ContentHash = types.StringNull()
+	}
+	return diags
+}
+
+// detectMultiSourceChange re-resolves sources or source_glob and compares
+// each remote path against its local copy. A different set of glob matches
+// also counts as a change.
+func (r *fileDownloadResource) detectMultiSourceChange(ctx context.Context, instance string, state *fileDownloadResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	paths, d := r.resolveDownloadSources(ctx, state)
+	diags.Append(d...)
+	if diags.HasError() {
+		return diags
+	}
+
+	var matched []string
+	if !state.MatchedSources.IsNull() && !state.MatchedSources.IsUnknown() {
+		diags.Append(state.MatchedSources.ElementsAs(ctx, &matched, false)...)
+	}
+	changed := strings.Join(paths, "\x00") != strings.Join(matched, "\x00")
+
+	dest := filepath.Clean(state.Destination.ValueString())
+	for _, remotePath := range paths {
+		if changed {
+			break
+		}
+		actual, exists, err := remoteHash(ctx, r.client, instance, remotePath, state.UseSudo.ValueBool())
+		if err != nil {
+			diags.AddWarning("Failed to check remote source", err.Error())
+			return diags
+		}
+		if !exists {
+			diags.AddWarning("Remote source missing", fmt.Sprintf("%s no longer exists inside %q; keeping the downloaded copy.", remotePath, instance))
+			return diags
+		}
+
+		local := filepath.Join(dest, path.Base(remotePath))
+		var expected string
+		if info, err := os.Stat(local); err == nil && info.IsDir() {
+			expected, err = hashDirectoryManifest(local, nil)
+		} else {
+			expected, err = hashFile(local)
+		}
+		if err != nil {
+			diags.AddWarning("Failed to check remote source", fmt.Sprintf("Unable to hash local copy: %s", err))
+			return diags
+		}
+		changed = actual != expected
+	}
+
+	if changed {
+		tflog.Info(ctx, "Remote sources changed; scheduling re-download", map[string]any{
+			"instance": instance,
+			"source":   downloadSourceLabel(ctx, state),
+		})
+		state.ContentHash = types.StringNull()
+	}
+	return diags
+}
+
 func (r *fileDownloadResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
 	if r.client == nil {
 		resp.Diagnostics.AddError("Client not configured", "The provider Multipass client was not configured.")
@@ -247,6 +587,9 @@ func (r *fileDownloadResource) Update(ctx context.Context, req resource.UpdateRe
 		return
 	}
 
+	ctx, done := startOperation(ctx, "multipass_file_download", "update", plan.Instance.ValueString())
+	defer done(&resp.Diagnostics)
+
 	updateTimeout, diags := plan.Timeouts.Update(ctx, r.commandTimeout)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
@@ -255,10 +598,24 @@ func (r *fileDownloadResource) Update(ctx context.Context, req resource.UpdateRe
 	ctx, cancel := context.WithTimeout(ctx, updateTimeout)
 	defer cancel()
 
+	prior, waitDiags := waitForTransferInstance(ctx, r.client, plan.Instance.ValueString(), plan.WaitForInstance, plan.StartIfStopped, plan.WaitTimeout)
+	resp.Diagnostics.Append(waitDiags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	defer func() {
+		resp.Diagnostics.Append(restoreInstanceState(ctx, r.client, plan.Instance.ValueString(), prior)...)
+	}()
+
 	if downloadDiags := r.downloadAndWrite(ctx, &plan); downloadDiags.HasError() {
 		resp.Diagnostics.Append(downloadDiags...)
 		return
 	}
+	resp.Diagnostics.Append(checkExpectedSHA256(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.recordDownloadTimes(ctx, &plan)
 
 	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
 }
@@ -270,18 +627,234 @@ func (r *fileDownloadResource) Delete(ctx context.Context, req resource.DeleteRe
 		return
 	}
 
+	ctx, done := startOperation(ctx, "multipass_file_download", "delete", state.Instance.ValueString())
+	defer done(&resp.Diagnostics)
+
 	dest := state.Destination.ValueString()
 	if dest == "" {
 		return
 	}
 
+	if state.RetainOnDestroy.ValueBool() {
+		tflog.Info(ctx, "retain_on_destroy set; leaving local destination in place", map[string]any{
+			"destination": dest,
+		})
+		return
+	}
+
+	if reason := unsafeLocalRemovalTarget(dest); reason != "" {
+		resp.Diagnostics.AddWarning(
+			"Refusing to remove destination",
+			fmt.Sprintf("Not removing %q because it is %s; remove it manually if intended.", dest, reason),
+		)
+		return
+	}
+
 	if err := os.RemoveAll(dest); err != nil && !os.IsNotExist(err) {
 		resp.Diagnostics.AddWarning("Failed to remove destination", err.Error())
 	}
 }
 
+// checkExpectedSHA256 compares content_hash with expected_sha256 and
+// removes the destination on mismatch so a bad artifact is never left
+// behind.
+func checkExpectedSHA256(ctx context.Context, model *fileDownloadResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+	if !hasStringValue(model.ExpectedSHA256) {
+		return diags
+	}
+
+	expected := strings.ToLower(model.ExpectedSHA256.ValueString())
+	actual := strings.TrimPrefix(model.ContentHash.ValueString(), directoryHashPrefix)
+	if actual == expected {
+		return diags
+	}
+
+	dest := filepath.Clean(model.Destination.ValueString())
+	if err := os.RemoveAll(dest); err != nil {
+		diags.AddWarning("Failed to remove destination", err.Error())
+	}
+	diags.AddAttributeError(
+		frameworkpath.Root("expected_sha256"),
+		"Downloaded payload does not match expected_sha256",
+		fmt.Sprintf("%s from %q has SHA256 %s, expected %s. The destination %s was removed.",
+			downloadSourceLabel(ctx, model), model.Instance.ValueString(), actual, expected, dest),
+	)
+	return diags
+}
+
+func (r *fileDownloadResource) UpgradeState(ctx context.Context) map[int64]resource.StateUpgrader {
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+	v0 := schemaResp.Schema
+	v0.Version = 0
+	v1 := schemaResp.Schema
+	v1.Version = 1
+
+	// Versions 0 and 1 stored the same attributes. Version 0 only differed
+	// in the triggers plan behaviour; both recorded an unescaped
+	// `<instance>:<source>-><destination>` id, which is rebuilt here.
+	upgrade := func(ctx context.Context, req resource.UpgradeStateRequest, resp *resource.UpgradeStateResponse) {
+		var state fileDownloadResourceModel
+		resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		state.ID = types.StringValue(downloadResourceID(state.Instance.ValueString(), downloadSourceLabel(ctx, &state), state.Destination.ValueString()))
+		resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+	}
+
+	return map[int64]resource.StateUpgrader{
+		0: {PriorSchema: &v0, StateUpgrader: upgrade},
+		1: {PriorSchema: &v1, StateUpgrader: upgrade},
+	}
+}
+
+// unsafeLocalRemovalTarget explains why removing dest recursively would be
+// destructive, or returns "" when it is safe. Filesystem roots, the user's
+// home directory and the working directory are never removed.
+func unsafeLocalRemovalTarget(dest string) string {
+	abs, err := filepath.Abs(dest)
+	if err != nil {
+		return "not resolvable to an absolute path"
+	}
+	if filepath.Dir(abs) == abs {
+		return "a filesystem root"
+	}
+	if home, err := os.UserHomeDir(); err == nil && sameLocalPath(abs, home) {
+		return "the home directory"
+	}
+	if wd, err := os.Getwd(); err == nil && sameLocalPath(abs, wd) {
+		return "the current working directory"
+	}
+	return ""
+}
+
+// sameLocalPath reports whether a and b name the same directory, following
+// symlinks when both exist.
+func sameLocalPath(a, b string) bool {
+	if filepath.Clean(a) == filepath.Clean(b) {
+		return true
+	}
+	ai, errA := os.Stat(a)
+	bi, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(ai, bi)
+}
+
 func (r *fileDownloadResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
-	resp.Diagnostics.AddError("Unsupported import", "multipass_file_download resources cannot be imported.")
+	instance, source, dest, err := parseDownloadImportID(req.ID)
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid import ID", err.Error())
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, frameworkpath.Root("id"), downloadResourceID(instance, source, dest))...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, frameworkpath.Root("instance"), instance)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, frameworkpath.Root("source"), source)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, frameworkpath.Root("destination"), dest)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, frameworkpath.Root("create_parents"), true)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, frameworkpath.Root("overwrite"), true)...)
+	if resp.Diagnostics.HasError() || r.client == nil {
+		return
+	}
+
+	if _, err := r.client.GetInstance(ctx, instance); err != nil {
+		if errors.Is(err, multipasscli.ErrNotFound) {
+			resp.Diagnostics.AddError("Instance not found", fmt.Sprintf("Instance %q does not exist.", instance))
+			return
+		}
+		resp.Diagnostics.AddError("Failed to verify instance", err.Error())
+		return
+	}
+
+	model, diags := inspectImportedDownload(dest)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, frameworkpath.Root("recursive"), model.Recursive)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, frameworkpath.Root("content_hash"), model.ContentHash)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, frameworkpath.Root("content"), model.Content)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, frameworkpath.Root("content_base64"), model.ContentBase64)...)
+}
+
+// downloadResourceID formats the identifier recorded for a download.
+func downloadResourceID(instance, source, dest string) string {
+	return transferResourceID(instance, source, dest)
+}
+
+// parseDownloadImportID accepts the escaped form recorded in id, a
+// human-written `<instance>:<source>:<destination>`, and the
+// `<instance>:<source>-><destination>` id used before schema version 2. In
+// the unescaped forms remote paths cannot contain colons, so everything
+// after the second separator belongs to the destination, which keeps
+// Windows drive letters intact.
+func parseDownloadImportID(id string) (instance, source, dest string, err error) {
+	if instance, paths, ok := parseTransferResourceID(id, 2); ok {
+		return instance, paths[0], paths[1], nil
+	}
+
+	instance, rest, ok := strings.Cut(id, ":")
+	if ok {
+		if source, dest, ok = strings.Cut(rest, "->"); !ok {
+			source, dest, ok = strings.Cut(rest, ":")
+		}
+	}
+	if !ok || instance == "" || source == "" || dest == "" {
+		return "", "", "", fmt.Errorf("expected `<instance>:<source>:<destination>`, got %q", id)
+	}
+	return instance, source, dest, nil
+}
+
+// inspectImportedDownload hashes an existing local destination the same way
+// a download would, so that the imported resource plans without changes.
+func inspectImportedDownload(dest string) (fileDownloadResourceModel, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	model := fileDownloadResourceModel{
+		Recursive:     types.BoolValue(false),
+		MaxInlineSize: types.Int64Null(),
+	}
+
+	info, err := os.Stat(dest)
+	if os.IsNotExist(err) {
+		diags.AddError(
+			"Destination not found",
+			fmt.Sprintf("%s does not exist on this host. Download it first (for example with `multipass transfer`), or remove the import and let Terraform create the resource.", dest),
+		)
+		return model, diags
+	}
+	if err != nil {
+		diags.AddError("Failed to inspect destination", err.Error())
+		return model, diags
+	}
+
+	if info.IsDir() {
+		hashValue, err := hashDirectory(dest)
+		if err != nil {
+			diags.AddError("Failed to hash destination", err.Error())
+			return model, diags
+		}
+		model.Recursive = types.BoolValue(true)
+		model.ContentHash = types.StringValue(hashValue)
+		setInlineContent(&model, nil)
+		return model, diags
+	}
+
+	hashValue, err := hashFile(dest)
+	if err != nil {
+		diags.AddError("Failed to hash destination", err.Error())
+		return model, diags
+	}
+	model.ContentHash = types.StringValue(hashValue)
+	var data []byte
+	if info.Size() <= defaultMaxInlineSize {
+		if data, err = os.ReadFile(dest); err != nil {
+			diags.AddError("Failed to read destination", err.Error())
+			return model, diags
+		}
+	}
+	setInlineContent(&model, data)
+	return model, diags
 }
 
 func (r *fileDownloadResource) downloadAndWrite(ctx context.Context, model *fileDownloadResourceModel) diag.Diagnostics {
@@ -293,12 +866,165 @@ func (r *fileDownloadResource) downloadAndWrite(ctx context.Context, model *file
 		return diags
 	}
 
-	if r.hostOS == "windows" {
+	if model.Source.IsNull() {
+		return r.downloadMany(ctx, model, dest)
+	}
+
+	model.MatchedSources = types.ListNull(types.StringType)
+	if model.UseSudo.ValueBool() {
+		return r.downloadWithSudo(ctx, model, dest)
+	}
+	if r.transferStrategy == transferStrategyTar {
 		return r.downloadWithTar(ctx, model, dest)
 	}
 	return r.downloadDirect(ctx, model, dest)
 }
 
+// remoteGlobScript prints every path matching the glob "$1", one per line.
+// Unmatched patterns expand to themselves and are filtered out.
+const remoteGlobScript = `for p in $1; do if [ -e "$p" ] || [ -L "$p" ]; then printf '%s\n' "$p"; fi; done`
+
+// resolveDownloadSources returns the remote paths selected by sources, or
+// by expanding source_glob inside the instance.
+func (r *fileDownloadResource) resolveDownloadSources(ctx context.Context, model *fileDownloadResourceModel) ([]string, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	if !model.Sources.IsNull() {
+		var sources []string
+		diags.Append(model.Sources.ElementsAs(ctx, &sources, false)...)
+		for i, source := range sources {
+			if resolved, err := resolveRemotePath(source); err == nil {
+				sources[i] = resolved
+			}
+		}
+		return sources, diags
+	}
+
+	pattern := model.SourceGlob.ValueString()
+	if resolved, err := resolveRemotePath(pattern); err == nil {
+		pattern = resolved
+	}
+	out, err := r.client.ExecCapture(ctx, model.Instance.ValueString(), sudoCommand(model.UseSudo.ValueBool(), "sh", "-c", remoteGlobScript, "sh", pattern))
+	if err != nil {
+		diags.AddError("Failed to expand source_glob", err.Error())
+		return nil, diags
+	}
+	var matches []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line != "" {
+			matches = append(matches, line)
+		}
+	}
+	sort.Strings(matches)
+	return matches, diags
+}
+
+// downloadMany downloads every entry of sources or source_glob into the
+// destination directory under its base name. content_hash combines the hash
+// of each entry, in order.
+func (r *fileDownloadResource) downloadMany(ctx context.Context, model *fileDownloadResourceModel, dest string) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	paths, d := r.resolveDownloadSources(ctx, model)
+	diags.Append(d...)
+	if diags.HasError() {
+		return diags
+	}
+
+	if len(paths) == 0 && !model.AllowEmptyGlob.ValueBool() {
+		diags.AddAttributeError(
+			frameworkpath.Root("source_glob"),
+			"No files matched source_glob",
+			fmt.Sprintf("%q matched nothing inside %q. Set allow_empty_glob = true to accept an empty result.", model.SourceGlob.ValueString(), model.Instance.ValueString()),
+		)
+		return diags
+	}
+
+	seen := make(map[string]string, len(paths))
+	for _, remotePath := range paths {
+		base := path.Base(remotePath)
+		if other, ok := seen[base]; ok {
+			diags.AddError("Conflicting source names", fmt.Sprintf("%s and %s would both be written to %s", other, remotePath, filepath.Join(dest, base)))
+			return diags
+		}
+		seen[base] = remotePath
+	}
+
+	if err := ensureParentDir(dest, model.CreateParents.ValueBool()); err != nil {
+		diags.AddError("Failed to prepare destination", err.Error())
+		return diags
+	}
+	if err := os.MkdirAll(dest, 0o755); err != nil {
+		diags.AddError("Failed to prepare destination", err.Error())
+		return diags
+	}
+
+	var combined strings.Builder
+	for _, remotePath := range paths {
+		entry := *model
+		entry.Source = types.StringValue(remotePath)
+		entry.Destination = types.StringValue(filepath.Join(dest, path.Base(remotePath)))
+		diags.Append(r.downloadAndWrite(ctx, &entry)...)
+		if diags.HasError() {
+			return diags
+		}
+		fmt.Fprintf(&combined, "%s\x00%s\n", path.Base(remotePath), entry.ContentHash.ValueString())
+	}
+
+	matched, d := types.ListValueFrom(ctx, types.StringType, paths)
+	diags.Append(d...)
+	model.MatchedSources = matched
+	model.ContentHash = types.StringValue(hashBytes([]byte(combined.String())))
+	setInlineContent(model, nil)
+	return diags
+}
+
+// recordDownloadTimes sets downloaded_at and source_last_modified after a
+// successful download. source_last_modified is the newest mtime among the
+// downloaded remote paths and is left null when stat fails inside the
+// instance, for example because it was stopped in the meantime.
+func (r *fileDownloadResource) recordDownloadTimes(ctx context.Context, model *fileDownloadResourceModel) {
+	model.DownloadedAt = types.StringValue(time.Now().UTC().Format(time.RFC3339))
+	model.SourceModified = types.StringNull()
+
+	var paths []string
+	if !model.Source.IsNull() {
+		source := model.Source.ValueString()
+		if resolved, err := resolveRemotePath(source); err == nil {
+			source = resolved
+		}
+		paths = []string{source}
+	} else if !model.MatchedSources.IsNull() && !model.MatchedSources.IsUnknown() {
+		_ = model.MatchedSources.ElementsAs(ctx, &paths, false)
+	}
+	if len(paths) == 0 {
+		return
+	}
+
+	modified, err := remoteLastModified(ctx, r.client, model.Instance.ValueString(), paths, model.UseSudo.ValueBool())
+	if err != nil {
+		tflog.Warn(ctx, "Unable to read source modification time", map[string]any{
+			"instance": model.Instance.ValueString(),
+			"error":    err.Error(),
+		})
+		return
+	}
+	model.SourceModified = types.StringValue(modified.UTC().Format(time.RFC3339))
+}
+
+// downloadSourceLabel describes the configured source for IDs and messages.
+func downloadSourceLabel(ctx context.Context, model *fileDownloadResourceModel) string {
+	switch {
+	case !model.Source.IsNull():
+		return model.Source.ValueString()
+	case !model.SourceGlob.IsNull():
+		return model.SourceGlob.ValueString()
+	}
+	var sources []string
+	_ = model.Sources.ElementsAs(ctx, &sources, false)
+	return strings.Join(sources, ",")
+}
+
 func (r *fileDownloadResource) downloadDirect(ctx context.Context, model *fileDownloadResourceModel, dest string) diag.Diagnostics {
 	var diags diag.Diagnostics
 
@@ -333,26 +1059,115 @@ func (r *fileDownloadResource) downloadDirect(ctx context.Context, model *fileDo
 		if diags.HasError() {
 			return diags
 		}
+		if err := r.applyDirectoryModes(dest, model); err != nil {
+			diags.AddError("Failed to set permissions", err.Error())
+			return diags
+		}
 		hashValue, err := hashDirectory(dest)
 		if err != nil {
 			diags.AddError("Failed to hash directory", err.Error())
 			return diags
 		}
 		model.ContentHash = types.StringValue(hashValue)
+		setInlineContent(model, nil)
 		return diags
 	}
 
-	targetFile := filepath.Join(tempDir, filepath.Base(source))
-	data, err := os.ReadFile(targetFile)
+	diags.Append(r.writeDownloadedFile(ctx, filepath.Join(tempDir, filepath.Base(source)), dest, model)...)
+	return diags
+}
+
+// sudoStageScript copies "$1" into the new directory "$2" as root, following
+// a symlinked source and keeping modes, then hands the copy to the user who
+// ran sudo so `multipass transfer` can read it.
+const sudoStageScript = `mkdir -m 700 -- "$2" && cp -RHp -- "$1" "$2/" && chown -R "$SUDO_UID:$SUDO_GID" -- "$2" && chmod -R u+rX -- "$2"`
+
+// downloadWithSudo copies the source with sudo into a staging directory
+// unique to this download and downloads the copy as the default user. The
+// staging directory is removed whether or not the download succeeds.
+func (r *fileDownloadResource) downloadWithSudo(ctx context.Context, model *fileDownloadResourceModel, dest string) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	instance := model.Instance.ValueString()
+	if _, err := r.client.ExecCapture(ctx, instance, []string{"sudo", "-n", "true"}); err != nil {
+		diags.AddAttributeError(
+			frameworkpath.Root("use_sudo"),
+			"Passwordless sudo unavailable",
+			fmt.Sprintf("use_sudo requires the default user in %q to run sudo without a password: %s", instance, err),
+		)
+		return diags
+	}
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		diags.AddError("Failed to generate staging path", err.Error())
+		return diags
+	}
+	stage := "/tmp/terraform-multipass-download-" + hex.EncodeToString(suffix)
+	source := model.Source.ValueString()
+	if resolved, err := resolveRemotePath(source); err == nil {
+		source = resolved
+	}
+	source = path.Clean(source)
+
+	defer func() {
+		// The download may have failed because ctx expired; clean up anyway.
+		if err := r.client.Exec(context.WithoutCancel(ctx), instance, []string{"sudo", "-n", "rm", "-rf", "--", stage}); err != nil {
+			tflog.Warn(ctx, "Failed to remove download staging directory", map[string]any{
+				"instance": instance,
+				"path":     stage,
+				"error":    err.Error(),
+			})
+		}
+	}()
+	if err := r.client.Exec(ctx, instance, []string{"sudo", "-n", "sh", "-c", sudoStageScript, "sh", source, stage}); err != nil {
+		diags.AddError("Failed to stage download with sudo", err.Error())
+		return diags
+	}
+
+	staged := *model
+	staged.Source = types.StringValue(stage + "/" + path.Base(source))
+	staged.UseSudo = types.BoolValue(false)
+	if r.transferStrategy == transferStrategyTar {
+		diags.Append(r.downloadWithTar(ctx, &staged, dest)...)
+	} else {
+		diags.Append(r.downloadDirect(ctx, &staged, dest)...)
+	}
+	staged.Source = model.Source
+	staged.UseSudo = model.UseSudo
+	*model = staged
+	return diags
+}
+
+// sudoCommand prefixes command with `sudo -n` when sudo is set.
+func sudoCommand(sudo bool, command ...string) []string {
+	if !sudo {
+		return command
+	}
+	return append([]string{"sudo", "-n"}, command...)
+}
+
+// writeDownloadedFile streams a file fetched into a temporary location to
+// dest, recording its hash and inline content on the model.
+func (r *fileDownloadResource) writeDownloadedFile(ctx context.Context, fetched, dest string, model *fileDownloadResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	in, err := os.Open(fetched)
 	if err != nil {
 		diags.AddError("Failed to read downloaded file", err.Error())
 		return diags
 	}
-	diags.Append(r.writeFileBytes(data, dest, model)...)
+	defer in.Close()
+
+	mode, d := r.downloadFileMode(ctx, model)
+	diags.Append(d...)
+	digest, inline, d := r.writeFileStream(in, dest, model, mode)
+	diags.Append(d...)
 	if diags.HasError() {
 		return diags
 	}
-	model.ContentHash = types.StringValue(hashBytes(data))
+	model.ContentHash = types.StringValue(digest)
+	setInlineContent(model, inline)
 	return diags
 }
 
@@ -380,10 +1195,12 @@ func (r *fileDownloadResource) copyDirectory(src, dest string, model *fileDownlo
 		return diags
 	}
 
-	if err := copyDirContents(src, dest); err != nil {
+	skipped, err := copyDirContents(src, dest, nil)
+	if err != nil {
 		diags.AddError("Failed to copy directory", err.Error())
 		return diags
 	}
+	addSkippedEntriesWarning(&diags, skipped)
 
 	return diags
 }
@@ -392,68 +1209,119 @@ func (r *fileDownloadResource) downloadWithTar(ctx context.Context, model *fileD
 	var diags diag.Diagnostics
 
 	if model.Recursive.ValueBool() {
-		archiveData, d := r.fetchDirectoryTar(ctx, model)
+		archivePath, d := r.fetchDirectoryTar(ctx, model)
 		diags.Append(d...)
 		if diags.HasError() {
 			return diags
 		}
-		diags.Append(r.writeDirectoryFromTar(archiveData, dest, model)...)
+		defer os.Remove(archivePath)
+
+		archive, err := os.Open(archivePath)
+		if err != nil {
+			diags.AddError("Failed to read downloaded archive", err.Error())
+			return diags
+		}
+		defer archive.Close()
+
+		diags.Append(r.writeDirectoryFromTar(archive, dest, model)...)
 		if diags.HasError() {
 			return diags
 		}
+		if err := r.applyDirectoryModes(dest, model); err != nil {
+			diags.AddError("Failed to set permissions", err.Error())
+			return diags
+		}
 		hashValue, err := hashDirectory(dest)
 		if err != nil {
 			diags.AddError("Failed to hash directory", err.Error())
 			return diags
 		}
 		model.ContentHash = types.StringValue(hashValue)
+		setInlineContent(model, nil)
 		return diags
 	}
 
-	fileData, d := r.fetchFileBytes(ctx, model)
+	fetched, d := r.fetchFile(ctx, model)
 	diags.Append(d...)
 	if diags.HasError() {
 		return diags
 	}
+	defer os.Remove(fetched)
 
-	diags.Append(r.writeFileBytes(fileData, dest, model)...)
-	if diags.HasError() {
-		return diags
-	}
-
-	model.ContentHash = types.StringValue(hashBytes(fileData))
+	diags.Append(r.writeDownloadedFile(ctx, fetched, dest, model)...)
 	return diags
 }
 
-func (r *fileDownloadResource) fetchFileBytes(ctx context.Context, model *fileDownloadResourceModel) ([]byte, diag.Diagnostics) {
+// defaultMaxInlineSize is the max_inline_size used when it is not set.
+const defaultMaxInlineSize = 64 * 1024
+
+// setInlineContent exposes data through content and content_base64 when it
+// fits within max_inline_size. Directories pass nil and always get nulls.
+func setInlineContent(model *fileDownloadResourceModel, data []byte) {
+	limit := valueOrDefaultInt(model.MaxInlineSize, defaultMaxInlineSize)
+	if data == nil || len(data) > limit {
+		model.Content = types.StringNull()
+		model.ContentBase64 = types.StringNull()
+		return
+	}
+	// Terraform strings must be valid UTF-8; binary payloads are only
+	// available base64-encoded.
+	model.Content = types.StringNull()
+	if utf8.Valid(data) {
+		model.Content = types.StringValue(string(data))
+	}
+	model.ContentBase64 = types.StringValue(base64.StdEncoding.EncodeToString(data))
+}
+
+// fetchFile streams the remote source into a temporary host file and
+// returns its path; the caller removes it.
+func (r *fileDownloadResource) fetchFile(ctx context.Context, model *fileDownloadResourceModel) (string, diag.Diagnostics) {
 	var diags diag.Diagnostics
 
 	instance := model.Instance.ValueString()
 	source := model.Source.ValueString()
 	if instance == "" || source == "" {
 		diags.AddError("Invalid configuration", "`instance` and `source` must be set")
-		return nil, diags
+		return "", diags
 	}
 
-	data, err := r.client.TransferCapture(ctx, multipasscli.TransferOptions{
-		Sources:     []string{fmt.Sprintf("%s:%s", instance, source)},
-		Destination: "-",
-	})
+	fetched, err := r.transferToTempFile(ctx, fmt.Sprintf("%s:%s", instance, source))
 	if err != nil {
 		diags.AddError("Failed to download from instance", err.Error())
-		return nil, diags
+		return "", diags
+	}
+	return fetched, diags
+}
+
+// transferToTempFile streams source to a new temporary file without
+// buffering it in memory. The file is removed again on failure.
+func (r *fileDownloadResource) transferToTempFile(ctx context.Context, source string) (string, error) {
+	out, err := os.CreateTemp("", "multipass-file-download-*")
+	if err != nil {
+		return "", err
+	}
+	err = r.client.TransferTo(ctx, multipasscli.TransferOptions{Sources: []string{source}}, out)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
 	}
-	return data, diags
+	if err != nil {
+		os.Remove(out.Name())
+		return "", err
+	}
+	return out.Name(), nil
 }
 
-func (r *fileDownloadResource) fetchDirectoryTar(ctx context.Context, model *fileDownloadResourceModel) ([]byte, diag.Diagnostics) {
+// fetchDirectoryTar archives the remote directory inside the instance and
+// streams the tarball into a temporary host file, returning its path; the
+// caller removes it.
+func (r *fileDownloadResource) fetchDirectoryTar(ctx context.Context, model *fileDownloadResourceModel) (string, diag.Diagnostics) {
 	var diags diag.Diagnostics
 
 	instance := model.Instance.ValueString()
 	source := model.Source.ValueString()
 	if instance == "" || source == "" {
 		diags.AddError("Invalid configuration", "`instance` and `source` must be set")
-		return nil, diags
+		return "", diags
 	}
 
 	clean := path.Clean(source)
@@ -467,22 +1335,121 @@ func (r *fileDownloadResource) fetchDirectoryTar(ctx context.Context, model *fil
 	createCmd := []string{"tar", "-C", baseDir, "-cf", tmpTar, target}
 	if err := r.client.Exec(ctx, instance, createCmd); err != nil {
 		diags.AddError("Failed to archive remote directory", err.Error())
-		return nil, diags
+		return "", diags
 	}
 	defer r.client.Exec(ctx, instance, []string{"rm", "-f", tmpTar})
 
-	data, err := r.client.TransferCapture(ctx, multipasscli.TransferOptions{
-		Sources:     []string{fmt.Sprintf("%s:%s", instance, tmpTar)},
-		Destination: "-",
-	})
+	fetched, err := r.transferToTempFile(ctx, fmt.Sprintf("%s:%s", instance, tmpTar))
 	if err != nil {
 		diags.AddError("Failed to download archive", err.Error())
-		return nil, diags
+		return "", diags
+	}
+	return fetched, diags
+}
+
+// defaultDownloadFileMode is the mode of downloaded single files when
+// neither file_mode nor preserve_mode is set.
+const defaultDownloadFileMode fs.FileMode = 0o644
+
+// downloadFileMode picks the mode for a single downloaded file. With
+// preserve_mode the source is inspected with stat; failures fall back to
+// file_mode or the default with a warning.
+func (r *fileDownloadResource) downloadFileMode(ctx context.Context, model *fileDownloadResourceModel) (fs.FileMode, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	mode := defaultDownloadFileMode
+	if hasStringValue(model.FileMode) {
+		if parsed, err := parseFileMode(model.FileMode.ValueString()); err == nil {
+			mode = parsed
+		}
 	}
-	return data, diags
+	if !model.PreserveMode.ValueBool() || r.hostOS == "windows" {
+		return mode, diags
+	}
+
+	source := model.Source.ValueString()
+	if resolved, err := resolveRemotePath(source); err == nil {
+		source = resolved
+	}
+	out, err := r.client.ExecCapture(ctx, model.Instance.ValueString(), sudoCommand(model.UseSudo.ValueBool(), "stat", "-L", "-c", "%a", "--", source))
+	if err == nil {
+		var parsed fs.FileMode
+		if parsed, err = parseFileMode(strings.TrimSpace(string(out))); err == nil {
+			return parsed, diags
+		}
+	}
+	diags.AddWarning("Unable to preserve file mode", fmt.Sprintf("Reading the mode of %s failed: %s; using %04o instead.", source, err, mode))
+	return mode, diags
 }
 
-func (r *fileDownloadResource) writeFileBytes(data []byte, dest string, model *fileDownloadResourceModel) diag.Diagnostics {
+// applyDirectoryModes applies file_mode and dir_mode to a downloaded
+// directory tree. Without them, or with preserve_mode, the source modes
+// written during extraction are kept. Symlinks are left untouched.
+func (r *fileDownloadResource) applyDirectoryModes(dest string, model *fileDownloadResourceModel) error {
+	if r.hostOS == "windows" || model.PreserveMode.ValueBool() {
+		return nil
+	}
+	if !hasStringValue(model.FileMode) && !hasStringValue(model.DirMode) {
+		return nil
+	}
+
+	var fileMode, dirMode fs.FileMode
+	var err error
+	if hasStringValue(model.FileMode) {
+		if fileMode, err = parseFileMode(model.FileMode.ValueString()); err != nil {
+			return err
+		}
+	}
+	if hasStringValue(model.DirMode) {
+		if dirMode, err = parseFileMode(model.DirMode.ValueString()); err != nil {
+			return err
+		}
+	}
+
+	// Directories are changed after the walk so a restrictive dir_mode
+	// cannot prevent descending into them.
+	var dirs []string
+	err = filepath.WalkDir(dest, func(current string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			if hasStringValue(model.DirMode) {
+				dirs = append(dirs, current)
+			}
+		case d.Type().IsRegular():
+			if hasStringValue(model.FileMode) {
+				return os.Chmod(current, fileMode)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirs[i], dirMode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseFileMode parses an octal permission string such as "0600".
+func parseFileMode(s string) (fs.FileMode, error) {
+	v, err := strconv.ParseUint(s, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid file mode %q", s)
+	}
+	return fs.FileMode(v) & fs.ModePerm, nil
+}
+
+// writeFileStream copies src to the destination through a temporary file
+// in the same directory, hashing it on the way, and renames it into place.
+// It returns the SHA256 and, when the payload fits max_inline_size, its
+// bytes.
+func (r *fileDownloadResource) writeFileStream(src io.Reader, dest string, model *fileDownloadResourceModel, mode fs.FileMode) (string, []byte, diag.Diagnostics) {
 	var diags diag.Diagnostics
 
 	destPath := dest
@@ -492,23 +1459,82 @@ func (r *fileDownloadResource) writeFileBytes(data []byte, dest string, model *f
 
 	if _, err := os.Stat(destPath); err == nil && !model.Overwrite.ValueBool() {
 		diags.AddError("Destination exists", fmt.Sprintf("File %q already exists and overwrite=false", destPath))
-		return diags
+		return "", nil, diags
 	}
 
 	if err := ensureParentDir(destPath, model.CreateParents.ValueBool()); err != nil {
 		diags.AddError("Failed to prepare destination", err.Error())
-		return diags
+		return "", nil, diags
 	}
 
-	if err := os.WriteFile(destPath, data, 0o644); err != nil {
+	// Without file_mode or preserve_mode an existing file keeps its mode.
+	explicit := hasStringValue(model.FileMode) || model.PreserveMode.ValueBool()
+	if info, err := os.Stat(destPath); err == nil && !explicit {
+		mode = info.Mode().Perm()
+	}
+
+	out, err := os.CreateTemp(filepath.Dir(destPath), "."+filepath.Base(destPath)+".tmp-*")
+	if err != nil {
 		diags.AddError("Failed to write destination file", err.Error())
-		return diags
+		return "", nil, diags
 	}
+	defer os.Remove(out.Name())
 
-	return diags
+	hasher := sha256.New()
+	inline := &limitedBuffer{limit: valueOrDefaultInt(model.MaxInlineSize, defaultMaxInlineSize)}
+	_, err = io.Copy(io.MultiWriter(out, hasher, inline), src)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		diags.AddError("Failed to write destination file", err.Error())
+		return "", nil, diags
+	}
+
+	if r.hostOS != "windows" {
+		if err := os.Chmod(out.Name(), mode); err != nil {
+			diags.AddError("Failed to set file mode", err.Error())
+			return "", nil, diags
+		}
+	}
+	if err := os.Rename(out.Name(), destPath); err != nil {
+		diags.AddError("Failed to write destination file", err.Error())
+		return "", nil, diags
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), inline.Bytes(), diags
 }
 
-func (r *fileDownloadResource) writeDirectoryFromTar(data []byte, dest string, model *fileDownloadResourceModel) diag.Diagnostics {
+// limitedBuffer keeps everything written to it as long as the total stays
+// within limit, so small payloads can be exposed inline while large ones are
+// only streamed.
+type limitedBuffer struct {
+	limit    int
+	buf      bytes.Buffer
+	overflow bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if !b.overflow {
+		if b.buf.Len()+len(p) > b.limit {
+			b.overflow = true
+			b.buf = bytes.Buffer{}
+		} else {
+			b.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+// Bytes returns the buffered payload, or nil once limit was exceeded.
+func (b *limitedBuffer) Bytes() []byte {
+	if b.overflow {
+		return nil
+	}
+	return append([]byte{}, b.buf.Bytes()...)
+}
+
+func (r *fileDownloadResource) writeDirectoryFromTar(archive io.Reader, dest string, model *fileDownloadResourceModel) diag.Diagnostics {
 	var diags diag.Diagnostics
 
 	if stat, err := os.Stat(dest); err == nil {
@@ -532,8 +1558,18 @@ func (r *fileDownloadResource) writeDirectoryFromTar(data []byte, dest string, m
 		return diags
 	}
 
-	tr := tar.NewReader(bytes.NewReader(data))
-	destPrefix := filepath.Clean(dest) + string(os.PathSeparator)
+	tr := tar.NewReader(archive)
+	root := filepath.Clean(dest)
+	destPrefix := root + string(os.PathSeparator)
+
+	// Directory modes are applied once extraction finishes so that
+	// read-only directories do not block writing their children.
+	type dirMode struct {
+		path string
+		mode fs.FileMode
+	}
+	var dirModes []dirMode
+	var skipped []string
 
 	for {
 		hdr, err := tr.Next()
@@ -551,18 +1587,20 @@ func (r *fileDownloadResource) writeDirectoryFromTar(data []byte, dest string, m
 			return diags
 		}
 
+		mode := hdr.FileInfo().Mode().Perm()
 		switch hdr.Typeflag {
 		case tar.TypeDir:
 			if err := os.MkdirAll(targetPath, 0o755); err != nil {
 				diags.AddError("Failed to create directory", err.Error())
 				return diags
 			}
+			dirModes = append(dirModes, dirMode{path: targetPath, mode: mode})
 		case tar.TypeReg:
 			if err := ensureParentDir(targetPath, true); err != nil {
 				diags.AddError("Failed to create parent directory", err.Error())
 				return diags
 			}
-			out, err := os.Create(targetPath)
+			out, err := os.OpenFile(targetPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
 			if err != nil {
 				diags.AddError("Failed to create file", err.Error())
 				return diags
@@ -573,15 +1611,76 @@ func (r *fileDownloadResource) writeDirectoryFromTar(data []byte, dest string, m
 				return diags
 			}
 			out.Close()
+			if err := os.Chmod(targetPath, mode); err != nil {
+				diags.AddError("Failed to set file mode", err.Error())
+				return diags
+			}
+		case tar.TypeSymlink:
+			if !linkTargetWithinRoot(root, targetPath, hdr.Linkname) {
+				skipped = append(skipped, fmt.Sprintf("%s -> %s (points outside the destination)", hdr.Name, hdr.Linkname))
+				continue
+			}
+			if err := ensureParentDir(targetPath, true); err != nil {
+				diags.AddError("Failed to create parent directory", err.Error())
+				return diags
+			}
+			_ = os.Remove(targetPath)
+			if err := os.Symlink(hdr.Linkname, targetPath); err != nil {
+				skipped = append(skipped, fmt.Sprintf("%s -> %s (%s)", hdr.Name, hdr.Linkname, err))
+			}
+		case tar.TypeLink:
+			linkSource, err := sanitizeExtractPath(destPrefix, hdr.Linkname)
+			if err != nil {
+				skipped = append(skipped, fmt.Sprintf("%s (hard link: %s)", hdr.Name, err))
+				continue
+			}
+			_ = os.Remove(targetPath)
+			if err := os.Link(linkSource, targetPath); err != nil {
+				skipped = append(skipped, fmt.Sprintf("%s (hard link to %s: %s)", hdr.Name, hdr.Linkname, err))
+			}
 		default:
 			diags.AddError("Unsupported archive entry", fmt.Sprintf("Entry %q has unsupported type %d", hdr.Name, hdr.Typeflag))
 			return diags
 		}
 	}
 
+	for i := len(dirModes) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirModes[i].path, dirModes[i].mode); err != nil {
+			diags.AddError("Failed to set directory mode", err.Error())
+			return diags
+		}
+	}
+	addSkippedEntriesWarning(&diags, skipped)
+
 	return diags
 }
 
+// linkTargetWithinRoot reports whether a symlink created at linkPath with
+// the given target resolves inside root. Absolute targets are rejected since
+// they refer to the instance's filesystem, not the download.
+func linkTargetWithinRoot(root, linkPath, target string) bool {
+	if filepath.IsAbs(target) || path.IsAbs(target) {
+		return false
+	}
+	rel, err := filepath.Rel(root, filepath.Join(filepath.Dir(linkPath), target))
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator))
+}
+
+// addSkippedEntriesWarning reports entries that could not be recreated
+// locally in a single warning.
+func addSkippedEntriesWarning(diags *diag.Diagnostics, skipped []string) {
+	if len(skipped) == 0 {
+		return
+	}
+	diags.AddWarning(
+		"Skipped links while downloading directory",
+		fmt.Sprintf("The following entries were not recreated locally:\n  %s", strings.Join(skipped, "\n  ")),
+	)
+}
+
 func sanitizeExtractPath(destPrefix, name string) (string, error) {
 	cleanName := filepath.Clean(name)
 	if strings.Contains(cleanName, "..") {
@@ -605,37 +1704,88 @@ func ensureParentDir(path string, create bool) error {
 	return nil
 }
 
-func copyDirContents(src, dest string) error {
-	return filepath.WalkDir(src, func(current string, d fs.DirEntry, err error) error {
+// copyDirContents copies src into dest without following symlinks,
+// preserving permission bits and leaving out entries matched by excludes.
+// Symlinks that would resolve outside dest are skipped and returned for
+// reporting.
+func copyDirContents(src, dest string, excludes excludePatterns) ([]string, error) {
+	root := filepath.Clean(dest)
+	var skipped []string
+	type dirMode struct {
+		path string
+		mode fs.FileMode
+	}
+	var dirModes []dirMode
+
+	err := filepath.WalkDir(src, func(current string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
+		if skip, err := excludes.skip(src, current, d); skip {
+			return err
+		}
 
 		rel, err := filepath.Rel(src, current)
 		if err != nil {
 			return err
 		}
 
+		info, err := os.Lstat(current)
+		if err != nil {
+			return err
+		}
+
 		target := filepath.Join(dest, rel)
-		if d.IsDir() {
+		switch {
+		case d.IsDir():
+			dirModes = append(dirModes, dirMode{path: target, mode: info.Mode().Perm()})
 			return os.MkdirAll(target, 0o755)
+		case info.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(current)
+			if err != nil {
+				return err
+			}
+			if !linkTargetWithinRoot(root, target, link) {
+				skipped = append(skipped, fmt.Sprintf("%s -> %s (points outside the destination)", rel, link))
+				return nil
+			}
+			if err := ensureParentDir(target, true); err != nil {
+				return err
+			}
+			if err := os.Symlink(link, target); err != nil {
+				skipped = append(skipped, fmt.Sprintf("%s -> %s (%s)", rel, link, err))
+			}
+			return nil
+		case !info.Mode().IsRegular():
+			skipped = append(skipped, fmt.Sprintf("%s (unsupported file type)", rel))
+			return nil
 		}
 
 		if err := ensureParentDir(target, true); err != nil {
 			return err
 		}
-		return copyFileContents(current, target)
+		return copyFileContents(current, target, info.Mode().Perm())
 	})
+	if err != nil {
+		return skipped, err
+	}
+
+	for i := len(dirModes) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirModes[i].path, dirModes[i].mode); err != nil {
+			return skipped, err
+		}
+	}
+	return skipped, nil
 }
 
-func copyFileContents(src, dest string) error {
+func copyFileContents(src, dest string, mode fs.FileMode) error {
 	in, err := os.Open(src)
 	if err != nil {
 		return err
 	}
 	defer in.Close()
 
-	out, err := os.Create(dest)
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
 	if err != nil {
 		return err
 	}
@@ -644,7 +1794,10 @@ func copyFileContents(src, dest string) error {
 	if _, err := io.Copy(out, in); err != nil {
 		return err
 	}
-	return out.Close()
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Chmod(dest, mode)
 }
 
 func mapsEqual(ctx context.Context, a, b types.Map) bool {
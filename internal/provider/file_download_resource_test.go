@@ -0,0 +1,1020 @@
+package provider
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework-timeouts/resource/timeouts"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+func TestDetectRemoteChange(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	running := &models.Instance{Name: "vm", State: "Running"}
+	hash := hashBytes([]byte("log line\n"))
+
+	newState := func() fileDownloadResourceModel {
+		return fileDownloadResourceModel{
+			Instance:    types.StringValue("vm"),
+			Source:      types.StringValue("/var/log/app.log"),
+			Destination: types.StringValue("app.log"),
+			Recursive:   types.BoolValue(false),
+			ContentHash: types.StringValue(hash),
+		}
+	}
+	remote := func(out string) *fakeClient {
+		return &fakeClient{
+			execCapture: func(string, []string) ([]byte, error) {
+				return []byte(out), nil
+			},
+		}
+	}
+
+	t.Run("unchanged", func(t *testing.T) {
+		state := newState()
+		r := &fileDownloadResource{client: remote(hash + "  -\n")}
+		if diags := r.detectRemoteChange(ctx, running, &state); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		if state.ContentHash.ValueString() != hash {
+			t.Fatalf("content_hash changed to %s", state.ContentHash)
+		}
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		state := newState()
+		r := &fileDownloadResource{client: remote(hashBytes([]byte("other\n")) + "  -\n")}
+		if diags := r.detectRemoteChange(ctx, running, &state); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		if !state.ContentHash.IsNull() {
+			t.Fatalf("expected content_hash to be cleared, got %s", state.ContentHash)
+		}
+	})
+
+	t.Run("stopped instance", func(t *testing.T) {
+		state := newState()
+		client := &fakeClient{}
+		r := &fileDownloadResource{client: client}
+		diags := r.detectRemoteChange(ctx, &models.Instance{Name: "vm", State: "Stopped"}, &state)
+		if diags.WarningsCount() != 1 {
+			t.Fatalf("expected a warning, got %v", diags)
+		}
+		if state.ContentHash.ValueString() != hash || len(client.recorded()) != 0 {
+			t.Fatalf("expected no check while stopped")
+		}
+	})
+
+	t.Run("directory", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0o644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+		manifest, err := hashDirectoryManifest(dir, nil)
+		if err != nil {
+			t.Fatalf("hash manifest: %v", err)
+		}
+
+		state := newState()
+		state.Recursive = types.BoolValue(true)
+		state.Destination = types.StringValue(dir)
+		state.ContentHash = types.StringValue("v2:local")
+		r := &fileDownloadResource{client: remote(manifest + "  -\n")}
+		if diags := r.detectRemoteChange(ctx, running, &state); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		if state.ContentHash.ValueString() != "v2:local" {
+			t.Fatalf("content_hash changed to %s", state.ContentHash)
+		}
+	})
+}
+
+func TestSetInlineContent(t *testing.T) {
+	t.Parallel()
+
+	model := fileDownloadResourceModel{MaxInlineSize: types.Int64Null()}
+	setInlineContent(&model, []byte("token\n"))
+	if model.Content.ValueString() != "token\n" || model.ContentBase64.ValueString() != "dG9rZW4K" {
+		t.Fatalf("unexpected inline content: %s / %s", model.Content, model.ContentBase64)
+	}
+
+	setInlineContent(&model, []byte{0xff, 0x00})
+	if !model.Content.IsNull() || model.ContentBase64.ValueString() != "/wA=" {
+		t.Fatalf("expected binary payload only in content_base64, got %s / %s", model.Content, model.ContentBase64)
+	}
+
+	model.MaxInlineSize = types.Int64Value(4)
+	setInlineContent(&model, []byte("token\n"))
+	if !model.Content.IsNull() || !model.ContentBase64.IsNull() {
+		t.Fatalf("expected oversized payload to be omitted")
+	}
+
+	model.MaxInlineSize = types.Int64Null()
+	setInlineContent(&model, nil)
+	if !model.Content.IsNull() || !model.ContentBase64.IsNull() {
+		t.Fatalf("expected directories to be omitted")
+	}
+}
+
+func TestWriteDirectoryFromTar(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix permission bits and symlinks are not portable to Windows")
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	entries := []struct {
+		hdr  tar.Header
+		body string
+	}{
+		{hdr: tar.Header{Name: "app/", Typeflag: tar.TypeDir, Mode: 0o750}},
+		{hdr: tar.Header{Name: "app/run.sh", Typeflag: tar.TypeReg, Mode: 0o755}, body: "#!/bin/sh\n"},
+		{hdr: tar.Header{Name: "app/key.pem", Typeflag: tar.TypeReg, Mode: 0o600}, body: "secret"},
+		{hdr: tar.Header{Name: "app/current", Typeflag: tar.TypeSymlink, Linkname: "run.sh"}},
+		{hdr: tar.Header{Name: "app/passwd", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"}},
+		{hdr: tar.Header{Name: "app/escape", Typeflag: tar.TypeSymlink, Linkname: "../../outside"}},
+		{hdr: tar.Header{Name: "app/run-copy.sh", Typeflag: tar.TypeLink, Linkname: "app/run.sh"}},
+	}
+	for _, entry := range entries {
+		hdr := entry.hdr
+		hdr.Size = int64(len(entry.body))
+		if err := tw.WriteHeader(&hdr); err != nil {
+			t.Fatalf("write header: %v", err)
+		}
+		if _, err := tw.Write([]byte(entry.body)); err != nil {
+			t.Fatalf("write body: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("close tar: %v", err)
+	}
+
+	dest := filepath.Join(t.TempDir(), "out")
+	r := &fileDownloadResource{}
+	model := &fileDownloadResourceModel{
+		Source:        types.StringValue("/opt/app"),
+		Overwrite:     types.BoolValue(true),
+		CreateParents: types.BoolValue(true),
+	}
+	diags := r.writeDirectoryFromTar(&buf, dest, model)
+	if diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if diags.WarningsCount() != 1 {
+		t.Fatalf("expected one warning for escaping links, got %v", diags)
+	}
+
+	for name, want := range map[string]os.FileMode{"app": 0o750, "app/run.sh": 0o755, "app/key.pem": 0o600} {
+		info, err := os.Stat(filepath.Join(dest, name))
+		if err != nil {
+			t.Fatalf("stat %s: %v", name, err)
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Fatalf("%s: mode %o, want %o", name, got, want)
+		}
+	}
+	if link, err := os.Readlink(filepath.Join(dest, "app/current")); err != nil || link != "run.sh" {
+		t.Fatalf("expected symlink to run.sh, got %q (%v)", link, err)
+	}
+	for _, name := range []string{"app/passwd", "app/escape"} {
+		if _, err := os.Lstat(filepath.Join(dest, name)); !os.IsNotExist(err) {
+			t.Fatalf("expected %s to be skipped, got %v", name, err)
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(dest, "app/run-copy.sh")); err != nil || string(data) != "#!/bin/sh\n" {
+		t.Fatalf("expected hard link contents, got %q (%v)", data, err)
+	}
+}
+
+func TestCopyDirContentsPreservesLinksAndModes(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix permission bits and symlinks are not portable to Windows")
+	}
+
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "id_ed25519"), []byte("key"), 0o600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if err := os.Symlink("id_ed25519", filepath.Join(src, "default")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+	if err := os.Symlink("/etc/hosts", filepath.Join(src, "hosts")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+
+	dest := filepath.Join(t.TempDir(), "copy")
+	skipped, err := copyDirContents(src, dest, nil)
+	if err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+	if len(skipped) != 1 {
+		t.Fatalf("expected the absolute link to be skipped, got %v", skipped)
+	}
+	if info, err := os.Stat(filepath.Join(dest, "id_ed25519")); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected 0600 key, got %v (%v)", info, err)
+	}
+	if link, err := os.Readlink(filepath.Join(dest, "default")); err != nil || link != "id_ed25519" {
+		t.Fatalf("expected relative symlink, got %q (%v)", link, err)
+	}
+}
+
+func TestDownloadModes(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix permission bits are not portable to Windows")
+	}
+
+	ctx := context.Background()
+	base := fileDownloadResourceModel{
+		Instance:      types.StringValue("vm"),
+		Source:        types.StringValue("/home/ubuntu/.ssh/id_ed25519"),
+		Overwrite:     types.BoolValue(true),
+		CreateParents: types.BoolValue(true),
+	}
+
+	t.Run("file_mode", func(t *testing.T) {
+		model := base
+		model.FileMode = types.StringValue("0600")
+		r := &fileDownloadResource{client: &fakeClient{}}
+		mode, diags := r.downloadFileMode(ctx, &model)
+		if diags.HasError() || mode != 0o600 {
+			t.Fatalf("expected 0600, got %o (%v)", mode, diags)
+		}
+
+		dest := filepath.Join(t.TempDir(), "key")
+		if _, _, diags := r.writeFileStream(strings.NewReader("key"), dest, &model, mode); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		if info, err := os.Stat(dest); err != nil || info.Mode().Perm() != 0o600 {
+			t.Fatalf("expected 0600 file, got %v (%v)", info, err)
+		}
+	})
+
+	t.Run("preserve_mode takes precedence", func(t *testing.T) {
+		model := base
+		model.FileMode = types.StringValue("0644")
+		model.PreserveMode = types.BoolValue(true)
+		client := &fakeClient{
+			execCapture: func(string, []string) ([]byte, error) {
+				return []byte("755\n"), nil
+			},
+		}
+		r := &fileDownloadResource{client: client}
+		mode, diags := r.downloadFileMode(ctx, &model)
+		if diags.HasError() || mode != 0o755 {
+			t.Fatalf("expected 0755, got %o (%v)", mode, diags)
+		}
+		if calls := client.recorded(); len(calls) != 1 || calls[0] != "exec-capture vm stat -L -c %a -- /home/ubuntu/.ssh/id_ed25519" {
+			t.Fatalf("unexpected calls: %v", calls)
+		}
+	})
+
+	t.Run("ignored on windows", func(t *testing.T) {
+		model := base
+		model.PreserveMode = types.BoolValue(true)
+		client := &fakeClient{}
+		r := &fileDownloadResource{client: client, hostOS: "windows", transferStrategy: transferStrategyTar}
+		if mode, diags := r.downloadFileMode(ctx, &model); diags.HasError() || mode != defaultDownloadFileMode {
+			t.Fatalf("expected default mode, got %o (%v)", mode, diags)
+		}
+		if calls := client.recorded(); len(calls) != 0 {
+			t.Fatalf("expected no remote stat, got %v", calls)
+		}
+	})
+
+	t.Run("directory modes", func(t *testing.T) {
+		dest := t.TempDir()
+		if err := os.MkdirAll(filepath.Join(dest, "sub"), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dest, "sub", "a.txt"), []byte("a"), 0o644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+
+		model := base
+		model.FileMode = types.StringValue("0600")
+		model.DirMode = types.StringValue("0700")
+		r := &fileDownloadResource{}
+		if err := r.applyDirectoryModes(dest, &model); err != nil {
+			t.Fatalf("apply modes: %v", err)
+		}
+		for name, want := range map[string]os.FileMode{"sub": 0o700, "sub/a.txt": 0o600} {
+			info, err := os.Stat(filepath.Join(dest, name))
+			if err != nil || info.Mode().Perm() != want {
+				t.Fatalf("%s: expected %o, got %v (%v)", name, want, info, err)
+			}
+		}
+	})
+}
+
+// modifyDownloadPlan runs ModifyPlan for a transition from state to plan
+// and returns the response.
+func modifyDownloadPlan(t *testing.T, state, plan fileDownloadResourceModel) resource.ModifyPlanResponse {
+	t.Helper()
+	ctx := context.Background()
+
+	r := &fileDownloadResource{}
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	nullTimeouts := timeouts.Value{Object: types.ObjectNull(map[string]attr.Type{
+		"create": types.StringType,
+		"update": types.StringType,
+	})}
+	for _, m := range []*fileDownloadResourceModel{&state, &plan} {
+		m.Timeouts = nullTimeouts
+		if m.Sources.ElementType(ctx) == nil {
+			m.Sources = types.ListNull(types.StringType)
+		}
+		if m.MatchedSources.ElementType(ctx) == nil {
+			m.MatchedSources = types.ListNull(types.StringType)
+		}
+	}
+
+	tfState := tfsdk.State{Schema: schemaResp.Schema}
+	if diags := tfState.Set(ctx, &state); diags.HasError() {
+		t.Fatalf("set state: %v", diags)
+	}
+	tfPlan := tfsdk.Plan{Schema: schemaResp.Schema}
+	if diags := tfPlan.Set(ctx, &plan); diags.HasError() {
+		t.Fatalf("set plan: %v", diags)
+	}
+
+	resp := resource.ModifyPlanResponse{Plan: tfPlan}
+	r.ModifyPlan(ctx, resource.ModifyPlanRequest{State: tfState, Plan: tfPlan}, &resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+	}
+	return resp
+}
+
+func TestModifyPlanTriggersUpdateInPlace(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	model := func(build string) fileDownloadResourceModel {
+		return fileDownloadResourceModel{
+			Instance:       types.StringValue("vm"),
+			Source:         types.StringValue("/var/log/app.log"),
+			Destination:    types.StringValue("app.log"),
+			Triggers:       types.MapValueMust(types.StringType, map[string]attr.Value{"build": types.StringValue(build)}),
+			ContentHash:    types.StringValue("abc"),
+			Content:        types.StringValue("log"),
+			ContentBase64:  types.StringValue("bG9n"),
+			SourceModified: types.StringValue("2024-01-02T03:04:05Z"),
+			DownloadedAt:   types.StringValue("2024-01-02T03:05:00Z"),
+		}
+	}
+
+	t.Run("changed triggers", func(t *testing.T) {
+		resp := modifyDownloadPlan(t, model("1"), model("2"))
+		if len(resp.RequiresReplace) != 0 {
+			t.Fatalf("expected in-place update, got replacement on %v", resp.RequiresReplace)
+		}
+		var planned fileDownloadResourceModel
+		resp.Plan.Get(ctx, &planned)
+		if !planned.ContentHash.IsUnknown() || !planned.Content.IsUnknown() || !planned.ContentBase64.IsUnknown() {
+			t.Fatalf("expected download results to be unknown, got %s / %s", planned.ContentHash, planned.Content)
+		}
+		if !planned.SourceModified.IsUnknown() || !planned.DownloadedAt.IsUnknown() {
+			t.Fatalf("expected timestamps to be unknown, got %s / %s", planned.SourceModified, planned.DownloadedAt)
+		}
+	})
+
+	t.Run("unchanged triggers", func(t *testing.T) {
+		resp := modifyDownloadPlan(t, model("1"), model("1"))
+		var planned fileDownloadResourceModel
+		resp.Plan.Get(ctx, &planned)
+		if planned.ContentHash.ValueString() != "abc" || planned.Content.ValueString() != "log" {
+			t.Fatalf("expected state values to carry over, got %s / %s", planned.ContentHash, planned.Content)
+		}
+	})
+}
+
+func TestDownloadMany(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	remoteFiles := map[string]string{
+		"/var/log/myapp/a.log": "alpha\n",
+		"/var/log/myapp/b.log": "beta\n",
+	}
+	newClient := func(globOutput string) *fakeClient {
+		return &fakeClient{
+			execCapture: func(string, []string) ([]byte, error) {
+				return []byte(globOutput), nil
+			},
+			transfer: func(opts multipasscli.TransferOptions) error {
+				source := strings.SplitN(opts.Sources[0], ":", 2)[1]
+				return os.WriteFile(filepath.Join(opts.Destination, filepath.Base(source)), []byte(remoteFiles[source]), 0o644)
+			},
+		}
+	}
+	newModel := func(dest string) fileDownloadResourceModel {
+		return fileDownloadResourceModel{
+			Instance:       types.StringValue("vm"),
+			Source:         types.StringNull(),
+			Sources:        types.ListNull(types.StringType),
+			SourceGlob:     types.StringValue("/var/log/myapp/*.log"),
+			Destination:    types.StringValue(dest),
+			CreateParents:  types.BoolValue(true),
+			Overwrite:      types.BoolValue(true),
+			MatchedSources: types.ListNull(types.StringType),
+		}
+	}
+
+	t.Run("glob", func(t *testing.T) {
+		dest := filepath.Join(t.TempDir(), "logs")
+		model := newModel(dest)
+		r := &fileDownloadResource{client: newClient("/var/log/myapp/b.log\n/var/log/myapp/a.log\n")}
+		if diags := r.downloadAndWrite(ctx, &model); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+
+		for name, want := range map[string]string{"a.log": "alpha\n", "b.log": "beta\n"} {
+			data, err := os.ReadFile(filepath.Join(dest, name))
+			if err != nil || string(data) != want {
+				t.Fatalf("%s: got %q (%v)", name, data, err)
+			}
+		}
+		want := hashBytes([]byte("a.log\x00" + hashBytes([]byte("alpha\n")) + "\n" + "b.log\x00" + hashBytes([]byte("beta\n")) + "\n"))
+		if model.ContentHash.ValueString() != want {
+			t.Fatalf("unexpected content_hash %s", model.ContentHash)
+		}
+		var matched []string
+		model.MatchedSources.ElementsAs(ctx, &matched, false)
+		if strings.Join(matched, ",") != "/var/log/myapp/a.log,/var/log/myapp/b.log" {
+			t.Fatalf("unexpected matched_sources %v", matched)
+		}
+	})
+
+	t.Run("empty glob", func(t *testing.T) {
+		dest := filepath.Join(t.TempDir(), "logs")
+		model := newModel(dest)
+		r := &fileDownloadResource{client: newClient("")}
+		if diags := r.downloadAndWrite(ctx, &model); !diags.HasError() {
+			t.Fatal("expected an error for an empty glob")
+		}
+
+		model.AllowEmptyGlob = types.BoolValue(true)
+		if diags := r.downloadAndWrite(ctx, &model); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		if info, err := os.Stat(dest); err != nil || !info.IsDir() {
+			t.Fatalf("expected empty destination directory, got %v (%v)", info, err)
+		}
+	})
+
+	t.Run("conflicting names", func(t *testing.T) {
+		model := newModel(filepath.Join(t.TempDir(), "logs"))
+		model.SourceGlob = types.StringNull()
+		model.Sources = types.ListValueMust(types.StringType, []attr.Value{
+			types.StringValue("/var/log/a/app.log"),
+			types.StringValue("/var/log/b/app.log"),
+		})
+		r := &fileDownloadResource{client: newClient("")}
+		diags := r.downloadAndWrite(ctx, &model)
+		if !diags.HasError() || !strings.Contains(diags[0].Summary(), "Conflicting") {
+			t.Fatalf("expected conflicting name error, got %v", diags)
+		}
+	})
+}
+
+func TestRecordDownloadTimes(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("single source", func(t *testing.T) {
+		client := &fakeClient{
+			execCapture: func(string, []string) ([]byte, error) {
+				return []byte("1704164645\n"), nil
+			},
+		}
+		model := fileDownloadResourceModel{
+			Instance:       types.StringValue("vm"),
+			Source:         types.StringValue("~/build/app.tar"),
+			MatchedSources: types.ListNull(types.StringType),
+		}
+		(&fileDownloadResource{client: client}).recordDownloadTimes(ctx, &model)
+
+		if got := model.SourceModified.ValueString(); got != "2024-01-02T03:04:05Z" {
+			t.Fatalf("source_last_modified = %q", got)
+		}
+		if _, err := time.Parse(time.RFC3339, model.DownloadedAt.ValueString()); err != nil {
+			t.Fatalf("downloaded_at is not RFC3339: %v", err)
+		}
+		want := "exec-capture vm stat -L -c %Y -- /home/ubuntu/build/app.tar"
+		if calls := client.recorded(); len(calls) != 1 || calls[0] != want {
+			t.Fatalf("calls = %v, want [%s]", calls, want)
+		}
+	})
+
+	t.Run("newest of matched sources", func(t *testing.T) {
+		client := &fakeClient{
+			execCapture: func(string, []string) ([]byte, error) {
+				return []byte("1704164645\n1704164700\n1704160000\n"), nil
+			},
+		}
+		model := fileDownloadResourceModel{
+			Instance:       types.StringValue("vm"),
+			Source:         types.StringNull(),
+			MatchedSources: types.ListValueMust(types.StringType, []attr.Value{types.StringValue("/a"), types.StringValue("/b"), types.StringValue("/c")}),
+		}
+		(&fileDownloadResource{client: client}).recordDownloadTimes(ctx, &model)
+
+		if got := model.SourceModified.ValueString(); got != "2024-01-02T03:05:00Z" {
+			t.Fatalf("source_last_modified = %q", got)
+		}
+	})
+
+	t.Run("instance unavailable", func(t *testing.T) {
+		client := &fakeClient{
+			execCapture: func(string, []string) ([]byte, error) {
+				return nil, errors.New("instance \"vm\" is not running")
+			},
+		}
+		model := fileDownloadResourceModel{
+			Instance:       types.StringValue("vm"),
+			Source:         types.StringValue("/var/log/app.log"),
+			SourceModified: types.StringValue("2024-01-02T03:04:05Z"),
+		}
+		(&fileDownloadResource{client: client}).recordDownloadTimes(ctx, &model)
+
+		if !model.SourceModified.IsNull() {
+			t.Fatalf("expected null source_last_modified, got %s", model.SourceModified)
+		}
+		if model.DownloadedAt.IsNull() {
+			t.Fatalf("expected downloaded_at to be set")
+		}
+	})
+}
+
+func TestCheckExpectedSHA256(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	digest := hashBytes([]byte("artifact"))
+	newModel := func(t *testing.T, contentHash, expected string) (fileDownloadResourceModel, string) {
+		dest := filepath.Join(t.TempDir(), "artifact.bin")
+		if err := os.WriteFile(dest, []byte("artifact"), 0o644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+		return fileDownloadResourceModel{
+			Instance:       types.StringValue("vm"),
+			Source:         types.StringValue("/build/artifact.bin"),
+			Destination:    types.StringValue(dest),
+			ContentHash:    types.StringValue(contentHash),
+			ExpectedSHA256: types.StringValue(expected),
+		}, dest
+	}
+
+	t.Run("match", func(t *testing.T) {
+		model, dest := newModel(t, digest, strings.ToUpper(digest))
+		if diags := checkExpectedSHA256(ctx, &model); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		if _, err := os.Stat(dest); err != nil {
+			t.Fatalf("expected destination to remain: %v", err)
+		}
+	})
+
+	t.Run("directory hash", func(t *testing.T) {
+		model, _ := newModel(t, directoryHashPrefix+digest, digest)
+		if diags := checkExpectedSHA256(ctx, &model); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		other := hashBytes([]byte("other"))
+		model, dest := newModel(t, digest, other)
+		diags := checkExpectedSHA256(ctx, &model)
+		if !diags.HasError() {
+			t.Fatal("expected mismatch error")
+		}
+		if detail := diags[0].Detail(); !strings.Contains(detail, digest) || !strings.Contains(detail, other) {
+			t.Fatalf("expected both digests in %q", detail)
+		}
+		if _, err := os.Stat(dest); !os.IsNotExist(err) {
+			t.Fatalf("expected destination to be removed, got %v", err)
+		}
+	})
+}
+
+func deleteDownload(t *testing.T, model fileDownloadResourceModel) diag.Diagnostics {
+	t.Helper()
+	ctx := context.Background()
+
+	r := &fileDownloadResource{}
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	model.Timeouts = timeouts.Value{Object: types.ObjectNull(map[string]attr.Type{
+		"create": types.StringType,
+		"update": types.StringType,
+	})}
+	model.Sources = types.ListNull(types.StringType)
+	model.MatchedSources = types.ListNull(types.StringType)
+	model.Triggers = types.MapNull(types.StringType)
+	state := tfsdk.State{Schema: schemaResp.Schema}
+	if diags := state.Set(ctx, &model); diags.HasError() {
+		t.Fatalf("set state: %v", diags)
+	}
+
+	resp := resource.DeleteResponse{State: state}
+	r.Delete(ctx, resource.DeleteRequest{State: state}, &resp)
+	return resp.Diagnostics
+}
+
+func TestDeleteDownload(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	base := func(dest string) fileDownloadResourceModel {
+		return fileDownloadResourceModel{
+			Instance:    types.StringValue("vm"),
+			Source:      types.StringValue("/etc/hosts"),
+			Destination: types.StringValue(dest),
+		}
+	}
+	writeDest := func(t *testing.T) string {
+		dest := filepath.Join(t.TempDir(), "hosts")
+		if err := os.WriteFile(dest, []byte("127.0.0.1 localhost\n"), 0o644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+		return dest
+	}
+
+	t.Run("removes destination", func(t *testing.T) {
+		dest := writeDest(t)
+		if diags := deleteDownload(t, base(dest)); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		if _, err := os.Stat(dest); !os.IsNotExist(err) {
+			t.Fatalf("expected destination to be removed, got %v", err)
+		}
+	})
+
+	t.Run("retain_on_destroy", func(t *testing.T) {
+		dest := writeDest(t)
+		model := base(dest)
+		model.RetainOnDestroy = types.BoolValue(true)
+		if diags := deleteDownload(t, model); len(diags) != 0 {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		if _, err := os.Stat(dest); err != nil {
+			t.Fatalf("expected destination to remain: %v", err)
+		}
+	})
+
+	for name, dest := range map[string]string{
+		"filesystem root": string(filepath.Separator),
+		"home directory":  home + string(filepath.Separator),
+		"working dir":     ".",
+	} {
+		t.Run(name, func(t *testing.T) {
+			diags := deleteDownload(t, base(dest))
+			if diags.HasError() || diags.WarningsCount() != 1 {
+				t.Fatalf("expected a single warning, got %v", diags)
+			}
+			if _, err := os.Stat(dest); err != nil {
+				t.Fatalf("expected %s to remain: %v", dest, err)
+			}
+		})
+	}
+}
+
+func TestDownloadWithTarStreamsFile(t *testing.T) {
+	t.Parallel()
+
+	payload := strings.Repeat("0123456789abcdef", 8192)
+	client := &fakeClient{
+		transferTo: func(_ multipasscli.TransferOptions, w io.Writer) error {
+			// Write in chunks to mimic the CLI's stdout.
+			for i := 0; i < len(payload); i += 4096 {
+				if _, err := io.WriteString(w, payload[i:i+4096]); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	r := &fileDownloadResource{client: client, hostOS: "windows", transferStrategy: transferStrategyTar}
+	dest := filepath.Join(t.TempDir(), "disk.img")
+	model := fileDownloadResourceModel{
+		Instance:      types.StringValue("vm"),
+		Source:        types.StringValue("/var/lib/disk.img"),
+		Destination:   types.StringValue(dest),
+		Recursive:     types.BoolValue(false),
+		CreateParents: types.BoolValue(true),
+		Overwrite:     types.BoolValue(true),
+	}
+	if diags := r.downloadAndWrite(context.Background(), &model); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	if calls := client.recorded(); len(calls) != 1 || calls[0] != "transfer-to vm:/var/lib/disk.img" {
+		t.Fatalf("unexpected calls: %v", calls)
+	}
+	data, err := os.ReadFile(dest)
+	if err != nil || string(data) != payload {
+		t.Fatalf("unexpected destination contents (%d bytes, %v)", len(data), err)
+	}
+	if model.ContentHash.ValueString() != hashBytes([]byte(payload)) {
+		t.Fatalf("unexpected content_hash %s", model.ContentHash)
+	}
+	// 128 KiB exceeds the default max_inline_size.
+	if !model.Content.IsNull() || !model.ContentBase64.IsNull() {
+		t.Fatal("expected large payload to stay out of state")
+	}
+	entries, _ := os.ReadDir(filepath.Dir(dest))
+	if len(entries) != 1 {
+		t.Fatalf("expected temporary files to be cleaned up, got %v", entries)
+	}
+}
+
+func TestDownloadWithSudo(t *testing.T) {
+	t.Parallel()
+
+	newModel := func(dest string) fileDownloadResourceModel {
+		return fileDownloadResourceModel{
+			Instance:      types.StringValue("vm"),
+			Source:        types.StringValue("/var/log/secure.log"),
+			Destination:   types.StringValue(dest),
+			Recursive:     types.BoolValue(false),
+			CreateParents: types.BoolValue(true),
+			Overwrite:     types.BoolValue(true),
+			UseSudo:       types.BoolValue(true),
+		}
+	}
+
+	t.Run("stages, downloads and cleans up", func(t *testing.T) {
+		client := &fakeClient{
+			transferTo: func(_ multipasscli.TransferOptions, w io.Writer) error {
+				_, err := io.WriteString(w, "secret\n")
+				return err
+			},
+		}
+		r := &fileDownloadResource{client: client, hostOS: "windows", transferStrategy: transferStrategyTar}
+		model := newModel(filepath.Join(t.TempDir(), "secure.log"))
+		if diags := r.downloadAndWrite(context.Background(), &model); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+
+		calls := client.recorded()
+		if len(calls) != 4 || calls[0] != "exec-capture vm sudo -n true" {
+			t.Fatalf("unexpected calls: %v", calls)
+		}
+		stage := strings.TrimSuffix(strings.TrimPrefix(calls[2], "transfer-to vm:"), "/secure.log")
+		if !strings.HasPrefix(stage, "/tmp/terraform-multipass-download-") {
+			t.Fatalf("expected a transfer from the staging directory, got %q", calls[2])
+		}
+		if !strings.HasPrefix(calls[1], "exec vm sudo -n sh -c") || !strings.HasSuffix(calls[1], " /var/log/secure.log "+stage) {
+			t.Fatalf("expected the source to be staged with sudo, got %q", calls[1])
+		}
+		if calls[3] != "exec vm sudo -n rm -rf -- "+stage {
+			t.Fatalf("expected staging cleanup, got %q", calls[3])
+		}
+		if model.Source.ValueString() != "/var/log/secure.log" || model.Content.ValueString() != "secret\n" {
+			t.Fatalf("unexpected model after download: source=%s content=%s", model.Source, model.Content)
+		}
+	})
+
+	t.Run("cleans up when the transfer fails", func(t *testing.T) {
+		client := &fakeClient{
+			transferTo: func(multipasscli.TransferOptions, io.Writer) error {
+				return errors.New("connection reset")
+			},
+		}
+		r := &fileDownloadResource{client: client, hostOS: "windows", transferStrategy: transferStrategyTar}
+		model := newModel(filepath.Join(t.TempDir(), "secure.log"))
+		if diags := r.downloadAndWrite(context.Background(), &model); !diags.HasError() {
+			t.Fatal("expected a download error")
+		}
+		calls := client.recorded()
+		if got := calls[len(calls)-1]; !strings.HasPrefix(got, "exec vm sudo -n rm -rf -- /tmp/terraform-multipass-download-") {
+			t.Fatalf("expected staging cleanup, got %q", got)
+		}
+	})
+
+	t.Run("requires passwordless sudo", func(t *testing.T) {
+		client := &fakeClient{
+			execCapture: func(string, []string) ([]byte, error) {
+				return nil, errors.New("sudo: a password is required")
+			},
+		}
+		r := &fileDownloadResource{client: client, transferStrategy: transferStrategyTar}
+		model := newModel(filepath.Join(t.TempDir(), "secure.log"))
+		diags := r.downloadAndWrite(context.Background(), &model)
+		if !diags.HasError() || diags[0].Summary() != "Passwordless sudo unavailable" {
+			t.Fatalf("expected sudo diagnostic, got %v", diags)
+		}
+		if calls := client.recorded(); len(calls) != 1 {
+			t.Fatalf("expected nothing to be staged, got %v", calls)
+		}
+	})
+}
+
+func TestLimitedBuffer(t *testing.T) {
+	t.Parallel()
+
+	b := &limitedBuffer{limit: 4}
+	io.WriteString(b, "ab")
+	io.WriteString(b, "cd")
+	if got := string(b.Bytes()); got != "abcd" {
+		t.Fatalf("expected buffered payload, got %q", got)
+	}
+	io.WriteString(b, "e")
+	if b.Bytes() != nil {
+		t.Fatal("expected nil after exceeding the limit")
+	}
+
+	if empty := (&limitedBuffer{limit: 4}).Bytes(); empty == nil || len(empty) != 0 {
+		t.Fatalf("expected empty non-nil payload, got %#v", empty)
+	}
+}
+
+func TestParseDownloadImportID(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		id                     string
+		instance, source, dest string
+	}{
+		{id: "vm:/etc/hosts:/tmp/hosts", instance: "vm", source: "/etc/hosts", dest: "/tmp/hosts"},
+		{id: `vm:/etc/hosts:C:\Users\me\hosts`, instance: "vm", source: "/etc/hosts", dest: `C:\Users\me\hosts`},
+		{id: `vm:~/.kube/config:D:\kube\config:backup`, instance: "vm", source: "~/.kube/config", dest: `D:\kube\config:backup`},
+		{id: `vm:/etc/hosts->C:\Users\me\hosts`, instance: "vm", source: "/etc/hosts", dest: `C:\Users\me\hosts`},
+		{id: "vm:%2Fetc%2Fhosts:C%3A%5CUsers%5Cme%5Chosts", instance: "vm", source: "/etc/hosts", dest: `C:\Users\me\hosts`},
+		{id: "vm:%2Fdata%2Fa%3Ab:%2Ftmp%2Fb", instance: "vm", source: "/data/a:b", dest: "/tmp/b"},
+	}
+	for _, tc := range cases {
+		instance, source, dest, err := parseDownloadImportID(tc.id)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.id, err)
+		}
+		if instance != tc.instance || source != tc.source || dest != tc.dest {
+			t.Fatalf("%s: got (%q, %q, %q)", tc.id, instance, source, dest)
+		}
+
+		// The id recorded in state must import back to the same values.
+		instance, source, dest, err = parseDownloadImportID(downloadResourceID(instance, source, dest))
+		if err != nil || instance != tc.instance || source != tc.source || dest != tc.dest {
+			t.Fatalf("%s: round trip gave (%q, %q, %q, %v)", tc.id, instance, source, dest, err)
+		}
+	}
+
+	for _, id := range []string{"vm", "vm:/etc/hosts", ":/etc/hosts:/tmp/hosts", "vm::/tmp/hosts", "vm:/etc/hosts:"} {
+		if _, _, _, err := parseDownloadImportID(id); err == nil {
+			t.Fatalf("%s: expected an error", id)
+		}
+	}
+}
+
+func TestImportDownload(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	importDownload := func(t *testing.T, client *fakeClient, id string) (fileDownloadResourceModel, diag.Diagnostics) {
+		t.Helper()
+		r := &fileDownloadResource{client: client}
+		var schemaResp resource.SchemaResponse
+		r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+		resp := resource.ImportStateResponse{State: tfsdk.State{
+			Schema: schemaResp.Schema,
+			Raw:    tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil),
+		}}
+		r.ImportState(ctx, resource.ImportStateRequest{ID: id}, &resp)
+
+		var model fileDownloadResourceModel
+		if !resp.Diagnostics.HasError() {
+			resp.Diagnostics.Append(resp.State.Get(ctx, &model)...)
+		}
+		return model, resp.Diagnostics
+	}
+
+	t.Run("file", func(t *testing.T) {
+		dest := filepath.Join(t.TempDir(), "hosts")
+		if err := os.WriteFile(dest, []byte("127.0.0.1 localhost\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		model, diags := importDownload(t, &fakeClient{}, "vm:/etc/hosts:"+dest)
+		if diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		if model.ID.ValueString() != downloadResourceID("vm", "/etc/hosts", dest) || model.Source.ValueString() != "/etc/hosts" || model.Destination.ValueString() != dest {
+			t.Fatalf("unexpected identity: %s / %s / %s", model.ID, model.Source, model.Destination)
+		}
+		if model.ContentHash.ValueString() != hashBytes([]byte("127.0.0.1 localhost\n")) || model.Recursive.ValueBool() {
+			t.Fatalf("unexpected content_hash %s (recursive %s)", model.ContentHash, model.Recursive)
+		}
+		if model.Content.ValueString() != "127.0.0.1 localhost\n" {
+			t.Fatalf("unexpected content %q", model.Content.ValueString())
+		}
+	})
+
+	t.Run("directory", func(t *testing.T) {
+		dest := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dest, "a.log"), []byte("a"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		want, err := hashDirectory(dest)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		model, diags := importDownload(t, &fakeClient{}, "vm:/var/log/app:"+dest)
+		if diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		if !model.Recursive.ValueBool() || model.ContentHash.ValueString() != want || !model.Content.IsNull() {
+			t.Fatalf("unexpected directory import: recursive %s, hash %s", model.Recursive, model.ContentHash)
+		}
+	})
+
+	t.Run("missing destination", func(t *testing.T) {
+		_, diags := importDownload(t, &fakeClient{}, "vm:/etc/hosts:"+filepath.Join(t.TempDir(), "missing"))
+		if !diags.HasError() || diags.Errors()[0].Summary() != "Destination not found" {
+			t.Fatalf("expected a missing destination error, got %v", diags)
+		}
+	})
+
+	t.Run("missing instance", func(t *testing.T) {
+		client := &fakeClient{getInstance: func(string) (*models.Instance, error) {
+			return nil, multipasscli.ErrNotFound
+		}}
+		_, diags := importDownload(t, client, "vm:/etc/hosts:/tmp/hosts")
+		if !diags.HasError() || diags.Errors()[0].Summary() != "Instance not found" {
+			t.Fatalf("expected a missing instance error, got %v", diags)
+		}
+	})
+}
+
+func TestUpgradeDownloadStateEscapesID(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	r := &fileDownloadResource{}
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	for version, upgrader := range r.UpgradeState(ctx) {
+		prior := fileDownloadResourceModel{
+			ID:             types.StringValue(`vm:/etc/hosts->C:\Users\me\hosts`),
+			Instance:       types.StringValue("vm"),
+			Source:         types.StringValue("/etc/hosts"),
+			Destination:    types.StringValue(`C:\Users\me\hosts`),
+			ContentHash:    types.StringValue("abc"),
+			Sources:        types.ListNull(types.StringType),
+			MatchedSources: types.ListNull(types.StringType),
+			Triggers:       types.MapNull(types.StringType),
+			Timeouts: timeouts.Value{Object: types.ObjectNull(map[string]attr.Type{
+				"create": types.StringType,
+				"update": types.StringType,
+			})},
+		}
+		state := tfsdk.State{Schema: *upgrader.PriorSchema}
+		if diags := state.Set(ctx, &prior); diags.HasError() {
+			t.Fatalf("v%d: set state: %v", version, diags)
+		}
+
+		resp := resource.UpgradeStateResponse{State: tfsdk.State{Schema: schemaResp.Schema}}
+		upgrader.StateUpgrader(ctx, resource.UpgradeStateRequest{State: &state}, &resp)
+		if resp.Diagnostics.HasError() {
+			t.Fatalf("v%d: unexpected diagnostics: %v", version, resp.Diagnostics)
+		}
+		var upgraded fileDownloadResourceModel
+		resp.State.Get(ctx, &upgraded)
+		if got, want := upgraded.ID.ValueString(), "vm:%2Fetc%2Fhosts:C%3A%5CUsers%5Cme%5Chosts"; got != want {
+			t.Fatalf("v%d: id = %q, want %q", version, got, want)
+		}
+		if upgraded.ContentHash.ValueString() != "abc" {
+			t.Fatalf("v%d: content_hash not carried over", version)
+		}
+	}
+}
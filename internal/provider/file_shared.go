@@ -1,16 +1,101 @@
 package provider
 
 import (
+	"archive/tar"
+	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+// Values of the provider's transfer_strategy. Resources only ever see
+// direct or tar; auto is resolved at configure time.
+const (
+	transferStrategyAuto   = "auto"
+	transferStrategyDirect = "direct"
+	transferStrategyTar    = "tar"
 )
 
-func hashPath(p string, recursive bool) (string, error) {
+// resolveTransferStrategy validates a transfer_strategy value and resolves
+// auto for hostOS: tar on Windows, where `multipass transfer` of directories
+// into host paths is unreliable, and direct elsewhere. An empty value is
+// treated as auto.
+func resolveTransferStrategy(strategy, hostOS string) (string, error) {
+	switch strategy {
+	case transferStrategyDirect, transferStrategyTar:
+		return strategy, nil
+	case "", transferStrategyAuto:
+		if hostOS == "windows" {
+			return transferStrategyTar, nil
+		}
+		return transferStrategyDirect, nil
+	}
+	return "", fmt.Errorf("transfer_strategy must be %q, %q or %q, got %q", transferStrategyAuto, transferStrategyDirect, transferStrategyTar, strategy)
+}
+
+// excludePatterns holds `excludes` globs for directory uploads. A pattern
+// without "/" matches the base name of an entry at any depth (".git",
+// "*.log"); a pattern with "/" matches the slash-separated path relative to
+// the uploaded directory ("build/out"). Excluding a directory excludes
+// everything below it.
+type excludePatterns []string
+
+// matches reports whether rel, a slash-separated path relative to the
+// uploaded directory, is excluded.
+func (e excludePatterns) matches(rel string) bool {
+	for _, pattern := range e {
+		target := path.Base(rel)
+		if strings.Contains(strings.Trim(pattern, "/"), "/") {
+			target = rel
+		}
+		if ok, _ := path.Match(strings.Trim(pattern, "/"), target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// checkExcludePattern reports whether pattern is a malformed glob.
+func checkExcludePattern(pattern string) error {
+	_, err := path.Match(pattern, "")
+	return err
+}
+
+// skip is a filepath.WalkDir helper: it reports whether current, below root,
+// is excluded and, for directories, returns filepath.SkipDir to prune it.
+func (e excludePatterns) skip(root, current string, d fs.DirEntry) (bool, error) {
+	if len(e) == 0 || current == root {
+		return false, nil
+	}
+	rel, err := filepath.Rel(root, current)
+	if err != nil {
+		return false, err
+	}
+	if !e.matches(filepath.ToSlash(rel)) {
+		return false, nil
+	}
+	if d.IsDir() {
+		return true, filepath.SkipDir
+	}
+	return true, nil
+}
+
+func hashPath(p string, recursive bool, excludes excludePatterns) (string, error) {
 	abs, err := filepath.Abs(p)
 	if err != nil {
 		return "", err
@@ -24,7 +109,7 @@ func hashPath(p string, recursive bool) (string, error) {
 		if !recursive {
 			return "", fmt.Errorf("path %q is a directory; set `recursive = true`", p)
 		}
-		return hashDirectory(abs)
+		return hashFilteredDirectory(abs, excludes)
 	}
 	return hashFile(abs)
 }
@@ -43,15 +128,37 @@ func hashFile(path string) (string, error) {
 	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
+// directoryHashPrefix marks directory hashes that include file modes and
+// symlink targets. Unprefixed (v1) hashes only cover paths and contents;
+// because the prefix makes every v1 value differ, resources written with v1
+// hashes are re-synchronised exactly once after upgrading.
+const directoryHashPrefix = "v2:"
+
+// hashDirectory returns the current (v2) hash of the tree rooted at root.
 func hashDirectory(root string) (string, error) {
+	return hashFilteredDirectory(root, nil)
+}
+
+// hashFilteredDirectory is hashDirectory without the entries matched by
+// excludes. The patterns themselves are part of the hash, so changing only
+// the exclude list changes it too; without patterns the result equals
+// hashDirectory.
+func hashFilteredDirectory(root string, excludes excludePatterns) (string, error) {
 	h := sha256.New()
-	if err := walkDirectory(root, root, h); err != nil {
+	if len(excludes) > 0 {
+		fmt.Fprintf(h, "excludes\x00%s\n", strings.Join(excludes, "\x00"))
+	}
+	if err := walkDirectory(root, root, h, true, excludes); err != nil {
 		return "", err
 	}
-	return hex.EncodeToString(h.Sum(nil)), nil
+	return directoryHashPrefix + hex.EncodeToString(h.Sum(nil)), nil
 }
 
-func walkDirectory(root, current string, h io.Writer) error {
+// walkDirectory feeds the tree below current into h in a stable order,
+// leaving out entries matched by excludes. With metadata set it also mixes
+// in permission bits and, for symlinks, the link target without following
+// it; otherwise it reproduces the v1 hash.
+func walkDirectory(root, current string, h io.Writer, metadata bool, excludes excludePatterns) error {
 	entries, err := os.ReadDir(current)
 	if err != nil {
 		return err
@@ -66,12 +173,35 @@ func walkDirectory(root, current string, h io.Writer) error {
 		if err != nil {
 			return err
 		}
+		if excludes.matches(filepath.ToSlash(rel)) {
+			continue
+		}
 		if _, err := h.Write([]byte(filepath.ToSlash(rel))); err != nil {
 			return err
 		}
 
+		if metadata {
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(h, "\x00%o\x00", info.Mode().Perm()); err != nil {
+				return err
+			}
+			if entry.Type()&fs.ModeSymlink != 0 {
+				target, err := os.Readlink(path)
+				if err != nil {
+					return err
+				}
+				if _, err := fmt.Fprintf(h, "->%s\x00", filepath.ToSlash(target)); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
 		if entry.IsDir() {
-			if err := walkDirectory(root, path, h); err != nil {
+			if err := walkDirectory(root, path, h, metadata, excludes); err != nil {
 				return err
 			}
 			continue
@@ -92,3 +222,329 @@ func hashBytes(data []byte) string {
 	sum := sha256.Sum256(data)
 	return hex.EncodeToString(sum[:])
 }
+
+// remoteHashScript prints the SHA256 of a remote path, or "missing" when the
+// path does not exist. Files hash their contents; directories hash the
+// `sha256sum` manifest of every regular file below them, sorted bytewise so
+// the result matches hashDirectoryManifest on the host.
+const remoteHashScript = `p="$1"
+if [ -d "$p" ]; then
+  cd "$p" && find . -type f -print0 | LC_ALL=C sort -z | xargs -0 -r sha256sum | sha256sum
+elif [ -e "$p" ]; then
+  sha256sum < "$p"
+else
+  echo missing
+fi`
+
+// remoteHash returns the hash of path inside instance as computed by
+// remoteHashScript, run with sudo when sudo is set. exists is false when the
+// path is absent.
+func remoteHash(ctx context.Context, client multipasscli.Client, instance, path string, sudo bool) (hash string, exists bool, err error) {
+	out, err := client.ExecCapture(ctx, instance, sudoCommand(sudo, "sh", "-c", remoteHashScript, "sh", path))
+	if err != nil {
+		return "", false, err
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		return "", false, fmt.Errorf("unexpected empty hash output for %s", path)
+	}
+	if fields[0] == "missing" {
+		return "", false, nil
+	}
+	return fields[0], true, nil
+}
+
+// remoteLastModified returns the newest modification time among paths inside
+// the instance, as reported by `stat -c %Y`, run with sudo when sudo is set.
+func remoteLastModified(ctx context.Context, client multipasscli.Client, instance string, paths []string, sudo bool) (time.Time, error) {
+	args := append([]string{"stat", "-L", "-c", "%Y", "--"}, paths...)
+	out, err := client.ExecCapture(ctx, instance, sudoCommand(sudo, args...))
+	if err != nil {
+		return time.Time{}, err
+	}
+	var newest int64
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		return time.Time{}, fmt.Errorf("unexpected empty stat output for %s", strings.Join(paths, ", "))
+	}
+	for _, field := range fields {
+		secs, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("unexpected stat output %q", field)
+		}
+		if secs > newest {
+			newest = secs
+		}
+	}
+	return time.Unix(newest, 0), nil
+}
+
+// hashDirectoryManifest mirrors the directory branch of remoteHashScript:
+// it hashes the `sha256sum`-formatted listing of every regular file below
+// root that is not matched by excludes, ordered bytewise by relative path.
+func hashDirectoryManifest(root string, excludes excludePatterns) (string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if skip, err := excludes.skip(root, path, d); skip {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, "./"+filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(files)
+
+	var manifest bytes.Buffer
+	for _, rel := range files {
+		sum, err := hashFile(filepath.Join(root, filepath.FromSlash(strings.TrimPrefix(rel, "./"))))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&manifest, "%s  %s\n", sum, rel)
+	}
+	return hashBytes(manifest.Bytes()), nil
+}
+
+// countFiles returns the number of non-directory entries below root.
+func countFiles(root string) (int, error) {
+	count := 0
+	err := filepath.WalkDir(root, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// tarPaths builds an uncompressed tarball. Each entry maps an archive prefix
+// to a local file or directory; an empty prefix places a directory's
+// contents at the archive root. Entries matched by excludes, relative to
+// each directory, are left out. Ownership is omitted so the archive extracts
+// as the user running tar, and symlinks are stored without being followed.
+func tarPaths(entries map[string]string, excludes excludePatterns) ([]byte, error) {
+	prefixes := make([]string, 0, len(entries))
+	for prefix := range entries {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, prefix := range prefixes {
+		root := entries[prefix]
+		err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if skip, err := excludes.skip(root, p, d); skip {
+				return err
+			}
+			rel, err := filepath.Rel(root, p)
+			if err != nil {
+				return err
+			}
+			name := path.Join(prefix, filepath.ToSlash(rel))
+			if name == "." || name == "" {
+				return nil
+			}
+
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			link := ""
+			if info.Mode()&fs.ModeSymlink != 0 {
+				if link, err = os.Readlink(p); err != nil {
+					return err
+				}
+			}
+			hdr, err := tar.FileInfoHeader(info, link)
+			if err != nil {
+				return err
+			}
+			hdr.Name = name
+			if info.IsDir() {
+				hdr.Name += "/"
+			}
+			hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = io.Copy(tw, f)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// maxSourceURLRedirects caps how many redirects fetchSourceURL follows.
+const maxSourceURLRedirects = 10
+
+// fetchSourceURL downloads rawURL into a temporary file, verifying it
+// against expectedSHA256 when set, and returns the payload together with its
+// SHA256 digest. The temporary file is removed before returning.
+func fetchSourceURL(ctx context.Context, rawURL string, timeout time.Duration, expectedSHA256 string) ([]byte, string, error) {
+	httpClient := &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxSourceURLRedirects {
+				return fmt.Errorf("stopped after %d redirects (last location %s)", maxSourceURLRedirects, req.URL.Redacted())
+			}
+			return nil
+		},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg := fmt.Sprintf("GET %s returned %s", req.URL.Redacted(), resp.Status)
+		if final := resp.Request.URL.String(); final != req.URL.String() {
+			msg += fmt.Sprintf(" (after redirect to %s)", resp.Request.URL.Redacted())
+		}
+		return nil, "", errors.New(msg)
+	}
+
+	tmp, err := os.CreateTemp("", "terraform-multipass-url-*")
+	if err != nil {
+		return nil, "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, h), resp.Body); err != nil {
+		return nil, "", fmt.Errorf("download %s: %w", req.URL.Redacted(), err)
+	}
+	digest := hex.EncodeToString(h.Sum(nil))
+	if expectedSHA256 != "" && !strings.EqualFold(digest, expectedSHA256) {
+		return nil, "", fmt.Errorf("checksum mismatch for %s: expected %s, got %s", req.URL.Redacted(), strings.ToLower(expectedSHA256), digest)
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, "", err
+	}
+	data, err := io.ReadAll(tmp)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, digest, nil
+}
+
+// defaultGuestHome is the home directory of the default user in Multipass
+// Ubuntu images, against which `~` in remote paths is expanded.
+const defaultGuestHome = "/home/ubuntu"
+
+// resolveRemotePath expands a leading "~" or "~/" to defaultGuestHome and
+// requires the result to be absolute. Trailing slashes are preserved because
+// they mark directory destinations.
+func resolveRemotePath(p string) (string, error) {
+	if strings.ContainsAny(p, "\n\x00") {
+		return "", fmt.Errorf("path %q must not contain newlines or NUL bytes", p)
+	}
+	switch {
+	case p == "~":
+		p = defaultGuestHome
+	case strings.HasPrefix(p, "~/"):
+		p = defaultGuestHome + p[1:]
+	}
+	if !strings.HasPrefix(p, "/") {
+		return "", fmt.Errorf("path %q must be absolute or start with ~/", p)
+	}
+	return p, nil
+}
+
+// directoryStats returns the number of regular files below root that are
+// not matched by excludes and their combined size, matching
+// remoteDirStatsScript.
+func directoryStats(root string, excludes excludePatterns) (files int, size int64, err error) {
+	err = filepath.WalkDir(root, func(current string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if skip, err := excludes.skip(root, current, d); skip {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files++
+		size += info.Size()
+		return nil
+	})
+	return files, size, err
+}
+
+// transferResourceID joins an instance name and paths into the ID of a file
+// transfer resource. Paths are URL path-escaped, including colons, so the ID
+// splits unambiguously on ":" even when a Windows destination carries a
+// drive letter, and contains no slashes or backslashes.
+func transferResourceID(instance string, paths ...string) string {
+	parts := []string{instance}
+	for _, p := range paths {
+		parts = append(parts, strings.ReplaceAll(url.PathEscape(p), ":", "%3A"))
+	}
+	return strings.Join(parts, ":")
+}
+
+// parseTransferResourceID reverses transferResourceID for an ID holding n
+// paths. ok is false when id is not in that form, for example because it is
+// a human-written import ID with literal slashes.
+func parseTransferResourceID(id string, n int) (instance string, paths []string, ok bool) {
+	if strings.ContainsAny(id, `/\`) {
+		return "", nil, false
+	}
+	parts := strings.Split(id, ":")
+	if len(parts) != n+1 || parts[0] == "" {
+		return "", nil, false
+	}
+	for _, part := range parts[1:] {
+		p, err := url.PathUnescape(part)
+		if err != nil || p == "" {
+			return "", nil, false
+		}
+		paths = append(paths, p)
+	}
+	return parts[0], paths, true
+}
@@ -1,9 +1,19 @@
 package provider
 
 import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestHashPathFile(t *testing.T) {
@@ -16,7 +26,7 @@ func TestHashPathFile(t *testing.T) {
 		t.Fatalf("write file: %v", err)
 	}
 
-	got, err := hashPath(path, false)
+	got, err := hashPath(path, false, nil)
 	if err != nil {
 		t.Fatalf("hashPath returned error: %v", err)
 	}
@@ -34,7 +44,7 @@ func TestHashPathDirectoryRequiresRecursive(t *testing.T) {
 		t.Fatalf("mkdir: %v", err)
 	}
 
-	if _, err := hashPath(dir, false); err == nil {
+	if _, err := hashPath(dir, false, nil); err == nil {
 		t.Fatalf("expected error when hashing directory without recursion")
 	}
 }
@@ -55,13 +65,13 @@ func TestHashDirectoryDetectsChanges(t *testing.T) {
 	}
 	write(filepath.Join(dir, "nested", "b.txt"), "two")
 
-	initial, err := hashPath(dir, true)
+	initial, err := hashPath(dir, true, nil)
 	if err != nil {
 		t.Fatalf("hashPath initial: %v", err)
 	}
 
 	write(filepath.Join(dir, "a.txt"), "changed")
-	updated, err := hashPath(dir, true)
+	updated, err := hashPath(dir, true, nil)
 	if err != nil {
 		t.Fatalf("hashPath updated: %v", err)
 	}
@@ -70,3 +80,348 @@ func TestHashDirectoryDetectsChanges(t *testing.T) {
 		t.Fatalf("expected hash to change after modifying directory contents")
 	}
 }
+
+func TestHashDirectoryManifestMatchesSha256sumListing(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "nested"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "b.txt"), []byte("two"), 0o600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "nested", "a.txt"), []byte("one"), 0o600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	got, err := hashDirectoryManifest(dir, nil)
+	if err != nil {
+		t.Fatalf("hashDirectoryManifest: %v", err)
+	}
+
+	// Equivalent to: find . -type f -print0 | LC_ALL=C sort -z | xargs -0 sha256sum | sha256sum
+	manifest := hashBytes([]byte("two")) + "  ./b.txt\n" +
+		hashBytes([]byte("one")) + "  ./nested/a.txt\n"
+	if want := hashBytes([]byte(manifest)); got != want {
+		t.Fatalf("manifest hash mismatch: got %s want %s", got, want)
+	}
+}
+
+func TestHashDirectoryDetectsModeChanges(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	script := filepath.Join(dir, "run.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	initial, err := hashDirectory(dir)
+	if err != nil {
+		t.Fatalf("hashDirectory initial: %v", err)
+	}
+	if !strings.HasPrefix(initial, directoryHashPrefix) {
+		t.Fatalf("expected %q prefix, got %s", directoryHashPrefix, initial)
+	}
+
+	if err := os.Chmod(script, 0o755); err != nil {
+		t.Fatalf("chmod: %v", err)
+	}
+	updated, err := hashDirectory(dir)
+	if err != nil {
+		t.Fatalf("hashDirectory updated: %v", err)
+	}
+	if initial == updated {
+		t.Fatalf("expected hash to change after chmod")
+	}
+}
+
+func TestHashDirectoryDetectsSymlinkTargetChanges(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("same"), 0o644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+	link := filepath.Join(dir, "current")
+	if err := os.Symlink("a.txt", link); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+
+	initial, err := hashDirectory(dir)
+	if err != nil {
+		t.Fatalf("hashDirectory initial: %v", err)
+	}
+
+	if err := os.Remove(link); err != nil {
+		t.Fatalf("remove link: %v", err)
+	}
+	if err := os.Symlink("b.txt", link); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+	updated, err := hashDirectory(dir)
+	if err != nil {
+		t.Fatalf("hashDirectory updated: %v", err)
+	}
+	if initial == updated {
+		t.Fatalf("expected hash to change after repointing symlink")
+	}
+}
+
+func TestHashDirectoryV1Unchanged(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("one"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	h := sha256.New()
+	if err := walkDirectory(dir, dir, h, false, nil); err != nil {
+		t.Fatalf("walkDirectory: %v", err)
+	}
+	want := hashBytes([]byte("a.txt" + hashBytes([]byte("one"))))
+	if got := hex.EncodeToString(h.Sum(nil)); got != want {
+		t.Fatalf("v1 hash changed: got %s want %s", got, want)
+	}
+}
+
+func TestTarPaths(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "bin"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "bin", "run"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	data, err := tarPaths(map[string]string{"": dir, "extra": filepath.Join(dir, "bin", "run")}, nil)
+	if err != nil {
+		t.Fatalf("tarPaths: %v", err)
+	}
+
+	var names []string
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read tar: %v", err)
+		}
+		names = append(names, hdr.Name)
+		if hdr.Name == "bin/run" && hdr.FileInfo().Mode().Perm() != 0o755 {
+			t.Fatalf("mode not preserved: %o", hdr.FileInfo().Mode().Perm())
+		}
+	}
+
+	want := []string{"bin/", "bin/run", "extra"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected entries: %v", names)
+	}
+}
+
+func TestFetchSourceURL(t *testing.T) {
+	t.Parallel()
+
+	payload := []byte("release tarball")
+	mux := http.NewServeMux()
+	mux.HandleFunc("/artifact", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(payload)
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/missing", http.StatusFound)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx := context.Background()
+
+	data, digest, err := fetchSourceURL(ctx, srv.URL+"/artifact", time.Second, strings.ToUpper(hashBytes(payload)))
+	if err != nil {
+		t.Fatalf("fetchSourceURL: %v", err)
+	}
+	if !bytes.Equal(data, payload) || digest != hashBytes(payload) {
+		t.Fatalf("unexpected payload %q / digest %s", data, digest)
+	}
+
+	if _, _, err := fetchSourceURL(ctx, srv.URL+"/artifact", time.Second, hashBytes([]byte("other"))); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+
+	if _, _, err := fetchSourceURL(ctx, srv.URL+"/moved", time.Second, ""); err == nil || !strings.Contains(err.Error(), "404") || !strings.Contains(err.Error(), "redirect to") {
+		t.Fatalf("expected 404 after redirect, got %v", err)
+	}
+
+	if _, _, err := fetchSourceURL(ctx, srv.URL+"/loop", time.Second, ""); err == nil || !strings.Contains(err.Error(), "redirects") {
+		t.Fatalf("expected redirect limit error, got %v", err)
+	}
+}
+
+func TestResolveTransferStrategy(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		strategy, hostOS, want string
+	}{
+		{"", "windows", transferStrategyTar},
+		{"", "linux", transferStrategyDirect},
+		{transferStrategyAuto, "windows", transferStrategyTar},
+		{transferStrategyAuto, "darwin", transferStrategyDirect},
+		{transferStrategyTar, "linux", transferStrategyTar},
+		{transferStrategyDirect, "windows", transferStrategyDirect},
+	}
+	for _, tc := range cases {
+		got, err := resolveTransferStrategy(tc.strategy, tc.hostOS)
+		if err != nil || got != tc.want {
+			t.Fatalf("resolveTransferStrategy(%q, %q) = %q, %v; want %q", tc.strategy, tc.hostOS, got, err, tc.want)
+		}
+	}
+	if _, err := resolveTransferStrategy("rsync", "linux"); err == nil {
+		t.Fatal("expected an error for an unknown strategy")
+	}
+}
+
+func TestResolveRemotePath(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"~":              "/home/ubuntu",
+		"~/":             "/home/ubuntu/",
+		"~/app.conf":     "/home/ubuntu/app.conf",
+		"/etc/myapp/":    "/etc/myapp/",
+		"/opt/app":       "/opt/app",
+		"/tmp/~/literal": "/tmp/~/literal",
+	}
+	for in, want := range cases {
+		got, err := resolveRemotePath(in)
+		if err != nil {
+			t.Fatalf("resolveRemotePath(%q): %v", in, err)
+		}
+		if got != want {
+			t.Fatalf("resolveRemotePath(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	for _, in := range []string{"app.conf", "./app.conf", "~user/app", "", "/tmp/a\nb", "/tmp/a\x00b"} {
+		if _, err := resolveRemotePath(in); err == nil {
+			t.Fatalf("expected error for %q", in)
+		}
+	}
+}
+
+func TestTransferResourceID(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		instance string
+		paths    []string
+		want     string
+	}{
+		{instance: "vm", paths: []string{"/etc/hosts"}, want: "vm:%2Fetc%2Fhosts"},
+		{instance: "vm", paths: []string{"/data/a:b"}, want: "vm:%2Fdata%2Fa%3Ab"},
+		{instance: "vm", paths: []string{"/etc/hosts", `C:\Users\me\hosts`}, want: "vm:%2Fetc%2Fhosts:C%3A%5CUsers%5Cme%5Chosts"},
+		{instance: "vm", paths: []string{"~/my file", "/tmp/100%"}, want: "vm:~%2Fmy%20file:%2Ftmp%2F100%25"},
+	}
+	for _, tc := range cases {
+		id := transferResourceID(tc.instance, tc.paths...)
+		if id != tc.want {
+			t.Fatalf("transferResourceID(%q, %q) = %q, want %q", tc.instance, tc.paths, id, tc.want)
+		}
+		instance, paths, ok := parseTransferResourceID(id, len(tc.paths))
+		if !ok || instance != tc.instance || strings.Join(paths, "\x00") != strings.Join(tc.paths, "\x00") {
+			t.Fatalf("parseTransferResourceID(%q) = %q, %q, %t", id, instance, paths, ok)
+		}
+	}
+
+	for _, id := range []string{"vm:/etc/hosts", `vm:C:\hosts`, "vm:%2Fa", "vm:%2Fa:%2Fb:%2Fc", ":%2Fa:%2Fb", "vm:%zz:%2Fb"} {
+		if _, _, ok := parseTransferResourceID(id, 2); ok {
+			t.Fatalf("parseTransferResourceID(%q) unexpectedly succeeded", id)
+		}
+	}
+}
+
+func TestExcludePatterns(t *testing.T) {
+	t.Parallel()
+
+	excludes := excludePatterns{".git", "*.log", "build/out", "/dist/"}
+	cases := map[string]bool{
+		".git":             true,
+		"vendor/.git":      true,
+		"app.log":          true,
+		"logs/app.log":     true,
+		"build/out":        true,
+		"build/cache":      false,
+		"src/build/out":    false,
+		"dist":             true,
+		"main.go":          false,
+		".gitignore":       false,
+		"src/app.log.keep": false,
+	}
+	for rel, want := range cases {
+		if got := excludes.matches(rel); got != want {
+			t.Fatalf("matches(%q) = %t, want %t", rel, got, want)
+		}
+	}
+}
+
+func TestHashFilteredDirectory(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for name, body := range map[string]string{
+		"main.go":         "package main\n",
+		".git/HEAD":       "ref: refs/heads/main\n",
+		"build/out/app":   "binary",
+		"build/README.md": "docs",
+	} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	unfiltered, err := hashFilteredDirectory(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain, _ := hashDirectory(dir); plain != unfiltered {
+		t.Fatalf("hash without excludes differs from hashDirectory")
+	}
+
+	excludes := excludePatterns{".git", "build/out"}
+	filtered, err := hashFilteredDirectory(dir, excludes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filtered == unfiltered {
+		t.Fatalf("expected excludes to change the hash")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ".git", "HEAD"), []byte("changed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := hashFilteredDirectory(dir, excludes); again != filtered {
+		t.Fatalf("changes to excluded entries must not change the hash")
+	}
+	if other, _ := hashFilteredDirectory(dir, excludePatterns{".git"}); other == filtered {
+		t.Fatalf("changing the exclude list must change the hash")
+	}
+
+	files, size, err := directoryStats(dir, excludes)
+	if err != nil || files != 2 || size != int64(len("package main\n")+len("docs")) {
+		t.Fatalf("directoryStats = %d, %d, %v", files, size, err)
+	}
+}
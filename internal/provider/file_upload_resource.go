@@ -2,13 +2,21 @@ package provider
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"time"
 
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/listvalidator"
 	stringvalidator "github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
 	"github.com/hashicorp/terraform-plugin-framework-timeouts/resource/timeouts"
 	"github.com/hashicorp/terraform-plugin-framework/diag"
@@ -20,7 +28,9 @@ import (
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
 	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
 )
 
@@ -37,20 +47,39 @@ func NewFileUploadResource() resource.Resource {
 }
 
 type fileUploadResource struct {
-	client         multipasscli.Client
-	commandTimeout time.Duration
+	client           multipasscli.Client
+	transferStrategy string
+	commandTimeout   time.Duration
 }
 
 type fileUploadResourceModel struct {
-	ID            types.String   `tfsdk:"id"`
-	Instance      types.String   `tfsdk:"instance"`
-	Destination   types.String   `tfsdk:"destination"`
-	Source        types.String   `tfsdk:"source"`
-	Content       types.String   `tfsdk:"content"`
-	Recursive     types.Bool     `tfsdk:"recursive"`
-	CreateParents types.Bool     `tfsdk:"create_parents"`
-	ContentHash   types.String   `tfsdk:"content_hash"`
-	Timeouts      timeouts.Value `tfsdk:"timeouts"`
+	ID                types.String   `tfsdk:"id"`
+	Instance          types.String   `tfsdk:"instance"`
+	Destination       types.String   `tfsdk:"destination"`
+	ResolvedDest      types.String   `tfsdk:"resolved_destination"`
+	Source            types.String   `tfsdk:"source"`
+	Content           types.String   `tfsdk:"content"`
+	ContentBase64     types.String   `tfsdk:"content_base64"`
+	Sources           types.List     `tfsdk:"sources"`
+	SourceURL         types.String   `tfsdk:"source_url"`
+	SourceURLSHA256   types.String   `tfsdk:"source_url_sha256"`
+	SourceURLTimeout  types.Int64    `tfsdk:"source_url_timeout"`
+	Recursive         types.Bool     `tfsdk:"recursive"`
+	CreateParents     types.Bool     `tfsdk:"create_parents"`
+	Mode              types.String   `tfsdk:"mode"`
+	Owner             types.String   `tfsdk:"owner"`
+	Group             types.String   `tfsdk:"group"`
+	Archive           types.String   `tfsdk:"archive"`
+	Excludes          types.List     `tfsdk:"excludes"`
+	VerifyRemote      types.Bool     `tfsdk:"verify_remote"`
+	KeepOnDestroy     types.Bool     `tfsdk:"keep_on_destroy"`
+	UseSudo           types.Bool     `tfsdk:"use_sudo"`
+	VerifyAfterUpload types.Bool     `tfsdk:"verify_after_upload"`
+	WaitForInstance   types.Bool     `tfsdk:"wait_for_instance"`
+	StartIfStopped    types.Bool     `tfsdk:"start_if_stopped"`
+	WaitTimeout       types.Int64    `tfsdk:"wait_timeout"`
+	ContentHash       types.String   `tfsdk:"content_hash"`
+	Timeouts          timeouts.Value `tfsdk:"timeouts"`
 }
 
 func (r *fileUploadResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
@@ -61,15 +90,20 @@ func (r *fileUploadResource) Schema(ctx context.Context, _ resource.SchemaReques
 	oneOf := []path.Expression{
 		path.MatchRelative().AtParent().AtName("source"),
 		path.MatchRelative().AtParent().AtName("content"),
+		path.MatchRelative().AtParent().AtName("content_base64"),
+		path.MatchRelative().AtParent().AtName("sources"),
+		path.MatchRelative().AtParent().AtName("source_url"),
 	}
 
 	resp.Schema = schema.Schema{
+		// Version 1: id escapes the destination.
+		Version:     1,
 		Description: "Uploads local files, inline content, or directories to Multipass instances via `multipass transfer`.",
 		Attributes: map[string]schema.Attribute{
 			"id": schema.StringAttribute{
 				Computed:            true,
-				Description:         "Canonical identifier in the form `<instance>:<destination>`.",
-				MarkdownDescription: "Canonical identifier in the form `<instance>:<destination>`.",
+				Description:         "Canonical identifier in the form `<instance>:<destination>`, with the destination URL-escaped.",
+				MarkdownDescription: "Canonical identifier in the form `<instance>:<destination>`, with the destination URL-escaped (including `:`).",
 				PlanModifiers: []planmodifier.String{
 					stringplanmodifier.UseStateForUnknown(),
 				},
@@ -85,15 +119,26 @@ func (r *fileUploadResource) Schema(ctx context.Context, _ resource.SchemaReques
 			"destination": schema.StringAttribute{
 				Required:            true,
 				Description:         "Absolute or relative path inside the instance (e.g. `/home/ubuntu/setup.sh`).",
-				MarkdownDescription: "Absolute or relative path inside the instance where the file or directory will be written.",
+				MarkdownDescription: "Absolute path inside the instance where the file or directory will be written. A leading `~/` refers to the default user's home (`/home/ubuntu`).",
 				PlanModifiers: []planmodifier.String{
 					stringplanmodifier.RequiresReplace(),
 				},
+				Validators: []validator.String{
+					remotePathValidator{},
+				},
+			},
+			"resolved_destination": schema.StringAttribute{
+				Computed:            true,
+				Description:         "Absolute destination path used inside the instance.",
+				MarkdownDescription: "Absolute destination path used for every operation inside the instance, with a leading `~/` expanded to `/home/ubuntu/`.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
 			},
 			"source": schema.StringAttribute{
 				Optional:            true,
 				Description:         "Local path to the file or directory that should be uploaded.",
-				MarkdownDescription: "Local path to the file or directory that should be uploaded. Conflicts with `content`.",
+				MarkdownDescription: "Local path to the file or directory that should be uploaded. Conflicts with `content`, `content_base64`, `sources` and `source_url`.",
 				Validators: []validator.String{
 					stringvalidator.ExactlyOneOf(oneOf...),
 				},
@@ -102,11 +147,58 @@ func (r *fileUploadResource) Schema(ctx context.Context, _ resource.SchemaReques
 				Optional:            true,
 				Sensitive:           true,
 				Description:         "Inline file content to upload.",
-				MarkdownDescription: "Inline file content to upload. Conflicts with `source`.",
+				MarkdownDescription: "Inline file content to upload. Conflicts with `source`, `content_base64`, `sources` and `source_url`.",
 				Validators: []validator.String{
 					stringvalidator.ExactlyOneOf(oneOf...),
 				},
 			},
+			"content_base64": schema.StringAttribute{
+				Optional:            true,
+				Sensitive:           true,
+				Description:         "Base64-encoded content to upload, for binary payloads.",
+				MarkdownDescription: "Base64-encoded content to upload, for binary payloads that would not survive as a UTF-8 string (e.g. `filebase64(\"cert.der\")`). Decoded before the transfer. Conflicts with `source`, `content`, `sources` and `source_url`.",
+				Validators: []validator.String{
+					stringvalidator.ExactlyOneOf(oneOf...),
+					base64Validator{},
+				},
+			},
+			"sources": schema.ListAttribute{
+				Optional:            true,
+				ElementType:         types.StringType,
+				Description:         "Local files or directories uploaded together into the destination directory.",
+				MarkdownDescription: "Local files or directories uploaded together into the destination directory with a single `multipass transfer` call. Conflicts with `source`, `content`, `content_base64` and `source_url`; `destination` must end with `/` when more than one entry is given.",
+				Validators: []validator.List{
+					listvalidator.ExactlyOneOf(oneOf...),
+					listvalidator.SizeAtLeast(1),
+				},
+			},
+			"source_url": schema.StringAttribute{
+				Optional:            true,
+				Description:         "HTTP(S) URL of a remote artifact to download and upload.",
+				MarkdownDescription: "HTTP(S) URL of a remote artifact that the provider downloads and then uploads. Conflicts with `source`, `content`, `content_base64` and `sources`.",
+				Validators: []validator.String{
+					stringvalidator.ExactlyOneOf(oneOf...),
+					stringvalidator.RegexMatches(sourceURLRegex, "must be an http:// or https:// URL"),
+				},
+			},
+			"source_url_sha256": schema.StringAttribute{
+				Optional:            true,
+				Description:         "Expected SHA256 of the artifact at source_url.",
+				MarkdownDescription: "Expected SHA256 of the artifact at `source_url`. The apply fails when the download does not match. When set, the URL is only fetched again if the checksum changes.",
+				Validators: []validator.String{
+					stringvalidator.AlsoRequires(path.MatchRelative().AtParent().AtName("source_url")),
+					stringvalidator.RegexMatches(sha256Regex, "must be a hex-encoded SHA256 digest"),
+				},
+			},
+			"source_url_timeout": schema.Int64Attribute{
+				Optional:            true,
+				Description:         "Maximum seconds allowed for downloading source_url. Defaults to 300.",
+				MarkdownDescription: "Maximum seconds allowed for downloading `source_url`. Defaults to `300`.",
+				Validators: []validator.Int64{
+					int64validator.AtLeast(1),
+					int64validator.AlsoRequires(path.MatchRelative().AtParent().AtName("source_url")),
+				},
+			},
 			"recursive": schema.BoolAttribute{
 				Optional:            true,
 				Computed:            true,
@@ -121,6 +213,81 @@ func (r *fileUploadResource) Schema(ctx context.Context, _ resource.SchemaReques
 				Description:         "Create destination parent directories as needed (maps to `multipass transfer --parents`).",
 				MarkdownDescription: "Create destination parent directories as needed (maps to `multipass transfer --parents`).",
 			},
+			"mode": schema.StringAttribute{
+				Optional:            true,
+				Description:         "Octal permissions applied after the transfer (e.g. `0755`).",
+				MarkdownDescription: "Octal permissions applied with `chmod` after the transfer (e.g. `0755`). Applied recursively when `recursive` is true.",
+				Validators: []validator.String{
+					stringvalidator.RegexMatches(fileModeRegex, "must be an octal mode such as 0644 or 0755"),
+				},
+			},
+			"owner": schema.StringAttribute{
+				Optional:            true,
+				Description:         "User that should own the uploaded path.",
+				MarkdownDescription: "User that should own the uploaded path. Applied with `sudo chown` when it differs from the instance's default user.",
+			},
+			"group": schema.StringAttribute{
+				Optional:            true,
+				Description:         "Group that should own the uploaded path.",
+				MarkdownDescription: "Group that should own the uploaded path. Applied with `sudo chown` when it differs from the instance's default user.",
+			},
+			"wait_for_instance": schema.BoolAttribute{
+				Optional:            true,
+				Description:         "Wait for the instance to be running before transferring. Defaults to true.",
+				MarkdownDescription: "Wait for the instance to report `Running` before transferring. Defaults to `true`.",
+			},
+			"start_if_stopped": schema.BoolAttribute{
+				Optional:            true,
+				Description:         "Start the instance if it is stopped or suspended when waiting for it.",
+				MarkdownDescription: "Start the instance if it is stopped or suspended while `wait_for_instance` is enabled. Defaults to `false`.",
+			},
+			"wait_timeout": schema.Int64Attribute{
+				Optional:            true,
+				Description:         "Maximum seconds to wait for the instance to be running. Defaults to 300.",
+				MarkdownDescription: "Maximum seconds to wait for the instance to be running. Defaults to `300`.",
+				Validators: []validator.Int64{
+					int64validator.AtLeast(1),
+				},
+			},
+			"excludes": schema.ListAttribute{
+				Optional:            true,
+				ElementType:         types.StringType,
+				Description:         "Glob patterns of entries to leave out of directory uploads.",
+				MarkdownDescription: "Glob patterns of entries to leave out of directory uploads, e.g. `[\".git\", \".terraform\", \"*.log\", \"build/out\"]`. A pattern without `/` matches an entry's base name at any depth; a pattern with `/` matches the path relative to the uploaded directory. Excluded entries are ignored by `content_hash`, the transfer and verification alike, and changing the list re-uploads the directory.",
+				Validators: []validator.List{
+					listvalidator.ValueStringsAre(excludePatternValidator{}),
+				},
+			},
+			"archive": schema.StringAttribute{
+				Optional:            true,
+				Description:         "Directory transport strategy: auto, always or never. Defaults to always when the provider's transfer_strategy is tar, and auto otherwise.",
+				MarkdownDescription: "Directory transport strategy. `always` packs directories into a single tarball, transfers it and extracts it with `tar` inside the instance; `never` uses `multipass transfer --recursive`; `auto` archives directories with more than 64 files. When unset, defaults to `always` if the provider's `transfer_strategy` resolves to `tar`, and to `auto` otherwise. Falls back to the direct transfer when the guest has no `tar`.",
+				Validators: []validator.String{
+					stringvalidator.OneOf(archiveAuto, archiveAlways, archiveNever),
+				},
+			},
+			"verify_remote": schema.BoolAttribute{
+				Optional:            true,
+				Computed:            true,
+				Default:             booldefault.StaticBool(false),
+				Description:         "Hash the remote path during refresh and re-upload when it was changed or removed outside Terraform.",
+				MarkdownDescription: "Hash the remote path with `sha256sum` during refresh and re-upload when it was changed or removed outside Terraform. Skipped with a warning while the instance is not running.",
+			},
+			"keep_on_destroy": schema.BoolAttribute{
+				Optional:            true,
+				Description:         "Leave the uploaded path in place when the resource is destroyed.",
+				MarkdownDescription: "Leave the uploaded path in place when the resource is destroyed instead of running `rm -rf`. Defaults to `false`.",
+			},
+			"verify_after_upload": schema.BoolAttribute{
+				Optional:            true,
+				Description:         "Check the payload inside the instance after each transfer and fail the apply on mismatch.",
+				MarkdownDescription: "Check the payload inside the instance after each transfer and fail the apply when it does not match. Files are compared by SHA256; directories by file count and total size. Defaults to `true` for file and inline uploads and `false` when a directory is uploaded.",
+			},
+			"use_sudo": schema.BoolAttribute{
+				Optional:            true,
+				Description:         "Stage the payload under /tmp and move it into place with sudo.",
+				MarkdownDescription: "Stage the payload under `/tmp` and copy it into `destination` with `sudo`, for paths such as `/etc` or `/usr/local/bin` that the default user cannot write. Requires passwordless sudo in the instance. Permissions and removal on destroy also run through `sudo`. Defaults to `false`.",
+			},
 			"content_hash": schema.StringAttribute{
 				Computed:            true,
 				Description:         "SHA256 hash of the payload sent to the instance. Changes trigger updates.",
@@ -146,6 +313,7 @@ func (r *fileUploadResource) Configure(_ context.Context, req resource.Configure
 
 	data := req.ProviderData.(providerData)
 	r.client = data.client
+	r.transferStrategy = data.transferStrategy
 	r.commandTimeout = data.commandTimeout
 }
 
@@ -160,11 +328,11 @@ func (r *fileUploadResource) ModifyPlan(ctx context.Context, req resource.Modify
 		return
 	}
 
-	if plan.Source.IsUnknown() || plan.Content.IsUnknown() {
+	if plan.Source.IsUnknown() || plan.Content.IsUnknown() || plan.ContentBase64.IsUnknown() || plan.Sources.IsUnknown() || plan.SourceURL.IsUnknown() || plan.SourceURLSHA256.IsUnknown() || plan.Excludes.IsUnknown() {
 		resp.Diagnostics.AddAttributeError(
 			path.Root("source"),
 			"Unknown file inputs",
-			"`source` or `content` must be known during planning.",
+			"`source`, `content`, `content_base64`, `sources`, `source_url` and `excludes` must be known during planning.",
 		)
 		return
 	}
@@ -174,12 +342,49 @@ func (r *fileUploadResource) ModifyPlan(ctx context.Context, req resource.Modify
 		return
 	}
 
-	if plan.Source.IsNull() && plan.Content.IsNull() {
-		resp.Diagnostics.AddError("Missing file inputs", "Provide either `source` or `content`.")
+	if plan.Source.IsNull() && plan.Content.IsNull() && plan.ContentBase64.IsNull() && plan.Sources.IsNull() && plan.SourceURL.IsNull() {
+		resp.Diagnostics.AddError("Missing file inputs", "Provide one of `source`, `content`, `content_base64`, `sources` or `source_url`.")
+		return
+	}
+
+	// Remote artifacts are not fetched during planning. Without a checksum
+	// the previous digest is kept while the URL is unchanged; a new URL
+	// leaves content_hash unknown until the download happens.
+	if !plan.SourceURL.IsNull() {
+		plan.ContentHash = types.StringUnknown()
+		if hasStringValue(plan.SourceURLSHA256) {
+			plan.ContentHash = types.StringValue(strings.ToLower(plan.SourceURLSHA256.ValueString()))
+		} else if !req.State.Raw.IsNull() {
+			var state fileUploadResourceModel
+			resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+			if resp.Diagnostics.HasError() {
+				return
+			}
+			if state.SourceURL.Equal(plan.SourceURL) && !state.ContentHash.IsNull() {
+				plan.ContentHash = state.ContentHash
+			}
+		}
+		resp.Diagnostics.Append(resp.Plan.Set(ctx, &plan)...)
+		return
+	}
+
+	resolved, err := resolveRemotePath(plan.Destination.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("destination"), "Invalid destination", err.Error())
 		return
 	}
+	plan.ResolvedDest = types.StringValue(resolved)
 
-	hashValue, diags := r.computeHash(&plan)
+	if len(plan.Sources.Elements()) > 1 && !strings.HasSuffix(resolved, "/") {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("destination"),
+			"Destination must be a directory",
+			"When `sources` has more than one entry, `destination` must end with `/` so every entry is placed inside it.",
+		)
+		return
+	}
+
+	hashValue, diags := r.computeHash(ctx, &plan)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
@@ -201,6 +406,9 @@ func (r *fileUploadResource) Create(ctx context.Context, req resource.CreateRequ
 		return
 	}
 
+	ctx, done := startOperation(ctx, "multipass_file_upload", "create", plan.Instance.ValueString())
+	defer done(&resp.Diagnostics)
+
 	createTimeout, diags := plan.Timeouts.Create(ctx, r.commandTimeout)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
@@ -209,36 +417,39 @@ func (r *fileUploadResource) Create(ctx context.Context, req resource.CreateRequ
 	ctx, cancel := context.WithTimeout(ctx, createTimeout)
 	defer cancel()
 
-	hashValue, diags := r.computeHash(&plan)
-	resp.Diagnostics.Append(diags...)
+	_, waitDiags := waitForTransferInstance(ctx, r.client, plan.Instance.ValueString(), plan.WaitForInstance, plan.StartIfStopped, plan.WaitTimeout)
+	resp.Diagnostics.Append(waitDiags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	srcPath, content, diags := r.prepareLocalSource(&plan)
+	hashValue, diags := r.computeHash(ctx, &plan)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	target := fmt.Sprintf("%s:%s", plan.Instance.ValueString(), plan.Destination.ValueString())
-	transferOpts := multipasscli.TransferOptions{
-		Destination: target,
-		Recursive:   plan.Recursive.ValueBool(),
-		Parents:     plan.CreateParents.ValueBool(),
+	digest, diags := r.transferPayload(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
 	}
-	if content != nil {
-		transferOpts.Stdin = content
-	} else {
-		transferOpts.Sources = []string{srcPath}
+	if digest != "" {
+		hashValue = digest
 	}
-	err := r.client.Transfer(ctx, transferOpts)
-	if err != nil {
-		resp.Diagnostics.AddError("Failed to transfer file", err.Error())
+	plan.ContentHash = types.StringValue(hashValue)
+
+	resp.Diagnostics.Append(r.verifyUpload(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if err := r.applyPermissions(ctx, &plan); err != nil {
+		resp.Diagnostics.AddError("Failed to apply file permissions", err.Error())
 		return
 	}
 
-	plan.ID = types.StringValue(fmt.Sprintf("%s:%s", plan.Instance.ValueString(), plan.Destination.ValueString()))
+	plan.ID = types.StringValue(transferResourceID(plan.Instance.ValueString(), plan.Destination.ValueString()))
 	plan.ContentHash = types.StringValue(hashValue)
 	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
 }
@@ -255,12 +466,16 @@ func (r *fileUploadResource) Read(ctx context.Context, req resource.ReadRequest,
 		return
 	}
 
+	ctx, done := startOperation(ctx, "multipass_file_upload", "read", state.Instance.ValueString())
+	defer done(&resp.Diagnostics)
+
 	if state.Instance.IsNull() || state.Instance.ValueString() == "" {
 		resp.State.RemoveResource(ctx)
 		return
 	}
 
-	if _, err := r.client.GetInstance(ctx, state.Instance.ValueString()); err != nil {
+	instance, err := r.client.GetInstance(ctx, state.Instance.ValueString())
+	if err != nil {
 		if err == multipasscli.ErrNotFound {
 			resp.State.RemoveResource(ctx)
 			return
@@ -269,21 +484,644 @@ func (r *fileUploadResource) Read(ctx context.Context, req resource.ReadRequest,
 		return
 	}
 
+	if state.VerifyRemote.ValueBool() && !state.ContentHash.IsNull() {
+		resp.Diagnostics.Append(r.verifyRemote(ctx, instance, &state)...)
+	}
+
 	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
 }
 
+// Values accepted by the archive attribute.
+const (
+	archiveAuto   = "auto"
+	archiveAlways = "always"
+	archiveNever  = "never"
+)
+
+// archiveAutoThreshold is the number of files above which archive = "auto"
+// switches directory uploads to a single tarball. Below it the per-file
+// round trips of `multipass transfer --recursive` are cheap enough.
+const archiveAutoThreshold = 64
+
+// transferPayload sends the configured payload to the instance, either
+// directly via `multipass transfer` or, for directories, as a tarball that
+// is extracted in place. For source_url uploads it returns the verified
+// digest of the downloaded artifact.
+func (r *fileUploadResource) transferPayload(ctx context.Context, model *fileUploadResourceModel) (string, diag.Diagnostics) {
+	if model.UseSudo.ValueBool() {
+		return r.transferWithSudo(ctx, model)
+	}
+	return r.transferDirect(ctx, model)
+}
+
+// sudoPromoteScript copies the staged payload at "$1$2" to "$2" as root,
+// creating parent directories when "$3" is 1, and always removes the
+// staging directory "$1", preserving the copy's exit status.
+const sudoPromoteScript = `src="$1$2"
+if [ -d "$src" ]; then
+  mkdir -p -- "$2" && cp -R -- "$src/." "$2"
+else
+  { [ "$3" != 1 ] || mkdir -p -- "$(dirname -- "$2")"; } && cp -- "$src" "$2"
+fi
+rc=$?
+rm -rf -- "$1"
+exit $rc`
+
+// transferWithSudo uploads the payload into a private staging directory
+// under /tmp, mirroring the destination path, and copies it into place with
+// sudo. The staging directory is removed whether or not the copy succeeds.
+func (r *fileUploadResource) transferWithSudo(ctx context.Context, model *fileUploadResourceModel) (string, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	instance := model.Instance.ValueString()
+	if _, err := r.client.ExecCapture(ctx, instance, []string{"sudo", "-n", "true"}); err != nil {
+		diags.AddAttributeError(
+			path.Root("use_sudo"),
+			"Passwordless sudo unavailable",
+			fmt.Sprintf("use_sudo requires the default user in %q to run sudo without a password: %s", instance, err),
+		)
+		return "", diags
+	}
+
+	dest := model.remoteDestination()
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		diags.AddError("Failed to generate staging path", err.Error())
+		return "", diags
+	}
+	stage := "/tmp/terraform-multipass-stage-" + hex.EncodeToString(suffix)
+	staged := *model
+	staged.ResolvedDest = types.StringValue(stage + dest)
+	staged.CreateParents = types.BoolValue(true)
+
+	digest, diags := r.transferDirect(ctx, &staged)
+	if diags.HasError() {
+		_ = r.client.Exec(ctx, instance, []string{"rm", "-rf", "--", stage})
+		return "", diags
+	}
+
+	parents := "0"
+	if model.CreateParents.ValueBool() {
+		parents = "1"
+	}
+	if err := r.client.Exec(ctx, instance, []string{"sudo", "-n", "sh", "-c", sudoPromoteScript, "sh", stage, dest, parents}); err != nil {
+		diags.AddError("Failed to move staged upload into place", err.Error())
+		return "", diags
+	}
+	return digest, diags
+}
+
+// transferDirect writes the payload to the model's remote destination as
+// the instance's default user.
+func (r *fileUploadResource) transferDirect(ctx context.Context, model *fileUploadResourceModel) (string, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	if !model.SourceURL.IsNull() {
+		timeout := time.Duration(valueOrDefaultInt(model.SourceURLTimeout, int(defaultSourceURLTimeout/time.Second))) * time.Second
+		data, digest, err := fetchSourceURL(ctx, model.SourceURL.ValueString(), timeout, valueOrEmpty(model.SourceURLSHA256))
+		if err != nil {
+			diags.AddAttributeError(path.Root("source_url"), "Failed to download source_url", err.Error())
+			return "", diags
+		}
+		if err := r.transfer(ctx, model, multipasscli.TransferOptions{
+			Destination: fmt.Sprintf("%s:%s", model.Instance.ValueString(), model.remoteDestination()),
+			Parents:     model.CreateParents.ValueBool(),
+			Stdin:       data,
+		}); err != nil {
+			diags.AddError("Failed to transfer file", err.Error())
+			return "", diags
+		}
+		return digest, diags
+	}
+
+	srcPaths, content, diags := r.prepareLocalSource(ctx, model)
+	if diags.HasError() {
+		return "", diags
+	}
+
+	excludes := uploadExcludes(ctx, model)
+	if content == nil && r.useArchive(ctx, model, srcPaths, len(excludes) > 0) {
+		diags.Append(r.transferArchive(ctx, model, srcPaths, excludes)...)
+		return "", diags
+	}
+	if content == nil && len(excludes) > 0 {
+		staged, cleanup, stageDiags := stageFilteredSources(srcPaths, excludes)
+		defer cleanup()
+		diags.Append(stageDiags...)
+		if diags.HasError() {
+			return "", diags
+		}
+		srcPaths = staged
+	}
+
+	target := fmt.Sprintf("%s:%s", model.Instance.ValueString(), model.remoteDestination())
+	transferOpts := multipasscli.TransferOptions{
+		Destination: target,
+		Recursive:   model.Recursive.ValueBool(),
+		Parents:     model.CreateParents.ValueBool(),
+	}
+	if content != nil {
+		transferOpts.Stdin = content
+	} else {
+		transferOpts.Sources = srcPaths
+	}
+	if err := r.transfer(ctx, model, transferOpts); err != nil {
+		diags.AddError("Failed to transfer file", err.Error())
+	}
+	return "", diags
+}
+
+// transfer runs a `multipass transfer` to the model's destination. With
+// create_parents, `--parents` only creates what the transfer itself can, so
+// when the transfer fails because the parent directory is missing or not
+// writable, the parent is created with `mkdir -p` and the transfer retried
+// once. The first error is kept if either step fails.
+func (r *fileUploadResource) transfer(ctx context.Context, model *fileUploadResourceModel, opts multipasscli.TransferOptions) error {
+	err := r.client.Transfer(ctx, opts)
+	if err == nil || !model.CreateParents.ValueBool() || !isTransferPathError(err) {
+		return err
+	}
+
+	instance := model.Instance.ValueString()
+	parent := remoteParentDir(model.remoteDestination())
+	if parent == "" {
+		return err
+	}
+	tflog.Warn(ctx, "Transfer failed; creating the parent directory and retrying", map[string]any{
+		"instance": instance,
+		"parent":   parent,
+		"error":    err.Error(),
+	})
+	if mkdirErr := r.client.Exec(ctx, instance, []string{"mkdir", "-p", "--", parent}); mkdirErr != nil {
+		return fmt.Errorf("%w (creating %s with mkdir -p also failed: %v; set use_sudo = true to upload where the default user cannot write)", err, parent, mkdirErr)
+	}
+	if retryErr := r.client.Transfer(ctx, opts); retryErr != nil {
+		return fmt.Errorf("%w (retrying after creating %s also failed: %v)", err, parent, retryErr)
+	}
+	return nil
+}
+
+// transferPathMessages are the ways a transfer reports that the destination's
+// directory is missing or not writable, lower-cased.
+var transferPathMessages = []string{
+	"permission denied",
+	"no such file or directory",
+}
+
+// isTransferPathError reports whether a transfer failed because of the
+// destination's directory rather than, say, the daemon or the source.
+func isTransferPathError(err error) bool {
+	if errors.Is(err, multipasscli.ErrNotFound) {
+		return true
+	}
+	lower := strings.ToLower(err.Error())
+	for _, msg := range transferPathMessages {
+		if strings.Contains(lower, msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteParentDir returns the directory a transfer to dest writes into:
+// dest itself when it ends with "/", otherwise its parent.
+func remoteParentDir(dest string) string {
+	return dest[:strings.LastIndex(dest, "/")+1]
+}
+
+// useArchive decides whether srcPaths should be sent as a tarball. With
+// excludes the archive is preferred under `auto` regardless of size, since
+// it filters without staging a copy of the directory. An unset archive
+// follows the provider's transfer_strategy.
+func (r *fileUploadResource) useArchive(ctx context.Context, model *fileUploadResourceModel, srcPaths []string, excludes bool) bool {
+	defaultStrategy := archiveAuto
+	if r.transferStrategy == transferStrategyTar {
+		defaultStrategy = archiveAlways
+	}
+	strategy := valueOrDefaultString(model.Archive, defaultStrategy)
+	if strategy == archiveNever {
+		return false
+	}
+
+	files, hasDir := 0, false
+	for _, src := range srcPaths {
+		info, err := os.Stat(src)
+		if err != nil || !info.IsDir() {
+			files++
+			continue
+		}
+		hasDir = true
+		n, err := countFiles(src)
+		if err != nil {
+			return false
+		}
+		files += n
+	}
+	if !hasDir || (strategy == archiveAuto && !excludes && files <= archiveAutoThreshold) {
+		return false
+	}
+
+	if _, err := r.client.ExecCapture(ctx, model.Instance.ValueString(), []string{"sh", "-c", "command -v tar"}); err != nil {
+		tflog.Warn(ctx, "tar is unavailable in the instance; falling back to multipass transfer --recursive", map[string]any{
+			"instance": model.Instance.ValueString(),
+		})
+		return false
+	}
+	return true
+}
+
+// archiveExtractScript creates the destination, unpacks the staged tarball
+// into it and always removes the tarball, preserving tar's exit status.
+const archiveExtractScript = `mkdir -p -- "$1" && tar -xf "$2" -C "$1"; rc=$?; rm -f -- "$2"; exit $rc`
+
+// transferArchive packs srcPaths, minus excludes, into one tarball, stages it
+// under /tmp in the instance and extracts it into the destination.
+func (r *fileUploadResource) transferArchive(ctx context.Context, model *fileUploadResourceModel, srcPaths []string, excludes excludePatterns) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	instance := model.Instance.ValueString()
+	dest := model.remoteDestination()
+
+	// A single directory becomes the destination itself; multiple entries
+	// land inside the destination under their base names.
+	var data []byte
+	var err error
+	if len(srcPaths) == 1 && !strings.HasSuffix(dest, "/") {
+		data, err = tarPaths(map[string]string{"": srcPaths[0]}, excludes)
+	} else {
+		entries := make(map[string]string, len(srcPaths))
+		for _, src := range srcPaths {
+			entries[filepath.Base(src)] = src
+		}
+		data, err = tarPaths(entries, excludes)
+	}
+	if err != nil {
+		diags.AddError("Failed to archive source", err.Error())
+		return diags
+	}
+
+	staged := fmt.Sprintf("/tmp/terraform-multipass-upload-%d.tar", time.Now().UnixNano())
+	if err := r.client.Transfer(ctx, multipasscli.TransferOptions{
+		Destination: fmt.Sprintf("%s:%s", instance, staged),
+		Stdin:       data,
+	}); err != nil {
+		_ = r.client.Exec(ctx, instance, []string{"rm", "-f", "--", staged})
+		diags.AddError("Failed to transfer archive", err.Error())
+		return diags
+	}
+
+	if err := r.client.Exec(ctx, instance, []string{"sh", "-c", archiveExtractScript, "sh", dest, staged}); err != nil {
+		diags.AddError("Failed to extract archive", err.Error())
+	}
+	return diags
+}
+
+// uploadTarget pairs a path written inside the instance with the local path
+// it was uploaded from (empty for inline content).
+type uploadTarget struct {
+	remote string
+	local  string
+}
+
+// remoteDestination returns the resolved destination, falling back to
+// resolving destination for state written before resolved_destination
+// existed.
+func (m *fileUploadResourceModel) remoteDestination() string {
+	if hasStringValue(m.ResolvedDest) {
+		return m.ResolvedDest.ValueString()
+	}
+	if resolved, err := resolveRemotePath(m.Destination.ValueString()); err == nil {
+		return resolved
+	}
+	return m.Destination.ValueString()
+}
+
+// uploadTargets lists the remote paths managed by the resource. Entries of
+// `sources` are placed under their base names when the destination ends
+// with "/"; otherwise the destination itself is the only target.
+func uploadTargets(ctx context.Context, model *fileUploadResourceModel) ([]uploadTarget, diag.Diagnostics) {
+	dest := model.remoteDestination()
+	if model.Sources.IsNull() {
+		return []uploadTarget{{remote: dest, local: valueOrEmpty(model.Source)}}, nil
+	}
+
+	sources, diags := uploadSources(ctx, model)
+	targets := make([]uploadTarget, 0, len(sources))
+	for _, src := range sources {
+		remote := dest
+		if strings.HasSuffix(dest, "/") {
+			remote = dest + filepath.Base(src)
+		}
+		targets = append(targets, uploadTarget{remote: remote, local: src})
+	}
+	return targets, diags
+}
+
+// verifyRemote compares the remote payload against the uploaded one and
+// clears content_hash on drift so the next plan schedules a re-transfer.
+func (r *fileUploadResource) verifyRemote(ctx context.Context, instance *models.Instance, state *fileUploadResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	if !strings.EqualFold(instance.State, "Running") {
+		diags.AddWarning(
+			"Skipping remote verification",
+			fmt.Sprintf("Instance %q is %s; the contents of %s cannot be verified until it is running.", instance.Name, instance.State, state.remoteDestination()),
+		)
+		return diags
+	}
+
+	targets, d := uploadTargets(ctx, state)
+	diags.Append(d...)
+	if diags.HasError() {
+		return diags
+	}
+
+	for _, target := range targets {
+		expected, err := expectedRemoteHash(ctx, target, state)
+		if err != nil {
+			diags.AddWarning("Skipping remote verification", fmt.Sprintf("Unable to hash %s: %s", target.local, err))
+			return diags
+		}
+
+		actual, exists, err := remoteHash(ctx, r.client, instance.Name, target.remote, false)
+		if err != nil {
+			diags.AddWarning("Failed to verify remote path", err.Error())
+			return diags
+		}
+
+		if !exists {
+			tflog.Info(ctx, "Remote path missing; scheduling re-upload", map[string]any{
+				"instance":    instance.Name,
+				"destination": target.remote,
+			})
+			state.ContentHash = types.StringNull()
+			return diags
+		}
+		if actual != expected {
+			tflog.Info(ctx, "Remote path changed outside Terraform; scheduling re-upload", map[string]any{
+				"instance":    instance.Name,
+				"destination": target.remote,
+			})
+			state.ContentHash = types.StringNull()
+			break
+		}
+	}
+
+	if !hasStringValue(state.Mode) && !hasStringValue(state.Owner) && !hasStringValue(state.Group) {
+		return diags
+	}
+
+	command := []string{"stat", "-c", "%a %U %G", "--"}
+	for _, target := range targets {
+		command = append(command, target.remote)
+	}
+	out, err := r.client.ExecCapture(ctx, instance.Name, command)
+	if err != nil {
+		diags.AddWarning("Failed to verify remote permissions", err.Error())
+		return diags
+	}
+
+	// Only record differing values so that equivalent spellings such as
+	// "755" and "0755" do not produce a diff.
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			diags.AddWarning("Failed to verify remote permissions", fmt.Sprintf("unexpected stat output %q", line))
+			return diags
+		}
+		if hasStringValue(state.Mode) && !sameFileMode(state.Mode.ValueString(), fields[0]) {
+			state.Mode = types.StringValue(fields[0])
+		}
+		if hasStringValue(state.Owner) && state.Owner.ValueString() != fields[1] {
+			state.Owner = types.StringValue(fields[1])
+		}
+		if hasStringValue(state.Group) && state.Group.ValueString() != fields[2] {
+			state.Group = types.StringValue(fields[2])
+		}
+	}
+	return diags
+}
+
+// remoteDirStatsScript prints the number of regular files below "$1" and
+// their combined size in bytes.
+const remoteDirStatsScript = `find "$1" -type f -exec stat -c %s {} + | awk '{n++; s+=$1} END {print n+0, s+0}'`
+
+// verifyUpload checks the freshly transferred payload inside the instance,
+// since `multipass transfer` can exit 0 after truncating a file on a full
+// disk. Files are compared by hash; directories only by file count and total
+// size, which is far cheaper than hashing every file remotely.
+func (r *fileUploadResource) verifyUpload(ctx context.Context, model *fileUploadResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	targets, d := uploadTargets(ctx, model)
+	diags.Append(d...)
+	if diags.HasError() {
+		return diags
+	}
+
+	hasDir := false
+	for _, target := range targets {
+		if info, err := os.Stat(target.local); err == nil && info.IsDir() {
+			hasDir = true
+			break
+		}
+	}
+	if model.VerifyAfterUpload.IsNull() || model.VerifyAfterUpload.IsUnknown() {
+		if hasDir {
+			return diags
+		}
+	} else if !model.VerifyAfterUpload.ValueBool() {
+		return diags
+	}
+
+	instance := model.Instance.ValueString()
+	for _, target := range targets {
+		if info, err := os.Stat(target.local); err == nil && info.IsDir() {
+			files, size, err := directoryStats(target.local, uploadExcludes(ctx, model))
+			if err != nil {
+				diags.AddError("Failed to verify upload", fmt.Sprintf("Unable to inspect %s: %s", target.local, err))
+				return diags
+			}
+			out, err := r.client.ExecCapture(ctx, instance, []string{"sh", "-c", remoteDirStatsScript, "sh", target.remote})
+			if err != nil {
+				diags.AddError("Failed to verify upload", err.Error())
+				return diags
+			}
+			var remoteFiles int
+			var remoteSize int64
+			if _, err := fmt.Sscan(string(out), &remoteFiles, &remoteSize); err != nil {
+				diags.AddError("Failed to verify upload", fmt.Sprintf("unexpected output %q", strings.TrimSpace(string(out))))
+				return diags
+			}
+			// Extra files already present in the destination are fine;
+			// fewer files or bytes mean the transfer was cut short.
+			if remoteFiles < files || remoteSize < size {
+				diags.AddError(
+					"Uploaded directory is incomplete",
+					fmt.Sprintf("%s inside %q holds %d files (%d bytes) but %s has %d files (%d bytes). The transfer may have been truncated; check free disk space in the instance.",
+						target.remote, instance, remoteFiles, remoteSize, target.local, files, size),
+				)
+				return diags
+			}
+			continue
+		}
+
+		// A single file sent to a directory destination lands under its
+		// base name.
+		if target.local != "" && strings.HasSuffix(target.remote, "/") {
+			target.remote += filepath.Base(target.local)
+		}
+
+		expected, err := expectedRemoteHash(ctx, target, model)
+		if err != nil {
+			diags.AddError("Failed to verify upload", fmt.Sprintf("Unable to hash %s: %s", target.local, err))
+			return diags
+		}
+		actual, exists, err := remoteHash(ctx, r.client, instance, target.remote, false)
+		if err != nil {
+			diags.AddError("Failed to verify upload", err.Error())
+			return diags
+		}
+		if !exists {
+			diags.AddError("Uploaded file is missing", fmt.Sprintf("%s does not exist inside %q after the transfer.", target.remote, instance))
+			return diags
+		}
+		if actual != expected {
+			diags.AddError(
+				"Uploaded file does not match",
+				fmt.Sprintf("%s inside %q has SHA256 %s, expected %s. The transfer may have been truncated; check free disk space in the instance.",
+					target.remote, instance, actual, expected),
+			)
+			return diags
+		}
+	}
+	return diags
+}
+
+// expectedRemoteHash returns the hash remoteHashScript should report for
+// target. Single files and inline content are compared against
+// content_hash; directories and `sources` entries are re-hashed locally.
+func expectedRemoteHash(ctx context.Context, target uploadTarget, state *fileUploadResourceModel) (string, error) {
+	if target.local == "" {
+		return state.ContentHash.ValueString(), nil
+	}
+	info, err := os.Stat(target.local)
+	if err != nil {
+		if state.Sources.IsNull() {
+			return state.ContentHash.ValueString(), nil
+		}
+		return "", err
+	}
+	if info.IsDir() {
+		return hashDirectoryManifest(target.local, uploadExcludes(ctx, state))
+	}
+	if state.Sources.IsNull() {
+		return state.ContentHash.ValueString(), nil
+	}
+	return hashFile(target.local)
+}
+
+// applyPermissions sets mode and ownership on the uploaded paths. `multipass
+// exec` runs as the instance's default user, so chown only needs sudo when
+// ownership moves away from that user or the payload was placed with
+// use_sudo.
+func (r *fileUploadResource) applyPermissions(ctx context.Context, model *fileUploadResourceModel) error {
+	owner := valueOrEmpty(model.Owner)
+	group := valueOrEmpty(model.Group)
+	mode := valueOrEmpty(model.Mode)
+	if owner == "" && group == "" && mode == "" {
+		return nil
+	}
+
+	instance := model.Instance.ValueString()
+	targets, diags := uploadTargets(ctx, model)
+	if diags.HasError() {
+		return fmt.Errorf("resolve upload targets: %v", diags)
+	}
+	paths := make([]string, 0, len(targets))
+	for _, target := range targets {
+		paths = append(paths, target.remote)
+	}
+
+	var sudo []string
+	if model.UseSudo.ValueBool() {
+		sudo = []string{"sudo"}
+	} else if owner != "" || group != "" {
+		out, err := r.client.ExecCapture(ctx, instance, []string{"id", "-un"})
+		if err != nil {
+			return fmt.Errorf("determine default user: %w", err)
+		}
+		user := strings.TrimSpace(string(out))
+		if (owner != "" && owner != user) || (group != "" && group != user) {
+			sudo = []string{"sudo"}
+		}
+	}
+
+	var recursive []string
+	if model.Recursive.ValueBool() {
+		recursive = []string{"-R"}
+	}
+
+	if owner != "" || group != "" {
+		spec := owner
+		if group != "" {
+			spec += ":" + group
+		}
+		command := append(append(append([]string{}, sudo...), "chown"), recursive...)
+		command = append(append(command, "--", spec), paths...)
+		if err := r.client.Exec(ctx, instance, command); err != nil {
+			return err
+		}
+	}
+
+	if mode != "" {
+		command := append(append(append([]string{}, sudo...), "chmod"), recursive...)
+		command = append(append(command, "--", mode), paths...)
+		if err := r.client.Exec(ctx, instance, command); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var fileModeRegex = regexp.MustCompile(`^0?[0-7]{3,4}$`)
+
+var (
+	sourceURLRegex = regexp.MustCompile(`^https?://`)
+	sha256Regex    = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+)
+
+// defaultSourceURLTimeout bounds source_url downloads when
+// source_url_timeout is not set.
+const defaultSourceURLTimeout = 5 * time.Minute
+
+// sameFileMode reports whether two octal mode strings describe the same
+// permissions, ignoring leading zeros.
+func sameFileMode(a, b string) bool {
+	av, errA := strconv.ParseUint(a, 8, 32)
+	bv, errB := strconv.ParseUint(b, 8, 32)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return av == bv
+}
+
 func (r *fileUploadResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
 	if r.client == nil {
 		resp.Diagnostics.AddError("Client not configured", "The provider Multipass client was not configured.")
 		return
 	}
 
-	var plan fileUploadResourceModel
+	var plan, state fileUploadResourceModel
 	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
+	ctx, done := startOperation(ctx, "multipass_file_upload", "update", plan.Instance.ValueString())
+	defer done(&resp.Diagnostics)
+
 	updateTimeout, diags := plan.Timeouts.Update(ctx, r.commandTimeout)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
@@ -292,32 +1130,50 @@ func (r *fileUploadResource) Update(ctx context.Context, req resource.UpdateRequ
 	ctx, cancel := context.WithTimeout(ctx, updateTimeout)
 	defer cancel()
 
-	hashValue, diags := r.computeHash(&plan)
-	resp.Diagnostics.Append(diags...)
+	_, waitDiags := waitForTransferInstance(ctx, r.client, plan.Instance.ValueString(), plan.WaitForInstance, plan.StartIfStopped, plan.WaitTimeout)
+	resp.Diagnostics.Append(waitDiags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	srcPath, content, diags := r.prepareLocalSource(&plan)
+	hashValue, diags := r.computeHash(ctx, &plan)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	target := fmt.Sprintf("%s:%s", plan.Instance.ValueString(), plan.Destination.ValueString())
-	transferOpts := multipasscli.TransferOptions{
-		Destination: target,
-		Recursive:   plan.Recursive.ValueBool(),
-		Parents:     plan.CreateParents.ValueBool(),
+	// Without a checksum, ModifyPlan carries the previous digest forward
+	// while source_url is unchanged.
+	if !plan.SourceURL.IsNull() && hashValue == "" && !plan.ContentHash.IsUnknown() && !plan.ContentHash.IsNull() {
+		hashValue = plan.ContentHash.ValueString()
 	}
-	if content != nil {
-		transferOpts.Stdin = content
-	} else {
-		transferOpts.Sources = []string{srcPath}
+
+	// Permission-only changes are applied in place without re-sending the
+	// payload.
+	payloadChanged := state.ContentHash.IsNull() ||
+		state.ContentHash.ValueString() != hashValue ||
+		!state.Recursive.Equal(plan.Recursive) ||
+		!state.CreateParents.Equal(plan.CreateParents)
+
+	if payloadChanged {
+		digest, diags := r.transferPayload(ctx, &plan)
+		resp.Diagnostics.Append(diags...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		if digest != "" {
+			hashValue = digest
+		}
+		plan.ContentHash = types.StringValue(hashValue)
+
+		resp.Diagnostics.Append(r.verifyUpload(ctx, &plan)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
 	}
-	err := r.client.Transfer(ctx, transferOpts)
-	if err != nil {
-		resp.Diagnostics.AddError("Failed to transfer file", err.Error())
+
+	if err := r.applyPermissions(ctx, &plan); err != nil {
+		resp.Diagnostics.AddError("Failed to apply file permissions", err.Error())
 		return
 	}
 
@@ -336,13 +1192,46 @@ func (r *fileUploadResource) Delete(ctx context.Context, req resource.DeleteRequ
 		return
 	}
 
+	ctx, done := startOperation(ctx, "multipass_file_upload", "delete", state.Instance.ValueString())
+	defer done(&resp.Diagnostics)
+
 	instance := state.Instance.ValueString()
-	dest := state.Destination.ValueString()
+	dest := state.remoteDestination()
 	if instance == "" || dest == "" {
 		return
 	}
 
-	if err := r.client.Exec(ctx, instance, []string{"rm", "-rf", "--", dest}); err != nil {
+	if state.KeepOnDestroy.ValueBool() {
+		tflog.Info(ctx, "keep_on_destroy set; leaving remote path in place", map[string]any{
+			"instance":    instance,
+			"destination": dest,
+		})
+		return
+	}
+
+	targets, diags := uploadTargets(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	command := []string{"rm", "-rf", "--"}
+	for _, target := range targets {
+		if unsafeRemovalTarget(target.remote) {
+			resp.Diagnostics.AddWarning(
+				"Refusing to remove remote path",
+				fmt.Sprintf("Not running `rm -rf` on %q inside %q; remove it manually if intended.", target.remote, instance),
+			)
+			return
+		}
+		command = append(command, target.remote)
+	}
+	if state.UseSudo.ValueBool() || hasStringValue(state.Owner) || hasStringValue(state.Group) {
+		// Paths placed with sudo or handed to another owner may not be
+		// removable by the default user.
+		command = append([]string{"sudo"}, command...)
+	}
+	if err := r.client.Exec(ctx, instance, command); err != nil {
 		if cliErr, ok := err.(*multipasscli.CLIError); ok {
 			resp.Diagnostics.AddWarning("Failed to remove remote path", cliErr.Error())
 			return
@@ -351,28 +1240,160 @@ func (r *fileUploadResource) Delete(ctx context.Context, req resource.DeleteRequ
 	}
 }
 
+// unsafeRemovalTarget reports whether removing p recursively would wipe the
+// root filesystem, every home directory, or a directory referenced through a
+// trailing "/." — destinations that are almost certainly misconfigured.
+func unsafeRemovalTarget(p string) bool {
+	trimmed := strings.TrimRight(p, "/")
+	switch {
+	case trimmed == "", trimmed == "/home", trimmed == ".":
+		return true
+	case strings.HasSuffix(trimmed, "/."):
+		return true
+	}
+	return false
+}
+
+func (r *fileUploadResource) UpgradeState(ctx context.Context) map[int64]resource.StateUpgrader {
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+	prior := schemaResp.Schema
+	prior.Version = 0
+
+	return map[int64]resource.StateUpgrader{
+		// Version 0 stored the same attributes with an unescaped
+		// `<instance>:<destination>` id.
+		0: {
+			PriorSchema: &prior,
+			StateUpgrader: func(ctx context.Context, req resource.UpgradeStateRequest, resp *resource.UpgradeStateResponse) {
+				var state fileUploadResourceModel
+				resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+				if resp.Diagnostics.HasError() {
+					return
+				}
+				state.ID = types.StringValue(transferResourceID(state.Instance.ValueString(), state.Destination.ValueString()))
+				resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+			},
+		},
+	}
+}
+
+// parseUploadImportID accepts the escaped id recorded in state as well as a
+// human-written `<instance>:<destination>`.
+func parseUploadImportID(id string) (instance, dest string, err error) {
+	if instance, paths, ok := parseTransferResourceID(id, 1); ok {
+		return instance, paths[0], nil
+	}
+	instance, dest, ok := strings.Cut(id, ":")
+	if !ok || instance == "" || dest == "" {
+		return "", "", fmt.Errorf("expected `<instance>:<destination>`, got %q", id)
+	}
+	return instance, dest, nil
+}
+
 func (r *fileUploadResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
-	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("id"), req.ID)...)
-	if resp.Diagnostics.HasError() {
+	instance, dest, err := parseUploadImportID(req.ID)
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid import ID", err.Error())
 		return
 	}
 
-	parts := strings.SplitN(req.ID, ":", 2)
-	if len(parts) != 2 {
-		resp.Diagnostics.AddError("Invalid import ID", "Expected `<instance>:<destination>`.")
+	resolved, err := resolveRemotePath(dest)
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid import ID", err.Error())
 		return
 	}
 
-	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("instance"), parts[0])...)
-	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("destination"), parts[1])...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("id"), transferResourceID(instance, dest))...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("instance"), instance)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("destination"), dest)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("resolved_destination"), resolved)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("create_parents"), true)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("verify_remote"), false)...)
+	if resp.Diagnostics.HasError() || r.client == nil {
+		return
+	}
+
+	recursive, hashValue, diags := r.inspectImportedPath(ctx, instance, resolved)
+	resp.Diagnostics.Append(diags...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("recursive"), recursive)...)
+	if hashValue != "" {
+		resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("content_hash"), hashValue)...)
+	}
+}
+
+// inspectImportedPath reports whether an imported destination is a
+// directory and, for files, its SHA256 so that a configuration uploading the
+// same bytes plans without a re-transfer. Directory hashes are computed
+// differently on the host and are left for the first apply to record.
+func (r *fileUploadResource) inspectImportedPath(ctx context.Context, instance, dest string) (bool, string, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	inst, err := r.client.GetInstance(ctx, instance)
+	if err != nil {
+		if errors.Is(err, multipasscli.ErrNotFound) {
+			diags.AddError("Instance not found", fmt.Sprintf("Instance %q does not exist.", instance))
+			return false, "", diags
+		}
+		diags.AddError("Failed to verify instance", err.Error())
+		return false, "", diags
+	}
+	if !strings.EqualFold(inst.State, "Running") {
+		diags.AddWarning(
+			"Imported without content hash",
+			fmt.Sprintf("Instance %q is %s, so %s could not be inspected. The next apply will re-upload it.", instance, inst.State, dest),
+		)
+		return false, "", diags
+	}
+
+	out, err := r.client.ExecCapture(ctx, instance, []string{"stat", "-c", "%F", "--", dest})
+	if err != nil {
+		diags.AddError("Failed to inspect remote path", err.Error())
+		return false, "", diags
+	}
+	if strings.TrimSpace(string(out)) == "directory" {
+		return true, "", diags
+	}
+
+	hashValue, exists, err := remoteHash(ctx, r.client, instance, dest, false)
+	if err != nil {
+		diags.AddError("Failed to hash remote path", err.Error())
+		return false, "", diags
+	}
+	if !exists {
+		diags.AddError("Remote path not found", fmt.Sprintf("%s does not exist in instance %q.", dest, instance))
+		return false, "", diags
+	}
+	return false, hashValue, diags
 }
 
-func (r *fileUploadResource) computeHash(model *fileUploadResourceModel) (string, diag.Diagnostics) {
+func (r *fileUploadResource) computeHash(ctx context.Context, model *fileUploadResourceModel) (string, diag.Diagnostics) {
 	var diags diag.Diagnostics
 
 	switch {
+	case !model.SourceURL.IsNull():
+		// The digest of a remote artifact is only known after download.
+		return strings.ToLower(valueOrEmpty(model.SourceURLSHA256)), diags
+	case !model.Sources.IsNull():
+		sources, d := uploadSources(ctx, model)
+		diags.Append(d...)
+		if diags.HasError() {
+			return "", diags
+		}
+		// Entries land in the destination under their base names, so the
+		// combined hash covers each base name and its hash in order.
+		var combined strings.Builder
+		for _, src := range sources {
+			hashValue, err := hashPath(src, model.Recursive.ValueBool(), uploadExcludes(ctx, model))
+			if err != nil {
+				diags.AddError("Failed to hash source", err.Error())
+				return "", diags
+			}
+			fmt.Fprintf(&combined, "%s\x00%s\n", filepath.Base(src), hashValue)
+		}
+		return hashBytes([]byte(combined.String())), diags
 	case !model.Source.IsNull() && model.Source.ValueString() != "":
-		hashValue, err := hashPath(model.Source.ValueString(), model.Recursive.ValueBool())
+		hashValue, err := hashPath(model.Source.ValueString(), model.Recursive.ValueBool(), uploadExcludes(ctx, model))
 		if err != nil {
 			diags.AddError("Failed to hash source", err.Error())
 			return "", diags
@@ -381,41 +1402,204 @@ func (r *fileUploadResource) computeHash(model *fileUploadResourceModel) (string
 	case !model.Content.IsNull():
 		value := model.Content.ValueString()
 		return hashBytes([]byte(value)), diags
+	case !model.ContentBase64.IsNull():
+		data, err := base64.StdEncoding.DecodeString(model.ContentBase64.ValueString())
+		if err != nil {
+			diags.AddAttributeError(path.Root("content_base64"), "Invalid base64 content", err.Error())
+			return "", diags
+		}
+		return hashBytes(data), diags
 	default:
-		diags.AddError("Missing file data", "Either `source` or `content` must be provided.")
+		diags.AddError("Missing file data", "One of `source`, `content`, `content_base64`, `sources` or `source_url` must be provided.")
 		return "", diags
 	}
 }
 
-// prepareLocalSource resolves the model into a form `multipass transfer` can
-// consume. For file-based uploads it returns an absolute host path. For
-// inline content it returns the bytes to pipe via stdin — avoiding a
-// temporary file that snap-confined multipass installs cannot read.
-func (r *fileUploadResource) prepareLocalSource(model *fileUploadResourceModel) (string, []byte, diag.Diagnostics) {
+// uploadSources returns the local paths configured for the upload: the
+// `sources` entries, or `source` alone.
+func uploadSources(ctx context.Context, model *fileUploadResourceModel) ([]string, diag.Diagnostics) {
 	var diags diag.Diagnostics
-
+	if !model.Sources.IsNull() {
+		var sources []string
+		diags.Append(model.Sources.ElementsAs(ctx, &sources, false)...)
+		return sources, diags
+	}
 	if !model.Source.IsNull() && model.Source.ValueString() != "" {
-		abs, err := filepath.Abs(model.Source.ValueString())
+		return []string{model.Source.ValueString()}, diags
+	}
+	return nil, diags
+}
+
+// uploadExcludes returns the configured `excludes` patterns.
+func uploadExcludes(ctx context.Context, model *fileUploadResourceModel) excludePatterns {
+	if model.Excludes.IsNull() || model.Excludes.IsUnknown() {
+		return nil
+	}
+	var patterns []string
+	_ = model.Excludes.ElementsAs(ctx, &patterns, false)
+	return patterns
+}
+
+// stageFilteredSources copies every directory in srcPaths, minus excludes,
+// into a temporary directory created next to it and returns the paths to
+// transfer instead. The copy keeps the directory's base name, and its
+// location keeps it readable by snap-confined multipass installs wherever
+// the original is. cleanup removes the staged copies.
+func stageFilteredSources(srcPaths []string, excludes excludePatterns) ([]string, func(), diag.Diagnostics) {
+	var diags diag.Diagnostics
+	var tempDirs []string
+	cleanup := func() {
+		for _, dir := range tempDirs {
+			_ = os.RemoveAll(dir)
+		}
+	}
+
+	staged := make([]string, 0, len(srcPaths))
+	var skipped []string
+	for _, src := range srcPaths {
+		info, err := os.Stat(src)
+		if err != nil || !info.IsDir() {
+			staged = append(staged, src)
+			continue
+		}
+		tempDir, err := os.MkdirTemp(filepath.Dir(src), ".terraform-multipass-upload-*")
 		if err != nil {
-			diags.AddError("Invalid source path", err.Error())
-			return "", nil, diags
+			diags.AddError("Failed to stage filtered upload", err.Error())
+			return nil, cleanup, diags
 		}
-		info, err := os.Stat(abs)
+		tempDirs = append(tempDirs, tempDir)
+
+		copyDest := filepath.Join(tempDir, filepath.Base(src))
+		s, err := copyDirContents(src, copyDest, excludes)
 		if err != nil {
-			diags.AddError("Invalid source path", err.Error())
-			return "", nil, diags
+			diags.AddError("Failed to stage filtered upload", err.Error())
+			return nil, cleanup, diags
 		}
-		if info.IsDir() && !model.Recursive.ValueBool() {
-			diags.AddError("Directory transfer requires recursion", "Set `recursive = true` when `source` is a directory.")
-			return "", nil, diags
+		skipped = append(skipped, s...)
+		if err := os.Chmod(copyDest, info.Mode().Perm()); err != nil {
+			diags.AddError("Failed to stage filtered upload", err.Error())
+			return nil, cleanup, diags
 		}
-		return abs, nil, diags
+		staged = append(staged, copyDest)
+	}
+	addSkippedEntriesWarning(&diags, skipped)
+	return staged, cleanup, diags
+}
+
+// prepareLocalSource resolves the model into a form `multipass transfer` can
+// consume. For file-based uploads it returns absolute host paths. For
+// inline content it returns the bytes to pipe via stdin — avoiding a
+// temporary file that snap-confined multipass installs cannot read.
+func (r *fileUploadResource) prepareLocalSource(ctx context.Context, model *fileUploadResourceModel) ([]string, []byte, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	sources, d := uploadSources(ctx, model)
+	diags.Append(d...)
+	if diags.HasError() {
+		return nil, nil, diags
+	}
+	if len(sources) > 0 {
+		paths := make([]string, 0, len(sources))
+		for _, src := range sources {
+			abs, err := filepath.Abs(src)
+			if err != nil {
+				diags.AddError("Invalid source path", err.Error())
+				return nil, nil, diags
+			}
+			info, err := os.Stat(abs)
+			if err != nil {
+				diags.AddError("Invalid source path", err.Error())
+				return nil, nil, diags
+			}
+			if info.IsDir() && !model.Recursive.ValueBool() {
+				diags.AddError("Directory transfer requires recursion", fmt.Sprintf("Set `recursive = true` to upload the directory %q.", src))
+				return nil, nil, diags
+			}
+			paths = append(paths, abs)
+		}
+		return paths, nil, diags
 	}
 
 	if !model.Content.IsNull() {
-		return "", []byte(model.Content.ValueString()), diags
+		return nil, []byte(model.Content.ValueString()), diags
+	}
+
+	if !model.ContentBase64.IsNull() {
+		data, err := base64.StdEncoding.DecodeString(model.ContentBase64.ValueString())
+		if err != nil {
+			diags.AddAttributeError(path.Root("content_base64"), "Invalid base64 content", err.Error())
+			return nil, nil, diags
+		}
+		return nil, data, diags
 	}
 
-	diags.AddError("Missing file data", "Either `source` or `content` must be provided.")
-	return "", nil, diags
+	diags.AddError("Missing file data", "One of `source`, `content`, `content_base64`, `sources` or `source_url` must be provided.")
+	return nil, nil, diags
+}
+
+// base64Validator rejects values that are not valid standard base64, so
+// malformed content_base64 fails at plan time rather than during apply.
+type base64Validator struct{}
+
+func (v base64Validator) Description(_ context.Context) string {
+	return "value must be valid base64"
+}
+
+func (v base64Validator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v base64Validator) ValidateString(_ context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+	if _, err := base64.StdEncoding.DecodeString(req.ConfigValue.ValueString()); err != nil {
+		resp.Diagnostics.AddAttributeError(req.Path, "Invalid base64 content", err.Error())
+	}
+}
+
+// excludePatternValidator rejects malformed `excludes` globs at plan time.
+type excludePatternValidator struct{}
+
+func (v excludePatternValidator) Description(_ context.Context) string {
+	return "value must be a valid glob pattern"
+}
+
+func (v excludePatternValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v excludePatternValidator) ValidateString(_ context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+	pattern := strings.Trim(req.ConfigValue.ValueString(), "/")
+	if pattern == "" {
+		resp.Diagnostics.AddAttributeError(req.Path, "Invalid exclude pattern", "Exclude patterns must not be empty.")
+		return
+	}
+	if err := checkExcludePattern(pattern); err != nil {
+		resp.Diagnostics.AddAttributeError(req.Path, "Invalid exclude pattern", fmt.Sprintf("%q: %s", req.ConfigValue.ValueString(), err))
+	}
+}
+
+// remotePathValidator rejects destinations that cannot be resolved to an
+// absolute path inside the instance.
+type remotePathValidator struct{}
+
+func (v remotePathValidator) Description(_ context.Context) string {
+	return "value must be an absolute path or start with ~/, without newlines or NUL bytes"
+}
+
+func (v remotePathValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v remotePathValidator) ValidateString(_ context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+	if _, err := resolveRemotePath(req.ConfigValue.ValueString()); err != nil {
+		resp.Diagnostics.AddAttributeError(req.Path, "Invalid remote path", err.Error())
+	}
 }
@@ -2,6 +2,8 @@ package provider
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
@@ -46,3 +48,84 @@ resource "multipass_file_upload" "test" {
 }
 `, instanceName, content)
 }
+
+func TestAccFileUploadResource_permissions(t *testing.T) {
+	instanceName := randomName()
+	rn := "multipass_file_upload.test"
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             testAccCheckInstanceDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccFileUploadConfig_permissions(instanceName, "0644"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr(rn, "mode", "0644"),
+					resource.TestCheckResourceAttr(rn, "owner", "root"),
+				),
+			},
+			// Changing only the mode is applied in place.
+			{
+				Config: testAccFileUploadConfig_permissions(instanceName, "0755"),
+				Check:  resource.TestCheckResourceAttr(rn, "mode", "0755"),
+			},
+		},
+	})
+}
+
+func testAccFileUploadConfig_permissions(instanceName, mode string) string {
+	return testProviderConfig + fmt.Sprintf(`
+resource "multipass_instance" "test" {
+  name = %q
+}
+
+resource "multipass_file_upload" "test" {
+  instance      = multipass_instance.test.name
+  destination   = "/home/ubuntu/run.sh"
+  content       = "#!/bin/sh\necho hi\n"
+  mode          = %q
+  owner         = "root"
+  group         = "root"
+  verify_remote = true
+}
+`, instanceName, mode)
+}
+
+func TestAccFileUploadResource_sources(t *testing.T) {
+	instanceName := randomName()
+	rn := "multipass_file_upload.test"
+
+	dir := t.TempDir()
+	for _, name := range []string{"a.conf", "b.conf"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             testAccCheckInstanceDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: testProviderConfig + fmt.Sprintf(`
+resource "multipass_instance" "test" {
+  name = %q
+}
+
+resource "multipass_file_upload" "test" {
+  instance      = multipass_instance.test.name
+  destination   = "/home/ubuntu/conf/"
+  sources       = [%q, %q]
+  verify_remote = true
+}
+`, instanceName, filepath.Join(dir, "a.conf"), filepath.Join(dir, "b.conf")),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr(rn, "sources.#", "2"),
+					resource.TestCheckResourceAttrSet(rn, "content_hash"),
+				),
+			},
+		},
+	})
+}
@@ -0,0 +1,682 @@
+package provider
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-framework-timeouts/resource/timeouts"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+func TestUploadTargets(t *testing.T) {
+	ctx := context.Background()
+
+	single := fileUploadResourceModel{
+		Destination: types.StringValue("/etc/app.conf"),
+		Source:      types.StringValue("files/app.conf"),
+		Sources:     types.ListNull(types.StringType),
+	}
+	got, diags := uploadTargets(ctx, &single)
+	if diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if len(got) != 1 || got[0].remote != "/etc/app.conf" {
+		t.Fatalf("unexpected single target: %#v", got)
+	}
+
+	multi := fileUploadResourceModel{
+		Destination: types.StringValue("/etc/app/"),
+		Sources: types.ListValueMust(types.StringType, []attr.Value{
+			types.StringValue("files/a.conf"),
+			types.StringValue("shared/conf.d"),
+		}),
+	}
+	got, diags = uploadTargets(ctx, &multi)
+	if diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if len(got) != 2 || got[0].remote != "/etc/app/a.conf" || got[1].remote != "/etc/app/conf.d" {
+		t.Fatalf("unexpected multi targets: %#v", got)
+	}
+}
+
+func TestTransferPayloadArchive(t *testing.T) {
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "index.js"), []byte("module.exports = {}\n"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	model := fileUploadResourceModel{
+		Instance:      types.StringValue("vm"),
+		Destination:   types.StringValue("/opt/app"),
+		Source:        types.StringValue(dir),
+		Sources:       types.ListNull(types.StringType),
+		Content:       types.StringNull(),
+		Recursive:     types.BoolValue(true),
+		CreateParents: types.BoolValue(true),
+		Archive:       types.StringValue(archiveAlways),
+	}
+
+	t.Run("extracts staged tarball", func(t *testing.T) {
+		client := &fakeClient{}
+		r := &fileUploadResource{client: client}
+		if _, diags := r.transferPayload(ctx, &model); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+
+		calls := client.recorded()
+		if len(calls) != 3 {
+			t.Fatalf("unexpected calls: %v", calls)
+		}
+		if !strings.HasPrefix(calls[1], "transfer - vm:/tmp/terraform-multipass-upload-") {
+			t.Fatalf("expected staged tarball transfer, got %q", calls[1])
+		}
+		if !strings.Contains(calls[2], "tar -xf") || !strings.Contains(calls[2], "/opt/app") {
+			t.Fatalf("expected extraction into destination, got %q", calls[2])
+		}
+	})
+
+	t.Run("falls back without tar", func(t *testing.T) {
+		client := &fakeClient{
+			execCapture: func(string, []string) ([]byte, error) {
+				return nil, errors.New("exit status 1")
+			},
+		}
+		r := &fileUploadResource{client: client}
+		if _, diags := r.transferPayload(ctx, &model); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+
+		calls := client.recorded()
+		if got := calls[len(calls)-1]; got != "transfer "+dir+" vm:/opt/app" {
+			t.Fatalf("expected direct transfer, got %q", got)
+		}
+	})
+
+	t.Run("unset archive follows transfer_strategy", func(t *testing.T) {
+		unset := model
+		unset.Archive = types.StringNull()
+		for strategy, wantArchive := range map[string]bool{
+			transferStrategyTar:    true,
+			transferStrategyDirect: false,
+		} {
+			client := &fakeClient{}
+			r := &fileUploadResource{client: client, transferStrategy: strategy}
+			if _, diags := r.transferPayload(ctx, &unset); diags.HasError() {
+				t.Fatalf("%s: unexpected diagnostics: %v", strategy, diags)
+			}
+			calls := client.recorded()
+			archived := strings.Contains(calls[len(calls)-1], "tar -xf")
+			if archived != wantArchive {
+				t.Fatalf("%s: archived = %t, want %t; calls: %v", strategy, archived, wantArchive, calls)
+			}
+		}
+	})
+}
+
+func TestContentBase64(t *testing.T) {
+	ctx := context.Background()
+	payload := []byte{0x30, 0x82, 0x01, 0xff, 0x00, 0xc3}
+
+	model := fileUploadResourceModel{
+		ContentBase64: types.StringValue(base64.StdEncoding.EncodeToString(payload)),
+		Sources:       types.ListNull(types.StringType),
+	}
+	r := &fileUploadResource{}
+	got, diags := r.computeHash(ctx, &model)
+	if diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if want := hashBytes(payload); got != want {
+		t.Fatalf("hash mismatch: got %s want %s", got, want)
+	}
+
+	var resp validator.StringResponse
+	base64Validator{}.ValidateString(ctx, validator.StringRequest{ConfigValue: types.StringValue("not base64!")}, &resp)
+	if !resp.Diagnostics.HasError() {
+		t.Fatalf("expected invalid base64 to be rejected")
+	}
+}
+
+// deleteUpload runs Delete against state built from model and returns the
+// recorded client calls.
+func deleteUpload(t *testing.T, model fileUploadResourceModel) ([]string, diag.Diagnostics) {
+	t.Helper()
+	ctx := context.Background()
+
+	r := &fileUploadResource{}
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	model.Timeouts = timeouts.Value{Object: types.ObjectNull(map[string]attr.Type{
+		"create": types.StringType,
+		"update": types.StringType,
+	})}
+	if model.Sources.IsNull() {
+		model.Sources = types.ListNull(types.StringType)
+	}
+	if model.Excludes.IsNull() {
+		model.Excludes = types.ListNull(types.StringType)
+	}
+	state := tfsdk.State{Schema: schemaResp.Schema}
+	if diags := state.Set(ctx, &model); diags.HasError() {
+		t.Fatalf("set state: %v", diags)
+	}
+
+	client := &fakeClient{}
+	r.client = client
+	resp := resource.DeleteResponse{State: state}
+	r.Delete(ctx, resource.DeleteRequest{State: state}, &resp)
+	return client.recorded(), resp.Diagnostics
+}
+
+func TestDeleteUpload(t *testing.T) {
+	base := func(dest string) fileUploadResourceModel {
+		return fileUploadResourceModel{
+			Instance:    types.StringValue("vm"),
+			Destination: types.StringValue(dest),
+			Content:     types.StringValue("hi"),
+		}
+	}
+
+	t.Run("removes destination", func(t *testing.T) {
+		calls, diags := deleteUpload(t, base("/home/ubuntu/app.conf"))
+		if diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		if diff := cmp.Diff([]string{"exec vm rm -rf -- /home/ubuntu/app.conf"}, calls); diff != "" {
+			t.Fatalf("unexpected calls: %s", diff)
+		}
+	})
+
+	t.Run("keep_on_destroy skips removal", func(t *testing.T) {
+		model := base("/home/ubuntu/app.conf")
+		model.KeepOnDestroy = types.BoolValue(true)
+		calls, _ := deleteUpload(t, model)
+		if len(calls) != 0 {
+			t.Fatalf("expected no calls, got %v", calls)
+		}
+	})
+
+	for _, dest := range []string{"/", "/home", "/home/", "/srv/app/.", "/srv/app/./"} {
+		t.Run("guards "+dest, func(t *testing.T) {
+			calls, diags := deleteUpload(t, base(dest))
+			if len(calls) != 0 {
+				t.Fatalf("expected no calls for %q, got %v", dest, calls)
+			}
+			if diags.WarningsCount() != 1 {
+				t.Fatalf("expected a warning for %q, got %v", dest, diags)
+			}
+		})
+	}
+}
+
+func TestInspectImportedPath(t *testing.T) {
+	ctx := context.Background()
+	digest := hashBytes([]byte("hello"))
+
+	statOutput := func(kind string) func(string, []string) ([]byte, error) {
+		return func(_ string, command []string) ([]byte, error) {
+			if command[0] == "stat" {
+				return []byte(kind + "\n"), nil
+			}
+			return []byte(digest + "  -\n"), nil
+		}
+	}
+
+	t.Run("file", func(t *testing.T) {
+		r := &fileUploadResource{client: &fakeClient{execCapture: statOutput("regular file")}}
+		recursive, hashValue, diags := r.inspectImportedPath(ctx, "vm", "/etc/app.conf")
+		if diags.HasError() || recursive || hashValue != digest {
+			t.Fatalf("unexpected result recursive=%v hash=%s diags=%v", recursive, hashValue, diags)
+		}
+	})
+
+	t.Run("directory", func(t *testing.T) {
+		r := &fileUploadResource{client: &fakeClient{execCapture: statOutput("directory")}}
+		recursive, hashValue, diags := r.inspectImportedPath(ctx, "vm", "/opt/app")
+		if diags.HasError() || !recursive || hashValue != "" {
+			t.Fatalf("unexpected result recursive=%v hash=%s diags=%v", recursive, hashValue, diags)
+		}
+	})
+
+	t.Run("stopped instance", func(t *testing.T) {
+		client := &fakeClient{
+			getInstance: func(name string) (*models.Instance, error) {
+				return &models.Instance{Name: name, State: "Stopped"}, nil
+			},
+		}
+		r := &fileUploadResource{client: client}
+		_, hashValue, diags := r.inspectImportedPath(ctx, "vm", "/etc/app.conf")
+		if diags.HasError() || diags.WarningsCount() != 1 || hashValue != "" {
+			t.Fatalf("expected warning and no hash, got hash=%s diags=%v", hashValue, diags)
+		}
+		if len(client.recorded()) != 1 {
+			t.Fatalf("expected no exec calls, got %v", client.recorded())
+		}
+	})
+}
+
+func TestTransferWithSudo(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	model := fileUploadResourceModel{
+		Instance:      types.StringValue("vm"),
+		Destination:   types.StringValue("/etc/myapp/app.conf"),
+		Content:       types.StringValue("key=value\n"),
+		Sources:       types.ListNull(types.StringType),
+		CreateParents: types.BoolValue(true),
+		UseSudo:       types.BoolValue(true),
+	}
+
+	t.Run("stages and promotes", func(t *testing.T) {
+		client := &fakeClient{}
+		r := &fileUploadResource{client: client}
+		if _, diags := r.transferPayload(ctx, &model); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+
+		calls := client.recorded()
+		if len(calls) != 3 {
+			t.Fatalf("unexpected calls: %v", calls)
+		}
+		if calls[0] != "exec-capture vm sudo -n true" {
+			t.Fatalf("expected sudo probe, got %q", calls[0])
+		}
+		if !regexp.MustCompile(`^transfer - vm:/tmp/terraform-multipass-stage-[0-9a-f]{16}/etc/myapp/app.conf$`).MatchString(calls[1]) {
+			t.Fatalf("expected staged transfer, got %q", calls[1])
+		}
+		if !strings.HasPrefix(calls[2], "exec vm sudo -n sh -c") || !strings.HasSuffix(calls[2], " /etc/myapp/app.conf 1") {
+			t.Fatalf("expected sudo promotion, got %q", calls[2])
+		}
+	})
+
+	t.Run("requires passwordless sudo", func(t *testing.T) {
+		client := &fakeClient{
+			execCapture: func(string, []string) ([]byte, error) {
+				return nil, errors.New("sudo: a password is required")
+			},
+		}
+		r := &fileUploadResource{client: client}
+		_, diags := r.transferPayload(ctx, &model)
+		if !diags.HasError() || !strings.Contains(diags[0].Summary(), "Passwordless sudo") {
+			t.Fatalf("expected sudo diagnostic, got %v", diags)
+		}
+		if calls := client.recorded(); len(calls) != 1 {
+			t.Fatalf("expected no transfer, got %v", calls)
+		}
+	})
+
+	t.Run("cleans up staging on failure", func(t *testing.T) {
+		client := &fakeClient{
+			transfer: func(multipasscli.TransferOptions) error {
+				return errors.New("no space left on device")
+			},
+		}
+		r := &fileUploadResource{client: client}
+		if _, diags := r.transferPayload(ctx, &model); !diags.HasError() {
+			t.Fatal("expected transfer error")
+		}
+
+		calls := client.recorded()
+		if got := calls[len(calls)-1]; !strings.HasPrefix(got, "exec vm rm -rf -- /tmp/terraform-multipass-stage-") {
+			t.Fatalf("expected staging cleanup, got %q", got)
+		}
+	})
+}
+
+func TestTransferCreatesParents(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	denied := &multipasscli.CLIError{Command: "transfer", Stderr: "[sftp] cannot open remote file /opt/app/app.conf: Permission denied", Err: errors.New("exit status 1")}
+	for _, tc := range []struct {
+		name          string
+		createParents bool
+		transferErrs  []error
+		mkdirErr      error
+		wantCalls     []string
+		wantError     []string
+	}{
+		{
+			name:          "retries after mkdir",
+			createParents: true,
+			transferErrs:  []error{denied},
+			wantCalls:     []string{"transfer - vm:/opt/app/app.conf", "exec vm mkdir -p -- /opt/app/", "transfer - vm:/opt/app/app.conf"},
+		},
+		{
+			name:          "keeps the first error when mkdir fails",
+			createParents: true,
+			transferErrs:  []error{denied},
+			mkdirErr:      errors.New("mkdir: cannot create directory '/opt/app': Permission denied"),
+			wantCalls:     []string{"transfer - vm:/opt/app/app.conf", "exec vm mkdir -p -- /opt/app/"},
+			wantError:     []string{"cannot open remote file /opt/app/app.conf: Permission denied)", "creating /opt/app/ with mkdir -p also failed", "use_sudo"},
+		},
+		{
+			name:          "keeps the first error when the retry fails",
+			createParents: true,
+			transferErrs:  []error{denied, errors.New("no space left on device")},
+			wantCalls:     []string{"transfer - vm:/opt/app/app.conf", "exec vm mkdir -p -- /opt/app/", "transfer - vm:/opt/app/app.conf"},
+			wantError:     []string{"cannot open remote file /opt/app/app.conf: Permission denied)", "retrying after creating /opt/app/ also failed: no space left on device"},
+		},
+		{
+			name:         "no fallback without create_parents",
+			transferErrs: []error{denied},
+			wantCalls:    []string{"transfer - vm:/opt/app/app.conf"},
+			wantError:    []string{"Permission denied"},
+		},
+		{
+			name:          "no fallback for other errors",
+			createParents: true,
+			transferErrs:  []error{errors.New("no space left on device")},
+			wantCalls:     []string{"transfer - vm:/opt/app/app.conf"},
+			wantError:     []string{"no space left on device"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			attempt := 0
+			client := &fakeClient{
+				transfer: func(multipasscli.TransferOptions) error {
+					attempt++
+					if attempt <= len(tc.transferErrs) {
+						return tc.transferErrs[attempt-1]
+					}
+					return nil
+				},
+				exec: func(string, []string) error { return tc.mkdirErr },
+			}
+			r := &fileUploadResource{client: client}
+			model := fileUploadResourceModel{
+				Instance:      types.StringValue("vm"),
+				Destination:   types.StringValue("/opt/app/app.conf"),
+				Content:       types.StringValue("key=value\n"),
+				Sources:       types.ListNull(types.StringType),
+				CreateParents: types.BoolValue(tc.createParents),
+			}
+			_, diags := r.transferPayload(ctx, &model)
+
+			if diff := cmp.Diff(tc.wantCalls, client.recorded()); diff != "" {
+				t.Fatalf("unexpected calls (-want +got): %s", diff)
+			}
+			if len(tc.wantError) == 0 {
+				if diags.HasError() {
+					t.Fatalf("unexpected diagnostics: %v", diags)
+				}
+				return
+			}
+			if !diags.HasError() {
+				t.Fatal("expected a transfer error")
+			}
+			for _, want := range tc.wantError {
+				if !strings.Contains(diags[0].Detail(), want) {
+					t.Fatalf("expected %q in %q", want, diags[0].Detail())
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyUpload(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	content := "key=value\n"
+	file := fileUploadResourceModel{
+		Instance:    types.StringValue("vm"),
+		Destination: types.StringValue("/etc/app.conf"),
+		Content:     types.StringValue(content),
+		Sources:     types.ListNull(types.StringType),
+		ContentHash: types.StringValue(hashBytes([]byte(content))),
+	}
+
+	t.Run("file matches", func(t *testing.T) {
+		client := &fakeClient{
+			execCapture: func(string, []string) ([]byte, error) {
+				return []byte(hashBytes([]byte(content)) + "  -\n"), nil
+			},
+		}
+		r := &fileUploadResource{client: client}
+		if diags := r.verifyUpload(ctx, &file); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+	})
+
+	t.Run("truncated file", func(t *testing.T) {
+		client := &fakeClient{
+			execCapture: func(string, []string) ([]byte, error) {
+				return []byte(hashBytes([]byte("key=")) + "  -\n"), nil
+			},
+		}
+		r := &fileUploadResource{client: client}
+		diags := r.verifyUpload(ctx, &file)
+		if !diags.HasError() || !strings.Contains(diags[0].Detail(), "truncated") {
+			t.Fatalf("expected mismatch diagnostic, got %v", diags)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		client := &fakeClient{}
+		r := &fileUploadResource{client: client}
+		disabled := file
+		disabled.VerifyAfterUpload = types.BoolValue(false)
+		if diags := r.verifyUpload(ctx, &disabled); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		if calls := client.recorded(); len(calls) != 0 {
+			t.Fatalf("expected no remote calls, got %v", calls)
+		}
+	})
+
+	dir := t.TempDir()
+	for name, body := range map[string]string{"a.txt": "aaaa", "b.txt": "bb"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+	directory := fileUploadResourceModel{
+		Instance:    types.StringValue("vm"),
+		Destination: types.StringValue("/opt/app"),
+		Source:      types.StringValue(dir),
+		Sources:     types.ListNull(types.StringType),
+		Recursive:   types.BoolValue(true),
+	}
+
+	t.Run("directories skipped by default", func(t *testing.T) {
+		client := &fakeClient{}
+		r := &fileUploadResource{client: client}
+		if diags := r.verifyUpload(ctx, &directory); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		if calls := client.recorded(); len(calls) != 0 {
+			t.Fatalf("expected no remote calls, got %v", calls)
+		}
+	})
+
+	t.Run("directory counts", func(t *testing.T) {
+		enabled := directory
+		enabled.VerifyAfterUpload = types.BoolValue(true)
+		for out, wantErr := range map[string]bool{"2 6\n": false, "3 10\n": false, "1 4\n": true, "2 5\n": true} {
+			client := &fakeClient{
+				execCapture: func(string, []string) ([]byte, error) {
+					return []byte(out), nil
+				},
+			}
+			r := &fileUploadResource{client: client}
+			if diags := r.verifyUpload(ctx, &enabled); diags.HasError() != wantErr {
+				t.Fatalf("remote stats %q: unexpected diagnostics: %v", out, diags)
+			}
+		}
+	})
+}
+
+func TestUpgradeUploadStateEscapesID(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	r := &fileUploadResource{}
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	upgrader := r.UpgradeState(ctx)[0]
+	prior := fileUploadResourceModel{
+		ID:          types.StringValue("vm:/data/a:b"),
+		Instance:    types.StringValue("vm"),
+		Destination: types.StringValue("/data/a:b"),
+		Sources:     types.ListNull(types.StringType),
+		Excludes:    types.ListNull(types.StringType),
+		Timeouts: timeouts.Value{Object: types.ObjectNull(map[string]attr.Type{
+			"create": types.StringType,
+			"update": types.StringType,
+		})},
+	}
+	state := tfsdk.State{Schema: *upgrader.PriorSchema}
+	if diags := state.Set(ctx, &prior); diags.HasError() {
+		t.Fatalf("set state: %v", diags)
+	}
+
+	resp := resource.UpgradeStateResponse{State: tfsdk.State{Schema: schemaResp.Schema}}
+	upgrader.StateUpgrader(ctx, resource.UpgradeStateRequest{State: &state}, &resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+	}
+	var upgraded fileUploadResourceModel
+	resp.State.Get(ctx, &upgraded)
+	if got, want := upgraded.ID.ValueString(), "vm:%2Fdata%2Fa%3Ab"; got != want {
+		t.Fatalf("id = %q, want %q", got, want)
+	}
+
+	instance, dest, err := parseUploadImportID(upgraded.ID.ValueString())
+	if err != nil || instance != "vm" || dest != "/data/a:b" {
+		t.Fatalf("parseUploadImportID round trip = %q, %q, %v", instance, dest, err)
+	}
+}
+
+func TestUploadExcludes(t *testing.T) {
+	ctx := context.Background()
+
+	dir := filepath.Join(t.TempDir(), "project")
+	for name, body := range map[string]string{
+		"main.tf":                  "terraform {}\n",
+		".terraform/providers/bin": "binary",
+		"logs/debug.log":           "noise",
+	} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	model := fileUploadResourceModel{
+		Instance:      types.StringValue("vm"),
+		Destination:   types.StringValue("/opt/project"),
+		Source:        types.StringValue(dir),
+		Sources:       types.ListNull(types.StringType),
+		Content:       types.StringNull(),
+		Recursive:     types.BoolValue(true),
+		CreateParents: types.BoolValue(true),
+		Excludes:      types.ListValueMust(types.StringType, []attr.Value{types.StringValue(".terraform"), types.StringValue("*.log")}),
+	}
+	want := []string{"logs/", "main.tf"}
+
+	t.Run("archive filters entries", func(t *testing.T) {
+		var names []string
+		client := &fakeClient{
+			transfer: func(opts multipasscli.TransferOptions) error {
+				tr := tar.NewReader(bytes.NewReader(opts.Stdin))
+				for {
+					hdr, err := tr.Next()
+					if err == io.EOF {
+						return nil
+					}
+					if err != nil {
+						return err
+					}
+					names = append(names, hdr.Name)
+				}
+			},
+		}
+		r := &fileUploadResource{client: client}
+		if _, diags := r.transferPayload(ctx, &model); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		sort.Strings(names)
+		if diff := cmp.Diff(want, names); diff != "" {
+			t.Fatalf("unexpected archive entries: %s", diff)
+		}
+	})
+
+	t.Run("direct transfer stages a filtered copy", func(t *testing.T) {
+		direct := model
+		direct.Archive = types.StringValue(archiveNever)
+		var staged, names []string
+		client := &fakeClient{
+			transfer: func(opts multipasscli.TransferOptions) error {
+				staged = opts.Sources
+				return filepath.WalkDir(opts.Sources[0], func(p string, d fs.DirEntry, err error) error {
+					if err != nil || p == opts.Sources[0] {
+						return err
+					}
+					rel, _ := filepath.Rel(opts.Sources[0], p)
+					if d.IsDir() {
+						rel += "/"
+					}
+					names = append(names, filepath.ToSlash(rel))
+					return nil
+				})
+			},
+		}
+		r := &fileUploadResource{client: client}
+		if _, diags := r.transferPayload(ctx, &direct); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		if len(staged) != 1 || filepath.Base(staged[0]) != "project" || staged[0] == dir {
+			t.Fatalf("expected a staged copy named project, got %v", staged)
+		}
+		if diff := cmp.Diff(want, names); diff != "" {
+			t.Fatalf("unexpected staged entries: %s", diff)
+		}
+		if _, err := os.Stat(staged[0]); !os.IsNotExist(err) {
+			t.Fatalf("expected staged copy to be removed, got %v", err)
+		}
+	})
+
+	t.Run("changing excludes changes the hash", func(t *testing.T) {
+		r := &fileUploadResource{}
+		filtered, diags := r.computeHash(ctx, &model)
+		if diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		other := model
+		other.Excludes = types.ListValueMust(types.StringType, []attr.Value{types.StringValue(".terraform")})
+		changed, _ := r.computeHash(ctx, &other)
+		if filtered == changed {
+			t.Fatalf("expected a different content_hash")
+		}
+	})
+}
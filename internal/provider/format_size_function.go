@@ -0,0 +1,49 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-framework/function"
+)
+
+var _ function.Function = (*formatSizeFunction)(nil)
+
+// NewFormatSizeFunction returns the format_size provider function.
+func NewFormatSizeFunction() function.Function {
+	return &formatSizeFunction{}
+}
+
+type formatSizeFunction struct{}
+
+func (f *formatSizeFunction) Metadata(_ context.Context, _ function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "format_size"
+}
+
+func (f *formatSizeFunction) Definition(_ context.Context, _ function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:             "Format a number of bytes as a Multipass size string.",
+		MarkdownDescription: "Formats a number of bytes in Multipass notation, using the largest of `K`, `M`, `G` and `T` that represents it exactly, e.g. `1610612736` becomes `1536M`. The value is first rounded down to whole kibibytes, so the result is always accepted by `multipass_instance.memory` and `disk`.",
+		Parameters: []function.Parameter{
+			function.Int64Parameter{
+				Name:                "bytes",
+				MarkdownDescription: "Non-negative number of bytes.",
+			},
+		},
+		Return: function.StringReturn{},
+	}
+}
+
+func (f *formatSizeFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var bytes int64
+	resp.Error = function.ConcatFuncErrors(resp.Error, req.Arguments.Get(ctx, &bytes))
+	if resp.Error != nil {
+		return
+	}
+
+	size, err := formatSize(bytes)
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(0, err.Error())
+		return
+	}
+	resp.Error = function.ConcatFuncErrors(resp.Error, resp.Result.Set(ctx, size))
+}
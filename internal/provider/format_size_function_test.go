@@ -0,0 +1,56 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestFormatSizeFunction(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		arg     int64
+		want    string
+		wantErr bool
+	}{
+		{arg: 1610612736, want: "1536M"},
+		{arg: 4 << 30, want: "4G"},
+		{arg: 1 << 40, want: "1T"},
+		{arg: 1500, want: "1K"},
+		{arg: -1024, wantErr: true},
+	}
+	for _, tc := range cases {
+		resp := runFunction(t, &formatSizeFunction{}, types.Int64Value(tc.arg), types.StringUnknown())
+		if tc.wantErr {
+			if resp.Error == nil {
+				t.Errorf("format_size(%d): expected an error", tc.arg)
+			} else if resp.Error.FunctionArgument == nil || *resp.Error.FunctionArgument != 0 {
+				t.Errorf("format_size(%d): error should point at the bytes argument: %v", tc.arg, resp.Error)
+			}
+			continue
+		}
+		if resp.Error != nil {
+			t.Errorf("format_size(%d): %v", tc.arg, resp.Error)
+			continue
+		}
+		if got := resp.Result.Value(); !got.Equal(types.StringValue(tc.want)) {
+			t.Errorf("format_size(%d) = %s, want %q", tc.arg, got, tc.want)
+		}
+	}
+}
+
+func TestFormatSizeFunction_definition(t *testing.T) {
+	t.Parallel()
+
+	var resp function.DefinitionResponse
+	(&formatSizeFunction{}).Definition(context.Background(), function.DefinitionRequest{}, &resp)
+	var validateResp function.DefinitionValidateResponse
+	resp.Definition.ValidateImplementation(context.Background(), function.DefinitionValidateRequest{FuncName: "format_size"}, &validateResp)
+	if validateResp.Diagnostics.HasError() {
+		t.Fatalf("invalid definition: %v", validateResp.Diagnostics)
+	}
+
+}
@@ -0,0 +1,172 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+var _ datasource.DataSource = (*imageDataSource)(nil)
+var _ datasource.DataSourceWithConfigure = (*imageDataSource)(nil)
+
+// NewImageDataSource creates the singular image data source.
+func NewImageDataSource() datasource.DataSource {
+	return &imageDataSource{}
+}
+
+type imageDataSource struct {
+	client multipasscli.Client
+}
+
+type imageDataSourceModel struct {
+	Name       types.String `tfsdk:"name"`
+	Alias      types.String `tfsdk:"alias"`
+	Remote     types.String `tfsdk:"remote"`
+	MostRecent types.Bool   `tfsdk:"most_recent"`
+	Aliases    types.List   `tfsdk:"aliases"`
+	Release    types.String `tfsdk:"release"`
+	Version    types.String `tfsdk:"version"`
+	Kind       types.String `tfsdk:"kind"`
+}
+
+func (d *imageDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_image"
+}
+
+func (d *imageDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Selects a single Multipass image or blueprint from `multipass find`. Fails when nothing matches, or when several match and `most_recent` is not set.",
+		Attributes: map[string]schema.Attribute{
+			"name": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Description: "Exact image name filter. Set to the selected image's name.",
+			},
+			"alias": schema.StringAttribute{
+				Optional:    true,
+				Description: "Alias filter (matches any alias).",
+			},
+			"remote": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Description: "Exact remote filter (e.g. `release`, `daily`, `appliance`). Set to the selected image's remote.",
+			},
+			"most_recent": schema.BoolAttribute{
+				Optional:    true,
+				Description: "When several images match, pick the one with the highest version instead of failing (default: false).",
+			},
+			"aliases": schema.ListAttribute{
+				ElementType: types.StringType,
+				Computed:    true,
+			},
+			"release": schema.StringAttribute{
+				Computed: true,
+			},
+			"version": schema.StringAttribute{
+				Computed: true,
+			},
+			"kind": schema.StringAttribute{
+				Computed: true,
+			},
+		},
+	}
+}
+
+func (d *imageDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, _ *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	data := req.ProviderData.(providerData)
+	d.client = data.client
+}
+
+func (d *imageDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	if d.client == nil {
+		resp.Diagnostics.AddError("Client not configured", "Multipass client is nil.")
+		return
+	}
+
+	var config imageDataSourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	filter := imagesDataSourceModel{
+		Name:   config.Name,
+		Alias:  config.Alias,
+		Remote: config.Remote,
+	}
+	images, err := d.client.ListImages(ctx, imageListOptions(filter))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to list images", err.Error())
+		return
+	}
+
+	img, err := selectImage(filterImages(images, filter), config.MostRecent.ValueBool())
+	if err != nil {
+		resp.Diagnostics.AddError("No single matching image", err.Error())
+		return
+	}
+
+	aliases, diag := types.ListValueFrom(ctx, types.StringType, img.Aliases)
+	resp.Diagnostics.Append(diag...)
+	config.Name = types.StringValue(img.Name)
+	config.Remote = types.StringValue(img.Remote)
+	config.Aliases = aliases
+	config.Release = types.StringValue(img.Release)
+	config.Version = types.StringValue(img.Version)
+	config.Kind = types.StringValue(string(img.Kind))
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &config)...)
+}
+
+// selectImage returns the only image in matches or, with mostRecent, the
+// one with the highest version.
+func selectImage(matches []models.Image, mostRecent bool) (models.Image, error) {
+	switch {
+	case len(matches) == 0:
+		return models.Image{}, fmt.Errorf("no image matches the given filters; run `multipass find` to see the available images")
+	case len(matches) == 1:
+		return matches[0], nil
+	case !mostRecent:
+		names := make([]string, 0, len(matches))
+		for _, img := range matches {
+			names = append(names, imageDisplayName(img))
+		}
+		return models.Image{}, fmt.Errorf("%d images match the given filters (%s); narrow the filters or set most_recent = true", len(matches), strings.Join(names, ", "))
+	}
+
+	sorted := append([]models.Image{}, matches...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return imageVersionLess(sorted[j].Version, sorted[i].Version)
+	})
+	return sorted[0], nil
+}
+
+// imageVersionLess compares image versions, which are usually build dates
+// such as 20240423, numerically where possible and as strings otherwise.
+func imageVersionLess(a, b string) bool {
+	va, errA := version.NewVersion(a)
+	vb, errB := version.NewVersion(b)
+	if errA == nil && errB == nil {
+		return va.LessThan(vb)
+	}
+	return a < b
+}
+
+func imageDisplayName(img models.Image) string {
+	if img.Remote != "" {
+		return img.Remote + ":" + img.Name
+	}
+	return img.Name
+}
@@ -0,0 +1,47 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
+)
+
+func TestSelectImage(t *testing.T) {
+	images := []models.Image{
+		{Name: "22.04", Version: "20240417", Kind: models.ImageKindImage},
+		{Name: "24.04", Version: "20240423", Kind: models.ImageKindImage},
+		{Name: "24.10", Remote: "daily", Version: "20240410", Kind: models.ImageKindImage},
+	}
+
+	if _, err := selectImage(nil, true); err == nil || !strings.Contains(err.Error(), "no image matches") {
+		t.Fatalf("expected a no-match error, got %v", err)
+	}
+
+	got, err := selectImage(images[:1], false)
+	if err != nil || got.Name != "22.04" {
+		t.Fatalf("expected the single match, got %#v, %v", got, err)
+	}
+
+	_, err = selectImage(images, false)
+	if err == nil || !strings.Contains(err.Error(), "daily:24.10") || !strings.Contains(err.Error(), "most_recent") {
+		t.Fatalf("expected an ambiguity error listing the matches, got %v", err)
+	}
+
+	got, err = selectImage(images, true)
+	if err != nil || got.Name != "24.04" {
+		t.Fatalf("expected the most recent image, got %#v, %v", got, err)
+	}
+}
+
+func TestImageVersionLess(t *testing.T) {
+	if !imageVersionLess("0.9", "0.10") {
+		t.Fatalf("expected numeric comparison")
+	}
+	if !imageVersionLess("20240417", "20240423") || imageVersionLess("20240423", "20240417") {
+		t.Fatalf("expected date versions to compare numerically")
+	}
+	if !imageVersionLess("abc", "abd") {
+		t.Fatalf("expected string fallback")
+	}
+}
@@ -2,12 +2,18 @@ package provider
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"strings"
 
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 
 	"github.com/todoroff/terraform-provider-multipass/internal/models"
 	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
@@ -21,27 +27,37 @@ func NewImagesDataSource() datasource.DataSource {
 	return &imagesDataSource{}
 }
 
+// imageArchitectureHost is the architecture filter value that stands for
+// the architecture of the machine running multipassd. `multipass find` only
+// lists images for that machine, so it keeps every image.
+const imageArchitectureHost = "host"
+
 type imagesDataSource struct {
 	client multipasscli.Client
 }
 
 type imagesDataSourceModel struct {
-	Name   types.String `tfsdk:"name"`
-	Alias  types.String `tfsdk:"alias"`
-	Kind   types.String `tfsdk:"kind"`
-	Query  types.String `tfsdk:"query"`
-	Images []imageModel `tfsdk:"images"`
+	Name              types.String `tfsdk:"name"`
+	Alias             types.String `tfsdk:"alias"`
+	Kind              types.String `tfsdk:"kind"`
+	Query             types.String `tfsdk:"query"`
+	Remote            types.String `tfsdk:"remote"`
+	IncludeBlueprints types.Bool   `tfsdk:"include_blueprints"`
+	ForceRefresh      types.Bool   `tfsdk:"force_refresh"`
+	Architecture      types.String `tfsdk:"architecture"`
+	Images            []imageModel `tfsdk:"images"`
 }
 
 type imageModel struct {
-	Name        types.String `tfsdk:"name"`
-	Aliases     types.List   `tfsdk:"aliases"`
-	OS          types.String `tfsdk:"os"`
-	Release     types.String `tfsdk:"release"`
-	Remote      types.String `tfsdk:"remote"`
-	Version     types.String `tfsdk:"version"`
-	Description types.String `tfsdk:"description"`
-	Kind        types.String `tfsdk:"kind"`
+	Name         types.String `tfsdk:"name"`
+	Aliases      types.List   `tfsdk:"aliases"`
+	OS           types.String `tfsdk:"os"`
+	Release      types.String `tfsdk:"release"`
+	Remote       types.String `tfsdk:"remote"`
+	Version      types.String `tfsdk:"version"`
+	Description  types.String `tfsdk:"description"`
+	Kind         types.String `tfsdk:"kind"`
+	Architecture types.String `tfsdk:"architecture"`
 }
 
 func (d *imagesDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
@@ -68,6 +84,25 @@ func (d *imagesDataSource) Schema(_ context.Context, _ datasource.SchemaRequest,
 				Optional:    true,
 				Description: "Case-insensitive substring filter applied to names and descriptions.",
 			},
+			"remote": schema.StringAttribute{
+				Optional:    true,
+				Description: "Exact remote filter (e.g. `release`, `daily`, `appliance`).",
+			},
+			"include_blueprints": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Whether blueprints are included in the results. Defaults to `true`; `false` maps to `multipass find --only-images`.",
+			},
+			"force_refresh": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Make the daemon refetch the image manifests instead of serving its cached copy (`multipass find --force-update`). Defaults to `false`.",
+			},
+			"architecture": schema.StringAttribute{
+				Optional:    true,
+				Description: "Only return images for this CPU architecture: `amd64`, `arm64`, or `host` for the architecture of the machine running multipassd. `multipass find` only lists images for that machine, so `amd64` and `arm64` return every image when they match its architecture and none otherwise. The architecture is read from `uname -m` in a running instance; without one it is unknown, every image is returned and a warning says so.",
+				Validators: []validator.String{
+					stringvalidator.OneOf("amd64", "arm64", imageArchitectureHost),
+				},
+			},
 			"images": schema.ListNestedAttribute{
 				Computed: true,
 				NestedObject: schema.NestedAttributeObject{
@@ -97,6 +132,10 @@ func (d *imagesDataSource) Schema(_ context.Context, _ datasource.SchemaRequest,
 						"kind": schema.StringAttribute{
 							Computed: true,
 						},
+						"architecture": schema.StringAttribute{
+							Computed:    true,
+							Description: "CPU architecture of the image, e.g. `amd64`. Only set when `architecture` is `amd64` or `arm64` and the daemon's architecture could be determined; null otherwise.",
+						},
 					},
 				},
 			},
@@ -124,35 +163,137 @@ func (d *imagesDataSource) Read(ctx context.Context, req datasource.ReadRequest,
 		return
 	}
 
-	images, err := d.client.ListImages(ctx, false)
+	images, err := d.client.ListImages(ctx, imageListOptions(config))
 	if err != nil {
 		resp.Diagnostics.AddError("Failed to list images", err.Error())
 		return
 	}
 
+	resp.Diagnostics.Append(d.annotateArchitecture(ctx, images, config)...)
 	filtered := filterImages(images, config)
 	model := imagesDataSourceModel{
-		Name:   config.Name,
-		Alias:  config.Alias,
-		Kind:   config.Kind,
-		Query:  config.Query,
-		Images: flattenImages(ctx, filtered, &resp.Diagnostics),
+		Name:              config.Name,
+		Alias:             config.Alias,
+		Kind:              config.Kind,
+		Query:             config.Query,
+		Remote:            config.Remote,
+		IncludeBlueprints: config.IncludeBlueprints,
+		ForceRefresh:      config.ForceRefresh,
+		Architecture:      config.Architecture,
+		Images:            flattenImages(ctx, filtered, &resp.Diagnostics),
 	}
 
 	resp.Diagnostics.Append(resp.State.Set(ctx, &model)...)
 }
 
+// imageListOptions pushes kind and blueprint filtering down to `multipass
+// find` so the CLI, rather than the provider, decides what is a blueprint.
+// force_refresh maps to --force-update.
+func imageListOptions(config imagesDataSourceModel) multipasscli.ImageListOptions {
+	var opts multipasscli.ImageListOptions
+	switch valueOrEmpty(config.Kind) {
+	case string(models.ImageKindImage):
+		opts.OnlyImages = true
+	case string(models.ImageKindBlueprint):
+		opts.OnlyBlueprints = true
+	}
+	if !config.IncludeBlueprints.IsNull() && !config.IncludeBlueprints.IsUnknown() && !config.IncludeBlueprints.ValueBool() {
+		opts.OnlyImages = true
+		opts.OnlyBlueprints = false
+	}
+	opts.ForceUpdate = config.ForceRefresh.ValueBool()
+	return opts
+}
+
+// annotateArchitecture sets the architecture of every image to that of the
+// machine running multipassd when the architecture filter names a concrete
+// architecture, since `multipass find` only lists images that run there.
+// Neither the find entries, `multipass version` nor `multipass get` report
+// it, so it is read from a running instance; when there is none the images
+// stay unknown and the filter keeps them all, with a warning.
+func (d *imagesDataSource) annotateArchitecture(ctx context.Context, images []models.Image, config imagesDataSourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+	arch := valueOrEmpty(config.Architecture)
+	if arch == "" || arch == imageArchitectureHost {
+		return diags
+	}
+
+	daemonArch, err := daemonArchitecture(ctx, d.client)
+	if err != nil {
+		tflog.Debug(ctx, "Could not determine the daemon architecture", map[string]any{"error": err.Error()})
+		diags.AddAttributeWarning(
+			path.Root("architecture"),
+			"Daemon architecture unknown",
+			fmt.Sprintf("The architecture filter %q was not applied and every image is returned: %s.", arch, err),
+		)
+		return diags
+	}
+	for i := range images {
+		images[i].Architecture = daemonArch
+	}
+	return diags
+}
+
+// errNoRunningInstance is returned by daemonArchitecture when there is no
+// instance to read the architecture from.
+var errNoRunningInstance = errors.New("no running instance to read the architecture of the Multipass host from")
+
+// daemonArchitecture returns the architecture of the machine running
+// multipassd, e.g. amd64 or arm64. Multipass runs instances on the host's
+// own architecture, so it is the `uname -m` of any running instance.
+func daemonArchitecture(ctx context.Context, client multipasscli.Client) (string, error) {
+	instances, err := client.ListInstances(ctx, false)
+	if err != nil {
+		return "", err
+	}
+	for _, inst := range instances {
+		if !strings.EqualFold(inst.State, "Running") {
+			continue
+		}
+		out, err := client.ExecCapture(ctx, inst.Name, []string{"uname", "-m"})
+		if err != nil {
+			return "", fmt.Errorf("reading the architecture of instance %q: %w", inst.Name, err)
+		}
+		switch machine := strings.TrimSpace(string(out)); machine {
+		case "x86_64":
+			return "amd64", nil
+		case "aarch64", "arm64":
+			return "arm64", nil
+		case "":
+			return "", fmt.Errorf("instance %q reported no architecture", inst.Name)
+		default:
+			return machine, nil
+		}
+	}
+	return "", errNoRunningInstance
+}
+
+// filterImages applies the data source filters. Images of unknown
+// architecture pass every architecture filter, and the value host keeps all
+// images.
 func filterImages(images []models.Image, config imagesDataSourceModel) []models.Image {
 	var results []models.Image
 	name := valueOrEmpty(config.Name)
 	alias := valueOrEmpty(config.Alias)
 	kind := valueOrEmpty(config.Kind)
 	query := strings.ToLower(valueOrEmpty(config.Query))
+	remote := valueOrEmpty(config.Remote)
+	includeBlueprints := config.IncludeBlueprints.IsNull() || config.IncludeBlueprints.IsUnknown() || config.IncludeBlueprints.ValueBool()
+	arch := valueOrEmpty(config.Architecture)
+	if arch == imageArchitectureHost {
+		arch = ""
+	}
 
 	for _, img := range images {
 		if name != "" && img.Name != name {
 			continue
 		}
+		if remote != "" && img.Remote != remote {
+			continue
+		}
+		if !includeBlueprints && img.Kind == models.ImageKindBlueprint {
+			continue
+		}
 		if alias != "" && !containsIgnoreCase(img.Aliases, alias) {
 			continue
 		}
@@ -162,6 +303,9 @@ func filterImages(images []models.Image, config imagesDataSourceModel) []models.
 		if query != "" && !strings.Contains(strings.ToLower(img.Name+" "+img.Description), query) {
 			continue
 		}
+		if arch != "" && img.Architecture != "" && img.Architecture != arch {
+			continue
+		}
 		results = append(results, img)
 	}
 	return results
@@ -172,15 +316,20 @@ func flattenImages(ctx context.Context, images []models.Image, diags *diag.Diagn
 	for _, img := range images {
 		aliases, diag := types.ListValueFrom(ctx, types.StringType, img.Aliases)
 		diags.Append(diag...)
+		architecture := types.StringNull()
+		if img.Architecture != "" {
+			architecture = types.StringValue(img.Architecture)
+		}
 		result = append(result, imageModel{
-			Name:        types.StringValue(img.Name),
-			Aliases:     aliases,
-			OS:          types.StringValue(img.OS),
-			Release:     types.StringValue(img.Release),
-			Remote:      types.StringValue(img.Remote),
-			Version:     types.StringValue(img.Version),
-			Description: types.StringValue(img.Description),
-			Kind:        types.StringValue(string(img.Kind)),
+			Name:         types.StringValue(img.Name),
+			Aliases:      aliases,
+			OS:           types.StringValue(img.OS),
+			Release:      types.StringValue(img.Release),
+			Remote:       types.StringValue(img.Remote),
+			Version:      types.StringValue(img.Version),
+			Description:  types.StringValue(img.Description),
+			Kind:         types.StringValue(string(img.Kind)),
+			Architecture: architecture,
 		})
 	}
 	return result
@@ -1,8 +1,11 @@
 package provider
 
 import (
+	"context"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 
 	"github.com/todoroff/terraform-provider-multipass/internal/models"
@@ -23,3 +26,130 @@ func TestFilterImages(t *testing.T) {
 		t.Fatalf("expected lts image, got %#v", got)
 	}
 }
+
+func TestFilterImages_remoteAndBlueprints(t *testing.T) {
+	images := []models.Image{
+		{Name: "24.04", Kind: models.ImageKindImage},
+		{Name: "24.10", Remote: "daily", Kind: models.ImageKindImage},
+		{Name: "docker", Kind: models.ImageKindBlueprint},
+	}
+
+	got := filterImages(images, imagesDataSourceModel{Remote: types.StringValue("daily")})
+	if len(got) != 1 || got[0].Name != "24.10" {
+		t.Fatalf("expected daily image, got %#v", got)
+	}
+
+	got = filterImages(images, imagesDataSourceModel{IncludeBlueprints: types.BoolValue(false)})
+	if len(got) != 2 {
+		t.Fatalf("expected blueprints to be excluded, got %#v", got)
+	}
+
+	opts := imageListOptions(imagesDataSourceModel{Kind: types.StringValue("blueprint"), IncludeBlueprints: types.BoolValue(false)})
+	if !opts.OnlyImages || opts.OnlyBlueprints {
+		t.Fatalf("expected include_blueprints=false to win, got %#v", opts)
+	}
+
+	opts = imageListOptions(imagesDataSourceModel{ForceRefresh: types.BoolValue(true)})
+	if !opts.ForceUpdate {
+		t.Fatalf("expected force_refresh to map to ForceUpdate, got %#v", opts)
+	}
+}
+
+func TestFilterImages_architecture(t *testing.T) {
+	images := []models.Image{
+		{Name: "24.04", Kind: models.ImageKindImage, Architecture: "arm64"},
+		{Name: "22.04", Kind: models.ImageKindImage, Architecture: "amd64"},
+		{Name: "docker", Kind: models.ImageKindBlueprint},
+	}
+
+	got := filterImages(images, imagesDataSourceModel{Architecture: types.StringValue("amd64")})
+	if len(got) != 2 || got[0].Name != "22.04" || got[1].Name != "docker" {
+		t.Fatalf("expected amd64 and unknown architecture images, got %#v", got)
+	}
+	got = filterImages(images, imagesDataSourceModel{Architecture: types.StringValue("host")})
+	if len(got) != 3 {
+		t.Fatalf("expected host to keep every image, got %#v", got)
+	}
+	var diags diag.Diagnostics
+	if flat := flattenImages(context.Background(), got, &diags); flat[0].Architecture.ValueString() != "arm64" || !flat[2].Architecture.IsNull() {
+		t.Fatalf("expected a known and a null architecture, got %v and %v", flat[0].Architecture, flat[2].Architecture)
+	}
+}
+
+func TestImagesAnnotateArchitecture(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	amd64 := imagesDataSourceModel{Architecture: types.StringValue("amd64")}
+	for _, tc := range []struct {
+		name      string
+		config    imagesDataSourceModel
+		instances []models.Instance
+		machine   string
+		want      []string // names left by filterImages
+		wantArch  string
+		warning   string
+	}{
+		{
+			name:      "matching daemon",
+			config:    amd64,
+			instances: []models.Instance{{Name: "off", State: "Stopped"}, {Name: "dev", State: "Running"}},
+			machine:   "x86_64\n",
+			want:      []string{"24.04", "docker"},
+			wantArch:  "amd64",
+		},
+		{
+			name:      "other daemon",
+			config:    amd64,
+			instances: []models.Instance{{Name: "dev", State: "Running"}},
+			machine:   "aarch64\n",
+			wantArch:  "arm64",
+		},
+		{
+			name:      "no running instance",
+			config:    amd64,
+			instances: []models.Instance{{Name: "off", State: "Stopped"}},
+			want:      []string{"24.04", "docker"},
+			warning:   "Daemon architecture unknown",
+		},
+		{
+			name:   "host",
+			config: imagesDataSourceModel{Architecture: types.StringValue("host")},
+			want:   []string{"24.04", "docker"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &fakeClient{
+				listInstances: func(bool) ([]models.Instance, error) { return tc.instances, nil },
+				execCapture: func(string, []string) ([]byte, error) {
+					return []byte(tc.machine), nil
+				},
+			}
+			images := []models.Image{
+				{Name: "24.04", Kind: models.ImageKindImage},
+				{Name: "docker", Kind: models.ImageKindBlueprint},
+			}
+			diags := (&imagesDataSource{client: client}).annotateArchitecture(ctx, images, tc.config)
+			if diags.HasError() {
+				t.Fatalf("unexpected errors: %v", diags)
+			}
+			switch warnings := diags.Warnings(); {
+			case tc.warning == "" && len(warnings) != 0:
+				t.Fatalf("expected no warnings, got %v", warnings)
+			case tc.warning != "" && (len(warnings) != 1 || warnings[0].Summary() != tc.warning):
+				t.Fatalf("expected warning %q, got %v", tc.warning, warnings)
+			}
+			if images[0].Architecture != tc.wantArch {
+				t.Fatalf("expected architecture %q, got %q", tc.wantArch, images[0].Architecture)
+			}
+
+			var names []string
+			for _, img := range filterImages(images, tc.config) {
+				names = append(names, img.Name)
+			}
+			if diff := cmp.Diff(tc.want, names); diff != "" {
+				t.Fatalf("unexpected images (-want +got): %s", diff)
+			}
+		})
+	}
+}
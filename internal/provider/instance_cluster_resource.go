@@ -0,0 +1,544 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework-timeouts/resource/timeouts"
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+var (
+	_ resource.Resource                = (*instanceClusterResource)(nil)
+	_ resource.ResourceWithConfigure   = (*instanceClusterResource)(nil)
+	_ resource.ResourceWithImportState = (*instanceClusterResource)(nil)
+)
+
+// NewInstanceClusterResource instantiates the instance cluster resource.
+func NewInstanceClusterResource() resource.Resource {
+	return &instanceClusterResource{}
+}
+
+type instanceClusterResource struct {
+	client         multipasscli.Client
+	defaultImage   string
+	purgeOnDelete  bool
+	commandTimeout time.Duration
+}
+
+type instanceClusterResourceModel struct {
+	ID               types.String   `tfsdk:"id"`
+	NamePrefix       types.String   `tfsdk:"name_prefix"`
+	Replicas         types.Int64    `tfsdk:"replicas"`
+	Image            types.String   `tfsdk:"image"`
+	CPUs             types.Int64    `tfsdk:"cpus"`
+	Memory           types.String   `tfsdk:"memory"`
+	Disk             types.String   `tfsdk:"disk"`
+	CloudInitFile    types.String   `tfsdk:"cloud_init_file"`
+	CloudInit        types.String   `tfsdk:"cloud_init"`
+	WaitForCloudInit types.Bool     `tfsdk:"wait_for_cloud_init"`
+	PurgeOnDelete    types.Bool     `tfsdk:"purge_on_delete"`
+	Timeouts         timeouts.Value `tfsdk:"timeouts"`
+	Names            types.List     `tfsdk:"names"`
+	IPv4             types.List     `tfsdk:"ipv4"`
+}
+
+func (r *instanceClusterResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_instance_cluster"
+}
+
+func (r *instanceClusterResource) Schema(ctx context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	cloudInitConflict := []path.Expression{
+		path.MatchRelative().AtParent().AtName("cloud_init"),
+		path.MatchRelative().AtParent().AtName("cloud_init_file"),
+	}
+
+	resp.Schema = schema.Schema{
+		Description: "Manages a set of identical Multipass instances named `<name_prefix>-1` to `<name_prefix>-<replicas>`.",
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:    true,
+				Description: "The name prefix.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"name_prefix": schema.StringAttribute{
+				Required:    true,
+				Description: "Prefix of the instance names. Changing forces recreation.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"replicas": schema.Int64Attribute{
+				Required:    true,
+				Description: "Number of instances. Changing it launches or deletes instances in place, deleting the highest indices first.",
+				Validators: []validator.Int64{
+					int64validator.AtLeast(0),
+				},
+			},
+			"image": schema.StringAttribute{
+				Optional:    true,
+				Description: "Image alias or name (e.g., `lts`, `jammy`, `24.04`). Defaults to provider `default_image`. Changing forces recreation.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"cpus": schema.Int64Attribute{
+				Optional:    true,
+				Description: "Number of virtual CPUs per instance. Changing forces recreation.",
+				PlanModifiers: []planmodifier.Int64{
+					int64planmodifier.RequiresReplace(),
+				},
+			},
+			"memory": schema.StringAttribute{
+				Optional:    true,
+				Description: "Memory size per instance (e.g., `1G`, `512M`). Changing forces recreation.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+				Validators: []validator.String{
+					stringvalidator.RegexMatches(memoryRegex, "must follow Multipass size notation, e.g. 1G or 512M"),
+				},
+			},
+			"disk": schema.StringAttribute{
+				Optional:    true,
+				Description: "Disk size per instance (e.g., `5G`). Changing forces recreation.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+				Validators: []validator.String{
+					stringvalidator.RegexMatches(memoryRegex, "must follow Multipass size notation, e.g. 5G"),
+				},
+			},
+			"cloud_init_file": schema.StringAttribute{
+				Optional:    true,
+				Description: "Path to a cloud-init YAML file applied at launch. Conflicts with `cloud_init`. Changing forces recreation.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+				Validators: []validator.String{
+					stringvalidator.ConflictsWith(cloudInitConflict[0]),
+				},
+			},
+			"cloud_init": schema.StringAttribute{
+				Optional:    true,
+				Sensitive:   true,
+				Description: "Inline cloud-init YAML applied at launch. Conflicts with `cloud_init_file`. Changing forces recreation.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+				Validators: []validator.String{
+					stringvalidator.ConflictsWith(cloudInitConflict[1]),
+				},
+			},
+			"wait_for_cloud_init": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Wait for cloud-init to finish in each new instance before launching the next one.",
+			},
+			"purge_on_delete": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Purge instances when they are deleted, on scale-in or destroy. When false, they are only soft-deleted. Defaults to the provider's purge_on_delete, which defaults to true.",
+			},
+			"names": schema.ListAttribute{
+				Computed:    true,
+				ElementType: types.StringType,
+				Description: "Instance names, in index order.",
+			},
+			"ipv4": schema.ListAttribute{
+				Computed:    true,
+				ElementType: types.StringType,
+				Description: "First IPv4 address of each instance, in the same order as names. Empty for an instance without an address.",
+			},
+		},
+		Blocks: map[string]schema.Block{
+			"timeouts": timeouts.Block(ctx, timeouts.Opts{
+				Create: true,
+				Update: true,
+				Delete: true,
+			}),
+		},
+	}
+}
+
+func (r *instanceClusterResource) Configure(_ context.Context, req resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	data := req.ProviderData.(providerData)
+	r.client = data.client
+	r.defaultImage = data.defaultImage
+	r.purgeOnDelete = data.purgeOnDelete
+	r.commandTimeout = data.commandTimeout
+}
+
+func (r *instanceClusterResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	if r.client == nil {
+		resp.Diagnostics.AddError("Client not configured", "The provider Multipass client was not configured.")
+		return
+	}
+
+	var plan instanceClusterResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ctx, done := startOperation(ctx, "multipass_instance_cluster", "create", "")
+	defer done(&resp.Diagnostics)
+
+	launchTimeout, diags := plan.Timeouts.Create(ctx, r.commandTimeout)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	plan.ID = plan.NamePrefix
+	r.scale(ctx, &plan, nil, launchTimeout, &resp.Diagnostics)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *instanceClusterResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	if r.client == nil {
+		resp.Diagnostics.AddError("Client not configured", "The provider Multipass client was not configured.")
+		return
+	}
+
+	var state instanceClusterResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ctx, done := startOperation(ctx, "multipass_instance_cluster", "read", "")
+	defer done(&resp.Diagnostics)
+
+	prefix := state.NamePrefix.ValueString()
+	instances, err := r.client.ListInstances(ctx, true)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to list instances", err.Error())
+		return
+	}
+	replicas := clusterReplicas(instances, prefix)
+	if len(replicas) == 0 && (state.Replicas.IsNull() || state.Replicas.ValueInt64() > 0) {
+		// A cluster scaled to zero legitimately has no instances; otherwise
+		// they were all deleted outside Terraform, or an import found none.
+		tflog.Info(ctx, "No Multipass instances left in cluster", map[string]any{"name_prefix": prefix})
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	// Instances deleted or added outside Terraform show up as a replica
+	// count that differs from the configuration, and the next apply
+	// launches the gaps and deletes the extras.
+	state.ID = types.StringValue(prefix)
+	state.Replicas = types.Int64Value(int64(contiguousReplicas(replicas, prefix)))
+	resp.Diagnostics.Append(applyReplicasToCluster(ctx, replicas, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+}
+
+func (r *instanceClusterResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	if r.client == nil {
+		resp.Diagnostics.AddError("Client not configured", "The provider Multipass client was not configured.")
+		return
+	}
+
+	var plan instanceClusterResourceModel
+	var state instanceClusterResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ctx, done := startOperation(ctx, "multipass_instance_cluster", "update", "")
+	defer done(&resp.Diagnostics)
+
+	launchTimeout, diags := plan.Timeouts.Update(ctx, r.commandTimeout)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	plan.ID = plan.NamePrefix
+	r.scale(ctx, &plan, &state, launchTimeout, &resp.Diagnostics)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *instanceClusterResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	if r.client == nil {
+		resp.Diagnostics.AddError("Client not configured", "The provider Multipass client was not configured.")
+		return
+	}
+
+	var state instanceClusterResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ctx, done := startOperation(ctx, "multipass_instance_cluster", "delete", "")
+	defer done(&resp.Diagnostics)
+
+	deleteTimeout, diags := state.Timeouts.Delete(ctx, r.commandTimeout)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, deleteTimeout)
+	defer cancel()
+
+	instances, err := r.client.ListInstances(ctx, true)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to list instances", err.Error())
+		return
+	}
+	replicas := clusterReplicas(instances, state.NamePrefix.ValueString())
+	purge := r.resolvePurgeOnDelete(state.PurgeOnDelete)
+	for i := len(replicas) - 1; i >= 0; i-- {
+		if err := r.client.DeleteInstance(ctx, replicas[i].Name, purge); err != nil && !errors.Is(err, multipasscli.ErrNotFound) {
+			resp.Diagnostics.AddError("Failed to delete instance", fmt.Sprintf("Deleting %q: %s", replicas[i].Name, err))
+			return
+		}
+	}
+}
+
+// ImportState takes the name prefix; Read then finds the replicas.
+func (r *instanceClusterResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	resource.ImportStatePassthroughID(ctx, path.Root("name_prefix"), req, resp)
+}
+
+// scale launches the missing replicas of plan in ascending order and then
+// deletes the ones above plan.Replicas, highest index first. A replica that
+// fails to launch is deleted again and scaling stops there, so plan ends up
+// describing the replicas that exist. launchTimeout applies to each launch.
+func (r *instanceClusterResource) scale(ctx context.Context, plan, state *instanceClusterResourceModel, launchTimeout time.Duration, diags *diag.Diagnostics) {
+	prefix := plan.NamePrefix.ValueString()
+	want := int(plan.Replicas.ValueInt64())
+	purge := r.resolvePurgeOnDelete(plan.PurgeOnDelete)
+	if state != nil {
+		// Scale-in honours the purge setting the instances were created with
+		// until the new one is stored.
+		purge = r.resolvePurgeOnDelete(state.PurgeOnDelete)
+	}
+
+	instances, err := r.client.ListInstances(ctx, true)
+	if err != nil {
+		diags.AddError("Failed to list instances", err.Error())
+		return
+	}
+	existing := map[string]bool{}
+	for _, inst := range clusterReplicas(instances, prefix) {
+		existing[inst.Name] = true
+	}
+	softDeleted := map[string]bool{}
+	for _, inst := range instances {
+		if _, ok := clusterReplicaIndex(inst.Name, prefix); ok && strings.EqualFold(inst.State, "Deleted") {
+			softDeleted[inst.Name] = true
+		}
+	}
+
+	for i := 1; i <= want; i++ {
+		name := clusterReplicaName(prefix, i)
+		if existing[name] {
+			continue
+		}
+		if softDeleted[name] {
+			// A replica scaled in without purge_on_delete still holds its
+			// name. It is left for the user to recover or purge.
+			diags.AddError(
+				"Cluster instance soft-deleted",
+				fmt.Sprintf("%q was deleted without purging and still holds its name, so it cannot be launched again. Recover it with `multipass recover %s` or remove it with `multipass purge`, then apply again.", name, name),
+			)
+			break
+		}
+		if err := r.launchReplica(ctx, plan, name, launchTimeout); err != nil {
+			diags.AddError("Failed to launch cluster instance", err.Error())
+			break
+		}
+		existing[name] = true
+	}
+
+	if !diags.HasError() {
+		for _, inst := range descendingReplicas(instances, prefix) {
+			if index, _ := clusterReplicaIndex(inst.Name, prefix); index <= want {
+				continue
+			}
+			tflog.Info(ctx, "Deleting cluster instance", map[string]any{"name": inst.Name})
+			if err := r.client.DeleteInstance(ctx, inst.Name, purge); err != nil && !errors.Is(err, multipasscli.ErrNotFound) {
+				diags.AddError("Failed to delete cluster instance", fmt.Sprintf("Deleting %q: %s", inst.Name, err))
+				break
+			}
+		}
+	}
+
+	instances, err = r.client.ListInstances(ctx, true)
+	if err != nil {
+		diags.AddError("Failed to list instances", err.Error())
+		return
+	}
+	replicas := clusterReplicas(instances, prefix)
+	diags.Append(applyReplicasToCluster(ctx, replicas, plan)...)
+	if diags.HasError() {
+		plan.Replicas = types.Int64Value(int64(contiguousReplicas(replicas, prefix)))
+		diags.AddError(
+			"Cluster partially applied",
+			fmt.Sprintf("The cluster now has %d of %d instances: %s. The next apply retries the rest.", len(replicas), want, describeReplicas(replicas)),
+		)
+	}
+}
+
+// launchReplica launches one replica and, when the launch or the cloud-init
+// wait fails, deletes it again so no half-provisioned instance is left
+// behind. scale only launches names it did not list, so an instance found
+// under name afterwards came from this launch; a missing or soft-deleted one
+// did not and is left alone.
+func (r *instanceClusterResource) launchReplica(ctx context.Context, plan *instanceClusterResourceModel, name string, timeout time.Duration) error {
+	launchCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	tflog.Info(ctx, "Launching cluster instance", map[string]any{"name": name})
+	err := r.client.LaunchInstance(launchCtx, models.LaunchOptions{
+		Name:            name,
+		Image:           resolveImage(plan.Image, r.defaultImage),
+		CPUs:            valueOrDefaultInt(plan.CPUs, 1),
+		Memory:          valueOrDefaultString(plan.Memory, "1G"),
+		Disk:            valueOrDefaultString(plan.Disk, "5G"),
+		CloudInitFile:   valueOrEmpty(plan.CloudInitFile),
+		CloudInitInline: valueOrEmpty(plan.CloudInit),
+	})
+	if err == nil && plan.WaitForCloudInit.ValueBool() {
+		err = waitForCloudInit(launchCtx, r.client, name)
+	}
+	if err == nil {
+		return nil
+	}
+
+	// The launch context may have expired, but the rollback still has to run.
+	rollbackCtx, rollbackCancel := context.WithTimeout(context.WithoutCancel(ctx), r.commandTimeout)
+	defer rollbackCancel()
+	inst, getErr := r.client.GetInstance(rollbackCtx, name)
+	if errors.Is(getErr, multipasscli.ErrNotFound) || (getErr == nil && strings.EqualFold(inst.State, "Deleted")) {
+		return fmt.Errorf("%q: %w", name, err)
+	}
+	if delErr := r.client.DeleteInstance(rollbackCtx, name, true); delErr != nil && !errors.Is(delErr, multipasscli.ErrNotFound) {
+		return fmt.Errorf("%q: %w (rolling it back also failed: %s; remove it with `multipass delete --purge %s`)", name, err, delErr, name)
+	}
+	return fmt.Errorf("%q: %w", name, err)
+}
+
+// resolvePurgeOnDelete returns purge_on_delete, falling back to the
+// provider default.
+func (r *instanceClusterResource) resolvePurgeOnDelete(value types.Bool) bool {
+	if value.IsNull() || value.IsUnknown() {
+		return r.purgeOnDelete
+	}
+	return value.ValueBool()
+}
+
+func clusterReplicaName(prefix string, index int) string {
+	return fmt.Sprintf("%s-%d", prefix, index)
+}
+
+// clusterReplicaIndex parses the index out of a `<prefix>-<index>` name.
+func clusterReplicaIndex(name, prefix string) (int, bool) {
+	suffix, ok := strings.CutPrefix(name, prefix+"-")
+	if !ok || suffix == "" || suffix[0] == '0' {
+		return 0, false
+	}
+	index, err := strconv.Atoi(suffix)
+	if err != nil || index < 1 {
+		return 0, false
+	}
+	return index, true
+}
+
+// clusterReplicas returns the instances named `<prefix>-<index>`, in index
+// order. Soft-deleted instances do not count.
+func clusterReplicas(instances []models.Instance, prefix string) []models.Instance {
+	var replicas []models.Instance
+	for _, inst := range instances {
+		if _, ok := clusterReplicaIndex(inst.Name, prefix); ok && !strings.EqualFold(inst.State, "Deleted") {
+			replicas = append(replicas, inst)
+		}
+	}
+	sort.Slice(replicas, func(i, j int) bool {
+		a, _ := clusterReplicaIndex(replicas[i].Name, prefix)
+		b, _ := clusterReplicaIndex(replicas[j].Name, prefix)
+		return a < b
+	})
+	return replicas
+}
+
+func descendingReplicas(instances []models.Instance, prefix string) []models.Instance {
+	replicas := clusterReplicas(instances, prefix)
+	for i, j := 0, len(replicas)-1; i < j; i, j = i+1, j-1 {
+		replicas[i], replicas[j] = replicas[j], replicas[i]
+	}
+	return replicas
+}
+
+// contiguousReplicas counts the replicas from index 1 up to the first gap.
+func contiguousReplicas(replicas []models.Instance, prefix string) int {
+	count := 0
+	for _, inst := range replicas {
+		if index, _ := clusterReplicaIndex(inst.Name, prefix); index != count+1 {
+			break
+		}
+		count++
+	}
+	return count
+}
+
+func describeReplicas(replicas []models.Instance) string {
+	if len(replicas) == 0 {
+		return "none"
+	}
+	names := make([]string, len(replicas))
+	for i, inst := range replicas {
+		names[i] = inst.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+func applyReplicasToCluster(ctx context.Context, replicas []models.Instance, model *instanceClusterResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+	names := make([]string, len(replicas))
+	ipv4 := make([]string, len(replicas))
+	for i, inst := range replicas {
+		names[i] = inst.Name
+		if len(inst.IPv4) > 0 {
+			ipv4[i] = inst.IPv4[0]
+		}
+	}
+
+	list, d := types.ListValueFrom(ctx, types.StringType, names)
+	diags.Append(d...)
+	model.Names = list
+	list, d = types.ListValueFrom(ctx, types.StringType, ipv4)
+	diags.Append(d...)
+	model.IPv4 = list
+	return diags
+}
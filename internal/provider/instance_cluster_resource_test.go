@@ -0,0 +1,318 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-framework-timeouts/resource/timeouts"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+var nullClusterTimeouts = timeouts.Value{Object: types.ObjectNull(map[string]attr.Type{
+	"create": types.StringType,
+	"update": types.StringType,
+	"delete": types.StringType,
+})}
+
+// clusterHost fakes a Multipass host holding the named instances, failing
+// to launch the ones listed in failLaunch.
+func clusterHost(names []string, failLaunch ...string) (*fakeClient, map[string]bool) {
+	existing := map[string]bool{}
+	for _, name := range names {
+		existing[name] = true
+	}
+	client := &fakeClient{
+		listInstances: func(bool) ([]models.Instance, error) {
+			var instances []models.Instance
+			for name := range existing {
+				instances = append(instances, models.Instance{Name: name, State: "Running", IPv4: []string{"10.0.0." + name[len(name)-1:]}})
+			}
+			return instances, nil
+		},
+		launchInstance: func(opts models.LaunchOptions) error {
+			existing[opts.Name] = true
+			for _, name := range failLaunch {
+				if name == opts.Name {
+					return errors.New("launch failed")
+				}
+			}
+			return nil
+		},
+		deleteInstance: func(name string) error {
+			if !existing[name] {
+				return multipasscli.ErrNotFound
+			}
+			delete(existing, name)
+			return nil
+		},
+	}
+	return client, existing
+}
+
+func clusterPlan(replicas int64) instanceClusterResourceModel {
+	return instanceClusterResourceModel{
+		ID:               types.StringUnknown(),
+		NamePrefix:       types.StringValue("web"),
+		Replicas:         types.Int64Value(replicas),
+		Image:            types.StringNull(),
+		CPUs:             types.Int64Null(),
+		Memory:           types.StringNull(),
+		Disk:             types.StringNull(),
+		CloudInitFile:    types.StringNull(),
+		CloudInit:        types.StringNull(),
+		WaitForCloudInit: types.BoolNull(),
+		PurgeOnDelete:    types.BoolNull(),
+		Timeouts:         nullClusterTimeouts,
+		Names:            types.ListUnknown(types.StringType),
+		IPv4:             types.ListUnknown(types.StringType),
+	}
+}
+
+func clusterStrings(t *testing.T, list types.List) []string {
+	t.Helper()
+	var out []string
+	if diags := list.ElementsAs(context.Background(), &out, false); diags.HasError() {
+		t.Fatalf("read list: %v", diags)
+	}
+	return out
+}
+
+func TestInstanceClusterCreate(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	for _, tc := range []struct {
+		name       string
+		failLaunch []string
+		wantCalls  []string
+		wantNames  []string
+		wantError  string
+	}{
+		{
+			name:      "launches in order",
+			wantCalls: []string{"list", "launch web-1", "launch web-2", "launch web-3", "list"},
+			wantNames: []string{"web-1", "web-2", "web-3"},
+		},
+		{
+			name:       "rolls back the failed replica only",
+			failLaunch: []string{"web-2"},
+			wantCalls:  []string{"list", "launch web-1", "launch web-2", "info web-2", "delete web-2 purge=true", "list"},
+			wantNames:  []string{"web-1"},
+			wantError:  "1 of 3 instances: web-1",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client, _ := clusterHost(nil, tc.failLaunch...)
+			r := &instanceClusterResource{client: client, defaultImage: "lts", purgeOnDelete: false, commandTimeout: time.Minute}
+			var schemaResp resource.SchemaResponse
+			r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+			plan := clusterPlan(3)
+			tfPlan := tfsdk.Plan{Schema: schemaResp.Schema}
+			if diags := tfPlan.Set(ctx, &plan); diags.HasError() {
+				t.Fatalf("set plan: %v", diags)
+			}
+			resp := resource.CreateResponse{State: tfsdk.State{Schema: schemaResp.Schema, Raw: tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil)}}
+			r.Create(ctx, resource.CreateRequest{Plan: tfPlan}, &resp)
+
+			if tc.wantError == "" && resp.Diagnostics.HasError() {
+				t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+			}
+			if errs := resp.Diagnostics.Errors(); tc.wantError != "" && (len(errs) == 0 || !strings.Contains(errs[len(errs)-1].Detail(), tc.wantError)) {
+				t.Fatalf("expected an error mentioning %q, got %v", tc.wantError, resp.Diagnostics)
+			}
+			if diff := cmp.Diff(tc.wantCalls, client.recorded()); diff != "" {
+				t.Fatalf("unexpected calls (-want +got): %s", diff)
+			}
+
+			var got instanceClusterResourceModel
+			resp.State.Get(ctx, &got)
+			if diff := cmp.Diff(tc.wantNames, clusterStrings(t, got.Names)); diff != "" {
+				t.Fatalf("unexpected names (-want +got): %s", diff)
+			}
+			if got.Replicas.ValueInt64() != int64(len(tc.wantNames)) {
+				t.Fatalf("expected replicas to match the instances that exist, got %d", got.Replicas.ValueInt64())
+			}
+		})
+	}
+}
+
+func TestInstanceClusterUpdateScalesIn(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	client, existing := clusterHost([]string{"web-1", "web-2", "web-3"})
+	r := &instanceClusterResource{client: client, purgeOnDelete: true, commandTimeout: time.Minute}
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	state := clusterPlan(3)
+	state.ID = types.StringValue("web")
+	state.Names, _ = types.ListValueFrom(ctx, types.StringType, []string{"web-1", "web-2", "web-3"})
+	state.IPv4, _ = types.ListValueFrom(ctx, types.StringType, []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"})
+	tfState := tfsdk.State{Schema: schemaResp.Schema}
+	if diags := tfState.Set(ctx, &state); diags.HasError() {
+		t.Fatalf("set state: %v", diags)
+	}
+	plan := clusterPlan(1)
+	plan.ID = types.StringValue("web")
+	tfPlan := tfsdk.Plan{Schema: schemaResp.Schema}
+	if diags := tfPlan.Set(ctx, &plan); diags.HasError() {
+		t.Fatalf("set plan: %v", diags)
+	}
+
+	resp := resource.UpdateResponse{State: tfState}
+	r.Update(ctx, resource.UpdateRequest{Plan: tfPlan, State: tfState}, &resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+	}
+	want := []string{"list", "delete web-3 purge=true", "delete web-2 purge=true", "list"}
+	if diff := cmp.Diff(want, client.recorded()); diff != "" {
+		t.Fatalf("unexpected calls (-want +got): %s", diff)
+	}
+	if len(existing) != 1 || !existing["web-1"] {
+		t.Fatalf("expected only web-1 to remain, got %v", existing)
+	}
+
+	var got instanceClusterResourceModel
+	resp.State.Get(ctx, &got)
+	if diff := cmp.Diff([]string{"10.0.0.1"}, clusterStrings(t, got.IPv4)); diff != "" {
+		t.Fatalf("unexpected ipv4 (-want +got): %s", diff)
+	}
+}
+
+// TestInstanceClusterScaleOutOverSoftDeleted scales a cluster in without
+// purge_on_delete and out again: the soft-deleted replica must survive.
+func TestInstanceClusterScaleOutOverSoftDeleted(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	host := newFakeHost()
+	r := &instanceClusterResource{client: host, defaultImage: "lts", commandTimeout: time.Minute}
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	update := func(from, to int64) (resource.UpdateResponse, error) {
+		state := clusterPlan(from)
+		state.ID = types.StringValue("web")
+		state.PurgeOnDelete = types.BoolValue(false)
+		state.Names = types.ListNull(types.StringType)
+		state.IPv4 = types.ListNull(types.StringType)
+		tfState := tfsdk.State{Schema: schemaResp.Schema}
+		if diags := tfState.Set(ctx, &state); diags.HasError() {
+			return resource.UpdateResponse{}, fmt.Errorf("set state: %v", diags)
+		}
+		plan := clusterPlan(to)
+		plan.ID = types.StringValue("web")
+		plan.PurgeOnDelete = types.BoolValue(false)
+		tfPlan := tfsdk.Plan{Schema: schemaResp.Schema}
+		if diags := tfPlan.Set(ctx, &plan); diags.HasError() {
+			return resource.UpdateResponse{}, fmt.Errorf("set plan: %v", diags)
+		}
+		resp := resource.UpdateResponse{State: tfState}
+		r.Update(ctx, resource.UpdateRequest{Plan: tfPlan, State: tfState}, &resp)
+		return resp, nil
+	}
+
+	for _, name := range []string{"web-1", "web-2"} {
+		if err := host.LaunchInstance(ctx, models.LaunchOptions{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resp, err := update(2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("unexpected diagnostics scaling in: %v", resp.Diagnostics)
+	}
+	if inst := host.instance("web-2"); inst == nil || inst.State != "Deleted" {
+		t.Fatalf("expected web-2 to be soft-deleted, got %+v", inst)
+	}
+
+	resp, err = update(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := resp.Diagnostics.Errors(); len(errs) == 0 || errs[0].Summary() != "Cluster instance soft-deleted" {
+		t.Fatalf("expected the soft-deleted replica to be reported, got %v", resp.Diagnostics)
+	}
+	if inst := host.instance("web-2"); inst == nil || inst.State != "Deleted" {
+		t.Fatalf("expected web-2 to stay recoverable, got %+v", inst)
+	}
+	if n := host.launchCount("web-2"); n != 1 {
+		t.Fatalf("expected no second launch of web-2, got %d launches", n)
+	}
+	var got instanceClusterResourceModel
+	resp.State.Get(ctx, &got)
+	if got.Replicas.ValueInt64() != 1 {
+		t.Fatalf("expected replicas to stay at 1, got %d", got.Replicas.ValueInt64())
+	}
+}
+
+func TestInstanceClusterReadDrift(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	// web-2 was deleted by hand, so web-3 no longer counts towards
+	// replicas; web-x and webapp-1 are not replicas at all.
+	client, _ := clusterHost([]string{"web-1", "web-3", "web-x", "webapp-1"})
+	r := &instanceClusterResource{client: client, commandTimeout: time.Minute}
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	state := clusterPlan(3)
+	state.ID = types.StringValue("web")
+	state.Names = types.ListNull(types.StringType)
+	state.IPv4 = types.ListNull(types.StringType)
+	tfState := tfsdk.State{Schema: schemaResp.Schema}
+	if diags := tfState.Set(ctx, &state); diags.HasError() {
+		t.Fatalf("set state: %v", diags)
+	}
+	resp := resource.ReadResponse{State: tfState}
+	r.Read(ctx, resource.ReadRequest{State: tfState}, &resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+	}
+
+	var got instanceClusterResourceModel
+	resp.State.Get(ctx, &got)
+	if got.Replicas.ValueInt64() != 1 {
+		t.Fatalf("expected replicas to stop at the gap, got %d", got.Replicas.ValueInt64())
+	}
+	if diff := cmp.Diff([]string{"web-1", "web-3"}, clusterStrings(t, got.Names)); diff != "" {
+		t.Fatalf("unexpected names (-want +got): %s", diff)
+	}
+}
+
+func TestClusterReplicaIndex(t *testing.T) {
+	t.Parallel()
+
+	for name, want := range map[string]int{
+		"web-1":    1,
+		"web-12":   12,
+		"web-0":    0,
+		"web-01":   0,
+		"web-":     0,
+		"web-x":    0,
+		"webapp-1": 0,
+		"web-1-2":  0,
+	} {
+		got, ok := clusterReplicaIndex(name, "web")
+		if got != want || ok != (want > 0) {
+			t.Errorf("clusterReplicaIndex(%q) = %d, %t; want %d", name, got, ok, want)
+		}
+	}
+}
@@ -2,18 +2,26 @@ package provider
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
 	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
 )
 
 var (
-	_ datasource.DataSource              = (*instanceDataSource)(nil)
-	_ datasource.DataSourceWithConfigure = (*instanceDataSource)(nil)
+	_ datasource.DataSource                   = (*instanceDataSource)(nil)
+	_ datasource.DataSourceWithConfigure      = (*instanceDataSource)(nil)
+	_ datasource.DataSourceWithValidateConfig = (*instanceDataSource)(nil)
 )
 
 // NewInstanceDataSource returns the instance data source.
@@ -27,19 +35,32 @@ type instanceDataSource struct {
 
 type instanceDataSourceModel struct {
 	Name          types.String `tfsdk:"name"`
+	IPv4Address   types.String `tfsdk:"ipv4_address"`
+	AllowMissing  types.Bool   `tfsdk:"allow_missing"`
+	Exists        types.Bool   `tfsdk:"exists"`
 	State         types.String `tfsdk:"state"`
 	Release       types.String `tfsdk:"release"`
 	ImageRelease  types.String `tfsdk:"image_release"`
 	IPv4          types.List   `tfsdk:"ipv4"`
+	IPv6          types.List   `tfsdk:"ipv6"`
 	CPUCount      types.Int64  `tfsdk:"cpu_count"`
 	MemoryTotal   types.Int64  `tfsdk:"memory_total_bytes"`
 	MemoryUsed    types.Int64  `tfsdk:"memory_used_bytes"`
 	DiskTotal     types.Int64  `tfsdk:"disk_total_bytes"`
 	DiskUsed      types.Int64  `tfsdk:"disk_used_bytes"`
 	SnapshotCount types.Int64  `tfsdk:"snapshot_count"`
+	Mounts        []mountModel `tfsdk:"mounts"`
 	LastUpdated   types.String `tfsdk:"last_updated"`
 }
 
+type mountModel struct {
+	HostPath     types.String `tfsdk:"host_path"`
+	InstancePath types.String `tfsdk:"instance_path"`
+	ReadOnly     types.Bool   `tfsdk:"read_only"`
+	UIDMappings  types.List   `tfsdk:"uid_mappings"`
+	GIDMappings  types.List   `tfsdk:"gid_mappings"`
+}
+
 func (d *instanceDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
 	resp.TypeName = req.ProviderTypeName + "_instance"
 }
@@ -49,8 +70,21 @@ func (d *instanceDataSource) Schema(_ context.Context, _ datasource.SchemaReques
 		Description: "Reads an existing Multipass instance.",
 		Attributes: map[string]schema.Attribute{
 			"name": schema.StringAttribute{
-				Required:    true,
-				Description: "Instance name to inspect.",
+				Optional:    true,
+				Computed:    true,
+				Description: "Instance name to inspect. Exactly one of `name` or `ipv4_address` is required; when looking up by address, this is the name of the instance found.",
+			},
+			"ipv4_address": schema.StringAttribute{
+				Optional:    true,
+				Description: "IPv4 address of the instance to inspect, e.g. from a DHCP reservation. Exactly one instance must report it. Exactly one of `name` or `ipv4_address` is required.",
+			},
+			"allow_missing": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Return `exists = false` with the other attributes null instead of failing when the instance does not exist (default: false).",
+			},
+			"exists": schema.BoolAttribute{
+				Computed:    true,
+				Description: "Whether the instance exists. Only ever false when allow_missing is set.",
 			},
 			"state": schema.StringAttribute{
 				Computed: true,
@@ -65,6 +99,10 @@ func (d *instanceDataSource) Schema(_ context.Context, _ datasource.SchemaReques
 				ElementType: types.StringType,
 				Computed:    true,
 			},
+			"ipv6": schema.ListAttribute{
+				ElementType: types.StringType,
+				Computed:    true,
+			},
 			"cpu_count": schema.Int64Attribute{
 				Computed: true,
 			},
@@ -83,6 +121,33 @@ func (d *instanceDataSource) Schema(_ context.Context, _ datasource.SchemaReques
 			"snapshot_count": schema.Int64Attribute{
 				Computed: true,
 			},
+			"mounts": schema.ListNestedAttribute{
+				Computed:    true,
+				Description: "Host directories mounted into the instance, sorted by instance_path.",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"host_path": schema.StringAttribute{
+							Computed: true,
+						},
+						"instance_path": schema.StringAttribute{
+							Computed: true,
+						},
+						"read_only": schema.BoolAttribute{
+							Computed: true,
+						},
+						"uid_mappings": schema.ListAttribute{
+							ElementType: types.StringType,
+							Computed:    true,
+							Description: "Ordered `host:instance` UID pairs; the instance side is `default` for the default instance user.",
+						},
+						"gid_mappings": schema.ListAttribute{
+							ElementType: types.StringType,
+							Computed:    true,
+							Description: "Ordered `host:instance` GID pairs; the instance side is `default` for the default instance group.",
+						},
+					},
+				},
+			},
 			"last_updated": schema.StringAttribute{
 				Computed: true,
 			},
@@ -98,6 +163,34 @@ func (d *instanceDataSource) Configure(_ context.Context, req datasource.Configu
 	d.client = data.client
 }
 
+// ValidateConfig requires exactly one of name and ipv4_address, and the
+// address to be IPv4.
+func (d *instanceDataSource) ValidateConfig(ctx context.Context, req datasource.ValidateConfigRequest, resp *datasource.ValidateConfigResponse) {
+	var config instanceDataSourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+	if resp.Diagnostics.HasError() || config.Name.IsUnknown() || config.IPv4Address.IsUnknown() {
+		return
+	}
+
+	if config.Name.IsNull() == config.IPv4Address.IsNull() {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("name"),
+			"Invalid instance lookup",
+			"Exactly one of name or ipv4_address must be set.",
+		)
+		return
+	}
+	if !config.IPv4Address.IsNull() {
+		if addr, err := netip.ParseAddr(config.IPv4Address.ValueString()); err != nil || !addr.Unmap().Is4() {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("ipv4_address"),
+				"Invalid IPv4 address",
+				fmt.Sprintf("%q is not an IPv4 address.", config.IPv4Address.ValueString()),
+			)
+		}
+	}
+}
+
 func (d *instanceDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
 	if d.client == nil {
 		resp.Diagnostics.AddError("Client not configured", "Multipass client is nil.")
@@ -110,10 +203,34 @@ func (d *instanceDataSource) Read(ctx context.Context, req datasource.ReadReques
 		return
 	}
 
-	instance, err := d.client.GetInstance(ctx, config.Name.ValueString())
+	name := config.Name.ValueString()
+	var err error
+	if !config.IPv4Address.IsNull() {
+		name, err = d.instanceByIPv4(ctx, config.IPv4Address.ValueString())
+	}
+	var instance *models.Instance
+	if err == nil {
+		instance, err = d.client.GetInstance(ctx, name)
+	}
+	if errors.Is(err, multipasscli.ErrNotFound) && config.AllowMissing.ValueBool() {
+		missing := instanceDataSourceModel{
+			Name:         config.Name,
+			IPv4Address:  config.IPv4Address,
+			AllowMissing: config.AllowMissing,
+			Exists:       types.BoolValue(false),
+			IPv4:         types.ListNull(types.StringType),
+			IPv6:         types.ListNull(types.StringType),
+		}
+		resp.Diagnostics.Append(resp.State.Set(ctx, &missing)...)
+		return
+	}
 	if err != nil {
-		if err == multipasscli.ErrNotFound {
-			resp.Diagnostics.AddError("Instance not found", "The requested Multipass instance does not exist.")
+		if errors.Is(err, multipasscli.ErrNotFound) {
+			detail := "The requested Multipass instance does not exist."
+			if !config.IPv4Address.IsNull() {
+				detail = fmt.Sprintf("No Multipass instance reports the address %s.", config.IPv4Address.ValueString())
+			}
+			resp.Diagnostics.AddError("Instance not found", detail)
 			return
 		}
 		resp.Diagnostics.AddError("Failed to read instance", err.Error())
@@ -122,21 +239,78 @@ func (d *instanceDataSource) Read(ctx context.Context, req datasource.ReadReques
 
 	ipv4, diag := types.ListValueFrom(ctx, types.StringType, instance.IPv4)
 	resp.Diagnostics.Append(diag...)
+	ipv6, diag := types.ListValueFrom(ctx, types.StringType, instance.IPv6)
+	resp.Diagnostics.Append(diag...)
 
 	state := instanceDataSourceModel{
 		Name:          types.StringValue(instance.Name),
+		IPv4Address:   config.IPv4Address,
+		AllowMissing:  config.AllowMissing,
+		Exists:        types.BoolValue(true),
 		State:         types.StringValue(instance.State),
 		Release:       types.StringValue(instance.Release),
 		ImageRelease:  types.StringValue(instance.ImageRelease),
 		IPv4:          ipv4,
+		IPv6:          ipv6,
 		CPUCount:      types.Int64Value(int64(instance.CPUCount)),
 		MemoryTotal:   types.Int64Value(int64(instance.MemoryTotal)),
 		MemoryUsed:    types.Int64Value(int64(instance.MemoryUsed)),
 		DiskTotal:     types.Int64Value(int64(instance.DiskTotal)),
 		DiskUsed:      types.Int64Value(int64(instance.DiskUsed)),
 		SnapshotCount: types.Int64Value(int64(instance.SnapshotCount)),
+		Mounts:        flattenMounts(instance.Mounts),
 		LastUpdated:   types.StringValue(instance.LastUpdated.UTC().Format(time.RFC3339)),
 	}
 
 	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
 }
+
+// instanceByIPv4 returns the name of the only instance that reports address,
+// or ErrNotFound when none does.
+func (d *instanceDataSource) instanceByIPv4(ctx context.Context, address string) (string, error) {
+	want, err := netip.ParseAddr(address)
+	if err != nil {
+		return "", err
+	}
+	instances, err := d.client.ListInstances(ctx, false)
+	if err != nil {
+		return "", err
+	}
+
+	var matches []string
+	for _, inst := range instances {
+		for _, ip := range inst.IPv4 {
+			if addr, err := netip.ParseAddr(ip); err == nil && addr == want.Unmap() {
+				matches = append(matches, inst.Name)
+				break
+			}
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", multipasscli.ErrNotFound
+	case 1:
+		return matches[0], nil
+	}
+	sort.Strings(matches)
+	return "", fmt.Errorf("address %s is reported by more than one instance: %s", address, strings.Join(matches, ", "))
+}
+
+// flattenMounts converts mounts to their data source model, sorted by
+// instance path.
+func flattenMounts(mounts []models.Mount) []mountModel {
+	sorted := append([]models.Mount{}, mounts...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].InstancePath < sorted[j].InstancePath })
+
+	result := make([]mountModel, 0, len(sorted))
+	for _, m := range sorted {
+		result = append(result, mountModel{
+			HostPath:     types.StringValue(m.HostPath),
+			InstancePath: types.StringValue(m.InstancePath),
+			ReadOnly:     types.BoolValue(m.ReadOnly),
+			UIDMappings:  stringListValue(m.UIDMappings),
+			GIDMappings:  stringListValue(m.GIDMappings),
+		})
+	}
+	return result
+}
@@ -0,0 +1,230 @@
+package provider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+// readInstanceDataSource runs instanceDataSource.Read for instance "dev",
+// with allow_missing set when allowMissing is non-nil, and returns the
+// response.
+func readInstanceDataSource(t *testing.T, client *fakeClient, allowMissing *bool) datasource.ReadResponse {
+	t.Helper()
+	attrs := map[string]tftypes.Value{"name": tftypes.NewValue(tftypes.String, "dev")}
+	if allowMissing != nil {
+		attrs["allow_missing"] = tftypes.NewValue(tftypes.Bool, *allowMissing)
+	}
+	return readInstanceDataSourceConfig(t, client, attrs)
+}
+
+// instanceDataSourceConfig builds a multipass_instance data source config
+// with every attribute null except those in attrs.
+func instanceDataSourceConfig(t *testing.T, attrs map[string]tftypes.Value) tfsdk.Config {
+	t.Helper()
+	ctx := context.Background()
+
+	var schemaResp datasource.SchemaResponse
+	(&instanceDataSource{}).Schema(ctx, datasource.SchemaRequest{}, &schemaResp)
+	configType := schemaResp.Schema.Type().TerraformType(ctx).(tftypes.Object)
+	values := map[string]tftypes.Value{}
+	for name, typ := range configType.AttributeTypes {
+		values[name] = tftypes.NewValue(typ, nil)
+	}
+	for name, v := range attrs {
+		values[name] = v
+	}
+	return tfsdk.Config{Schema: schemaResp.Schema, Raw: tftypes.NewValue(configType, values)}
+}
+
+// readInstanceDataSourceConfig runs instanceDataSource.Read with the
+// config built from attrs and returns the response.
+func readInstanceDataSourceConfig(t *testing.T, client *fakeClient, attrs map[string]tftypes.Value) datasource.ReadResponse {
+	t.Helper()
+	ctx := context.Background()
+
+	d := &instanceDataSource{client: client}
+	config := instanceDataSourceConfig(t, attrs)
+	resp := datasource.ReadResponse{State: tfsdk.State{
+		Schema: config.Schema,
+		Raw:    tftypes.NewValue(config.Raw.Type(), nil),
+	}}
+	d.Read(ctx, datasource.ReadRequest{Config: config}, &resp)
+	return resp
+}
+
+func TestInstanceDataSourceReadMounts(t *testing.T) {
+	t.Parallel()
+
+	client := &fakeClient{getInstance: func(name string) (*models.Instance, error) {
+		return &models.Instance{
+			Name:        name,
+			State:       "Running",
+			LastUpdated: time.Now(),
+			Mounts: []models.Mount{
+				{HostPath: "/Users/dev/src", InstancePath: "/home/ubuntu/src", UIDMappings: []string{"501:default"}, GIDMappings: []string{"20:default"}},
+				{HostPath: "/Users/dev/data", InstancePath: "/data", ReadOnly: true},
+			},
+		}, nil
+	}}
+	resp := readInstanceDataSource(t, client, nil)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+	}
+
+	var state instanceDataSourceModel
+	resp.State.Get(context.Background(), &state)
+	if len(state.Mounts) != 2 {
+		t.Fatalf("expected 2 mounts, got %+v", state.Mounts)
+	}
+	data, src := state.Mounts[0], state.Mounts[1]
+	if data.InstancePath.ValueString() != "/data" || !data.ReadOnly.ValueBool() || data.UIDMappings.IsNull() || len(data.UIDMappings.Elements()) != 0 {
+		t.Fatalf("unexpected first mount: %+v", data)
+	}
+	if src.HostPath.ValueString() != "/Users/dev/src" || src.ReadOnly.ValueBool() ||
+		!src.UIDMappings.Equal(stringListValue([]string{"501:default"})) || !src.GIDMappings.Equal(stringListValue([]string{"20:default"})) {
+		t.Fatalf("unexpected second mount: %+v", src)
+	}
+}
+
+func TestInstanceDataSourceAllowMissing(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	missing := &fakeClient{getInstance: func(string) (*models.Instance, error) { return nil, multipasscli.ErrNotFound }}
+	allow, deny := true, false
+
+	t.Run("missing fails by default", func(t *testing.T) {
+		for _, allowMissing := range []*bool{nil, &deny} {
+			if resp := readInstanceDataSource(t, missing, allowMissing); !resp.Diagnostics.HasError() {
+				t.Fatalf("expected an error for a missing instance")
+			}
+		}
+	})
+
+	t.Run("missing allowed", func(t *testing.T) {
+		resp := readInstanceDataSource(t, missing, &allow)
+		if resp.Diagnostics.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+		}
+		var state instanceDataSourceModel
+		resp.State.Get(ctx, &state)
+		if state.Exists.IsNull() || state.Exists.ValueBool() || state.Name.ValueString() != "dev" {
+			t.Fatalf("expected exists = false, got %+v", state)
+		}
+		if !state.State.IsNull() || !state.IPv4.IsNull() || !state.CPUCount.IsNull() || state.Mounts != nil || !state.LastUpdated.IsNull() {
+			t.Fatalf("expected null computed attributes, got %+v", state)
+		}
+	})
+
+	t.Run("present", func(t *testing.T) {
+		resp := readInstanceDataSource(t, &fakeClient{}, &allow)
+		if resp.Diagnostics.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+		}
+		var state instanceDataSourceModel
+		resp.State.Get(ctx, &state)
+		if !state.Exists.ValueBool() || state.State.ValueString() != "Running" {
+			t.Fatalf("expected exists = true, got %+v", state)
+		}
+	})
+}
+
+func TestInstanceDataSourceByIPv4(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	host := &fakeClient{listInstances: func(bool) ([]models.Instance, error) {
+		return []models.Instance{
+			{Name: "db", IPv4: []string{"10.211.55.7"}},
+			{Name: "web", IPv4: []string{"10.211.55.8", "192.168.1.20"}},
+			{Name: "web-copy", IPv4: []string{"192.168.1.20"}},
+		}, nil
+	}}
+	allow := tftypes.NewValue(tftypes.Bool, true)
+
+	for _, tc := range []struct {
+		name         string
+		address      string
+		allowMissing bool
+		wantName     string
+		wantError    string
+	}{
+		{name: "single match", address: "10.211.55.8", wantName: "web"},
+		{name: "no match", address: "10.0.0.1", wantError: "No Multipass instance reports the address 10.0.0.1."},
+		{name: "no match allowed", address: "10.0.0.1", allowMissing: true},
+		{name: "several matches", address: "192.168.1.20", wantError: "address 192.168.1.20 is reported by more than one instance: web, web-copy"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			attrs := map[string]tftypes.Value{"ipv4_address": tftypes.NewValue(tftypes.String, tc.address)}
+			if tc.allowMissing {
+				attrs["allow_missing"] = allow
+			}
+			resp := readInstanceDataSourceConfig(t, host, attrs)
+			if tc.wantError != "" {
+				if !resp.Diagnostics.HasError() || resp.Diagnostics.Errors()[0].Detail() != tc.wantError {
+					t.Fatalf("expected %q, got %v", tc.wantError, resp.Diagnostics)
+				}
+				return
+			}
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+			}
+
+			var state instanceDataSourceModel
+			resp.State.Get(ctx, &state)
+			if state.Name.ValueString() != tc.wantName || state.Exists.ValueBool() != (tc.wantName != "") {
+				t.Fatalf("expected instance %q, got name %s, exists %s", tc.wantName, state.Name, state.Exists)
+			}
+			if state.IPv4Address.ValueString() != tc.address {
+				t.Fatalf("expected ipv4_address to be kept, got %s", state.IPv4Address)
+			}
+		})
+	}
+}
+
+func TestInstanceDataSourceValidateConfig(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	for _, tc := range []struct {
+		name  string
+		attrs map[string]tftypes.Value
+		want  string
+	}{
+		{name: "name", attrs: map[string]tftypes.Value{"name": tftypes.NewValue(tftypes.String, "dev")}},
+		{name: "address", attrs: map[string]tftypes.Value{"ipv4_address": tftypes.NewValue(tftypes.String, "10.0.0.1")}},
+		{name: "unknown address", attrs: map[string]tftypes.Value{"ipv4_address": tftypes.NewValue(tftypes.String, tftypes.UnknownValue)}},
+		{name: "neither", want: "Invalid instance lookup"},
+		{
+			name: "both",
+			attrs: map[string]tftypes.Value{
+				"name":         tftypes.NewValue(tftypes.String, "dev"),
+				"ipv4_address": tftypes.NewValue(tftypes.String, "10.0.0.1"),
+			},
+			want: "Invalid instance lookup",
+		},
+		{name: "ipv6 address", attrs: map[string]tftypes.Value{"ipv4_address": tftypes.NewValue(tftypes.String, "fd42::1")}, want: "Invalid IPv4 address"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var resp datasource.ValidateConfigResponse
+			(&instanceDataSource{}).ValidateConfig(ctx, datasource.ValidateConfigRequest{Config: instanceDataSourceConfig(t, tc.attrs)}, &resp)
+			if tc.want == "" {
+				if resp.Diagnostics.HasError() {
+					t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+				}
+				return
+			}
+			if !resp.Diagnostics.HasError() || resp.Diagnostics.Errors()[0].Summary() != tc.want {
+				t.Fatalf("expected %q, got %v", tc.want, resp.Diagnostics)
+			}
+		})
+	}
+}
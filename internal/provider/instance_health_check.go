@@ -0,0 +1,175 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/listvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+const (
+	defaultHealthCheckRetries  = 30
+	defaultHealthCheckInterval = 5 * time.Second
+)
+
+var healthCheckPathRegex = regexp.MustCompile(`^/`)
+
+type healthCheckModel struct {
+	Port     types.Int64  `tfsdk:"port"`
+	Path     types.String `tfsdk:"path"`
+	Command  types.List   `tfsdk:"command"`
+	Retries  types.Int64  `tfsdk:"retries"`
+	Interval types.Int64  `tfsdk:"interval"`
+	OnUpdate types.Bool   `tfsdk:"on_update"`
+}
+
+func healthCheckBlock() schema.SingleNestedBlock {
+	return schema.SingleNestedBlock{
+		Description:         "Probe run inside the instance at the end of create, and of update with on_update. The apply fails with the last probe's output if it never passes. Refresh never runs it.",
+		MarkdownDescription: "Probe run inside the instance at the end of create, and of update with `on_update`. The apply fails with the last probe's output if it never passes. Refresh never runs it.",
+		Attributes: map[string]schema.Attribute{
+			"port": schema.Int64Attribute{
+				Optional:    true,
+				Description: "TCP port that must accept connections on 127.0.0.1 inside the instance. Exactly one of `port` or `command` is required.",
+				Validators: []validator.Int64{
+					int64validator.Between(1, 65535),
+				},
+			},
+			"path": schema.StringAttribute{
+				Optional:    true,
+				Description: "HTTP path on `port` that must answer with a 2xx or 3xx status, checked with curl inside the instance.",
+				Validators: []validator.String{
+					stringvalidator.RegexMatches(healthCheckPathRegex, "must start with /"),
+				},
+			},
+			"command": schema.ListAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "Command and arguments, run without a shell; exit status 0 means healthy. Exactly one of `port` or `command` is required.",
+				Validators: []validator.List{
+					listvalidator.SizeAtLeast(1),
+				},
+			},
+			"retries": schema.Int64Attribute{
+				Optional:    true,
+				Description: "Number of probes before the check fails. Defaults to 30.",
+				Validators: []validator.Int64{
+					int64validator.AtLeast(1),
+				},
+			},
+			"interval": schema.Int64Attribute{
+				Optional:    true,
+				Description: "Seconds between probes. Defaults to 5.",
+				Validators: []validator.Int64{
+					int64validator.AtLeast(1),
+				},
+			},
+			"on_update": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Also run the check at the end of every update.",
+			},
+		},
+	}
+}
+
+// validateHealthCheck reports a health_check block that sets neither or both
+// of port and command, or a path without a port.
+func validateHealthCheck(hc *healthCheckModel, diags *diag.Diagnostics) {
+	if hc == nil || hc.Port.IsUnknown() || hc.Command.IsUnknown() {
+		return
+	}
+	if hc.Port.IsNull() == hc.Command.IsNull() {
+		diags.AddAttributeError(
+			path.Root("health_check"),
+			"Invalid health check",
+			"Set exactly one of port or command in the health_check block.",
+		)
+		return
+	}
+	if !hc.Path.IsNull() && hc.Port.IsNull() {
+		diags.AddAttributeError(
+			path.Root("health_check").AtName("path"),
+			"Invalid health check",
+			"path is checked over HTTP on port, so it requires port to be set.",
+		)
+	}
+}
+
+// healthCheckCommand turns the health_check block into the probe command.
+func healthCheckCommand(ctx context.Context, hc *healthCheckModel) ([]string, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	switch {
+	case !hc.Command.IsNull():
+		var command []string
+		diags.Append(hc.Command.ElementsAs(ctx, &command, false)...)
+		return command, diags
+	case !hc.Path.IsNull():
+		url := fmt.Sprintf("http://127.0.0.1:%d%s", hc.Port.ValueInt64(), hc.Path.ValueString())
+		return []string{"curl", "-fsS", "-o", "/dev/null", url}, diags
+	default:
+		return tcpProbeCommand(hc.Port.ValueInt64()), diags
+	}
+}
+
+// checkHealth runs the health_check probe until it passes or its retries are
+// used up.
+func (r *instanceResource) checkHealth(ctx context.Context, name string, hc *healthCheckModel) diag.Diagnostics {
+	command, diags := healthCheckCommand(ctx, hc)
+	if diags.HasError() {
+		return diags
+	}
+	retries := valueOrDefaultInt(hc.Retries, defaultHealthCheckRetries)
+	interval := defaultHealthCheckInterval
+	if !hc.Interval.IsNull() && !hc.Interval.IsUnknown() {
+		interval = time.Duration(hc.Interval.ValueInt64()) * time.Second
+	}
+
+	tflog.Info(ctx, "Running health check", map[string]any{"command": command, "retries": retries})
+	if err := runHealthCheck(ctx, r.client, name, command, retries, interval); err != nil {
+		diags.AddAttributeError(
+			path.Root("health_check"),
+			"Health check failed",
+			fmt.Sprintf("Instance %q did not pass its health check: %s", name, err),
+		)
+	}
+	return diags
+}
+
+// runHealthCheck runs command up to retries times, interval apart, until it
+// exits with status 0. The error carries the output of the last probe.
+func runHealthCheck(ctx context.Context, client multipasscli.Client, instance string, command []string, retries int, interval time.Duration) error {
+	var last string
+	for attempt := 1; ; attempt++ {
+		result, err := client.ExecOutput(ctx, instance, multipasscli.ExecOptions{Command: command, ReadOnly: true})
+		switch {
+		case err != nil:
+			last = err.Error()
+		case result.ExitCode == 0:
+			return nil
+		default:
+			last = execFailure(result)
+		}
+		tflog.Debug(ctx, "Health check probe failed", map[string]any{"attempt": attempt, "last": last})
+		if attempt >= retries {
+			return fmt.Errorf("%d probes failed, the last one %s", retries, last)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w after %d probes, the last one %s", ctx.Err(), attempt, last)
+		case <-time.After(interval):
+		}
+	}
+}
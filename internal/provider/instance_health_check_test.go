@@ -0,0 +1,135 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+func TestHealthCheckCommand(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	command, _ := types.ListValueFrom(ctx, types.StringType, []string{"systemctl", "is-active", "nginx"})
+	for _, tc := range []struct {
+		name  string
+		model healthCheckModel
+		want  []string
+	}{
+		{
+			name:  "port",
+			model: healthCheckModel{Port: types.Int64Value(8080), Path: types.StringNull(), Command: types.ListNull(types.StringType)},
+			want:  []string{"bash", "-c", "exec 3<>/dev/tcp/127.0.0.1/8080"},
+		},
+		{
+			name:  "http",
+			model: healthCheckModel{Port: types.Int64Value(8080), Path: types.StringValue("/healthz"), Command: types.ListNull(types.StringType)},
+			want:  []string{"curl", "-fsS", "-o", "/dev/null", "http://127.0.0.1:8080/healthz"},
+		},
+		{
+			name:  "command",
+			model: healthCheckModel{Port: types.Int64Null(), Path: types.StringNull(), Command: command},
+			want:  []string{"systemctl", "is-active", "nginx"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, diags := healthCheckCommand(ctx, &tc.model)
+			if diags.HasError() {
+				t.Fatalf("unexpected diagnostics: %v", diags)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Fatalf("unexpected command (-want +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestRunHealthCheck(t *testing.T) {
+	t.Parallel()
+
+	t.Run("passes after retries", func(t *testing.T) {
+		attempts := 0
+		client := &fakeClient{execOutput: func(string, multipasscli.ExecOptions) (multipasscli.ExecResult, error) {
+			attempts++
+			switch attempts {
+			case 1:
+				return multipasscli.ExecResult{}, errors.New("ssh: connection refused")
+			case 2:
+				return multipasscli.ExecResult{ExitCode: 1}, nil
+			}
+			return multipasscli.ExecResult{}, nil
+		}}
+		if err := runHealthCheck(context.Background(), client, "vm", []string{"true"}, 3, time.Millisecond); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if attempts != 3 {
+			t.Fatalf("expected 3 probes, got %d", attempts)
+		}
+	})
+
+	t.Run("fails with the last output", func(t *testing.T) {
+		client := &fakeClient{execOutput: func(string, multipasscli.ExecOptions) (multipasscli.ExecResult, error) {
+			return multipasscli.ExecResult{ExitCode: 22, Stderr: []byte("curl: (22) The requested URL returned error: 503\n")}, nil
+		}}
+		err := runHealthCheck(context.Background(), client, "vm", []string{"curl"}, 2, time.Millisecond)
+		if err == nil || err.Error() != "2 probes failed, the last one exited with status 22: curl: (22) The requested URL returned error: 503" {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := len(client.recorded()); got != 2 {
+			t.Fatalf("expected 2 probes, got %d", got)
+		}
+	})
+}
+
+func TestInstanceCreateHealthCheckFailure(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	client := &fakeClient{execOutput: func(string, multipasscli.ExecOptions) (multipasscli.ExecResult, error) {
+		return multipasscli.ExecResult{ExitCode: 1}, nil
+	}}
+	r := &instanceResource{client: client, commandTimeout: time.Minute}
+	objType := instanceState(t, r, nil).Raw.Type().(tftypes.Object)
+	healthCheckType := objType.AttributeTypes["health_check"].(tftypes.Object)
+	planned := instanceState(t, r, map[string]tftypes.Value{
+		"health_check": tftypes.NewValue(healthCheckType, map[string]tftypes.Value{
+			"port":      tftypes.NewValue(tftypes.Number, 8080),
+			"path":      tftypes.NewValue(tftypes.String, nil),
+			"command":   tftypes.NewValue(healthCheckType.AttributeTypes["command"], nil),
+			"retries":   tftypes.NewValue(tftypes.Number, 1),
+			"interval":  tftypes.NewValue(tftypes.Number, nil),
+			"on_update": tftypes.NewValue(tftypes.Bool, nil),
+		}),
+	})
+	plan := tfsdk.Plan{Schema: planned.Schema, Raw: planned.Raw}
+
+	resp := resource.CreateResponse{State: tfsdk.State{Schema: planned.Schema, Raw: tftypes.NewValue(planned.Raw.Type(), nil)}}
+	r.Create(ctx, resource.CreateRequest{Plan: plan}, &resp)
+	if !resp.Diagnostics.HasError() || resp.Diagnostics.Errors()[0].Summary() != "Health check failed" {
+		t.Fatalf("expected the health check to fail, got %v", resp.Diagnostics)
+	}
+	if !strings.Contains(resp.Diagnostics.Errors()[0].Detail(), "exited with status 1") {
+		t.Fatalf("expected the probe output in the error, got %q", resp.Diagnostics.Errors()[0].Detail())
+	}
+	want := []string{"launch vm", "info vm", "exec-output vm bash -c exec 3<>/dev/tcp/127.0.0.1/8080"}
+	if diff := cmp.Diff(want, client.recorded()); diff != "" {
+		t.Fatalf("unexpected calls (-want +got): %s", diff)
+	}
+
+	// The failed instance stays in state so Terraform taints it.
+	var got instanceResourceModel
+	resp.State.Get(ctx, &got)
+	if got.ID.ValueString() != "vm" {
+		t.Fatalf("expected the instance in state, got id %s", got.ID)
+	}
+}
@@ -0,0 +1,135 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
+)
+
+// mountDrift is a mount recorded in state that multipass reports
+// differently.
+type mountDrift struct {
+	recorded mountConfigModel
+	// reported is nil when multipass no longer lists the mount.
+	reported *models.Mount
+}
+
+// driftedMounts compares the mounts recorded in state with the ones
+// multipass reports, by target. Only mounts in state are considered, so
+// mounts added outside Terraform are left alone. host_path is not compared
+// because multipass reports it resolved, and uid/gid mappings only when
+// they are set, since multipass reports a default mapping otherwise.
+func driftedMounts(recorded []mountConfigModel, reported []models.Mount) []mountDrift {
+	byTarget := make(map[string]models.Mount, len(reported))
+	for _, m := range reported {
+		byTarget[normalizeMountTarget(m.InstancePath)] = m
+	}
+
+	var drift []mountDrift
+	for _, m := range recorded {
+		actual, ok := byTarget[mountTarget(m)]
+		switch {
+		case !ok:
+			drift = append(drift, mountDrift{recorded: m})
+		case actual.ReadOnly != m.ReadOnly.ValueBool(),
+			!sameIDMappings(m.UIDMappings, actual.UIDMappings),
+			!sameIDMappings(m.GIDMappings, actual.GIDMappings):
+			drift = append(drift, mountDrift{recorded: m, reported: &actual})
+		}
+	}
+	return drift
+}
+
+// sameIDMappings reports whether multipass reports the mappings recorded
+// in state, in any order. Unset mappings always match.
+func sameIDMappings(recorded types.List, reported []string) bool {
+	if recorded.IsNull() || recorded.IsUnknown() {
+		return true
+	}
+	want := idMappings(recorded)
+	got := slices.Clone(reported)
+	slices.Sort(want)
+	slices.Sort(got)
+	return slices.Equal(want, got)
+}
+
+// reconcileMounts checks the mounts in state against those multipass
+// reports for the instance. Mounts disappear when the instance restarts
+// while the host directory is unavailable, or when someone runs
+// `multipass umount`. Missing mounts are dropped from state and mounts whose
+// read_only or mappings changed take the reported values, so the next plan
+// shows an update that mounts them again. With auto_remount they are mounted again
+// right away instead, and state is left as it is.
+func (r *instanceResource) reconcileMounts(ctx context.Context, name string, reported []models.Mount, state *instanceResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+	drift := driftedMounts(state.Mounts, reported)
+	if len(drift) == 0 {
+		return diags
+	}
+	targets := make([]string, 0, len(drift))
+	for _, d := range drift {
+		targets = append(targets, d.recorded.InstancePath.ValueString())
+	}
+
+	if state.AutoRemount.ValueBool() {
+		err := r.remount(ctx, name, drift)
+		if err == nil {
+			tflog.Info(ctx, "Remounted drifted mounts", map[string]any{"name": name, "mounts": targets})
+			return diags
+		}
+		diags.AddAttributeWarning(
+			path.Root("mounts"),
+			"Failed to remount",
+			fmt.Sprintf("Instance %q is missing or has changed mounts at %s and remounting them failed: %s. The next apply mounts them again.", name, strings.Join(targets, ", "), err),
+		)
+	}
+
+	tflog.Info(ctx, "Mounts drifted, the next apply mounts them again", map[string]any{"name": name, "mounts": targets})
+	kept := make([]mountConfigModel, 0, len(state.Mounts))
+	changed := make(map[string]*models.Mount, len(drift))
+	for _, d := range drift {
+		changed[mountTarget(d.recorded)] = d.reported
+	}
+	for _, m := range state.Mounts {
+		actual, ok := changed[mountTarget(m)]
+		switch {
+		case !ok:
+			kept = append(kept, m)
+		case actual != nil:
+			m.ReadOnly = types.BoolValue(actual.ReadOnly)
+			if !m.UIDMappings.IsNull() {
+				m.UIDMappings = stringListValue(actual.UIDMappings)
+			}
+			if !m.GIDMappings.IsNull() {
+				m.GIDMappings = stringListValue(actual.GIDMappings)
+			}
+			kept = append(kept, m)
+		}
+	}
+	state.Mounts = kept
+	return diags
+}
+
+// remount mounts drifted mounts again as recorded in state, unmounting the
+// reported mount first where there is one.
+func (r *instanceResource) remount(ctx context.Context, name string, drift []mountDrift) error {
+	for _, d := range drift {
+		if d.reported != nil {
+			if err := r.client.Unmount(ctx, name, models.Mount{InstancePath: d.reported.InstancePath}); err != nil {
+				return err
+			}
+		}
+		if err := r.client.Mount(ctx, name, mountConfigToModel(d.recorded)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
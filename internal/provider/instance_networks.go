@@ -0,0 +1,89 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+// missingNetworks returns the networks recorded in state that no reported
+// extra interface accounts for. Multipass reports the NIC inside the
+// instance, not the host network it is attached to, so networks with a mac
+// are matched by MAC address and the others only by count: when fewer
+// unmatched interfaces remain than networks without a mac, the surplus
+// networks, last by name, are missing.
+func missingNetworks(recorded []networkConfigModel, reported []models.NetworkInterface) []networkConfigModel {
+	unmatched := map[string]int{}
+	extras := 0
+	for _, iface := range reported {
+		if iface.Name == multipasscli.DefaultInterfaceName {
+			continue
+		}
+		unmatched[strings.ToLower(iface.MAC)]++
+		extras++
+	}
+
+	var missing, withoutMAC []networkConfigModel
+	for _, n := range recorded {
+		mac := strings.ToLower(n.Mac.ValueString())
+		if mac == "" {
+			withoutMAC = append(withoutMAC, n)
+			continue
+		}
+		if unmatched[mac] == 0 {
+			missing = append(missing, n)
+			continue
+		}
+		unmatched[mac]--
+		extras--
+	}
+
+	if len(withoutMAC) > extras {
+		sort.Slice(withoutMAC, func(i, j int) bool { return withoutMAC[i].Name.ValueString() < withoutMAC[j].Name.ValueString() })
+		missing = append(missing, withoutMAC[extras:]...)
+	}
+	return missing
+}
+
+// reconcileNetworks checks the networks in state against the interfaces
+// multipass reports for a running instance. A network that is no longer
+// attached, for example because the host network or bridge went away, is
+// dropped from state. networks requires replacement, so the next plan
+// relaunches the instance with it; the warning says so up front.
+func (r *instanceResource) reconcileNetworks(ctx context.Context, instance *models.Instance, state *instanceResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+	missing := missingNetworks(state.Networks, instance.Interfaces)
+	if len(missing) == 0 {
+		return diags
+	}
+
+	names := make([]string, 0, len(missing))
+	dropped := make(map[networkConfigModel]bool, len(missing))
+	for _, n := range missing {
+		names = append(names, n.Name.ValueString())
+		dropped[n] = true
+	}
+	tflog.Info(ctx, "Networks no longer attached, the next plan replaces the instance", map[string]any{"name": instance.Name, "networks": names})
+	diags.AddAttributeWarning(
+		path.Root("networks"),
+		"Network no longer attached",
+		fmt.Sprintf("Instance %q no longer reports an interface for the networks %s. Networks can only be attached at launch, so the next plan replaces the instance.", instance.Name, strings.Join(names, ", ")),
+	)
+
+	kept := make([]networkConfigModel, 0, len(state.Networks))
+	for _, n := range state.Networks {
+		if !dropped[n] {
+			kept = append(kept, n)
+		}
+	}
+	state.Networks = kept
+	return diags
+}
@@ -2,24 +2,32 @@ package provider
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"regexp"
+	"slices"
 	"strings"
 	"time"
 
 	stringvalidator "github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
 	"github.com/hashicorp/terraform-plugin-framework-timeouts/resource/timeouts"
+	"github.com/hashicorp/terraform-plugin-framework-validators/listvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
 	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64planmodifier"
-	"github.com/hashicorp/terraform-plugin-framework/resource/schema/listplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/setplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
 
 	"github.com/todoroff/terraform-provider-multipass/internal/models"
@@ -28,9 +36,12 @@ import (
 
 // Ensure implementation satisfies interfaces.
 var (
-	_ resource.Resource                = (*instanceResource)(nil)
-	_ resource.ResourceWithConfigure   = (*instanceResource)(nil)
-	_ resource.ResourceWithImportState = (*instanceResource)(nil)
+	_ resource.Resource                   = (*instanceResource)(nil)
+	_ resource.ResourceWithConfigure      = (*instanceResource)(nil)
+	_ resource.ResourceWithImportState    = (*instanceResource)(nil)
+	_ resource.ResourceWithModifyPlan     = (*instanceResource)(nil)
+	_ resource.ResourceWithValidateConfig = (*instanceResource)(nil)
+	_ resource.ResourceWithUpgradeState   = (*instanceResource)(nil)
 )
 
 // NewInstanceResource registers the resource with the provider.
@@ -41,7 +52,16 @@ func NewInstanceResource() resource.Resource {
 type instanceResource struct {
 	client         multipasscli.Client
 	defaultImage   string
+	purgeOnDelete  bool
 	commandTimeout time.Duration
+	primaryClaims  *primaryClaims
+	// stateRetries and stateInterval bound how long Read waits for a
+	// transitional state to settle. Zero retries disables the wait.
+	stateRetries  int
+	stateInterval time.Duration
+	// driver is left unknown with suppress_driver_warnings, which turns
+	// off warnDriverLimits.
+	driver driverInfo
 }
 
 func (r *instanceResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
@@ -51,6 +71,7 @@ func (r *instanceResource) Metadata(_ context.Context, req resource.MetadataRequ
 func (r *instanceResource) Schema(ctx context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
 	resp.Schema = schema.Schema{
 		Description: "Manages Canonical Multipass instances.",
+		Version:     1,
 		Attributes: map[string]schema.Attribute{
 			"id": schema.StringAttribute{
 				Computed: true,
@@ -59,9 +80,28 @@ func (r *instanceResource) Schema(ctx context.Context, _ resource.SchemaRequest,
 				},
 			},
 			"name": schema.StringAttribute{
-				Required:            true,
-				Description:         "Instance name.",
-				MarkdownDescription: "Instance name. Must be unique per Multipass host.",
+				Optional:            true,
+				Computed:            true,
+				Description:         "Instance name. Exactly one of name or name_prefix is required.",
+				MarkdownDescription: "Instance name. Must be unique per Multipass host. Exactly one of `name` or `name_prefix` is required; with `name_prefix`, this is the generated name.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+				Validators: []validator.String{
+					stringvalidator.ExactlyOneOf(path.MatchRoot("name_prefix")),
+				},
+			},
+			"name_prefix": schema.StringAttribute{
+				Optional:            true,
+				Description:         "Generate a unique instance name that starts with this prefix, followed by a random suffix. Changing forces recreation.",
+				MarkdownDescription: fmt.Sprintf("Generate a unique instance name that starts with this prefix, followed by %d random hex characters, e.g. to use one configuration in several workspaces. The prefix must start with a letter and only contain letters, digits and hyphens; it is shortened when the name would exceed %d characters. Changing forces recreation.", namePrefixSuffixLength, instanceNameMaxLength),
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplaceIf(namePrefixRequiresReplace, "Changing name_prefix forces recreation.", "Changing `name_prefix` forces recreation."),
+				},
+				Validators: []validator.String{
+					stringvalidator.ExactlyOneOf(path.MatchRoot("name")),
+					stringvalidator.RegexMatches(namePrefixRegex, "must start with a letter and only contain letters, digits and hyphens"),
+				},
 			},
 			"image": schema.StringAttribute{
 				Optional:            true,
@@ -130,21 +170,88 @@ func (r *instanceResource) Schema(ctx context.Context, _ resource.SchemaRequest,
 				Description:         "If true, automatically start the instance after a successful auto-recover when it was soft-deleted outside Terraform.",
 				MarkdownDescription: "If true, automatically start the instance after a successful auto-recover when it was soft-deleted outside Terraform.",
 			},
+			"auto_remount": schema.BoolAttribute{
+				Optional:            true,
+				Description:         "Mount missing or changed mounts again during refresh instead of planning an update that does it.",
+				MarkdownDescription: "Mount missing or changed `mounts` again during refresh instead of planning an update that does it.",
+			},
+			"purge_on_delete": schema.BoolAttribute{
+				Optional:            true,
+				Description:         "Purge the instance on destroy. When false, the instance is only soft-deleted and can be brought back with `multipass recover`. Defaults to the provider's purge_on_delete, which defaults to true.",
+				MarkdownDescription: "Purge the instance on destroy. When `false`, the instance is only soft-deleted and can be brought back with `multipass recover` until it is purged. Defaults to the provider's `purge_on_delete`, which defaults to `true`.",
+			},
+			"destroy_behavior": schema.StringAttribute{
+				Optional:            true,
+				Description:         "What destroy does to the instance: delete (the default), suspend (suspend it and remove it from state) or abandon (remove it from state and leave it alone).",
+				MarkdownDescription: "What destroy, including destroy for replacement, does to the instance. `delete` (the default) deletes it, honouring `purge_on_delete`. `suspend` suspends a running instance and removes it from state. `abandon` removes it from state without touching it. Bring a kept instance back under management with `terraform import`.",
+				Validators: []validator.String{
+					stringvalidator.OneOf(destroyBehaviorDelete, destroyBehaviorSuspend, destroyBehaviorAbandon),
+				},
+			},
+			"delete_protection": schema.BoolAttribute{
+				Optional:            true,
+				Description:         "Refuse to delete the instance, including for replacement. Set it to false and apply before destroying. Defaults to false.",
+				MarkdownDescription: "Refuse to delete the instance, including when a change would replace it. Delete reads the value from state, so set it to `false` and apply before running `terraform destroy`. Defaults to `false`.",
+			},
 			"wait_for_cloud_init": schema.BoolAttribute{
 				Optional:            true,
 				Description:         "Wait for cloud-init to finish after launch before marking the resource as created. Useful when downstream resources depend on packages or configuration applied by cloud-init.",
 				MarkdownDescription: "Wait for cloud-init to finish after launch before marking the resource as created. Useful when downstream resources depend on packages or configuration applied by cloud-init.",
 			},
+			"track_cloud_init": schema.BoolAttribute{
+				Optional:            true,
+				Description:         "Report cloud-init's status in cloud_init_status and cloud_init_errors. Each refresh then runs cloud-init status inside the running instance, which makes refreshes slower.",
+				MarkdownDescription: "Report cloud-init's status in `cloud_init_status` and `cloud_init_errors`. Each refresh then runs `cloud-init status` inside the running instance, which makes refreshes slower.",
+			},
+			"cloud_init_status": schema.StringAttribute{
+				Computed:            true,
+				Description:         "cloud-init status (done, error, running, disabled) when track_cloud_init is set.",
+				MarkdownDescription: "cloud-init status (`done`, `error`, `running`, `disabled`) when `track_cloud_init` is set. Keeps the last known value while the instance is not running.",
+			},
+			"cloud_init_errors": schema.ListAttribute{
+				Computed:            true,
+				ElementType:         types.StringType,
+				Description:         "Errors reported by cloud-init when track_cloud_init is set.",
+				MarkdownDescription: "Errors reported by cloud-init when `track_cloud_init` is set. Keeps the last known value while the instance is not running.",
+			},
 			"ipv4": schema.ListAttribute{
 				Computed:            true,
 				Description:         "Assigned IPv4 addresses.",
-				MarkdownDescription: "Assigned IPv4 addresses as reported by `multipass info`.",
+				MarkdownDescription: "Assigned IPv4 addresses as reported by `multipass info`. IPv6 addresses are reported separately in `ipv6`.",
 				ElementType:         types.StringType,
 			},
+			"ipv6": schema.ListAttribute{
+				Computed:            true,
+				Description:         "Assigned IPv6 addresses.",
+				MarkdownDescription: "Assigned IPv6 addresses as reported by `multipass info` on dual-stack networks.",
+				ElementType:         types.StringType,
+			},
+			"interfaces": schema.ListNestedAttribute{
+				Computed:            true,
+				Description:         "Network interfaces reported by Multipass, including the default NIC and any extra networks.",
+				MarkdownDescription: "Network interfaces reported by `multipass info`, including the default NIC and any extra networks. Empty on Multipass releases that do not report interface details.",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"name": schema.StringAttribute{
+							Computed:    true,
+							Description: "Interface identifier (`default` for the NIC Multipass always creates).",
+						},
+						"mac": schema.StringAttribute{
+							Computed:    true,
+							Description: "MAC address of the interface.",
+						},
+						"addresses": schema.ListAttribute{
+							Computed:    true,
+							ElementType: types.StringType,
+							Description: "Addresses assigned to the interface.",
+						},
+					},
+				},
+			},
 			"state": schema.StringAttribute{
 				Computed:            true,
-				Description:         "Current power state.",
-				MarkdownDescription: "Current power state reported by Multipass.",
+				Description:         "Current power state: Running, Stopped, Suspended, Deleted, or transitionally Starting, Restarting, Suspending, Delayed Shutdown or Unknown.",
+				MarkdownDescription: "Current power state reported by Multipass: `Running`, `Stopped`, `Suspended` or `Deleted`. Refresh waits for the transitional states `Starting`, `Restarting`, `Suspending`, `Delayed Shutdown` and `Unknown` to settle, as set by the provider's `transitional_state_retries`. If they do not, the transitional state is stored, except `Unknown`, which keeps the previous value and produces a warning.",
 			},
 			"release": schema.StringAttribute{
 				Computed:            true,
@@ -168,10 +275,10 @@ func (r *instanceResource) Schema(ctx context.Context, _ resource.SchemaRequest,
 			},
 		},
 		Blocks: map[string]schema.Block{
-			"networks": schema.ListNestedBlock{
-				Description: "Optional networks to attach during launch.",
-				PlanModifiers: []planmodifier.List{
-					listplanmodifier.RequiresReplace(),
+			"networks": schema.SetNestedBlock{
+				Description: "Optional networks to attach during launch, identified by name. Their order does not matter.",
+				PlanModifiers: []planmodifier.Set{
+					setplanmodifier.RequiresReplace(),
 				},
 				NestedObject: schema.NestedBlockObject{
 					Attributes: map[string]schema.Attribute{
@@ -190,8 +297,8 @@ func (r *instanceResource) Schema(ctx context.Context, _ resource.SchemaRequest,
 					},
 				},
 			},
-			"mounts": schema.ListNestedBlock{
-				Description: "Host directory mounts to attach at launch.",
+			"mounts": schema.SetNestedBlock{
+				Description: "Host directory mounts to attach at launch, identified by instance_path. Their order does not matter.",
 				NestedObject: schema.NestedBlockObject{
 					Attributes: map[string]schema.Attribute{
 						"host_path": schema.StringAttribute{
@@ -203,9 +310,26 @@ func (r *instanceResource) Schema(ctx context.Context, _ resource.SchemaRequest,
 						"read_only": schema.BoolAttribute{
 							Optional: true,
 						},
+						"uid_mappings": schema.ListAttribute{
+							Optional:    true,
+							ElementType: types.StringType,
+							Description: "Ordered `host:instance` UID pairs passed as `multipass mount --uid-map`; `default` or `-1` maps to the default instance user. Mounts with mappings are attached with `multipass mount` right after launch.",
+							Validators: []validator.List{
+								listvalidator.ValueStringsAre(stringvalidator.RegexMatches(idMappingRegex, "must be a `host:instance` pair of IDs, where the instance side may be `default` or `-1`")),
+							},
+						},
+						"gid_mappings": schema.ListAttribute{
+							Optional:    true,
+							ElementType: types.StringType,
+							Description: "Ordered `host:instance` GID pairs passed as `multipass mount --gid-map`; `default` or `-1` maps to the default instance group.",
+							Validators: []validator.List{
+								listvalidator.ValueStringsAre(stringvalidator.RegexMatches(idMappingRegex, "must be a `host:instance` pair of IDs, where the instance side may be `default` or `-1`")),
+							},
+						},
 					},
 				},
 			},
+			"health_check": healthCheckBlock(),
 			"timeouts": timeouts.Block(ctx, timeouts.Opts{
 				Create: true,
 				Read:   true,
@@ -224,7 +348,253 @@ func (r *instanceResource) Configure(_ context.Context, req resource.ConfigureRe
 	data := req.ProviderData.(providerData)
 	r.client = data.client
 	r.defaultImage = data.defaultImage
+	r.purgeOnDelete = data.purgeOnDelete
 	r.commandTimeout = data.commandTimeout
+	r.primaryClaims = data.primaryClaims
+	r.stateRetries = data.stateRetries
+	r.stateInterval = data.stateInterval
+	if !data.silenceDriver {
+		r.driver = data.driver
+	}
+}
+
+// ValidateConfig rejects combinations that would only fail at apply time.
+// Conflicts with other resources, such as primary = true next to a
+// multipass_primary, are checked in ModifyPlan since a resource's config
+// does not show the others.
+func (r *instanceResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var cloudInit, cloudInitFile types.String
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("cloud_init"), &cloudInit)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("cloud_init_file"), &cloudInitFile)...)
+	if hasStringValue(cloudInit) && hasStringValue(cloudInitFile) {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("cloud_init"),
+			"Conflicting cloud-init configuration",
+			"Only one of cloud_init or cloud_init_file can be set. Remove one of the attributes and try again.",
+		)
+	}
+
+	var healthCheck *healthCheckModel
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("health_check"), &healthCheck)...)
+	validateHealthCheck(healthCheck, &resp.Diagnostics)
+
+	var destroyBehavior types.String
+	var purgeOnDelete types.Bool
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("destroy_behavior"), &destroyBehavior)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("purge_on_delete"), &purgeOnDelete)...)
+	if hasStringValue(destroyBehavior) && destroyBehavior.ValueString() != destroyBehaviorDelete && !purgeOnDelete.IsNull() {
+		resp.Diagnostics.AddAttributeWarning(
+			path.Root("purge_on_delete"),
+			"purge_on_delete has no effect",
+			fmt.Sprintf("purge_on_delete only applies when destroy_behavior is %q; with %q the instance is never deleted.", destroyBehaviorDelete, destroyBehavior.ValueString()),
+		)
+	}
+
+	seenNetworks := map[string]bool{}
+	for _, elem := range configBlockSet(ctx, req, path.Root("networks"), &resp.Diagnostics) {
+		var n networkConfigModel
+		resp.Diagnostics.Append(elem.As(ctx, &n, basetypes.ObjectAsOptions{})...)
+		if !hasStringValue(n.Name) {
+			continue
+		}
+		if seenNetworks[n.Name.ValueString()] {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("networks").AtSetValue(elem).AtName("name"),
+				"Duplicate network",
+				fmt.Sprintf("Network %q is listed more than once. Multipass attaches each host network once per instance.", n.Name.ValueString()),
+			)
+		}
+		seenNetworks[n.Name.ValueString()] = true
+	}
+
+	seenMounts := map[string]bool{}
+	for _, elem := range configBlockSet(ctx, req, path.Root("mounts"), &resp.Diagnostics) {
+		var m mountConfigModel
+		resp.Diagnostics.Append(elem.As(ctx, &m, basetypes.ObjectAsOptions{})...)
+		if !hasStringValue(m.InstancePath) {
+			continue
+		}
+		target := mountTarget(m)
+		if seenMounts[target] {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("mounts").AtSetValue(elem).AtName("instance_path"),
+				"Duplicate mount target",
+				fmt.Sprintf("%q is the instance_path of more than one mount. Each instance path can only hold one mount.", m.InstancePath.ValueString()),
+			)
+		}
+		seenMounts[target] = true
+	}
+}
+
+// configBlockSet returns the known elements of the set block at p. It
+// returns nothing while the block itself is unknown, e.g. a dynamic block
+// over a value that is only known after apply.
+func configBlockSet(ctx context.Context, req resource.ValidateConfigRequest, p path.Path, diags *diag.Diagnostics) []types.Object {
+	var set types.Set
+	diags.Append(req.Config.GetAttribute(ctx, p, &set)...)
+	if diags.HasError() || set.IsNull() || set.IsUnknown() {
+		return nil
+	}
+	var elems []types.Object
+	for _, elem := range set.Elements() {
+		if obj, ok := elem.(types.Object); ok && !obj.IsUnknown() {
+			elems = append(elems, obj)
+		}
+	}
+	return elems
+}
+
+// importOptionRecover is the import ID suffix that recovers a soft-deleted
+// instance before importing it.
+const importOptionRecover = "recover"
+
+// Values of destroy_behavior.
+const (
+	destroyBehaviorDelete  = "delete"
+	destroyBehaviorSuspend = "suspend"
+	destroyBehaviorAbandon = "abandon"
+)
+
+// instanceFieldsAddedInV1 are the attributes and blocks that are not part of
+// the version 0 schema; the upgrader sets them to null.
+var instanceFieldsAddedInV1 = map[string]bool{
+	"name_prefix":       true,
+	"track_cloud_init":  true,
+	"cloud_init_status": true,
+	"cloud_init_errors": true,
+	"health_check":      true,
+	"delete_protection": true,
+	"destroy_behavior":  true,
+	"auto_remount":      true,
+}
+
+// UpgradeState converts networks and mounts from the lists of version 0 to
+// sets and adds name_prefix. The elements are unchanged, so the stored order
+// is simply dropped.
+func (r *instanceResource) UpgradeState(ctx context.Context) map[int64]resource.StateUpgrader {
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+	prior := schemaResp.Schema
+	prior.Version = 0
+	prior.Attributes = map[string]schema.Attribute{}
+	for name, attribute := range schemaResp.Schema.Attributes {
+		if !instanceFieldsAddedInV1[name] {
+			prior.Attributes[name] = attribute
+		}
+	}
+	prior.Blocks = map[string]schema.Block{}
+	for name, block := range schemaResp.Schema.Blocks {
+		if !instanceFieldsAddedInV1[name] {
+			prior.Blocks[name] = block
+		}
+	}
+	networks := schemaResp.Schema.Blocks["networks"].(schema.SetNestedBlock)
+	prior.Blocks["networks"] = schema.ListNestedBlock{Description: networks.Description, NestedObject: networks.NestedObject}
+	mounts := schemaResp.Schema.Blocks["mounts"].(schema.SetNestedBlock)
+	prior.Blocks["mounts"] = schema.ListNestedBlock{Description: mounts.Description, NestedObject: mounts.NestedObject}
+
+	return map[int64]resource.StateUpgrader{
+		0: {
+			PriorSchema: &prior,
+			StateUpgrader: func(ctx context.Context, req resource.UpgradeStateRequest, resp *resource.UpgradeStateResponse) {
+				var values map[string]tftypes.Value
+				if err := req.State.Raw.As(&values); err != nil {
+					resp.Diagnostics.AddError("Unable to upgrade instance state", err.Error())
+					return
+				}
+				current := resp.State.Schema.Type().TerraformType(ctx).(tftypes.Object)
+				for _, block := range []string{"networks", "mounts"} {
+					var elems []tftypes.Value
+					if err := values[block].As(&elems); err != nil {
+						resp.Diagnostics.AddError("Unable to upgrade instance state", err.Error())
+						return
+					}
+					if values[block].IsNull() {
+						values[block] = tftypes.NewValue(current.AttributeTypes[block], nil)
+						continue
+					}
+					values[block] = tftypes.NewValue(current.AttributeTypes[block], elems)
+				}
+				for name := range instanceFieldsAddedInV1 {
+					values[name] = tftypes.NewValue(current.AttributeTypes[name], nil)
+				}
+				resp.State.Raw = tftypes.NewValue(current, values)
+			},
+		},
+	}
+}
+
+// ModifyPlan rejects primary = true alongside a multipass_setting for
+// client.primary-name, and warns when a replacement would soft-delete the
+// instance: the deleted instance keeps its name, so launching the
+// replacement fails until it is purged.
+func (r *instanceResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+	r.claimPrimary(ctx, req, resp)
+	r.warnDriverLimits(ctx, req, resp)
+	if resp.Diagnostics.HasError() || req.State.Raw.IsNull() || len(resp.RequiresReplace) == 0 {
+		return
+	}
+
+	var state instanceResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if behavior := state.DestroyBehavior.ValueString(); behavior == destroyBehaviorSuspend || behavior == destroyBehaviorAbandon {
+		resp.Diagnostics.AddWarning(
+			"Replacement will keep the old instance",
+			fmt.Sprintf("destroy_behavior is %q, so replacing %q leaves the old instance in place under the same name, and launching the replacement will fail. "+
+				"Set destroy_behavior = \"delete\" and apply before replacing it.", behavior, state.Name.ValueString()),
+		)
+		return
+	}
+	if r.resolvePurgeOnDelete(state.PurgeOnDelete) {
+		return
+	}
+	resp.Diagnostics.AddWarning(
+		"Replacement will not purge the instance",
+		fmt.Sprintf("purge_on_delete is false, so replacing %q only soft-deletes it and the deleted instance keeps its name. "+
+			"Launching the replacement will fail until it is removed with `multipass purge`. Set purge_on_delete = true before replacing it.", state.Name.ValueString()),
+	)
+}
+
+// claimPrimary records an instance planned with primary = true. Two
+// instances may both set it, as before, but a multipass_setting for
+// client.primary-name would keep undoing it. Alongside a multipass_primary
+// the instance flag is only redundant, so that is a warning.
+func (r *instanceResource) claimPrimary(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	var name types.String
+	var primary types.Bool
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("name"), &name)...)
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("primary"), &primary)...)
+	if resp.Diagnostics.HasError() || name.IsUnknown() || !primary.ValueBool() {
+		return
+	}
+
+	var designated []primaryClaim
+	for _, other := range r.primaryClaims.claim(primaryClaim{resourceType: "multipass_instance", name: name.ValueString()}, req.State.Raw.IsNull()) {
+		switch other.resourceType {
+		case "multipass_setting":
+			resp.Diagnostics.AddAttributeError(
+				path.Root("primary"),
+				"Conflicting primary instance settings",
+				fmt.Sprintf("Instance %q sets primary = true, but %s also manages client.primary-name. Use only one of them.", name.ValueString(), other),
+			)
+			return
+		case "multipass_primary":
+			designated = append(designated, other)
+		}
+	}
+	if len(designated) > 0 {
+		resp.Diagnostics.AddAttributeWarning(
+			path.Root("primary"),
+			"Primary instance also set by multipass_primary",
+			fmt.Sprintf("Instance %q sets primary = true, but the primary instance is managed by %s. Remove primary from the instance.", name.ValueString(), describePrimaryClaims(designated)),
+		)
+	}
 }
 
 func (r *instanceResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
@@ -239,24 +609,27 @@ func (r *instanceResource) Create(ctx context.Context, req resource.CreateReques
 		return
 	}
 
+	if !hasStringValue(plan.Name) {
+		name, err := generateInstanceName(plan.NamePrefix.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to generate instance name", err.Error())
+			return
+		}
+		plan.Name = types.StringValue(name)
+	}
+
+	ctx, done := startOperation(ctx, "multipass_instance", "create", plan.Name.ValueString())
+	defer done(&resp.Diagnostics)
+
 	createTimeout, diags := plan.Timeouts.Create(ctx, r.commandTimeout)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	if hasStringValue(plan.CloudInitFile) && hasStringValue(plan.CloudInit) {
-		resp.Diagnostics.AddAttributeError(
-			path.Root("cloud_init"),
-			"Conflicting cloud-init configuration",
-			"Only one of cloud_init or cloud_init_file can be set. Remove one of the attributes and try again.",
-		)
-		return
-	}
-
 	opts := models.LaunchOptions{
 		Name:            plan.Name.ValueString(),
-		Image:           r.resolveImage(plan.Image),
+		Image:           resolveImage(plan.Image, r.defaultImage),
 		CPUs:            valueOrDefaultInt(plan.CPUs, 1),
 		Memory:          valueOrDefaultString(plan.Memory, "1G"),
 		Disk:            valueOrDefaultString(plan.Disk, "5G"),
@@ -308,7 +681,7 @@ func (r *instanceResource) Create(ctx context.Context, req resource.CreateReques
 
 	if plan.WaitForCloudInit.ValueBool() {
 		tflog.Info(ctx, "Waiting for cloud-init to finish", map[string]any{"name": opts.Name})
-		if err := r.waitForCloudInit(createCtx, opts.Name); err != nil {
+		if err := waitForCloudInit(createCtx, r.client, opts.Name); err != nil {
 			resp.Diagnostics.AddWarning("cloud-init wait failed", err.Error())
 		}
 	}
@@ -324,7 +697,26 @@ func (r *instanceResource) Create(ctx context.Context, req resource.CreateReques
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	resp.Diagnostics.Append(r.refreshCloudInit(ctx, &plan, nil)...)
 	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+
+	// `multipass launch --mount` takes no ID mappings, so those mounts
+	// follow the launch. Like the health check below, a failure taints the
+	// instance instead of leaking it.
+	if !resp.Diagnostics.HasError() {
+		for _, m := range mountsWithMappings(plan.Mounts) {
+			if err := r.client.Mount(ctx, opts.Name, m); err != nil {
+				resp.Diagnostics.AddAttributeError(path.Root("mounts"), "Failed to mount directory", err.Error())
+				return
+			}
+		}
+	}
+
+	// The instance is in state before the check runs, so a failure taints
+	// it instead of leaking it.
+	if plan.HealthCheck != nil && !resp.Diagnostics.HasError() {
+		resp.Diagnostics.Append(r.checkHealth(ctx, opts.Name, plan.HealthCheck)...)
+	}
 }
 
 func (r *instanceResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
@@ -339,6 +731,9 @@ func (r *instanceResource) Read(ctx context.Context, req resource.ReadRequest, r
 		return
 	}
 
+	ctx, done := startOperation(ctx, "multipass_instance", "read", state.Name.ValueString())
+	defer done(&resp.Diagnostics)
+
 	readTimeout, diags := state.Timeouts.Read(ctx, r.commandTimeout)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
@@ -349,6 +744,9 @@ func (r *instanceResource) Read(ctx context.Context, req resource.ReadRequest, r
 
 	name := state.Name.ValueString()
 	instance, err := r.client.GetInstance(ctx, name)
+	// detailed is false when the instance comes from multipass list, which
+	// does not report mounts.
+	detailed := true
 
 	// If the instance is missing and auto_recover is enabled, attempt a recover.
 	if err == multipasscli.ErrNotFound && state.AutoRecover.ValueBool() {
@@ -379,13 +777,16 @@ func (r *instanceResource) Read(ctx context.Context, req resource.ReadRequest, r
 		// booting instances.  Fall back to multipass list which only
 		// queries the daemon, same as refreshState does.
 		tflog.Warn(ctx, "multipass info failed in Read, falling back to list", map[string]any{"name": name, "error": err.Error()})
-		instance, err = r.getInstanceFromList(ctx, name)
+		instance, err = findListedInstance(ctx, r.client, name)
 		if err != nil {
 			resp.Diagnostics.AddError("Failed to read instance", err.Error())
 			return
 		}
+		detailed = false
 	}
 
+	instance = r.settleState(ctx, name, instance)
+
 	// Multipass keeps "soft-deleted" instances around with a Deleted state that can be
 	// recovered via `multipass recover`. If auto_recover is enabled, transparently
 	// recover such instances so Terraform can continue managing them.
@@ -416,10 +817,36 @@ func (r *instanceResource) Read(ctx context.Context, req resource.ReadRequest, r
 
 	// Ensure id is always set — important after import where only name is populated.
 	state.ID = types.StringValue(name)
+	priorState := state.State
 	resp.Diagnostics.Append(applyInstanceToModel(ctx, instance, &state)...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	if strings.EqualFold(instance.State, "Unknown") {
+		detail := fmt.Sprintf("Multipass reports instance %q as Unknown", name)
+		if r.stateRetries > 0 {
+			detail += fmt.Sprintf(" after %d retries", r.stateRetries)
+		}
+		// Unknown says nothing about the instance, so keep the last state
+		// it reported instead of showing it as drift.
+		if !priorState.IsNull() && !priorState.IsUnknown() && priorState.ValueString() != "" {
+			state.State = priorState
+			detail += fmt.Sprintf(", so its previous state %s was kept", priorState.ValueString())
+		}
+		resp.Diagnostics.AddAttributeWarning(
+			path.Root("state"),
+			"Instance state unknown",
+			detail+". Check the instance with `multipass info "+name+"`.",
+		)
+	}
+	if detailed && !strings.EqualFold(instance.State, "Deleted") && !strings.EqualFold(instance.State, "Unknown") {
+		resp.Diagnostics.Append(r.reconcileMounts(ctx, name, instance.Mounts, &state)...)
+	}
+	// Stopped instances report no extra interfaces.
+	if detailed && instance.AllInterfaces && strings.EqualFold(instance.State, "Running") {
+		resp.Diagnostics.Append(r.reconcileNetworks(ctx, instance, &state)...)
+	}
+	resp.Diagnostics.Append(r.refreshCloudInit(ctx, &state, &state)...)
 	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
 }
 
@@ -437,6 +864,9 @@ func (r *instanceResource) Update(ctx context.Context, req resource.UpdateReques
 		return
 	}
 
+	ctx, done := startOperation(ctx, "multipass_instance", "update", plan.Name.ValueString())
+	defer done(&resp.Diagnostics)
+
 	updateTimeout, diags := plan.Timeouts.Update(ctx, r.commandTimeout)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
@@ -445,15 +875,6 @@ func (r *instanceResource) Update(ctx context.Context, req resource.UpdateReques
 	ctx, cancel := context.WithTimeout(ctx, updateTimeout)
 	defer cancel()
 
-	if hasStringValue(plan.CloudInitFile) && hasStringValue(plan.CloudInit) {
-		resp.Diagnostics.AddAttributeError(
-			path.Root("cloud_init"),
-			"Conflicting cloud-init configuration",
-			"Only one of cloud_init or cloud_init_file can be set. Remove one of the attributes and try again.",
-		)
-		return
-	}
-
 	if plan.Primary.ValueBool() && !state.Primary.ValueBool() {
 		if err := r.client.SetPrimary(ctx, plan.Name.ValueString()); err != nil {
 			resp.Diagnostics.AddError("Failed to set primary", err.Error())
@@ -484,7 +905,12 @@ func (r *instanceResource) Update(ctx context.Context, req resource.UpdateReques
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	resp.Diagnostics.Append(r.refreshCloudInit(ctx, &plan, &state)...)
 	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+
+	if plan.HealthCheck != nil && plan.HealthCheck.OnUpdate.ValueBool() && !resp.Diagnostics.HasError() {
+		resp.Diagnostics.Append(r.checkHealth(ctx, plan.Name.ValueString(), plan.HealthCheck)...)
+	}
 }
 
 func (r *instanceResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
@@ -499,6 +925,20 @@ func (r *instanceResource) Delete(ctx context.Context, req resource.DeleteReques
 		return
 	}
 
+	// Checked before anything touches the instance. The value comes from
+	// state, so turning protection off takes an apply of its own.
+	if state.DeleteProtection.ValueBool() {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("delete_protection"),
+			"Instance is delete-protected",
+			fmt.Sprintf("Instance %q has delete_protection enabled. Set delete_protection = false and apply, then destroy or replace it.", state.Name.ValueString()),
+		)
+		return
+	}
+
+	ctx, done := startOperation(ctx, "multipass_instance", "delete", state.Name.ValueString())
+	defer done(&resp.Diagnostics)
+
 	deleteTimeout, diags := state.Timeouts.Delete(ctx, r.commandTimeout)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
@@ -508,7 +948,16 @@ func (r *instanceResource) Delete(ctx context.Context, req resource.DeleteReques
 	defer cancel()
 
 	name := state.Name.ValueString()
-	if err := r.client.DeleteInstance(ctx, name, true); err != nil {
+	switch state.DestroyBehavior.ValueString() {
+	case destroyBehaviorAbandon:
+		tflog.Info(ctx, "Removing instance from state without deleting it", map[string]any{"name": name})
+		return
+	case destroyBehaviorSuspend:
+		resp.Diagnostics.Append(r.suspendForDestroy(ctx, name)...)
+		return
+	}
+
+	if err := r.client.DeleteInstance(ctx, name, r.resolvePurgeOnDelete(state.PurgeOnDelete)); err != nil {
 		if err == multipasscli.ErrNotFound {
 			return
 		}
@@ -516,8 +965,69 @@ func (r *instanceResource) Delete(ctx context.Context, req resource.DeleteReques
 	}
 }
 
+// suspendForDestroy suspends a running instance that destroy_behavior =
+// "suspend" keeps. Instances that are not running, or already gone, are
+// left as they are, since Multipass only suspends running ones.
+func (r *instanceResource) suspendForDestroy(ctx context.Context, name string) diag.Diagnostics {
+	var diags diag.Diagnostics
+	instances, err := r.client.ListInstances(ctx, true)
+	if err != nil {
+		diags.AddError("Failed to read instance before suspending it", err.Error())
+		return diags
+	}
+	var instance *models.Instance
+	for i := range instances {
+		if instances[i].Name == name {
+			instance = &instances[i]
+		}
+	}
+	if instance == nil {
+		return diags
+	}
+	if !strings.EqualFold(instance.State, "Running") {
+		tflog.Info(ctx, "Instance is not running, removing it from state as it is", map[string]any{"name": name, "state": instance.State})
+		return diags
+	}
+	if err := r.client.SuspendInstance(ctx, name); err != nil {
+		diags.AddError("Failed to suspend instance", err.Error())
+	}
+	return diags
+}
+
+// ImportState takes the instance name, or <name>:recover to recover a
+// soft-deleted instance first. Instance names cannot contain a colon, so the
+// suffix is unambiguous. Importing a soft-deleted instance by plain name
+// fails and suggests the suffix.
 func (r *instanceResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
-	resource.ImportStatePassthroughID(ctx, path.Root("name"), req, resp)
+	name, option, hasOption := strings.Cut(req.ID, ":")
+	if name == "" || (hasOption && option != importOptionRecover) {
+		resp.Diagnostics.AddError(
+			"Invalid import ID",
+			fmt.Sprintf("Expected <name> or <name>:%s, got %q.", importOptionRecover, req.ID),
+		)
+		return
+	}
+
+	if r.client != nil {
+		// A failed lookup is left to the Read that follows the import.
+		if instance, err := findListedInstance(ctx, r.client, name); err == nil && strings.EqualFold(instance.State, "Deleted") {
+			if !hasOption {
+				resp.Diagnostics.AddError(
+					"Instance is deleted",
+					fmt.Sprintf("Instance %q is soft-deleted. Import it with the ID %s:%s to recover it first, or run `multipass recover %s` and import it again.",
+						name, name, importOptionRecover, name),
+				)
+				return
+			}
+			tflog.Info(ctx, "Recovering soft-deleted instance for import", map[string]any{"name": name})
+			if err := r.client.RecoverInstance(ctx, name); err != nil {
+				resp.Diagnostics.AddError("Failed to recover instance", err.Error())
+				return
+			}
+		}
+	}
+
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("name"), name)...)
 }
 
 func (r *instanceResource) refreshState(ctx context.Context, name string, model *instanceResourceModel) diag.Diagnostics {
@@ -529,7 +1039,7 @@ func (r *instanceResource) refreshState(ctx context.Context, name string, model
 		// (name, state, ipv4, release) while info-only fields (load,
 		// cpu_count, memory_bytes, etc.) remain empty until the next refresh.
 		tflog.Warn(ctx, "multipass info failed, falling back to list", map[string]any{"name": name, "error": err.Error()})
-		instance, err = r.getInstanceFromList(ctx, name)
+		instance, err = findListedInstance(ctx, r.client, name)
 		if err != nil {
 			diags.AddError("Failed to refresh instance state", err.Error())
 			return diags
@@ -548,9 +1058,9 @@ func (r *instanceResource) refreshState(ctx context.Context, name string, model
 	return diags
 }
 
-// getInstanceFromList finds an instance by name using ListInstances (no SSH).
-func (r *instanceResource) getInstanceFromList(ctx context.Context, name string) (*models.Instance, error) {
-	instances, err := r.client.ListInstances(ctx, true)
+// findListedInstance finds an instance by name using ListInstances (no SSH).
+func findListedInstance(ctx context.Context, client multipasscli.Client, name string) (*models.Instance, error) {
+	instances, err := client.ListInstances(ctx, true)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list instances: %w", err)
 	}
@@ -579,13 +1089,101 @@ func applyInstanceToModel(ctx context.Context, instance *models.Instance, model
 		model.IPv4 = types.ListNull(types.StringType)
 	}
 
+	if len(instance.IPv6) > 0 {
+		list, diag := types.ListValueFrom(ctx, types.StringType, instance.IPv6)
+		diags.Append(diag...)
+		model.IPv6 = list
+	} else {
+		model.IPv6 = types.ListNull(types.StringType)
+	}
+
+	interfaces, diag := flattenInterfaces(ctx, instance.Interfaces)
+	diags.Append(diag...)
+	model.Interfaces = interfaces
+
 	return diags
 }
 
+var interfaceAttrTypes = map[string]attr.Type{
+	"name":      types.StringType,
+	"mac":       types.StringType,
+	"addresses": types.ListType{ElemType: types.StringType},
+}
+
+type interfaceModel struct {
+	Name      types.String `tfsdk:"name"`
+	MAC       types.String `tfsdk:"mac"`
+	Addresses types.List   `tfsdk:"addresses"`
+}
+
+func flattenInterfaces(ctx context.Context, interfaces []models.NetworkInterface) (types.List, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	elemType := types.ObjectType{AttrTypes: interfaceAttrTypes}
+
+	items := make([]interfaceModel, 0, len(interfaces))
+	for _, iface := range interfaces {
+		addrs, d := types.ListValueFrom(ctx, types.StringType, iface.Addresses)
+		diags.Append(d...)
+		items = append(items, interfaceModel{
+			Name:      types.StringValue(iface.Name),
+			MAC:       types.StringValue(iface.MAC),
+			Addresses: addrs,
+		})
+	}
+
+	list, d := types.ListValueFrom(ctx, elemType, items)
+	diags.Append(d...)
+	return list, diags
+}
+
 // Helpers
 
 var memoryRegex = regexp.MustCompile(`^[0-9]+(K|M|G|T)$`)
 
+var namePrefixRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9-]*$`)
+
+var idMappingRegex = regexp.MustCompile(`^[0-9]+:([0-9]+|-1|default)$`)
+
+const (
+	// instanceNameMaxLength is the longest instance name Multipass
+	// accepts, that of a hostname label.
+	instanceNameMaxLength = 63
+	// namePrefixSuffixLength is the number of hex characters appended to
+	// name_prefix.
+	namePrefixSuffixLength = 8
+)
+
+// generateInstanceName appends a random hex suffix to prefix, shortening
+// the prefix so the name stays within instanceNameMaxLength. The suffix
+// keeps the name from ending in a hyphen.
+func generateInstanceName(prefix string) (string, error) {
+	suffix := make([]byte, namePrefixSuffixLength/2)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return trimNamePrefix(prefix) + hex.EncodeToString(suffix), nil
+}
+
+// trimNamePrefix shortens prefix to leave room for the random suffix.
+func trimNamePrefix(prefix string) string {
+	if limit := instanceNameMaxLength - namePrefixSuffixLength; len(prefix) > limit {
+		return prefix[:limit]
+	}
+	return prefix
+}
+
+// namePrefixRequiresReplace keeps an imported instance, whose state has no
+// name_prefix, when its name already starts with the configured prefix.
+func namePrefixRequiresReplace(ctx context.Context, req planmodifier.StringRequest, resp *stringplanmodifier.RequiresReplaceIfFuncResponse) {
+	if !req.StateValue.IsNull() || req.PlanValue.IsNull() || req.PlanValue.IsUnknown() {
+		resp.RequiresReplace = true
+		return
+	}
+	var name types.String
+	resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root("name"), &name)...)
+	resp.RequiresReplace = !strings.HasPrefix(name.ValueString(), trimNamePrefix(req.PlanValue.ValueString()))
+}
+
 type networkConfigModel struct {
 	Name types.String `tfsdk:"name"`
 	Mode types.String `tfsdk:"mode"`
@@ -596,11 +1194,14 @@ type mountConfigModel struct {
 	HostPath     types.String `tfsdk:"host_path"`
 	InstancePath types.String `tfsdk:"instance_path"`
 	ReadOnly     types.Bool   `tfsdk:"read_only"`
+	UIDMappings  types.List   `tfsdk:"uid_mappings"`
+	GIDMappings  types.List   `tfsdk:"gid_mappings"`
 }
 
 type instanceResourceModel struct {
 	ID                 types.String         `tfsdk:"id"`
 	Name               types.String         `tfsdk:"name"`
+	NamePrefix         types.String         `tfsdk:"name_prefix"`
 	Image              types.String         `tfsdk:"image"`
 	CPUs               types.Int64          `tfsdk:"cpus"`
 	Memory             types.String         `tfsdk:"memory"`
@@ -610,11 +1211,21 @@ type instanceResourceModel struct {
 	Primary            types.Bool           `tfsdk:"primary"`
 	AutoRecover        types.Bool           `tfsdk:"auto_recover"`
 	AutoStartOnRecover types.Bool           `tfsdk:"auto_start_on_recover"`
+	AutoRemount        types.Bool           `tfsdk:"auto_remount"`
+	PurgeOnDelete      types.Bool           `tfsdk:"purge_on_delete"`
+	DeleteProtection   types.Bool           `tfsdk:"delete_protection"`
+	DestroyBehavior    types.String         `tfsdk:"destroy_behavior"`
 	WaitForCloudInit   types.Bool           `tfsdk:"wait_for_cloud_init"`
+	TrackCloudInit     types.Bool           `tfsdk:"track_cloud_init"`
+	CloudInitStatus    types.String         `tfsdk:"cloud_init_status"`
+	CloudInitErrors    types.List           `tfsdk:"cloud_init_errors"`
 	Networks           []networkConfigModel `tfsdk:"networks"`
 	Mounts             []mountConfigModel   `tfsdk:"mounts"`
+	HealthCheck        *healthCheckModel    `tfsdk:"health_check"`
 	Timeouts           timeouts.Value       `tfsdk:"timeouts"`
 	IPv4               types.List           `tfsdk:"ipv4"`
+	IPv6               types.List           `tfsdk:"ipv6"`
+	Interfaces         types.List           `tfsdk:"interfaces"`
 	State              types.String         `tfsdk:"state"`
 	Release            types.String         `tfsdk:"release"`
 	ImageRelease       types.String         `tfsdk:"image_release"`
@@ -622,12 +1233,23 @@ type instanceResourceModel struct {
 	LastUpdated        types.String         `tfsdk:"last_updated"`
 }
 
-func (r *instanceResource) resolveImage(image types.String) string {
+// resolvePurgeOnDelete returns the resource's purge_on_delete, falling back
+// to the provider default.
+func (r *instanceResource) resolvePurgeOnDelete(value types.Bool) bool {
+	if value.IsNull() || value.IsUnknown() {
+		return r.purgeOnDelete
+	}
+	return value.ValueBool()
+}
+
+// resolveImage returns image, falling back to the provider's default_image
+// and then to "lts".
+func resolveImage(image types.String, defaultImage string) string {
 	if !image.IsNull() && !image.IsUnknown() && image.ValueString() != "" {
 		return image.ValueString()
 	}
-	if r.defaultImage != "" {
-		return r.defaultImage
+	if defaultImage != "" {
+		return defaultImage
 	}
 	return "lts"
 }
@@ -647,17 +1269,29 @@ func expandNetworkAttachments(configs []networkConfigModel) []models.NetworkAtta
 	return result
 }
 
+// expandMounts returns the mounts to pass to `multipass launch --mount`.
+// The flag takes no ID mappings, so mounts with mappings are left to
+// mountsWithMappings.
 func expandMounts(configs []mountConfigModel) []models.Mount {
 	result := make([]models.Mount, 0, len(configs))
 	for _, m := range configs {
-		if m.HostPath.ValueString() == "" || m.InstancePath.ValueString() == "" {
+		if m.HostPath.ValueString() == "" || m.InstancePath.ValueString() == "" || hasIDMappings(m) {
 			continue
 		}
-		result = append(result, models.Mount{
-			HostPath:     m.HostPath.ValueString(),
-			InstancePath: m.InstancePath.ValueString(),
-			ReadOnly:     m.ReadOnly.ValueBool(),
-		})
+		result = append(result, mountConfigToModel(m))
+	}
+	return result
+}
+
+// mountsWithMappings returns the mounts expandMounts leaves out, which are
+// mounted after launch.
+func mountsWithMappings(configs []mountConfigModel) []models.Mount {
+	var result []models.Mount
+	for _, m := range configs {
+		if m.HostPath.ValueString() == "" || m.InstancePath.ValueString() == "" || !hasIDMappings(m) {
+			continue
+		}
+		result = append(result, mountConfigToModel(m))
 	}
 	return result
 }
@@ -667,9 +1301,33 @@ func mountConfigToModel(m mountConfigModel) models.Mount {
 		HostPath:     m.HostPath.ValueString(),
 		InstancePath: m.InstancePath.ValueString(),
 		ReadOnly:     m.ReadOnly.ValueBool(),
+		UIDMappings:  idMappings(m.UIDMappings),
+		GIDMappings:  idMappings(m.GIDMappings),
+	}
+}
+
+// idMappings returns the normalized pairs of a uid_mappings or
+// gid_mappings list, nil when it is null or unknown.
+func idMappings(list types.List) []string {
+	if list.IsNull() || list.IsUnknown() {
+		return nil
+	}
+	values := make([]string, 0, len(list.Elements()))
+	for _, elem := range list.Elements() {
+		if s, ok := elem.(types.String); ok {
+			values = append(values, s.ValueString())
+		}
 	}
+	return multipasscli.NormalizeIDMappings(values)
 }
 
+func hasIDMappings(m mountConfigModel) bool {
+	return len(idMappings(m.UIDMappings)) > 0 || len(idMappings(m.GIDMappings)) > 0
+}
+
+// diffMounts compares mounts by instance_path, their identity. A mount
+// whose host_path, read_only or ID mappings changed is removed and added
+// again.
 func diffMounts(plan, state []mountConfigModel) (toAdd, toRemove []mountConfigModel) {
 	planMap := mountConfigMap(plan)
 	stateMap := mountConfigMap(state)
@@ -680,7 +1338,10 @@ func diffMounts(plan, state []mountConfigModel) (toAdd, toRemove []mountConfigMo
 			toRemove = append(toRemove, current)
 			continue
 		}
-		if current.ReadOnly.ValueBool() != desired.ReadOnly.ValueBool() {
+		if current.HostPath.ValueString() != desired.HostPath.ValueString() ||
+			current.ReadOnly.ValueBool() != desired.ReadOnly.ValueBool() ||
+			!slices.Equal(idMappings(current.UIDMappings), idMappings(desired.UIDMappings)) ||
+			!slices.Equal(idMappings(current.GIDMappings), idMappings(desired.GIDMappings)) {
 			toRemove = append(toRemove, current)
 			toAdd = append(toAdd, desired)
 		}
@@ -698,17 +1359,29 @@ func diffMounts(plan, state []mountConfigModel) (toAdd, toRemove []mountConfigMo
 func mountConfigMap(configs []mountConfigModel) map[string]mountConfigModel {
 	result := make(map[string]mountConfigModel, len(configs))
 	for _, c := range configs {
-		host := c.HostPath.ValueString()
-		instance := c.InstancePath.ValueString()
-		if host == "" || instance == "" {
+		if c.HostPath.ValueString() == "" || c.InstancePath.ValueString() == "" {
 			continue
 		}
-		key := host + "|" + instance
-		result[key] = c
+		result[mountTarget(c)] = c
 	}
 	return result
 }
 
+// mountTarget is the identity of a mount: its instance path without a
+// trailing slash.
+func mountTarget(m mountConfigModel) string {
+	return normalizeMountTarget(m.InstancePath.ValueString())
+}
+
+// normalizeMountTarget strips the trailing slash from an instance path.
+func normalizeMountTarget(instancePath string) string {
+	target := strings.TrimRight(instancePath, "/")
+	if target == "" {
+		return "/"
+	}
+	return target
+}
+
 func valueOrEmpty(v types.String) string {
 	if v.IsNull() || v.IsUnknown() {
 		return ""
@@ -769,10 +1442,66 @@ func (r *instanceResource) waitForInstanceAfterTimeout(ctx context.Context, name
 
 // waitForCloudInit runs `cloud-init status --wait` inside the instance,
 // which blocks until cloud-init reaches a terminal state (done/error/disabled).
-func (r *instanceResource) waitForCloudInit(ctx context.Context, name string) error {
-	if err := r.client.Exec(ctx, name, []string{"cloud-init", "status", "--wait"}); err != nil {
+func waitForCloudInit(ctx context.Context, client multipasscli.Client, name string) error {
+	if err := client.Exec(ctx, name, []string{"cloud-init", "status", "--wait"}); err != nil {
 		return fmt.Errorf("cloud-init did not finish successfully on instance %q: %w. "+
 			"Check logs with: multipass exec %s -- cat /var/log/cloud-init-output.log", name, err, name)
 	}
 	return nil
 }
+
+// refreshCloudInit sets cloud_init_status and cloud_init_errors when
+// track_cloud_init is enabled and the instance in model is running. Otherwise
+// they keep their values from prior, which is nil on create, or are cleared
+// when tracking is off.
+func (r *instanceResource) refreshCloudInit(ctx context.Context, model, prior *instanceResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+	track := model.TrackCloudInit.ValueBool()
+	status, errs := types.StringNull(), types.ListNull(types.StringType)
+	if track && prior != nil && !prior.CloudInitStatus.IsUnknown() && !prior.CloudInitErrors.IsUnknown() {
+		status, errs = prior.CloudInitStatus, prior.CloudInitErrors
+	}
+	model.CloudInitStatus, model.CloudInitErrors = status, errs
+	if !track || !strings.EqualFold(model.State.ValueString(), "Running") {
+		return diags
+	}
+
+	name := model.Name.ValueString()
+	result, err := queryCloudInit(ctx, r.client, name)
+	if err != nil {
+		diags.AddWarning("Failed to read cloud-init status",
+			fmt.Sprintf("Could not read the cloud-init status of instance %q, keeping the last known value: %s", name, err))
+		return diags
+	}
+	errs, d := types.ListValueFrom(ctx, types.StringType, result.Errors)
+	diags.Append(d...)
+	model.CloudInitStatus = types.StringValue(result.Status)
+	model.CloudInitErrors = errs
+	return diags
+}
+
+// cloudInitStatus is the part of `cloud-init status --format json` that the
+// provider reports.
+type cloudInitStatus struct {
+	Status string   `json:"status"`
+	Errors []string `json:"errors"`
+}
+
+// queryCloudInit runs `cloud-init status --format json` inside the instance.
+// It uses ExecOutput because cloud-init exits non-zero when it reports an
+// error, and ExecCapture would drop the JSON in exactly that case.
+func queryCloudInit(ctx context.Context, client multipasscli.Client, name string) (cloudInitStatus, error) {
+	result, err := client.ExecOutput(ctx, name, multipasscli.ExecOptions{Command: []string{"cloud-init", "status", "--format", "json"}, ReadOnly: true})
+	if err != nil {
+		return cloudInitStatus{}, err
+	}
+	var status cloudInitStatus
+	if err := json.Unmarshal(result.Stdout, &status); err != nil || status.Status == "" {
+		out := strings.TrimSpace(execOutputString(result.Stdout, true) + "\n" + execOutputString(result.Stderr, true))
+		return cloudInitStatus{}, fmt.Errorf("unexpected output from cloud-init status (exit status %d): %s", result.ExitCode, out)
+	}
+	if status.Errors == nil {
+		status.Errors = []string{}
+	}
+	return status, nil
+}
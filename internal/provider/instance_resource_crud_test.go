@@ -0,0 +1,240 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+)
+
+// TestInstanceResourceCRUD drives multipass_instance through Terraform
+// against a fakeHost, so it runs without Multipass or TF_ACC.
+func TestInstanceResourceCRUD(t *testing.T) {
+	t.Parallel()
+
+	const rn = "multipass_instance.test"
+	for _, tc := range []struct {
+		name         string
+		steps        func(host *fakeHost) []resource.TestStep
+		checkDestroy func(host *fakeHost) error
+	}{
+		{
+			name: "create and import",
+			steps: func(host *fakeHost) []resource.TestStep {
+				return []resource.TestStep{
+					{
+						Config: testFakeInstanceConfig(`cpus = 2`),
+						Check: resource.ComposeAggregateTestCheckFunc(
+							resource.TestCheckResourceAttr(rn, "id", "vm"),
+							resource.TestCheckResourceAttr(rn, "state", "Running"),
+							resource.TestCheckResourceAttr(rn, "release", "Ubuntu 24.04 LTS"),
+							resource.TestCheckResourceAttr(rn, "ipv4.0", "10.0.0.1"),
+							checkFakeHost(func() error {
+								if inst := host.instance("vm"); inst == nil || inst.CPUCount != 2 {
+									return fmt.Errorf("expected vm to be launched with 2 CPUs, got %+v", inst)
+								}
+								return nil
+							}),
+						),
+					},
+					{
+						ResourceName:                         rn,
+						ImportState:                          true,
+						ImportStateId:                        "vm",
+						ImportStateVerify:                    true,
+						ImportStateVerifyIdentifierAttribute: "name",
+						ImportStateVerifyIgnore:              []string{"cpus", "image", "last_updated"},
+					},
+				}
+			},
+			checkDestroy: func(host *fakeHost) error {
+				if inst := host.instance("vm"); inst != nil {
+					return fmt.Errorf("vm still exists (state: %s)", inst.State)
+				}
+				return nil
+			},
+		},
+		{
+			name: "relaunch after deletion outside terraform",
+			steps: func(host *fakeHost) []resource.TestStep {
+				return []resource.TestStep{
+					{Config: testFakeInstanceConfig("")},
+					{
+						PreConfig: func() { host.setState("vm", "") },
+						Config:    testFakeInstanceConfig(""),
+						Check: checkFakeHost(func() error {
+							if n := host.launchCount("vm"); n != 2 {
+								return fmt.Errorf("expected vm to be launched twice, got %d", n)
+							}
+							return nil
+						}),
+					},
+				}
+			},
+		},
+		{
+			name: "auto_recover a soft-deleted instance",
+			steps: func(host *fakeHost) []resource.TestStep {
+				config := testFakeInstanceConfig("auto_recover = true\n  auto_start_on_recover = true")
+				return []resource.TestStep{
+					{Config: config},
+					{
+						PreConfig: func() { host.setState("vm", "Deleted") },
+						Config:    config,
+						Check: resource.ComposeAggregateTestCheckFunc(
+							resource.TestCheckResourceAttr(rn, "state", "Running"),
+							checkFakeHost(func() error {
+								if n := host.launchCount("vm"); n != 1 {
+									return fmt.Errorf("expected vm to be recovered rather than relaunched, got %d launches", n)
+								}
+								return nil
+							}),
+						),
+					},
+				}
+			},
+		},
+		{
+			name: "reordering mounts and networks plans nothing",
+			steps: func(*fakeHost) []resource.TestStep {
+				block := func(name, body string) string { return name + " {\n    " + body + "\n  }\n  " }
+				srcMount := block("mounts", `host_path = "/src"
+    instance_path = "/mnt/src"`)
+				dataMount := block("mounts", `host_path = "/data"
+    instance_path = "/mnt/data"`)
+				en0 := block("networks", `name = "en0"`)
+				en1 := block("networks", `name = "en1"`)
+				return []resource.TestStep{
+					{Config: testFakeInstanceConfig(srcMount + dataMount + en0 + en1)},
+					{
+						Config:   testFakeInstanceConfig(dataMount + srcMount + en1 + en0),
+						PlanOnly: true,
+					},
+				}
+			},
+		},
+		{
+			name: "missing mount is mounted again on apply",
+			steps: func(host *fakeHost) []resource.TestStep {
+				config := testFakeInstanceConfig(`mounts {
+    host_path     = "/src"
+    instance_path = "/mnt/src"
+  }`)
+				return []resource.TestStep{
+					{Config: config},
+					{
+						PreConfig: func() { host.removeMount("vm", "/mnt/src") },
+						Config:    config,
+						Check: checkFakeHost(func() error {
+							if got := host.mountPaths("vm"); len(got) != 1 || got[0] != "/mnt/src" {
+								return fmt.Errorf("expected /mnt/src to be mounted again, got %v", got)
+							}
+							return nil
+						}),
+					},
+				}
+			},
+		},
+		{
+			name: "auto_remount mounts again on refresh",
+			steps: func(host *fakeHost) []resource.TestStep {
+				config := testFakeInstanceConfig(`auto_remount = true
+  mounts {
+    host_path     = "/src"
+    instance_path = "/mnt/src"
+  }`)
+				return []resource.TestStep{
+					{Config: config},
+					{
+						PreConfig: func() { host.removeMount("vm", "/mnt/src") },
+						Config:    config,
+						PlanOnly:  true,
+					},
+					{
+						Config: config,
+						Check: checkFakeHost(func() error {
+							if got := host.mountPaths("vm"); len(got) != 1 || got[0] != "/mnt/src" {
+								return fmt.Errorf("expected /mnt/src to be mounted again, got %v", got)
+							}
+							return nil
+						}),
+					},
+				}
+			},
+		},
+		{
+			name: "name_prefix generates a stable name",
+			steps: func(host *fakeHost) []resource.TestStep {
+				config := testProviderConfig + `
+resource "multipass_instance" "test" {
+  name_prefix = "web-"
+}
+`
+				return []resource.TestStep{
+					{
+						Config: config,
+						Check: resource.ComposeAggregateTestCheckFunc(
+							resource.TestMatchResourceAttr(rn, "name", regexp.MustCompile(`^web-[0-9a-f]{8}$`)),
+							resource.TestCheckResourceAttrPair(rn, "id", rn, "name"),
+						),
+					},
+					{Config: config, PlanOnly: true},
+					{
+						ResourceName: rn,
+						ImportState:  true,
+						ImportStateIdFunc: func(s *terraform.State) (string, error) {
+							return s.RootModule().Resources[rn].Primary.Attributes["name"], nil
+						},
+						ImportStateVerify:                    true,
+						ImportStateVerifyIdentifierAttribute: "name",
+						ImportStateVerifyIgnore:              []string{"name_prefix", "cpus", "image", "last_updated"},
+					},
+				}
+			},
+		},
+		{
+			name: "soft delete without purge_on_delete",
+			steps: func(*fakeHost) []resource.TestStep {
+				return []resource.TestStep{{Config: testFakeInstanceConfig("purge_on_delete = false")}}
+			},
+			checkDestroy: func(host *fakeHost) error {
+				if inst := host.instance("vm"); inst == nil || inst.State != "Deleted" {
+					return fmt.Errorf("expected vm to be soft-deleted, got %+v", inst)
+				}
+				return nil
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			host := newFakeHost()
+			testCase := resource.TestCase{
+				ProtoV6ProviderFactories: fakeHostProviderFactories(host),
+				Steps:                    tc.steps(host),
+			}
+			if tc.checkDestroy != nil {
+				testCase.CheckDestroy = func(*terraform.State) error { return tc.checkDestroy(host) }
+			}
+			resource.UnitTest(t, testCase)
+		})
+	}
+}
+
+// testFakeInstanceConfig returns a multipass_instance named vm with extra
+// arguments appended.
+func testFakeInstanceConfig(extra string) string {
+	return testProviderConfig + fmt.Sprintf(`
+resource "multipass_instance" "test" {
+  name = "vm"
+  %s
+}
+`, extra)
+}
+
+// checkFakeHost adapts an assertion on a fakeHost to a TestCheckFunc.
+func checkFakeHost(check func() error) resource.TestCheckFunc {
+	return func(*terraform.State) error { return check() }
+}
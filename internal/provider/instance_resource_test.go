@@ -0,0 +1,1067 @@
+package provider
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+// instanceState builds a multipass_instance state named "vm" with every
+// other attribute null except those in attrs.
+func instanceState(t *testing.T, r *instanceResource, attrs map[string]tftypes.Value) tfsdk.State {
+	t.Helper()
+	ctx := context.Background()
+
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+	objType := schemaResp.Schema.Type().TerraformType(ctx).(tftypes.Object)
+	values := map[string]tftypes.Value{}
+	for name, typ := range objType.AttributeTypes {
+		values[name] = tftypes.NewValue(typ, nil)
+	}
+	values["name"] = tftypes.NewValue(tftypes.String, "vm")
+	for name, v := range attrs {
+		values[name] = v
+	}
+	return tfsdk.State{Schema: schemaResp.Schema, Raw: tftypes.NewValue(objType, values)}
+}
+
+func TestInstanceDeletePurgeOnDelete(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name            string
+		providerDefault bool
+		value           *bool
+		want            string
+	}{
+		{name: "default purges", providerDefault: true, want: "delete vm purge=true"},
+		{name: "provider soft delete", providerDefault: false, want: "delete vm purge=false"},
+		{name: "resource overrides provider", providerDefault: false, value: boolPtr(true), want: "delete vm purge=true"},
+		{name: "resource soft delete", providerDefault: true, value: boolPtr(false), want: "delete vm purge=false"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &fakeClient{}
+			r := &instanceResource{client: client, purgeOnDelete: tc.providerDefault, commandTimeout: time.Minute}
+			attrs := map[string]tftypes.Value{}
+			if tc.value != nil {
+				attrs["purge_on_delete"] = tftypes.NewValue(tftypes.Bool, *tc.value)
+			}
+			state := instanceState(t, r, attrs)
+
+			resp := resource.DeleteResponse{State: state}
+			r.Delete(context.Background(), resource.DeleteRequest{State: state}, &resp)
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+			}
+			if diff := cmp.Diff([]string{tc.want}, client.recorded()); diff != "" {
+				t.Fatalf("unexpected calls (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestInstanceDeleteProtection(t *testing.T) {
+	t.Parallel()
+
+	client := &fakeClient{}
+	r := &instanceResource{client: client, purgeOnDelete: true, commandTimeout: time.Minute}
+	state := instanceState(t, r, map[string]tftypes.Value{
+		"delete_protection": tftypes.NewValue(tftypes.Bool, true),
+	})
+
+	resp := resource.DeleteResponse{State: state}
+	r.Delete(context.Background(), resource.DeleteRequest{State: state}, &resp)
+	if !resp.Diagnostics.HasError() || resp.Diagnostics.Errors()[0].Summary() != "Instance is delete-protected" {
+		t.Fatalf("expected delete protection error, got %v", resp.Diagnostics)
+	}
+	if calls := client.recorded(); len(calls) != 0 {
+		t.Fatalf("expected no calls, got %v", calls)
+	}
+}
+
+func TestInstanceDestroyBehavior(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		behavior  string
+		state     string
+		wantCalls []string
+	}{
+		{behavior: "", wantCalls: []string{"delete vm purge=true"}},
+		{behavior: "delete", wantCalls: []string{"delete vm purge=true"}},
+		{behavior: "suspend", state: "Running", wantCalls: []string{"list", "suspend vm"}},
+		{behavior: "suspend", state: "Stopped", wantCalls: []string{"list"}},
+		{behavior: "suspend", wantCalls: []string{"list"}},
+		{behavior: "abandon", state: "Running"},
+	} {
+		t.Run(tc.behavior+" "+tc.state, func(t *testing.T) {
+			client := &fakeClient{
+				listInstances: func(bool) ([]models.Instance, error) {
+					if tc.state == "" {
+						return nil, nil
+					}
+					return []models.Instance{{Name: "vm", State: tc.state}}, nil
+				},
+				suspend: func(string) error { return nil },
+			}
+			r := &instanceResource{client: client, purgeOnDelete: true, commandTimeout: time.Minute}
+			behavior := tftypes.NewValue(tftypes.String, nil)
+			if tc.behavior != "" {
+				behavior = tftypes.NewValue(tftypes.String, tc.behavior)
+			}
+			state := instanceState(t, r, map[string]tftypes.Value{"destroy_behavior": behavior})
+
+			resp := resource.DeleteResponse{State: state}
+			r.Delete(context.Background(), resource.DeleteRequest{State: state}, &resp)
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+			}
+			if diff := cmp.Diff(tc.wantCalls, client.recorded()); diff != "" {
+				t.Fatalf("unexpected calls (-want +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestInstanceImportState(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		id        string
+		state     string
+		wantErr   string
+		wantCalls []string
+	}{
+		{id: "vm", state: "Running", wantCalls: []string{"list"}},
+		{id: "vm", state: "Deleted", wantErr: "Instance is deleted", wantCalls: []string{"list"}},
+		{id: "vm:recover", state: "Deleted", wantCalls: []string{"list", "recover vm"}},
+		{id: "vm:recover", state: "Stopped", wantCalls: []string{"list"}},
+		// Missing instances are reported by the Read after the import.
+		{id: "vm", wantCalls: []string{"list"}},
+		{id: "vm:purge", wantErr: "Invalid import ID"},
+		{id: ":recover", wantErr: "Invalid import ID"},
+	} {
+		t.Run(tc.id+" "+tc.state, func(t *testing.T) {
+			ctx := context.Background()
+			client := &fakeClient{listInstances: func(bool) ([]models.Instance, error) {
+				if tc.state == "" {
+					return nil, nil
+				}
+				return []models.Instance{{Name: "vm", State: tc.state}}, nil
+			}}
+			r := &instanceResource{client: client}
+			var schemaResp resource.SchemaResponse
+			r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+			resp := resource.ImportStateResponse{State: tfsdk.State{Schema: schemaResp.Schema}}
+			resp.State.Raw = tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil)
+			r.ImportState(ctx, resource.ImportStateRequest{ID: tc.id}, &resp)
+			if diff := cmp.Diff(tc.wantCalls, client.recorded()); diff != "" {
+				t.Fatalf("unexpected calls (-want +got): %s", diff)
+			}
+			if tc.wantErr != "" {
+				if !resp.Diagnostics.HasError() || resp.Diagnostics.Errors()[0].Summary() != tc.wantErr {
+					t.Fatalf("expected %q, got %v", tc.wantErr, resp.Diagnostics)
+				}
+				return
+			}
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+			}
+			var got instanceResourceModel
+			resp.State.Get(ctx, &got)
+			if got.Name.ValueString() != "vm" {
+				t.Fatalf("expected name vm, got %s", got.Name)
+			}
+		})
+	}
+}
+
+func TestInstanceModifyPlanSoftDeleteReplace(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	for _, purge := range []bool{true, false} {
+		r := &instanceResource{purgeOnDelete: purge}
+		state := instanceState(t, r, nil)
+		plan := tfsdk.Plan{Schema: state.Schema, Raw: state.Raw}
+
+		resp := resource.ModifyPlanResponse{Plan: plan, RequiresReplace: path.Paths{path.Root("cpus")}}
+		r.ModifyPlan(ctx, resource.ModifyPlanRequest{State: state, Plan: plan}, &resp)
+		if got := resp.Diagnostics.WarningsCount() == 1; got == purge {
+			t.Fatalf("purge_on_delete=%t: unexpected diagnostics %v", purge, resp.Diagnostics)
+		}
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func TestInstanceValidateConfig(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	r := &instanceResource{}
+	objType := instanceState(t, r, nil).Raw.Type().(tftypes.Object)
+	networksType := objType.AttributeTypes["networks"].(tftypes.Set)
+	mountsType := objType.AttributeTypes["mounts"].(tftypes.Set)
+	healthCheckType := objType.AttributeTypes["health_check"].(tftypes.Object)
+
+	healthCheck := func(port any, path any, command []string) tftypes.Value {
+		commandValue := tftypes.NewValue(healthCheckType.AttributeTypes["command"], nil)
+		if command != nil {
+			var elems []tftypes.Value
+			for _, arg := range command {
+				elems = append(elems, tftypes.NewValue(tftypes.String, arg))
+			}
+			commandValue = tftypes.NewValue(healthCheckType.AttributeTypes["command"], elems)
+		}
+		return tftypes.NewValue(healthCheckType, map[string]tftypes.Value{
+			"port":      tftypes.NewValue(tftypes.Number, port),
+			"path":      tftypes.NewValue(tftypes.String, path),
+			"command":   commandValue,
+			"retries":   tftypes.NewValue(tftypes.Number, nil),
+			"interval":  tftypes.NewValue(tftypes.Number, nil),
+			"on_update": tftypes.NewValue(tftypes.Bool, nil),
+		})
+	}
+
+	network := func(name, mode string) tftypes.Value {
+		elemType := networksType.ElementType.(tftypes.Object)
+		return tftypes.NewValue(elemType, map[string]tftypes.Value{
+			"name": tftypes.NewValue(tftypes.String, name),
+			"mode": tftypes.NewValue(tftypes.String, mode),
+			"mac":  tftypes.NewValue(tftypes.String, nil),
+		})
+	}
+	mount := func(host, target string) tftypes.Value {
+		elemType := mountsType.ElementType.(tftypes.Object)
+		return tftypes.NewValue(elemType, map[string]tftypes.Value{
+			"host_path":     tftypes.NewValue(tftypes.String, host),
+			"instance_path": tftypes.NewValue(tftypes.String, target),
+			"read_only":     tftypes.NewValue(tftypes.Bool, nil),
+			"uid_mappings":  tftypes.NewValue(tftypes.List{ElementType: tftypes.String}, nil),
+			"gid_mappings":  tftypes.NewValue(tftypes.List{ElementType: tftypes.String}, nil),
+		})
+	}
+
+	for _, tc := range []struct {
+		name  string
+		attrs map[string]tftypes.Value
+		want  path.Expression
+	}{
+		{
+			name: "valid",
+			attrs: map[string]tftypes.Value{
+				"cloud_init": tftypes.NewValue(tftypes.String, "#cloud-config"),
+				"networks":   tftypes.NewValue(networksType, []tftypes.Value{network("en0", "auto"), network("en1", "auto")}),
+				"mounts":     tftypes.NewValue(mountsType, []tftypes.Value{mount("/src", "/mnt/src"), mount("/data", "/mnt/data")}),
+			},
+		},
+		{
+			name: "cloud-init conflict",
+			attrs: map[string]tftypes.Value{
+				"cloud_init":      tftypes.NewValue(tftypes.String, "#cloud-config"),
+				"cloud_init_file": tftypes.NewValue(tftypes.String, "init.yaml"),
+			},
+			want: path.MatchRoot("cloud_init"),
+		},
+		{
+			name: "unknown cloud-init file",
+			attrs: map[string]tftypes.Value{
+				"cloud_init":      tftypes.NewValue(tftypes.String, "#cloud-config"),
+				"cloud_init_file": tftypes.NewValue(tftypes.String, tftypes.UnknownValue),
+			},
+		},
+		{
+			name: "duplicate network",
+			attrs: map[string]tftypes.Value{
+				"networks": tftypes.NewValue(networksType, []tftypes.Value{network("en0", "auto"), network("en1", "auto"), network("en0", "manual")}),
+			},
+			want: path.MatchRoot("networks").AtAnySetValue().AtName("name"),
+		},
+		{
+			name: "duplicate mount target",
+			attrs: map[string]tftypes.Value{
+				"mounts": tftypes.NewValue(mountsType, []tftypes.Value{mount("/src", "/mnt/src"), mount("/other", "/mnt/src/")}),
+			},
+			want: path.MatchRoot("mounts").AtAnySetValue().AtName("instance_path"),
+		},
+		{
+			name: "http health check",
+			attrs: map[string]tftypes.Value{
+				"health_check": healthCheck(8080, "/healthz", nil),
+			},
+		},
+		{
+			name: "health check without probe",
+			attrs: map[string]tftypes.Value{
+				"health_check": healthCheck(nil, nil, nil),
+			},
+			want: path.MatchRoot("health_check"),
+		},
+		{
+			name: "health check with port and command",
+			attrs: map[string]tftypes.Value{
+				"health_check": healthCheck(8080, nil, []string{"true"}),
+			},
+			want: path.MatchRoot("health_check"),
+		},
+		{
+			name: "health check path without port",
+			attrs: map[string]tftypes.Value{
+				"health_check": healthCheck(nil, "/healthz", []string{"true"}),
+			},
+			want: path.MatchRoot("health_check").AtName("path"),
+		},
+		{
+			name: "unknown networks",
+			attrs: map[string]tftypes.Value{
+				"networks": tftypes.NewValue(networksType, tftypes.UnknownValue),
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			state := instanceState(t, r, tc.attrs)
+			config := tfsdk.Config{Schema: state.Schema, Raw: state.Raw}
+
+			var resp resource.ValidateConfigResponse
+			r.ValidateConfig(ctx, resource.ValidateConfigRequest{Config: config}, &resp)
+			if len(tc.want.Steps()) == 0 {
+				if resp.Diagnostics.HasError() {
+					t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+				}
+				return
+			}
+			if resp.Diagnostics.ErrorsCount() != 1 {
+				t.Fatalf("expected one error, got %v", resp.Diagnostics)
+			}
+			withPath, ok := resp.Diagnostics.Errors()[0].(diag.DiagnosticWithPath)
+			if !ok || !tc.want.Matches(withPath.Path()) {
+				t.Fatalf("expected an error at %s, got %v", tc.want, resp.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestInstanceUpgradeStateV0(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	r := &instanceResource{}
+	upgrader := r.UpgradeState(ctx)[0]
+	priorType := upgrader.PriorSchema.Type().TerraformType(ctx).(tftypes.Object)
+	mountsType := priorType.AttributeTypes["mounts"].(tftypes.List)
+	networksType := priorType.AttributeTypes["networks"].(tftypes.List)
+
+	values := map[string]tftypes.Value{}
+	for name, typ := range priorType.AttributeTypes {
+		values[name] = tftypes.NewValue(typ, nil)
+	}
+	values["id"] = tftypes.NewValue(tftypes.String, "vm")
+	values["name"] = tftypes.NewValue(tftypes.String, "vm")
+	values["cpus"] = tftypes.NewValue(tftypes.Number, 2)
+	values["networks"] = tftypes.NewValue(networksType, []tftypes.Value{
+		tftypes.NewValue(networksType.ElementType, map[string]tftypes.Value{
+			"name": tftypes.NewValue(tftypes.String, "en0"),
+			"mode": tftypes.NewValue(tftypes.String, nil),
+			"mac":  tftypes.NewValue(tftypes.String, nil),
+		}),
+	})
+	var mounts []tftypes.Value
+	for _, target := range []string{"/mnt/b", "/mnt/a"} {
+		mounts = append(mounts, tftypes.NewValue(mountsType.ElementType, map[string]tftypes.Value{
+			"host_path":     tftypes.NewValue(tftypes.String, "/src"+target),
+			"instance_path": tftypes.NewValue(tftypes.String, target),
+			"read_only":     tftypes.NewValue(tftypes.Bool, true),
+			"uid_mappings":  tftypes.NewValue(tftypes.List{ElementType: tftypes.String}, nil),
+			"gid_mappings":  tftypes.NewValue(tftypes.List{ElementType: tftypes.String}, nil),
+		}))
+	}
+	values["mounts"] = tftypes.NewValue(mountsType, mounts)
+	prior := tfsdk.State{Schema: *upgrader.PriorSchema, Raw: tftypes.NewValue(priorType, values)}
+
+	current := instanceState(t, r, nil)
+	resp := resource.UpgradeStateResponse{State: tfsdk.State{Schema: current.Schema, Raw: tftypes.NewValue(current.Raw.Type(), nil)}}
+	upgrader.StateUpgrader(ctx, resource.UpgradeStateRequest{State: &prior}, &resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+	}
+
+	var got instanceResourceModel
+	if diags := resp.State.Get(ctx, &got); diags.HasError() {
+		t.Fatalf("read upgraded state: %v", diags)
+	}
+	if got.Name.ValueString() != "vm" || got.CPUs.ValueInt64() != 2 {
+		t.Fatalf("unexpected upgraded state: name=%s cpus=%s", got.Name, got.CPUs)
+	}
+	if len(got.Networks) != 1 || got.Networks[0].Name.ValueString() != "en0" {
+		t.Fatalf("unexpected networks: %+v", got.Networks)
+	}
+	targets := map[string]bool{}
+	for _, m := range got.Mounts {
+		targets[m.InstancePath.ValueString()] = m.ReadOnly.ValueBool()
+	}
+	if diff := cmp.Diff(map[string]bool{"/mnt/a": true, "/mnt/b": true}, targets); diff != "" {
+		t.Fatalf("unexpected mounts (-want +got): %s", diff)
+	}
+}
+
+func TestInstanceReorderedMounts(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	client := &fakeClient{}
+	r := &instanceResource{client: client, commandTimeout: time.Minute}
+	mountsType := instanceState(t, r, nil).Raw.Type().(tftypes.Object).AttributeTypes["mounts"].(tftypes.Set)
+	mount := func(target string) tftypes.Value {
+		return tftypes.NewValue(mountsType.ElementType, map[string]tftypes.Value{
+			"host_path":     tftypes.NewValue(tftypes.String, "/src"+target),
+			"instance_path": tftypes.NewValue(tftypes.String, target),
+			"read_only":     tftypes.NewValue(tftypes.Bool, nil),
+			"uid_mappings":  tftypes.NewValue(tftypes.List{ElementType: tftypes.String}, nil),
+			"gid_mappings":  tftypes.NewValue(tftypes.List{ElementType: tftypes.String}, nil),
+		})
+	}
+
+	state := instanceState(t, r, map[string]tftypes.Value{
+		"mounts": tftypes.NewValue(mountsType, []tftypes.Value{mount("/mnt/a"), mount("/mnt/b")}),
+	})
+	reordered := instanceState(t, r, map[string]tftypes.Value{
+		"mounts": tftypes.NewValue(mountsType, []tftypes.Value{mount("/mnt/b"), mount("/mnt/a")}),
+	})
+	if !reordered.Raw.Equal(state.Raw) {
+		t.Fatalf("reordered mounts should be the same value:\n%s\n%s", state.Raw, reordered.Raw)
+	}
+
+	plan := tfsdk.Plan{Schema: reordered.Schema, Raw: reordered.Raw}
+	resp := resource.UpdateResponse{State: state}
+	r.Update(ctx, resource.UpdateRequest{Plan: plan, State: state}, &resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+	}
+	if diff := cmp.Diff([]string{"info vm"}, client.recorded()); diff != "" {
+		t.Fatalf("reordering should not remount (-want +got): %s", diff)
+	}
+}
+
+func TestDiffMounts(t *testing.T) {
+	t.Parallel()
+
+	mount := func(host, target string, readOnly bool) mountConfigModel {
+		return mountConfigModel{HostPath: types.StringValue(host), InstancePath: types.StringValue(target), ReadOnly: types.BoolValue(readOnly)}
+	}
+	state := []mountConfigModel{mount("/src", "/mnt/src", false), mount("/data", "/mnt/data", false)}
+
+	for _, tc := range []struct {
+		name                string
+		plan                []mountConfigModel
+		wantAdd, wantRemove []string
+	}{
+		{name: "reordered", plan: []mountConfigModel{state[1], state[0]}},
+		{name: "trailing slash", plan: []mountConfigModel{mount("/src", "/mnt/src/", false), state[1]}},
+		{name: "new host path", plan: []mountConfigModel{mount("/other", "/mnt/src", false), state[1]}, wantAdd: []string{"/other"}, wantRemove: []string{"/src"}},
+		{name: "read only", plan: []mountConfigModel{mount("/src", "/mnt/src", true), state[1]}, wantAdd: []string{"/src"}, wantRemove: []string{"/src"}},
+		{name: "removed", plan: state[:1], wantRemove: []string{"/data"}},
+		{name: "uid mappings", plan: []mountConfigModel{withUIDMappings(mount("/src", "/mnt/src", false), "1000:1000"), state[1]}, wantAdd: []string{"/src"}, wantRemove: []string{"/src"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			toAdd, toRemove := diffMounts(tc.plan, state)
+			hosts := func(mounts []mountConfigModel) []string {
+				var out []string
+				for _, m := range mounts {
+					out = append(out, m.HostPath.ValueString())
+				}
+				return out
+			}
+			if diff := cmp.Diff(tc.wantAdd, hosts(toAdd)); diff != "" {
+				t.Errorf("unexpected additions (-want +got): %s", diff)
+			}
+			if diff := cmp.Diff(tc.wantRemove, hosts(toRemove)); diff != "" {
+				t.Errorf("unexpected removals (-want +got): %s", diff)
+			}
+		})
+	}
+}
+
+// withUIDMappings returns m with uid_mappings set to pairs.
+func withUIDMappings(m mountConfigModel, pairs ...string) mountConfigModel {
+	m.UIDMappings = stringListValue(pairs)
+	return m
+}
+
+func TestDriftedMounts(t *testing.T) {
+	t.Parallel()
+
+	mapped := withUIDMappings(mountConfigModel{HostPath: types.StringValue("/src"), InstancePath: types.StringValue("/mnt/src"), ReadOnly: types.BoolValue(false)}, "1000:-1")
+	unmapped := mountConfigModel{HostPath: types.StringValue("/src"), InstancePath: types.StringValue("/mnt/src"), ReadOnly: types.BoolValue(false)}
+
+	for _, tc := range []struct {
+		name     string
+		recorded mountConfigModel
+		reported models.Mount
+		want     bool
+	}{
+		{name: "same mappings", recorded: mapped, reported: models.Mount{InstancePath: "/mnt/src", UIDMappings: []string{"1000:default"}}},
+		{name: "changed mappings", recorded: mapped, reported: models.Mount{InstancePath: "/mnt/src", UIDMappings: []string{"1000:1001"}}, want: true},
+		{name: "removed mappings", recorded: mapped, reported: models.Mount{InstancePath: "/mnt/src"}, want: true},
+		{name: "mappings not configured", recorded: unmapped, reported: models.Mount{InstancePath: "/mnt/src", UIDMappings: []string{"1000:1001"}}},
+		{name: "read only", recorded: unmapped, reported: models.Mount{InstancePath: "/mnt/src", ReadOnly: true}, want: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			drift := driftedMounts([]mountConfigModel{tc.recorded}, []models.Mount{tc.reported})
+			if got := len(drift) == 1; got != tc.want {
+				t.Fatalf("drifted = %t, want %t", got, tc.want)
+			}
+			if tc.want && drift[0].reported == nil {
+				t.Fatalf("expected the reported mount to be kept for remounting")
+			}
+		})
+	}
+}
+
+func TestInstanceReadMountMappingDrift(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	reported := []models.Mount{{HostPath: "/src", InstancePath: "/mnt/src", UIDMappings: []string{"1000:default"}}}
+	for _, tc := range []struct {
+		name        string
+		autoRemount bool
+		wantUID     []string
+		wantCalls   []string
+	}{
+		{
+			name:      "planned for the next apply",
+			wantUID:   []string{"1000:default"},
+			wantCalls: []string{"info vm"},
+		},
+		{
+			name:        "auto_remount",
+			autoRemount: true,
+			wantUID:     []string{"1000:1001"},
+			wantCalls:   []string{"info vm", "umount vm /mnt/src", "mount vm /mnt/src read_only=false uid=[1000:1001] gid=[]"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &fakeClient{getInstance: func(name string) (*models.Instance, error) {
+				return &models.Instance{Name: name, State: "Running", Mounts: reported}, nil
+			}}
+			r := &instanceResource{client: client, commandTimeout: time.Minute}
+			mountsType := instanceState(t, r, nil).Raw.Type().(tftypes.Object).AttributeTypes["mounts"].(tftypes.Set)
+			listType := tftypes.List{ElementType: tftypes.String}
+			state := instanceState(t, r, map[string]tftypes.Value{
+				"auto_remount": tftypes.NewValue(tftypes.Bool, tc.autoRemount),
+				"mounts": tftypes.NewValue(mountsType, []tftypes.Value{
+					tftypes.NewValue(mountsType.ElementType, map[string]tftypes.Value{
+						"host_path":     tftypes.NewValue(tftypes.String, "/src"),
+						"instance_path": tftypes.NewValue(tftypes.String, "/mnt/src"),
+						"read_only":     tftypes.NewValue(tftypes.Bool, nil),
+						"uid_mappings":  tftypes.NewValue(listType, []tftypes.Value{tftypes.NewValue(tftypes.String, "1000:1001")}),
+						"gid_mappings":  tftypes.NewValue(listType, nil),
+					}),
+				}),
+			})
+
+			resp := resource.ReadResponse{State: state}
+			r.Read(ctx, resource.ReadRequest{State: state}, &resp)
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+			}
+			var got instanceResourceModel
+			if diags := resp.State.Get(ctx, &got); diags.HasError() {
+				t.Fatalf("get state: %v", diags)
+			}
+			if len(got.Mounts) != 1 {
+				t.Fatalf("expected the mount to stay in state, got %v", got.Mounts)
+			}
+			if diff := cmp.Diff(tc.wantUID, idMappings(got.Mounts[0].UIDMappings)); diff != "" {
+				t.Fatalf("unexpected uid_mappings (-want +got): %s", diff)
+			}
+			if diff := cmp.Diff(tc.wantCalls, client.recorded()); diff != "" {
+				t.Fatalf("unexpected calls (-want +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestMissingNetworks(t *testing.T) {
+	t.Parallel()
+
+	network := func(name, mac string) networkConfigModel {
+		n := networkConfigModel{Name: types.StringValue(name), Mode: types.StringNull(), Mac: types.StringNull()}
+		if mac != "" {
+			n.Mac = types.StringValue(mac)
+		}
+		return n
+	}
+	iface := func(name, mac string) models.NetworkInterface {
+		return models.NetworkInterface{Name: name, MAC: mac}
+	}
+	defaultNIC := iface("default", "52:54:00:00:00:01")
+
+	for _, tc := range []struct {
+		name     string
+		recorded []networkConfigModel
+		reported []models.NetworkInterface
+		want     []string
+	}{
+		{
+			name:     "all attached",
+			recorded: []networkConfigModel{network("en0", "52:54:00:AA:BB:02"), network("br0", "")},
+			reported: []models.NetworkInterface{defaultNIC, iface("eth1", "52:54:00:aa:bb:02"), iface("eth2", "52:54:00:aa:bb:03")},
+		},
+		{
+			name:     "mac no longer reported",
+			recorded: []networkConfigModel{network("en0", "52:54:00:aa:bb:02"), network("br0", "")},
+			reported: []models.NetworkInterface{defaultNIC, iface("eth2", "52:54:00:aa:bb:03")},
+			want:     []string{"en0"},
+		},
+		{
+			name:     "fewer interfaces than networks",
+			recorded: []networkConfigModel{network("en0", ""), network("br0", "")},
+			reported: []models.NetworkInterface{defaultNIC, iface("eth1", "52:54:00:aa:bb:02")},
+			want:     []string{"en0"},
+		},
+		{
+			name:     "default interface does not count",
+			recorded: []networkConfigModel{network("br0", "52:54:00:00:00:01")},
+			reported: []models.NetworkInterface{defaultNIC},
+			want:     []string{"br0"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			for _, n := range missingNetworks(tc.recorded, tc.reported) {
+				got = append(got, n.Name.ValueString())
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Fatalf("unexpected missing networks (-want +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestInstanceReadNetworkDrift(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	for _, tc := range []struct {
+		name         string
+		state        string
+		all          bool
+		wantNetworks int
+		wantWarning  bool
+	}{
+		{name: "detached network is dropped", state: "Running", all: true, wantNetworks: 0, wantWarning: true},
+		{name: "stopped instance is not checked", state: "Stopped", all: true, wantNetworks: 1},
+		{name: "release without extra_interfaces is not checked", state: "Running", wantNetworks: 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &fakeClient{getInstance: func(name string) (*models.Instance, error) {
+				return &models.Instance{
+					Name:          name,
+					State:         tc.state,
+					Interfaces:    []models.NetworkInterface{{Name: "default", MAC: "52:54:00:00:00:01"}},
+					AllInterfaces: tc.all,
+				}, nil
+			}}
+			r := &instanceResource{client: client, commandTimeout: time.Minute}
+			networksType := instanceState(t, r, nil).Raw.Type().(tftypes.Object).AttributeTypes["networks"].(tftypes.Set)
+			state := instanceState(t, r, map[string]tftypes.Value{
+				"state": tftypes.NewValue(tftypes.String, tc.state),
+				"networks": tftypes.NewValue(networksType, []tftypes.Value{
+					tftypes.NewValue(networksType.ElementType, map[string]tftypes.Value{
+						"name": tftypes.NewValue(tftypes.String, "en0"),
+						"mode": tftypes.NewValue(tftypes.String, nil),
+						"mac":  tftypes.NewValue(tftypes.String, "52:54:00:aa:bb:02"),
+					}),
+				}),
+			})
+
+			resp := resource.ReadResponse{State: state}
+			r.Read(ctx, resource.ReadRequest{State: state}, &resp)
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+			}
+			var got instanceResourceModel
+			if diags := resp.State.Get(ctx, &got); diags.HasError() {
+				t.Fatalf("get state: %v", diags)
+			}
+			if len(got.Networks) != tc.wantNetworks {
+				t.Fatalf("expected %d networks in state, got %v", tc.wantNetworks, got.Networks)
+			}
+			if warned := resp.Diagnostics.WarningsCount() > 0; warned != tc.wantWarning {
+				t.Fatalf("warning = %t, want %t: %v", warned, tc.wantWarning, resp.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestGenerateInstanceName(t *testing.T) {
+	t.Parallel()
+
+	valid := regexp.MustCompile(`^[A-Za-z][A-Za-z0-9-]*[A-Za-z0-9]$`)
+	for _, tc := range []struct {
+		prefix     string
+		wantPrefix string
+	}{
+		{prefix: "web-", wantPrefix: "web-"},
+		{prefix: "dev", wantPrefix: "dev"},
+		{prefix: strings.Repeat("a", 70), wantPrefix: strings.Repeat("a", instanceNameMaxLength-namePrefixSuffixLength)},
+	} {
+		name, err := generateInstanceName(tc.prefix)
+		if err != nil {
+			t.Fatalf("generate: %v", err)
+		}
+		if !strings.HasPrefix(name, tc.wantPrefix) || len(name) != len(tc.wantPrefix)+namePrefixSuffixLength {
+			t.Errorf("generateInstanceName(%q) = %q, want %q and %d random characters", tc.prefix, name, tc.wantPrefix, namePrefixSuffixLength)
+		}
+		if len(name) > instanceNameMaxLength || !valid.MatchString(name) {
+			t.Errorf("generateInstanceName(%q) = %q is not a valid instance name", tc.prefix, name)
+		}
+	}
+
+	first, _ := generateInstanceName("web-")
+	second, _ := generateInstanceName("web-")
+	if first == second {
+		t.Fatalf("expected distinct names, got %q twice", first)
+	}
+}
+
+func TestInstanceCreateNamePrefix(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	client := &fakeClient{}
+	r := &instanceResource{client: client, commandTimeout: time.Minute}
+	planned := instanceState(t, r, map[string]tftypes.Value{
+		"name":        tftypes.NewValue(tftypes.String, tftypes.UnknownValue),
+		"name_prefix": tftypes.NewValue(tftypes.String, "web-"),
+	})
+	plan := tfsdk.Plan{Schema: planned.Schema, Raw: planned.Raw}
+
+	resp := resource.CreateResponse{State: tfsdk.State{Schema: planned.Schema, Raw: tftypes.NewValue(planned.Raw.Type(), nil)}}
+	r.Create(ctx, resource.CreateRequest{Plan: plan}, &resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+	}
+
+	var got instanceResourceModel
+	resp.State.Get(ctx, &got)
+	name := got.Name.ValueString()
+	if !strings.HasPrefix(name, "web-") || len(name) != len("web-")+namePrefixSuffixLength {
+		t.Fatalf("unexpected generated name %q", name)
+	}
+	if calls := client.recorded(); len(calls) == 0 || calls[0] != "launch "+name {
+		t.Fatalf("expected %q to be launched, got %v", name, calls)
+	}
+	if got.NamePrefix.ValueString() != "web-" {
+		t.Fatalf("expected name_prefix to be kept, got %s", got.NamePrefix)
+	}
+}
+
+func TestNamePrefixRequiresReplace(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	r := &instanceResource{}
+	for _, tc := range []struct {
+		name        string
+		statePrefix tftypes.Value
+		stateName   string
+		planPrefix  string
+		want        bool
+	}{
+		{name: "imported with matching name", statePrefix: tftypes.NewValue(tftypes.String, nil), stateName: "web-1a2b3c4d", planPrefix: "web-"},
+		{name: "imported with other name", statePrefix: tftypes.NewValue(tftypes.String, nil), stateName: "db-1a2b3c4d", planPrefix: "web-", want: true},
+		{name: "prefix changed", statePrefix: tftypes.NewValue(tftypes.String, "web-"), stateName: "web-1a2b3c4d", planPrefix: "api-", want: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			state := instanceState(t, r, map[string]tftypes.Value{
+				"name":        tftypes.NewValue(tftypes.String, tc.stateName),
+				"name_prefix": tc.statePrefix,
+			})
+			var stateValue types.String
+			state.GetAttribute(ctx, path.Root("name_prefix"), &stateValue)
+
+			req := planmodifier.StringRequest{
+				Path:       path.Root("name_prefix"),
+				State:      state,
+				StateValue: stateValue,
+				PlanValue:  types.StringValue(tc.planPrefix),
+			}
+			var resp stringplanmodifier.RequiresReplaceIfFuncResponse
+			namePrefixRequiresReplace(ctx, req, &resp)
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+			}
+			if resp.RequiresReplace != tc.want {
+				t.Fatalf("RequiresReplace = %t, want %t", resp.RequiresReplace, tc.want)
+			}
+		})
+	}
+}
+
+func TestInstanceReadCloudInit(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	lastErrors := tftypes.NewValue(tftypes.List{ElementType: tftypes.String}, []tftypes.Value{tftypes.NewValue(tftypes.String, "old")})
+	for _, tc := range []struct {
+		name       string
+		track      bool
+		state      string
+		output     string
+		exitCode   int
+		wantCalls  []string
+		wantStatus string
+		wantErrors []string
+		wantWarn   bool
+	}{
+		{
+			name:      "not tracked",
+			state:     "Running",
+			wantCalls: []string{"info vm"},
+		},
+		{
+			name:       "done",
+			track:      true,
+			state:      "Running",
+			output:     `{"status": "done", "errors": []}`,
+			wantCalls:  []string{"info vm", "exec-output vm cloud-init status --format json"},
+			wantStatus: "done",
+			wantErrors: []string{},
+		},
+		{
+			// cloud-init exits with status 1 when it reports an error.
+			name:       "error",
+			track:      true,
+			state:      "Running",
+			output:     `{"status": "error", "errors": ["('scripts_user', RuntimeError('failed'))"]}`,
+			exitCode:   1,
+			wantCalls:  []string{"info vm", "exec-output vm cloud-init status --format json"},
+			wantStatus: "error",
+			wantErrors: []string{"('scripts_user', RuntimeError('failed'))"},
+		},
+		{
+			name:       "stopped keeps last known values",
+			track:      true,
+			state:      "Stopped",
+			wantCalls:  []string{"info vm"},
+			wantStatus: "running",
+			wantErrors: []string{"old"},
+		},
+		{
+			name:       "unreadable output keeps last known values",
+			track:      true,
+			state:      "Running",
+			output:     "usage: cloud-init status [-h] [-l] [-w]",
+			exitCode:   2,
+			wantCalls:  []string{"info vm", "exec-output vm cloud-init status --format json"},
+			wantStatus: "running",
+			wantErrors: []string{"old"},
+			wantWarn:   true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &fakeClient{
+				getInstance: func(name string) (*models.Instance, error) {
+					return &models.Instance{Name: name, State: tc.state}, nil
+				},
+				execOutput: func(string, multipasscli.ExecOptions) (multipasscli.ExecResult, error) {
+					return multipasscli.ExecResult{Stdout: []byte(tc.output), ExitCode: tc.exitCode}, nil
+				},
+			}
+			r := &instanceResource{client: client, commandTimeout: time.Minute}
+			state := instanceState(t, r, map[string]tftypes.Value{
+				"track_cloud_init":  tftypes.NewValue(tftypes.Bool, tc.track),
+				"cloud_init_status": tftypes.NewValue(tftypes.String, "running"),
+				"cloud_init_errors": lastErrors,
+			})
+
+			resp := resource.ReadResponse{State: state}
+			r.Read(ctx, resource.ReadRequest{State: state}, &resp)
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+			}
+			if got := resp.Diagnostics.WarningsCount() > 0; got != tc.wantWarn {
+				t.Fatalf("expected warning %t, got %v", tc.wantWarn, resp.Diagnostics)
+			}
+			if diff := cmp.Diff(tc.wantCalls, client.recorded()); diff != "" {
+				t.Fatalf("unexpected calls (-want +got): %s", diff)
+			}
+
+			var got instanceResourceModel
+			resp.State.Get(ctx, &got)
+			if !tc.track {
+				if !got.CloudInitStatus.IsNull() || !got.CloudInitErrors.IsNull() {
+					t.Fatalf("expected no cloud-init attributes, got %s %s", got.CloudInitStatus, got.CloudInitErrors)
+				}
+				return
+			}
+			gotErrors := []string{}
+			got.CloudInitErrors.ElementsAs(ctx, &gotErrors, false)
+			if got.CloudInitStatus.ValueString() != tc.wantStatus {
+				t.Fatalf("expected status %q, got %s", tc.wantStatus, got.CloudInitStatus)
+			}
+			if diff := cmp.Diff(tc.wantErrors, gotErrors); diff != "" {
+				t.Fatalf("unexpected errors (-want +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestInstanceReadTransitionalState(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	for _, tc := range []struct {
+		name      string
+		reported  []string
+		wantState string
+		wantCalls int
+		wantWarn  bool
+	}{
+		{
+			name:      "settles",
+			reported:  []string{"Starting", "Starting", "Running"},
+			wantState: "Running",
+			wantCalls: 3,
+		},
+		{
+			name:      "stays transitional",
+			reported:  []string{"Delayed Shutdown"},
+			wantState: "Delayed Shutdown",
+			wantCalls: 3,
+		},
+		{
+			name:      "unknown keeps the previous state",
+			reported:  []string{"Unknown"},
+			wantState: "Stopped",
+			wantCalls: 3,
+			wantWarn:  true,
+		},
+		{
+			name:      "stable state is read once",
+			reported:  []string{"Suspended"},
+			wantState: "Suspended",
+			wantCalls: 1,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			reads := 0
+			client := &fakeClient{getInstance: func(name string) (*models.Instance, error) {
+				state := tc.reported[min(reads, len(tc.reported)-1)]
+				reads++
+				return &models.Instance{Name: name, State: state}, nil
+			}}
+			r := &instanceResource{client: client, commandTimeout: time.Minute, stateRetries: 2, stateInterval: time.Millisecond}
+			state := instanceState(t, r, map[string]tftypes.Value{
+				"state": tftypes.NewValue(tftypes.String, "Stopped"),
+			})
+
+			resp := resource.ReadResponse{State: state}
+			r.Read(ctx, resource.ReadRequest{State: state}, &resp)
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+			}
+			if got := resp.Diagnostics.WarningsCount() > 0; got != tc.wantWarn {
+				t.Fatalf("expected warning %t, got %v", tc.wantWarn, resp.Diagnostics)
+			}
+			if reads != tc.wantCalls {
+				t.Fatalf("expected %d reads, got %d", tc.wantCalls, reads)
+			}
+
+			var got instanceResourceModel
+			resp.State.Get(ctx, &got)
+			if got.State.ValueString() != tc.wantState {
+				t.Fatalf("expected state %q, got %s", tc.wantState, got.State)
+			}
+		})
+	}
+}
+
+func TestInstanceReadMountDrift(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	reported := []models.Mount{{HostPath: "/data", InstancePath: "/mnt/data", ReadOnly: true}}
+	for _, tc := range []struct {
+		name        string
+		autoRemount bool
+		wantMounts  map[string]bool
+		wantCalls   []string
+	}{
+		{
+			name:       "planned for the next apply",
+			wantMounts: map[string]bool{"/mnt/data": true},
+			wantCalls:  []string{"info vm"},
+		},
+		{
+			name:        "auto_remount",
+			autoRemount: true,
+			wantMounts:  map[string]bool{"/mnt/src": false, "/mnt/data": false},
+			wantCalls:   []string{"info vm", "mount vm /mnt/src read_only=false", "umount vm /mnt/data", "mount vm /mnt/data read_only=false"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &fakeClient{getInstance: func(name string) (*models.Instance, error) {
+				return &models.Instance{Name: name, State: "Running", Mounts: reported}, nil
+			}}
+			r := &instanceResource{client: client, commandTimeout: time.Minute}
+			mountsType := instanceState(t, r, nil).Raw.Type().(tftypes.Object).AttributeTypes["mounts"].(tftypes.Set)
+			mount := func(host, target string) tftypes.Value {
+				return tftypes.NewValue(mountsType.ElementType, map[string]tftypes.Value{
+					"host_path":     tftypes.NewValue(tftypes.String, host),
+					"instance_path": tftypes.NewValue(tftypes.String, target),
+					"read_only":     tftypes.NewValue(tftypes.Bool, nil),
+					"uid_mappings":  tftypes.NewValue(tftypes.List{ElementType: tftypes.String}, nil),
+					"gid_mappings":  tftypes.NewValue(tftypes.List{ElementType: tftypes.String}, nil),
+				})
+			}
+			state := instanceState(t, r, map[string]tftypes.Value{
+				"auto_remount": tftypes.NewValue(tftypes.Bool, tc.autoRemount),
+				"mounts":       tftypes.NewValue(mountsType, []tftypes.Value{mount("/src", "/mnt/src"), mount("/data", "/mnt/data")}),
+			})
+
+			resp := resource.ReadResponse{State: state}
+			r.Read(ctx, resource.ReadRequest{State: state}, &resp)
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+			}
+			var got instanceResourceModel
+			if diags := resp.State.Get(ctx, &got); diags.HasError() {
+				t.Fatalf("get state: %v", diags)
+			}
+			mounts := map[string]bool{}
+			for _, m := range got.Mounts {
+				mounts[m.InstancePath.ValueString()] = m.ReadOnly.ValueBool()
+			}
+			if diff := cmp.Diff(tc.wantMounts, mounts); diff != "" {
+				t.Fatalf("unexpected mounts (-want +got): %s", diff)
+			}
+			if diff := cmp.Diff(tc.wantCalls, client.recorded()); diff != "" {
+				t.Fatalf("unexpected calls (-want +got): %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+// defaultInstanceWaitTimeout bounds waitForRunning when the resource does
+// not configure its own timeout.
+const defaultInstanceWaitTimeout = 5 * time.Minute
+
+// instanceWaitInterval is the delay between state polls. It is a variable so
+// tests can shorten it.
+var instanceWaitInterval = 2 * time.Second
+
+// waitForRunning blocks until the named instance reports Running. When
+// startIfStopped is set, a stopped or suspended instance is started first;
+// otherwise the wait only covers instances that are still booting. Like
+// waitForInstanceAfterTimeout it polls `multipass list`, which does not
+// depend on SSH being available inside the guest.
+//
+// The returned prior state is "stopped" or "suspended" when the instance was
+// started here, and empty otherwise.
+func waitForRunning(ctx context.Context, client multipasscli.Client, name string, startIfStopped bool, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	prior := ""
+	lastState := "unknown"
+	for {
+		instances, err := client.ListInstances(ctx, true)
+		if err == nil {
+			found := false
+			for _, inst := range instances {
+				if inst.Name != name {
+					continue
+				}
+				found = true
+				lastState = inst.State
+				switch strings.ToLower(inst.State) {
+				case "running":
+					return prior, nil
+				case "stopped", "suspended":
+					if !startIfStopped {
+						return "", fmt.Errorf("instance %q is %s; start it or set `start_if_stopped = true`", name, strings.ToLower(inst.State))
+					}
+					if prior == "" {
+						tflog.Info(ctx, "Starting instance before file transfer", map[string]any{"instance": name})
+						if err := client.StartInstance(ctx, name); err != nil {
+							return "", fmt.Errorf("start instance %q: %w", name, err)
+						}
+						prior = strings.ToLower(inst.State)
+					}
+				}
+			}
+			if !found {
+				return "", fmt.Errorf("instance %q: %w", name, multipasscli.ErrNotFound)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("instance %q did not reach Running within %s (last state: %s)", name, timeout, lastState)
+		case <-time.After(instanceWaitInterval):
+		}
+	}
+}
+
+// waitForStopped blocks until the named instance reports Stopped, stopping
+// it first if needed. Multipass cannot stop a suspended instance, so one is
+// resumed and then stopped.
+//
+// The returned prior state is "running" or "suspended" when the instance was
+// stopped here, and empty when it already was. It is returned alongside an
+// error as well, so callers can put a half-stopped instance back.
+func waitForStopped(ctx context.Context, client multipasscli.Client, name string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	prior := ""
+	resumed, stopped := false, false
+	lastState := "unknown"
+	for {
+		instances, err := client.ListInstances(ctx, true)
+		if err == nil {
+			found := false
+			for _, inst := range instances {
+				if inst.Name != name {
+					continue
+				}
+				found = true
+				lastState = inst.State
+				switch strings.ToLower(inst.State) {
+				case "stopped":
+					return prior, nil
+				case "suspended":
+					if !resumed {
+						tflog.Info(ctx, "Resuming suspended instance so it can be stopped", map[string]any{"instance": name})
+						prior = "suspended"
+						resumed = true
+						if err := client.StartInstance(ctx, name); err != nil {
+							return prior, fmt.Errorf("resume instance %q: %w", name, err)
+						}
+					}
+				case "running":
+					if !stopped {
+						if prior == "" {
+							prior = "running"
+						}
+						tflog.Info(ctx, "Stopping instance", map[string]any{"instance": name})
+						stopped = true
+						if err := client.StopInstance(ctx, name, false); err != nil {
+							return prior, fmt.Errorf("stop instance %q: %w", name, err)
+						}
+					}
+				}
+			}
+			if !found {
+				return prior, fmt.Errorf("instance %q: %w", name, multipasscli.ErrNotFound)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return prior, fmt.Errorf("instance %q did not reach Stopped within %s (last state: %s)", name, timeout, lastState)
+		case <-time.After(instanceWaitInterval):
+		}
+	}
+}
+
+// stopForOperation stops the named instance with waitForStopped ahead of
+// an operation that needs it stopped. The returned restore function puts the
+// instance back into its prior state via restartAfterStop; callers defer it
+// and must do so even when err is set, so a half-stopped instance is put
+// back too. restore runs on a context detached from ctx and bounded by
+// restoreTimeout, so it still works once ctx has expired.
+func stopForOperation(ctx context.Context, client multipasscli.Client, name string, timeout, restoreTimeout time.Duration, purpose string) (restore func() diag.Diagnostics, err error) {
+	prior, err := waitForStopped(ctx, client, name, timeout)
+	restore = func() diag.Diagnostics {
+		restoreCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), restoreTimeout)
+		defer cancel()
+		return restartAfterStop(restoreCtx, client, name, prior, purpose)
+	}
+	return restore, err
+}
+
+// restartAfterStop returns an instance stopped by waitForStopped to its
+// prior state. A suspended instance can only be suspended again once it is
+// running, so it is started first. purpose completes "was stopped for" in
+// the warning reported on failure.
+func restartAfterStop(ctx context.Context, client multipasscli.Client, instance, prior, purpose string) diag.Diagnostics {
+	var diags diag.Diagnostics
+	if prior != "running" && prior != "suspended" {
+		return diags
+	}
+
+	tflog.Info(ctx, "Restarting instance stopped for "+purpose, map[string]any{"instance": instance, "prior_state": prior})
+	err := client.StartInstance(ctx, instance)
+	if err == nil && prior == "suspended" {
+		err = client.SuspendInstance(ctx, instance)
+	}
+	if err != nil {
+		diags.AddWarning(
+			"Failed to restore instance state",
+			fmt.Sprintf("Instance %q was stopped for %s but could not be returned to %s: %s", instance, purpose, prior, err),
+		)
+	}
+	return diags
+}
+
+// waitForTransferInstance applies the wait_for_instance, start_if_stopped
+// and wait_timeout attributes shared by the file transfer resources. It
+// returns the state the instance was started from, for restoreInstanceState.
+func waitForTransferInstance(ctx context.Context, client multipasscli.Client, instance string, wait, start types.Bool, timeout types.Int64) (string, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	if !wait.IsNull() && !wait.IsUnknown() && !wait.ValueBool() {
+		return "", diags
+	}
+
+	waitTimeout := defaultInstanceWaitTimeout
+	if !timeout.IsNull() && !timeout.IsUnknown() {
+		waitTimeout = time.Duration(timeout.ValueInt64()) * time.Second
+	}
+
+	startIfStopped := !start.IsNull() && !start.IsUnknown() && start.ValueBool()
+	prior, err := waitForRunning(ctx, client, instance, startIfStopped, waitTimeout)
+	if err != nil {
+		diags.AddError("Instance not running", err.Error())
+	}
+	return prior, diags
+}
+
+// restoreInstanceState stops or suspends an instance that
+// waitForTransferInstance started, returning it to the state it was found
+// in. Failures are reported as warnings since the transfer itself succeeded.
+func restoreInstanceState(ctx context.Context, client multipasscli.Client, instance, prior string) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	var err error
+	switch prior {
+	case "stopped":
+		tflog.Info(ctx, "Stopping instance after file transfer", map[string]any{"instance": instance})
+		err = client.StopInstance(ctx, instance, false)
+	case "suspended":
+		tflog.Info(ctx, "Suspending instance after file transfer", map[string]any{"instance": instance})
+		err = client.SuspendInstance(ctx, instance)
+	default:
+		return diags
+	}
+	if err != nil {
+		diags.AddWarning(
+			"Failed to restore instance state",
+			fmt.Sprintf("Instance %q was started for the transfer but could not be returned to %s: %s", instance, prior, err),
+		)
+	}
+	return diags
+}
+
+// isTransitionalState reports whether state is one Multipass only reports
+// while an instance is changing, or when it cannot tell.
+func isTransitionalState(state string) bool {
+	switch strings.ToLower(state) {
+	case "starting", "restarting", "suspending", "delayed shutdown", "unknown":
+		return true
+	}
+	return false
+}
+
+// settleState reads the instance again, up to stateRetries times and
+// stateInterval apart, while it reports a transitional state. It returns
+// the last instance read, which may still be transitional.
+func (r *instanceResource) settleState(ctx context.Context, name string, instance *models.Instance) *models.Instance {
+	for attempt := 1; attempt <= r.stateRetries && isTransitionalState(instance.State); attempt++ {
+		tflog.Debug(ctx, "Waiting for instance state to settle", map[string]any{"state": instance.State, "attempt": attempt})
+		select {
+		case <-ctx.Done():
+			return instance
+		case <-time.After(r.stateInterval):
+		}
+
+		next, err := r.client.GetInstance(ctx, name)
+		if err != nil && !errors.Is(err, multipasscli.ErrNotFound) {
+			next, err = findListedInstance(ctx, r.client, name)
+		}
+		if err != nil {
+			tflog.Debug(ctx, "Re-reading instance failed", map[string]any{"error": err.Error()})
+			return instance
+		}
+		instance = next
+	}
+	return instance
+}
@@ -0,0 +1,166 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
+)
+
+func TestWaitForRunning(t *testing.T) {
+	instanceWaitInterval = time.Millisecond
+
+	t.Run("waits through transitional states", func(t *testing.T) {
+		states := []string{"Starting", "Starting", "Running"}
+		client := &fakeClient{
+			listInstances: func(bool) ([]models.Instance, error) {
+				state := states[0]
+				if len(states) > 1 {
+					states = states[1:]
+				}
+				return []models.Instance{{Name: "vm", State: state}}, nil
+			},
+		}
+		if _, err := waitForRunning(context.Background(), client, "vm", false, time.Second); err != nil {
+			t.Fatalf("waitForRunning: %v", err)
+		}
+		if got := len(client.recorded()); got != 3 {
+			t.Fatalf("expected 3 polls, got %d", got)
+		}
+	})
+
+	t.Run("stopped without start fails", func(t *testing.T) {
+		client := &fakeClient{
+			listInstances: func(bool) ([]models.Instance, error) {
+				return []models.Instance{{Name: "vm", State: "Stopped"}}, nil
+			},
+		}
+		_, err := waitForRunning(context.Background(), client, "vm", false, time.Second)
+		if err == nil || !strings.Contains(err.Error(), "start_if_stopped") {
+			t.Fatalf("expected start_if_stopped hint, got %v", err)
+		}
+	})
+
+	t.Run("starts stopped instance once", func(t *testing.T) {
+		state := "Stopped"
+		client := &fakeClient{
+			listInstances: func(bool) ([]models.Instance, error) {
+				return []models.Instance{{Name: "vm", State: state}}, nil
+			},
+			startInstance: func(string) error {
+				state = "Running"
+				return nil
+			},
+		}
+		prior, err := waitForRunning(context.Background(), client, "vm", true, time.Second)
+		if err != nil {
+			t.Fatalf("waitForRunning: %v", err)
+		}
+		if prior != "stopped" {
+			t.Fatalf("expected prior state stopped, got %q", prior)
+		}
+		want := []string{"list", "start vm", "list"}
+		if diff := cmp.Diff(want, client.recorded()); diff != "" {
+			t.Fatalf("unexpected calls: %s", diff)
+		}
+	})
+
+	t.Run("missing instance", func(t *testing.T) {
+		client := &fakeClient{}
+		if _, err := waitForRunning(context.Background(), client, "vm", false, time.Second); err == nil {
+			t.Fatalf("expected error for missing instance")
+		}
+	})
+}
+
+func TestRestoreInstanceState(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	cases := []struct {
+		prior string
+		want  []string
+	}{
+		{prior: "", want: nil},
+		{prior: "stopped", want: []string{"stop vm force=false"}},
+		{prior: "suspended", want: []string{"suspend vm"}},
+	}
+	for _, tc := range cases {
+		client := &fakeClient{}
+		if diags := restoreInstanceState(ctx, client, "vm", tc.prior); diags.HasError() || diags.WarningsCount() != 0 {
+			t.Fatalf("%q: unexpected diagnostics: %v", tc.prior, diags)
+		}
+		if diff := cmp.Diff(tc.want, client.recorded()); diff != "" {
+			t.Fatalf("%q: unexpected calls: %s", tc.prior, diff)
+		}
+	}
+
+	client := &fakeClient{stopInstance: func(string) error { return errors.New("stop timed out") }}
+	diags := restoreInstanceState(ctx, client, "vm", "stopped")
+	if diags.HasError() || diags.WarningsCount() != 1 {
+		t.Fatalf("expected a single warning, got %v", diags)
+	}
+}
+
+func TestWaitForStopped(t *testing.T) {
+	instanceWaitInterval = time.Millisecond
+
+	// vmClient simulates an instance in the given state that follows
+	// start, stop and suspend requests.
+	vmClient := func(state string) *fakeClient {
+		return &fakeClient{
+			listInstances: func(bool) ([]models.Instance, error) {
+				return []models.Instance{{Name: "vm", State: state}}, nil
+			},
+			startInstance: func(string) error { state = "Running"; return nil },
+			stopInstance:  func(string) error { state = "Stopped"; return nil },
+			suspend:       func(string) error { state = "Suspended"; return nil },
+		}
+	}
+
+	cases := map[string]struct {
+		state     string
+		wantPrior string
+		wantCalls []string
+	}{
+		"already stopped": {"Stopped", "", []string{"list"}},
+		"running":         {"Running", "running", []string{"list", "stop vm force=false", "list"}},
+		"suspended":       {"Suspended", "suspended", []string{"list", "start vm", "list", "stop vm force=false", "list"}},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			client := vmClient(tc.state)
+			prior, err := waitForStopped(context.Background(), client, "vm", time.Second)
+			if err != nil {
+				t.Fatalf("waitForStopped: %v", err)
+			}
+			if prior != tc.wantPrior {
+				t.Fatalf("prior = %q, want %q", prior, tc.wantPrior)
+			}
+			if diff := cmp.Diff(tc.wantCalls, client.recorded()); diff != "" {
+				t.Fatalf("unexpected calls: %s", diff)
+			}
+		})
+	}
+
+	t.Run("stop failure keeps prior state", func(t *testing.T) {
+		client := vmClient("Running")
+		client.stopInstance = func(string) error { return errors.New("boom") }
+		prior, err := waitForStopped(context.Background(), client, "vm", time.Second)
+		if err == nil || prior != "running" {
+			t.Fatalf("got prior %q, err %v", prior, err)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		client := vmClient("Stopping")
+		if _, err := waitForStopped(context.Background(), client, "vm", 20*time.Millisecond); err == nil || !strings.Contains(err.Error(), "did not reach Stopped") {
+			t.Fatalf("expected timeout error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,162 @@
+package provider
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+var (
+	_ datasource.DataSource              = (*instancesDataSource)(nil)
+	_ datasource.DataSourceWithConfigure = (*instancesDataSource)(nil)
+)
+
+// NewInstancesDataSource returns the instances data source.
+func NewInstancesDataSource() datasource.DataSource {
+	return &instancesDataSource{}
+}
+
+type instancesDataSource struct {
+	client multipasscli.Client
+}
+
+type instancesDataSourceModel struct {
+	State          types.String        `tfsdk:"state"`
+	NameRegex      types.String        `tfsdk:"name_regex"`
+	IncludeDeleted types.Bool          `tfsdk:"include_deleted"`
+	Instances      []instanceModelInfo `tfsdk:"instances"`
+}
+
+type instanceModelInfo struct {
+	Name    types.String `tfsdk:"name"`
+	State   types.String `tfsdk:"state"`
+	Release types.String `tfsdk:"release"`
+	IPv4    types.List   `tfsdk:"ipv4"`
+}
+
+func (d *instancesDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_instances"
+}
+
+func (d *instancesDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Lists the Multipass instances on the host, sorted by name.",
+		Attributes: map[string]schema.Attribute{
+			"state": schema.StringAttribute{
+				Optional:    true,
+				Description: "Only return instances in this state, e.g. `Running` or `Stopped` (case-insensitive).",
+			},
+			"name_regex": schema.StringAttribute{
+				Optional:    true,
+				Description: "Only return instances whose name matches this regular expression.",
+			},
+			"include_deleted": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Include instances that are deleted but not yet purged (default: false).",
+			},
+			"instances": schema.ListNestedAttribute{
+				Computed: true,
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"name": schema.StringAttribute{
+							Computed: true,
+						},
+						"state": schema.StringAttribute{
+							Computed: true,
+						},
+						"release": schema.StringAttribute{
+							Computed: true,
+						},
+						"ipv4": schema.ListAttribute{
+							ElementType: types.StringType,
+							Computed:    true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (d *instancesDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, _ *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	data := req.ProviderData.(providerData)
+	d.client = data.client
+}
+
+func (d *instancesDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	if d.client == nil {
+		resp.Diagnostics.AddError("Client not configured", "Multipass client is nil.")
+		return
+	}
+
+	var config instancesDataSourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	var nameRegex *regexp.Regexp
+	if pattern := valueOrEmpty(config.NameRegex); pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("name_regex"), "Invalid regular expression", err.Error())
+			return
+		}
+		nameRegex = re
+	}
+
+	instances, err := d.client.ListInstances(ctx, true)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to list instances", err.Error())
+		return
+	}
+
+	filtered := filterInstances(instances, valueOrEmpty(config.State), nameRegex, config.IncludeDeleted.ValueBool())
+	config.Instances = make([]instanceModelInfo, 0, len(filtered))
+	for _, inst := range filtered {
+		ipv4, diag := types.ListValueFrom(ctx, types.StringType, inst.IPv4)
+		resp.Diagnostics.Append(diag...)
+		config.Instances = append(config.Instances, instanceModelInfo{
+			Name:    types.StringValue(inst.Name),
+			State:   types.StringValue(inst.State),
+			Release: types.StringValue(inst.Release),
+			IPv4:    ipv4,
+		})
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &config)...)
+}
+
+// filterInstances applies the data source filters and sorts the result by
+// name. Deleted instances are dropped unless includeDeleted is set or state
+// asks for them.
+func filterInstances(instances []models.Instance, state string, nameRegex *regexp.Regexp, includeDeleted bool) []models.Instance {
+	includeDeleted = includeDeleted || strings.EqualFold(state, "Deleted")
+	out := []models.Instance{}
+	for _, inst := range instances {
+		if !includeDeleted && strings.EqualFold(inst.State, "Deleted") {
+			continue
+		}
+		if state != "" && !strings.EqualFold(inst.State, state) {
+			continue
+		}
+		if nameRegex != nil && !nameRegex.MatchString(inst.Name) {
+			continue
+		}
+		out = append(out, inst)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
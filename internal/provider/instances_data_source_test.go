@@ -0,0 +1,118 @@
+package provider
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
+)
+
+func TestFilterInstances(t *testing.T) {
+	t.Parallel()
+
+	instances := []models.Instance{
+		{Name: "web-2", State: "Running"},
+		{Name: "db", State: "Stopped"},
+		{Name: "web-1", State: "Running"},
+		{Name: "old-web", State: "Deleted"},
+	}
+	names := func(in []models.Instance) []string {
+		out := []string{}
+		for _, i := range in {
+			out = append(out, i.Name)
+		}
+		return out
+	}
+
+	cases := map[string]struct {
+		state          string
+		nameRegex      *regexp.Regexp
+		includeDeleted bool
+		want           []string
+	}{
+		"default":         {want: []string{"db", "web-1", "web-2"}},
+		"include deleted": {includeDeleted: true, want: []string{"db", "old-web", "web-1", "web-2"}},
+		"state":           {state: "running", want: []string{"web-1", "web-2"}},
+		"deleted state":   {state: "Deleted", want: []string{"old-web"}},
+		"name regex":      {nameRegex: regexp.MustCompile(`web`), includeDeleted: true, want: []string{"old-web", "web-1", "web-2"}},
+		"no match":        {state: "Suspended", want: []string{}},
+	}
+	for name, tc := range cases {
+		got := names(filterInstances(instances, tc.state, tc.nameRegex, tc.includeDeleted))
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("%s: unexpected instances (-want +got): %s", name, diff)
+		}
+	}
+}
+
+func TestInstancesDataSourceRead(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	read := func(client *fakeClient, config instancesDataSourceModel) (instancesDataSourceModel, datasource.ReadResponse) {
+		d := &instancesDataSource{client: client}
+		var schemaResp datasource.SchemaResponse
+		d.Schema(ctx, datasource.SchemaRequest{}, &schemaResp)
+
+		configState := tfsdk.State{Schema: schemaResp.Schema}
+		if diags := configState.Set(ctx, &config); diags.HasError() {
+			t.Fatalf("set config: %v", diags)
+		}
+		resp := datasource.ReadResponse{State: tfsdk.State{
+			Schema: schemaResp.Schema,
+			Raw:    tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil),
+		}}
+		d.Read(ctx, datasource.ReadRequest{Config: tfsdk.Config{Schema: schemaResp.Schema, Raw: configState.Raw}}, &resp)
+		var state instancesDataSourceModel
+		if !resp.Diagnostics.HasError() {
+			resp.State.Get(ctx, &state)
+		}
+		return state, resp
+	}
+	config := instancesDataSourceModel{
+		State:          types.StringNull(),
+		NameRegex:      types.StringNull(),
+		IncludeDeleted: types.BoolNull(),
+	}
+
+	t.Run("lists instances", func(t *testing.T) {
+		client := &fakeClient{listInstances: func(bool) ([]models.Instance, error) {
+			return []models.Instance{{Name: "web", State: "Running", Release: "24.04 LTS", IPv4: []string{"10.0.0.5"}}}, nil
+		}}
+		state, resp := read(client, config)
+		if resp.Diagnostics.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+		}
+		if len(state.Instances) != 1 || state.Instances[0].Name.ValueString() != "web" || state.Instances[0].Release.ValueString() != "24.04 LTS" ||
+			!state.Instances[0].IPv4.Equal(stringListValue([]string{"10.0.0.5"})) {
+			t.Fatalf("unexpected instances: %+v", state.Instances)
+		}
+	})
+
+	t.Run("no instances", func(t *testing.T) {
+		client := &fakeClient{listInstances: func(bool) ([]models.Instance, error) { return nil, nil }}
+		state, resp := read(client, config)
+		if resp.Diagnostics.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+		}
+		if state.Instances == nil || len(state.Instances) != 0 {
+			t.Fatalf("expected an empty list, got %+v", state.Instances)
+		}
+	})
+
+	t.Run("invalid regex", func(t *testing.T) {
+		invalid := config
+		invalid.NameRegex = types.StringValue("web[")
+		_, resp := read(&fakeClient{}, invalid)
+		if !resp.Diagnostics.HasError() {
+			t.Fatalf("expected an error for an invalid name_regex")
+		}
+	})
+}
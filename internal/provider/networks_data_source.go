@@ -2,10 +2,12 @@ package provider
 
 import (
 	"context"
+	"regexp"
 	"strings"
 
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 
 	"github.com/todoroff/terraform-provider-multipass/internal/models"
@@ -27,8 +29,11 @@ type networksDataSource struct {
 }
 
 type networksDataSourceModel struct {
-	Name     types.String   `tfsdk:"name"`
-	Networks []networkModel `tfsdk:"networks"`
+	Name         types.String   `tfsdk:"name"`
+	Type         types.String   `tfsdk:"type"`
+	NameRegex    types.String   `tfsdk:"name_regex"`
+	RequireMatch types.Bool     `tfsdk:"require_match"`
+	Networks     []networkModel `tfsdk:"networks"`
 }
 
 type networkModel struct {
@@ -49,6 +54,18 @@ func (d *networksDataSource) Schema(_ context.Context, _ datasource.SchemaReques
 				Optional:    true,
 				Description: "Exact network name filter.",
 			},
+			"type": schema.StringAttribute{
+				Optional:    true,
+				Description: "Exact network type filter, e.g. `ethernet`, `wifi` or `bridge`.",
+			},
+			"name_regex": schema.StringAttribute{
+				Optional:    true,
+				Description: "Only return networks whose name matches this regular expression.",
+			},
+			"require_match": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Fail instead of returning an empty list when no network matches the filters (default: false).",
+			},
 			"networks": schema.ListNestedAttribute{
 				Computed: true,
 				NestedObject: schema.NestedAttributeObject{
@@ -89,29 +106,57 @@ func (d *networksDataSource) Read(ctx context.Context, req datasource.ReadReques
 		return
 	}
 
+	var nameRegex *regexp.Regexp
+	if pattern := valueOrEmpty(config.NameRegex); pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("name_regex"), "Invalid regular expression", err.Error())
+			return
+		}
+		nameRegex = re
+	}
+
 	networks, err := d.client.ListNetworks(ctx, false)
 	if err != nil {
 		resp.Diagnostics.AddError("Failed to list networks", err.Error())
 		return
 	}
 
-	nameFilter := strings.TrimSpace(config.Name.ValueString())
-	var filtered []models.Network
-	for _, nw := range networks {
-		if nameFilter != "" && nw.Name != nameFilter {
-			continue
-		}
-		filtered = append(filtered, nw)
+	filtered := filterNetworks(networks, strings.TrimSpace(config.Name.ValueString()), valueOrEmpty(config.Type), nameRegex)
+	if len(filtered) == 0 && config.RequireMatch.ValueBool() {
+		resp.Diagnostics.AddError("No matching network", "No host network matches the given filters; run `multipass networks` to see the available networks.")
+		return
 	}
 
 	model := networksDataSourceModel{
-		Name:     config.Name,
-		Networks: flattenNetworks(filtered),
+		Name:         config.Name,
+		Type:         config.Type,
+		NameRegex:    config.NameRegex,
+		RequireMatch: config.RequireMatch,
+		Networks:     flattenNetworks(filtered),
 	}
 
 	resp.Diagnostics.Append(resp.State.Set(ctx, &model)...)
 }
 
+// filterNetworks keeps the networks matching every non-empty filter.
+func filterNetworks(networks []models.Network, name, networkType string, nameRegex *regexp.Regexp) []models.Network {
+	var filtered []models.Network
+	for _, nw := range networks {
+		if name != "" && nw.Name != name {
+			continue
+		}
+		if networkType != "" && nw.Type != networkType {
+			continue
+		}
+		if nameRegex != nil && !nameRegex.MatchString(nw.Name) {
+			continue
+		}
+		filtered = append(filtered, nw)
+	}
+	return filtered
+}
+
 func flattenNetworks(networks []models.Network) []networkModel {
 	result := make([]networkModel, 0, len(networks))
 	for _, nw := range networks {
@@ -0,0 +1,45 @@
+package provider
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
+)
+
+func TestFilterNetworks(t *testing.T) {
+	networks := []models.Network{
+		{Name: "en0", Type: "wifi"},
+		{Name: "en1", Type: "ethernet"},
+		{Name: "bridge0", Type: "bridge"},
+		{Name: "eth0", Type: "ethernet"},
+	}
+	names := func(in []models.Network) []string {
+		var out []string
+		for _, nw := range in {
+			out = append(out, nw.Name)
+		}
+		return out
+	}
+
+	cases := map[string]struct {
+		name, networkType string
+		nameRegex         *regexp.Regexp
+		want              []string
+	}{
+		"no filters":     {want: []string{"en0", "en1", "bridge0", "eth0"}},
+		"name":           {name: "en1", want: []string{"en1"}},
+		"type":           {networkType: "ethernet", want: []string{"en1", "eth0"}},
+		"regex":          {nameRegex: regexp.MustCompile(`^en\d$`), want: []string{"en0", "en1"}},
+		"type and regex": {networkType: "ethernet", nameRegex: regexp.MustCompile(`^en`), want: []string{"en1"}},
+		"no match":       {networkType: "Ethernet"},
+	}
+	for name, tc := range cases {
+		got := names(filterNetworks(networks, tc.name, tc.networkType, tc.nameRegex))
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("%s: unexpected networks (-want +got): %s", name, diff)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+// Log fields that identify the resource operation a log line belongs to.
+const (
+	logFieldResourceType = "resource_type"
+	logFieldInstance     = "instance"
+	logFieldOperation    = "operation"
+	logFieldDurationMs   = "duration_ms"
+)
+
+// startOperation adds the resource type, instance and operation to ctx's
+// log fields, so every line logged with the returned context carries them,
+// including the multipass client's, and logs the start of the operation.
+// The returned func logs its end with the duration; defer it with the
+// response diagnostics so failures are flagged. Under the provider's
+// dry_run it also adds a warning listing the commands that were skipped.
+func startOperation(ctx context.Context, resourceType, operation, instance string) (context.Context, func(*diag.Diagnostics)) {
+	ctx = tflog.SetField(ctx, logFieldResourceType, resourceType)
+	ctx = tflog.SetField(ctx, logFieldOperation, operation)
+	ctx = multipasscli.TrackSimulated(ctx)
+	if instance != "" {
+		ctx = tflog.SetField(ctx, logFieldInstance, instance)
+	}
+	tflog.Debug(ctx, "Resource operation started")
+
+	start := time.Now()
+	return ctx, func(diags *diag.Diagnostics) {
+		fields := map[string]any{
+			logFieldDurationMs: time.Since(start).Milliseconds(),
+			"failed":           diags.HasError(),
+		}
+		tflog.Debug(ctx, "Resource operation finished", fields)
+
+		if simulated := multipasscli.SimulatedCommands(ctx); len(simulated) > 0 {
+			diags.AddWarning(
+				"Simulated by dry_run",
+				fmt.Sprintf("The %s %s operation did not run these multipass commands:\n  multipass %s", resourceType, operation, strings.Join(simulated, "\n  multipass ")),
+			)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-log/tflogtest"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+// logEntries decodes the JSON log lines written to buf, keeping the message
+// and the operation fields. duration_ms varies and is left out.
+func logEntries(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	entries, err := tflogtest.MultilineJSONDecode(buf)
+	if err != nil {
+		t.Fatalf("decode logs: %v", err)
+	}
+	keep := []string{"@message", logFieldResourceType, logFieldOperation, logFieldInstance, "failed"}
+	out := make([]map[string]any, 0, len(entries))
+	for _, entry := range entries {
+		filtered := map[string]any{}
+		for _, key := range keep {
+			if v, ok := entry[key]; ok {
+				filtered[key] = v
+			}
+		}
+		out = append(out, filtered)
+	}
+	return out
+}
+
+func TestStartOperation(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	ctx := tflogtest.RootLogger(context.Background(), &buf)
+
+	ctx, done := startOperation(ctx, "multipass_snapshot", "create", "vm")
+	tflog.Debug(ctx, "Ran multipass command")
+	var diags diag.Diagnostics
+	diags.AddError("Failed", "boom")
+	done(&diags)
+
+	fields := map[string]any{logFieldResourceType: "multipass_snapshot", logFieldOperation: "create", logFieldInstance: "vm"}
+	with := func(message string, extra map[string]any) map[string]any {
+		entry := map[string]any{"@message": message}
+		for k, v := range fields {
+			entry[k] = v
+		}
+		for k, v := range extra {
+			entry[k] = v
+		}
+		return entry
+	}
+	want := []map[string]any{
+		with("Resource operation started", nil),
+		with("Ran multipass command", nil),
+		with("Resource operation finished", map[string]any{"failed": true}),
+	}
+	if diff := cmp.Diff(want, logEntries(t, &buf)); diff != "" {
+		t.Fatalf("unexpected log entries (-want +got): %s", diff)
+	}
+}
+
+func TestInstanceDeleteLogsOperation(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	ctx := tflogtest.RootLogger(context.Background(), &buf)
+	client := &fakeClient{deleteInstance: func(string) error { return errors.New("delete failed") }}
+	r := &instanceResource{client: client, purgeOnDelete: true, commandTimeout: time.Minute}
+	state := instanceState(t, r, nil)
+
+	resp := resource.DeleteResponse{State: state}
+	r.Delete(ctx, resource.DeleteRequest{State: state}, &resp)
+	if !resp.Diagnostics.HasError() {
+		t.Fatal("expected the delete to fail")
+	}
+
+	entries, err := tflogtest.MultilineJSONDecode(&buf)
+	if err != nil {
+		t.Fatalf("decode logs: %v", err)
+	}
+	if len(entries) < 2 {
+		t.Fatalf("expected start and finish entries, got %v", entries)
+	}
+	for _, entry := range entries {
+		if entry[logFieldResourceType] != "multipass_instance" || entry[logFieldOperation] != "delete" || entry[logFieldInstance] != "vm" {
+			t.Errorf("entry %q is missing the operation fields: %v", entry["@message"], entry)
+		}
+	}
+	last := entries[len(entries)-1]
+	if last["@message"] != "Resource operation finished" || last["failed"] != true {
+		t.Fatalf("expected a failed finish entry, got %v", last)
+	}
+	if _, ok := last[logFieldDurationMs]; !ok {
+		t.Fatalf("expected the finish entry to carry %s, got %v", logFieldDurationMs, last)
+	}
+}
+
+func TestInstanceCreateDryRun(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	fake := &fakeClient{}
+	r := &instanceResource{client: multipasscli.NewDryRunClient(fake), commandTimeout: time.Minute}
+	planned := instanceState(t, r, nil)
+	plan := tfsdk.Plan{Schema: planned.Schema, Raw: planned.Raw}
+
+	resp := resource.CreateResponse{State: tfsdk.State{Schema: planned.Schema, Raw: tftypes.NewValue(planned.Raw.Type(), nil)}}
+	r.Create(ctx, resource.CreateRequest{Plan: plan}, &resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("unexpected error: %v", resp.Diagnostics)
+	}
+	if calls := fake.recorded(); len(calls) != 0 {
+		t.Fatalf("expected no commands to reach multipass, got %v", calls)
+	}
+	warnings := resp.Diagnostics.Warnings()
+	if len(warnings) != 1 || warnings[0].Summary() != "Simulated by dry_run" {
+		t.Fatalf("expected a dry_run warning, got %v", resp.Diagnostics)
+	}
+	if want := "multipass launch --name vm"; !strings.Contains(warnings[0].Detail(), want) {
+		t.Fatalf("expected %q in the warning, got %q", want, warnings[0].Detail())
+	}
+
+	var got instanceResourceModel
+	resp.State.Get(ctx, &got)
+	if got.State.ValueString() != "Running" || len(got.IPv4.Elements()) != 0 {
+		t.Fatalf("expected a running instance without an address, got state %s and ipv4 %s", got.State, got.IPv4)
+	}
+}
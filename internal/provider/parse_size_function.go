@@ -0,0 +1,49 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-framework/function"
+)
+
+var _ function.Function = (*parseSizeFunction)(nil)
+
+// NewParseSizeFunction returns the parse_size provider function.
+func NewParseSizeFunction() function.Function {
+	return &parseSizeFunction{}
+}
+
+type parseSizeFunction struct{}
+
+func (f *parseSizeFunction) Metadata(_ context.Context, _ function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "parse_size"
+}
+
+func (f *parseSizeFunction) Definition(_ context.Context, _ function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:             "Convert a Multipass size string to bytes.",
+		MarkdownDescription: "Converts a size in Multipass notation, such as `512M` or `1.5G`, to a number of bytes. Units are binary (`1K` is 1024 bytes) and case-insensitive, and may be followed by `B` or `iB`. A number without a unit is a byte count. Fractions of a byte are truncated.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:                "size",
+				MarkdownDescription: "Size in Multipass notation, e.g. `1.5G`.",
+			},
+		},
+		Return: function.Int64Return{},
+	}
+}
+
+func (f *parseSizeFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var size string
+	resp.Error = function.ConcatFuncErrors(resp.Error, req.Arguments.Get(ctx, &size))
+	if resp.Error != nil {
+		return
+	}
+
+	bytes, err := parseSize(size)
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(0, err.Error())
+		return
+	}
+	resp.Error = function.ConcatFuncErrors(resp.Error, resp.Result.Set(ctx, bytes))
+}
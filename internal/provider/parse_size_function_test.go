@@ -0,0 +1,66 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// runFunction calls f with a single argument and returns the response with
+// its result initialised to an unknown value of the result type.
+func runFunction(t *testing.T, f function.Function, arg attr.Value, result attr.Value) function.RunResponse {
+	t.Helper()
+	resp := function.RunResponse{Result: function.NewResultData(result)}
+	f.Run(context.Background(), function.RunRequest{Arguments: function.NewArgumentsData([]attr.Value{arg})}, &resp)
+	return resp
+}
+
+func TestParseSizeFunction(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		arg     string
+		want    int64
+		wantErr bool
+	}{
+		{arg: "1.5G", want: 1610612736},
+		{arg: "512M", want: 512 << 20},
+		{arg: "2048", want: 2048},
+		{arg: "1.5X", wantErr: true},
+		{arg: "", wantErr: true},
+	}
+	for _, tc := range cases {
+		resp := runFunction(t, &parseSizeFunction{}, types.StringValue(tc.arg), types.Int64Unknown())
+		if tc.wantErr {
+			if resp.Error == nil {
+				t.Errorf("parse_size(%q): expected an error", tc.arg)
+			} else if resp.Error.FunctionArgument == nil || *resp.Error.FunctionArgument != 0 {
+				t.Errorf("parse_size(%q): error should point at the size argument: %v", tc.arg, resp.Error)
+			}
+			continue
+		}
+		if resp.Error != nil {
+			t.Errorf("parse_size(%q): %v", tc.arg, resp.Error)
+			continue
+		}
+		if got := resp.Result.Value(); !got.Equal(types.Int64Value(tc.want)) {
+			t.Errorf("parse_size(%q) = %s, want %d", tc.arg, got, tc.want)
+		}
+	}
+}
+
+func TestParseSizeFunction_definition(t *testing.T) {
+	t.Parallel()
+
+	var resp function.DefinitionResponse
+	(&parseSizeFunction{}).Definition(context.Background(), function.DefinitionRequest{}, &resp)
+	var validateResp function.DefinitionValidateResponse
+	resp.Definition.ValidateImplementation(context.Background(), function.DefinitionValidateRequest{FuncName: "parse_size"}, &validateResp)
+	if validateResp.Diagnostics.HasError() {
+		t.Fatalf("invalid definition: %v", validateResp.Diagnostics)
+	}
+
+}
@@ -0,0 +1,84 @@
+package provider
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// primaryClaim is one configuration object that sets client.primary-name.
+type primaryClaim struct {
+	// resourceType is the Terraform type of the claiming resource.
+	resourceType string
+	// name identifies the resource within its type, e.g. the instance name.
+	name string
+}
+
+func (c primaryClaim) String() string {
+	return fmt.Sprintf("%s %q", c.resourceType, c.name)
+}
+
+// primaryClaims records the resources that manage client.primary-name
+// during one plan. Terraform plans every resource through the same provider
+// process, so two resources fighting over the setting show up here even
+// though neither can see the other's configuration.
+type primaryClaims struct {
+	mu     sync.Mutex
+	claims map[primaryClaim]*claimPlans
+}
+
+// claimPlans counts the plans recorded for one claim. The provider never
+// learns a resource's address, and Terraform plans a replaced resource twice,
+// first as an update of the prior object and then as a create, so a create
+// and an update pair up as one resource. A second create or update means
+// another resource makes the same claim, e.g. two multipass_primary
+// resources from count = 2 with the same instance.
+type claimPlans struct {
+	creates, updates int
+}
+
+// claim records c, planned as a create when create is set, and returns the
+// other claims seen so far, sorted. c itself is among them when more than
+// one resource makes it. A nil receiver records nothing.
+func (p *primaryClaims) claim(c primaryClaim, create bool) []primaryClaim {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.claims == nil {
+		p.claims = map[primaryClaim]*claimPlans{}
+	}
+	plans := p.claims[c]
+	if plans == nil {
+		plans = &claimPlans{}
+		p.claims[c] = plans
+	}
+	if create {
+		plans.creates++
+	} else {
+		plans.updates++
+	}
+
+	var others []primaryClaim
+	for other := range p.claims {
+		if other != c {
+			others = append(others, other)
+		}
+	}
+	if plans.creates > 1 || plans.updates > 1 {
+		others = append(others, c)
+	}
+	sort.Slice(others, func(i, j int) bool { return others[i].String() < others[j].String() })
+	return others
+}
+
+// describePrimaryClaims lists claims for a diagnostic.
+func describePrimaryClaims(claims []primaryClaim) string {
+	names := make([]string, len(claims))
+	for i, c := range claims {
+		names[i] = c.String()
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,213 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+var (
+	_ resource.Resource                = (*primaryResource)(nil)
+	_ resource.ResourceWithConfigure   = (*primaryResource)(nil)
+	_ resource.ResourceWithModifyPlan  = (*primaryResource)(nil)
+	_ resource.ResourceWithImportState = (*primaryResource)(nil)
+)
+
+// defaultPrimaryName is the value Multipass ships client.primary-name with.
+const defaultPrimaryName = "primary"
+
+// NewPrimaryResource instantiates the primary instance resource.
+func NewPrimaryResource() resource.Resource {
+	return &primaryResource{}
+}
+
+type primaryResource struct {
+	client        multipasscli.Client
+	primaryClaims *primaryClaims
+}
+
+type primaryResourceModel struct {
+	ID       types.String `tfsdk:"id"`
+	Instance types.String `tfsdk:"instance"`
+}
+
+func (r *primaryResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_primary"
+}
+
+func (r *primaryResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Designates the Multipass primary instance (client.primary-name). At most one per configuration.",
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:    true,
+				Description: "Always `client.primary-name`.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"instance": schema.StringAttribute{
+				Required:    true,
+				Description: "Name of the primary instance, used by `multipass shell` and other commands when no instance is given.",
+			},
+		},
+	}
+}
+
+func (r *primaryResource) Configure(_ context.Context, req resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	data := req.ProviderData.(providerData)
+	r.client = data.client
+	r.primaryClaims = data.primaryClaims
+}
+
+// ModifyPlan allows a single multipass_primary per configuration, rejects it
+// alongside a multipass_setting for client.primary-name, and warns about
+// instances that also set primary = true.
+func (r *primaryResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var instance types.String
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("instance"), &instance)...)
+	if resp.Diagnostics.HasError() || instance.IsUnknown() {
+		return
+	}
+
+	var instances []primaryClaim
+	for _, other := range r.primaryClaims.claim(primaryClaim{resourceType: "multipass_primary", name: instance.ValueString()}, req.State.Raw.IsNull()) {
+		switch other.resourceType {
+		case "multipass_primary":
+			detail := fmt.Sprintf("Multipass has a single primary instance, but both %q and %q are configured as primary. Keep only one multipass_primary resource.", instance.ValueString(), other.name)
+			if other.name == instance.ValueString() {
+				detail = fmt.Sprintf("More than one multipass_primary resource sets %q as the primary instance, e.g. through count or for_each. Keep only one multipass_primary resource.", other.name)
+			}
+			resp.Diagnostics.AddAttributeError(path.Root("instance"), "Multiple multipass_primary resources", detail)
+			return
+		case "multipass_setting":
+			resp.Diagnostics.AddAttributeError(
+				path.Root("instance"),
+				"Conflicting primary instance settings",
+				fmt.Sprintf("This resource manages %s, but %s also sets it. Use only one of them.", primaryNameSetting, other),
+			)
+			return
+		default:
+			instances = append(instances, other)
+		}
+	}
+	if len(instances) > 0 {
+		resp.Diagnostics.AddAttributeWarning(
+			path.Root("instance"),
+			"Instances also set primary",
+			fmt.Sprintf("%s set primary = true, which this resource overrides. Remove primary from those instances.", describePrimaryClaims(instances)),
+		)
+	}
+}
+
+func (r *primaryResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	if r.client == nil {
+		resp.Diagnostics.AddError("Client not configured", "Multipass client is nil.")
+		return
+	}
+
+	var plan primaryResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ctx, done := startOperation(ctx, "multipass_primary", "create", plan.Instance.ValueString())
+	defer done(&resp.Diagnostics)
+
+	if err := r.client.SetPrimary(ctx, plan.Instance.ValueString()); err != nil {
+		resp.Diagnostics.AddError("Failed to set primary", err.Error())
+		return
+	}
+
+	plan.ID = types.StringValue(primaryNameSetting)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *primaryResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	if r.client == nil {
+		resp.Diagnostics.AddError("Client not configured", "Multipass client is nil.")
+		return
+	}
+
+	var state primaryResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ctx, done := startOperation(ctx, "multipass_primary", "read", state.Instance.ValueString())
+	defer done(&resp.Diagnostics)
+
+	name, err := r.client.GetSetting(ctx, primaryNameSetting)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read primary instance", err.Error())
+		return
+	}
+
+	state.ID = types.StringValue(primaryNameSetting)
+	state.Instance = types.StringValue(name)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+}
+
+func (r *primaryResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	if r.client == nil {
+		resp.Diagnostics.AddError("Client not configured", "Multipass client is nil.")
+		return
+	}
+
+	var plan primaryResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ctx, done := startOperation(ctx, "multipass_primary", "update", plan.Instance.ValueString())
+	defer done(&resp.Diagnostics)
+
+	if err := r.client.SetPrimary(ctx, plan.Instance.ValueString()); err != nil {
+		resp.Diagnostics.AddError("Failed to set primary", err.Error())
+		return
+	}
+
+	plan.ID = types.StringValue(primaryNameSetting)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+// Delete sets client.primary-name back to Multipass's default rather than
+// to the value found at create, which may name an instance that is gone.
+func (r *primaryResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	if r.client == nil {
+		return
+	}
+
+	ctx, done := startOperation(ctx, "multipass_primary", "delete", "")
+	defer done(&resp.Diagnostics)
+
+	if err := r.client.SetPrimary(ctx, defaultPrimaryName); err != nil {
+		resp.Diagnostics.AddError("Failed to reset primary", err.Error())
+	}
+}
+
+func (r *primaryResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	if req.ID != primaryNameSetting {
+		resp.Diagnostics.AddError("Invalid import ID", fmt.Sprintf("Expected %q, got %q. The primary instance is a single host-wide setting.", primaryNameSetting, req.ID))
+		return
+	}
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("id"), req.ID)...)
+}
@@ -0,0 +1,160 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+// primaryState returns multipass_primary state designating instance.
+func primaryState(t *testing.T, instance string) tfsdk.State {
+	t.Helper()
+	var schemaResp resource.SchemaResponse
+	(&primaryResource{}).Schema(context.Background(), resource.SchemaRequest{}, &schemaResp)
+	state := tfsdk.State{Schema: schemaResp.Schema}
+	model := primaryResourceModel{ID: types.StringValue(primaryNameSetting), Instance: types.StringValue(instance)}
+	if diags := state.Set(context.Background(), &model); diags.HasError() {
+		t.Fatalf("set state: %v", diags)
+	}
+	return state
+}
+
+func TestPrimaryResourceLifecycle(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	current := defaultPrimaryName
+	client := &fakeClient{
+		getSetting: func(string) (string, error) { return current, nil },
+		setPrimary: func(name string) error { current = name; return nil },
+	}
+	r := &primaryResource{client: client}
+
+	created := primaryState(t, "dev")
+	createResp := resource.CreateResponse{State: tfsdk.State{Schema: created.Schema}}
+	r.Create(ctx, resource.CreateRequest{Plan: tfsdk.Plan{Schema: created.Schema, Raw: created.Raw}}, &createResp)
+	if createResp.Diagnostics.HasError() {
+		t.Fatalf("create: %v", createResp.Diagnostics)
+	}
+
+	// Someone runs `multipass set client.primary-name=other` by hand.
+	current = "other"
+	readResp := resource.ReadResponse{State: createResp.State}
+	r.Read(ctx, resource.ReadRequest{State: createResp.State}, &readResp)
+	var got primaryResourceModel
+	readResp.Diagnostics.Append(readResp.State.Get(ctx, &got)...)
+	if readResp.Diagnostics.HasError() || got.Instance.ValueString() != "other" {
+		t.Fatalf("expected drift to other, got %q (%v)", got.Instance.ValueString(), readResp.Diagnostics)
+	}
+
+	deleteResp := resource.DeleteResponse{State: readResp.State}
+	r.Delete(ctx, resource.DeleteRequest{State: readResp.State}, &deleteResp)
+	if deleteResp.Diagnostics.HasError() {
+		t.Fatalf("delete: %v", deleteResp.Diagnostics)
+	}
+
+	want := []string{"primary dev", "get client.primary-name", "primary primary"}
+	if diff := cmp.Diff(want, client.recorded()); diff != "" {
+		t.Fatalf("unexpected calls (-want +got): %s", diff)
+	}
+}
+
+func TestPrimaryResourceModifyPlan(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	// update plans the resource with prior state, as Terraform does before
+	// planning a replacement as a create.
+	planPrimary := func(claims *primaryClaims, instance string, update bool) diag.Diagnostics {
+		state := primaryState(t, instance)
+		plan := tfsdk.Plan{Schema: state.Schema, Raw: state.Raw}
+		resp := resource.ModifyPlanResponse{Plan: plan}
+		req := resource.ModifyPlanRequest{Plan: plan, State: tfsdk.State{Schema: state.Schema, Raw: tftypes.NewValue(state.Raw.Type(), nil)}}
+		if update {
+			req.State = state
+		}
+		(&primaryResource{primaryClaims: claims}).ModifyPlan(ctx, req, &resp)
+		return resp.Diagnostics
+	}
+	modifyPlan := func(claims *primaryClaims, instance string) diag.Diagnostics {
+		return planPrimary(claims, instance, false)
+	}
+
+	t.Run("second primary", func(t *testing.T) {
+		claims := &primaryClaims{}
+		if diags := modifyPlan(claims, "dev"); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		if diags := modifyPlan(claims, "ci"); !diags.HasError() {
+			t.Fatal("expected a second multipass_primary to be rejected")
+		}
+	})
+
+	t.Run("replacement", func(t *testing.T) {
+		claims := &primaryClaims{}
+		if diags := planPrimary(claims, "dev", true); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		if diags := planPrimary(claims, "dev", false); diags.HasError() {
+			t.Fatalf("planning a replacement as a create should not conflict: %v", diags)
+		}
+	})
+
+	t.Run("same instance twice", func(t *testing.T) {
+		claims := &primaryClaims{}
+		if diags := modifyPlan(claims, "dev"); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		diags := modifyPlan(claims, "dev")
+		if !diags.HasError() || !strings.Contains(diags[0].Detail(), "More than one multipass_primary") {
+			t.Fatalf("expected a second multipass_primary for the same instance to be rejected, got %v", diags)
+		}
+	})
+
+	t.Run("setting", func(t *testing.T) {
+		claims := &primaryClaims{}
+		claims.claim(primaryClaim{resourceType: "multipass_setting", name: primaryNameSetting}, true)
+		if diags := modifyPlan(claims, "dev"); !diags.HasError() {
+			t.Fatal("expected a conflict with multipass_setting")
+		}
+	})
+
+	t.Run("instances", func(t *testing.T) {
+		claims := &primaryClaims{}
+		claims.claim(primaryClaim{resourceType: "multipass_instance", name: "web"}, true)
+		diags := modifyPlan(claims, "dev")
+		if diags.HasError() || diags.WarningsCount() != 1 {
+			t.Fatalf("expected a single warning, got %v", diags)
+		}
+
+		r := &instanceResource{primaryClaims: claims}
+		state := instanceState(t, r, map[string]tftypes.Value{"primary": tftypes.NewValue(tftypes.Bool, true)})
+		plan := tfsdk.Plan{Schema: state.Schema, Raw: state.Raw}
+		resp := resource.ModifyPlanResponse{Plan: plan}
+		r.ModifyPlan(ctx, resource.ModifyPlanRequest{Plan: plan, State: tfsdk.State{Schema: state.Schema, Raw: tftypes.NewValue(state.Raw.Type(), nil)}}, &resp)
+		if resp.Diagnostics.HasError() || resp.Diagnostics.WarningsCount() != 1 {
+			t.Fatalf("expected the instance to warn, got %v", resp.Diagnostics)
+		}
+	})
+}
+
+func TestPrimaryResourceImportState(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	state := primaryState(t, "dev")
+	for id, wantErr := range map[string]bool{primaryNameSetting: false, "dev": true} {
+		resp := resource.ImportStateResponse{State: tfsdk.State{Schema: state.Schema, Raw: tftypes.NewValue(state.Raw.Type(), nil)}}
+		(&primaryResource{}).ImportState(ctx, resource.ImportStateRequest{ID: id}, &resp)
+		if resp.Diagnostics.HasError() != wantErr {
+			t.Errorf("import %q: unexpected diagnostics %v", id, resp.Diagnostics)
+		}
+	}
+}
@@ -2,25 +2,50 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
 	"runtime"
 	"sync"
 	"time"
 
 	"github.com/hashicorp/go-version"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
+	"github.com/hashicorp/terraform-plugin-framework/function"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/provider"
 	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
 
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
 	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
 )
 
 const (
-	defaultBinaryName = "multipass"
-	defaultTimeoutSec = 600
+	defaultBinaryName  = "multipass"
+	defaultTimeoutSec  = 600
+	defaultMaxParallel = 4
+
+	// defaultStateRetries and defaultStateInterval bound how long an
+	// instance refresh waits for a transitional state to settle.
+	defaultStateRetries  = 3
+	defaultStateInterval = 2
+
+	// passphraseEnvVar supplies passphrase when it is not configured.
+	passphraseEnvVar = "MULTIPASS_PROVIDER_PASSPHRASE"
+
+	// daemonPollInterval is how often wait_for_daemon probes the daemon.
+	daemonPollInterval = 2 * time.Second
+	// daemonProbeTimeout bounds each multipass list run by wait_for_daemon.
+	daemonProbeTimeout = 10 * time.Second
+
+	// minimumMultipassVersion is the oldest release whose JSON output the
+	// provider parses. It only warns; required_version fails Configure.
+	minimumMultipassVersion = "1.13.0"
 )
 
 // New returns a function that instantiates a Multipass provider configured with
@@ -33,11 +58,29 @@ func New(version string) func() provider.Provider {
 	}
 }
 
-var _ provider.Provider = (*MultipassProvider)(nil)
+// NewWithClient is like New, but the provider runs every command through
+// client instead of the multipass binary, so tests can substitute a scripted
+// fake. multipass_path, cache_ttl and max_parallel_commands are ignored.
+func NewWithClient(version string, client multipasscli.Client) func() provider.Provider {
+	return func() provider.Provider {
+		return &MultipassProvider{
+			version:        version,
+			injectedClient: client,
+		}
+	}
+}
+
+var (
+	_ provider.Provider                       = (*MultipassProvider)(nil)
+	_ provider.ProviderWithFunctions          = (*MultipassProvider)(nil)
+	_ provider.ProviderWithEphemeralResources = (*MultipassProvider)(nil)
+)
 
 // MultipassProvider implements the Terraform Plugin Framework provider.Provider interface.
 type MultipassProvider struct {
 	version string
+	// injectedClient replaces the CLI client when set by NewWithClient.
+	injectedClient multipasscli.Client
 
 	mu     sync.RWMutex
 	client multipasscli.Client
@@ -67,10 +110,87 @@ func (p *MultipassProvider) Schema(_ context.Context, _ provider.SchemaRequest,
 					defaultTimeoutSec,
 				),
 			},
+			"cache_ttl": schema.Int64Attribute{
+				Optional:            true,
+				Description:         "How long instance, image, network and alias listings are cached, in seconds; 0 disables caching (default: 3).",
+				MarkdownDescription: "How long instance, image, network and alias listings are cached, in seconds. `0` disables caching. Defaults to `3`.",
+			},
+			"max_parallel_commands": schema.Int64Attribute{
+				Optional: true,
+				Description: fmt.Sprintf(
+					"Maximum number of multipass commands the provider runs at once (default: %d). Terraform's -parallelism still controls how many resources are processed concurrently.",
+					defaultMaxParallel,
+				),
+				MarkdownDescription: fmt.Sprintf(
+					"Maximum number of `multipass` commands the provider runs at once. Defaults to `%d`. Terraform's `-parallelism` still controls how many resources are processed concurrently; commands beyond this limit wait for a free slot.",
+					defaultMaxParallel,
+				),
+			},
+			"transfer_strategy": schema.StringAttribute{
+				Optional:            true,
+				Description:         "How the file resources move data: direct (multipass transfer), tar (a tar stream through multipass exec) or auto (tar on Windows, direct elsewhere; the default).",
+				MarkdownDescription: "How `multipass_file_download` and `multipass_file_upload` move data. `direct` uses `multipass transfer`; `tar` streams a tar archive through `multipass exec`, which keeps file modes; `auto` (default) picks `tar` on Windows hosts and `direct` elsewhere.",
+			},
+			"passphrase": schema.StringAttribute{
+				Optional:            true,
+				Sensitive:           true,
+				Description:         "Passphrase used to run `multipass authenticate` when the daemon requires one (local.passphrase). Defaults to the MULTIPASS_PROVIDER_PASSPHRASE environment variable.",
+				MarkdownDescription: "Passphrase used to run `multipass authenticate` when the daemon requires one (`local.passphrase`). Defaults to the `MULTIPASS_PROVIDER_PASSPHRASE` environment variable.",
+			},
+			"purge_on_delete": schema.BoolAttribute{
+				Optional:            true,
+				Description:         "Default purge_on_delete for multipass_instance resources that do not set it (default: true). Set to false to only soft-delete instances on destroy.",
+				MarkdownDescription: "Default `purge_on_delete` for `multipass_instance` resources that do not set it. Set to `false` to only soft-delete instances on destroy. Defaults to `true`.",
+			},
+			"defer_binary_check": schema.BoolAttribute{
+				Optional:            true,
+				Description:         "Look up the multipass binary and detect its version on the first command instead of during provider configuration, so multipass can be installed earlier in the same apply (default: false).",
+				MarkdownDescription: "Look up the `multipass` binary and detect its version on the first command instead of during provider configuration, so Multipass can be installed earlier in the same apply. `required_version` is not checked in this mode. Defaults to `false`.",
+			},
 			"default_image": schema.StringAttribute{
 				Optional:    true,
 				Description: "Default image alias or name used when a resource omits an explicit image value.",
 			},
+			"strict_alias_instances": schema.BoolAttribute{
+				Optional:            true,
+				Description:         "Fail the plan instead of warning when a multipass_alias targets an instance that does not exist (default: false).",
+				MarkdownDescription: "Fail the plan instead of warning when a `multipass_alias` targets an instance that does not exist. Defaults to `false`.",
+			},
+			"required_version": schema.StringAttribute{
+				Optional:            true,
+				Description:         "Version constraint the multipass CLI must satisfy, e.g. \">= 1.14, < 2.0\". A mismatch fails provider configuration.",
+				MarkdownDescription: "Version constraint the `multipass` CLI must satisfy, e.g. `\">= 1.14, < 2.0\"`. A mismatch fails provider configuration. When unset, versions older than 1.13.0 only produce a warning.",
+			},
+			"skip_version_check": schema.BoolAttribute{
+				Optional:            true,
+				Description:         "Skip checking the multipass version against required_version and the supported minimum (default: false).",
+				MarkdownDescription: "Skip checking the `multipass` version against `required_version` and the supported minimum. Defaults to `false`.",
+			},
+			"wait_for_daemon": schema.Int64Attribute{
+				Optional:            true,
+				Description:         "Seconds to wait for the multipassd daemon to report its version and list instances during provider configuration, e.g. right after boot or a snap refresh. Configuration fails if it never does (default: 0, no readiness check).",
+				MarkdownDescription: "Seconds to wait for the `multipassd` daemon to report its version and answer `multipass list` during provider configuration, e.g. right after boot or a snap refresh, when it takes a while to accept connections. Each `list` probe gets 10 seconds. The provider fails with instructions for starting the daemon once the window elapses. Ignored when `defer_binary_check` is set. Defaults to `0`, which skips the readiness check; an unreachable daemon then only produces a warning until the first resource fails.",
+			},
+			"transitional_state_retries": schema.Int64Attribute{
+				Optional:            true,
+				Description:         fmt.Sprintf("How many more times a multipass_instance refresh reads an instance that reports a transitional state such as Starting or Unknown; 0 disables the retry (default: %d).", defaultStateRetries),
+				MarkdownDescription: fmt.Sprintf("How many more times a `multipass_instance` refresh reads an instance that reports a transitional state (`Starting`, `Restarting`, `Suspending`, `Delayed Shutdown` or `Unknown`). `0` disables the retry. Defaults to `%d`.", defaultStateRetries),
+			},
+			"transitional_state_interval": schema.Int64Attribute{
+				Optional:            true,
+				Description:         fmt.Sprintf("Seconds between the reads of transitional_state_retries (default: %d).", defaultStateInterval),
+				MarkdownDescription: fmt.Sprintf("Seconds between the reads of `transitional_state_retries`. Defaults to `%d`.", defaultStateInterval),
+			},
+			"dry_run": schema.BoolAttribute{
+				Optional:            true,
+				Description:         "Log multipass commands that would change the host or an instance and report them as successful without running them; reads still run (default: false).",
+				MarkdownDescription: "Log `multipass` commands that would change the host or an instance (launch, delete, start, stop, mount, transfer, snapshot, ...) and report them as successful without running them. Reads still run, so simulated instances disappear on the next refresh. Each simulated resource operation carries a warning. Defaults to `false`.",
+			},
+			"suppress_driver_warnings": schema.BoolAttribute{
+				Optional:            true,
+				Description:         "Do not warn at plan time about instance settings the Multipass driver does not support, such as mounts on hyperv without privileged mounts (default: false).",
+				MarkdownDescription: "Do not warn at plan time about instance settings the Multipass driver (`local.driver`) does not support, such as `mounts` on hyperv without `local.privileged-mounts`, or `destroy_behavior = \"suspend\"` on lxd. Defaults to `false`.",
+			},
 		},
 	}
 }
@@ -88,6 +208,8 @@ func (p *MultipassProvider) Configure(ctx context.Context, req provider.Configur
 		BinaryPath:     defaultBinaryName,
 		DefaultImage:   "",
 		CommandTimeout: defaultTimeoutSec,
+		MaxParallel:    defaultMaxParallel,
+		PurgeOnDelete:  true,
 	}
 
 	if !config.MultipassPath.IsNull() && !config.MultipassPath.IsUnknown() {
@@ -106,45 +228,282 @@ func (p *MultipassProvider) Configure(ctx context.Context, req provider.Configur
 		cfg.CommandTimeout = int(config.CommandTimeout.ValueInt64())
 	}
 
+	if !config.CacheTTL.IsNull() && !config.CacheTTL.IsUnknown() {
+		if config.CacheTTL.ValueInt64() < 0 {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("cache_ttl"),
+				"Invalid cache TTL",
+				"Cache TTL must be zero or a positive integer representing seconds.",
+			)
+			return
+		}
+		ttl := int(config.CacheTTL.ValueInt64())
+		cfg.CacheTTL = &ttl
+	}
+
+	if !config.MaxParallel.IsNull() && !config.MaxParallel.IsUnknown() {
+		if config.MaxParallel.ValueInt64() < 1 {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("max_parallel_commands"),
+				"Invalid max_parallel_commands",
+				"At least one multipass command must be allowed to run at a time.",
+			)
+			return
+		}
+		cfg.MaxParallel = int(config.MaxParallel.ValueInt64())
+	}
+
 	if !config.DefaultImage.IsNull() && !config.DefaultImage.IsUnknown() {
 		cfg.DefaultImage = config.DefaultImage.ValueString()
 	}
 
-	client, err := multipasscli.NewClient(ctx, multipasscli.Config{
-		BinaryPath: cfg.BinaryPath,
-		Timeout:    cfg.CommandTimeout,
-	})
+	if !config.StrictAliases.IsNull() && !config.StrictAliases.IsUnknown() {
+		cfg.StrictAliases = config.StrictAliases.ValueBool()
+	}
+
+	if !config.PurgeOnDelete.IsNull() && !config.PurgeOnDelete.IsUnknown() {
+		cfg.PurgeOnDelete = config.PurgeOnDelete.ValueBool()
+	}
+
+	if !config.DeferBinaryCheck.IsNull() && !config.DeferBinaryCheck.IsUnknown() {
+		cfg.DeferBinaryCheck = config.DeferBinaryCheck.ValueBool()
+	}
+
+	if !config.SkipVersionCheck.IsNull() && !config.SkipVersionCheck.IsUnknown() {
+		cfg.SkipVersionCheck = config.SkipVersionCheck.ValueBool()
+	}
+
+	if !config.WaitForDaemon.IsNull() && !config.WaitForDaemon.IsUnknown() {
+		if config.WaitForDaemon.ValueInt64() < 0 {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("wait_for_daemon"),
+				"Invalid wait_for_daemon",
+				"wait_for_daemon must be zero or a positive integer representing seconds.",
+			)
+			return
+		}
+		cfg.WaitForDaemon = int(config.WaitForDaemon.ValueInt64())
+	}
+
+	cfg.StateRetries = defaultStateRetries
+	if !config.StateRetries.IsNull() && !config.StateRetries.IsUnknown() {
+		if config.StateRetries.ValueInt64() < 0 {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("transitional_state_retries"),
+				"Invalid transitional_state_retries",
+				"transitional_state_retries must be zero or a positive integer.",
+			)
+			return
+		}
+		cfg.StateRetries = int(config.StateRetries.ValueInt64())
+	}
+
+	cfg.StateInterval = defaultStateInterval
+	if !config.StateInterval.IsNull() && !config.StateInterval.IsUnknown() {
+		if config.StateInterval.ValueInt64() < 1 {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("transitional_state_interval"),
+				"Invalid transitional_state_interval",
+				"transitional_state_interval must be a positive integer representing seconds.",
+			)
+			return
+		}
+		cfg.StateInterval = int(config.StateInterval.ValueInt64())
+	}
+
+	if !config.DryRun.IsNull() && !config.DryRun.IsUnknown() {
+		cfg.DryRun = config.DryRun.ValueBool()
+	}
+
+	if !config.SilenceDriver.IsNull() && !config.SilenceDriver.IsUnknown() {
+		cfg.SilenceDriver = config.SilenceDriver.ValueBool()
+	}
+
+	if !config.TransferStrategy.IsNull() && !config.TransferStrategy.IsUnknown() {
+		cfg.TransferStrategy = config.TransferStrategy.ValueString()
+	}
+	cfg.Passphrase = os.Getenv(passphraseEnvVar)
+	if !config.Passphrase.IsNull() && !config.Passphrase.IsUnknown() {
+		cfg.Passphrase = config.Passphrase.ValueString()
+	}
+	if cfg.Passphrase != "" {
+		ctx = tflog.MaskMessageStrings(ctx, cfg.Passphrase)
+		ctx = tflog.MaskAllFieldValuesStrings(ctx, cfg.Passphrase)
+	}
+
+	transferStrategy, err := resolveTransferStrategy(cfg.TransferStrategy, runtime.GOOS)
 	if err != nil {
-		resp.Diagnostics.AddError("Unable to create multipass client", err.Error())
+		resp.Diagnostics.AddAttributeError(path.Root("transfer_strategy"), "Invalid transfer_strategy", err.Error())
 		return
 	}
 
-	ver, vErr := client.Version(ctx)
-	if vErr != nil {
+	constraints := version.MustConstraints(version.NewConstraint(">= " + minimumMultipassVersion))
+	requiredSet := !config.RequiredVersion.IsNull() && !config.RequiredVersion.IsUnknown()
+	if requiredSet {
+		c, err := version.NewConstraint(config.RequiredVersion.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("required_version"),
+				"Invalid required_version",
+				fmt.Sprintf("Could not parse version constraint %q: %v", config.RequiredVersion.ValueString(), err),
+			)
+			return
+		}
+		constraints = c
+	}
+
+	client := p.injectedClient
+	if client == nil {
+		client, err = multipasscli.NewClient(ctx, multipasscli.Config{
+			BinaryPath:       cfg.BinaryPath,
+			Timeout:          cfg.CommandTimeout,
+			CacheTTL:         cfg.CacheTTL,
+			MaxParallel:      cfg.MaxParallel,
+			DeferBinaryCheck: cfg.DeferBinaryCheck,
+		})
+		if err != nil {
+			resp.Diagnostics.AddError("Unable to create multipass client", err.Error())
+			return
+		}
+	}
+
+	switch {
+	case cfg.WaitForDaemon == 0:
+	case cfg.DeferBinaryCheck:
+		resp.Diagnostics.AddAttributeWarning(
+			path.Root("wait_for_daemon"),
+			"wait_for_daemon not applied",
+			"The multipass daemon is not contacted during configuration while defer_binary_check is set, so wait_for_daemon is ignored.",
+		)
+	default:
+		window := time.Duration(cfg.WaitForDaemon) * time.Second
+		if err := waitForDaemon(ctx, client, window, daemonPollInterval); err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("wait_for_daemon"),
+				"Multipass daemon unreachable",
+				fmt.Sprintf("The multipass daemon did not respond within %s: %v", window, err),
+			)
+			return
+		}
+	}
+
+	var detected *version.Version
+	if cfg.Passphrase != "" {
+		switch err := client.Authenticate(ctx, cfg.Passphrase); {
+		case err == nil:
+			tflog.Debug(ctx, "Authenticated with the Multipass daemon")
+		case cfg.DeferBinaryCheck && (errors.Is(err, exec.ErrNotFound) || errors.Is(err, fs.ErrNotExist)):
+			resp.Diagnostics.AddAttributeWarning(
+				path.Root("passphrase"),
+				"Multipass authentication skipped",
+				"The multipass binary is not installed yet, so the provider could not authenticate during configuration. "+
+					"Commands will fail if the daemon requires authentication; run terraform apply again once Multipass is installed.",
+			)
+		case errors.Is(err, multipasscli.ErrAuthenticationFailed):
+			resp.Diagnostics.AddAttributeError(
+				path.Root("passphrase"),
+				"Multipass authentication failed",
+				fmt.Sprintf("The Multipass daemon rejected the passphrase. Check the provider's passphrase argument or the %s environment variable.", passphraseEnvVar),
+			)
+			return
+		default:
+			resp.Diagnostics.AddError("Unable to authenticate with multipass", err.Error())
+			return
+		}
+	}
+
+	tflog.Info(ctx, "Configured Multipass client", map[string]any{
+		"max_parallel_commands": cfg.MaxParallel,
+		"transfer_strategy":     transferStrategy,
+	})
+
+	var ver models.VersionInfo
+	var vErr error
+	if !cfg.DeferBinaryCheck {
+		ver, vErr = client.VersionInfo(ctx)
+	}
+	switch {
+	case cfg.DeferBinaryCheck:
+		tflog.Debug(ctx, "Deferring multipass binary lookup and version detection to the first command")
+		if requiredSet && !cfg.SkipVersionCheck {
+			resp.Diagnostics.AddAttributeWarning(
+				path.Root("required_version"),
+				"required_version not checked",
+				"The multipass version is not detected during configuration while defer_binary_check is set, so required_version is ignored.",
+			)
+		}
+	case vErr != nil && cfg.SkipVersionCheck:
+		tflog.Debug(ctx, "Unable to detect multipass version", map[string]any{"error": vErr.Error()})
+	case vErr != nil:
 		resp.Diagnostics.AddWarning(
 			"Unable to detect multipass version",
 			fmt.Sprintf("Multipass client could not report its version: %v", vErr),
 		)
-	} else {
-		if err := ensureSupportedVersion(ver); err != nil {
+	case ver.Daemon == "":
+		resp.Diagnostics.AddWarning(
+			"Multipass daemon unreachable",
+			fmt.Sprintf("The multipass CLI (version %s) responded, but the multipassd daemon did not report a version; operations will fail until it is reachable. %s "+
+				"If the daemon is still starting, set wait_for_daemon.", ver.Client, multipasscli.DaemonHint(runtime.GOOS)),
+		)
+	default:
+		current, err := checkMultipassVersion(ver.Client, constraints)
+		detected = current
+		switch {
+		case err == nil:
+			tflog.Info(ctx, "Detected Multipass CLI", map[string]any{"version": ver.Client, "daemon_version": ver.Daemon})
+		case cfg.SkipVersionCheck:
+			tflog.Debug(ctx, "Skipping multipass version check", map[string]any{"version": ver.Client, "error": err.Error()})
+		case current == nil:
+			// Unusual development builds must not block the provider.
+			resp.Diagnostics.AddWarning("Unable to parse multipass version", err.Error())
+		case requiredSet:
+			resp.Diagnostics.AddAttributeError(
+				path.Root("required_version"),
+				"Unsupported multipass version",
+				err.Error()+". Set skip_version_check = true to override.",
+			)
+			return
+		default:
 			resp.Diagnostics.AddWarning("Unsupported multipass version", err.Error())
-		} else {
-			tflog.Info(ctx, "Detected Multipass CLI", map[string]any{"version": ver})
 		}
 	}
 
+	var driver driverInfo
+	if !cfg.DeferBinaryCheck && vErr == nil && ver.Daemon != "" {
+		driver = detectDriver(ctx, client)
+	}
+
+	if cfg.DryRun {
+		client = multipasscli.NewDryRunClient(client)
+		resp.Diagnostics.AddAttributeWarning(
+			path.Root("dry_run"),
+			"Dry run enabled",
+			"multipass commands that change the host or an instance are logged and skipped. Resources created in this run do not exist and will be planned again.",
+		)
+	}
+
 	p.mu.Lock()
 	p.client = client
 	p.hostOS = runtime.GOOS
 	p.mu.Unlock()
 
 	resp.ResourceData = providerData{
-		client:         client,
-		defaultImage:   cfg.DefaultImage,
-		hostOS:         p.hostOS,
-		commandTimeout: time.Duration(cfg.CommandTimeout) * time.Second,
+		client:           client,
+		defaultImage:     cfg.DefaultImage,
+		hostOS:           p.hostOS,
+		commandTimeout:   time.Duration(cfg.CommandTimeout) * time.Second,
+		strictAliases:    cfg.StrictAliases,
+		purgeOnDelete:    cfg.PurgeOnDelete,
+		transferStrategy: transferStrategy,
+		multipassVersion: detected,
+		primaryClaims:    &primaryClaims{},
+		stateRetries:     cfg.StateRetries,
+		stateInterval:    time.Duration(cfg.StateInterval) * time.Second,
+		driver:           driver,
+		silenceDriver:    cfg.SilenceDriver,
 	}
 	resp.DataSourceData = resp.ResourceData
+	resp.EphemeralResourceData = resp.ResourceData
 }
 
 // Resources returns the list of resources exposed by the provider.
@@ -153,31 +512,114 @@ func (p *MultipassProvider) Resources(_ context.Context) []func() resource.Resou
 		NewInstanceResource,
 		NewAliasResource,
 		NewSnapshotResource,
+		NewSnapshotRetentionResource,
+		NewSnapshotRestoreResource,
 		NewFileUploadResource,
 		NewFileDownloadResource,
+		NewExecResource,
+		NewSettingResource,
+		NewCloneResource,
+		NewPrimaryResource,
+		NewInstanceClusterResource,
+		NewWaitResource,
 	}
 }
 
 // DataSources returns the list of data sources supported by the provider.
 func (p *MultipassProvider) DataSources(_ context.Context) []func() datasource.DataSource {
 	return []func() datasource.DataSource{
+		NewImageDataSource,
 		NewImagesDataSource,
 		NewNetworksDataSource,
 		NewInstanceDataSource,
+		NewInstancesDataSource,
 		NewSnapshotsDataSource,
+		NewSettingsDataSource,
+		NewFileDataSource,
+		NewExecDataSource,
+	}
+}
+
+// EphemeralResources returns the ephemeral resources supported by the
+// provider.
+func (p *MultipassProvider) EphemeralResources(_ context.Context) []func() ephemeral.EphemeralResource {
+	return []func() ephemeral.EphemeralResource{
+		NewExecEphemeralResource,
 	}
 }
 
-func ensureSupportedVersion(raw string) error {
-	// Multipass continuously evolves, but we expect at least v1.13.0 for JSON outputs used here.
-	min := version.Must(version.NewVersion("1.13.0"))
+// Functions returns the provider-defined functions.
+func (p *MultipassProvider) Functions(_ context.Context) []func() function.Function {
+	return []func() function.Function{
+		NewParseSizeFunction,
+		NewFormatSizeFunction,
+	}
+}
+
+// waitForDaemon probes the daemon every interval until it answers or
+// window elapses. Errors other than an unreachable daemon, such as a missing
+// binary, are returned straight away.
+func waitForDaemon(ctx context.Context, client multipasscli.Client, window, interval time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, window)
+	defer cancel()
+
+	for {
+		err := probeDaemon(ctx, client)
+		switch {
+		case err == nil:
+			return nil
+		case !errors.Is(err, multipasscli.ErrDaemonUnavailable):
+			return err
+		}
+		tflog.Debug(ctx, "Waiting for the Multipass daemon", map[string]any{"error": err.Error()})
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(interval):
+		}
+	}
+}
+
+// probeDaemon checks that the daemon reports its version and lists the
+// instances within daemonProbeTimeout. A daemon that answers version while
+// it is still starting up can hang on list, which is what every resource
+// runs first. Failures that waiting may fix wrap ErrDaemonUnavailable.
+func probeDaemon(ctx context.Context, client multipasscli.Client) error {
+	ver, err := client.VersionInfo(ctx)
+	switch {
+	case err == nil && ver.Daemon == "",
+		errors.Is(err, multipasscli.ErrTimeout) && ctx.Err() != nil:
+		return fmt.Errorf("%w. %s", multipasscli.ErrDaemonUnavailable, multipasscli.DaemonHint(runtime.GOOS))
+	case err != nil:
+		return err
+	}
+
+	probeCtx, cancel := context.WithTimeout(ctx, daemonProbeTimeout)
+	defer cancel()
+	if _, err := client.ListInstances(probeCtx, true); err != nil {
+		if errors.Is(err, multipasscli.ErrTimeout) && probeCtx.Err() != nil {
+			return fmt.Errorf("%w: multipass list did not answer within %s. %s",
+				multipasscli.ErrDaemonUnavailable, daemonProbeTimeout, multipasscli.DaemonHint(runtime.GOOS))
+		}
+		return err
+	}
+	return nil
+}
+
+// checkMultipassVersion parses raw and checks it against constraints. The
+// pre-release and build suffixes of development builds such as
+// 1.16.0-dev.123+g456 are ignored, since go-version never lets pre-releases
+// satisfy plain constraints. The parsed version is nil when raw cannot be
+// parsed.
+func checkMultipassVersion(raw string, constraints version.Constraints) (*version.Version, error) {
 	current, err := version.NewVersion(raw)
 	if err != nil {
-		return fmt.Errorf("could not parse multipass version %q: %w", raw, err)
+		return nil, fmt.Errorf("could not parse multipass version %q: %w", raw, err)
 	}
 
-	if current.LessThan(min) {
-		return fmt.Errorf("multipass version %s is older than supported minimum %s", current.Original(), min.Original())
+	if !constraints.Check(current.Core()) {
+		return current, fmt.Errorf("multipass version %s does not satisfy %q", current.Original(), constraints.String())
 	}
-	return nil
+	return current, nil
 }
@@ -0,0 +1,152 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/terraform-plugin-framework/provider"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+func TestCheckMultipassVersion(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		raw        string
+		constraint string
+		wantParsed bool
+		wantErr    bool
+	}{
+		{raw: "1.14.1", constraint: ">= 1.14, < 2.0", wantParsed: true},
+		{raw: "1.13.1+mac", constraint: ">= 1.14, < 2.0", wantParsed: true, wantErr: true},
+		{raw: "2.0.0", constraint: ">= 1.14, < 2.0", wantParsed: true, wantErr: true},
+		{raw: "1.16.0-dev.123+g456", constraint: ">= 1.14, < 2.0", wantParsed: true},
+		{raw: "1.16.0-dev.123+g456", constraint: ">= " + minimumMultipassVersion, wantParsed: true},
+		{raw: "not-a-version", constraint: ">= 1.14", wantErr: true},
+	}
+	for _, tc := range cases {
+		constraints, err := version.NewConstraint(tc.constraint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := checkMultipassVersion(tc.raw, constraints)
+		if (got != nil) != tc.wantParsed {
+			t.Errorf("%s against %q: parsed = %v, want %v", tc.raw, tc.constraint, got, tc.wantParsed)
+		}
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s against %q: err = %v, want error %v", tc.raw, tc.constraint, err, tc.wantErr)
+		}
+	}
+}
+
+func TestNewWithClient(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	host := newFakeHost()
+	p := NewWithClient("test", host)()
+
+	var schemaResp provider.SchemaResponse
+	p.Schema(ctx, provider.SchemaRequest{}, &schemaResp)
+	objectType := schemaResp.Schema.Type().TerraformType(ctx).(tftypes.Object)
+	attrs := map[string]tftypes.Value{}
+	for name, attrType := range objectType.AttributeTypes {
+		attrs[name] = tftypes.NewValue(attrType, nil)
+	}
+
+	var resp provider.ConfigureResponse
+	p.Configure(ctx, provider.ConfigureRequest{
+		Config: tfsdk.Config{Schema: schemaResp.Schema, Raw: tftypes.NewValue(objectType, attrs)},
+	}, &resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+	}
+	data, ok := resp.ResourceData.(providerData)
+	if !ok || data.client != host {
+		t.Fatalf("expected the injected client to reach resources, got %#v", resp.ResourceData)
+	}
+	if data.multipassVersion == nil || data.multipassVersion.String() != "1.15.0" {
+		t.Fatalf("expected the version to be read from the injected client, got %v", data.multipassVersion)
+	}
+	if data.driver.name != "qemu" {
+		t.Fatalf("expected the driver to be read from the injected client, got %q", data.driver.name)
+	}
+}
+
+func TestWaitForDaemon(t *testing.T) {
+	t.Parallel()
+
+	t.Run("retries until the daemon answers", func(t *testing.T) {
+		attempts := 0
+		client := &fakeClient{versionInfo: func() (models.VersionInfo, error) {
+			attempts++
+			switch attempts {
+			case 1:
+				return models.VersionInfo{}, multipasscli.ErrDaemonUnavailable
+			case 2:
+				return models.VersionInfo{Client: "1.15.0"}, nil
+			}
+			return models.VersionInfo{Client: "1.15.0", Daemon: "1.15.0"}, nil
+		}}
+		if err := waitForDaemon(context.Background(), client, time.Minute, time.Millisecond); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if attempts != 3 {
+			t.Fatalf("expected 3 attempts, got %d", attempts)
+		}
+	})
+
+	t.Run("explains how to start the daemon", func(t *testing.T) {
+		client := &fakeClient{versionInfo: func() (models.VersionInfo, error) {
+			return models.VersionInfo{Client: "1.15.0"}, nil
+		}}
+		err := waitForDaemon(context.Background(), client, 20*time.Millisecond, time.Millisecond)
+		if !errors.Is(err, multipasscli.ErrDaemonUnavailable) || !strings.Contains(err.Error(), multipasscli.DaemonHint(runtime.GOOS)) {
+			t.Fatalf("expected an unreachable daemon with a hint, got %v", err)
+		}
+	})
+
+	t.Run("waits for list to answer", func(t *testing.T) {
+		lists := 0
+		client := &fakeClient{listInstances: func(refresh bool) ([]models.Instance, error) {
+			if !refresh {
+				t.Error("expected the probe to bypass the listing cache")
+			}
+			lists++
+			if lists < 3 {
+				return nil, multipasscli.ErrDaemonUnavailable
+			}
+			return nil, nil
+		}}
+		if err := waitForDaemon(context.Background(), client, time.Minute, time.Millisecond); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []string{"version", "list", "version", "list", "version", "list"}
+		if diff := cmp.Diff(want, client.recorded()); diff != "" {
+			t.Fatalf("unexpected calls (-want +got): %s", diff)
+		}
+	})
+
+	t.Run("does not retry other errors", func(t *testing.T) {
+		client := &fakeClient{versionInfo: func() (models.VersionInfo, error) {
+			return models.VersionInfo{}, exec.ErrNotFound
+		}}
+		if err := waitForDaemon(context.Background(), client, time.Minute, time.Millisecond); !errors.Is(err, exec.ErrNotFound) {
+			t.Fatalf("expected the lookup error, got %v", err)
+		}
+		if n := len(client.recorded()); n != 1 {
+			t.Fatalf("expected a single attempt, got %d", n)
+		}
+	})
+}
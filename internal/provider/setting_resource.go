@@ -0,0 +1,253 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+var (
+	_ resource.Resource                = (*settingResource)(nil)
+	_ resource.ResourceWithConfigure   = (*settingResource)(nil)
+	_ resource.ResourceWithModifyPlan  = (*settingResource)(nil)
+	_ resource.ResourceWithImportState = (*settingResource)(nil)
+)
+
+// primaryNameSetting is the settings key `multipass set` uses for the
+// primary instance.
+const primaryNameSetting = "client.primary-name"
+
+// NewSettingResource instantiates the setting resource.
+func NewSettingResource() resource.Resource {
+	return &settingResource{}
+}
+
+type settingResource struct {
+	client        multipasscli.Client
+	primaryClaims *primaryClaims
+}
+
+type settingResourceModel struct {
+	ID               types.String `tfsdk:"id"`
+	Key              types.String `tfsdk:"key"`
+	Value            types.String `tfsdk:"value"`
+	RestoreOnDestroy types.Bool   `tfsdk:"restore_on_destroy"`
+	PreviousValue    types.String `tfsdk:"previous_value"`
+}
+
+func (r *settingResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_setting"
+}
+
+func (r *settingResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Manages a single Multipass setting via `multipass set`.",
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:    true,
+				Description: "The setting key.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"key": schema.StringAttribute{
+				Required:    true,
+				Description: "Setting key, e.g. `local.bridged-network`. Changing forces recreation.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"value": schema.StringAttribute{
+				Required:    true,
+				Description: "Value to set, spelled the way `multipass get` prints it (e.g. `true` rather than `on`) so refresh does not report drift.",
+			},
+			"restore_on_destroy": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Set the key back to previous_value when the resource is destroyed. When false, destroy leaves the setting as is (default: true).",
+			},
+			"previous_value": schema.StringAttribute{
+				Computed:    true,
+				Description: "Value the key had before the resource was created. Null after an import.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+		},
+	}
+}
+
+func (r *settingResource) Configure(_ context.Context, req resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	data := req.ProviderData.(providerData)
+	r.client = data.client
+	r.primaryClaims = data.primaryClaims
+}
+
+// ModifyPlan rejects managing client.primary-name alongside instances that
+// set primary = true or a multipass_primary; each apply would undo the other.
+func (r *settingResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var key types.String
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("key"), &key)...)
+	if resp.Diagnostics.HasError() || key.ValueString() != primaryNameSetting {
+		return
+	}
+
+	others := r.primaryClaims.claim(primaryClaim{resourceType: "multipass_setting", name: primaryNameSetting}, req.State.Raw.IsNull())
+	if len(others) > 0 {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("key"),
+			"Conflicting primary instance settings",
+			fmt.Sprintf("This resource manages %s, but %s also sets the primary instance. Use only one of them.", primaryNameSetting, describePrimaryClaims(others)),
+		)
+	}
+}
+
+func (r *settingResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	if r.client == nil {
+		resp.Diagnostics.AddError("Client not configured", "Multipass client is nil.")
+		return
+	}
+
+	var plan settingResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ctx, done := startOperation(ctx, "multipass_setting", "create", "")
+	defer done(&resp.Diagnostics)
+
+	key := plan.Key.ValueString()
+	previous, err := r.client.GetSetting(ctx, key)
+	if errors.Is(err, multipasscli.ErrNotFound) {
+		resp.Diagnostics.AddAttributeError(path.Root("key"), "Unknown setting", fmt.Sprintf("Multipass does not recognise the setting %q. Run `multipass get --keys` to list the available keys.", key))
+		return
+	}
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read setting", err.Error())
+		return
+	}
+
+	if err := r.client.SetSetting(ctx, key, plan.Value.ValueString()); err != nil {
+		resp.Diagnostics.AddError("Failed to set setting", err.Error())
+		return
+	}
+
+	plan.ID = types.StringValue(key)
+	plan.PreviousValue = types.StringValue(previous)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *settingResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	if r.client == nil {
+		resp.Diagnostics.AddError("Client not configured", "Multipass client is nil.")
+		return
+	}
+
+	var state settingResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ctx, done := startOperation(ctx, "multipass_setting", "read", "")
+	defer done(&resp.Diagnostics)
+
+	key := state.Key.ValueString()
+	value, err := r.client.GetSetting(ctx, key)
+	if errors.Is(err, multipasscli.ErrNotFound) {
+		// Keys depend on the driver and platform, e.g. after switching
+		// local.driver.
+		tflog.Info(ctx, "Multipass setting no longer exists", map[string]any{"key": key})
+		resp.State.RemoveResource(ctx)
+		return
+	}
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read setting", err.Error())
+		return
+	}
+
+	state.ID = types.StringValue(key)
+	state.Value = types.StringValue(value)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+}
+
+func (r *settingResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	if r.client == nil {
+		resp.Diagnostics.AddError("Client not configured", "Multipass client is nil.")
+		return
+	}
+
+	var plan settingResourceModel
+	var state settingResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ctx, done := startOperation(ctx, "multipass_setting", "update", "")
+	defer done(&resp.Diagnostics)
+
+	if !plan.Value.Equal(state.Value) {
+		if err := r.client.SetSetting(ctx, plan.Key.ValueString(), plan.Value.ValueString()); err != nil {
+			resp.Diagnostics.AddError("Failed to set setting", err.Error())
+			return
+		}
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+// Delete sets the key back to previous_value unless restore_on_destroy is
+// false. Imported settings have no previous value and are left as is.
+func (r *settingResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	if r.client == nil {
+		return
+	}
+
+	var state settingResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ctx, done := startOperation(ctx, "multipass_setting", "delete", "")
+	defer done(&resp.Diagnostics)
+
+	restore := state.RestoreOnDestroy.IsNull() || state.RestoreOnDestroy.ValueBool()
+	if !restore || state.PreviousValue.IsNull() {
+		return
+	}
+
+	key := state.Key.ValueString()
+	err := r.client.SetSetting(ctx, key, state.PreviousValue.ValueString())
+	if errors.Is(err, multipasscli.ErrNotFound) {
+		tflog.Info(ctx, "Multipass setting no longer exists; nothing to restore", map[string]any{"key": key})
+		return
+	}
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to restore setting", fmt.Sprintf("Setting %s back to %q: %s", key, state.PreviousValue.ValueString(), err))
+	}
+}
+
+func (r *settingResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("id"), req.ID)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("key"), req.ID)...)
+}
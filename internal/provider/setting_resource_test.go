@@ -0,0 +1,206 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+// settingPlan returns a tfsdk.Plan for model using the resource schema.
+func settingPlan(t *testing.T, model settingResourceModel) tfsdk.Plan {
+	t.Helper()
+	var schemaResp resource.SchemaResponse
+	(&settingResource{}).Schema(context.Background(), resource.SchemaRequest{}, &schemaResp)
+	plan := tfsdk.Plan{Schema: schemaResp.Schema}
+	if diags := plan.Set(context.Background(), &model); diags.HasError() {
+		t.Fatalf("set plan: %v", diags)
+	}
+	return plan
+}
+
+func settingModel(key, value string) settingResourceModel {
+	return settingResourceModel{
+		ID:               types.StringUnknown(),
+		Key:              types.StringValue(key),
+		Value:            types.StringValue(value),
+		RestoreOnDestroy: types.BoolNull(),
+		PreviousValue:    types.StringUnknown(),
+	}
+}
+
+// settingsClient serves settings from a map, like the Multipass daemon.
+func settingsClient(values map[string]string) *fakeClient {
+	return &fakeClient{
+		getSetting: func(key string) (string, error) {
+			value, ok := values[key]
+			if !ok {
+				return "", multipasscli.ErrNotFound
+			}
+			return value, nil
+		},
+		setSetting: func(key, value string) error {
+			if _, ok := values[key]; !ok {
+				return multipasscli.ErrNotFound
+			}
+			values[key] = value
+			return nil
+		},
+	}
+}
+
+func TestSettingResourceCreate(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("records previous value", func(t *testing.T) {
+		values := map[string]string{"local.privileged-mounts": "false"}
+		client := settingsClient(values)
+		r := &settingResource{client: client}
+		plan := settingPlan(t, settingModel("local.privileged-mounts", "true"))
+		resp := resource.CreateResponse{State: tfsdk.State{Schema: plan.Schema}}
+		r.Create(ctx, resource.CreateRequest{Plan: plan}, &resp)
+		if resp.Diagnostics.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+		}
+
+		want := []string{"get local.privileged-mounts", "set local.privileged-mounts=true"}
+		if diff := cmp.Diff(want, client.recorded()); diff != "" {
+			t.Fatalf("unexpected calls (-want +got): %s", diff)
+		}
+		var got settingResourceModel
+		resp.Diagnostics.Append(resp.State.Get(ctx, &got)...)
+		if got.ID.ValueString() != "local.privileged-mounts" || got.PreviousValue.ValueString() != "false" {
+			t.Fatalf("unexpected state: id %q, previous_value %q", got.ID.ValueString(), got.PreviousValue.ValueString())
+		}
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		client := settingsClient(map[string]string{})
+		r := &settingResource{client: client}
+		plan := settingPlan(t, settingModel("local.bogus", "1"))
+		resp := resource.CreateResponse{State: tfsdk.State{Schema: plan.Schema}}
+		r.Create(ctx, resource.CreateRequest{Plan: plan}, &resp)
+		if !resp.Diagnostics.HasError() || resp.Diagnostics[0].Summary() != "Unknown setting" {
+			t.Fatalf("expected an unknown setting error, got %v", resp.Diagnostics)
+		}
+		if diff := cmp.Diff([]string{"get local.bogus"}, client.recorded()); diff != "" {
+			t.Fatalf("unexpected calls (-want +got): %s", diff)
+		}
+	})
+}
+
+func TestSettingResourceRead(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	model := settingModel("local.bridged-network", "en0")
+	model.ID = types.StringValue("local.bridged-network")
+	model.PreviousValue = types.StringValue("")
+	plan := settingPlan(t, model)
+
+	t.Run("drift", func(t *testing.T) {
+		r := &settingResource{client: settingsClient(map[string]string{"local.bridged-network": "en1"})}
+		state := tfsdk.State{Schema: plan.Schema, Raw: plan.Raw}
+		resp := resource.ReadResponse{State: state}
+		r.Read(ctx, resource.ReadRequest{State: state}, &resp)
+		var got settingResourceModel
+		resp.Diagnostics.Append(resp.State.Get(ctx, &got)...)
+		if resp.Diagnostics.HasError() || got.Value.ValueString() != "en1" {
+			t.Fatalf("expected value en1, got %q (%v)", got.Value.ValueString(), resp.Diagnostics)
+		}
+	})
+
+	t.Run("key gone", func(t *testing.T) {
+		r := &settingResource{client: settingsClient(map[string]string{})}
+		state := tfsdk.State{Schema: plan.Schema, Raw: plan.Raw}
+		resp := resource.ReadResponse{State: state}
+		r.Read(ctx, resource.ReadRequest{State: state}, &resp)
+		if resp.Diagnostics.HasError() || !resp.State.Raw.IsNull() {
+			t.Fatalf("expected the resource to be removed, got %v", resp.Diagnostics)
+		}
+	})
+}
+
+func TestSettingResourceDelete(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		restore  types.Bool
+		previous types.String
+		want     []string
+	}{
+		{name: "restores by default", restore: types.BoolNull(), previous: types.StringValue("primary"), want: []string{"set client.primary-name=primary"}},
+		{name: "restore disabled", restore: types.BoolValue(false), previous: types.StringValue("primary")},
+		{name: "imported", restore: types.BoolNull(), previous: types.StringNull()},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := settingsClient(map[string]string{primaryNameSetting: "dev"})
+			r := &settingResource{client: client}
+			model := settingModel(primaryNameSetting, "dev")
+			model.ID = types.StringValue(primaryNameSetting)
+			model.RestoreOnDestroy = tc.restore
+			model.PreviousValue = tc.previous
+			plan := settingPlan(t, model)
+			state := tfsdk.State{Schema: plan.Schema, Raw: plan.Raw}
+
+			resp := resource.DeleteResponse{State: state}
+			r.Delete(context.Background(), resource.DeleteRequest{State: state}, &resp)
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+			}
+			if diff := cmp.Diff(tc.want, client.recorded()); diff != "" {
+				t.Fatalf("unexpected calls (-want +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestSettingResourceModifyPlanPrimaryConflict(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	modifyPlan := func(r *settingResource, key string) resource.ModifyPlanResponse {
+		plan := settingPlan(t, settingModel(key, "dev"))
+		resp := resource.ModifyPlanResponse{Plan: plan}
+		r.ModifyPlan(ctx, resource.ModifyPlanRequest{Plan: plan, State: tfsdk.State{Schema: plan.Schema, Raw: tftypes.NewValue(plan.Raw.Type(), nil)}}, &resp)
+		return resp
+	}
+
+	t.Run("setting planned after instance", func(t *testing.T) {
+		claims := &primaryClaims{}
+		claims.claim(primaryClaim{resourceType: "multipass_instance", name: "dev"}, true)
+		r := &settingResource{primaryClaims: claims}
+		if resp := modifyPlan(r, "local.driver"); resp.Diagnostics.HasError() {
+			t.Fatalf("other keys should not conflict: %v", resp.Diagnostics)
+		}
+		if resp := modifyPlan(r, primaryNameSetting); !resp.Diagnostics.HasError() {
+			t.Fatal("expected a conflict with the primary instance")
+		}
+	})
+
+	t.Run("instance planned after setting", func(t *testing.T) {
+		claims := &primaryClaims{}
+		if resp := modifyPlan(&settingResource{primaryClaims: claims}, primaryNameSetting); resp.Diagnostics.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+		}
+
+		r := &instanceResource{primaryClaims: claims}
+		state := instanceState(t, r, map[string]tftypes.Value{"primary": tftypes.NewValue(tftypes.Bool, true)})
+		plan := tfsdk.Plan{Schema: state.Schema, Raw: state.Raw}
+		resp := resource.ModifyPlanResponse{Plan: plan}
+		r.ModifyPlan(ctx, resource.ModifyPlanRequest{Plan: plan, State: tfsdk.State{Schema: state.Schema, Raw: tftypes.NewValue(state.Raw.Type(), nil)}}, &resp)
+		if !resp.Diagnostics.HasError() {
+			t.Fatal("expected the instance to report the conflict")
+		}
+	})
+}
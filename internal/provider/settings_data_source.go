@@ -0,0 +1,134 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+var (
+	_ datasource.DataSource              = (*settingsDataSource)(nil)
+	_ datasource.DataSourceWithConfigure = (*settingsDataSource)(nil)
+)
+
+// NewSettingsDataSource returns the settings data source.
+func NewSettingsDataSource() datasource.DataSource {
+	return &settingsDataSource{}
+}
+
+type settingsDataSource struct {
+	client multipasscli.Client
+	// driver is the driver read during provider configuration.
+	driver string
+}
+
+type settingsDataSourceModel struct {
+	Keys   types.List `tfsdk:"keys"`
+	Strict types.Bool `tfsdk:"strict"`
+	Values types.Map  `tfsdk:"values"`
+	// Driver is filled from the provider configuration.
+	Driver types.String `tfsdk:"driver"`
+}
+
+func (d *settingsDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_settings"
+}
+
+func (d *settingsDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Reads Multipass settings via `multipass get`.",
+		Attributes: map[string]schema.Attribute{
+			"keys": schema.ListAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "Setting keys to read, e.g. `local.driver`. When omitted, every key reported by `multipass get --keys` is read.",
+			},
+			"strict": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Fail when a requested key is not recognised by Multipass instead of omitting it from values (default: false).",
+			},
+			"values": schema.MapAttribute{
+				Computed:    true,
+				ElementType: types.StringType,
+				Description: "Setting values keyed by setting key.",
+			},
+			"driver": schema.StringAttribute{
+				Computed:    true,
+				Description: "Virtualization driver (`local.driver`) the provider detected during configuration, such as `qemu`, `hyperv` or `lxd`. Null when it could not be read, including when `defer_binary_check` is set.",
+			},
+		},
+	}
+}
+
+func (d *settingsDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, _ *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	data := req.ProviderData.(providerData)
+	d.client = data.client
+	d.driver = data.driver.name
+}
+
+func (d *settingsDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	if d.client == nil {
+		resp.Diagnostics.AddError("Client not configured", "Multipass client is nil.")
+		return
+	}
+
+	var config settingsDataSourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	var keys []string
+	if !config.Keys.IsNull() && !config.Keys.IsUnknown() {
+		resp.Diagnostics.Append(config.Keys.ElementsAs(ctx, &keys, false)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	} else {
+		listed, err := d.client.ListSettingKeys(ctx)
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to list setting keys", err.Error())
+			return
+		}
+		keys = listed
+	}
+
+	values := make(map[string]string, len(keys))
+	for _, key := range keys {
+		value, err := d.client.GetSetting(ctx, key)
+		if errors.Is(err, multipasscli.ErrNotFound) {
+			if config.Strict.ValueBool() {
+				resp.Diagnostics.AddAttributeError(path.Root("keys"), "Unknown setting key", fmt.Sprintf("Multipass does not recognise the setting key %q; run `multipass get --keys` to see the available keys.", key))
+				return
+			}
+			tflog.Debug(ctx, "Omitting unknown Multipass setting key", map[string]any{"key": key})
+			continue
+		}
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to read setting", fmt.Sprintf("Reading %q: %s", key, err))
+			return
+		}
+		values[key] = value
+	}
+
+	mapValue, diags := types.MapValueFrom(ctx, types.StringType, values)
+	resp.Diagnostics.Append(diags...)
+	config.Values = mapValue
+	config.Driver = types.StringNull()
+	if d.driver != "" {
+		config.Driver = types.StringValue(d.driver)
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &config)...)
+}
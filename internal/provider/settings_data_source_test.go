@@ -0,0 +1,98 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+// readSettings runs settingsDataSource.Read for config and returns the
+// response.
+func readSettings(t *testing.T, client *fakeClient, config settingsDataSourceModel) datasource.ReadResponse {
+	t.Helper()
+	ctx := context.Background()
+
+	d := &settingsDataSource{client: client}
+	var schemaResp datasource.SchemaResponse
+	d.Schema(ctx, datasource.SchemaRequest{}, &schemaResp)
+
+	config.Values = types.MapNull(types.StringType)
+	configState := tfsdk.State{Schema: schemaResp.Schema}
+	if diags := configState.Set(ctx, &config); diags.HasError() {
+		t.Fatalf("set config: %v", diags)
+	}
+	resp := datasource.ReadResponse{State: tfsdk.State{
+		Schema: schemaResp.Schema,
+		Raw:    tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil),
+	}}
+	d.Read(ctx, datasource.ReadRequest{Config: tfsdk.Config{Schema: schemaResp.Schema, Raw: configState.Raw}}, &resp)
+	return resp
+}
+
+func TestSettingsDataSourceRead(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	settings := map[string]string{
+		"local.driver":            "qemu",
+		"local.privileged-mounts": "true",
+	}
+	newClient := func() *fakeClient {
+		return &fakeClient{
+			settingKeys: []string{"local.driver", "local.privileged-mounts"},
+			getSetting: func(key string) (string, error) {
+				if v, ok := settings[key]; ok {
+					return v, nil
+				}
+				return "", multipasscli.ErrNotFound
+			},
+		}
+	}
+	values := func(resp datasource.ReadResponse) map[string]string {
+		var state settingsDataSourceModel
+		resp.State.Get(ctx, &state)
+		got := map[string]string{}
+		state.Values.ElementsAs(ctx, &got, false)
+		return got
+	}
+	keys, _ := types.ListValueFrom(ctx, types.StringType, []string{"local.driver", "local.bogus"})
+
+	t.Run("all keys", func(t *testing.T) {
+		client := newClient()
+		resp := readSettings(t, client, settingsDataSourceModel{Keys: types.ListNull(types.StringType), Strict: types.BoolNull()})
+		if resp.Diagnostics.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+		}
+		if diff := cmp.Diff(settings, values(resp)); diff != "" {
+			t.Fatalf("unexpected values (-want +got): %s", diff)
+		}
+		want := []string{"get --keys", "get local.driver", "get local.privileged-mounts"}
+		if diff := cmp.Diff(want, client.recorded()); diff != "" {
+			t.Fatalf("unexpected calls (-want +got): %s", diff)
+		}
+	})
+
+	t.Run("unknown key omitted", func(t *testing.T) {
+		resp := readSettings(t, newClient(), settingsDataSourceModel{Keys: keys, Strict: types.BoolNull()})
+		if resp.Diagnostics.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+		}
+		if diff := cmp.Diff(map[string]string{"local.driver": "qemu"}, values(resp)); diff != "" {
+			t.Fatalf("unexpected values (-want +got): %s", diff)
+		}
+	})
+
+	t.Run("unknown key strict", func(t *testing.T) {
+		resp := readSettings(t, newClient(), settingsDataSourceModel{Keys: keys, Strict: types.BoolValue(true)})
+		if !resp.Diagnostics.HasError() {
+			t.Fatalf("expected an error for an unknown key")
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package provider
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// sizeRegex matches Multipass size notation: a number with an optional
+// fraction, an optional K/M/G/T unit and an optional B or iB suffix. Units
+// are binary and case-insensitive, as in `multipass launch`.
+var sizeRegex = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)([KMGT]?)(?:I?B)?$`)
+
+// sizeUnits lists the units formatSize picks from, largest first.
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"T", 1 << 40},
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+}
+
+// parseSize converts a Multipass size such as "512M" or "1.5G" to bytes.
+// Fractions of a byte are truncated.
+func parseSize(raw string) (int64, error) {
+	m := sizeRegex.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(raw)))
+	if m == nil {
+		return 0, fmt.Errorf("%q is not a Multipass size, expected a number with an optional K, M, G or T unit such as 512M or 1.5G", raw)
+	}
+
+	value, ok := new(big.Rat).SetString(m[1])
+	if !ok {
+		return 0, fmt.Errorf("%q is not a valid number", m[1])
+	}
+	multiplier := int64(1)
+	for _, unit := range sizeUnits {
+		if unit.suffix == m[2] {
+			multiplier = unit.bytes
+		}
+	}
+	value.Mul(value, new(big.Rat).SetInt64(multiplier))
+
+	bytes := new(big.Int).Quo(value.Num(), value.Denom())
+	if !bytes.IsInt64() {
+		return 0, fmt.Errorf("%q is larger than %d bytes", raw, int64(math.MaxInt64))
+	}
+	return bytes.Int64(), nil
+}
+
+// formatSize renders bytes in the largest unit that divides it exactly,
+// rounding down to whole kibibytes first, so 1610612736 becomes "1536M".
+// The result is accepted by the memory and disk arguments.
+func formatSize(bytes int64) (string, error) {
+	if bytes < 0 {
+		return "", fmt.Errorf("size must not be negative, got %d", bytes)
+	}
+	bytes &^= 1<<10 - 1
+	if bytes == 0 {
+		return "0K", nil
+	}
+	for _, unit := range sizeUnits[:len(sizeUnits)-1] {
+		if bytes%unit.bytes == 0 {
+			return fmt.Sprintf("%d%s", bytes/unit.bytes, unit.suffix), nil
+		}
+	}
+	return fmt.Sprintf("%dK", bytes>>10), nil
+}
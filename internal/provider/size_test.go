@@ -0,0 +1,96 @@
+package provider
+
+import (
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		raw     string
+		want    int64
+		wantErr bool
+	}{
+		{raw: "1024", want: 1024},
+		{raw: "1K", want: 1 << 10},
+		{raw: "512M", want: 512 << 20},
+		{raw: "1.5G", want: 1610612736},
+		{raw: "2T", want: 2 << 40},
+		{raw: "4g", want: 4 << 30},
+		{raw: "4GB", want: 4 << 30},
+		{raw: "4GiB", want: 4 << 30},
+		{raw: " 8G ", want: 8 << 30},
+		{raw: "0.3K", want: 307},
+		{raw: "1.5", want: 1},
+		{raw: "0", want: 0},
+		{raw: "", wantErr: true},
+		{raw: "G", wantErr: true},
+		{raw: "1.G", wantErr: true},
+		{raw: ".5G", wantErr: true},
+		{raw: "-1G", wantErr: true},
+		{raw: "1P", wantErr: true},
+		{raw: "1 G", wantErr: true},
+		{raw: "lots", wantErr: true},
+		{raw: "8388608T", wantErr: true},
+	}
+	for _, tc := range cases {
+		got, err := parseSize(tc.raw)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("parseSize(%q) error = %v, want error %v", tc.raw, err, tc.wantErr)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("parseSize(%q) = %d, want %d", tc.raw, got, tc.want)
+		}
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		bytes   int64
+		want    string
+		wantErr bool
+	}{
+		{bytes: 0, want: "0K"},
+		{bytes: 1000, want: "0K"},
+		{bytes: 1 << 10, want: "1K"},
+		{bytes: 1536, want: "1K"},
+		{bytes: 1610612736, want: "1536M"},
+		{bytes: 1 << 30, want: "1G"},
+		{bytes: 1<<30 + 1, want: "1G"},
+		{bytes: 3 << 40, want: "3T"},
+		{bytes: 1<<40 + 1<<10, want: "1073741825K"},
+		{bytes: -1, wantErr: true},
+	}
+	for _, tc := range cases {
+		got, err := formatSize(tc.bytes)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("formatSize(%d) error = %v, want error %v", tc.bytes, err, tc.wantErr)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("formatSize(%d) = %q, want %q", tc.bytes, got, tc.want)
+		}
+		if err == nil && !memoryRegex.MatchString(got) {
+			t.Errorf("formatSize(%d) = %q is not accepted by memory and disk", tc.bytes, got)
+		}
+	}
+}
+
+func TestFormatSize_roundTrip(t *testing.T) {
+	t.Parallel()
+
+	for _, raw := range []string{"1K", "512M", "1536M", "8G", "2T"} {
+		bytes, err := parseSize(raw)
+		if err != nil {
+			t.Fatalf("parseSize(%q): %v", raw, err)
+		}
+		got, err := formatSize(bytes)
+		if err != nil || got != raw {
+			t.Errorf("formatSize(parseSize(%q)) = %q, %v", raw, got, err)
+		}
+	}
+}
@@ -2,10 +2,13 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/hashicorp/go-version"
 	"github.com/hashicorp/terraform-plugin-framework-timeouts/resource/timeouts"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
@@ -15,12 +18,14 @@ import (
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
 
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
 	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
 )
 
 var (
 	_ resource.Resource                = (*snapshotResource)(nil)
 	_ resource.ResourceWithConfigure   = (*snapshotResource)(nil)
+	_ resource.ResourceWithModifyPlan  = (*snapshotResource)(nil)
 	_ resource.ResourceWithImportState = (*snapshotResource)(nil)
 )
 
@@ -32,14 +37,20 @@ func NewSnapshotResource() resource.Resource {
 type snapshotResource struct {
 	client         multipasscli.Client
 	commandTimeout time.Duration
+	// multipassVersion is the detected CLI version, or nil when unknown.
+	multipassVersion *version.Version
 }
 
 type snapshotResourceModel struct {
-	ID       types.String   `tfsdk:"id"`
-	Instance types.String   `tfsdk:"instance"`
-	Name     types.String   `tfsdk:"name"`
-	Comment  types.String   `tfsdk:"comment"`
-	Timeouts timeouts.Value `tfsdk:"timeouts"`
+	ID           types.String   `tfsdk:"id"`
+	Instance     types.String   `tfsdk:"instance"`
+	Name         types.String   `tfsdk:"name"`
+	Comment      types.String   `tfsdk:"comment"`
+	Parent       types.String   `tfsdk:"parent"`
+	CreatedAt    types.String   `tfsdk:"created_at"`
+	StopInstance types.Bool     `tfsdk:"stop_instance"`
+	Cascade      types.Bool     `tfsdk:"cascade_delete"`
+	Timeouts     timeouts.Value `tfsdk:"timeouts"`
 }
 
 func (r *snapshotResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
@@ -79,6 +90,28 @@ func (r *snapshotResource) Schema(ctx context.Context, _ resource.SchemaRequest,
 					stringplanmodifier.RequiresReplace(),
 				},
 			},
+			"parent": schema.StringAttribute{
+				Computed:    true,
+				Description: "Name of the parent snapshot, empty for the first snapshot of an instance.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"created_at": schema.StringAttribute{
+				Computed:    true,
+				Description: "Snapshot creation time in RFC3339 format (UTC).",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"stop_instance": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Stop a running or suspended instance before taking the snapshot and return it to that state afterwards (default: false). Only affects creation.",
+			},
+			"cascade_delete": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Delete the snapshot's descendants, deepest first, before deleting the snapshot itself (default: false).",
+			},
 		},
 		Blocks: map[string]schema.Block{
 			"timeouts": timeouts.Block(ctx, timeouts.Opts{
@@ -96,6 +129,46 @@ func (r *snapshotResource) Configure(_ context.Context, req resource.ConfigureRe
 	data := req.ProviderData.(providerData)
 	r.client = data.client
 	r.commandTimeout = data.commandTimeout
+	r.multipassVersion = data.multipassVersion
+}
+
+// ModifyPlan rejects a snapshot name that is already taken on the instance,
+// which `multipass snapshot` would only report at apply time, possibly after
+// stop_instance has already stopped the instance.
+func (r *snapshotResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if r.client == nil || req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var instance, name types.String
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("instance"), &instance)...)
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("name"), &name)...)
+	if resp.Diagnostics.HasError() || instance.IsNull() || instance.IsUnknown() || name.IsNull() || name.IsUnknown() {
+		return
+	}
+	if !req.State.Raw.IsNull() {
+		var state snapshotResourceModel
+		resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+		if resp.Diagnostics.HasError() || (state.Instance.Equal(instance) && state.Name.Equal(name)) {
+			return
+		}
+	}
+
+	snapshots, err := r.client.ListSnapshots(ctx, instance.ValueString())
+	if err != nil {
+		// A missing instance may be created in the same apply, and the
+		// daemon may be unavailable at plan time; apply reports either.
+		tflog.Debug(ctx, "Skipping snapshot name check", map[string]any{"instance": instance.ValueString(), "error": err.Error()})
+		return
+	}
+	if findSnapshot(snapshots, name.ValueString()) != nil {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("name"),
+			"Snapshot already exists",
+			fmt.Sprintf("Instance %q already has a snapshot named %q. Choose a different name, or bring the existing snapshot under management with `terraform import <address> %s.%s`.",
+				instance.ValueString(), name.ValueString(), instance.ValueString(), name.ValueString()),
+		)
+	}
 }
 
 func (r *snapshotResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
@@ -110,6 +183,9 @@ func (r *snapshotResource) Create(ctx context.Context, req resource.CreateReques
 		return
 	}
 
+	ctx, done := startOperation(ctx, "multipass_snapshot", "create", plan.Instance.ValueString())
+	defer done(&resp.Diagnostics)
+
 	createTimeout, diags := plan.Timeouts.Create(ctx, r.commandTimeout)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
@@ -118,6 +194,11 @@ func (r *snapshotResource) Create(ctx context.Context, req resource.CreateReques
 	ctx, cancel := context.WithTimeout(ctx, createTimeout)
 	defer cancel()
 
+	if !supportsFeature(r.multipassVersion, featureSnapshots) {
+		resp.Diagnostics.AddError("Snapshots not supported", unsupportedFeatureDetail(r.multipassVersion, featureSnapshots))
+		return
+	}
+
 	instance := plan.Instance.ValueString()
 	name := ""
 	if !plan.Name.IsNull() && !plan.Name.IsUnknown() {
@@ -128,6 +209,15 @@ func (r *snapshotResource) Create(ctx context.Context, req resource.CreateReques
 		comment = plan.Comment.ValueString()
 	}
 
+	if plan.StopInstance.ValueBool() {
+		restore, err := stopForOperation(ctx, r.client, instance, createTimeout, r.commandTimeout, "the snapshot")
+		defer func() { resp.Diagnostics.Append(restore()...) }()
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to stop instance for snapshot", err.Error())
+			return
+		}
+	}
+
 	actualName, err := r.client.CreateSnapshot(ctx, instance, name, comment)
 	if err != nil {
 		resp.Diagnostics.AddError("Failed to create snapshot", err.Error())
@@ -143,6 +233,15 @@ func (r *snapshotResource) Create(ctx context.Context, req resource.CreateReques
 	plan.ID = types.StringValue(id)
 	plan.Instance = types.StringValue(instance)
 	plan.Name = types.StringValue(actualName)
+	plan.Parent = types.StringNull()
+	plan.CreatedAt = types.StringNull()
+
+	details, err := r.client.ListSnapshotDetails(ctx, instance)
+	if err != nil {
+		resp.Diagnostics.AddWarning("Failed to read snapshot details", err.Error())
+	} else if snap := findSnapshot(details, actualName); snap != nil {
+		applySnapshotDetails(snap, &plan)
+	}
 
 	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
 }
@@ -159,30 +258,28 @@ func (r *snapshotResource) Read(ctx context.Context, req resource.ReadRequest, r
 		return
 	}
 
+	ctx, done := startOperation(ctx, "multipass_snapshot", "read", state.Instance.ValueString())
+	defer done(&resp.Diagnostics)
+
 	instance := state.Instance.ValueString()
 	name := state.Name.ValueString()
 
 	snapshots, err := r.client.ListSnapshots(ctx, instance)
+	if errors.Is(err, multipasscli.ErrNotFound) {
+		tflog.Info(ctx, "Multipass snapshot instance no longer exists", map[string]any{
+			"instance": instance,
+			"name":     name,
+		})
+		resp.State.RemoveResource(ctx)
+		return
+	}
 	if err != nil {
 		resp.Diagnostics.AddError("Failed to list snapshots", err.Error())
 		return
 	}
 
-	found := false
-	for _, s := range snapshots {
-		if s.Name == name {
-			found = true
-			// Only update comment from the API when it's non-empty,
-			// otherwise preserve the state value (null when not set in
-			// config) to avoid triggering RequiresReplace.
-			if s.Comment != "" {
-				state.Comment = types.StringValue(s.Comment)
-			}
-			break
-		}
-	}
-
-	if !found {
+	snap := findSnapshot(snapshots, name)
+	if snap == nil {
 		tflog.Info(ctx, "Multipass snapshot no longer exists", map[string]any{
 			"instance": instance,
 			"name":     name,
@@ -190,6 +287,25 @@ func (r *snapshotResource) Read(ctx context.Context, req resource.ReadRequest, r
 		resp.State.RemoveResource(ctx)
 		return
 	}
+	if snapshotReplaced(snap, state.CreatedAt) {
+		// The instance was recreated and a new snapshot reuses the name;
+		// the one in state is gone with the old instance.
+		tflog.Info(ctx, "Multipass snapshot was replaced by a newer one with the same name", map[string]any{
+			"instance":   instance,
+			"name":       name,
+			"created_at": state.CreatedAt.ValueString(),
+		})
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	// Only update comment from the API when it's non-empty, otherwise
+	// preserve the state value (null when not set in config) to avoid
+	// triggering RequiresReplace.
+	if snap.Comment != "" {
+		state.Comment = types.StringValue(snap.Comment)
+	}
+	applySnapshotDetails(snap, &state)
 
 	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
 }
@@ -201,6 +317,9 @@ func (r *snapshotResource) Update(ctx context.Context, req resource.UpdateReques
 	if resp.Diagnostics.HasError() {
 		return
 	}
+
+	ctx, done := startOperation(ctx, "multipass_snapshot", "update", plan.Instance.ValueString())
+	defer done(&resp.Diagnostics)
 	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
 }
 
@@ -216,6 +335,9 @@ func (r *snapshotResource) Delete(ctx context.Context, req resource.DeleteReques
 		return
 	}
 
+	ctx, done := startOperation(ctx, "multipass_snapshot", "delete", state.Instance.ValueString())
+	defer done(&resp.Diagnostics)
+
 	deleteTimeout, diags := state.Timeouts.Delete(ctx, r.commandTimeout)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
@@ -227,27 +349,160 @@ func (r *snapshotResource) Delete(ctx context.Context, req resource.DeleteReques
 	instance := state.Instance.ValueString()
 	name := state.Name.ValueString()
 
+	if state.Cascade.ValueBool() {
+		snapshots, err := r.client.ListSnapshots(ctx, instance)
+		if errors.Is(err, multipasscli.ErrNotFound) {
+			return
+		}
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to list snapshots", err.Error())
+			return
+		}
+		for _, descendant := range snapshotDescendants(snapshots, name) {
+			tflog.Info(ctx, "Deleting descendant snapshot", map[string]any{"instance": instance, "name": descendant, "ancestor": name})
+			if err := r.client.DeleteSnapshot(ctx, instance, descendant, true); err != nil && !errors.Is(err, multipasscli.ErrNotFound) {
+				resp.Diagnostics.AddError("Failed to delete descendant snapshot", fmt.Sprintf("Deleting %s.%s before %s.%s: %s", instance, descendant, instance, name, err))
+				return
+			}
+		}
+	}
+
 	if err := r.client.DeleteSnapshot(ctx, instance, name, true); err != nil {
-		if err == multipasscli.ErrNotFound {
+		if errors.Is(err, multipasscli.ErrNotFound) {
 			return
 		}
-		resp.Diagnostics.AddError("Failed to delete snapshot", err.Error())
+		detail := err.Error()
+		// Name the children that block the delete; the CLI error does not.
+		if snapshots, listErr := r.client.ListSnapshots(ctx, instance); listErr == nil {
+			if children := snapshotChildren(snapshots, name); len(children) > 0 {
+				detail += fmt.Sprintf("\n\nSnapshot %q has child snapshots %s. Delete them first or set cascade_delete = true.", name, strings.Join(children, ", "))
+			}
+		}
+		resp.Diagnostics.AddError("Failed to delete snapshot", detail)
 	}
 }
 
+// snapshotChildren returns the names of the snapshots whose parent is name,
+// sorted.
+func snapshotChildren(snapshots []models.Snapshot, name string) []string {
+	var children []string
+	for _, s := range snapshots {
+		if s.Parent == name && s.Name != name {
+			children = append(children, s.Name)
+		}
+	}
+	sort.Strings(children)
+	return children
+}
+
+// snapshotDescendants returns every descendant of name ordered so that each
+// snapshot comes before its parent, i.e. leaves first.
+func snapshotDescendants(snapshots []models.Snapshot, name string) []string {
+	var out []string
+	visited := map[string]bool{name: true}
+	var walk func(parent string)
+	walk = func(parent string) {
+		for _, child := range snapshotChildren(snapshots, parent) {
+			if visited[child] {
+				continue
+			}
+			visited[child] = true
+			walk(child)
+			out = append(out, child)
+		}
+	}
+	walk(name)
+	return out
+}
+
 func (r *snapshotResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
-	// Expect ID in the form "instance.snapshot"
-	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("id"), req.ID)...)
-	if resp.Diagnostics.HasError() {
+	if r.client == nil {
+		resp.Diagnostics.AddError("Client not configured", "Multipass client is nil.")
 		return
 	}
 
-	parts := strings.SplitN(req.ID, ".", 2)
-	if len(parts) != 2 {
+	// Expect ID in the form "instance.snapshot"
+	if !strings.Contains(req.ID, ".") {
 		resp.Diagnostics.AddError("Invalid import ID", "Expected <instance>.<snapshot>.")
 		return
 	}
 
-	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("instance"), parts[0])...)
-	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("name"), parts[1])...)
+	instance, snap, err := resolveSnapshotImportID(ctx, r.client, req.ID)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to import snapshot", err.Error())
+		return
+	}
+
+	state := snapshotResourceModel{
+		Comment:   types.StringNull(),
+		CreatedAt: types.StringNull(),
+	}
+	if snap.Comment != "" {
+		state.Comment = types.StringValue(snap.Comment)
+	}
+	applySnapshotDetails(snap, &state)
+
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("id"), fmt.Sprintf("%s.%s", instance, snap.Name))...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("instance"), instance)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("name"), snap.Name)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("comment"), state.Comment)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("parent"), state.Parent)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("created_at"), state.CreatedAt)...)
+}
+
+// resolveSnapshotImportID splits an `<instance>.<snapshot>` import ID by
+// checking each dot against the instance's actual snapshots, so instance
+// names containing dots resolve. The last dot is tried first.
+func resolveSnapshotImportID(ctx context.Context, client multipasscli.Client, id string) (string, *models.Snapshot, error) {
+	instanceFound := false
+	for i := strings.LastIndex(id, "."); i > 0; i = strings.LastIndex(id[:i], ".") {
+		instance, name := id[:i], id[i+1:]
+		if name == "" {
+			continue
+		}
+		snapshots, err := client.ListSnapshots(ctx, instance)
+		if errors.Is(err, multipasscli.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		instanceFound = true
+		if snap := findSnapshot(snapshots, name); snap != nil {
+			return instance, snap, nil
+		}
+	}
+	if !instanceFound {
+		return "", nil, fmt.Errorf("no Multipass instance matches import ID %q", id)
+	}
+	return "", nil, fmt.Errorf("snapshot %q does not exist; run `multipass list --snapshots` to see the available snapshots", id)
+}
+
+func findSnapshot(snapshots []models.Snapshot, name string) *models.Snapshot {
+	for i := range snapshots {
+		if snapshots[i].Name == name {
+			return &snapshots[i]
+		}
+	}
+	return nil
+}
+
+// snapshotReplaced reports whether snap has a different creation time than
+// the one recorded in state. Either side being unknown counts as a match.
+func snapshotReplaced(snap *models.Snapshot, recorded types.String) bool {
+	if snap.Created.IsZero() || recorded.IsNull() || recorded.IsUnknown() || recorded.ValueString() == "" {
+		return false
+	}
+	return snap.Created.UTC().Format(time.RFC3339) != recorded.ValueString()
+}
+
+// applySnapshotDetails copies the parent and creation time reported by
+// Multipass into model. A creation time Multipass omits or that cannot be
+// parsed leaves a previously recorded created_at untouched, so the value
+// stays stable across refreshes.
+func applySnapshotDetails(snap *models.Snapshot, model *snapshotResourceModel) {
+	model.Parent = types.StringValue(snap.Parent)
+	if !snap.Created.IsZero() {
+		model.CreatedAt = types.StringValue(snap.Created.UTC().Format(time.RFC3339))
+	}
 }
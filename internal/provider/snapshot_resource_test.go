@@ -0,0 +1,509 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-framework-timeouts/resource/timeouts"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+// readSnapshot runs snapshotResource.Read with the given client and returns
+// the refreshed state.
+func readSnapshot(t *testing.T, client *fakeClient, state snapshotResourceModel) tfsdk.State {
+	t.Helper()
+	ctx := context.Background()
+
+	r := &snapshotResource{client: client}
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	state.Timeouts = timeouts.Value{Object: types.ObjectNull(map[string]attr.Type{
+		"create": types.StringType,
+		"delete": types.StringType,
+	})}
+	tfState := tfsdk.State{Schema: schemaResp.Schema}
+	if diags := tfState.Set(ctx, &state); diags.HasError() {
+		t.Fatalf("set state: %v", diags)
+	}
+	resp := resource.ReadResponse{State: tfState}
+	r.Read(ctx, resource.ReadRequest{State: tfState}, &resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+	}
+	return resp.State
+}
+
+func TestSnapshotRead(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	state := snapshotResourceModel{
+		ID:        types.StringValue("app.snapshot1"),
+		Instance:  types.StringValue("app"),
+		Name:      types.StringValue("snapshot1"),
+		Comment:   types.StringNull(),
+		Parent:    types.StringValue(""),
+		CreatedAt: types.StringNull(),
+	}
+
+	t.Run("refreshes details", func(t *testing.T) {
+		created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+		client := &fakeClient{listSnapshots: func(string) ([]models.Snapshot, error) {
+			return []models.Snapshot{{Instance: "app", Name: "snapshot1", Comment: "before upgrade", Created: created}}, nil
+		}}
+		var got snapshotResourceModel
+		readSnapshot(t, client, state).Get(ctx, &got)
+		if got.Comment.ValueString() != "before upgrade" || got.CreatedAt.ValueString() != "2024-05-01T10:00:00Z" {
+			t.Fatalf("unexpected state: %+v", got)
+		}
+		if calls := client.recorded(); len(calls) != 1 || calls[0] != "snapshots app" {
+			t.Fatalf("unexpected calls: %v", calls)
+		}
+	})
+
+	t.Run("created_at kept when omitted", func(t *testing.T) {
+		recorded := state
+		recorded.CreatedAt = types.StringValue("2024-05-01T10:00:00Z")
+		client := &fakeClient{listSnapshots: func(string) ([]models.Snapshot, error) {
+			return []models.Snapshot{{Instance: "app", Name: "snapshot1", Parent: "base"}}, nil
+		}}
+		var got snapshotResourceModel
+		readSnapshot(t, client, recorded).Get(ctx, &got)
+		if got.CreatedAt.ValueString() != "2024-05-01T10:00:00Z" || got.Parent.ValueString() != "base" {
+			t.Fatalf("unexpected state: %+v", got)
+		}
+	})
+
+	t.Run("created_at normalized to UTC", func(t *testing.T) {
+		created := time.Date(2024, 5, 1, 12, 0, 0, 500, time.FixedZone("CEST", 2*60*60))
+		client := &fakeClient{listSnapshots: func(string) ([]models.Snapshot, error) {
+			return []models.Snapshot{{Instance: "app", Name: "snapshot1", Created: created}}, nil
+		}}
+		var got snapshotResourceModel
+		readSnapshot(t, client, state).Get(ctx, &got)
+		if got.CreatedAt.ValueString() != "2024-05-01T10:00:00Z" {
+			t.Fatalf("created_at = %s", got.CreatedAt)
+		}
+	})
+
+	t.Run("snapshot gone", func(t *testing.T) {
+		client := &fakeClient{listSnapshots: func(string) ([]models.Snapshot, error) {
+			return []models.Snapshot{{Instance: "app", Name: "snapshot2"}}, nil
+		}}
+		if got := readSnapshot(t, client, state); !got.Raw.IsNull() {
+			t.Fatalf("expected the snapshot to be removed from state")
+		}
+	})
+
+	t.Run("instance recreated", func(t *testing.T) {
+		recorded := state
+		recorded.CreatedAt = types.StringValue("2024-05-01T10:00:00Z")
+		client := &fakeClient{listSnapshots: func(string) ([]models.Snapshot, error) {
+			return []models.Snapshot{{Instance: "app", Name: "snapshot1", Created: time.Date(2024, 6, 3, 8, 0, 0, 0, time.UTC)}}, nil
+		}}
+		if got := readSnapshot(t, client, recorded); !got.Raw.IsNull() {
+			t.Fatalf("expected the snapshot to be removed from state")
+		}
+	})
+
+	t.Run("instance gone", func(t *testing.T) {
+		client := &fakeClient{listSnapshots: func(string) ([]models.Snapshot, error) {
+			return nil, multipasscli.ErrNotFound
+		}}
+		if got := readSnapshot(t, client, state); !got.Raw.IsNull() {
+			t.Fatalf("expected the snapshot to be removed from state")
+		}
+	})
+}
+
+// createSnapshot runs snapshotResource.Create for plan and returns the
+// recorded CLI calls and the diagnostics.
+func createSnapshot(t *testing.T, client *fakeClient, plan snapshotResourceModel) ([]string, diag.Diagnostics) {
+	t.Helper()
+	ctx := context.Background()
+
+	r := &snapshotResource{client: client, commandTimeout: time.Minute}
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	plan.Timeouts = timeouts.Value{Object: types.ObjectNull(map[string]attr.Type{
+		"create": types.StringType,
+		"delete": types.StringType,
+	})}
+	tfPlan := tfsdk.Plan{Schema: schemaResp.Schema}
+	if diags := tfPlan.Set(ctx, &plan); diags.HasError() {
+		t.Fatalf("set plan: %v", diags)
+	}
+	resp := resource.CreateResponse{State: tfsdk.State{Schema: schemaResp.Schema}}
+	r.Create(ctx, resource.CreateRequest{Plan: tfPlan}, &resp)
+	return client.recorded(), resp.Diagnostics
+}
+
+func TestSnapshotCreateStopInstance(t *testing.T) {
+	instanceWaitInterval = time.Millisecond
+
+	plan := snapshotResourceModel{
+		ID:           types.StringUnknown(),
+		Instance:     types.StringValue("vm"),
+		Name:         types.StringValue("pre"),
+		Comment:      types.StringNull(),
+		Parent:       types.StringUnknown(),
+		CreatedAt:    types.StringUnknown(),
+		StopInstance: types.BoolValue(true),
+	}
+	vmClient := func(state string) *fakeClient {
+		return &fakeClient{
+			listInstances: func(bool) ([]models.Instance, error) {
+				return []models.Instance{{Name: "vm", State: state}}, nil
+			},
+			startInstance: func(string) error { state = "Running"; return nil },
+			stopInstance:  func(string) error { state = "Stopped"; return nil },
+			suspend:       func(string) error { state = "Suspended"; return nil },
+		}
+	}
+
+	t.Run("running", func(t *testing.T) {
+		calls, diags := createSnapshot(t, vmClient("Running"), plan)
+		if diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		want := []string{"list", "stop vm force=false", "list", "snapshot vm pre", "snapshot-details vm", "start vm"}
+		if diff := cmp.Diff(want, calls); diff != "" {
+			t.Fatalf("unexpected calls: %s", diff)
+		}
+	})
+
+	t.Run("suspended", func(t *testing.T) {
+		calls, diags := createSnapshot(t, vmClient("Suspended"), plan)
+		if diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		want := []string{"list", "start vm", "list", "stop vm force=false", "list", "snapshot vm pre", "snapshot-details vm", "start vm", "suspend vm"}
+		if diff := cmp.Diff(want, calls); diff != "" {
+			t.Fatalf("unexpected calls: %s", diff)
+		}
+	})
+
+	t.Run("snapshot failure restarts", func(t *testing.T) {
+		client := vmClient("Running")
+		client.createSnapshot = func(string, string, string) (string, error) {
+			return "", errors.New("disk full")
+		}
+		calls, diags := createSnapshot(t, client, plan)
+		if !diags.HasError() {
+			t.Fatalf("expected an error")
+		}
+		if last := calls[len(calls)-1]; last != "start vm" {
+			t.Fatalf("expected the instance to be restarted, got calls %v", calls)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		disabled := plan
+		disabled.StopInstance = types.BoolNull()
+		calls, diags := createSnapshot(t, vmClient("Stopped"), disabled)
+		if diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		want := []string{"snapshot vm pre", "snapshot-details vm"}
+		if diff := cmp.Diff(want, calls); diff != "" {
+			t.Fatalf("unexpected calls: %s", diff)
+		}
+	})
+}
+
+// planSnapshot runs snapshotResource.ModifyPlan for creating plan, or for
+// moving from state when it is non-nil, and returns the diagnostics.
+func planSnapshot(t *testing.T, client *fakeClient, state *snapshotResourceModel, plan snapshotResourceModel) diag.Diagnostics {
+	t.Helper()
+	ctx := context.Background()
+
+	r := &snapshotResource{client: client}
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	nullTimeouts := timeouts.Value{Object: types.ObjectNull(map[string]attr.Type{
+		"create": types.StringType,
+		"delete": types.StringType,
+	})}
+	plan.Timeouts = nullTimeouts
+	tfState := tfsdk.State{Schema: schemaResp.Schema, Raw: tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil)}
+	if state != nil {
+		state.Timeouts = nullTimeouts
+		if diags := tfState.Set(ctx, state); diags.HasError() {
+			t.Fatalf("set state: %v", diags)
+		}
+	}
+	tfPlan := tfsdk.Plan{Schema: schemaResp.Schema}
+	if diags := tfPlan.Set(ctx, &plan); diags.HasError() {
+		t.Fatalf("set plan: %v", diags)
+	}
+
+	resp := resource.ModifyPlanResponse{Plan: tfPlan}
+	r.ModifyPlan(ctx, resource.ModifyPlanRequest{State: tfState, Plan: tfPlan}, &resp)
+	return resp.Diagnostics
+}
+
+func TestSnapshotModifyPlanNameConflict(t *testing.T) {
+	t.Parallel()
+
+	plan := snapshotResourceModel{
+		ID:        types.StringUnknown(),
+		Instance:  types.StringValue("vm"),
+		Name:      types.StringValue("pre"),
+		Comment:   types.StringNull(),
+		Parent:    types.StringUnknown(),
+		CreatedAt: types.StringUnknown(),
+	}
+	existing := func() *fakeClient {
+		return &fakeClient{listSnapshots: func(string) ([]models.Snapshot, error) {
+			return []models.Snapshot{{Instance: "vm", Name: "pre"}}, nil
+		}}
+	}
+
+	t.Run("name taken", func(t *testing.T) {
+		diags := planSnapshot(t, existing(), nil, plan)
+		if !diags.HasError() || !strings.Contains(diags.Errors()[0].Detail(), "vm.pre") {
+			t.Fatalf("expected a conflict error, got %v", diags)
+		}
+	})
+
+	t.Run("name free", func(t *testing.T) {
+		free := plan
+		free.Name = types.StringValue("post")
+		if diags := planSnapshot(t, existing(), nil, free); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+	})
+
+	t.Run("snapshot in state", func(t *testing.T) {
+		client := existing()
+		state := plan
+		state.ID = types.StringValue("vm.pre")
+		state.Parent = types.StringValue("")
+		state.CreatedAt = types.StringNull()
+		if diags := planSnapshot(t, client, &state, state); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		if calls := client.recorded(); len(calls) != 0 {
+			t.Fatalf("expected no CLI calls, got %v", calls)
+		}
+	})
+
+	t.Run("generated name", func(t *testing.T) {
+		client := existing()
+		generated := plan
+		generated.Name = types.StringUnknown()
+		if diags := planSnapshot(t, client, nil, generated); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		if calls := client.recorded(); len(calls) != 0 {
+			t.Fatalf("expected no CLI calls, got %v", calls)
+		}
+	})
+
+	t.Run("instance not created yet", func(t *testing.T) {
+		client := &fakeClient{listSnapshots: func(string) ([]models.Snapshot, error) {
+			return nil, multipasscli.ErrNotFound
+		}}
+		if diags := planSnapshot(t, client, nil, plan); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+	})
+}
+
+func TestSnapshotDescendants(t *testing.T) {
+	t.Parallel()
+
+	// base ─┬─ a ─── a1
+	//       └─ b
+	// other
+	snapshots := []models.Snapshot{
+		{Name: "a", Parent: "base"},
+		{Name: "a1", Parent: "a"},
+		{Name: "b", Parent: "base"},
+		{Name: "base"},
+		{Name: "other"},
+	}
+	if diff := cmp.Diff([]string{"a1", "a", "b"}, snapshotDescendants(snapshots, "base")); diff != "" {
+		t.Fatalf("unexpected descendants: %s", diff)
+	}
+	if got := snapshotDescendants(snapshots, "other"); len(got) != 0 {
+		t.Fatalf("expected no descendants, got %v", got)
+	}
+	if diff := cmp.Diff([]string{"a", "b"}, snapshotChildren(snapshots, "base")); diff != "" {
+		t.Fatalf("unexpected children: %s", diff)
+	}
+}
+
+// deleteSnapshot runs snapshotResource.Delete for state and returns the
+// recorded CLI calls and the diagnostics.
+func deleteSnapshot(t *testing.T, client *fakeClient, state snapshotResourceModel) ([]string, diag.Diagnostics) {
+	t.Helper()
+	ctx := context.Background()
+
+	r := &snapshotResource{client: client, commandTimeout: time.Minute}
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	state.Timeouts = timeouts.Value{Object: types.ObjectNull(map[string]attr.Type{
+		"create": types.StringType,
+		"delete": types.StringType,
+	})}
+	tfState := tfsdk.State{Schema: schemaResp.Schema}
+	if diags := tfState.Set(ctx, &state); diags.HasError() {
+		t.Fatalf("set state: %v", diags)
+	}
+	resp := resource.DeleteResponse{State: tfState}
+	r.Delete(ctx, resource.DeleteRequest{State: tfState}, &resp)
+	return client.recorded(), resp.Diagnostics
+}
+
+func TestSnapshotDeleteWithChildren(t *testing.T) {
+	t.Parallel()
+
+	state := snapshotResourceModel{
+		ID:        types.StringValue("vm.base"),
+		Instance:  types.StringValue("vm"),
+		Name:      types.StringValue("base"),
+		Comment:   types.StringNull(),
+		Parent:    types.StringValue(""),
+		CreatedAt: types.StringNull(),
+	}
+	snapshots := []models.Snapshot{
+		{Name: "a", Parent: "base"},
+		{Name: "a1", Parent: "a"},
+		{Name: "base"},
+	}
+	client := func() *fakeClient {
+		return &fakeClient{
+			listSnapshots: func(string) ([]models.Snapshot, error) { return snapshots, nil },
+			deleteSnapshot: func(_, name string) error {
+				if name == "base" {
+					return errors.New("multipass delete vm.base failed: exit status 2")
+				}
+				return nil
+			},
+		}
+	}
+
+	t.Run("without cascade names children", func(t *testing.T) {
+		_, diags := deleteSnapshot(t, client(), state)
+		if !diags.HasError() || !strings.Contains(diags.Errors()[0].Detail(), "child snapshots a.") {
+			t.Fatalf("expected children in the error, got %v", diags)
+		}
+	})
+
+	t.Run("cascade deletes leaves first", func(t *testing.T) {
+		c := client()
+		c.deleteSnapshot = nil
+		cascade := state
+		cascade.Cascade = types.BoolValue(true)
+		calls, diags := deleteSnapshot(t, c, cascade)
+		if diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		want := []string{"snapshots vm", "delete vm.a1 purge=true", "delete vm.a purge=true", "delete vm.base purge=true"}
+		if diff := cmp.Diff(want, calls); diff != "" {
+			t.Fatalf("unexpected calls: %s", diff)
+		}
+	})
+
+	t.Run("cascade skips snapshots already deleted", func(t *testing.T) {
+		// The CLI client wraps ErrNotFound with multipass's stderr.
+		c := client()
+		c.deleteSnapshot = func(_, name string) error {
+			if name == "a1" || name == "base" {
+				return fmt.Errorf("%w: instance \"vm.%s\" does not exist", multipasscli.ErrNotFound, name)
+			}
+			return nil
+		}
+		cascade := state
+		cascade.Cascade = types.BoolValue(true)
+		calls, diags := deleteSnapshot(t, c, cascade)
+		if diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		want := []string{"snapshots vm", "delete vm.a1 purge=true", "delete vm.a purge=true", "delete vm.base purge=true"}
+		if diff := cmp.Diff(want, calls); diff != "" {
+			t.Fatalf("unexpected calls: %s", diff)
+		}
+	})
+}
+
+func TestSnapshotImportState(t *testing.T) {
+	t.Parallel()
+
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	client := &fakeClient{
+		listSnapshots: func(instance string) ([]models.Snapshot, error) {
+			switch instance {
+			case "web.1":
+				return []models.Snapshot{{Instance: "web.1", Name: "pre-upgrade", Comment: "before v2", Parent: "base", Created: created}}, nil
+			case "app":
+				return []models.Snapshot{{Instance: "app", Name: "snapshot1"}}, nil
+			}
+			return nil, multipasscli.ErrNotFound
+		},
+	}
+
+	importSnapshot := func(id string) (snapshotResourceModel, diag.Diagnostics) {
+		ctx := context.Background()
+		r := &snapshotResource{client: client}
+		var schemaResp resource.SchemaResponse
+		r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+		resp := resource.ImportStateResponse{State: tfsdk.State{Schema: schemaResp.Schema}}
+		resp.State.Raw = tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil)
+		r.ImportState(ctx, resource.ImportStateRequest{ID: id}, &resp)
+		var got snapshotResourceModel
+		if !resp.Diagnostics.HasError() {
+			resp.State.Get(ctx, &got)
+		}
+		return got, resp.Diagnostics
+	}
+
+	got, diags := importSnapshot("web.1.pre-upgrade")
+	if diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if got.ID.ValueString() != "web.1.pre-upgrade" || got.Instance.ValueString() != "web.1" || got.Name.ValueString() != "pre-upgrade" {
+		t.Fatalf("unexpected identity: id=%s instance=%s name=%s", got.ID, got.Instance, got.Name)
+	}
+	if got.Comment.ValueString() != "before v2" || got.Parent.ValueString() != "base" || got.CreatedAt.ValueString() != "2024-05-01T12:00:00Z" {
+		t.Fatalf("unexpected details: comment=%s parent=%s created_at=%s", got.Comment, got.Parent, got.CreatedAt)
+	}
+
+	got, diags = importSnapshot("app.snapshot1")
+	if diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if !got.Comment.IsNull() {
+		t.Fatalf("expected null comment, got %s", got.Comment)
+	}
+
+	for id, want := range map[string]string{
+		"app":         "Expected <instance>.<snapshot>",
+		"app.missing": "does not exist",
+		"db.snap":     "no Multipass instance",
+	} {
+		_, diags := importSnapshot(id)
+		if !diags.HasError() || !strings.Contains(diags.Errors()[0].Detail(), want) {
+			t.Fatalf("%s: expected error containing %q, got %v", id, want, diags)
+		}
+	}
+}
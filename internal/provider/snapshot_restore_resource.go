@@ -0,0 +1,243 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/terraform-plugin-framework-timeouts/resource/timeouts"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/boolplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/mapplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+var (
+	_ resource.Resource                   = (*snapshotRestoreResource)(nil)
+	_ resource.ResourceWithConfigure      = (*snapshotRestoreResource)(nil)
+	_ resource.ResourceWithValidateConfig = (*snapshotRestoreResource)(nil)
+)
+
+// NewSnapshotRestoreResource instantiates the snapshot restore resource.
+func NewSnapshotRestoreResource() resource.Resource {
+	return &snapshotRestoreResource{}
+}
+
+type snapshotRestoreResource struct {
+	client         multipasscli.Client
+	commandTimeout time.Duration
+	// multipassVersion is the detected CLI version, or nil when unknown.
+	multipassVersion *version.Version
+}
+
+type snapshotRestoreResourceModel struct {
+	ID                   types.String   `tfsdk:"id"`
+	Instance             types.String   `tfsdk:"instance"`
+	Snapshot             types.String   `tfsdk:"snapshot"`
+	Destructive          types.Bool     `tfsdk:"destructive"`
+	SnapshotCurrentState types.Bool     `tfsdk:"snapshot_current_state"`
+	StopInstance         types.Bool     `tfsdk:"stop_instance"`
+	Triggers             types.Map      `tfsdk:"triggers"`
+	Timeouts             timeouts.Value `tfsdk:"timeouts"`
+}
+
+func (r *snapshotRestoreResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_snapshot_restore"
+}
+
+func (r *snapshotRestoreResource) Schema(ctx context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description:         "Restores a Multipass instance to a snapshot on create, and again whenever the arguments or triggers change. Destroy leaves the instance as it is.",
+		MarkdownDescription: "Restores a Multipass instance to a snapshot on create, and again whenever the arguments or `triggers` change. Destroy leaves the instance as it is. The restore never waits on the prompt `multipass restore` shows without `--destructive`; the answer comes from `snapshot_current_state`.",
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:    true,
+				Description: "Identifier in the form `<instance>.<snapshot>`.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"instance": schema.StringAttribute{
+				Required:    true,
+				Description: "Name of the Multipass instance to restore. The instance must be stopped. Changing restores again.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"snapshot": schema.StringAttribute{
+				Required:    true,
+				Description: "Name of the snapshot to restore. Changing restores again.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"destructive": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Discard the current state of the instance without taking a snapshot of it, like `multipass restore --destructive` (default: false).",
+				PlanModifiers: []planmodifier.Bool{
+					boolplanmodifier.RequiresReplace(),
+				},
+			},
+			"snapshot_current_state": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Take a snapshot of the current state before restoring, by answering yes to the prompt of a non-destructive restore (default: false). Cannot be combined with `destructive`.",
+				PlanModifiers: []planmodifier.Bool{
+					boolplanmodifier.RequiresReplace(),
+				},
+			},
+			"stop_instance": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Stop a running or suspended instance before restoring and return it to that state afterwards (default: false).",
+			},
+			"triggers": schema.MapAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "Map of arbitrary values that, when changed, restore the snapshot again.",
+				PlanModifiers: []planmodifier.Map{
+					mapplanmodifier.RequiresReplace(),
+				},
+			},
+		},
+		Blocks: map[string]schema.Block{
+			"timeouts": timeouts.Block(ctx, timeouts.Opts{
+				Create: true,
+			}),
+		},
+	}
+}
+
+func (r *snapshotRestoreResource) Configure(_ context.Context, req resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	data := req.ProviderData.(providerData)
+	r.client = data.client
+	r.commandTimeout = data.commandTimeout
+	r.multipassVersion = data.multipassVersion
+}
+
+// ValidateConfig rejects snapshot_current_state on a destructive restore,
+// which discards the current state by definition.
+func (r *snapshotRestoreResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var destructive, snapshotCurrent types.Bool
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("destructive"), &destructive)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("snapshot_current_state"), &snapshotCurrent)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if destructive.ValueBool() && snapshotCurrent.ValueBool() {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("snapshot_current_state"),
+			"Conflicting restore options",
+			"A destructive restore discards the current state without a snapshot. Set at most one of destructive and snapshot_current_state.",
+		)
+	}
+}
+
+func (r *snapshotRestoreResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	if r.client == nil {
+		resp.Diagnostics.AddError("Client not configured", "Multipass client is nil.")
+		return
+	}
+
+	var plan snapshotRestoreResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ctx, done := startOperation(ctx, "multipass_snapshot_restore", "create", plan.Instance.ValueString())
+	defer done(&resp.Diagnostics)
+
+	createTimeout, diags := plan.Timeouts.Create(ctx, r.commandTimeout)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, createTimeout)
+	defer cancel()
+
+	if !supportsFeature(r.multipassVersion, featureSnapshots) {
+		resp.Diagnostics.AddError("Snapshots not supported", unsupportedFeatureDetail(r.multipassVersion, featureSnapshots))
+		return
+	}
+
+	instance := plan.Instance.ValueString()
+	snapshot := plan.Snapshot.ValueString()
+
+	if plan.StopInstance.ValueBool() {
+		restore, err := stopForOperation(ctx, r.client, instance, createTimeout, r.commandTimeout, "the restore")
+		defer func() { resp.Diagnostics.Append(restore()...) }()
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to stop instance for restore", err.Error())
+			return
+		}
+	}
+
+	opts := multipasscli.RestoreOptions{
+		Destructive:          plan.Destructive.ValueBool(),
+		SnapshotCurrentState: plan.SnapshotCurrentState.ValueBool(),
+	}
+	tflog.Info(ctx, "Restoring snapshot", map[string]any{"instance": instance, "snapshot": snapshot, "destructive": opts.Destructive})
+	if err := r.client.RestoreSnapshot(ctx, instance, snapshot, opts); err != nil {
+		resp.Diagnostics.AddError("Failed to restore snapshot", err.Error())
+		return
+	}
+
+	plan.ID = types.StringValue(fmt.Sprintf("%s.%s", instance, snapshot))
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+// Read only checks that the instance still exists; the restore is never
+// repeated on refresh.
+func (r *snapshotRestoreResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	if r.client == nil {
+		resp.Diagnostics.AddError("Client not configured", "Multipass client is nil.")
+		return
+	}
+
+	var state snapshotRestoreResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ctx, done := startOperation(ctx, "multipass_snapshot_restore", "read", state.Instance.ValueString())
+	defer done(&resp.Diagnostics)
+
+	if _, err := r.client.GetInstance(ctx, state.Instance.ValueString()); err != nil {
+		if errors.Is(err, multipasscli.ErrNotFound) {
+			tflog.Info(ctx, "Multipass instance no longer exists", map[string]any{"instance": state.Instance.ValueString()})
+			resp.State.RemoveResource(ctx)
+			return
+		}
+		resp.Diagnostics.AddError("Failed to read instance", err.Error())
+	}
+}
+
+func (r *snapshotRestoreResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	// Only stop_instance and timeouts update in place; neither has an
+	// effect after the restore.
+	var plan snapshotRestoreResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ctx, done := startOperation(ctx, "multipass_snapshot_restore", "update", plan.Instance.ValueString())
+	defer done(&resp.Diagnostics)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+// Delete leaves the instance in its restored state.
+func (r *snapshotRestoreResource) Delete(_ context.Context, _ resource.DeleteRequest, _ *resource.DeleteResponse) {
+}
@@ -0,0 +1,132 @@
+package provider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-framework-timeouts/resource/timeouts"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
+)
+
+// restorePlan returns a plan restoring vm to snapshot pre.
+func restorePlan() snapshotRestoreResourceModel {
+	return snapshotRestoreResourceModel{
+		ID:                   types.StringUnknown(),
+		Instance:             types.StringValue("vm"),
+		Snapshot:             types.StringValue("pre"),
+		Destructive:          types.BoolNull(),
+		SnapshotCurrentState: types.BoolNull(),
+		StopInstance:         types.BoolNull(),
+		Triggers:             types.MapNull(types.StringType),
+		Timeouts:             timeouts.Value{Object: types.ObjectNull(map[string]attr.Type{"create": types.StringType})},
+	}
+}
+
+// createRestore runs snapshotRestoreResource.Create for plan and returns the
+// recorded calls and the diagnostics.
+func createRestore(t *testing.T, client *fakeClient, plan snapshotRestoreResourceModel) ([]string, diag.Diagnostics) {
+	t.Helper()
+	ctx := context.Background()
+
+	r := &snapshotRestoreResource{client: client, commandTimeout: time.Minute}
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	tfPlan := tfsdk.Plan{Schema: schemaResp.Schema}
+	if diags := tfPlan.Set(ctx, &plan); diags.HasError() {
+		t.Fatalf("set plan: %v", diags)
+	}
+	resp := resource.CreateResponse{State: tfsdk.State{Schema: schemaResp.Schema, Raw: tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil)}}
+	r.Create(ctx, resource.CreateRequest{Plan: tfPlan}, &resp)
+	return client.recorded(), resp.Diagnostics
+}
+
+func TestSnapshotRestoreCreate(t *testing.T) {
+	instanceWaitInterval = time.Millisecond
+
+	cases := []struct {
+		name  string
+		plan  func(*snapshotRestoreResourceModel)
+		state string
+		want  []string
+	}{
+		{
+			name:  "default",
+			plan:  func(*snapshotRestoreResourceModel) {},
+			state: "Stopped",
+			want:  []string{"restore vm.pre destructive=false snapshot=false"},
+		},
+		{
+			name:  "snapshot current state",
+			plan:  func(p *snapshotRestoreResourceModel) { p.SnapshotCurrentState = types.BoolValue(true) },
+			state: "Stopped",
+			want:  []string{"restore vm.pre destructive=false snapshot=true"},
+		},
+		{
+			name:  "destructive",
+			plan:  func(p *snapshotRestoreResourceModel) { p.Destructive = types.BoolValue(true) },
+			state: "Stopped",
+			want:  []string{"restore vm.pre destructive=true snapshot=false"},
+		},
+		{
+			name:  "stop instance",
+			plan:  func(p *snapshotRestoreResourceModel) { p.StopInstance = types.BoolValue(true) },
+			state: "Running",
+			want:  []string{"list", "stop vm force=false", "list", "restore vm.pre destructive=false snapshot=false", "start vm"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			state := tc.state
+			client := &fakeClient{
+				listInstances: func(bool) ([]models.Instance, error) {
+					return []models.Instance{{Name: "vm", State: state}}, nil
+				},
+				startInstance: func(string) error { state = "Running"; return nil },
+				stopInstance:  func(string) error { state = "Stopped"; return nil },
+			}
+			plan := restorePlan()
+			tc.plan(&plan)
+			calls, diags := createRestore(t, client, plan)
+			if diags.HasError() {
+				t.Fatalf("unexpected diagnostics: %v", diags)
+			}
+			if diff := cmp.Diff(tc.want, calls); diff != "" {
+				t.Fatalf("unexpected calls: %s", diff)
+			}
+		})
+	}
+}
+
+func TestSnapshotRestoreValidateConfig(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	r := &snapshotRestoreResource{}
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	plan := restorePlan()
+	plan.ID = types.StringNull()
+	plan.Destructive = types.BoolValue(true)
+	plan.SnapshotCurrentState = types.BoolValue(true)
+	tfPlan := tfsdk.Plan{Schema: schemaResp.Schema}
+	if diags := tfPlan.Set(ctx, &plan); diags.HasError() {
+		t.Fatalf("set plan: %v", diags)
+	}
+
+	var resp resource.ValidateConfigResponse
+	r.ValidateConfig(ctx, resource.ValidateConfigRequest{Config: tfsdk.Config{Schema: schemaResp.Schema, Raw: tfPlan.Raw}}, &resp)
+	if !resp.Diagnostics.HasError() || resp.Diagnostics.Errors()[0].Summary() != "Conflicting restore options" {
+		t.Fatalf("expected conflicting options to be rejected, got %v", resp.Diagnostics)
+	}
+}
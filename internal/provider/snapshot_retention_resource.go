@@ -0,0 +1,356 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework-timeouts/resource/timeouts"
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+var (
+	_ resource.Resource               = (*snapshotRetentionResource)(nil)
+	_ resource.ResourceWithConfigure  = (*snapshotRetentionResource)(nil)
+	_ resource.ResourceWithModifyPlan = (*snapshotRetentionResource)(nil)
+)
+
+// NewSnapshotRetentionResource instantiates the snapshot retention resource.
+func NewSnapshotRetentionResource() resource.Resource {
+	return &snapshotRetentionResource{}
+}
+
+type snapshotRetentionResource struct {
+	client         multipasscli.Client
+	commandTimeout time.Duration
+}
+
+type snapshotRetentionResourceModel struct {
+	ID          types.String   `tfsdk:"id"`
+	Instance    types.String   `tfsdk:"instance"`
+	KeepLast    types.Int64    `tfsdk:"keep_last"`
+	NamePrefix  types.String   `tfsdk:"name_prefix"`
+	IgnoreNames types.List     `tfsdk:"ignore_names"`
+	Retained    types.List     `tfsdk:"retained"`
+	Expired     types.List     `tfsdk:"expired"`
+	Timeouts    timeouts.Value `tfsdk:"timeouts"`
+}
+
+func (r *snapshotRetentionResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_snapshot_retention"
+}
+
+func (r *snapshotRetentionResource) Schema(ctx context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Keeps only the newest N snapshots of a Multipass instance, deleting older ones on apply. Destroying the resource deletes no snapshots.",
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed: true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+				Description: "The instance name.",
+			},
+			"instance": schema.StringAttribute{
+				Required:    true,
+				Description: "Name of the Multipass instance whose snapshots are pruned. Changing forces recreation.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"keep_last": schema.Int64Attribute{
+				Required:    true,
+				Description: "Number of newest matching snapshots to keep.",
+				Validators: []validator.Int64{
+					int64validator.AtLeast(1),
+				},
+			},
+			"name_prefix": schema.StringAttribute{
+				Optional:    true,
+				Description: "Only snapshots whose name starts with this prefix are counted and pruned. Other snapshots are left alone.",
+			},
+			"ignore_names": schema.ListAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "Snapshot names that are never counted or deleted, e.g. those managed by `multipass_snapshot` resources.",
+			},
+			"retained": schema.ListAttribute{
+				Computed:    true,
+				ElementType: types.StringType,
+				Description: "Matching snapshots that are kept, newest first.",
+			},
+			"expired": schema.ListAttribute{
+				Computed:    true,
+				ElementType: types.StringType,
+				Description: "Matching snapshots beyond `keep_last` found on refresh; the next apply deletes them.",
+			},
+		},
+		Blocks: map[string]schema.Block{
+			"timeouts": timeouts.Block(ctx, timeouts.Opts{
+				Create: true,
+				Update: true,
+			}),
+		},
+	}
+}
+
+func (r *snapshotRetentionResource) Configure(_ context.Context, req resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	data := req.ProviderData.(providerData)
+	r.client = data.client
+	r.commandTimeout = data.commandTimeout
+}
+
+// ModifyPlan plans an update whenever refresh found expired snapshots, so
+// the apply that deletes them shows up in the plan.
+func (r *snapshotRetentionResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var plan snapshotRetentionResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if !req.State.Raw.IsNull() && !plan.Expired.IsUnknown() {
+		var state snapshotRetentionResourceModel
+		resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+		if resp.Diagnostics.HasError() || len(state.Expired.Elements()) == 0 {
+			return
+		}
+	}
+
+	plan.Expired = stringListValue(nil)
+	plan.Retained = types.ListUnknown(types.StringType)
+	resp.Diagnostics.Append(resp.Plan.Set(ctx, &plan)...)
+}
+
+func (r *snapshotRetentionResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var plan snapshotRetentionResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ctx, done := startOperation(ctx, "multipass_snapshot_retention", "create", plan.Instance.ValueString())
+	defer done(&resp.Diagnostics)
+
+	createTimeout, diags := plan.Timeouts.Create(ctx, r.commandTimeout)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, createTimeout)
+	defer cancel()
+
+	resp.Diagnostics.Append(r.apply(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *snapshotRetentionResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	if r.client == nil {
+		resp.Diagnostics.AddError("Client not configured", "Multipass client is nil.")
+		return
+	}
+
+	var state snapshotRetentionResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ctx, done := startOperation(ctx, "multipass_snapshot_retention", "read", state.Instance.ValueString())
+	defer done(&resp.Diagnostics)
+
+	instance := state.Instance.ValueString()
+	snapshots, err := r.client.ListSnapshots(ctx, instance)
+	if errors.Is(err, multipasscli.ErrNotFound) {
+		tflog.Info(ctx, "Multipass instance for snapshot retention no longer exists", map[string]any{"instance": instance})
+		resp.State.RemoveResource(ctx)
+		return
+	}
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to list snapshots", err.Error())
+		return
+	}
+
+	policy, diags := retentionPolicyFromModel(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	retained, expired := policy.partition(snapshots)
+	state.Retained = stringListValue(retained)
+	state.Expired = stringListValue(expired)
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+}
+
+func (r *snapshotRetentionResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var plan snapshotRetentionResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ctx, done := startOperation(ctx, "multipass_snapshot_retention", "update", plan.Instance.ValueString())
+	defer done(&resp.Diagnostics)
+
+	updateTimeout, diags := plan.Timeouts.Update(ctx, r.commandTimeout)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, updateTimeout)
+	defer cancel()
+
+	resp.Diagnostics.Append(r.apply(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+// Delete only removes the policy from state; snapshots are left in place.
+func (r *snapshotRetentionResource) Delete(_ context.Context, _ resource.DeleteRequest, _ *resource.DeleteResponse) {
+}
+
+// apply deletes the snapshots beyond keep_last and records the ones kept.
+func (r *snapshotRetentionResource) apply(ctx context.Context, model *snapshotRetentionResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+	if r.client == nil {
+		diags.AddError("Client not configured", "Multipass client is nil.")
+		return diags
+	}
+
+	policy, d := retentionPolicyFromModel(ctx, model)
+	diags.Append(d...)
+	if diags.HasError() {
+		return diags
+	}
+
+	instance := model.Instance.ValueString()
+	snapshots, err := r.client.ListSnapshots(ctx, instance)
+	if err != nil {
+		diags.AddError("Failed to list snapshots", err.Error())
+		return diags
+	}
+
+	retained, expired := policy.partition(snapshots)
+	for _, name := range snapshotDeleteOrder(snapshots, expired) {
+		tflog.Info(ctx, "Deleting expired snapshot", map[string]any{"instance": instance, "name": name, "keep_last": policy.keepLast})
+		if err := r.client.DeleteSnapshot(ctx, instance, name, true); err != nil && !errors.Is(err, multipasscli.ErrNotFound) {
+			diags.AddError("Failed to delete expired snapshot", fmt.Sprintf("Deleting %s.%s: %s", instance, name, err))
+			return diags
+		}
+	}
+
+	model.ID = types.StringValue(instance)
+	model.Retained = stringListValue(retained)
+	model.Expired = stringListValue(nil)
+	return diags
+}
+
+// snapshotRetentionPolicy selects which snapshots of an instance to keep.
+type snapshotRetentionPolicy struct {
+	keepLast int
+	prefix   string
+	ignore   map[string]bool
+}
+
+func retentionPolicyFromModel(ctx context.Context, model *snapshotRetentionResourceModel) (snapshotRetentionPolicy, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	policy := snapshotRetentionPolicy{
+		keepLast: int(model.KeepLast.ValueInt64()),
+		prefix:   model.NamePrefix.ValueString(),
+		ignore:   map[string]bool{},
+	}
+	if !model.IgnoreNames.IsNull() && !model.IgnoreNames.IsUnknown() {
+		var names []string
+		diags.Append(model.IgnoreNames.ElementsAs(ctx, &names, false)...)
+		for _, name := range names {
+			policy.ignore[name] = true
+		}
+	}
+	return policy, diags
+}
+
+// partition splits the snapshots matching the policy into the newest
+// keepLast, newest first, and the rest. Snapshots Multipass reports without
+// a creation time sort as the oldest.
+func (p snapshotRetentionPolicy) partition(snapshots []models.Snapshot) (retained, expired []string) {
+	var matching []models.Snapshot
+	for _, s := range snapshots {
+		if p.ignore[s.Name] || !strings.HasPrefix(s.Name, p.prefix) {
+			continue
+		}
+		matching = append(matching, s)
+	}
+	sort.SliceStable(matching, func(i, j int) bool {
+		if !matching[i].Created.Equal(matching[j].Created) {
+			return matching[i].Created.After(matching[j].Created)
+		}
+		return matching[i].Name > matching[j].Name
+	})
+	for i, s := range matching {
+		if i < p.keepLast {
+			retained = append(retained, s.Name)
+		} else {
+			expired = append(expired, s.Name)
+		}
+	}
+	return retained, expired
+}
+
+// snapshotDeleteOrder orders names so that every snapshot is deleted before
+// its ancestors, i.e. deepest first.
+func snapshotDeleteOrder(snapshots []models.Snapshot, names []string) []string {
+	parents := make(map[string]string, len(snapshots))
+	for _, s := range snapshots {
+		parents[s.Name] = s.Parent
+	}
+	depth := func(name string) int {
+		d := 0
+		seen := map[string]bool{name: true}
+		for p := parents[name]; p != "" && !seen[p]; p = parents[p] {
+			seen[p] = true
+			d++
+		}
+		return d
+	}
+
+	out := append([]string{}, names...)
+	sort.SliceStable(out, func(i, j int) bool {
+		return depth(out[i]) > depth(out[j])
+	})
+	return out
+}
+
+func stringListValue(values []string) types.List {
+	elems := make([]attr.Value, 0, len(values))
+	for _, v := range values {
+		elems = append(elems, types.StringValue(v))
+	}
+	return types.ListValueMust(types.StringType, elems)
+}
@@ -0,0 +1,227 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-framework-timeouts/resource/timeouts"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+var nullRetentionTimeouts = timeouts.Value{Object: types.ObjectNull(map[string]attr.Type{
+	"create": types.StringType,
+	"update": types.StringType,
+})}
+
+// nightlySnapshots is a chain nightly-1 <- nightly-2 <- nightly-3 <- manual
+// <- nightly-4, one day apart.
+func nightlySnapshots() []models.Snapshot {
+	day := func(d int) time.Time { return time.Date(2024, 5, d, 2, 0, 0, 0, time.UTC) }
+	return []models.Snapshot{
+		{Instance: "db", Name: "nightly-1", Created: day(1)},
+		{Instance: "db", Name: "nightly-2", Parent: "nightly-1", Created: day(2)},
+		{Instance: "db", Name: "nightly-3", Parent: "nightly-2", Created: day(3)},
+		{Instance: "db", Name: "manual", Parent: "nightly-3", Created: day(4)},
+		{Instance: "db", Name: "nightly-4", Parent: "manual", Created: day(5)},
+	}
+}
+
+func TestSnapshotRetentionPartition(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		policy       snapshotRetentionPolicy
+		wantRetained []string
+		wantExpired  []string
+	}{
+		"all snapshots": {
+			policy:       snapshotRetentionPolicy{keepLast: 2},
+			wantRetained: []string{"nightly-4", "manual"},
+			wantExpired:  []string{"nightly-3", "nightly-2", "nightly-1"},
+		},
+		"prefix": {
+			policy:       snapshotRetentionPolicy{keepLast: 2, prefix: "nightly-"},
+			wantRetained: []string{"nightly-4", "nightly-3"},
+			wantExpired:  []string{"nightly-2", "nightly-1"},
+		},
+		"ignored": {
+			policy:       snapshotRetentionPolicy{keepLast: 2, ignore: map[string]bool{"nightly-1": true, "manual": true}},
+			wantRetained: []string{"nightly-4", "nightly-3"},
+			wantExpired:  []string{"nightly-2"},
+		},
+		"nothing expired": {
+			policy:       snapshotRetentionPolicy{keepLast: 10},
+			wantRetained: []string{"nightly-4", "manual", "nightly-3", "nightly-2", "nightly-1"},
+		},
+	}
+	for name, tc := range cases {
+		retained, expired := tc.policy.partition(nightlySnapshots())
+		if diff := cmp.Diff(tc.wantRetained, retained); diff != "" {
+			t.Errorf("%s: retained (-want +got): %s", name, diff)
+		}
+		if diff := cmp.Diff(tc.wantExpired, expired); diff != "" {
+			t.Errorf("%s: expired (-want +got): %s", name, diff)
+		}
+	}
+}
+
+func TestSnapshotDeleteOrder(t *testing.T) {
+	t.Parallel()
+
+	got := snapshotDeleteOrder(nightlySnapshots(), []string{"nightly-1", "nightly-3", "nightly-2"})
+	if diff := cmp.Diff([]string{"nightly-3", "nightly-2", "nightly-1"}, got); diff != "" {
+		t.Fatalf("unexpected order (-want +got): %s", diff)
+	}
+}
+
+// createRetention runs snapshotRetentionResource.Create keeping the last
+// nightly snapshot of db, except nightly-2.
+func createRetention(t *testing.T, client *fakeClient) resource.CreateResponse {
+	t.Helper()
+	ctx := context.Background()
+
+	r := &snapshotRetentionResource{client: client, commandTimeout: time.Minute}
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	ignore, _ := types.ListValueFrom(ctx, types.StringType, []string{"nightly-2"})
+	plan := snapshotRetentionResourceModel{
+		Instance:    types.StringValue("db"),
+		KeepLast:    types.Int64Value(1),
+		NamePrefix:  types.StringValue("nightly-"),
+		IgnoreNames: ignore,
+		Retained:    types.ListUnknown(types.StringType),
+		Expired:     types.ListUnknown(types.StringType),
+		Timeouts:    nullRetentionTimeouts,
+	}
+	tfPlan := tfsdk.Plan{Schema: schemaResp.Schema}
+	if diags := tfPlan.Set(ctx, &plan); diags.HasError() {
+		t.Fatalf("set plan: %v", diags)
+	}
+	resp := resource.CreateResponse{State: tfsdk.State{Schema: schemaResp.Schema}}
+	r.Create(ctx, resource.CreateRequest{Plan: tfPlan}, &resp)
+	return resp
+}
+
+func TestSnapshotRetentionCreate(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	client := &fakeClient{listSnapshots: func(string) ([]models.Snapshot, error) {
+		return nightlySnapshots(), nil
+	}}
+	resp := createRetention(t, client)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+	}
+
+	want := []string{"snapshots db", "delete db.nightly-3 purge=true", "delete db.nightly-1 purge=true"}
+	if diff := cmp.Diff(want, client.recorded()); diff != "" {
+		t.Fatalf("unexpected calls (-want +got): %s", diff)
+	}
+	var got snapshotRetentionResourceModel
+	resp.State.Get(ctx, &got)
+	if got.ID.ValueString() != "db" || !got.Retained.Equal(stringListValue([]string{"nightly-4"})) || len(got.Expired.Elements()) != 0 {
+		t.Fatalf("unexpected state: %+v", got)
+	}
+}
+
+func TestSnapshotRetentionCreateSkipsDeletedSnapshots(t *testing.T) {
+	t.Parallel()
+
+	client := &fakeClient{
+		listSnapshots: func(string) ([]models.Snapshot, error) {
+			return nightlySnapshots(), nil
+		},
+		// The CLI client wraps ErrNotFound with multipass's stderr.
+		deleteSnapshot: func(_, name string) error {
+			if name == "nightly-3" {
+				return fmt.Errorf("%w: instance \"db.nightly-3\" does not exist", multipasscli.ErrNotFound)
+			}
+			return nil
+		},
+	}
+	resp := createRetention(t, client)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("expected a snapshot deleted elsewhere to be skipped, got %v", resp.Diagnostics)
+	}
+
+	want := []string{"snapshots db", "delete db.nightly-3 purge=true", "delete db.nightly-1 purge=true"}
+	if diff := cmp.Diff(want, client.recorded()); diff != "" {
+		t.Fatalf("unexpected calls (-want +got): %s", diff)
+	}
+}
+
+func TestSnapshotRetentionModifyPlan(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	r := &snapshotRetentionResource{}
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	state := snapshotRetentionResourceModel{
+		ID:          types.StringValue("db"),
+		Instance:    types.StringValue("db"),
+		KeepLast:    types.Int64Value(1),
+		NamePrefix:  types.StringNull(),
+		IgnoreNames: types.ListNull(types.StringType),
+		Retained:    stringListValue([]string{"nightly-4"}),
+		Timeouts:    nullRetentionTimeouts,
+	}
+
+	for expired, wantUpdate := range map[string]bool{"": false, "nightly-3": true} {
+		state := state
+		state.Expired = stringListValue(nil)
+		if expired != "" {
+			state.Expired = stringListValue([]string{expired})
+		}
+		tfState := tfsdk.State{Schema: schemaResp.Schema}
+		if diags := tfState.Set(ctx, &state); diags.HasError() {
+			t.Fatalf("set state: %v", diags)
+		}
+		tfPlan := tfsdk.Plan{Schema: schemaResp.Schema, Raw: tfState.Raw.Copy()}
+
+		resp := resource.ModifyPlanResponse{Plan: tfPlan}
+		r.ModifyPlan(ctx, resource.ModifyPlanRequest{State: tfState, Plan: tfPlan}, &resp)
+		if resp.Diagnostics.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+		}
+		var got snapshotRetentionResourceModel
+		resp.Plan.Get(ctx, &got)
+		if update := !resp.Plan.Raw.Equal(tfState.Raw); update != wantUpdate {
+			t.Fatalf("expired=%q: update planned = %t", expired, update)
+		}
+		if wantUpdate && (len(got.Expired.Elements()) != 0 || !got.Retained.IsUnknown()) {
+			t.Fatalf("expired=%q: unexpected plan: %+v", expired, got)
+		}
+	}
+
+	// Creating always plans an empty expired list.
+	create := state
+	create.ID = types.StringUnknown()
+	create.Retained = types.ListUnknown(types.StringType)
+	create.Expired = types.ListUnknown(types.StringType)
+	tfPlan := tfsdk.Plan{Schema: schemaResp.Schema}
+	if diags := tfPlan.Set(ctx, &create); diags.HasError() {
+		t.Fatalf("set plan: %v", diags)
+	}
+	nullState := tfsdk.State{Schema: schemaResp.Schema, Raw: tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil)}
+	resp := resource.ModifyPlanResponse{Plan: tfPlan}
+	r.ModifyPlan(ctx, resource.ModifyPlanRequest{State: nullState, Plan: tfPlan}, &resp)
+	var got snapshotRetentionResourceModel
+	resp.Plan.Get(ctx, &got)
+	if got.Expired.IsUnknown() || len(got.Expired.Elements()) != 0 {
+		t.Fatalf("unexpected expired on create: %s", got.Expired)
+	}
+}
@@ -2,9 +2,13 @@ package provider
 
 import (
 	"context"
+	"sort"
+	"time"
 
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 
 	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
@@ -27,23 +31,34 @@ type snapshotsDataSource struct {
 type snapshotsDataSourceModel struct {
 	Instance  types.String        `tfsdk:"instance"`
 	Name      types.String        `tfsdk:"name"`
+	Sort      types.String        `tfsdk:"sort"`
 	Snapshots []snapshotModelInfo `tfsdk:"snapshots"`
 }
 
 type snapshotModelInfo struct {
-	Instance types.String `tfsdk:"instance"`
-	Name     types.String `tfsdk:"name"`
-	Comment  types.String `tfsdk:"comment"`
-	Parent   types.String `tfsdk:"parent"`
+	Instance       types.String `tfsdk:"instance"`
+	Name           types.String `tfsdk:"name"`
+	Comment        types.String `tfsdk:"comment"`
+	Parent         types.String `tfsdk:"parent"`
+	CreatedAt      types.String `tfsdk:"created_at"`
+	Children       types.List   `tfsdk:"children"`
+	TotalSizeBytes types.Int64  `tfsdk:"total_size_bytes"`
 }
 
+// Orderings accepted by the snapshots data source's sort attribute.
+const (
+	snapshotSortCreatedAsc  = "created_asc"
+	snapshotSortCreatedDesc = "created_desc"
+	snapshotSortName        = "name"
+)
+
 func (d *snapshotsDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
 	resp.TypeName = req.ProviderTypeName + "_snapshots"
 }
 
 func (d *snapshotsDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
 	resp.Schema = schema.Schema{
-		Description: "Lists snapshots for a given Multipass instance.",
+		Description: "Lists snapshots for a given Multipass instance, ordered by creation time (oldest first) unless sort says otherwise.",
 		Attributes: map[string]schema.Attribute{
 			"instance": schema.StringAttribute{
 				Required:    true,
@@ -53,6 +68,13 @@ func (d *snapshotsDataSource) Schema(_ context.Context, _ datasource.SchemaReque
 				Optional:    true,
 				Description: "Optional snapshot name filter.",
 			},
+			"sort": schema.StringAttribute{
+				Optional:    true,
+				Description: "Order of the snapshots list: created_asc (default, oldest first), created_desc (newest first) or name.",
+				Validators: []validator.String{
+					stringvalidator.OneOf(snapshotSortCreatedAsc, snapshotSortCreatedDesc, snapshotSortName),
+				},
+			},
 			"snapshots": schema.ListNestedAttribute{
 				Computed: true,
 				NestedObject: schema.NestedAttributeObject{
@@ -69,6 +91,19 @@ func (d *snapshotsDataSource) Schema(_ context.Context, _ datasource.SchemaReque
 						"parent": schema.StringAttribute{
 							Computed: true,
 						},
+						"created_at": schema.StringAttribute{
+							Computed:    true,
+							Description: "Creation time in RFC3339 format (UTC), null when Multipass does not report it.",
+						},
+						"children": schema.ListAttribute{
+							Computed:    true,
+							ElementType: types.StringType,
+							Description: "Names of the snapshots taken on top of this one.",
+						},
+						"total_size_bytes": schema.Int64Attribute{
+							Computed:    true,
+							Description: "Snapshot size in bytes, null when Multipass does not report it.",
+						},
 					},
 				},
 			},
@@ -102,28 +137,56 @@ func (d *snapshotsDataSource) Read(ctx context.Context, req datasource.ReadReque
 		nameFilter = config.Name.ValueString()
 	}
 
-	snapshots, err := d.client.ListSnapshots(ctx, instance)
+	// Snapshot details come back ordered by creation time, oldest first.
+	snapshots, err := d.client.ListSnapshotDetails(ctx, instance)
 	if err != nil {
 		resp.Diagnostics.AddError("Failed to list snapshots", err.Error())
 		return
 	}
 
+	switch config.Sort.ValueString() {
+	case snapshotSortCreatedDesc:
+		for i, j := 0, len(snapshots)-1; i < j; i, j = i+1, j-1 {
+			snapshots[i], snapshots[j] = snapshots[j], snapshots[i]
+		}
+	case snapshotSortName:
+		sort.SliceStable(snapshots, func(i, j int) bool {
+			return snapshots[i].Name < snapshots[j].Name
+		})
+	}
+
 	result := make([]snapshotModelInfo, 0, len(snapshots))
 	for _, s := range snapshots {
 		if nameFilter != "" && s.Name != nameFilter {
 			continue
 		}
-		result = append(result, snapshotModelInfo{
-			Instance: types.StringValue(s.Instance),
-			Name:     types.StringValue(s.Name),
-			Comment:  types.StringValue(s.Comment),
-			Parent:   types.StringValue(s.Parent),
-		})
+		children, diags := types.ListValueFrom(ctx, types.StringType, append([]string{}, s.Children...))
+		resp.Diagnostics.Append(diags...)
+		info := snapshotModelInfo{
+			Instance:       types.StringValue(s.Instance),
+			Name:           types.StringValue(s.Name),
+			Comment:        types.StringValue(s.Comment),
+			Parent:         types.StringValue(s.Parent),
+			CreatedAt:      types.StringNull(),
+			Children:       children,
+			TotalSizeBytes: types.Int64Null(),
+		}
+		if !s.Created.IsZero() {
+			info.CreatedAt = types.StringValue(s.Created.UTC().Format(time.RFC3339))
+		}
+		if s.Size > 0 {
+			info.TotalSizeBytes = types.Int64Value(int64(s.Size))
+		}
+		result = append(result, info)
+	}
+	if resp.Diagnostics.HasError() {
+		return
 	}
 
 	state := snapshotsDataSourceModel{
 		Instance:  config.Instance,
 		Name:      config.Name,
+		Sort:      config.Sort,
 		Snapshots: result,
 	}
 
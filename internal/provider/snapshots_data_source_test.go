@@ -0,0 +1,101 @@
+package provider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
+)
+
+// readSnapshots runs snapshotsDataSource.Read for instance "vm" with the
+// given sort and returns the snapshots it reports.
+func readSnapshots(t *testing.T, listing []models.Snapshot, sortOrder types.String) []snapshotModelInfo {
+	t.Helper()
+	ctx := context.Background()
+
+	d := &snapshotsDataSource{client: &fakeClient{listSnapshots: func(string) ([]models.Snapshot, error) {
+		return append([]models.Snapshot{}, listing...), nil
+	}}}
+	var schemaResp datasource.SchemaResponse
+	d.Schema(ctx, datasource.SchemaRequest{}, &schemaResp)
+
+	config := tfsdk.Config{Schema: schemaResp.Schema}
+	configState := tfsdk.State{Schema: schemaResp.Schema}
+	if diags := configState.Set(ctx, &snapshotsDataSourceModel{
+		Instance: types.StringValue("vm"),
+		Name:     types.StringNull(),
+		Sort:     sortOrder,
+	}); diags.HasError() {
+		t.Fatalf("set config: %v", diags)
+	}
+	config.Raw = configState.Raw
+
+	resp := datasource.ReadResponse{State: tfsdk.State{
+		Schema: schemaResp.Schema,
+		Raw:    tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil),
+	}}
+	d.Read(ctx, datasource.ReadRequest{Config: config}, &resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+	}
+	var state snapshotsDataSourceModel
+	resp.Diagnostics.Append(resp.State.Get(ctx, &state)...)
+	return state.Snapshots
+}
+
+func TestSnapshotsDataSourceRead(t *testing.T) {
+	t.Parallel()
+
+	// ListSnapshotDetails reports snapshots oldest first.
+	listing := []models.Snapshot{
+		{Instance: "vm", Name: "b-base", Children: []string{"a-next"}, Created: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), Size: 2048},
+		{Instance: "vm", Name: "a-next", Parent: "b-base"},
+	}
+
+	t.Run("details", func(t *testing.T) {
+		got := readSnapshots(t, listing, types.StringNull())
+		if len(got) != 2 {
+			t.Fatalf("expected 2 snapshots, got %d", len(got))
+		}
+		first := got[0]
+		if first.CreatedAt.ValueString() != "2024-05-01T10:00:00Z" || first.TotalSizeBytes.ValueInt64() != 2048 {
+			t.Fatalf("unexpected details: %+v", first)
+		}
+		var children []string
+		first.Children.ElementsAs(context.Background(), &children, false)
+		if diff := cmp.Diff([]string{"a-next"}, children); diff != "" {
+			t.Fatalf("unexpected children: %s", diff)
+		}
+		if second := got[1]; !second.CreatedAt.IsNull() || !second.TotalSizeBytes.IsNull() {
+			t.Fatalf("expected unreported details to be null: %+v", second)
+		}
+	})
+
+	for sortOrder, want := range map[string][]string{
+		"":             {"b-base", "a-next"},
+		"created_asc":  {"b-base", "a-next"},
+		"created_desc": {"a-next", "b-base"},
+		"name":         {"a-next", "b-base"},
+	} {
+		t.Run("sort "+sortOrder, func(t *testing.T) {
+			value := types.StringValue(sortOrder)
+			if sortOrder == "" {
+				value = types.StringNull()
+			}
+			var names []string
+			for _, s := range readSnapshots(t, listing, value) {
+				names = append(names, s.Name.ValueString())
+			}
+			if diff := cmp.Diff(want, names); diff != "" {
+				t.Fatalf("unexpected order: %s", diff)
+			}
+		})
+	}
+}
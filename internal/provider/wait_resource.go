@@ -0,0 +1,300 @@
+package provider
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/listvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+var (
+	_ resource.Resource              = (*waitResource)(nil)
+	_ resource.ResourceWithConfigure = (*waitResource)(nil)
+)
+
+const (
+	defaultWaitInterval = 5 * time.Second
+	defaultWaitTimeout  = 5 * time.Minute
+)
+
+// NewWaitResource instantiates the wait resource.
+func NewWaitResource() resource.Resource {
+	return &waitResource{}
+}
+
+type waitResource struct {
+	client multipasscli.Client
+}
+
+type waitResourceModel struct {
+	ID               types.String `tfsdk:"id"`
+	Instance         types.String `tfsdk:"instance"`
+	ConditionCommand types.List   `tfsdk:"condition_command"`
+	ConditionPort    types.Int64  `tfsdk:"condition_port"`
+	ConditionFile    types.String `tfsdk:"condition_file"`
+	Interval         types.Int64  `tfsdk:"interval"`
+	Timeout          types.Int64  `tfsdk:"timeout"`
+	Triggers         types.Map    `tfsdk:"triggers"`
+}
+
+func (r *waitResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_wait"
+}
+
+func (r *waitResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	oneOf := []path.Expression{
+		path.MatchRelative().AtParent().AtName("condition_command"),
+		path.MatchRelative().AtParent().AtName("condition_port"),
+		path.MatchRelative().AtParent().AtName("condition_file"),
+	}
+
+	resp.Schema = schema.Schema{
+		Description:         "Blocks until a condition inside a Multipass instance is met, so that other resources can depend on it. Refresh does nothing.",
+		MarkdownDescription: "Blocks until a condition inside a Multipass instance is met, so that other resources can depend on it. The condition is checked on create, and again whenever it or `triggers` change. Refresh does nothing, so it stays fast.",
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:    true,
+				Description: "Identifier in the form `<instance>/<random suffix>`.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"instance": schema.StringAttribute{
+				Required:    true,
+				Description: "Instance to check. Changing forces recreation.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"condition_command": schema.ListAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "Command and arguments, run without a shell; exit status 0 means ready. Exactly one of `condition_command`, `condition_port` or `condition_file` is required.",
+				Validators: []validator.List{
+					listvalidator.ExactlyOneOf(oneOf...),
+					listvalidator.SizeAtLeast(1),
+				},
+			},
+			"condition_port": schema.Int64Attribute{
+				Optional:    true,
+				Description: "TCP port that must accept connections on 127.0.0.1 inside the instance. Exactly one of `condition_command`, `condition_port` or `condition_file` is required.",
+				Validators: []validator.Int64{
+					int64validator.ExactlyOneOf(oneOf...),
+					int64validator.Between(1, 65535),
+				},
+			},
+			"condition_file": schema.StringAttribute{
+				Optional:    true,
+				Description: "Path inside the instance that must exist. Exactly one of `condition_command`, `condition_port` or `condition_file` is required.",
+				Validators: []validator.String{
+					stringvalidator.ExactlyOneOf(oneOf...),
+					stringvalidator.LengthAtLeast(1),
+				},
+			},
+			"interval": schema.Int64Attribute{
+				Optional:    true,
+				Description: "Seconds between checks. Defaults to 5.",
+				Validators: []validator.Int64{
+					int64validator.AtLeast(1),
+				},
+			},
+			"timeout": schema.Int64Attribute{
+				Optional:    true,
+				Description: "Maximum seconds to wait, including for the instance to be running. Defaults to 300.",
+				Validators: []validator.Int64{
+					int64validator.AtLeast(1),
+				},
+			},
+			"triggers": schema.MapAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "Map of arbitrary values that, when changed, wait for the condition again.",
+			},
+		},
+	}
+}
+
+func (r *waitResource) Configure(_ context.Context, req resource.ConfigureRequest, _ *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	data := req.ProviderData.(providerData)
+	r.client = data.client
+}
+
+func (r *waitResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var plan waitResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(r.wait(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		resp.Diagnostics.AddError("Failed to generate resource id", err.Error())
+		return
+	}
+	plan.ID = types.StringValue(plan.Instance.ValueString() + "/" + hex.EncodeToString(suffix))
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+// Read does nothing: the condition was met when it was last checked, and
+// checking it again on every refresh would make plans slow.
+func (r *waitResource) Read(context.Context, resource.ReadRequest, *resource.ReadResponse) {}
+
+func (r *waitResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var plan waitResourceModel
+	var state waitResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if waitNeedsRun(ctx, &plan, &state) {
+		resp.Diagnostics.Append(r.wait(ctx, &plan)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+	plan.ID = state.ID
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+// Delete only removes the resource from state.
+func (r *waitResource) Delete(context.Context, resource.DeleteRequest, *resource.DeleteResponse) {}
+
+// wait blocks until the instance is running and the condition in model holds.
+func (r *waitResource) wait(ctx context.Context, model *waitResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+	if r.client == nil {
+		diags.AddError("Client not configured", "Multipass client is nil.")
+		return diags
+	}
+
+	command, d := waitConditionCommand(ctx, model)
+	diags.Append(d...)
+	if diags.HasError() {
+		return diags
+	}
+
+	interval := defaultWaitInterval
+	if !model.Interval.IsNull() && !model.Interval.IsUnknown() {
+		interval = time.Duration(model.Interval.ValueInt64()) * time.Second
+	}
+	timeout := defaultWaitTimeout
+	if !model.Timeout.IsNull() && !model.Timeout.IsUnknown() {
+		timeout = time.Duration(model.Timeout.ValueInt64()) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	instance := model.Instance.ValueString()
+	if _, err := waitForRunning(ctx, r.client, instance, false, timeout); err != nil {
+		if errors.Is(err, multipasscli.ErrNotFound) {
+			diags.AddError("Instance not found", fmt.Sprintf("Instance %q does not exist.", instance))
+			return diags
+		}
+		diags.AddError("Instance not running", err.Error())
+		return diags
+	}
+
+	if err := waitForCondition(ctx, r.client, instance, command, interval); err != nil {
+		diags.AddError("Condition not met", fmt.Sprintf("Waited %s for the condition in %q: %s", timeout, instance, err))
+	}
+	return diags
+}
+
+// waitConditionCommand turns the configured condition into the command that
+// checks it.
+func waitConditionCommand(ctx context.Context, model *waitResourceModel) ([]string, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	switch {
+	case !model.ConditionCommand.IsNull():
+		var command []string
+		diags.Append(model.ConditionCommand.ElementsAs(ctx, &command, false)...)
+		return command, diags
+	case !model.ConditionPort.IsNull():
+		return tcpProbeCommand(model.ConditionPort.ValueInt64()), diags
+	default:
+		return []string{"test", "-e", model.ConditionFile.ValueString()}, diags
+	}
+}
+
+// waitForCondition runs command every interval until it exits with status
+// 0 or ctx is done. The error then carries the output of the last attempt.
+func waitForCondition(ctx context.Context, client multipasscli.Client, instance string, command []string, interval time.Duration) error {
+	last := "no attempt finished"
+	for {
+		result, err := client.ExecOutput(ctx, instance, multipasscli.ExecOptions{Command: command, ReadOnly: true})
+		switch {
+		case err != nil && ctx.Err() != nil:
+			// Cut short by the timeout; keep the previous attempt's output.
+		case err != nil:
+			// exec fails while sshd is still starting; keep trying.
+			last = err.Error()
+		case result.ExitCode == 0:
+			return nil
+		default:
+			last = execFailure(result)
+		}
+		tflog.Debug(ctx, "Condition not met yet", map[string]any{"instance": instance, "command": command, "last": last})
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("the last check %s", last)
+		case <-time.After(interval):
+		}
+	}
+}
+
+// tcpProbeCommand checks that port accepts connections on 127.0.0.1.
+// bash's /dev/tcp avoids depending on nc or ss being installed.
+func tcpProbeCommand(port int64) []string {
+	return []string{"bash", "-c", fmt.Sprintf("exec 3<>/dev/tcp/127.0.0.1/%d", port)}
+}
+
+// execFailure describes a command that exited with a non-zero status,
+// including its output.
+func execFailure(result multipasscli.ExecResult) string {
+	last := fmt.Sprintf("exited with status %d", result.ExitCode)
+	out := strings.TrimSpace(execOutputString(result.Stdout, true) + "\n" + execOutputString(result.Stderr, true))
+	if out != "" {
+		last += ": " + out
+	}
+	return last
+}
+
+// waitNeedsRun reports whether an update changes the condition or triggers.
+// interval and timeout only affect later waits.
+func waitNeedsRun(ctx context.Context, plan, state *waitResourceModel) bool {
+	return !plan.ConditionCommand.Equal(state.ConditionCommand) ||
+		!plan.ConditionPort.Equal(state.ConditionPort) ||
+		!plan.ConditionFile.Equal(state.ConditionFile) ||
+		!mapsEqual(ctx, plan.Triggers, state.Triggers)
+}
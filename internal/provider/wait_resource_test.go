@@ -0,0 +1,164 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+
+	"github.com/todoroff/terraform-provider-multipass/internal/models"
+	"github.com/todoroff/terraform-provider-multipass/internal/multipasscli"
+)
+
+func TestWaitForCondition(t *testing.T) {
+	t.Parallel()
+
+	t.Run("retries until ready", func(t *testing.T) {
+		attempts := 0
+		client := &fakeClient{execOutput: func(string, multipasscli.ExecOptions) (multipasscli.ExecResult, error) {
+			attempts++
+			switch attempts {
+			case 1:
+				return multipasscli.ExecResult{}, errors.New("ssh: connection refused")
+			case 2:
+				return multipasscli.ExecResult{ExitCode: 1}, nil
+			}
+			return multipasscli.ExecResult{}, nil
+		}}
+		if err := waitForCondition(context.Background(), client, "vm", []string{"test", "-e", "/ready"}, time.Millisecond); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []string{"exec-output vm test -e /ready", "exec-output vm test -e /ready", "exec-output vm test -e /ready"}
+		if diff := cmp.Diff(want, client.recorded()); diff != "" {
+			t.Fatalf("unexpected calls (-want +got): %s", diff)
+		}
+	})
+
+	t.Run("times out with the last output", func(t *testing.T) {
+		client := &fakeClient{execOutput: func(string, multipasscli.ExecOptions) (multipasscli.ExecResult, error) {
+			return multipasscli.ExecResult{ExitCode: 2, Stderr: []byte("curl: (7) Failed to connect\n")}, nil
+		}}
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		err := waitForCondition(ctx, client, "vm", []string{"curl", "-f", "localhost"}, time.Millisecond)
+		if err == nil || !strings.Contains(err.Error(), "exited with status 2: curl: (7) Failed to connect") {
+			t.Fatalf("expected the last probe output in the error, got %v", err)
+		}
+	})
+}
+
+func TestWaitConditionCommand(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	command, _ := types.ListValueFrom(ctx, types.StringType, []string{"cloud-init", "status", "--wait"})
+	for _, tc := range []struct {
+		name  string
+		model waitResourceModel
+		want  []string
+	}{
+		{
+			name:  "command",
+			model: waitResourceModel{ConditionCommand: command, ConditionPort: types.Int64Null(), ConditionFile: types.StringNull()},
+			want:  []string{"cloud-init", "status", "--wait"},
+		},
+		{
+			name:  "port",
+			model: waitResourceModel{ConditionCommand: types.ListNull(types.StringType), ConditionPort: types.Int64Value(8080), ConditionFile: types.StringNull()},
+			want:  []string{"bash", "-c", "exec 3<>/dev/tcp/127.0.0.1/8080"},
+		},
+		{
+			name:  "file",
+			model: waitResourceModel{ConditionCommand: types.ListNull(types.StringType), ConditionPort: types.Int64Null(), ConditionFile: types.StringValue("/var/lib/app/ready")},
+			want:  []string{"test", "-e", "/var/lib/app/ready"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, diags := waitConditionCommand(ctx, &tc.model)
+			if diags.HasError() {
+				t.Fatalf("unexpected diagnostics: %v", diags)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Fatalf("unexpected command (-want +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestWaitCreate(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	for _, tc := range []struct {
+		name      string
+		instances []models.Instance
+		wantCalls []string
+		wantError string
+	}{
+		{
+			name:      "condition met",
+			instances: []models.Instance{{Name: "vm", State: "Running"}},
+			wantCalls: []string{"list", "exec-output vm test -e /ready"},
+		},
+		{
+			name:      "missing instance",
+			wantCalls: []string{"list"},
+			wantError: "Instance not found",
+		},
+		{
+			name:      "stopped instance",
+			instances: []models.Instance{{Name: "vm", State: "Stopped"}},
+			wantCalls: []string{"list"},
+			wantError: "Instance not running",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &fakeClient{listInstances: func(bool) ([]models.Instance, error) { return tc.instances, nil }}
+			r := &waitResource{client: client}
+			var schemaResp resource.SchemaResponse
+			r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+			plan := waitResourceModel{
+				ID:               types.StringUnknown(),
+				Instance:         types.StringValue("vm"),
+				ConditionCommand: types.ListNull(types.StringType),
+				ConditionPort:    types.Int64Null(),
+				ConditionFile:    types.StringValue("/ready"),
+				Interval:         types.Int64Null(),
+				Timeout:          types.Int64Null(),
+				Triggers:         types.MapNull(types.StringType),
+			}
+			tfPlan := tfsdk.Plan{Schema: schemaResp.Schema}
+			if diags := tfPlan.Set(ctx, &plan); diags.HasError() {
+				t.Fatalf("set plan: %v", diags)
+			}
+			resp := resource.CreateResponse{State: tfsdk.State{Schema: schemaResp.Schema, Raw: tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil)}}
+			r.Create(ctx, resource.CreateRequest{Plan: tfPlan}, &resp)
+
+			if diff := cmp.Diff(tc.wantCalls, client.recorded()); diff != "" {
+				t.Fatalf("unexpected calls (-want +got): %s", diff)
+			}
+			if tc.wantError != "" {
+				if !resp.Diagnostics.HasError() || resp.Diagnostics.Errors()[0].Summary() != tc.wantError {
+					t.Fatalf("expected %q, got %v", tc.wantError, resp.Diagnostics)
+				}
+				return
+			}
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+			}
+			var got waitResourceModel
+			resp.State.Get(ctx, &got)
+			if !strings.HasPrefix(got.ID.ValueString(), "vm/") {
+				t.Fatalf("unexpected id %q", got.ID.ValueString())
+			}
+		})
+	}
+}